@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/interactsh/internal/assert"
+	"github.com/projectdiscovery/interactsh/pkg/client"
+)
+
+// Exit codes of the assert subcommand
+const (
+	assertPass  = 0
+	assertFail  = 1
+	assertError = 2
+)
+
+// runAssertCommand runs the assert subcommand and reports whether args named it
+func runAssertCommand(args []string) bool {
+	if len(args) == 0 || args[0] != "assert" {
+		return false
+	}
+	flagSet := flag.NewFlagSet(args[0], flag.ExitOnError)
+	specPath := flagSet.String("spec", "", "yaml file of the expected interactions")
+	serverURL := flagSet.String("server", client.DefaultOptions.ServerURL, "interactsh server(s) to use")
+	token := flagSet.String("token", "", "authentication token to connect protected interactsh server")
+	pollInterval := flagSet.Duration("poll-interval", time.Second, "poll interval to pull interaction data")
+	_ = flagSet.Parse(args[1:])
+
+	result, code := runAssert(*specPath, *serverURL, *token, *pollInterval)
+	data, _ := jsoniter.Marshal(result)
+	fmt.Println(string(data))
+	if code != assertPass {
+		os.Exit(code)
+	}
+	return true
+}
+
+// runAssert generates a payload, runs the trigger of the spec and waits for
+// the expected interactions, returning the outcome and the exit code
+func runAssert(specPath, serverURL, token string, pollInterval time.Duration) (*assert.Result, int) {
+	if specPath == "" {
+		return &assert.Result{Error: "a spec file must be specified with -spec"}, assertError
+	}
+	spec, err := assert.Load(specPath)
+	if err != nil {
+		return &assert.Result{Error: fmt.Sprintf("could not load spec: %s", err)}, assertError
+	}
+	interactsh, err := client.New(&client.Options{ServerURL: serverURL, Token: token})
+	if err != nil {
+		return &assert.Result{Error: fmt.Sprintf("could not create client: %s", err)}, assertError
+	}
+	defer func() {
+		_ = interactsh.Close()
+	}()
+
+	start := time.Now()
+	payload := interactsh.URL()
+	gologger.Info().Msgf("%s\n", payload)
+	tracker := assert.NewTracker(spec)
+	if err := interactsh.StartPolling(pollInterval, tracker.Add); err != nil {
+		return &assert.Result{Payload: payload, Error: fmt.Sprintf("could not poll: %s", err)}, assertError
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), spec.Timeout)
+	defer cancel()
+	if command := spec.Command(payload); len(command) > 0 {
+		cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+		// the standard output is left to the result
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return &assert.Result{Payload: payload, Error: fmt.Sprintf("could not run trigger: %s", err)}, assertError
+		}
+	}
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for !tracker.Done() && ctx.Err() == nil {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+		}
+	}
+	_ = interactsh.StopPolling()
+	result := tracker.Result(payload, time.Since(start))
+	return result, assertExitCode(result)
+}
+
+func assertExitCode(result *assert.Result) int {
+	if result.Pass {
+		return assertPass
+	}
+	return assertFail
+}
@@ -2,26 +2,36 @@ package main
 
 import (
 	"bytes"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"syscall"
 	"time"
 
 	jsoniter "github.com/json-iterator/go"
 	"github.com/projectdiscovery/goflags"
 	"github.com/projectdiscovery/gologger"
 	"github.com/projectdiscovery/gologger/levels"
+	"github.com/projectdiscovery/gologger/writer"
+	"github.com/projectdiscovery/interactsh/internal/daemon"
+	"github.com/projectdiscovery/interactsh/internal/output"
 	"github.com/projectdiscovery/interactsh/internal/runner"
+	"github.com/projectdiscovery/interactsh/internal/secrets"
+	"github.com/projectdiscovery/interactsh/internal/tui"
 	"github.com/projectdiscovery/interactsh/pkg/client"
 	"github.com/projectdiscovery/interactsh/pkg/options"
 	"github.com/projectdiscovery/interactsh/pkg/server"
 	"github.com/projectdiscovery/interactsh/pkg/settings"
+	"github.com/projectdiscovery/interactsh/pkg/storage"
 	fileutil "github.com/projectdiscovery/utils/file"
 	folderutil "github.com/projectdiscovery/utils/folder"
 	updateutils "github.com/projectdiscovery/utils/update"
+	"golang.org/x/crypto/openpgp"
 )
 
 var (
@@ -31,6 +41,9 @@ var (
 
 func main() {
 	gologger.DefaultLogger.SetMaxLevel(levels.LevelVerbose)
+	if runAssertCommand(os.Args[1:]) || runStatsCommand(os.Args[1:]) {
+		return
+	}
 
 	defaultOpts := client.DefaultOptions
 	cliOptions := &options.CLIClientOptions{}
@@ -50,10 +63,26 @@ func main() {
 		flagSet.BoolVarP(&cliOptions.DisableHTTPFallback, "no-http-fallback", "nf", false, "disable http fallback registration"),
 		flagSet.IntVarP(&cliOptions.CorrelationIdLength, "correlation-id-length", "cidl", settings.CorrelationIdLengthDefault, "length of the correlation id preamble"),
 		flagSet.IntVarP(&cliOptions.CorrelationIdNonceLength, "correlation-id-nonce-length", "cidn", settings.CorrelationIdNonceLengthDefault, "length of the correlation id nonce"),
+		flagSet.BoolVarP(&cliOptions.CorrelationIdChecksum, "correlation-id-checksum", "cidc", false, "end the correlation id nonce with a checksum, required by servers validating checksums"),
 		flagSet.StringVarP(&cliOptions.SessionFile, "session-file", "sf", "", "store/read from session file"),
+		flagSet.StringVar(&cliOptions.CNAME, "cname", "", "external host the payloads resolve to through a cname record"),
+		flagSet.StringSliceVarP(&cliOptions.DNSScripts, "dns-script", "dsc", nil, "addresses answered in turn to the lookups of a label before the payloads (label=ip,ip), eg. rebind1=203.0.113.10,127.0.0.1", goflags.StringSliceOptions),
+		flagSet.StringVarP(&cliOptions.HTTPTemplate, "http-template", "ht", "", "yaml file of the http response (status, headers, content-type, body) served to the requests of the payloads"),
+		flagSet.StringSliceVarP(&cliOptions.PayloadFiles, "payload-file", "plf", nil, "file(s) hosted for the session under /<correlation-id>/<name> (eg. xss.js, xxe.dtd)", goflags.CommaSeparatedStringSliceOptions),
 		flagSet.DurationVarP(&cliOptions.KeepAliveInterval, "keep-alive-interval", "kai", time.Minute, "keep alive interval"),
 	)
 
+	flagSet.CreateGroup("share", "Share",
+		flagSet.StringSliceVar(&cliOptions.Share, "share", nil, "teammate public key(s) to grant read access to the session", goflags.FileCommaSeparatedStringSliceOptions),
+		flagSet.BoolVarP(&cliOptions.ShareKeygen, "share-keygen", "skg", false, "generate a key pair into the session file to join a shared session"),
+		flagSet.StringVar(&cliOptions.Join, "join", "", "share token received from the session owner (requires -sf with generated keys)"),
+		flagSet.StringVar(&cliOptions.EscrowURL, "escrow-url", "", "team keyserver url to escrow the session to (or recover it from)"),
+		flagSet.StringSliceVar(&cliOptions.EscrowKeys, "escrow-key", nil, "armored pgp public key file(s) of the teammates allowed to recover the escrowed session", goflags.CommaSeparatedStringSliceOptions),
+		flagSet.StringVar(&cliOptions.EscrowRecover, "escrow-recover", "", "correlation id of an escrowed session to recover into the session file (requires -escrow-url, -escrow-identity and -sf)"),
+		flagSet.StringVar(&cliOptions.EscrowIdentity, "escrow-identity", "", "armored pgp private key file decrypting recovered sessions (passphrase from INTERACTSH_ESCROW_PASSPHRASE)"),
+		flagSet.BoolVar(&cliOptions.Inspect, "inspect", false, "print a browsable url showing the recent http interactions of the session"),
+	)
+
 	flagSet.CreateGroup("filter", "Filter",
 		flagSet.StringSliceVarP(&cliOptions.Match, "match", "m", nil, "match interaction based on the specified pattern", goflags.FileCommaSeparatedStringSliceOptions),
 		flagSet.StringSliceVarP(&cliOptions.Filter, "filter", "f", nil, "filter interaction based on the specified pattern", goflags.FileCommaSeparatedStringSliceOptions),
@@ -61,6 +90,21 @@ func main() {
 		flagSet.BoolVar(&cliOptions.HTTPOnly, "http-only", false, "display only http interaction in CLI output"),
 		flagSet.BoolVar(&cliOptions.SmtpOnly, "smtp-only", false, "display only smtp interactions in CLI output"),
 		flagSet.BoolVar(&cliOptions.Asn, "asn", false, " include asn information of remote ip in json output"),
+		flagSet.BoolVarP(&cliOptions.VerifyDNS, "verify-dns", "vd", false, "flag http and smtp interactions not preceded by a dns resolution of the payload"),
+		flagSet.IntVarP(&cliOptions.VerifyDNSGrace, "verify-dns-grace", "vdg", 10, "seconds to wait for the dns resolution of an interaction to be polled"),
+		flagSet.StringSliceVarP(&cliOptions.VerifyWebhook, "verify-webhook", "vwh", nil, "secret(s) to verify github, stripe and slack webhook signatures with (name=secret, secret reference or file)", goflags.FileStringSliceOptions),
+	)
+
+	flagSet.CreateGroup("daemon", "Daemon",
+		flagSet.StringVar(&cliOptions.Service, "service", "", fmt.Sprintf("manage the client as a system service with the given flags (%s)", strings.Join(daemon.Actions, ","))),
+		flagSet.StringVarP(&cliOptions.ServiceName, "service-name", "sn", "interactsh-client", "name of the system service"),
+		flagSet.StringVar(&cliOptions.Database, "db", "", "local sqlite database file to persist interactions"),
+		flagSet.StringVarP(&cliOptions.Webhook, "webhook", "wh", "", "webhook url to notify interactions as json"),
+		flagSet.StringVarP(&cliOptions.WebhookDeadLetter, "webhook-dead-letter", "whdl", "", "file to append interactions the webhook could not be notified of after retries"),
+		flagSet.StringVarP(&cliOptions.WebhookRate, "webhook-rate", "whr", "", "global rate of webhook notifications, the exceeding interactions being posted as digests (eg. 30/m)"),
+		flagSet.StringSliceVarP(&cliOptions.WebhookProviderRates, "webhook-provider-rate", "whpr", nil, "rate of the webhook notifications of a provider host, overriding the defaults (host=rate, eg. hooks.slack.com=1/s)", goflags.CommaSeparatedStringSliceOptions),
+		flagSet.DurationVarP(&cliOptions.WebhookBatch, "webhook-batch", "whb", 0, "time to collect interactions for before posting them as a webhook digest"),
+		flagSet.StringSliceVar(&cliOptions.Plugins, "plugin", nil, "command to run for each interaction and at session end with the event json on stdin", goflags.StringSliceOptions),
 	)
 
 	flagSet.CreateGroup("update", "Update",
@@ -71,10 +115,15 @@ func main() {
 	flagSet.CreateGroup("output", "Output",
 		flagSet.StringVar(&cliOptions.Output, "o", "", "output file to write interaction data"),
 		flagSet.BoolVar(&cliOptions.JSON, "json", false, "write output in JSONL(ines) format"),
+		flagSet.StringVarP(&cliOptions.SaveBodies, "save-bodies", "sbd", "", "directory to download the truncated http bodies stored by the server to (named by sha256)"),
 		flagSet.BoolVarP(&cliOptions.StorePayload, "payload-store", "ps", false, "write generated interactsh payload to file"),
 		flagSet.StringVarP(&cliOptions.StorePayloadFile, "payload-store-file", "psf", settings.StorePayloadFileDefault, "store generated interactsh payloads to given file"),
+		flagSet.BoolVarP(&cliOptions.UsageReport, "usage-report", "ur", false, "report generated payloads which never received interactions and their time to first interaction at exit"),
+		flagSet.StringVarP(&cliOptions.UsageReportFile, "usage-report-file", "urf", "", "write payloads which never received interactions to given file"),
+		flagSet.StringVarP(&cliOptions.StatsFile, "stats-file", "stf", "", "write per protocol session statistics to given file"),
 
 		flagSet.BoolVar(&cliOptions.Verbose, "v", false, "display verbose interaction"),
+		flagSet.BoolVarP(&cliOptions.Watch, "watch", "w", false, "display interactions in an interactive terminal ui (output file is written in JSONL format)"),
 	)
 
 	flagSet.CreateGroup("debug", "Debug",
@@ -115,8 +164,27 @@ func main() {
 		}
 	}
 
+	if cliOptions.Service != "" {
+		serviceOptions := &daemon.Options{Name: cliOptions.ServiceName, Description: "Interactsh client"}
+		if strings.EqualFold(cliOptions.Service, "install") {
+			if cliOptions.SessionFile == "" {
+				gologger.Fatal().Msgf("A session file must be specified to resume the session across service restarts\n")
+			}
+			serviceOptions.Args = serviceArgs(os.Args[1:])
+		}
+		if err := daemon.Control(cliOptions.Service, serviceOptions); err != nil {
+			gologger.Fatal().Msgf("Could not %s service: %s\n", cliOptions.Service, err)
+		}
+		gologger.Info().Msgf("Service %s: %s successful\n", cliOptions.ServiceName, cliOptions.Service)
+		os.Exit(0)
+	}
+
+	webhookVerifier, err := newWebhookVerifier(cliOptions)
+	if err != nil {
+		gologger.Fatal().Msgf("Could not load webhook secrets: %s\n", err)
+	}
+
 	var outputFile *os.File
-	var err error
 	if cliOptions.Output != "" {
 		if outputFile, err = os.Create(cliOptions.Output); err != nil {
 			gologger.Fatal().Msgf("Could not create output file: %s\n", err)
@@ -124,24 +192,187 @@ func main() {
 		defer outputFile.Close()
 	}
 
+	if cliOptions.ShareKeygen {
+		if cliOptions.SessionFile == "" {
+			gologger.Fatal().Msgf("A session file must be specified to store the generated keys\n")
+		}
+		sessionInfo, err := client.GenerateShareKeys()
+		if err != nil {
+			gologger.Fatal().Msgf("Could not generate share keys: %s\n", err)
+		}
+		if err := fileutil.Marshal(fileutil.YAML, []byte(cliOptions.SessionFile), sessionInfo); err != nil {
+			gologger.Fatal().Msgf("Could not write session file: %s\n", err)
+		}
+		gologger.Info().Msgf("Send the following public key to the session owner:\n")
+		gologger.Silent().Msgf("%s\n", sessionInfo.PublicKey)
+		os.Exit(0)
+	}
+
+	if cliOptions.EscrowRecover != "" {
+		if cliOptions.EscrowURL == "" || cliOptions.EscrowIdentity == "" || cliOptions.SessionFile == "" {
+			gologger.Fatal().Msgf("Recovering an escrowed session requires -escrow-url, -escrow-identity and a session file\n")
+		}
+		keyring, err := client.ReadPGPKeys(cliOptions.EscrowIdentity)
+		if err != nil {
+			gologger.Fatal().Msgf("Could not read escrow identity: %s\n", err)
+		}
+		recovered, err := client.RecoverSession(cliOptions.EscrowURL, cliOptions.EscrowRecover, keyring, []byte(os.Getenv("INTERACTSH_ESCROW_PASSPHRASE")))
+		if err != nil {
+			gologger.Fatal().Msgf("Could not recover session: %s\n", err)
+		}
+		if err := fileutil.Marshal(fileutil.YAML, []byte(cliOptions.SessionFile), recovered); err != nil {
+			gologger.Fatal().Msgf("Could not write session file: %s\n", err)
+		}
+		gologger.Info().Msgf("Recovered session %s into %s\n", recovered.CorrelationID, cliOptions.SessionFile)
+	}
+
 	var sessionInfo *options.SessionInfo
 	if fileutil.FileExists(cliOptions.SessionFile) {
 		// attempt to load session info - silently ignore on failure
 		_ = fileutil.Unmarshal(fileutil.YAML, []byte(cliOptions.SessionFile), &sessionInfo)
 	}
 
+	if cliOptions.Join != "" {
+		if sessionInfo == nil || sessionInfo.PrivateKey == "" {
+			gologger.Fatal().Msgf("Joining a shared session requires a session file with keys generated by -share-keygen\n")
+		}
+		sharedInfo, err := options.ParseShareToken(cliOptions.Join)
+		if err != nil {
+			gologger.Fatal().Msgf("Could not parse share token: %s\n", err)
+		}
+		if sharedInfo.PublicKey != sessionInfo.PublicKey {
+			gologger.Fatal().Msgf("The share token was issued for a different public key\n")
+		}
+		sharedInfo.PrivateKey = sessionInfo.PrivateKey
+		sessionInfo = sharedInfo
+	}
+
+	var escrowRecipients openpgp.EntityList
+	if cliOptions.EscrowURL != "" && len(cliOptions.EscrowKeys) > 0 {
+		if escrowRecipients, err = client.ReadPGPKeys(cliOptions.EscrowKeys...); err != nil {
+			gologger.Fatal().Msgf("Could not read escrow keys: %s\n", err)
+		}
+	}
+
+	var httpTemplate *storage.HTTPTemplate
+	if cliOptions.HTTPTemplate != "" {
+		if httpTemplate, err = client.ReadHTTPTemplate(cliOptions.HTTPTemplate); err != nil {
+			gologger.Fatal().Msgf("Could not read http template: %s\n", err)
+		}
+	}
+
+	// the usage tracks the payloads generated by the client from the start
+	var usage *output.Usage
+	var onPayload func(payload, uniqueID string)
+	if cliOptions.UsageReport || cliOptions.UsageReportFile != "" {
+		usage = output.NewUsage(cliOptions.UsageReportFile)
+		onPayload = usage.Emit
+	}
 	client, err := client.New(&client.Options{
 		ServerURL:                cliOptions.ServerURL,
 		Token:                    cliOptions.Token,
 		DisableHTTPFallback:      cliOptions.DisableHTTPFallback,
 		CorrelationIdLength:      cliOptions.CorrelationIdLength,
 		CorrelationIdNonceLength: cliOptions.CorrelationIdNonceLength,
+		CorrelationIdChecksum:    cliOptions.CorrelationIdChecksum,
 		SessionInfo:              sessionInfo,
+		OnPayload:                onPayload,
 	})
 	if err != nil {
 		gologger.Fatal().Msgf("Could not create client: %s\n", err)
 	}
 
+	// persist the session right away so that a supervised client resumes it after a crash
+	if cliOptions.SessionFile != "" {
+		if err := client.SaveSessionTo(cliOptions.SessionFile); err != nil {
+			gologger.Warning().Msgf("Could not save session file: %s\n", err)
+		}
+	}
+	if len(escrowRecipients) > 0 {
+		if err := client.EscrowSession(cliOptions.EscrowURL, escrowRecipients); err != nil {
+			gologger.Warning().Msgf("Could not escrow session: %s\n", err)
+		} else {
+			gologger.Info().Msgf("Session escrowed for %d teammate key(s)\n", len(escrowRecipients))
+		}
+	}
+
+	var writers output.MultiWriter
+	if cliOptions.Database != "" {
+		database, err := output.NewDatabase(cliOptions.Database)
+		if err != nil {
+			gologger.Fatal().Msgf("Could not open database: %s\n", err)
+		}
+		writers = append(writers, database)
+	}
+	if cliOptions.Webhook != "" {
+		throttle, err := output.ParseThrottle(cliOptions.WebhookRate, cliOptions.WebhookProviderRates, cliOptions.WebhookBatch)
+		if err != nil {
+			gologger.Fatal().Msgf("Could not parse webhook rate limits: %s\n", err)
+		}
+		writers = append(writers, output.NewThrottledWebhook(cliOptions.Webhook, nil, cliOptions.WebhookDeadLetter, throttle))
+	}
+	for _, command := range cliOptions.Plugins {
+		plugin, err := output.NewPlugin(command)
+		if err != nil {
+			gologger.Fatal().Msgf("Could not create plugin: %s\n", err)
+		}
+		writers = append(writers, plugin)
+	}
+
+	if cliOptions.CNAME != "" {
+		if err := client.SetCNAME(cliOptions.CNAME); err != nil {
+			gologger.Fatal().Msgf("Could not set cname: %s\n", err)
+		}
+		gologger.Info().Msgf("Payloads resolve to %s through cname\n", cliOptions.CNAME)
+	}
+	for _, script := range cliOptions.DNSScripts {
+		label, answers, ok := strings.Cut(script, "=")
+		if !ok {
+			gologger.Fatal().Msgf("Invalid dns script %s, expected label=ip,ip\n", script)
+		}
+		if err := client.SetDNSScript(label, strings.Split(answers, ",")); err != nil {
+			gologger.Fatal().Msgf("Could not set dns script: %s\n", err)
+		}
+		gologger.Info().Msgf("Lookups of %s.<payload> answer %s in turn\n", label, answers)
+	}
+	if httpTemplate != nil {
+		if err := client.SetHTTPTemplate(httpTemplate); err != nil {
+			gologger.Fatal().Msgf("Could not set http template: %s\n", err)
+		}
+		gologger.Info().Msgf("HTTP requests to the payloads are answered with %s\n", cliOptions.HTTPTemplate)
+	}
+	for _, payloadFile := range cliOptions.PayloadFiles {
+		data, err := os.ReadFile(payloadFile)
+		if err != nil {
+			gologger.Fatal().Msgf("Could not read payload file: %s\n", err)
+		}
+		fileURL, err := client.SetPayloadFile(filepath.Base(payloadFile), "", data)
+		if err != nil {
+			gologger.Fatal().Msgf("Could not host payload file: %s\n", err)
+		}
+		gologger.Info().Msgf("Hosting %s at %s\n", payloadFile, fileURL)
+	}
+
+	for _, publicKey := range cliOptions.Share {
+		sharedInfo, err := client.Share(publicKey)
+		if err != nil {
+			gologger.Fatal().Msgf("Could not share session: %s\n", err)
+		}
+		token, err := sharedInfo.ShareToken()
+		if err != nil {
+			gologger.Fatal().Msgf("Could not create share token: %s\n", err)
+		}
+		gologger.Info().Msgf("Share token (use with -join): %s\n", token)
+	}
+
+	if cliOptions.Inspect {
+		inspectURL, err := client.Inspect()
+		if err != nil {
+			gologger.Fatal().Msgf("Could not enable inspect page: %s\n", err)
+		}
+		gologger.Info().Msgf("Inspect page: %s\n", inspectURL)
+	}
+
 	interactshURLs := generatePayloadURL(cliOptions.NumberOfPayloads, client)
 
 	gologger.Info().Msgf("Listing %d payload for OOB Testing\n", cliOptions.NumberOfPayloads)
@@ -171,7 +402,13 @@ func main() {
 		}
 	}
 
-	err = client.StartPolling(time.Duration(cliOptions.PollInterval)*time.Second, func(interaction *server.Interaction) {
+	var watchUI *tui.UI
+	if cliOptions.Watch {
+		watchUI = tui.New(&tui.Options{Payloads: interactshURLs})
+		gologger.DefaultLogger.SetWriter(watchUI)
+	}
+
+	callback := func(interaction *server.Interaction) {
 		if matcher != nil && !matcher.match(interaction.FullId) {
 			return
 		}
@@ -182,6 +419,24 @@ func main() {
 		if cliOptions.Asn {
 			_ = client.TryGetAsnInfo(interaction)
 		}
+		if cliOptions.SaveBodies != "" && interaction.BodyStored > 0 {
+			go saveBody(client, cliOptions.SaveBodies, interaction.BodySHA256)
+		}
+
+		if err := writers.Write(interaction); err != nil {
+			gologger.Warning().Msgf("Could not write interaction: %s\n", err)
+		}
+
+		if watchUI != nil {
+			if outputFile != nil {
+				if b, err := jsoniter.Marshal(interaction); err == nil {
+					_, _ = outputFile.Write(b)
+					_, _ = outputFile.Write([]byte("\n"))
+				}
+			}
+			watchUI.Add(interaction)
+			return
+		}
 
 		if !cliOptions.JSON {
 			builder := &bytes.Buffer{}
@@ -189,7 +444,30 @@ func main() {
 			switch interaction.Protocol {
 			case "dns":
 				if noFilter || cliOptions.DNSOnly {
-					builder.WriteString(fmt.Sprintf("[%s] Received DNS interaction (%s) from %s at %s", interaction.FullId, interaction.QType, interaction.RemoteAddress, interaction.Timestamp.Format("2006-01-02 15:04:05")))
+					if interaction.ExfilChunks > 0 {
+						builder.WriteString(fmt.Sprintf("[%s] Received DNS exfil of %d bytes in %d %s chunks from %s at %s", interaction.FullId, len(interaction.ExfilData), interaction.ExfilChunks, interaction.ExfilEncoding, remoteAddress(interaction), receivedAt(interaction)))
+						if cliOptions.Verbose {
+							builder.WriteString(fmt.Sprintf("\n----------\nDNS Exfil\n----------\n\n%s\n\n", interaction.ExfilData))
+						}
+						writeOutput(outputFile, builder)
+						break
+					}
+					transport := ""
+					if interaction.DNSTransport != "" {
+						transport = " over " + dnsTransportName(interaction.DNSTransport)
+					}
+					if interaction.ClientSubnet != "" {
+						transport += " for client " + interaction.ClientSubnet
+					}
+					if interaction.RebindStrategy != "" {
+						answer := interaction.RebindAnswer
+						if answer == "" {
+							answer = "no address"
+						}
+						transport += fmt.Sprintf(" answered %s (rebind %s)", answer, interaction.RebindStrategy)
+					}
+					builder.WriteString(fmt.Sprintf("[%s] Received DNS interaction (%s)%s from %s at %s", interaction.FullId, interaction.QType, transport, remoteAddress(interaction), receivedAt(interaction)))
+					writeDecoded(builder, interaction)
 					if cliOptions.Verbose {
 						builder.WriteString(fmt.Sprintf("\n-----------\nDNS Request\n-----------\n\n%s\n\n------------\nDNS Response\n------------\n\n%s\n\n", interaction.RawRequest, interaction.RawResponse))
 					}
@@ -197,15 +475,88 @@ func main() {
 				}
 			case "http":
 				if noFilter || cliOptions.HTTPOnly {
-					builder.WriteString(fmt.Sprintf("[%s] Received HTTP interaction from %s at %s", interaction.FullId, interaction.RemoteAddress, interaction.Timestamp.Format("2006-01-02 15:04:05")))
+					builder.WriteString(fmt.Sprintf("[%s] Received HTTP interaction from %s at %s", interaction.FullId, remoteAddress(interaction), receivedAt(interaction)))
+					if interaction.GRPCMethod != "" {
+						builder.WriteString(fmt.Sprintf("\n    grpc call %s with %d messages", interaction.GRPCMethod, len(interaction.GRPCMessages)))
+					}
+					for _, operation := range interaction.GraphQLOperations {
+						if operation.Introspection {
+							builder.WriteString("\n    graphql introspection query")
+						} else {
+							builder.WriteString(fmt.Sprintf("\n    graphql operation %q", operation.OperationName))
+						}
+					}
+					if interaction.OAuthResponseMode != "" {
+						builder.WriteString(fmt.Sprintf("\n    oauth callback (%s) code %q state %q", interaction.OAuthResponseMode, interaction.OAuthCode, interaction.OAuthState))
+					}
+					if interaction.RegistryOperation != "" {
+						builder.WriteString(fmt.Sprintf("\n    registry %s %s", interaction.RegistryOperation, interaction.RegistryRepository))
+						if interaction.RegistryReference != "" {
+							builder.WriteString(" " + interaction.RegistryReference)
+						}
+						if interaction.RegistryAuthorization != "" {
+							builder.WriteString(fmt.Sprintf(" with %s authorization", interaction.RegistryAuthorization))
+						}
+					}
+					if interaction.PackageEcosystem != "" {
+						builder.WriteString(fmt.Sprintf("\n    %s package %s", interaction.PackageEcosystem, interaction.PackageName))
+						if interaction.PackageVersion != "" {
+							builder.WriteString(" " + interaction.PackageVersion)
+						}
+						if interaction.PackageUserAgent != "" {
+							builder.WriteString(fmt.Sprintf(" requested by %q", interaction.PackageUserAgent))
+						}
+					}
+					if interaction.RedirectLocation != "" {
+						builder.WriteString(fmt.Sprintf("\n    redirected to %s", interaction.RedirectLocation))
+						if interaction.RedirectHop > 0 {
+							builder.WriteString(fmt.Sprintf(" (chain hop %d)", interaction.RedirectHop))
+						}
+					}
+					writeWebhookSignature(builder, interaction)
+					for _, assertion := range interaction.SAMLAssertions {
+						if assertion.Encrypted {
+							builder.WriteString("\n    saml encrypted assertion")
+						} else {
+							builder.WriteString(fmt.Sprintf("\n    saml assertion for %q issued by %s", assertion.Subject, assertion.Issuer))
+						}
+					}
+					if interaction.XXE {
+						builder.WriteString("\n    likely XXE attempt:")
+						for _, entity := range interaction.XMLEntities {
+							if entity.SystemID != "" {
+								builder.WriteString(fmt.Sprintf(" %s=%s", entity.Name, entity.SystemID))
+							}
+						}
+					}
+					writeDNSVerdict(builder, interaction)
+					writeScannerProbe(builder, interaction)
+					writeBodyType(builder, interaction)
+					writeBodyParts(builder, interaction)
+					writeUNCChain(builder, interaction)
+					writeTLSFingerprint(builder, interaction)
+					writeClockSkew(builder, interaction)
+					writeDecoded(builder, interaction)
 					if cliOptions.Verbose {
 						builder.WriteString(fmt.Sprintf("\n------------\nHTTP Request\n------------\n\n%s\n\n-------------\nHTTP Response\n-------------\n\n%s\n\n", interaction.RawRequest, interaction.RawResponse))
 					}
 					writeOutput(outputFile, builder)
 				}
+			case "websocket":
+				if noFilter || cliOptions.HTTPOnly {
+					builder.WriteString(fmt.Sprintf("[%s] Received WebSocket frame %d from %s at %s", interaction.FullId, interaction.WebSocketFrame, remoteAddress(interaction), receivedAt(interaction)))
+					if cliOptions.Verbose {
+						builder.WriteString(fmt.Sprintf("\n---------------\nWebSocket Frame\n---------------\n\n%s\n\n", interaction.RawRequest))
+					}
+					writeOutput(outputFile, builder)
+				}
 			case "smtp":
 				if noFilter || cliOptions.SmtpOnly {
-					builder.WriteString(fmt.Sprintf("[%s] Received SMTP interaction from %s at %s", interaction.FullId, interaction.RemoteAddress, interaction.Timestamp.Format("2006-01-02 15:04:05")))
+					builder.WriteString(fmt.Sprintf("[%s] Received SMTP interaction from %s at %s", interaction.FullId, remoteAddress(interaction), receivedAt(interaction)))
+					writeDNSVerdict(builder, interaction)
+					writeBodyType(builder, interaction)
+					writeTLSFingerprint(builder, interaction)
+					writeClockSkew(builder, interaction)
 					if cliOptions.Verbose {
 						builder.WriteString(fmt.Sprintf("\n------------\nSMTP Interaction\n------------\n\n%s\n\n", interaction.RawRequest))
 					}
@@ -213,7 +564,8 @@ func main() {
 				}
 			case "ftp":
 				if noFilter {
-					builder.WriteString(fmt.Sprintf("Received FTP interaction from %s at %s", interaction.RemoteAddress, interaction.Timestamp.Format("2006-01-02 15:04:05")))
+					builder.WriteString(fmt.Sprintf("Received FTP interaction from %s at %s", remoteAddress(interaction), receivedAt(interaction)))
+					writeBodyType(builder, interaction)
 					if cliOptions.Verbose {
 						builder.WriteString(fmt.Sprintf("\n------------\nFTP Interaction\n------------\n\n%s\n\n", interaction.RawRequest))
 					}
@@ -221,17 +573,168 @@ func main() {
 				}
 			case "responder", "smb":
 				if noFilter {
-					builder.WriteString(fmt.Sprintf("Received Responder/Smb interaction at %s", interaction.Timestamp.Format("2006-01-02 15:04:05")))
+					builder.WriteString(fmt.Sprintf("Received Responder/Smb interaction at %s", receivedAt(interaction)))
 					if cliOptions.Verbose {
 						builder.WriteString(fmt.Sprintf("\n------------\nResponder/SMB Interaction\n------------\n\n%s\n\n", interaction.RawRequest))
 					}
 					writeOutput(outputFile, builder)
 				}
+			case "tcp-echo", "udp-echo":
+				if noFilter {
+					builder.WriteString(fmt.Sprintf("[%s] Received %s interaction from %s at %s", interaction.FullId, strings.ToUpper(interaction.Protocol), remoteAddress(interaction), receivedAt(interaction)))
+					if cliOptions.Verbose {
+						builder.WriteString(fmt.Sprintf("\n------------\nEcho Interaction\n------------\n\n%s\n\n", interaction.RawRequest))
+					}
+					writeOutput(outputFile, builder)
+				}
+			case "ssh":
+				if noFilter {
+					builder.WriteString(fmt.Sprintf("[%s] Received SSH %s authentication as %q from %s at %s", interaction.FullId, interaction.SSHAuthMethod, interaction.SSHUser, remoteAddress(interaction), receivedAt(interaction)))
+					builder.WriteString(fmt.Sprintf("\n    client %q credential %q", interaction.SSHClientVersion, interaction.SSHCredential))
+					if cliOptions.Verbose {
+						builder.WriteString(fmt.Sprintf("\n------------\nSSH Interaction\n------------\n\n%s\n\n", interaction.RawRequest))
+					}
+					writeOutput(outputFile, builder)
+				}
+			case "dropped":
+				if noFilter {
+					builder.WriteString(fmt.Sprintf("Received %d packets to %s without a listener from %s at %s", interaction.DroppedPackets, interaction.DroppedPort, remoteAddress(interaction), receivedAt(interaction)))
+					if cliOptions.Verbose {
+						builder.WriteString(fmt.Sprintf("\n------------\nDropped Packets\n------------\n\n%s\n\n", interaction.RawRequest))
+					}
+					writeOutput(outputFile, builder)
+				}
+			case "redis":
+				if noFilter {
+					builder.WriteString(fmt.Sprintf("[%s] Received Redis interaction from %s at %s", interaction.FullId, remoteAddress(interaction), receivedAt(interaction)))
+					if interaction.TLSServerName != "" {
+						builder.WriteString(fmt.Sprintf("\n    tls server name %q", interaction.TLSServerName))
+					}
+					writeScannerProbe(builder, interaction)
+					if cliOptions.Verbose {
+						builder.WriteString(fmt.Sprintf("\n------------\nRedis Commands\n------------\n\n%s\n\n", interaction.RawRequest))
+					}
+					writeOutput(outputFile, builder)
+				}
+			case "imap", "pop3":
+				if noFilter {
+					builder.WriteString(fmt.Sprintf("[%s] Received %s login as %q from %s at %s", interaction.FullId, strings.ToUpper(interaction.Protocol), interaction.MailUser, remoteAddress(interaction), receivedAt(interaction)))
+					builder.WriteString(fmt.Sprintf("\n    password %q", interaction.MailPassword))
+					if cliOptions.Verbose {
+						builder.WriteString(fmt.Sprintf("\n------------\n%s Commands\n------------\n\n%s\n\n", strings.ToUpper(interaction.Protocol), interaction.RawRequest))
+					}
+					writeOutput(outputFile, builder)
+				}
+			case "tftp":
+				if noFilter {
+					builder.WriteString(fmt.Sprintf("[%s] Received TFTP %s request for %q from %s at %s", interaction.FullId, interaction.TFTPOperation, interaction.TFTPFile, remoteAddress(interaction), receivedAt(interaction)))
+					if cliOptions.Verbose {
+						builder.WriteString(fmt.Sprintf("\n------------\nTFTP Request\n------------\n\n%s\n\n", interaction.RawRequest))
+					}
+					writeOutput(outputFile, builder)
+				}
+			case "snmp":
+				if noFilter {
+					builder.WriteString(fmt.Sprintf("[%s] Received SNMP %s %s with community %q from %s at %s", interaction.FullId, interaction.SNMPVersion, interaction.SNMPPDU, interaction.SNMPCommunity, remoteAddress(interaction), receivedAt(interaction)))
+					if cliOptions.Verbose {
+						builder.WriteString(fmt.Sprintf("\n------------\nSNMP Message\n------------\n\n%s\n\n", interaction.RawRequest))
+					}
+					writeOutput(outputFile, builder)
+				}
+			case "ntp":
+				if noFilter {
+					// requests without correlation id are stored for the server token
+					if interaction.FullId != "" {
+						builder.WriteString(fmt.Sprintf("[%s] ", interaction.FullId))
+					}
+					builder.WriteString(fmt.Sprintf("Received NTP %s %s request from %s at %s", interaction.NTPMode, interaction.NTPRequest, remoteAddress(interaction), receivedAt(interaction)))
+					if cliOptions.Verbose {
+						builder.WriteString(fmt.Sprintf("\n------------\nNTP Request\n------------\n\n%s\n\n", interaction.RawRequest))
+					}
+					writeOutput(outputFile, builder)
+				}
+			case "sip":
+				if noFilter {
+					builder.WriteString(fmt.Sprintf("[%s] Received SIP %s request for %s over %s from %s at %s", interaction.FullId, interaction.SIPMethod, interaction.SIPRequestURI, strings.ToUpper(interaction.SIPTransport), remoteAddress(interaction), receivedAt(interaction)))
+					if cliOptions.Verbose {
+						builder.WriteString(fmt.Sprintf("\n-----------\nSIP Request\n-----------\n\n%s\n\n", interaction.RawRequest))
+					}
+					writeOutput(outputFile, builder)
+				}
+			case "grpc":
+				if noFilter {
+					builder.WriteString(fmt.Sprintf("[%s] Received gRPC call %s with %d messages from %s at %s", interaction.FullId, interaction.GRPCMethod, len(interaction.GRPCMessages), remoteAddress(interaction), receivedAt(interaction)))
+					if len(interaction.GRPCReflection) > 0 {
+						builder.WriteString(fmt.Sprintf("\n    reflection %s", strings.Join(interaction.GRPCReflection, ", ")))
+					}
+					if cliOptions.Verbose {
+						builder.WriteString(fmt.Sprintf("\n---------\ngRPC Call\n---------\n\n%s\n\n", interaction.RawRequest))
+					}
+					writeOutput(outputFile, builder)
+				}
+			case "icmp":
+				if noFilter {
+					builder.WriteString(fmt.Sprintf("[%s] Received ICMP echo request from %s at %s", interaction.FullId, remoteAddress(interaction), receivedAt(interaction)))
+					if interaction.ICMPPaired {
+						builder.WriteString(" (paired with the host name lookup)")
+					}
+					if cliOptions.Verbose {
+						builder.WriteString(fmt.Sprintf("\n------------\nICMP Request\n------------\n\n%s\n\n", interaction.RawRequest))
+					}
+					writeOutput(outputFile, builder)
+				}
+			case "tcp":
+				if noFilter {
+					builder.WriteString(fmt.Sprintf("[%s] Received TCP interaction on port %d from %s at %s", interaction.FullId, interaction.LocalPort, remoteAddress(interaction), receivedAt(interaction)))
+					if interaction.TLSServerName != "" {
+						builder.WriteString(fmt.Sprintf("\n    tls server name %q", interaction.TLSServerName))
+					}
+					if cliOptions.Verbose {
+						builder.WriteString(fmt.Sprintf("\n------------\nTCP Payload\n------------\n\n%s\n\n", interaction.RawRequest))
+					}
+					writeOutput(outputFile, builder)
+				}
+			case "udp":
+				if noFilter {
+					// datagrams without correlation id are stored for the server token
+					if interaction.FullId != "" {
+						builder.WriteString(fmt.Sprintf("[%s] ", interaction.FullId))
+					}
+					builder.WriteString(fmt.Sprintf("Received UDP interaction on port %d from %s at %s", interaction.LocalPort, remoteAddress(interaction), receivedAt(interaction)))
+					writeScannerProbe(builder, interaction)
+					if cliOptions.Verbose {
+						builder.WriteString(fmt.Sprintf("\n------------\nUDP Datagram\n------------\n\n%s\n\n", interaction.RawRequest))
+					}
+					writeOutput(outputFile, builder)
+				}
+			case "mysql":
+				if noFilter {
+					builder.WriteString(fmt.Sprintf("[%s] Received MySQL interaction as %q from %s at %s", interaction.FullId, interaction.MySQLUser, remoteAddress(interaction), receivedAt(interaction)))
+					if interaction.MySQLDatabase != "" {
+						builder.WriteString(fmt.Sprintf("\n    database %q", interaction.MySQLDatabase))
+					}
+					if interaction.MySQLInfile != "" {
+						builder.WriteString(fmt.Sprintf("\n    load data local infile %q", interaction.MySQLInfile))
+					}
+					if cliOptions.Verbose {
+						builder.WriteString(fmt.Sprintf("\n------------\nMySQL Interaction\n------------\n\n%s\n\n", interaction.RawRequest))
+					}
+					writeOutput(outputFile, builder)
+				}
 			case "ldap":
 				if noFilter {
-					builder.WriteString(fmt.Sprintf("[%s] Received LDAP interaction from %s at %s", interaction.FullId, interaction.RemoteAddress, interaction.Timestamp.Format("2006-01-02 15:04:05")))
+					builder.WriteString(fmt.Sprintf("[%s] Received LDAP interaction from %s at %s", interaction.FullId, remoteAddress(interaction), receivedAt(interaction)))
+					if interaction.BindUser != "" {
+						builder.WriteString(fmt.Sprintf("\n    bind user %q password %q", interaction.BindUser, interaction.BindPassword))
+					}
+					writeJavaClasses(builder, interaction)
+					writeTLSFingerprint(builder, interaction)
+					writeDecoded(builder, interaction)
 					if cliOptions.Verbose {
 						builder.WriteString(fmt.Sprintf("\n------------\nLDAP Interaction\n------------\n\n%s\n\n", interaction.RawRequest))
+						if len(interaction.RawBytes) > 0 {
+							builder.WriteString(fmt.Sprintf("------------\nLDAP Raw Bytes\n------------\n\n%s\n", hex.Dump(interaction.RawBytes)))
+						}
 					}
 					writeOutput(outputFile, builder)
 				}
@@ -249,13 +752,48 @@ func main() {
 				_, _ = outputFile.Write([]byte("\n"))
 			}
 		}
+	}
+	verifier := newDNSVerifier(cliOptions, callback)
+	var stats *statsWriter
+	if cliOptions.StatsFile != "" {
+		stats = newStatsWriter(cliOptions.StatsFile)
+	}
+
+	err = client.StartPolling(time.Duration(cliOptions.PollInterval)*time.Second, func(interaction *server.Interaction) {
+		// usage is tracked before matchers so that filtered interactions still count
+		if usage != nil {
+			_ = usage.Write(interaction)
+		}
+		if stats != nil {
+			stats.Record(interaction.Protocol, interaction.Timestamp)
+		}
+		if webhookVerifier != nil {
+			webhookVerifier.Verify(interaction)
+		}
+		if verifier != nil {
+			verifier.Verify(interaction)
+			return
+		}
+		callback(interaction)
 	})
 	if err != nil {
 		gologger.Error().Msgf(err.Error())
 	}
 
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	if err := daemon.Notify(cliOptions.ServiceName, c); err != nil {
+		gologger.Warning().Msgf("Could not attach to the service manager: %s\n", err)
+	}
+	if watchUI != nil {
+		go func() {
+			if err := watchUI.Run(); err != nil {
+				gologger.DefaultLogger.SetWriter(writer.NewCLI())
+				gologger.Error().Msgf("Could not start watch mode: %s\n", err)
+			}
+			c <- os.Interrupt
+		}()
+	}
 	for range c {
 		if cliOptions.SessionFile != "" {
 			_ = client.SaveSessionTo(cliOptions.SessionFile)
@@ -265,10 +803,51 @@ func main() {
 		if cliOptions.SessionFile == "" {
 			client.Close()
 		}
+		// flush the interactions pending verification before closing the outputs
+		if verifier != nil {
+			_ = verifier.Close()
+		}
+		if err := writers.Close(); err != nil {
+			gologger.Warning().Msgf("Could not close outputs: %s\n", err)
+		}
+		if usage != nil {
+			if err := usage.Close(); err != nil {
+				gologger.Warning().Msgf("Could not write usage report: %s\n", err)
+			}
+		}
+		if stats != nil {
+			stats.Close()
+		}
 		os.Exit(1)
 	}
 }
 
+func newDNSVerifier(cliOptions *options.CLIClientOptions, callback client.InteractionCallback) *client.DNSVerifier {
+	if !cliOptions.VerifyDNS {
+		return nil
+	}
+	return client.NewDNSVerifier(time.Duration(cliOptions.VerifyDNSGrace)*time.Second, callback)
+}
+
+// newWebhookVerifier resolves the webhook secrets, which may be secret references
+func newWebhookVerifier(cliOptions *options.CLIClientOptions) (*client.WebhookVerifier, error) {
+	if len(cliOptions.VerifyWebhook) == 0 {
+		return nil, nil
+	}
+	webhookSecrets := make([]client.WebhookSecret, 0, len(cliOptions.VerifyWebhook))
+	for i, value := range cliOptions.VerifyWebhook {
+		secret, err := client.ParseWebhookSecret(value, i)
+		if err != nil {
+			return nil, err
+		}
+		if secret.Secret, err = secrets.Resolve(secret.Secret); err != nil {
+			return nil, fmt.Errorf("could not resolve secret %s: %w", secret.Name, err)
+		}
+		webhookSecrets = append(webhookSecrets, secret)
+	}
+	return client.NewWebhookVerifier(webhookSecrets), nil
+}
+
 func generatePayloadURL(numberOfPayloads int, client *client.Client) []string {
 	interactshURLs := make([]string, numberOfPayloads)
 	for i := 0; i < numberOfPayloads; i++ {
@@ -277,6 +856,188 @@ func generatePayloadURL(numberOfPayloads int, client *client.Client) []string {
 	return interactshURLs
 }
 
+// writeDNSVerdict flags interactions which were not preceded by a dns resolution
+func writeDNSVerdict(builder *bytes.Buffer, interaction *server.Interaction) {
+	if interaction.DNSVerified != nil && !*interaction.DNSVerified {
+		builder.WriteString(" (no prior dns resolution)")
+	}
+}
+
+// writeWebhookSignature appends the vendor signature of a webhook with its verdict
+func writeWebhookSignature(builder *bytes.Buffer, interaction *server.Interaction) {
+	signature := interaction.WebhookSignature
+	if signature == nil {
+		return
+	}
+	builder.WriteString(fmt.Sprintf("\n    %s webhook signed with %s", signature.Vendor, signature.Algorithm))
+	switch {
+	case signature.Verified == nil:
+	case *signature.Verified:
+		builder.WriteString(fmt.Sprintf(", verified with secret %s", signature.Secret))
+	default:
+		builder.WriteString(", not matching any secret")
+	}
+}
+
+// writeScannerProbe appends the fingerprint probe of a network scanner
+func writeScannerProbe(builder *bytes.Buffer, interaction *server.Interaction) {
+	if interaction.ScannerProbe != "" {
+		builder.WriteString(fmt.Sprintf("\n    scanner probe %s", interaction.ScannerProbe))
+	}
+}
+
+// writeBodyType appends the sniffed type of a body with its tags and preview
+func writeBodyType(builder *bytes.Buffer, interaction *server.Interaction) {
+	if interaction.BodyType == "" {
+		return
+	}
+	builder.WriteString(fmt.Sprintf("\n    %s body", interaction.BodyType))
+	if len(interaction.BodyTags) > 0 {
+		builder.WriteString(fmt.Sprintf(" (%s)", strings.Join(interaction.BodyTags, ", ")))
+	}
+	builder.WriteString(fmt.Sprintf(": %s", interaction.BodyPreview))
+	writeJavaClasses(builder, interaction)
+}
+
+// saveBody downloads a body stored by the server to the directory
+func saveBody(interactsh *client.Client, dir, id string) {
+	// the id comes from the server
+	id = filepath.Base(id)
+	body, err := interactsh.Body(id)
+	if err != nil {
+		gologger.Warning().Msgf("Could not download body %s: %s\n", id, err)
+		return
+	}
+	defer body.Close()
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		gologger.Warning().Msgf("Could not create bodies directory: %s\n", err)
+		return
+	}
+	file, err := os.OpenFile(filepath.Join(dir, id), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		gologger.Warning().Msgf("Could not save body %s: %s\n", id, err)
+		return
+	}
+	defer file.Close()
+	if _, err := io.Copy(file, body); err != nil {
+		gologger.Warning().Msgf("Could not save body %s: %s\n", id, err)
+		return
+	}
+	gologger.Info().Msgf("Saved body %s\n", filepath.Join(dir, id))
+}
+
+// writeBodyParts appends the fields and files of a multipart body
+func writeBodyParts(builder *bytes.Buffer, interaction *server.Interaction) {
+	for _, part := range interaction.BodyParts {
+		if part.FileName == "" {
+			builder.WriteString(fmt.Sprintf("\n    multipart field %q (%d bytes)", part.Name, part.Size))
+			continue
+		}
+		builder.WriteString(fmt.Sprintf("\n    multipart file %q of field %q (%d bytes", part.FileName, part.Name, part.Size))
+		if part.ContentType != "" {
+			builder.WriteString(", " + part.ContentType)
+		}
+		builder.WriteString(")")
+	}
+}
+
+// writeUNCChain appends the steps of the unc path resolution linked to a webdav request
+func writeUNCChain(builder *bytes.Buffer, interaction *server.Interaction) {
+	chain := interaction.UNCChain
+	if chain == nil {
+		return
+	}
+	steps := make([]string, 0, len(chain.Steps))
+	for _, step := range chain.Steps {
+		steps = append(steps, fmt.Sprintf("%s from %s at %s", step.Protocol, step.Source, step.Timestamp.Format("15:04:05")))
+	}
+	builder.WriteString(fmt.Sprintf("\n    unc chain %s: %s", chain.ID, strings.Join(steps, " -> ")))
+	if chain.Payload != "" {
+		builder.WriteString(fmt.Sprintf(" (payload %s)", chain.Payload))
+	}
+}
+
+// writeTLSFingerprint appends the ja3 and ja4 fingerprints of the tls client
+// with the negotiated version and cipher suite, and its certificate
+func writeTLSFingerprint(builder *bytes.Buffer, interaction *server.Interaction) {
+	fingerprint := interaction.TLS
+	if fingerprint == nil {
+		return
+	}
+	builder.WriteString(fmt.Sprintf("\n    tls ja3 %s ja4 %s", fingerprint.JA3Hash, fingerprint.JA4))
+	if fingerprint.Version != "" {
+		builder.WriteString(fmt.Sprintf(" (%s, %s)", fingerprint.Version, fingerprint.CipherSuite))
+	}
+	if certificate := fingerprint.ClientCertificate; certificate != nil {
+		builder.WriteString(fmt.Sprintf("\n    tls client certificate %q issued by %q sha256 %s", certificate.Subject, certificate.Issuer, certificate.SHA256))
+		if len(certificate.SANs) > 0 {
+			builder.WriteString(fmt.Sprintf(" for %s", strings.Join(certificate.SANs, ", ")))
+		}
+	}
+}
+
+// writeJavaClasses appends the classes of java serialized objects with the
+// gadget chains they belong to
+func writeJavaClasses(builder *bytes.Buffer, interaction *server.Interaction) {
+	if len(interaction.JavaClasses) == 0 {
+		return
+	}
+	builder.WriteString(fmt.Sprintf("\n    java classes %s", strings.Join(interaction.JavaClasses, ", ")))
+	if len(interaction.JavaGadgets) > 0 {
+		builder.WriteString(fmt.Sprintf("\n    java gadget chains %s", strings.Join(interaction.JavaGadgets, ", ")))
+	}
+}
+
+// remoteAddress returns the remote address of an interaction along with the
+// IPv4 address embedded in it when translated by NAT64
+func remoteAddress(interaction *server.Interaction) string {
+	if interaction.EmbeddedIPv4 == "" {
+		return interaction.RemoteAddress
+	}
+	return fmt.Sprintf("%s (%s via nat64 %s)", interaction.RemoteAddress, interaction.EmbeddedIPv4, interaction.NAT64Prefix)
+}
+
+// dnsTransportName returns the display name of an encrypted dns transport
+func dnsTransportName(transport string) string {
+	switch transport {
+	case "doh":
+		return "DoH"
+	case "dot":
+		return "DoT"
+	}
+	return strings.ToUpper(transport)
+}
+
+// receivedAt returns the time the interaction was received, along with the
+// delay since the generation of its payload if known
+func receivedAt(interaction *server.Interaction) string {
+	timestamp := interaction.Timestamp.Format("2006-01-02 15:04:05")
+	if interaction.PayloadLatency == nil {
+		return timestamp
+	}
+	return fmt.Sprintf("%s (%s after payload)", timestamp, time.Duration(*interaction.PayloadLatency)*time.Millisecond)
+}
+
+// writeClockSkew appends the estimated clock skew of the remote host
+func writeClockSkew(builder *bytes.Buffer, interaction *server.Interaction) {
+	skew, ok := interaction.ClockSkew()
+	if !ok {
+		return
+	}
+	sign := "+"
+	if skew < 0 {
+		sign, skew = "-", -skew
+	}
+	builder.WriteString(fmt.Sprintf(" (remote clock skew %s%s)", sign, skew.Round(time.Second)))
+}
+
+func writeDecoded(builder *bytes.Buffer, interaction *server.Interaction) {
+	for _, decoded := range interaction.Decoded {
+		builder.WriteString(fmt.Sprintf("\n    decoded %s %s: %q", decoded.Encoding, decoded.Source, decoded.Value))
+	}
+}
+
 func writeOutput(outputFile *os.File, builder *bytes.Buffer) {
 	if outputFile != nil {
 		_, _ = outputFile.Write(builder.Bytes())
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// pathFlags are the flags holding paths which must be absolute for the service
+var pathFlags = map[string]struct{}{
+	"config": {}, "sf": {}, "session-file": {}, "db": {}, "o": {}, "psf": {}, "payload-store-file": {},
+}
+
+// serviceArgs returns the command line arguments the service runs the client
+// with, dropping the service management flags and making paths absolute as
+// the service working directory differs from the current one.
+func serviceArgs(args []string) []string {
+	var result []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") {
+			result = append(result, arg)
+			continue
+		}
+		switch name {
+		case "service":
+			if !hasValue {
+				i++
+			}
+			continue
+		case "w", "watch":
+			// the service has no terminal attached
+			continue
+		}
+		if _, ok := pathFlags[name]; ok {
+			if !hasValue && i+1 < len(args) {
+				i++
+				value = args[i]
+			}
+			if abs, err := filepath.Abs(value); err == nil {
+				value = abs
+			}
+			result = append(result, "-"+name, value)
+			continue
+		}
+		result = append(result, arg)
+	}
+	return result
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/interactsh/pkg/server"
+)
+
+// statsWriteInterval is the interval the stats file is rewritten at while
+// interactions are polled
+var statsWriteInterval = 5 * time.Second
+
+// runStatsCommand displays the session statistics of a stats file when
+// invoked as the stats subcommand, returning false for other arguments
+func runStatsCommand(args []string) bool {
+	if len(args) == 0 || args[0] != "stats" {
+		return false
+	}
+	flagSet := flag.NewFlagSet(args[0], flag.ExitOnError)
+	statsFile := flagSet.String("stats-file", "", "stats file written by the client with -stats-file")
+	_ = flagSet.Parse(args[1:])
+	if *statsFile == "" && flagSet.NArg() > 0 {
+		*statsFile = flagSet.Arg(0)
+	}
+
+	if *statsFile == "" {
+		gologger.Fatal().Msgf("A stats file must be specified to display the session statistics\n")
+	}
+	if err := printStats(*statsFile); err != nil {
+		gologger.Fatal().Msgf("Could not read stats file: %s\n", err)
+	}
+	return true
+}
+
+// statsWriter records the session statistics and writes them to a file
+// periodically rather than for each interaction
+type statsWriter struct {
+	path    string
+	metrics server.ProtocolMetrics
+	dirty   atomic.Bool
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// newStatsWriter returns a writer of the session statistics to the file
+func newStatsWriter(path string) *statsWriter {
+	w := &statsWriter{path: path, done: make(chan struct{}), stopped: make(chan struct{})}
+	go w.run()
+	return w
+}
+
+// Record accounts an interaction of the protocol received at timestamp
+func (w *statsWriter) Record(protocol string, timestamp time.Time) {
+	w.metrics.Record(protocol, timestamp)
+	w.dirty.Store(true)
+}
+
+func (w *statsWriter) run() {
+	defer close(w.stopped)
+	ticker := time.NewTicker(statsWriteInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.flush()
+		case <-w.done:
+			w.flush()
+			return
+		}
+	}
+}
+
+// flush writes the statistics if they changed since the last write
+func (w *statsWriter) flush() {
+	if !w.dirty.Swap(false) {
+		return
+	}
+	if err := writeStats(w.path, &w.metrics); err != nil {
+		gologger.Warning().Msgf("Could not write stats file: %s\n", err)
+	}
+}
+
+// Close writes the pending statistics and stops the writer
+func (w *statsWriter) Close() {
+	close(w.done)
+	<-w.stopped
+}
+
+// writeStats writes a snapshot of the session statistics to the given file,
+// replacing it atomically so that readers never observe a partial file
+func writeStats(path string, metrics *server.ProtocolMetrics) error {
+	data, err := jsoniter.Marshal(metrics)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// printStats prints the session statistics stored in the given file
+func printStats(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var stats map[string]server.ProtocolStats
+	if err := jsoniter.Unmarshal(data, &stats); err != nil {
+		return err
+	}
+	protocols := make([]string, 0, len(stats))
+	for protocol := range stats {
+		protocols = append(protocols, protocol)
+	}
+	sort.Strings(protocols)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROTOCOL\tCOUNT\tFIRST SEEN\tLAST SEEN\tRATE/MIN")
+	for _, protocol := range protocols {
+		item := stats[protocol]
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%.2f\n", protocol, item.Count, item.FirstSeen.Format("2006-01-02 15:04:05"), item.LastSeen.Format("2006-01-02 15:04:05"), item.Rate)
+	}
+	return w.Flush()
+}
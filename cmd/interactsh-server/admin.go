@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/interactsh/pkg/server"
+)
+
+// defaultAdminSocket is the admin socket path used by the admin subcommands
+var defaultAdminSocket = filepath.Join(os.TempDir(), "interactsh-server.sock")
+
+// adminCommands are the subcommands talking to a running server over its admin socket
+var adminCommands = map[string]func(client *adminClient, args []string) error{
+	"sessions":    adminSessions,
+	"stats":       adminStats,
+	"sources":     adminSources,
+	"ban":         adminBan,
+	"evict":       adminEvict,
+	"config-dump": adminConfigDump,
+	"reload":      adminReload,
+}
+
+// runAdminCommand runs an admin subcommand and reports whether args named one
+func runAdminCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	command, ok := adminCommands[args[0]]
+	if !ok {
+		return false
+	}
+	flagSet := flag.NewFlagSet(args[0], flag.ExitOnError)
+	socket := flagSet.String("socket", defaultAdminSocket, "admin socket of the running server")
+	unban := flagSet.Bool("unban", false, "unban the correlation ids (ban only)")
+	_ = flagSet.Parse(args[1:])
+
+	client := newAdminClient(*socket)
+	commandArgs := flagSet.Args()
+	if args[0] == "ban" && *unban {
+		command = adminUnban
+	}
+	if err := command(client, commandArgs); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", args[0], err)
+		os.Exit(1)
+	}
+	return true
+}
+
+// adminClient is an http client of the admin api served on a unix socket
+type adminClient struct {
+	client *http.Client
+}
+
+func newAdminClient(socket string) *adminClient {
+	return &adminClient{client: &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		},
+	}}
+}
+
+// do sends a request to the admin api and decodes the json response into value
+func (c *adminClient) do(method, path string, body, value interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := jsoniter.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, "http://admin"+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		message := struct {
+			Error string `json:"error"`
+		}{}
+		if jsoniter.Unmarshal(data, &message) == nil && message.Error != "" {
+			return errors.New(message.Error)
+		}
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	if value == nil {
+		return nil
+	}
+	return jsoniter.Unmarshal(data, value)
+}
+
+// adminSessions prints the registered sessions
+func adminSessions(client *adminClient, _ []string) error {
+	response := &server.SessionsResponse{}
+	if err := client.do(http.MethodGet, "/admin/sessions", nil, response); err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CORRELATION ID\tINTERACTIONS\tREADERS\tCNAME")
+	for _, session := range response.Sessions {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", session.CorrelationID, session.Interactions, session.Readers, session.CNAME)
+	}
+	return w.Flush()
+}
+
+// adminStats prints the interaction counters of the server
+func adminStats(client *adminClient, _ []string) error {
+	metrics := &server.Metrics{}
+	if err := client.do(http.MethodGet, "/admin/stats", nil, metrics); err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROTOCOL\tCOUNT")
+	for _, item := range []struct {
+		protocol string
+		count    uint64
+	}{
+		{"dns", metrics.Dns}, {"ftp", metrics.Ftp}, {"http", metrics.Http},
+		{"ldap", metrics.Ldap}, {"smb", metrics.Smb}, {"smtp", metrics.Smtp}, {"ssh", metrics.Ssh}, {"mysql", metrics.Mysql}, {"redis", metrics.Redis}, {"tcp", metrics.Tcp}, {"udp", metrics.Udp}, {"websocket", metrics.Websocket}, {"imap", metrics.Imap}, {"pop3", metrics.Pop3}, {"tftp", metrics.Tftp}, {"snmp", metrics.Snmp}, {"ntp", metrics.Ntp}, {"sip", metrics.Sip}, {"grpc", metrics.Grpc}, {"icmp", metrics.Icmp},
+	} {
+		fmt.Fprintf(w, "%s\t%d\n", item.protocol, item.count)
+	}
+	fmt.Fprintf(w, "sessions\t%d\n", metrics.Sessions)
+	return w.Flush()
+}
+
+// adminSources prints the protocols attempted by each source within the window
+func adminSources(client *adminClient, _ []string) error {
+	response := &server.SourcesResponse{}
+	if err := client.do(http.MethodGet, "/admin/sources", nil, response); err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "SOURCE	PROTOCOLS (last %s)	FIRST SEEN	LAST SEEN\n", response.Window)
+	for _, source := range response.Sources {
+		protocols := make([]string, 0, len(source.Protocols))
+		for protocol, count := range source.Protocols {
+			protocols = append(protocols, fmt.Sprintf("%s:%d", protocol, count))
+		}
+		sort.Strings(protocols)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", source.Source, strings.Join(protocols, ","), source.FirstSeen.Format(time.RFC3339), source.LastSeen.Format(time.RFC3339))
+	}
+	return w.Flush()
+}
+
+// adminBan bans the given correlation ids or prints the banned ones
+func adminBan(client *adminClient, ids []string) error {
+	if len(ids) == 0 {
+		response := &server.BansResponse{}
+		if err := client.do(http.MethodGet, "/admin/bans", nil, response); err != nil {
+			return err
+		}
+		for _, id := range response.Bans {
+			fmt.Println(id)
+		}
+		return nil
+	}
+	for _, id := range ids {
+		if err := client.do(http.MethodPost, "/admin/bans", &server.SessionRequest{CorrelationID: id}, nil); err != nil {
+			return err
+		}
+		fmt.Printf("Banned %s\n", id)
+	}
+	return nil
+}
+
+// adminUnban lifts the ban of the given correlation ids
+func adminUnban(client *adminClient, ids []string) error {
+	for _, id := range ids {
+		if err := client.do(http.MethodDelete, "/admin/bans", &server.SessionRequest{CorrelationID: id}, nil); err != nil {
+			return err
+		}
+		fmt.Printf("Unbanned %s\n", id)
+	}
+	return nil
+}
+
+// adminEvict evicts the sessions of the given correlation ids
+func adminEvict(client *adminClient, ids []string) error {
+	if len(ids) == 0 {
+		return errors.New("no correlation id given")
+	}
+	for _, id := range ids {
+		if err := client.do(http.MethodDelete, "/admin/sessions", &server.SessionRequest{CorrelationID: id}, nil); err != nil {
+			return err
+		}
+		fmt.Printf("Evicted %s\n", id)
+	}
+	return nil
+}
+
+// adminConfigDump prints the sanitized configuration of the server
+func adminConfigDump(client *adminClient, _ []string) error {
+	var config map[string]interface{}
+	if err := client.do(http.MethodGet, "/admin/config", nil, &config); err != nil {
+		return err
+	}
+	data, err := jsoniter.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// adminReload reloads the options of the server from its arguments and config file
+func adminReload(client *adminClient, _ []string) error {
+	response := &server.ReloadResponse{}
+	if err := client.do(http.MethodPost, "/admin/reload", nil, response); err != nil {
+		return err
+	}
+	fmt.Println("Reloaded server options")
+	for _, name := range response.Restarted {
+		fmt.Printf("Restarted %s server\n", name)
+	}
+	return nil
+}
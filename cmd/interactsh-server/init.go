@@ -0,0 +1,415 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/projectdiscovery/interactsh/pkg/server"
+	"github.com/projectdiscovery/interactsh/pkg/server/acme"
+	fileutil "github.com/projectdiscovery/utils/file"
+	iputil "github.com/projectdiscovery/utils/ip"
+	"gopkg.in/yaml.v3"
+)
+
+// initFeatures are the optional services offered by the init wizard, by flag
+// name, with the tcp ports checked for them
+var initFeatures = map[string][]int{
+	"wildcard": nil,
+	"dnssec":   nil,
+	"doh":      nil,
+	"dot":      {853},
+	"ldap":     {389},
+	"ftp":      {21, 990},
+	"smb":      {445},
+	"echo":     {7},
+	"ssh":      {22},
+	"mysql":    {3306},
+	"redis":    {6379, 6380},
+	"imap":     {143, 993},
+	"pop3":     {110, 995},
+	"grpc":     {50051},
+	"tftp":     nil,
+	"snmp":     nil,
+	"ntp":      nil,
+	"sip":      {5060},
+}
+
+// initPorts are the tcp ports of the default services checked by the init wizard
+var initPorts = []int{53, 80, 443, 25, 587, 465}
+
+// initResolver is the public resolver the delegation of the domains is checked with
+var initResolver = "8.8.8.8:53"
+
+// runInitCommand runs the quickstart wizard and reports whether args named it
+func runInitCommand(args []string) bool {
+	if len(args) == 0 || args[0] != "init" {
+		return false
+	}
+	flagSet := flag.NewFlagSet(args[0], flag.ExitOnError)
+	config := flagSet.String("config", defaultConfigLocation, "flag configuration file to write")
+	skipChecks := flagSet.Bool("skip-checks", false, "skip the live dns delegation and port checks")
+	_ = flagSet.Parse(args[1:])
+
+	wizard := &initWizard{in: bufio.NewReader(os.Stdin), out: os.Stderr}
+	if err := wizard.run(*config, !*skipChecks); err != nil {
+		fmt.Fprintf(os.Stderr, "init: %s\n", err)
+		os.Exit(1)
+	}
+	return true
+}
+
+// initWizard asks for the settings of a self-hosted server and writes its configuration
+type initWizard struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// ask returns the answer to a question, or the default value if empty
+func (w *initWizard) ask(question, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(w.out, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(w.out, "%s: ", question)
+	}
+	line, err := w.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	if answer := strings.TrimSpace(line); answer != "" {
+		return answer, nil
+	}
+	return def, nil
+}
+
+// confirm returns the yes or no answer to a question
+func (w *initWizard) confirm(question string, def bool) (bool, error) {
+	choices := "y/N"
+	if def {
+		choices = "Y/n"
+	}
+	answer, err := w.ask(fmt.Sprintf("%s (%s)", question, choices), "")
+	if err != nil || answer == "" {
+		return def, err
+	}
+	return strings.HasPrefix(strings.ToLower(answer), "y"), nil
+}
+
+// check prints the outcome of a check
+func (w *initWizard) check(ok bool, format string, args ...interface{}) {
+	status := "ok"
+	if !ok {
+		status = "!!"
+	}
+	fmt.Fprintf(w.out, "  [%s] %s\n", status, fmt.Sprintf(format, args...))
+}
+
+func (w *initWizard) run(configPath string, checks bool) error {
+	config := make(map[string]interface{})
+
+	answer, err := w.ask("Domain(s) to serve, comma separated (eg. oast.example.com)", "")
+	if err != nil {
+		return err
+	}
+	var domains []string
+	for _, domain := range strings.Split(answer, ",") {
+		domain = server.NormalizeHost(strings.TrimSuffix(strings.TrimSpace(domain), "."))
+		if _, ok := dns.IsDomainName(domain); !ok || !strings.Contains(domain, ".") {
+			return fmt.Errorf("invalid domain %q", domain)
+		}
+		domains = append(domains, domain)
+	}
+	config["domain"] = domains
+
+	detected, _ := iputil.WhatsMyIP()
+	answer, err = w.ask("Public IP address of the server", detected)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(answer)
+	if ip == nil || ip.To4() == nil {
+		return fmt.Errorf("invalid ipv4 address %q", answer)
+	}
+	config["ip"] = ip.String()
+
+	names := make([]string, 0, len(initFeatures))
+	for name := range initFeatures {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	answer, err = w.ask(fmt.Sprintf("Optional services, comma separated (%s)", strings.Join(names, ",")), "")
+	if err != nil {
+		return err
+	}
+	ports := slices.Clone(initPorts)
+	for _, feature := range strings.Split(answer, ",") {
+		feature = strings.ToLower(strings.TrimSpace(feature))
+		if feature == "" {
+			continue
+		}
+		featurePorts, ok := initFeatures[feature]
+		if !ok {
+			return fmt.Errorf("unknown service %q", feature)
+		}
+		config[feature] = true
+		ports = append(ports, featurePorts...)
+	}
+
+	if auth, err := w.confirm("Require a token from the clients", true); err != nil {
+		return err
+	} else if auth {
+		token := make([]byte, 32)
+		if _, err := rand.Read(token); err != nil {
+			return err
+		}
+		config["token"] = hex.EncodeToString(token)
+	}
+	if disk, err := w.confirm("Store the interactions on disk", false); err != nil {
+		return err
+	} else if disk {
+		path, err := w.ask("Disk storage path", filepath.Join(filepath.Dir(defaultConfigLocation), "storage"))
+		if err != nil {
+			return err
+		}
+		config["disk"] = true
+		config["disk-path"] = path
+	}
+	certificates, err := w.ask("Certificates (acme, self-signed, none)", "acme")
+	if err != nil {
+		return err
+	}
+	switch certificates {
+	case "acme":
+	case "self-signed":
+		config["self-signed"] = true
+	case "none":
+		config["skip-acme"] = true
+	default:
+		return fmt.Errorf("unknown certificates %q", certificates)
+	}
+
+	delegated := true
+	if checks {
+		fmt.Fprintf(w.out, "\nChecking the setup live:\n")
+		probe, err := newInitDNS(ip)
+		if err != nil {
+			w.check(false, "dns server on port 53: %s", err)
+			delegated = false
+		} else {
+			defer probe.close()
+			for _, domain := range domains {
+				delegated = w.checkDelegation(probe, domain, ip) && delegated
+			}
+		}
+		w.checkPorts(ports, ip, probe != nil)
+
+		switch {
+		case certificates == "acme" && !delegated:
+			fmt.Fprintf(w.out, "\nThe certificates will be requested at the first start, once the domains are delegated\n")
+		case certificates == "acme":
+			fmt.Fprintf(w.out, "\nRequesting the certificates:\n")
+			for _, domain := range domains {
+				_, files, err := acme.HandleWildcardCertificates("*."+domain, "admin@"+domain, probe.acmeStore, &acme.AccountOptions{}, false)
+				if err != nil {
+					w.check(false, "certificate of *.%s: %s", domain, err)
+					continue
+				}
+				for _, file := range files {
+					w.check(true, "certificate of *.%s: %s", domain, file.CertPath)
+				}
+			}
+		}
+	}
+	if certificates == "self-signed" {
+		selfSigned, err := acme.HandleSelfSignedCertificates(defaultSelfSignedLocation, domains)
+		if err != nil {
+			return fmt.Errorf("could not generate self-signed certificates: %w", err)
+		}
+		fmt.Fprintf(w.out, "\nSelf-signed CA fingerprint (SHA256): %s\n", selfSigned.CAFingerprint)
+	}
+
+	return w.writeConfig(configPath, config)
+}
+
+// checkDelegation checks that the name servers of a domain resolve to the
+// server and that queries for the domain reach it through a public resolver
+func (w *initWizard) checkDelegation(probe *initDNS, domain string, ip net.IP) bool {
+	resolver := &net.Resolver{PreferGo: true, Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, initResolver)
+	}}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ok := true
+	nameServers, err := resolver.LookupNS(ctx, domain)
+	if err != nil || len(nameServers) == 0 {
+		w.check(false, "%s has no name servers, register ns1.%s and ns2.%s with glue records to %s at the registrar", domain, domain, domain, ip)
+		ok = false
+	}
+	for _, nameServer := range nameServers {
+		addresses, err := resolver.LookupHost(ctx, nameServer.Host)
+		matches := err == nil && slices.Contains(addresses, ip.String())
+		w.check(matches, "name server %s of %s resolves to %s", strings.TrimSuffix(nameServer.Host, "."), domain, strings.Join(addresses, ","))
+		ok = ok && matches
+	}
+
+	// a random name can't be cached, so the query must reach the probe
+	nonce := make([]byte, 6)
+	_, _ = rand.Read(nonce)
+	name := fmt.Sprintf("init-%s.%s", hex.EncodeToString(nonce), domain)
+	_, _ = resolver.LookupHost(ctx, name)
+	received := probe.received(name)
+	if received {
+		w.check(true, "queries for %s reach this server through %s", domain, initResolver)
+	} else {
+		w.check(false, "queries for %s don't reach this server through %s, check the delegation and udp port 53", domain, initResolver)
+	}
+	return ok && received
+}
+
+// checkPorts checks that the tcp ports can be bound and reached on the public
+// ip, the dns port being bound by the probe when it runs
+func (w *initWizard) checkPorts(ports []int, ip net.IP, probing bool) {
+	slices.Sort(ports)
+	for _, port := range slices.Compact(ports) {
+		address := net.JoinHostPort(ip.String(), strconv.Itoa(port))
+		var listener net.Listener
+		if port != 53 || !probing {
+			var err error
+			if listener, err = net.Listen("tcp", fmt.Sprintf(":%d", port)); err != nil {
+				w.check(false, "port %d can't be bound: %s", port, err)
+				continue
+			}
+		}
+		conn, err := net.DialTimeout("tcp", address, 3*time.Second)
+		if err == nil {
+			_ = conn.Close()
+		}
+		if listener != nil {
+			_ = listener.Close()
+		}
+		if err != nil {
+			// hairpin nat can prevent reaching the public address from the server itself
+			w.check(false, "port %d not reachable on %s, check the firewall (or hairpin nat on the server)", port, address)
+			continue
+		}
+		w.check(true, "port %d reachable on %s", port, address)
+	}
+}
+
+// writeConfig writes the flag configuration file, asking before overwriting it
+func (w *initWizard) writeConfig(path string, config map[string]interface{}) error {
+	if fileutil.FileExists(path) {
+		overwrite, err := w.confirm(fmt.Sprintf("\n%s exists, overwrite it", path), false)
+		if err != nil {
+			return err
+		}
+		if !overwrite {
+			return errors.New("configuration not written")
+		}
+	}
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	// the file holds the client token
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return err
+	}
+	fmt.Fprintf(w.out, "\nWrote %s, start the server with:\n  interactsh-server -config %s\n", path, path)
+	if token, ok := config["token"]; ok {
+		fmt.Fprintf(w.out, "Clients connect with:\n  interactsh-client -server %s -token %s\n", config["domain"].([]string)[0], token)
+	}
+	return nil
+}
+
+// initDNS is a temporary dns server answering the probes of the delegation
+// checks and the acme challenges while the wizard runs
+type initDNS struct {
+	ip        net.IP
+	acmeStore *acme.Provider
+	servers   []*dns.Server
+
+	mu      sync.Mutex
+	queries map[string]struct{}
+}
+
+func newInitDNS(ip net.IP) (*initDNS, error) {
+	probe := &initDNS{ip: ip, acmeStore: acme.NewProvider(), queries: make(map[string]struct{})}
+	for _, network := range []string{"udp", "tcp"} {
+		started := make(chan error, 1)
+		dnsServer := &dns.Server{Addr: ":53", Net: network, Handler: probe, NotifyStartedFunc: func() { started <- nil }}
+		go func() {
+			if err := dnsServer.ListenAndServe(); err != nil {
+				started <- err
+			}
+		}()
+		if err := <-started; err != nil {
+			probe.close()
+			return nil, err
+		}
+		probe.servers = append(probe.servers, dnsServer)
+	}
+	return probe, nil
+}
+
+// ServeDNS answers the address of the server and the acme challenges
+func (p *initDNS) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+	for _, question := range r.Question {
+		name := strings.ToLower(question.Name)
+		p.mu.Lock()
+		p.queries[strings.TrimSuffix(name, ".")] = struct{}{}
+		p.mu.Unlock()
+
+		switch {
+		case question.Qtype == dns.TypeTXT && strings.HasPrefix(name, acme.DNSChallengeString):
+			records, _ := p.acmeStore.GetRecords(context.Background(), name)
+			for _, record := range records {
+				m.Answer = append(m.Answer, &dns.TXT{Hdr: dns.RR_Header{Name: question.Name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: uint32(record.TTL)}, Txt: []string{record.Value}})
+			}
+		case question.Qtype == dns.TypeA:
+			m.Answer = append(m.Answer, &dns.A{Hdr: dns.RR_Header{Name: question.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 0}, A: p.ip})
+		}
+	}
+	_ = w.WriteMsg(m)
+}
+
+// received reports whether a query for the name was received, waiting for it a while
+func (p *initDNS) received(name string) bool {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		p.mu.Lock()
+		_, ok := p.queries[strings.ToLower(name)]
+		p.mu.Unlock()
+		if ok || time.Now().After(deadline) {
+			return ok
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+func (p *initDNS) close() {
+	for _, dnsServer := range p.servers {
+		_ = dnsServer.Shutdown()
+	}
+}
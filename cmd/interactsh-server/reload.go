@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/interactsh/internal/secrets"
+	"github.com/projectdiscovery/interactsh/pkg/options"
+	"github.com/projectdiscovery/interactsh/pkg/server"
+)
+
+// serverReloader reloads the server options from the arguments and config
+// file of the server, on SIGHUP or through the admin api
+type serverReloader struct {
+	mu              sync.Mutex
+	serverOptions   *server.Options
+	protocolServers *server.ProtocolServers
+	tlsConfig       *tls.Config
+}
+
+// reload applies the domains, token, wildcard setting, http responses and
+// custom dns records read again, then restarts the protocol servers whose
+// ports changed. The other options require restarting the server.
+func (r *serverReloader) reload() (*server.ReloadResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// the flags are parsed again as at startup, command line values taking
+	// precedence over the config file ones
+	cliOptions := &options.CLIServerOptions{}
+	flagSet := newFlagSet(cliOptions)
+	if err := flagSet.CommandLine.Parse(os.Args[1:]); err != nil {
+		return nil, err
+	}
+	if configPath, err := flagSet.GetConfigFilePath(); err == nil {
+		_ = flagSet.MergeConfigFile(configPath)
+	}
+	if cliOptions.Config != defaultConfigLocation {
+		if err := flagSet.MergeConfigFile(cliOptions.Config); err != nil {
+			return nil, fmt.Errorf("could not read config: %w", err)
+		}
+	}
+
+	token, err := secrets.Resolve(cliOptions.Token)
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve token: %w", err)
+	}
+	for i, domain := range cliOptions.Domains {
+		cliOptions.Domains[i] = server.NormalizeHost(domain)
+	}
+	var responses []*server.HTTPResponse
+	if cliOptions.HTTPResponses != "" {
+		if responses, err = server.NewHTTPResponses(cliOptions.HTTPResponses); err != nil {
+			return nil, fmt.Errorf("could not load http responses: %w", err)
+		}
+	}
+	var dnsRecords *server.DNSRecords
+	if cliOptions.DNSRecords != "" {
+		if dnsRecords, err = server.NewDNSRecords(cliOptions.DNSRecords); err != nil {
+			return nil, fmt.Errorf("could not load dns records: %w", err)
+		}
+	}
+	if err := r.serverOptions.Reload(&server.ReloadOptions{
+		Domains:       cliOptions.Domains,
+		Token:         token,
+		RootTLD:       cliOptions.RootTLD,
+		HTTPResponses: responses,
+		CustomRecords: cliOptions.CustomRecords,
+		DNSRecords:    dnsRecords,
+	}); err != nil {
+		return nil, err
+	}
+
+	response := &server.ReloadResponse{}
+	current, reloaded := protocolPorts(r.serverOptions), protocolPorts(cliOptions.AsServerOptions())
+	for _, name := range server.Protocols() {
+		if _, running := r.protocolServers.Get(name); !running || equalPorts(current[name], reloaded[name]) {
+			continue
+		}
+		r.protocolServers.Stop(name)
+		for i, port := range current[name] {
+			*port = *reloaded[name][i]
+		}
+		if err := r.protocolServers.Start(r.tlsConfig, name); err != nil {
+			return response, fmt.Errorf("could not restart %s server: %w", name, err)
+		}
+		response.Restarted = append(response.Restarted, name)
+	}
+	if len(response.Restarted) > 0 {
+		gologger.Info().Msgf("Reloaded server options, restarted the %s servers\n", strings.Join(response.Restarted, ","))
+	} else {
+		gologger.Info().Msgf("Reloaded server options\n")
+	}
+	return response, nil
+}
+
+// protocolPorts returns the port options of the protocol servers listening on
+// fixed ports, the catch-all servers are not restarted
+func protocolPorts(serverOptions *server.Options) map[string][]*int {
+	return map[string][]*int{
+		"dns":   {&serverOptions.DnsPort},
+		"dot":   {&serverOptions.DoTPort},
+		"http":  {&serverOptions.HttpPort, &serverOptions.HttpsPort},
+		"smtp":  {&serverOptions.SmtpPort, &serverOptions.SmtpsPort, &serverOptions.SmtpAutoTLSPort},
+		"ldap":  {&serverOptions.LdapPort},
+		"ftp":   {&serverOptions.FtpPort, &serverOptions.FtpsPort},
+		"smb":   {&serverOptions.SmbPort},
+		"echo":  {&serverOptions.EchoTCPPort, &serverOptions.EchoUDPPort},
+		"ssh":   {&serverOptions.SshPort},
+		"mysql": {&serverOptions.MysqlPort},
+		"redis": {&serverOptions.RedisPort, &serverOptions.RedisTLSPort},
+		"imap":  {&serverOptions.IMAPPort, &serverOptions.IMAPSPort},
+		"pop3":  {&serverOptions.POP3Port, &serverOptions.POP3SPort},
+		"tftp":  {&serverOptions.TFTPPort},
+		"snmp":  {&serverOptions.SNMPPort, &serverOptions.SNMPTrapPort},
+		"ntp":   {&serverOptions.NTPPort},
+		"sip":   {&serverOptions.SIPPort},
+		"grpc":  {&serverOptions.GRPCPort},
+	}
+}
+
+// equalPorts reports whether the port options have the same values
+func equalPorts(current, reloaded []*int) bool {
+	if len(current) != len(reloaded) {
+		return false
+	}
+	for i := range current {
+		if *current[i] != *reloaded[i] {
+			return false
+		}
+	}
+	return true
+}
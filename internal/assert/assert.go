@@ -0,0 +1,168 @@
+// Package assert evaluates declarative expectations on the interactions of a
+// payload, for regression tests of out-of-band detection in pipelines.
+package assert
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/projectdiscovery/interactsh/pkg/server"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultTimeout is the time waited for the interactions when the spec sets none
+var DefaultTimeout = 30 * time.Second
+
+// URLPlaceholder is replaced by the payload in the trigger command
+const URLPlaceholder = "{{url}}"
+
+// Spec is a declarative test of the interactions received by a payload
+type Spec struct {
+	// Timeout is the time waited for the expected interactions
+	Timeout time.Duration `yaml:"timeout"`
+	// Trigger is the command run to make the target interact with the payload,
+	// as program and arguments with {{url}} replaced by the payload
+	Trigger []string `yaml:"trigger"`
+	// Expect are the expected interactions
+	Expect []Expectation `yaml:"expect"`
+}
+
+// Expectation is a number of interactions of a protocol containing a value
+type Expectation struct {
+	// Protocol of the interactions, any if empty
+	Protocol string `yaml:"protocol" json:"protocol,omitempty"`
+	// Contains is a value contained in the request or the full id of the interactions
+	Contains string `yaml:"contains" json:"contains,omitempty"`
+	// Count is the minimum number of interactions, 1 unless a maximum is set
+	Count int `yaml:"count" json:"count"`
+	// Max is the maximum number of interactions, unbounded if unset
+	Max *int `yaml:"max" json:"max,omitempty"`
+}
+
+// matches reports whether an interaction matches the expectation
+func (e *Expectation) matches(interaction *server.Interaction) bool {
+	if e.Protocol != "" && !strings.EqualFold(e.Protocol, interaction.Protocol) {
+		return false
+	}
+	if e.Contains == "" {
+		return true
+	}
+	return strings.Contains(interaction.RawRequest, e.Contains) || strings.Contains(interaction.FullId, e.Contains)
+}
+
+// Load reads a spec from a yaml file
+func Load(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	spec := &Spec{}
+	if err := yaml.Unmarshal(data, spec); err != nil {
+		return nil, fmt.Errorf("could not decode spec: %w", err)
+	}
+	if len(spec.Expect) == 0 {
+		return nil, errors.New("the spec expects no interaction")
+	}
+	if spec.Timeout <= 0 {
+		spec.Timeout = DefaultTimeout
+	}
+	for i := range spec.Expect {
+		expectation := &spec.Expect[i]
+		if expectation.Count == 0 && expectation.Max == nil {
+			expectation.Count = 1
+		}
+		if expectation.Max != nil && *expectation.Max < expectation.Count {
+			return nil, fmt.Errorf("expectation %d has a maximum lower than its count", i+1)
+		}
+	}
+	return spec, nil
+}
+
+// Command returns the trigger command of the payload, if any
+func (s *Spec) Command(payload string) []string {
+	var command []string
+	for _, arg := range s.Trigger {
+		command = append(command, strings.ReplaceAll(arg, URLPlaceholder, payload))
+	}
+	return command
+}
+
+// Result is the machine-readable outcome of a spec
+type Result struct {
+	Pass         bool                `json:"pass"`
+	Payload      string              `json:"payload,omitempty"`
+	Elapsed      int64               `json:"elapsed-ms"`
+	Expectations []ExpectationResult `json:"expectations,omitempty"`
+	Error        string              `json:"error,omitempty"`
+}
+
+// ExpectationResult is the outcome of an expectation
+type ExpectationResult struct {
+	Expectation
+	Matched int  `json:"matched"`
+	Pass    bool `json:"pass"`
+}
+
+// Tracker counts the interactions matching the expectations of a spec
+type Tracker struct {
+	spec *Spec
+
+	mu      sync.Mutex
+	matched []int
+}
+
+// NewTracker returns a tracker of the expectations of the spec
+func NewTracker(spec *Spec) *Tracker {
+	return &Tracker{spec: spec, matched: make([]int, len(spec.Expect))}
+}
+
+// Add accounts an interaction in the expectations it matches
+func (t *Tracker) Add(interaction *server.Interaction) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i := range t.spec.Expect {
+		if t.spec.Expect[i].matches(interaction) {
+			t.matched[i]++
+		}
+	}
+}
+
+// Done reports whether the outcome is known before the timeout, when an
+// expectation exceeds its maximum or all of them are met without maximum
+func (t *Tracker) Done() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	met := true
+	for i, expectation := range t.spec.Expect {
+		if expectation.Max != nil {
+			if t.matched[i] > *expectation.Max {
+				return true
+			}
+			met = false
+		}
+		if t.matched[i] < expectation.Count {
+			met = false
+		}
+	}
+	return met
+}
+
+// Result returns the outcome of the expectations
+func (t *Tracker) Result(payload string, elapsed time.Duration) *Result {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := &Result{Pass: true, Payload: payload, Elapsed: elapsed.Milliseconds()}
+	for i, expectation := range t.spec.Expect {
+		pass := t.matched[i] >= expectation.Count && (expectation.Max == nil || t.matched[i] <= *expectation.Max)
+		result.Expectations = append(result.Expectations, ExpectationResult{Expectation: expectation, Matched: t.matched[i], Pass: pass})
+		result.Pass = result.Pass && pass
+	}
+	return result
+}
@@ -0,0 +1,73 @@
+package assert
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/projectdiscovery/interactsh/pkg/server"
+	"github.com/stretchr/testify/require"
+)
+
+func writeSpec(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "spec.yaml")
+	require.Nil(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	spec, err := Load(writeSpec(t, `
+trigger: [curl, "http://{{url}}/x"]
+expect:
+  - protocol: dns
+    count: 2
+  - protocol: http
+    contains: "GET /x"
+  - protocol: smtp
+    max: 0
+`))
+	require.Nil(t, err)
+	require.Equal(t, DefaultTimeout, spec.Timeout)
+	require.Equal(t, 2, spec.Expect[0].Count)
+	require.Equal(t, 1, spec.Expect[1].Count)
+	require.Equal(t, 0, spec.Expect[2].Count)
+	require.Equal(t, []string{"curl", "http://abc.oast.fun/x"}, spec.Command("abc.oast.fun"))
+
+	_, err = Load(writeSpec(t, "timeout: 5s\n"))
+	require.NotNil(t, err, "a spec without expectation should be rejected")
+	_, err = Load(writeSpec(t, "expect:\n  - count: 3\n    max: 1\n"))
+	require.NotNil(t, err, "a maximum lower than the count should be rejected")
+}
+
+func TestTracker(t *testing.T) {
+	max := 0
+	spec := &Spec{Timeout: time.Second, Expect: []Expectation{
+		{Protocol: "dns", Count: 2},
+		{Protocol: "http", Contains: "GET /x", Count: 1},
+	}}
+	tracker := NewTracker(spec)
+	tracker.Add(&server.Interaction{Protocol: "dns"})
+	tracker.Add(&server.Interaction{Protocol: "http", RawRequest: "GET /y HTTP/1.1"})
+	require.False(t, tracker.Done())
+	require.False(t, tracker.Result("", 0).Pass)
+
+	tracker.Add(&server.Interaction{Protocol: "dns"})
+	tracker.Add(&server.Interaction{Protocol: "http", RawRequest: "GET /x HTTP/1.1"})
+	require.True(t, tracker.Done())
+	result := tracker.Result("abc.oast.fun", 1500*time.Millisecond)
+	require.True(t, result.Pass)
+	require.EqualValues(t, 1500, result.Elapsed)
+	require.Equal(t, 2, result.Expectations[0].Matched)
+
+	spec.Expect = append(spec.Expect, Expectation{Protocol: "smtp", Max: &max})
+	tracker = NewTracker(spec)
+	tracker.Add(&server.Interaction{Protocol: "dns"})
+	tracker.Add(&server.Interaction{Protocol: "dns"})
+	tracker.Add(&server.Interaction{Protocol: "http", RawRequest: "GET /x HTTP/1.1"})
+	require.False(t, tracker.Done(), "a maximum is only settled at the timeout")
+	require.True(t, tracker.Result("", 0).Pass)
+	tracker.Add(&server.Interaction{Protocol: "smtp"})
+	require.True(t, tracker.Done())
+	require.False(t, tracker.Result("", 0).Pass)
+}
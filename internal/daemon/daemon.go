@@ -0,0 +1,59 @@
+// Package daemon manages the client as a supervised system service
+// (systemd on linux, launchd on darwin and the service manager on windows).
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Options contains configuration options for the service
+type Options struct {
+	// Name is the name of the service
+	Name string
+	// Description is the human readable description of the service
+	Description string
+	// Executable is the absolute path of the binary run by the service
+	Executable string
+	// Args are the arguments passed to the executable
+	Args []string
+}
+
+// Actions are the supported service management actions
+var Actions = []string{"install", "uninstall", "start", "stop"}
+
+// ErrUnsupported is returned on platforms without a supported service manager
+var ErrUnsupported = errors.New("service mode is not supported on this platform")
+
+// Control performs the service management action
+func Control(action string, options *Options) error {
+	switch strings.ToLower(action) {
+	case "install":
+		if options.Executable == "" {
+			executable, err := os.Executable()
+			if err != nil {
+				return err
+			}
+			options.Executable = executable
+		}
+		return install(options)
+	case "uninstall":
+		return uninstall(options.Name)
+	case "start":
+		return start(options.Name)
+	case "stop":
+		return stop(options.Name)
+	default:
+		return fmt.Errorf("unknown service action %q (valid: %s)", action, strings.Join(Actions, ","))
+	}
+}
+
+// quote quotes the argument if it contains spaces
+func quote(arg string) string {
+	if strings.ContainsAny(arg, " \t\"") {
+		return `"` + strings.ReplaceAll(arg, `"`, `\"`) + `"`
+	}
+	return arg
+}
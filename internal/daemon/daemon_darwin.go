@@ -0,0 +1,91 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const launchdPlist = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>%s</string>
+	<key>ProgramArguments</key>
+	<array>
+%s	</array>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<true/>
+</dict>
+</plist>
+`
+
+// launchd returns the plist path, launch daemons are used when running as root
+func launchd(name string) (string, error) {
+	if os.Geteuid() == 0 {
+		return filepath.Join("/Library/LaunchDaemons", name+".plist"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library", "LaunchAgents", name+".plist"), nil
+}
+
+func launchctl(args ...string) error {
+	output, err := exec.Command("launchctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("launchctl %s: %s (%s)", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func install(options *Options) error {
+	plistPath, err := launchd(options.Name)
+	if err != nil {
+		return err
+	}
+	var arguments strings.Builder
+	for _, arg := range append([]string{options.Executable}, options.Args...) {
+		var escaped bytes.Buffer
+		_ = xml.EscapeText(&escaped, []byte(arg))
+		arguments.WriteString(fmt.Sprintf("\t\t<string>%s</string>\n", escaped.String()))
+	}
+	if err := os.MkdirAll(filepath.Dir(plistPath), 0755); err != nil {
+		return err
+	}
+	plist := fmt.Sprintf(launchdPlist, options.Name, arguments.String())
+	if err := os.WriteFile(plistPath, []byte(plist), 0644); err != nil {
+		return err
+	}
+	return launchctl("load", "-w", plistPath)
+}
+
+func uninstall(name string) error {
+	plistPath, err := launchd(name)
+	if err != nil {
+		return err
+	}
+	_ = launchctl("unload", "-w", plistPath)
+	return os.Remove(plistPath)
+}
+
+func start(name string) error {
+	return launchctl("start", name)
+}
+
+func stop(name string) error {
+	return launchctl("stop", name)
+}
+
+// Notify relays service stop requests to the channel, launchd relies on SIGTERM
+func Notify(name string, c chan<- os.Signal) error {
+	return nil
+}
@@ -0,0 +1,106 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/projectdiscovery/gologger"
+)
+
+const systemdUnit = `[Unit]
+Description=%s
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+ExecStart=%s
+Restart=always
+RestartSec=10
+
+[Install]
+WantedBy=%s
+`
+
+// systemd returns the unit path and the systemctl arguments prefix, user
+// units are used when not running as root
+func systemd(name string) (string, []string, error) {
+	if os.Geteuid() == 0 {
+		return filepath.Join("/etc/systemd/system", name+".service"), nil, nil
+	}
+	config, err := os.UserConfigDir()
+	if err != nil {
+		return "", nil, err
+	}
+	return filepath.Join(config, "systemd", "user", name+".service"), []string{"--user"}, nil
+}
+
+func systemctl(prefix []string, args ...string) error {
+	output, err := exec.Command("systemctl", append(prefix, args...)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl %s: %s (%s)", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func install(options *Options) error {
+	unitPath, prefix, err := systemd(options.Name)
+	if err != nil {
+		return err
+	}
+	command := []string{quote(options.Executable)}
+	for _, arg := range options.Args {
+		command = append(command, quote(arg))
+	}
+	wantedBy := "multi-user.target"
+	if prefix != nil {
+		wantedBy = "default.target"
+		gologger.Info().Msgf("Installing user service, enable lingering (loginctl enable-linger) to start it at boot\n")
+	}
+	if err := os.MkdirAll(filepath.Dir(unitPath), 0755); err != nil {
+		return err
+	}
+	unit := fmt.Sprintf(systemdUnit, options.Description, strings.Join(command, " "), wantedBy)
+	if err := os.WriteFile(unitPath, []byte(unit), 0644); err != nil {
+		return err
+	}
+	if err := systemctl(prefix, "daemon-reload"); err != nil {
+		return err
+	}
+	return systemctl(prefix, "enable", options.Name)
+}
+
+func uninstall(name string) error {
+	unitPath, prefix, err := systemd(name)
+	if err != nil {
+		return err
+	}
+	_ = systemctl(prefix, "disable", "--now", name)
+	if err := os.Remove(unitPath); err != nil {
+		return err
+	}
+	return systemctl(prefix, "daemon-reload")
+}
+
+func start(name string) error {
+	_, prefix, err := systemd(name)
+	if err != nil {
+		return err
+	}
+	return systemctl(prefix, "start", name)
+}
+
+func stop(name string) error {
+	_, prefix, err := systemd(name)
+	if err != nil {
+		return err
+	}
+	return systemctl(prefix, "stop", name)
+}
+
+// Notify relays service stop requests to the channel, systemd relies on SIGTERM
+func Notify(name string, c chan<- os.Signal) error {
+	return nil
+}
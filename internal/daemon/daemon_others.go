@@ -0,0 +1,26 @@
+//go:build !linux && !darwin && !windows
+
+package daemon
+
+import "os"
+
+func install(options *Options) error {
+	return ErrUnsupported
+}
+
+func uninstall(name string) error {
+	return ErrUnsupported
+}
+
+func start(name string) error {
+	return ErrUnsupported
+}
+
+func stop(name string) error {
+	return ErrUnsupported
+}
+
+// Notify relays service stop requests to the channel
+func Notify(name string, c chan<- os.Signal) error {
+	return nil
+}
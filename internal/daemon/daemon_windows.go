@@ -0,0 +1,102 @@
+package daemon
+
+import (
+	"os"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+func install(options *Options) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	s, err := m.CreateService(options.Name, options.Executable, mgr.Config{
+		DisplayName: options.Name,
+		Description: options.Description,
+		StartType:   mgr.StartAutomatic,
+	}, options.Args...)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	// restart the service when it unexpectedly stops
+	return s.SetRecoveryActions([]mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: 10 * time.Second},
+	}, uint32((24 * time.Hour).Seconds()))
+}
+
+func withService(name string, do func(s *mgr.Service) error) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(name)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	return do(s)
+}
+
+func uninstall(name string) error {
+	return withService(name, func(s *mgr.Service) error {
+		_, _ = s.Control(svc.Stop)
+		return s.Delete()
+	})
+}
+
+func start(name string) error {
+	return withService(name, func(s *mgr.Service) error {
+		return s.Start()
+	})
+}
+
+func stop(name string) error {
+	return withService(name, func(s *mgr.Service) error {
+		_, err := s.Control(svc.Stop)
+		return err
+	})
+}
+
+// handler reports the service status to the service manager and relays
+// stop requests as interrupt signals
+type handler struct {
+	c chan<- os.Signal
+}
+
+func (h *handler) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for request := range requests {
+		switch request.Cmd {
+		case svc.Interrogate:
+			status <- request.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			status <- svc.Status{State: svc.StopPending}
+			h.c <- os.Interrupt
+			return false, 0
+		}
+	}
+	return false, 0
+}
+
+// Notify relays service stop requests to the channel when the process is
+// run by the windows service manager
+func Notify(name string, c chan<- os.Signal) error {
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService {
+		return err
+	}
+	go func() {
+		_ = svc.Run(name, &handler{c: c})
+	}()
+	return nil
+}
@@ -0,0 +1,276 @@
+package output
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/interactsh/pkg/server"
+	"github.com/projectdiscovery/interactsh/pkg/storage"
+)
+
+// ClickHouse batching defaults, inserts of large batches being much cheaper
+// than many small ones for the merge tree tables
+var (
+	// ClickHouseBatchSize is the number of rows inserted at once
+	ClickHouseBatchSize = 1000
+	// ClickHouseFlushInterval is the maximum delay before the buffered rows are inserted
+	ClickHouseFlushInterval = 5 * time.Second
+	// ClickHouseMaxBuffered is the number of rows kept while the server is unavailable,
+	// the oldest rows being dropped beyond it
+	ClickHouseMaxBuffered = 100000
+)
+
+// clickHouseTableName matches the table names, optionally prefixed by their database
+var clickHouseTableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// ClickHouse inserts the stored interactions into a ClickHouse table through
+// its http interface, in batches of columnar rows with the full interaction
+// kept as json for the fields without column
+type ClickHouse struct {
+	endpoint   *url.URL
+	table      string
+	httpClient *http.Client
+
+	mu   sync.Mutex
+	rows [][]byte
+	// flushMu serializes the inserts, so that rows are inserted in order
+	flushMu sync.Mutex
+	// full signals the flush loop that a batch is buffered
+	full chan struct{}
+	done chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+// clickHouseRow is a row of the interactions table, the remote_ip column is
+// computed by the server from the remote address
+type clickHouseRow struct {
+	Timestamp     string `json:"timestamp"`
+	Protocol      string `json:"protocol"`
+	Session       string `json:"session"`
+	UniqueID      string `json:"unique_id"`
+	FullID        string `json:"full_id"`
+	Domain        string `json:"domain"`
+	QType         string `json:"q_type"`
+	RemoteAddress string `json:"remote_address"`
+	LocalPort     int    `json:"local_port"`
+	ScannerProbe  string `json:"scanner_probe"`
+	Interaction   string `json:"interaction"`
+}
+
+// NewClickHouse creates the interactions table unless it exists and returns a
+// sink inserting into it. The url is the http interface of the server, with
+// the credentials as user info and the database as query parameter if any.
+// Rows older than the retention days are deleted by the server, if not zero.
+func NewClickHouse(rawURL, table string, retentionDays int) (*ClickHouse, error) {
+	endpoint, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+		return nil, errors.New("clickhouse url must use the http interface (http or https)")
+	}
+	if !clickHouseTableName.MatchString(table) {
+		return nil, fmt.Errorf("invalid clickhouse table %s", table)
+	}
+	c := &ClickHouse{endpoint: endpoint, table: table, httpClient: &http.Client{Timeout: 30 * time.Second}, full: make(chan struct{}, 1), done: make(chan struct{})}
+	if err := c.query(c.createTable(retentionDays), nil); err != nil {
+		return nil, fmt.Errorf("could not create clickhouse table: %w", err)
+	}
+	c.wg.Add(1)
+	go c.flushLoop()
+	return c, nil
+}
+
+// createTable returns the statement creating the interactions table, ordered
+// by protocol and time for the per protocol aggregations over time ranges
+func (c *ClickHouse) createTable(retentionDays int) string {
+	var ttl string
+	if retentionDays > 0 {
+		ttl = fmt.Sprintf("\nTTL toDateTime(timestamp) + INTERVAL %d DAY", retentionDays)
+	}
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	timestamp DateTime64(3, 'UTC'),
+	protocol LowCardinality(String),
+	session String,
+	unique_id String,
+	full_id String,
+	domain LowCardinality(String),
+	q_type LowCardinality(String),
+	remote_address String,
+	remote_ip IPv6 DEFAULT toIPv6OrDefault(remote_address),
+	local_port UInt16,
+	scanner_probe LowCardinality(String),
+	interaction String CODEC(ZSTD(3))
+) ENGINE = MergeTree
+PARTITION BY toYYYYMM(timestamp)
+ORDER BY (protocol, timestamp)%s`, c.table, ttl)
+}
+
+// Send buffers a stored interaction, session being its correlation id if
+// stored for a session
+func (c *ClickHouse) Send(session string, data []byte) {
+	interaction := &server.Interaction{}
+	if err := jsoniter.Unmarshal(data, interaction); err != nil {
+		return
+	}
+	row := &clickHouseRow{
+		Timestamp:     interaction.Timestamp.UTC().Format("2006-01-02 15:04:05.000"),
+		Protocol:      interaction.Protocol,
+		Session:       session,
+		UniqueID:      interaction.UniqueID,
+		FullID:        interaction.FullId,
+		Domain:        interaction.Domain,
+		QType:         interaction.QType,
+		RemoteAddress: interaction.RemoteAddress,
+		LocalPort:     interaction.LocalPort,
+		ScannerProbe:  interaction.ScannerProbe,
+		Interaction:   string(bytes.TrimSpace(data)),
+	}
+	line, err := jsoniter.Marshal(row)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	if len(c.rows) >= ClickHouseMaxBuffered {
+		c.rows = c.rows[1:]
+	}
+	c.rows = append(c.rows, line)
+	full := len(c.rows) >= ClickHouseBatchSize
+	c.mu.Unlock()
+	if full {
+		select {
+		case c.full <- struct{}{}:
+		default:
+			// a flush is already pending
+		}
+	}
+}
+
+// Write inserts a polled interaction
+func (c *ClickHouse) Write(interaction *server.Interaction) error {
+	data, err := jsoniter.Marshal(interaction)
+	if err != nil {
+		return err
+	}
+	c.Send("", data)
+	return nil
+}
+
+func (c *ClickHouse) flushLoop() {
+	defer c.wg.Done()
+	ticker := time.NewTicker(ClickHouseFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.flush()
+		case <-c.full:
+			c.flush()
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// flush inserts the buffered rows, which are kept for the next attempt when
+// the server is unavailable
+func (c *ClickHouse) flush() error {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	c.mu.Lock()
+	rows := c.rows
+	c.rows = nil
+	c.mu.Unlock()
+	if len(rows) == 0 {
+		return nil
+	}
+	err := c.query(fmt.Sprintf("INSERT INTO %s FORMAT JSONEachRow", c.table), bytes.Join(rows, []byte("\n")))
+	if err != nil {
+		gologger.Warning().Msgf("Could not insert %d interactions into clickhouse: %s\n", len(rows), err)
+		c.mu.Lock()
+		c.rows = append(rows, c.rows...)
+		if dropped := len(c.rows) - ClickHouseMaxBuffered; dropped > 0 {
+			c.rows = c.rows[dropped:]
+		}
+		c.mu.Unlock()
+	}
+	return err
+}
+
+// query runs a statement, the data of inserts following it in the body
+func (c *ClickHouse) query(statement string, data []byte) error {
+	endpoint := *c.endpoint
+	endpoint.User = nil
+	values := endpoint.Query()
+	values.Set("query", statement)
+	endpoint.RawQuery = values.Encode()
+
+	req, err := http.NewRequest(http.MethodPost, endpoint.String(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if user := c.endpoint.User; user != nil {
+		password, _ := user.Password()
+		req.SetBasicAuth(user.Username(), password)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// Close inserts the buffered rows
+func (c *ClickHouse) Close() error {
+	c.once.Do(func() { close(c.done) })
+	c.wg.Wait()
+	return c.flush()
+}
+
+// ClickHouseStorage returns a storage inserting every stored interaction into clickhouse
+func ClickHouseStorage(store storage.Storage, clickHouse *ClickHouse) storage.Storage {
+	return &clickHouseStorage{Storage: store, clickHouse: clickHouse}
+}
+
+// clickHouseStorage inserts the interactions stored into clickhouse
+type clickHouseStorage struct {
+	storage.Storage
+	clickHouse *ClickHouse
+}
+
+// AddInteraction stores the interaction and inserts it into clickhouse
+func (s *clickHouseStorage) AddInteraction(correlationID string, data []byte) error {
+	if err := s.Storage.AddInteraction(correlationID, data); err != nil {
+		return err
+	}
+	s.clickHouse.Send(correlationID, data)
+	return nil
+}
+
+// AddInteractionWithId stores the interaction and inserts it into clickhouse,
+// without the id which may be the server token
+func (s *clickHouseStorage) AddInteractionWithId(id string, data []byte) error {
+	if err := s.Storage.AddInteractionWithId(id, data); err != nil {
+		return err
+	}
+	s.clickHouse.Send("", data)
+	return nil
+}
@@ -0,0 +1,79 @@
+package output
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/interactsh/pkg/server"
+	"github.com/projectdiscovery/interactsh/pkg/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClickHouse(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		queries []string
+		inserts []string
+	)
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, _ := r.BasicAuth()
+		require.Equal(t, "default", user)
+		require.Equal(t, "secret", password)
+		require.Equal(t, "research", r.URL.Query().Get("database"))
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		queries = append(queries, r.URL.Query().Get("query"))
+		if len(body) > 0 {
+			inserts = append(inserts, string(body))
+		}
+	}))
+	defer endpoint.Close()
+
+	_, err := NewClickHouse(endpoint.URL, "interactions; DROP TABLE x", 0)
+	require.NotNil(t, err)
+	clickHouse, err := NewClickHouse(strings.Replace(endpoint.URL, "http://", "http://default:secret@", 1)+"/?database=research", "interactions", 30)
+	require.Nil(t, err)
+	require.Contains(t, queries[0], "CREATE TABLE IF NOT EXISTS interactions")
+	require.Contains(t, queries[0], "TTL toDateTime(timestamp) + INTERVAL 30 DAY")
+
+	store, err := storage.New(&storage.DefaultOptions)
+	require.Nil(t, err)
+	defer store.Close()
+	require.Nil(t, store.SetID("c59e3crp82ke7bcnedq0"))
+	wrapped := ClickHouseStorage(store, clickHouse)
+	data, err := jsoniter.Marshal(&server.Interaction{Protocol: "dns", UniqueID: "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy", QType: "A", RemoteAddress: "192.0.2.1", Timestamp: time.Date(2024, 5, 12, 10, 31, 7, 0, time.UTC)})
+	require.Nil(t, err)
+	require.Nil(t, wrapped.AddInteraction("c59e3crp82ke7bcnedq0", data))
+	require.Nil(t, clickHouse.Close())
+
+	require.Len(t, queries, 2)
+	require.Equal(t, "INSERT INTO interactions FORMAT JSONEachRow", queries[1])
+	row := &clickHouseRow{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(inserts[0]), row))
+	require.Equal(t, "2024-05-12 10:31:07.000", row.Timestamp)
+	require.Equal(t, "c59e3crp82ke7bcnedq0", row.Session)
+	require.Equal(t, "192.0.2.1", row.RemoteAddress)
+	require.Equal(t, string(data), row.Interaction)
+}
+
+func TestClickHouseFullBatch(t *testing.T) {
+	defer func(size int) { ClickHouseBatchSize = size }(ClickHouseBatchSize)
+	ClickHouseBatchSize = 1
+
+	// full batches only signal the flush loop, which is not running
+	clickHouse := &ClickHouse{full: make(chan struct{}, 1)}
+	data, err := jsoniter.Marshal(&server.Interaction{Protocol: "dns"})
+	require.Nil(t, err)
+	for i := 0; i < 10; i++ {
+		clickHouse.Send("", data)
+	}
+	require.Len(t, clickHouse.full, 1)
+	require.Len(t, clickHouse.rows, 10)
+}
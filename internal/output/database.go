@@ -0,0 +1,86 @@
+package output
+
+import (
+	"database/sql"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/interactsh/pkg/server"
+	// registers the cgo-free sqlite driver
+	_ "modernc.org/sqlite"
+)
+
+// databaseSchema creates the interactions table, the reception order being
+// the one of the row ids
+const databaseSchema = `CREATE TABLE IF NOT EXISTS interactions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	timestamp TEXT NOT NULL,
+	protocol TEXT NOT NULL,
+	unique_id TEXT NOT NULL,
+	full_id TEXT NOT NULL,
+	remote_address TEXT NOT NULL,
+	interaction TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS interactions_unique_id ON interactions (unique_id);`
+
+// Database persists interactions in a local SQLite database, with their
+// protocol, ids and remote address in columns to be queried and the full
+// interaction as json
+type Database struct {
+	db *sql.DB
+}
+
+// NewDatabase opens or creates the SQLite database at the given path
+func NewDatabase(path string) (*Database, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	// sqlite allows a single writer
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(databaseSchema); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &Database{db: db}, nil
+}
+
+// Write stores the interaction in the database
+func (d *Database) Write(interaction *server.Interaction) error {
+	data, err := jsoniter.Marshal(interaction)
+	if err != nil {
+		return err
+	}
+	_, err = d.db.Exec(
+		"INSERT INTO interactions (timestamp, protocol, unique_id, full_id, remote_address, interaction) VALUES (?, ?, ?, ?, ?, ?)",
+		interaction.Timestamp.UTC().Format("2006-01-02T15:04:05.000000000Z"), interaction.Protocol, interaction.UniqueID, interaction.FullId, interaction.RemoteAddress, string(data),
+	)
+	return err
+}
+
+// Interactions returns all the stored interactions in reception order
+func (d *Database) Interactions() ([]*server.Interaction, error) {
+	rows, err := d.db.Query("SELECT interaction FROM interactions ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var interactions []*server.Interaction
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		interaction := &server.Interaction{}
+		if err := jsoniter.UnmarshalFromString(data, interaction); err != nil {
+			return nil, err
+		}
+		interactions = append(interactions, interaction)
+	}
+	return interactions, rows.Err()
+}
+
+// Close closes the database
+func (d *Database) Close() error {
+	return d.db.Close()
+}
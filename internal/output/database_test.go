@@ -0,0 +1,26 @@
+package output
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/projectdiscovery/interactsh/pkg/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatabase(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "interactions.db"))
+	require.Nil(t, err)
+	defer db.Close()
+
+	for _, protocol := range []string{"dns", "http", "smtp"} {
+		err = db.Write(&server.Interaction{Protocol: protocol, FullId: "test"})
+		require.Nil(t, err)
+	}
+
+	interactions, err := db.Interactions()
+	require.Nil(t, err)
+	require.Len(t, interactions, 3)
+	require.Equal(t, "dns", interactions[0].Protocol)
+	require.Equal(t, "smtp", interactions[2].Protocol)
+}
@@ -0,0 +1,37 @@
+// Package output implements the destinations polled interactions are
+// delivered to besides the CLI output.
+package output
+
+import (
+	"github.com/projectdiscovery/interactsh/pkg/server"
+	"go.uber.org/multierr"
+)
+
+// Writer receives each polled interaction
+type Writer interface {
+	// Write delivers an interaction to the destination
+	Write(interaction *server.Interaction) error
+	// Close flushes and releases the destination at session end
+	Close() error
+}
+
+// MultiWriter delivers interactions to multiple writers
+type MultiWriter []Writer
+
+// Write delivers the interaction to all the writers
+func (m MultiWriter) Write(interaction *server.Interaction) error {
+	var errs []error
+	for _, writer := range m {
+		errs = append(errs, writer.Write(interaction))
+	}
+	return multierr.Combine(errs...)
+}
+
+// Close closes all the writers
+func (m MultiWriter) Close() error {
+	var errs []error
+	for _, writer := range m {
+		errs = append(errs, writer.Close())
+	}
+	return multierr.Combine(errs...)
+}
@@ -0,0 +1,134 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/interactsh/pkg/server"
+)
+
+const (
+	// EventInteraction is sent to plugins for each polled interaction
+	EventInteraction = "interaction"
+	// EventSessionEnd is sent to plugins once when the client exits
+	EventSessionEnd = "session-end"
+)
+
+var (
+	// PluginTimeout is the maximum duration a plugin invocation may run
+	PluginTimeout = 30 * time.Second
+	// PluginQueueSize is the number of interactions waiting for a plugin,
+	// the ones beyond it being dropped
+	PluginQueueSize = 256
+)
+
+// PluginEvent is the JSON document written to the plugin standard input
+type PluginEvent struct {
+	Event        string              `json:"event"`
+	Interaction  *server.Interaction `json:"interaction,omitempty"`
+	Interactions uint64              `json:"interactions"`
+}
+
+// Plugin runs an external command for each interaction and at session end,
+// writing the event as JSON on its standard input. The event name is also
+// available in the INTERACTSH_EVENT environment variable. The interactions
+// are passed to the command in background one at a time, so that a slow
+// plugin doesn't stall the polling, and its output is written to stderr.
+type Plugin struct {
+	name  string
+	args  []string
+	count uint64
+
+	mu     sync.Mutex
+	closed bool
+	queue  chan *PluginEvent
+	done   chan struct{}
+}
+
+// NewPlugin returns a plugin running the given command line
+func NewPlugin(command string) (*Plugin, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty plugin command")
+	}
+	p := &Plugin{
+		name:  fields[0],
+		args:  fields[1:],
+		queue: make(chan *PluginEvent, PluginQueueSize),
+		done:  make(chan struct{}),
+	}
+	go p.worker()
+	return p, nil
+}
+
+// Write queues the interaction to be passed to the plugin
+func (p *Plugin) Write(interaction *server.Interaction) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return fmt.Errorf("plugin %s: closed", p.name)
+	}
+	count := atomic.AddUint64(&p.count, 1)
+	select {
+	case p.queue <- &PluginEvent{Event: EventInteraction, Interaction: interaction, Interactions: count}:
+		return nil
+	default:
+		return fmt.Errorf("plugin %s: queue full, interaction dropped", p.name)
+	}
+}
+
+// Close waits for the queued interactions and runs the plugin with the session end event
+func (p *Plugin) Close() error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+	<-p.done
+	return p.run(&PluginEvent{Event: EventSessionEnd, Interactions: atomic.LoadUint64(&p.count)})
+}
+
+// worker runs the plugin with the queued events
+func (p *Plugin) worker() {
+	defer close(p.done)
+	for event := range p.queue {
+		if err := p.run(event); err != nil {
+			gologger.Warning().Msgf("Could not run plugin: %s\n", err)
+		}
+	}
+}
+
+func (p *Plugin) run(event *PluginEvent) error {
+	data, err := jsoniter.Marshal(event)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), PluginTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, p.name, p.args...)
+	cmd.Env = append(os.Environ(), "INTERACTSH_EVENT="+event.Event)
+	cmd.Stdin = bytes.NewReader(data)
+	// the standard output is kept for the interactions
+	cmd.Stdout = os.Stderr
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return fmt.Errorf("plugin %s: %w: %s", p.name, err, message)
+		}
+		return fmt.Errorf("plugin %s: %w", p.name, err)
+	}
+	return nil
+}
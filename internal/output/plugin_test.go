@@ -0,0 +1,46 @@
+package output
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/interactsh/pkg/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlugin(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("plugin test requires a posix shell")
+	}
+	events := filepath.Join(t.TempDir(), "events")
+	script := filepath.Join(t.TempDir(), "plugin.sh")
+	err := os.WriteFile(script, []byte("#!/bin/sh\ncat >> "+events+"\necho >> "+events+"\n"), 0755)
+	require.Nil(t, err)
+
+	plugin, err := NewPlugin("sh " + script)
+	require.Nil(t, err)
+	require.Nil(t, plugin.Write(&server.Interaction{Protocol: "dns"}))
+	require.Nil(t, plugin.Close())
+
+	data, err := os.ReadFile(events)
+	require.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+
+	var event PluginEvent
+	require.Nil(t, jsoniter.Unmarshal([]byte(lines[0]), &event))
+	require.Equal(t, EventInteraction, event.Event)
+	require.Equal(t, "dns", event.Interaction.Protocol)
+	require.Nil(t, jsoniter.Unmarshal([]byte(lines[1]), &event))
+	require.Equal(t, EventSessionEnd, event.Event)
+	require.EqualValues(t, 1, event.Interactions)
+
+	require.NotNil(t, plugin.Write(&server.Interaction{Protocol: "dns"}), "closed plugins don't accept interactions")
+
+	_, err = NewPlugin(" ")
+	require.NotNil(t, err)
+}
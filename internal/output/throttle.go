@@ -0,0 +1,211 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebhookDigestSize is the maximum number of events posted in a digest
+var WebhookDigestSize = 100
+
+// DefaultProviderRates are the rates of the notification providers with
+// documented webhook rate limits, by host
+var DefaultProviderRates = map[string]Rate{
+	"hooks.slack.com":    {Events: 1, Per: time.Second},
+	"discord.com":        {Events: 30, Per: time.Minute},
+	"discordapp.com":     {Events: 30, Per: time.Minute},
+	"webhook.office.com": {Events: 4, Per: time.Second},
+	"api.telegram.org":   {Events: 1, Per: time.Second},
+}
+
+// Rate is a number of events allowed per period, bursting up to the number
+type Rate struct {
+	Events int
+	Per    time.Duration
+}
+
+// ParseRate parses a rate such as 10/s, 30/m or 100/1h
+func ParseRate(value string) (Rate, error) {
+	events, period, ok := strings.Cut(strings.TrimSpace(value), "/")
+	if !ok {
+		return Rate{}, fmt.Errorf("invalid rate %q, expected events/period (eg. 30/m)", value)
+	}
+	count, err := strconv.Atoi(events)
+	if err != nil || count <= 0 {
+		return Rate{}, fmt.Errorf("invalid number of events in rate %q", value)
+	}
+	if period != "" && (period[0] < '0' || period[0] > '9') {
+		period = "1" + period
+	}
+	per, err := time.ParseDuration(period)
+	if err != nil || per <= 0 {
+		return Rate{}, fmt.Errorf("invalid period in rate %q", value)
+	}
+	return Rate{Events: count, Per: per}, nil
+}
+
+// String returns the rate as events/period
+func (r Rate) String() string {
+	return fmt.Sprintf("%d/%s", r.Events, r.Per)
+}
+
+// TokenBucket limits the rate of events, allowing bursts of its capacity
+type TokenBucket struct {
+	mu       sync.Mutex
+	rate     Rate
+	tokens   float64
+	refilled time.Time
+}
+
+// NewTokenBucket returns a full bucket of the rate
+func NewTokenBucket(rate Rate) *TokenBucket {
+	return &TokenBucket{rate: rate, tokens: float64(rate.Events), refilled: time.Now()}
+}
+
+// delay returns the time until a token is available, refilling the bucket
+func (b *TokenBucket) delay(now time.Time) time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	interval := b.rate.Per / time.Duration(b.rate.Events)
+	if elapsed := now.Sub(b.refilled); elapsed > 0 {
+		b.tokens += float64(elapsed) / float64(interval)
+		if b.tokens > float64(b.rate.Events) {
+			b.tokens = float64(b.rate.Events)
+		}
+		b.refilled = now
+	}
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) * float64(interval))
+}
+
+// take consumes a token, possibly going into debt when shared
+func (b *TokenBucket) take() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.tokens--
+	b.mu.Unlock()
+}
+
+// Throttle rate limits the notifications of the webhooks globally and per
+// provider, the events exceeding the limits being posted as digests
+type Throttle struct {
+	// Batch is the time events are collected for before being posted as a digest
+	Batch time.Duration
+
+	global        *TokenBucket
+	providerRates map[string]Rate
+
+	mu        sync.Mutex
+	providers map[string]*TokenBucket
+}
+
+// NewThrottle returns a throttle of the global rate (unlimited if zero) and of
+// the provider rates by host, which override the default provider rates
+func NewThrottle(global Rate, providerRates map[string]Rate, batch time.Duration) *Throttle {
+	throttle := &Throttle{Batch: batch, providerRates: make(map[string]Rate), providers: make(map[string]*TokenBucket)}
+	if global.Events > 0 {
+		throttle.global = NewTokenBucket(global)
+	}
+	for host, rate := range DefaultProviderRates {
+		throttle.providerRates[host] = rate
+	}
+	for host, rate := range providerRates {
+		throttle.providerRates[strings.ToLower(host)] = rate
+	}
+	return throttle
+}
+
+// ParseProviderRates parses host=rate values
+func ParseProviderRates(values []string) (map[string]Rate, error) {
+	rates := make(map[string]Rate, len(values))
+	for _, value := range values {
+		host, rate, ok := strings.Cut(value, "=")
+		if !ok || host == "" {
+			return nil, errors.New("provider rates must be given as host=rate (eg. hooks.slack.com=1/s)")
+		}
+		parsed, err := ParseRate(rate)
+		if err != nil {
+			return nil, err
+		}
+		rates[strings.ToLower(host)] = parsed
+	}
+	return rates, nil
+}
+
+// limiter returns the limiter of a webhook url, nil when the throttle
+// neither limits nor batches its notifications
+func (t *Throttle) limiter(webhookURL string) *webhookLimiter {
+	if t == nil {
+		return nil
+	}
+	var host string
+	if parsed, err := url.Parse(webhookURL); err == nil {
+		host = strings.ToLower(parsed.Hostname())
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	provider, ok := t.providers[host]
+	if rate, limited := t.providerRates[host]; !ok && limited {
+		// the webhooks of a provider share its limit
+		provider = NewTokenBucket(rate)
+		t.providers[host] = provider
+	}
+	if provider == nil && t.global == nil && t.Batch <= 0 {
+		return nil
+	}
+	return &webhookLimiter{global: t.global, provider: provider, batch: t.Batch}
+}
+
+// webhookLimiter are the token buckets a webhook notification takes from
+type webhookLimiter struct {
+	global, provider *TokenBucket
+	batch            time.Duration
+}
+
+// reserve takes a token from the buckets if available, or returns the time
+// until one is
+func (l *webhookLimiter) reserve(now time.Time) time.Duration {
+	delay := l.global.delay(now)
+	if providerDelay := l.provider.delay(now); providerDelay > delay {
+		delay = providerDelay
+	}
+	if delay == 0 {
+		l.global.take()
+		l.provider.take()
+	}
+	return delay
+}
+
+// ParseThrottle returns the throttle of a global rate (unlimited if empty),
+// host=rate provider rates and batch window given as flags
+func ParseThrottle(global string, providerRates []string, batch time.Duration) (*Throttle, error) {
+	var rate Rate
+	if global != "" {
+		var err error
+		if rate, err = ParseRate(global); err != nil {
+			return nil, err
+		}
+	}
+	rates, err := ParseProviderRates(providerRates)
+	if err != nil {
+		return nil, err
+	}
+	if batch < 0 {
+		return nil, errors.New("the batch window can't be negative")
+	}
+	return NewThrottle(rate, rates, batch), nil
+}
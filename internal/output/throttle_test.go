@@ -0,0 +1,74 @@
+package output
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRate(t *testing.T) {
+	rate, err := ParseRate("30/m")
+	require.Nil(t, err)
+	require.Equal(t, Rate{Events: 30, Per: time.Minute}, rate)
+	rate, err = ParseRate("100/2h")
+	require.Nil(t, err)
+	require.Equal(t, Rate{Events: 100, Per: 2 * time.Hour}, rate)
+
+	for _, value := range []string{"30", "0/s", "x/s", "1/x", "1/-1s"} {
+		_, err := ParseRate(value)
+		require.NotNil(t, err, value)
+	}
+	_, err = ParseProviderRates([]string{"hooks.slack.com"})
+	require.NotNil(t, err)
+}
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	bucket := NewTokenBucket(Rate{Events: 2, Per: time.Second})
+	for i := 0; i < 2; i++ {
+		require.Equal(t, time.Duration(0), bucket.delay(now))
+		bucket.take()
+	}
+	require.Greater(t, bucket.delay(now), time.Duration(0))
+	require.Equal(t, time.Duration(0), bucket.delay(now.Add(time.Second)))
+}
+
+func TestThrottledWebhook(t *testing.T) {
+	var mu sync.Mutex
+	var posts []string
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		posts = append(posts, string(body))
+		mu.Unlock()
+	}))
+	defer endpoint.Close()
+
+	// unknown providers aren't throttled by default
+	require.Nil(t, NewThrottle(Rate{}, nil, 0).limiter(endpoint.URL))
+	require.NotNil(t, NewThrottle(Rate{}, nil, 0).limiter("https://hooks.slack.com/services/x"))
+
+	webhook := NewThrottledWebhook(endpoint.URL, nil, "", NewThrottle(Rate{Events: 1, Per: time.Hour}, nil, 0))
+	for i := 0; i < 5; i++ {
+		webhook.Send([]byte(`{"protocol":"dns"}` + "\n"))
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Nil(t, webhook.Close())
+
+	// the events exceeding the rate are posted as a digest on close
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, posts, 2)
+	require.Contains(t, posts[0], `{"protocol":"dns"}`)
+	digest := &digestEvent{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(posts[1]), digest))
+	require.True(t, digest.Digest)
+	require.Equal(t, 4, digest.Count)
+	require.Len(t, digest.Events, 4)
+}
@@ -0,0 +1,122 @@
+package output
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/interactsh/pkg/server"
+)
+
+// Usage tracks the emitted payloads receiving interactions and reports the
+// unused ones at session end, useful for coverage analysis of injection points.
+// The time to first interaction of the used payloads measures the delay of
+// the asynchronous pipelines processing them.
+type Usage struct {
+	path string
+
+	mu       sync.Mutex
+	payloads []emittedPayload
+	hits     map[string]int
+	// firsts are the smallest latencies of the payloads generated by the client
+	firsts map[string]time.Duration
+}
+
+// emittedPayload is a payload generated by the client with its unique id
+type emittedPayload struct {
+	payload  string
+	uniqueID string
+}
+
+// NewUsage returns a usage tracker writing the unused payloads report to the given
+// file, the report is only logged if the path is empty
+func NewUsage(path string) *Usage {
+	return &Usage{path: path, hits: make(map[string]int), firsts: make(map[string]time.Duration)}
+}
+
+// Emit records a payload generated by the client with its unique id, as
+// called back by the client for each payload
+func (u *Usage) Emit(payload, uniqueID string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.payloads = append(u.payloads, emittedPayload{payload: payload, uniqueID: strings.ToLower(uniqueID)})
+}
+
+// Write marks the payload of the interaction as used
+func (u *Usage) Write(interaction *server.Interaction) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	id := strings.ToLower(interaction.UniqueID)
+	u.hits[id]++
+	if interaction.PayloadLatency != nil {
+		latency := time.Duration(*interaction.PayloadLatency) * time.Millisecond
+		if first, ok := u.firsts[id]; !ok || latency < first {
+			u.firsts[id] = latency
+		}
+	}
+	return nil
+}
+
+// FirstLatencies returns the time to first interaction of the emitted
+// payloads which received interactions, in emission order
+func (u *Usage) FirstLatencies() ([]string, []time.Duration) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	var (
+		payloads  []string
+		latencies []time.Duration
+	)
+	for _, emitted := range u.payloads {
+		if latency, ok := u.firsts[emitted.uniqueID]; ok {
+			payloads = append(payloads, emitted.payload)
+			latencies = append(latencies, latency)
+		}
+	}
+	return payloads, latencies
+}
+
+// Unused returns the emitted payloads which never received interactions
+func (u *Usage) Unused() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	var unused []string
+	for _, emitted := range u.payloads {
+		if u.hits[emitted.uniqueID] == 0 {
+			unused = append(unused, emitted.payload)
+		}
+	}
+	return unused
+}
+
+// Close reports the unused payloads
+func (u *Usage) Close() error {
+	unused := u.Unused()
+	u.mu.Lock()
+	total := len(u.payloads)
+	u.mu.Unlock()
+
+	gologger.Info().Msgf("%d/%d payloads received interactions\n", total-len(unused), total)
+	payloads, latencies := u.FirstLatencies()
+	if len(latencies) > 0 {
+		sorted := append([]time.Duration(nil), latencies...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		gologger.Info().Msgf("Time to first interaction: min %s, median %s, max %s\n", sorted[0], sorted[len(sorted)/2], sorted[len(sorted)-1])
+	}
+	if u.path == "" {
+		for i, payload := range payloads {
+			gologger.Info().Msgf("First interaction of %s after %s\n", payload, latencies[i])
+		}
+		for _, payload := range unused {
+			gologger.Info().Msgf("Unused payload: %s\n", payload)
+		}
+		return nil
+	}
+	var data string
+	if len(unused) > 0 {
+		data = strings.Join(unused, "\n") + "\n"
+	}
+	return os.WriteFile(u.path, []byte(data), 0644)
+}
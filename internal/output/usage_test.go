@@ -0,0 +1,46 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/projectdiscovery/interactsh/pkg/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsage(t *testing.T) {
+	report := filepath.Join(t.TempDir(), "unused.txt")
+	usage := NewUsage(report)
+	usage.Emit("c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.oast.pro", "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy")
+	usage.Emit("c59e3crp82ke7bcnedq0abcdefghijklm.oast.pro", "c59e3crp82ke7bcnedq0abcdefghijklm")
+
+	// the payloads of a namespace are matched on their unique id
+	usage.Emit("sqli.c59e3crp82ke7bcnedq0nopqrstuvwxyz.oast.pro", "c59e3crp82ke7bcnedq0nopqrstuvwxyz")
+
+	err := usage.Write(&server.Interaction{UniqueID: "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy"})
+	require.Nil(t, err)
+	require.Nil(t, usage.Write(&server.Interaction{UniqueID: "C59E3CRP82KE7BCNEDQ0NOPQRSTUVWXYZ"}))
+	require.Equal(t, []string{"c59e3crp82ke7bcnedq0abcdefghijklm.oast.pro"}, usage.Unused())
+
+	require.Nil(t, usage.Close())
+	data, err := os.ReadFile(report)
+	require.Nil(t, err)
+	require.Equal(t, "c59e3crp82ke7bcnedq0abcdefghijklm.oast.pro\n", string(data))
+}
+
+func TestUsageFirstLatencies(t *testing.T) {
+	usage := NewUsage("")
+	usage.Emit("c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.oast.pro", "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy")
+	usage.Emit("c59e3crp82ke7bcnedq0abcdefghijklm.oast.pro", "c59e3crp82ke7bcnedq0abcdefghijklm")
+
+	for _, latency := range []int64{4000, 1200} {
+		latency := latency
+		require.Nil(t, usage.Write(&server.Interaction{UniqueID: "c59e3crp82ke7bcnedq0abcdefghijklm", PayloadLatency: &latency}))
+	}
+	payloads, latencies := usage.FirstLatencies()
+	require.Equal(t, []string{"c59e3crp82ke7bcnedq0abcdefghijklm.oast.pro"}, payloads)
+	require.Equal(t, []time.Duration{1200 * time.Millisecond}, latencies)
+	require.Nil(t, usage.Close())
+}
@@ -0,0 +1,364 @@
+package output
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/interactsh/pkg/server"
+	"github.com/projectdiscovery/interactsh/pkg/storage"
+	"github.com/projectdiscovery/retryablehttp-go"
+)
+
+// WebhookAttempts is the number of delivery attempts of an event before it is dead-lettered
+var WebhookAttempts = 10
+
+// WebhookBackoff is the delay before the first redelivery, doubled on each
+// attempt up to WebhookMaxBackoff
+var (
+	WebhookBackoff    = time.Second
+	WebhookMaxBackoff = 5 * time.Minute
+)
+
+var (
+	// WebhookQueueSize is the number of events waiting for delivery, the
+	// ones beyond it being dead-lettered
+	WebhookQueueSize = 1000
+	// WebhookWorkers is the number of events delivered at once
+	WebhookWorkers = 2
+)
+
+// Webhook posts each interaction as JSON to an URL
+type Webhook struct {
+	url        string
+	httpClient *retryablehttp.Client
+	wg         sync.WaitGroup
+	done       chan struct{}
+	closeOnce  sync.Once
+
+	// deliveries are the events waiting for the delivery workers
+	deliveries   chan []byte
+	deliveriesMu sync.RWMutex
+	closed       bool
+
+	// deadLetter is the file undeliverable events are appended to
+	deadLetter   string
+	deadLetterMu sync.Mutex
+
+	// limiter rate limits the notifications, the events being queued
+	// meanwhile and posted as digests
+	limiter    *webhookLimiter
+	queueMu    sync.Mutex
+	queue      [][]byte
+	wake       chan struct{}
+	dispatched chan struct{}
+}
+
+// deadLetterEvent is an undeliverable event written to the dead letter file
+type deadLetterEvent struct {
+	Timestamp time.Time           `json:"timestamp"`
+	URL       string              `json:"url"`
+	Error     string              `json:"error"`
+	Event     jsoniter.RawMessage `json:"event"`
+}
+
+// digestEvent is posted instead of the events queued while the notifications
+// were rate limited
+type digestEvent struct {
+	Digest bool                  `json:"digest"`
+	Count  int                   `json:"count"`
+	Events []jsoniter.RawMessage `json:"events"`
+}
+
+// NewWebhook returns a new webhook writer, undeliverable events are appended
+// to the dead letter file if any
+func NewWebhook(url string, httpClient *retryablehttp.Client, deadLetter string) *Webhook {
+	return NewThrottledWebhook(url, httpClient, deadLetter, nil)
+}
+
+// NewThrottledWebhook returns a new webhook writer whose notifications are
+// rate limited and batched by the throttle
+func NewThrottledWebhook(url string, httpClient *retryablehttp.Client, deadLetter string, throttle *Throttle) *Webhook {
+	if httpClient == nil {
+		// redeliveries are handled by the webhook with a longer backoff
+		options := retryablehttp.DefaultOptionsSingle
+		options.RetryMax = 0
+		httpClient = retryablehttp.NewClient(options)
+	}
+	webhook := &Webhook{
+		url:        url,
+		httpClient: httpClient,
+		deadLetter: deadLetter,
+		done:       make(chan struct{}),
+		deliveries: make(chan []byte, WebhookQueueSize),
+		limiter:    throttle.limiter(url),
+	}
+	for i := 0; i < WebhookWorkers; i++ {
+		webhook.wg.Add(1)
+		go webhook.deliveryWorker()
+	}
+	if webhook.limiter != nil {
+		webhook.wake = make(chan struct{}, 1)
+		webhook.dispatched = make(chan struct{})
+		go webhook.dispatch()
+	}
+	return webhook
+}
+
+// Write posts the interaction in background so that slow endpoints don't delay polling
+func (w *Webhook) Write(interaction *server.Interaction) error {
+	data, err := jsoniter.Marshal(interaction)
+	if err != nil {
+		return err
+	}
+	w.Send(data)
+	return nil
+}
+
+// Send posts a json event in background, redelivering it with exponential
+// backoff while the endpoint is unavailable. The events exceeding the queue
+// are dead-lettered.
+func (w *Webhook) Send(data []byte) {
+	data = bytes.TrimSpace(data)
+	if w.limiter != nil {
+		w.queueMu.Lock()
+		full := len(w.queue) >= WebhookQueueSize
+		if !full {
+			w.queue = append(w.queue, data)
+		}
+		w.queueMu.Unlock()
+		if full {
+			w.deadLetterEvent(data, errors.New("webhook queue full"))
+			return
+		}
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+		return
+	}
+	w.enqueue(data)
+}
+
+// enqueue queues an event for the delivery workers, dead-lettering it if
+// the queue is full or the webhook closed
+func (w *Webhook) enqueue(data []byte) {
+	w.deliveriesMu.RLock()
+	defer w.deliveriesMu.RUnlock()
+
+	if w.closed {
+		w.deadLetterEvent(data, errors.New("webhook closed"))
+		return
+	}
+	select {
+	case w.deliveries <- data:
+	default:
+		w.deadLetterEvent(data, errors.New("webhook queue full"))
+	}
+}
+
+// deliveryWorker delivers the queued events one at a time
+func (w *Webhook) deliveryWorker() {
+	defer w.wg.Done()
+	for data := range w.deliveries {
+		w.deliver(data)
+	}
+}
+
+// dispatch posts the queued events as the limits allow, the events queued
+// while waiting for the batch window or a token being posted as a digest
+func (w *Webhook) dispatch() {
+	defer close(w.dispatched)
+	for {
+		select {
+		case <-w.wake:
+		case <-w.done:
+			w.flush()
+			return
+		}
+		if w.limiter.batch > 0 && !w.sleep(w.limiter.batch) {
+			w.flush()
+			return
+		}
+		for w.pending() > 0 {
+			if delay := w.limiter.reserve(time.Now()); delay > 0 {
+				if !w.sleep(delay) {
+					w.flush()
+					return
+				}
+				continue
+			}
+			w.enqueue(w.next())
+		}
+	}
+}
+
+// sleep waits for the delay, returning false if the webhook is closed meanwhile
+func (w *Webhook) sleep(delay time.Duration) bool {
+	select {
+	case <-time.After(delay):
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// flush posts the queued events regardless of the limits when closing
+func (w *Webhook) flush() {
+	for w.pending() > 0 {
+		w.enqueue(w.next())
+	}
+}
+
+func (w *Webhook) pending() int {
+	w.queueMu.Lock()
+	defer w.queueMu.Unlock()
+
+	return len(w.queue)
+}
+
+// next dequeues the next event, or a digest of up to WebhookDigestSize events
+func (w *Webhook) next() []byte {
+	w.queueMu.Lock()
+	count := min(len(w.queue), WebhookDigestSize)
+	events := w.queue[:count:count]
+	w.queue = w.queue[count:]
+	w.queueMu.Unlock()
+
+	if len(events) == 1 {
+		return events[0]
+	}
+	digest := &digestEvent{Digest: true, Count: len(events), Events: make([]jsoniter.RawMessage, 0, len(events))}
+	for _, event := range events {
+		digest.Events = append(digest.Events, event)
+	}
+	data, _ := jsoniter.Marshal(digest)
+	return data
+}
+
+func (w *Webhook) deliver(data []byte) {
+	backoff := WebhookBackoff
+	for attempt := 1; ; attempt++ {
+		// redeliveries are rate limited too, the first attempt being dispatched
+		if attempt > 1 && w.limiter != nil {
+			for delay := w.limiter.reserve(time.Now()); delay > 0; delay = w.limiter.reserve(time.Now()) {
+				if !w.sleep(delay) {
+					w.deadLetterEvent(data, errors.New("webhook closed while rate limited"))
+					return
+				}
+			}
+		}
+		err := w.post(data)
+		if err == nil {
+			return
+		}
+		if attempt >= WebhookAttempts {
+			w.deadLetterEvent(data, err)
+			return
+		}
+		gologger.Verbose().Msgf("Could not notify webhook (attempt %d/%d): %s\n", attempt, WebhookAttempts, err)
+		select {
+		case <-time.After(backoff):
+		case <-w.done:
+			// pending redeliveries are dead-lettered on close
+			w.deadLetterEvent(data, err)
+			return
+		}
+		if backoff *= 2; backoff > WebhookMaxBackoff {
+			backoff = WebhookMaxBackoff
+		}
+	}
+}
+
+func (w *Webhook) post(data []byte) error {
+	req, err := retryablehttp.NewRequest(http.MethodPost, w.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// deadLetterEvent appends an undeliverable event to the dead letter file
+func (w *Webhook) deadLetterEvent(data []byte, deliveryErr error) {
+	if w.deadLetter == "" {
+		gologger.Warning().Msgf("Could not notify webhook: %s\n", deliveryErr)
+		return
+	}
+	line, err := jsoniter.Marshal(&deadLetterEvent{Timestamp: time.Now(), URL: w.url, Error: deliveryErr.Error(), Event: data})
+	if err != nil {
+		return
+	}
+	w.deadLetterMu.Lock()
+	defer w.deadLetterMu.Unlock()
+
+	file, err := os.OpenFile(w.deadLetter, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		gologger.Warning().Msgf("Could not notify webhook: %s (dead letter: %s)\n", deliveryErr, err)
+		return
+	}
+	defer file.Close()
+	_, _ = file.Write(append(line, '\n'))
+	gologger.Warning().Msgf("Could not notify webhook, event written to %s: %s\n", w.deadLetter, deliveryErr)
+}
+
+// Close dead-letters the events waiting for redelivery and waits for the pending notifications
+func (w *Webhook) Close() error {
+	w.closeOnce.Do(func() { close(w.done) })
+	if w.dispatched != nil {
+		<-w.dispatched
+	}
+	w.deliveriesMu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.deliveries)
+	}
+	w.deliveriesMu.Unlock()
+	w.wg.Wait()
+	return nil
+}
+
+// NotifyStorage returns a storage posting every stored interaction to the webhook
+func NotifyStorage(store storage.Storage, webhook *Webhook) storage.Storage {
+	return &notifyStorage{Storage: store, webhook: webhook}
+}
+
+// notifyStorage posts the interactions stored to a webhook
+type notifyStorage struct {
+	storage.Storage
+	webhook *Webhook
+}
+
+// AddInteraction stores the interaction and posts it to the webhook
+func (s *notifyStorage) AddInteraction(correlationID string, data []byte) error {
+	if err := s.Storage.AddInteraction(correlationID, data); err != nil {
+		return err
+	}
+	s.webhook.Send(data)
+	return nil
+}
+
+// AddInteractionWithId stores the interaction and posts it to the webhook
+func (s *notifyStorage) AddInteractionWithId(id string, data []byte) error {
+	if err := s.Storage.AddInteractionWithId(id, data); err != nil {
+		return err
+	}
+	s.webhook.Send(data)
+	return nil
+}
@@ -0,0 +1,58 @@
+package output
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/interactsh/pkg/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookRetry(t *testing.T) {
+	WebhookBackoff = 10 * time.Millisecond
+	defer func() { WebhookBackoff = time.Second }()
+
+	var requests, delivered int32
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the consumer is down for the first attempts
+		if atomic.AddInt32(&requests, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		atomic.AddInt32(&delivered, 1)
+	}))
+	defer endpoint.Close()
+
+	deadLetter := filepath.Join(t.TempDir(), "dead-letter.jsonl")
+	webhook := NewWebhook(endpoint.URL, nil, deadLetter)
+	require.Nil(t, webhook.Write(&server.Interaction{Protocol: "dns"}))
+	for deadline := time.Now().Add(5 * time.Second); atomic.LoadInt32(&delivered) == 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Nil(t, webhook.Close())
+	require.EqualValues(t, 1, atomic.LoadInt32(&delivered))
+	_, err := os.Stat(deadLetter)
+	require.True(t, os.IsNotExist(err))
+
+	// undeliverable events are written to the dead letter file
+	endpoint.Close()
+	webhook = NewWebhook(endpoint.URL, nil, deadLetter)
+	webhook.Send([]byte(`{"protocol":"http"}` + "\n"))
+	require.Nil(t, webhook.Close())
+
+	data, err := os.ReadFile(deadLetter)
+	require.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 1)
+	event := &deadLetterEvent{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(lines[0]), event))
+	require.Equal(t, `{"protocol":"http"}`, string(event.Event))
+	require.NotEmpty(t, event.Error)
+}
@@ -0,0 +1,98 @@
+package secrets
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// EnvKey is the environment variable holding the base64 encoded AES-256
+	// key the encrypted env file values are decrypted with
+	EnvKey = "INTERACTSH_ENV_KEY"
+	// encryptedPrefix marks the encrypted env file values
+	encryptedPrefix = "enc:"
+)
+
+// LoadEnvFile sets the variables of a KEY=VALUE env file in the environment,
+// values prefixed with enc: are decrypted with the key from INTERACTSH_ENV_KEY
+func LoadEnvFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(strings.TrimPrefix(text, "export "), "=")
+		if !ok {
+			return fmt.Errorf("invalid env file line %d", line)
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+		if strings.HasPrefix(value, encryptedPrefix) {
+			if value, err = Decrypt(strings.TrimPrefix(value, encryptedPrefix)); err != nil {
+				return fmt.Errorf("could not decrypt env file line %d: %w", line, err)
+			}
+		}
+		if err := os.Setenv(strings.TrimSpace(name), value); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Encrypt encrypts a value for the env file with the key from INTERACTSH_ENV_KEY
+func Encrypt(plaintext string) (string, error) {
+	gcm, err := envCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a base64 encoded env file value with the key from INTERACTSH_ENV_KEY
+func Decrypt(ciphertext string) (string, error) {
+	gcm, err := envCipher()
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", fmt.Errorf("ciphertext too short")
+	}
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+func envCipher() (cipher.AEAD, error) {
+	key, err := base64.StdEncoding.DecodeString(os.Getenv(EnvKey))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("%s must be a base64 encoded 32 bytes key", EnvKey)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
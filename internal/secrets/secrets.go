@@ -0,0 +1,82 @@
+// Package secrets resolves keys and tokens from external secret stores so
+// that they don't need to be stored in plain text on disk.
+//
+// A secret reference has the form scheme:reference, the supported schemes being
+//
+//	env:NAME                       environment variable
+//	file:/path                     file content, without its trailing newline
+//	vault:mount/data/path#field    HashiCorp Vault KV secret (VAULT_ADDR, VAULT_TOKEN)
+//	aws-sm:secret-id[#field]       AWS Secrets Manager secret
+//	aws-kms:base64-ciphertext      AWS KMS encrypted blob
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Schemes are the supported secret reference schemes
+var Schemes = []string{"env", "file", "vault", "aws-sm", "aws-kms"}
+
+// IsReference reports whether value is a secret reference
+func IsReference(value string) bool {
+	scheme, _, ok := strings.Cut(value, ":")
+	if !ok {
+		return false
+	}
+	for _, supported := range Schemes {
+		if scheme == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns the secret referenced by value, values which are not
+// secret references are returned as is
+func Resolve(value string) (string, error) {
+	if !IsReference(value) {
+		return value, nil
+	}
+	scheme, reference, _ := strings.Cut(value, ":")
+	switch scheme {
+	case "env":
+		secret, ok := os.LookupEnv(reference)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", reference)
+		}
+		return secret, nil
+	case "file":
+		data, err := os.ReadFile(reference)
+		if err != nil {
+			return "", err
+		}
+		// the newline ending the text files isn't part of the secret
+		return strings.TrimRight(string(data), "\r\n"), nil
+	case "vault":
+		return resolveVault(reference)
+	case "aws-sm":
+		return resolveSecretsManager(reference)
+	default:
+		return resolveKMS(reference)
+	}
+}
+
+// field extracts a field of a json object secret
+func field(secret, name string) (string, error) {
+	var fields map[string]interface{}
+	if err := jsoniter.Unmarshal([]byte(secret), &fields); err != nil {
+		return "", fmt.Errorf("secret is not a json object: %w", err)
+	}
+	value, ok := fields[name]
+	if !ok {
+		return "", fmt.Errorf("secret has no field %s", name)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(value), nil
+}
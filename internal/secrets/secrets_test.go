@@ -0,0 +1,90 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolve(t *testing.T) {
+	value, err := Resolve("plain-token")
+	require.Nil(t, err)
+	require.Equal(t, "plain-token", value)
+
+	t.Setenv("INTERACTSH_TEST_TOKEN", "secret")
+	value, err = Resolve("env:INTERACTSH_TEST_TOKEN")
+	require.Nil(t, err)
+	require.Equal(t, "secret", value)
+
+	_, err = Resolve("env:INTERACTSH_TEST_MISSING")
+	require.NotNil(t, err)
+}
+
+func TestResolveFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	require.Nil(t, os.WriteFile(path, []byte("secret\r\n"), 0600))
+	value, err := Resolve("file:" + path)
+	require.Nil(t, err)
+	require.Equal(t, "secret", value)
+
+	_, err = Resolve("file:" + filepath.Join(t.TempDir(), "missing"))
+	require.NotNil(t, err)
+}
+
+func TestEnvFile(t *testing.T) {
+	t.Setenv(EnvKey, base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	encrypted, err := Encrypt("secret-token")
+	require.Nil(t, err)
+
+	path := filepath.Join(t.TempDir(), "server.env")
+	err = os.WriteFile(path, []byte("# interactsh\nINTERACTSH_TEST_PLAIN=plain\nexport INTERACTSH_TEST_ENCRYPTED=\""+encrypted+"\"\n"), 0600)
+	require.Nil(t, err)
+	t.Setenv("INTERACTSH_TEST_PLAIN", "")
+	t.Setenv("INTERACTSH_TEST_ENCRYPTED", "")
+
+	require.Nil(t, LoadEnvFile(path))
+	require.Equal(t, "plain", os.Getenv("INTERACTSH_TEST_PLAIN"))
+	require.Equal(t, "secret-token", os.Getenv("INTERACTSH_TEST_ENCRYPTED"))
+}
+
+func TestVault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "vault-token" || r.URL.Path != "/v1/secret/data/interactsh" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":{"data":{"token":"secret"},"metadata":{"version":1}}}`))
+	}))
+	defer server.Close()
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "vault-token")
+
+	value, err := Resolve("vault:secret/data/interactsh#token")
+	require.Nil(t, err)
+	require.Equal(t, "secret", value)
+}
+
+func TestSecretsManager(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" || !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`{"SecretString":"{\"token\":\"secret\"}"}`))
+	}))
+	defer server.Close()
+	t.Setenv("AWS_ENDPOINT_URL", server.URL)
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	value, err := Resolve("aws-sm:interactsh#token")
+	require.Nil(t, err)
+	require.Equal(t, "secret", value)
+}
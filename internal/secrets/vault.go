@@ -0,0 +1,68 @@
+package secrets
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// resolveVault reads a field of a KV secret through the Vault HTTP API,
+// both KV version 1 and 2 (mount/data/path) secrets are supported
+func resolveVault(reference string) (string, error) {
+	path, name, ok := strings.Cut(reference, "#")
+	if !ok || name == "" {
+		return "", fmt.Errorf("vault reference must specify a field (path#field)")
+	}
+	address := os.Getenv("VAULT_ADDR")
+	if address == "" {
+		return "", fmt.Errorf("VAULT_ADDR is not set")
+	}
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(address, "/")+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", os.Getenv("VAULT_TOKEN"))
+	if namespace := os.Getenv("VAULT_NAMESPACE"); namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var secret struct {
+		Data map[string]jsoniter.RawMessage `json:"data"`
+	}
+	if err := jsoniter.Unmarshal(body, &secret); err != nil {
+		return "", err
+	}
+	data := secret.Data
+	// kv version 2 nests the secret in data.data
+	if nested, ok := data["data"]; ok {
+		var fields map[string]jsoniter.RawMessage
+		if err := jsoniter.Unmarshal(nested, &fields); err == nil {
+			data = fields
+		}
+	}
+	value, ok := data[name]
+	if !ok {
+		return "", fmt.Errorf("vault secret has no field %s", name)
+	}
+	var s string
+	if err := jsoniter.Unmarshal(value, &s); err != nil {
+		return string(value), nil
+	}
+	return s, nil
+}
@@ -0,0 +1,423 @@
+// Package tui implements an interactive terminal view of live interactions
+// following a simple model/update/view loop.
+package tui
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/projectdiscovery/gologger/levels"
+	"github.com/projectdiscovery/interactsh/pkg/server"
+	"golang.org/x/term"
+)
+
+// protocols is the cycle order of the protocol filter
+var protocols = []string{"", "dns", "http", "smtp", "ldap", "ftp", "smb", "responder", "tcp-echo", "udp-echo"}
+
+// Options contains configuration options for the terminal UI
+type Options struct {
+	// Payloads are the generated payloads displayed in the header
+	Payloads []string
+	// MaxItems is the maximum number of interactions kept in memory
+	MaxItems int
+}
+
+// UI is an interactive terminal view of interactions
+type UI struct {
+	options *Options
+
+	mu           sync.Mutex
+	items        []*server.Interaction
+	counts       map[string]int
+	selected     int
+	offset       int
+	detail       bool
+	detailOffset int
+	protocol     int
+	search       string
+	input        *string
+	status       string
+	width        int
+	height       int
+
+	events chan struct{}
+	quit   chan struct{}
+	once   sync.Once
+}
+
+// New returns a new terminal UI
+func New(options *Options) *UI {
+	if options.MaxItems <= 0 {
+		options.MaxItems = 10000
+	}
+	return &UI{
+		options: options,
+		counts:  make(map[string]int),
+		events:  make(chan struct{}, 1),
+		quit:    make(chan struct{}),
+		width:   80,
+		height:  24,
+	}
+}
+
+// Add adds a new interaction to the view
+func (u *UI) Add(interaction *server.Interaction) {
+	u.mu.Lock()
+	u.items = append(u.items, interaction)
+	if len(u.items) > u.options.MaxItems {
+		u.items = u.items[len(u.items)-u.options.MaxItems:]
+	}
+	u.counts[interaction.Protocol]++
+	u.mu.Unlock()
+	u.refresh()
+}
+
+// Write implements gologger writer.Writer so that log messages are shown
+// in the status line instead of corrupting the screen
+func (u *UI) Write(data []byte, level levels.Level) {
+	u.mu.Lock()
+	u.status = strings.TrimSpace(string(data))
+	u.mu.Unlock()
+	u.refresh()
+}
+
+// Done returns a channel closed once the user quits the UI
+func (u *UI) Done() <-chan struct{} {
+	return u.quit
+}
+
+func (u *UI) refresh() {
+	select {
+	case u.events <- struct{}{}:
+	default:
+	}
+}
+
+func (u *UI) close() {
+	u.once.Do(func() { close(u.quit) })
+}
+
+// Run puts the terminal in raw mode and runs the UI until the user quits
+func (u *UI) Run() error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return fmt.Errorf("watch mode requires an interactive terminal")
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = term.Restore(fd, state)
+	}()
+
+	// use the alternate screen and hide the cursor
+	fmt.Fprint(os.Stdout, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(os.Stdout, "\x1b[?25h\x1b[?1049l")
+
+	go u.readKeys(bufio.NewReader(os.Stdin))
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if width, height, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+			u.mu.Lock()
+			u.width, u.height = width, height
+			u.mu.Unlock()
+		}
+		u.mu.Lock()
+		view := u.view()
+		u.mu.Unlock()
+		fmt.Fprint(os.Stdout, view)
+
+		select {
+		case <-u.quit:
+			return nil
+		case <-u.events:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (u *UI) readKeys(reader *bufio.Reader) {
+	for {
+		r, _, err := reader.ReadRune()
+		if err != nil {
+			u.close()
+			return
+		}
+		key := string(r)
+		// decode arrow keys escape sequences
+		if r == '\x1b' && reader.Buffered() >= 2 {
+			seq := make([]byte, 2)
+			_, _ = reader.Read(seq)
+			key = "\x1b" + string(seq)
+		}
+		u.mu.Lock()
+		quit := u.update(key)
+		u.mu.Unlock()
+		if quit {
+			u.close()
+			return
+		}
+		u.refresh()
+	}
+}
+
+const (
+	keyUp    = "\x1b[A"
+	keyDown  = "\x1b[B"
+	keyEsc   = "\x1b"
+	keyEnter = "\r"
+	keyCtrlC = "\x03"
+	keyBack  = "\x7f"
+)
+
+// update applies a key press to the model and reports whether the UI should quit
+func (u *UI) update(key string) bool {
+	if u.input != nil {
+		switch key {
+		case keyEnter:
+			u.search = *u.input
+			u.input = nil
+			u.selected, u.offset = 0, 0
+		case keyEsc, keyCtrlC:
+			u.input = nil
+		case keyBack:
+			if len(*u.input) > 0 {
+				*u.input = (*u.input)[:len(*u.input)-1]
+			}
+		default:
+			if len(key) == 1 && key[0] >= 0x20 {
+				*u.input += key
+			}
+		}
+		return false
+	}
+
+	switch key {
+	case "q", keyCtrlC:
+		return true
+	case keyUp, "k":
+		if u.detail {
+			if u.detailOffset > 0 {
+				u.detailOffset--
+			}
+		} else if u.selected > 0 {
+			u.selected--
+		}
+	case keyDown, "j":
+		if u.detail {
+			u.detailOffset++
+		} else if u.selected < len(u.visible())-1 {
+			u.selected++
+		}
+	case "g":
+		u.selected, u.detailOffset = 0, 0
+	case "G":
+		u.selected = len(u.visible()) - 1
+	case keyEnter:
+		if len(u.visible()) > 0 {
+			u.detail, u.detailOffset = true, 0
+		}
+	case keyEsc, keyBack:
+		u.detail = false
+	case "\t", "p":
+		u.protocol = (u.protocol + 1) % len(protocols)
+		u.selected, u.offset = 0, 0
+	case "/":
+		input := ""
+		u.input = &input
+	case "c":
+		u.search, u.protocol = "", 0
+		u.selected, u.offset = 0, 0
+	}
+	if u.selected < 0 {
+		u.selected = 0
+	}
+	return false
+}
+
+// visible returns the interactions matching the current filters
+func (u *UI) visible() []*server.Interaction {
+	var result []*server.Interaction
+	for _, item := range u.items {
+		if protocols[u.protocol] != "" && item.Protocol != protocols[u.protocol] {
+			continue
+		}
+		if u.search != "" && !strings.Contains(strings.ToLower(item.FullId+item.RemoteAddress+item.RawRequest), strings.ToLower(u.search)) {
+			continue
+		}
+		result = append(result, item)
+	}
+	return result
+}
+
+// view renders the full screen for the current model
+func (u *UI) view() string {
+	var lines []string
+
+	var total int
+	var counts []string
+	for protocol, count := range u.counts {
+		total += count
+		counts = append(counts, fmt.Sprintf("%s: %d", protocol, count))
+	}
+	sort.Strings(counts)
+	lines = append(lines, reverse(fmt.Sprintf(" interactsh watch | total: %d | %s", total, strings.Join(counts, " "))))
+	for _, payload := range u.options.Payloads {
+		lines = append(lines, " "+payload)
+	}
+	filter := "all"
+	if protocols[u.protocol] != "" {
+		filter = protocols[u.protocol]
+	}
+	lines = append(lines, fmt.Sprintf(" protocol: %s | search: %q", filter, u.search), "")
+
+	items := u.visible()
+	if u.selected >= len(items) {
+		u.selected = len(items) - 1
+	}
+	body := u.height - len(lines) - 2
+	if body < 1 {
+		body = 1
+	}
+
+	if u.detail && u.selected >= 0 {
+		detail := strings.Split(formatDetail(items[u.selected]), "\n")
+		if u.detailOffset > len(detail)-1 {
+			u.detailOffset = len(detail) - 1
+		}
+		lines = append(lines, window(detail, u.detailOffset, body)...)
+	} else {
+		if u.selected < u.offset {
+			u.offset = u.selected
+		}
+		if u.selected >= u.offset+body {
+			u.offset = u.selected - body + 1
+		}
+		if u.offset < 0 {
+			u.offset = 0
+		}
+		var rows []string
+		for i, item := range items {
+			row := formatRow(item)
+			if i == u.selected {
+				row = reverse(row)
+			}
+			rows = append(rows, row)
+		}
+		lines = append(lines, window(rows, u.offset, body)...)
+	}
+	for len(lines) < u.height-1 {
+		lines = append(lines, "")
+	}
+
+	footer := " q quit | j/k move | enter detail | esc back | tab protocol | / search | c clear"
+	if u.input != nil {
+		footer = " search: " + *u.input + "_"
+	} else if u.status != "" {
+		footer = " " + u.status
+	}
+	lines = append(lines, footer)
+
+	var builder strings.Builder
+	builder.WriteString("\x1b[H")
+	for i, line := range lines {
+		builder.WriteString(truncate(line, u.width))
+		builder.WriteString("\x1b[K")
+		if i < len(lines)-1 {
+			builder.WriteString("\r\n")
+		}
+	}
+	return builder.String()
+}
+
+func formatRow(interaction *server.Interaction) string {
+	return sanitize(fmt.Sprintf(" %s %-9s %s from %s", interaction.Timestamp.Format("15:04:05"), strings.ToUpper(interaction.Protocol), interaction.FullId, interaction.RemoteAddress))
+}
+
+func formatDetail(interaction *server.Interaction) string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf(" Protocol: %s\n Full ID: %s\n Remote Address: %s\n Timestamp: %s\n", interaction.Protocol, interaction.FullId, interaction.RemoteAddress, interaction.Timestamp.Format("2006-01-02 15:04:05")))
+	if interaction.QType != "" {
+		builder.WriteString(fmt.Sprintf(" Query Type: %s\n", interaction.QType))
+	}
+	if interaction.EmbeddedIPv4 != "" {
+		builder.WriteString(fmt.Sprintf(" NAT64: %s via %s\n", interaction.EmbeddedIPv4, interaction.NAT64Prefix))
+	}
+	if skew, ok := interaction.ClockSkew(); ok {
+		builder.WriteString(fmt.Sprintf(" Client Timestamp: %s (skew %s)\n", interaction.ClientTimestamp.Format("2006-01-02 15:04:05"), skew.Round(time.Second)))
+	}
+	if interaction.SMTPFrom != "" {
+		builder.WriteString(fmt.Sprintf(" SMTP From: %s\n", interaction.SMTPFrom))
+	}
+	builder.WriteString("\n-------\nRequest\n-------\n\n")
+	builder.WriteString(interaction.RawRequest)
+	if interaction.RawResponse != "" {
+		builder.WriteString("\n\n--------\nResponse\n--------\n\n")
+		builder.WriteString(interaction.RawResponse)
+	}
+	return sanitize(builder.String())
+}
+
+// sanitize strips control characters from remote controlled data
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func window(lines []string, offset, size int) []string {
+	if offset > len(lines) {
+		offset = len(lines)
+	}
+	end := offset + size
+	if end > len(lines) {
+		end = len(lines)
+	}
+	return lines[offset:end]
+}
+
+func reverse(s string) string {
+	return "\x1b[7m" + s + "\x1b[0m"
+}
+
+// truncate cuts a line to the terminal width ignoring escape sequences
+func truncate(s string, width int) string {
+	var builder strings.Builder
+	var visible int
+	inEscape := false
+	for _, r := range s {
+		switch {
+		case r == '\x1b':
+			inEscape = true
+		case inEscape:
+			if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+				inEscape = false
+			}
+		case r == '\t':
+			r = ' '
+			fallthrough
+		default:
+			if visible >= width {
+				continue
+			}
+			visible++
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
@@ -0,0 +1,34 @@
+package tui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/projectdiscovery/interactsh/pkg/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUIFilters(t *testing.T) {
+	ui := New(&Options{})
+	ui.Add(&server.Interaction{Protocol: "dns", FullId: "first", Timestamp: time.Now()})
+	ui.Add(&server.Interaction{Protocol: "http", FullId: "second", Timestamp: time.Now()})
+	ui.Add(&server.Interaction{Protocol: "http", FullId: "third", Timestamp: time.Now()})
+	require.Len(t, ui.visible(), 3, "could not get all interactions")
+
+	// cycle the protocol filter to http
+	ui.update("\t")
+	ui.update("\t")
+	require.Len(t, ui.visible(), 2, "could not filter by protocol")
+
+	// search inside the filtered interactions
+	for _, key := range []string{"/", "t", "h", "i", keyEnter} {
+		require.False(t, ui.update(key))
+	}
+	items := ui.visible()
+	require.Len(t, items, 1, "could not filter by search")
+	require.Equal(t, "third", items[0].FullId, "could not get correct interaction")
+
+	ui.update("c")
+	require.Len(t, ui.visible(), 3, "could not clear filters")
+	require.True(t, ui.update("q"), "could not quit")
+}
@@ -3,6 +3,7 @@ package client
 import (
 	"bytes"
 	"context"
+	"crypto"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
@@ -68,6 +69,9 @@ type Client struct {
 	token                    string
 	correlationIdLength      int
 	CorrelationIdNonceLength int
+	correlationIdChecksum    bool
+	emissions                payloadEmissions
+	onPayload                func(payload, uniqueID string)
 }
 
 // Options contains configuration options for interactsh client
@@ -82,12 +86,18 @@ type Options struct {
 	CorrelationIdLength int
 	// CorrelationIdNonceLengthLength of the nonce
 	CorrelationIdNonceLength int
+	// CorrelationIdChecksum ends the nonce with the checksum of the unique id,
+	// required by servers validating checksums
+	CorrelationIdChecksum bool
 	// HTTPClient use a custom http client
 	HTTPClient *retryablehttp.Client
 	// SessionInfo to resume an existing session
 	SessionInfo *options.SessionInfo
 	// keepAliveInterval to renew the session
 	KeepAliveInterval time.Duration
+	// OnPayload is called with each payload generated by the client, from
+	// URL or a payload factory, and its unique id
+	OnPayload func(payload, uniqueID string)
 }
 
 // DefaultOptions is the default options for the interact client
@@ -159,6 +169,8 @@ func New(options *Options) (*Client, error) {
 		disableHTTPFallback:      options.DisableHTTPFallback,
 		correlationIdLength:      options.CorrelationIdLength,
 		CorrelationIdNonceLength: options.CorrelationIdNonceLength,
+		correlationIdChecksum:    options.CorrelationIdChecksum,
+		onPayload:                options.OnPayload,
 	}
 
 	if options.SessionInfo != nil {
@@ -179,8 +191,11 @@ func New(options *Options) (*Client, error) {
 		if err != nil {
 			return nil, err
 		}
-		// silently fails to re-register if the session is still alive
-		_ = client.performRegistration(options.SessionInfo.ServerURL, registrationRequest)
+		// silently fails to re-register if the session is still alive, but not if
+		// the correlation id was claimed by another client in the meantime
+		if err := client.performRegistration(options.SessionInfo.ServerURL, registrationRequest); errors.Is(err, storage.ErrCorrelationIdConflict) {
+			return nil, fmt.Errorf("could not resume session %s, it is registered on the server with a different key (the session file may belong to another client or be stale, remove it to start a new session): %w", options.SessionInfo.CorrelationID, err)
+		}
 	} else {
 		payload, err := client.initializeRSAKeys()
 		if err != nil {
@@ -215,7 +230,9 @@ func New(options *Options) (*Client, error) {
 						return
 					}
 					// silently fails to re-register if the session is still alive
-					_ = client.performRegistration(client.serverURL.String(), registrationRequest)
+					if err := client.performRegistration(client.serverURL.String(), registrationRequest); errors.Is(err, storage.ErrCorrelationIdConflict) {
+						gologger.Warning().Msgf("The correlation id %s was registered by another client, interactions are no longer received\n", client.correlationID)
+					}
 				case <-client.quitKeepAliveChan:
 					ticker.Stop()
 					return
@@ -456,6 +473,7 @@ func (c *Client) getInteractions(callback InteractionCallback) error {
 			gologger.Error().Msgf("Could not unmarshal interaction data interaction: %v\n", err)
 			continue
 		}
+		c.emissions.apply(interaction)
 		callback(interaction)
 	}
 
@@ -465,6 +483,7 @@ func (c *Client) getInteractions(callback InteractionCallback) error {
 			gologger.Error().Msgf("Could not unmarshal interaction data interaction: %v\n", err)
 			continue
 		}
+		c.emissions.apply(interaction)
 		callback(interaction)
 	}
 
@@ -578,6 +597,379 @@ func (c *Client) Close() error {
 	return nil
 }
 
+// Share grants read access on the current session to a teammate identified
+// by its base64 encoded public key. The returned session info carries no
+// private key material and can be handed to the teammate, who completes it
+// with its own private key to poll the same correlation ID.
+func (c *Client) Share(publicKey string) (*options.SessionInfo, error) {
+	if c.State.Load() == Closed {
+		return nil, errors.New("client is closed")
+	}
+	if _, err := decodePublicKey(publicKey); err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not decode teammate public key")
+	}
+	readerSecretKey := uuid.New().String()
+
+	share := server.ShareRequest{
+		CorrelationID:   c.correlationID,
+		SecretKey:       c.secretKey,
+		ReaderSecretKey: readerSecretKey,
+		ReaderPublicKey: publicKey,
+	}
+	data, err := jsoniter.Marshal(share)
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not marshal share request")
+	}
+	URL := c.serverURL.String() + "/share"
+	req, err := retryablehttp.NewRequest("POST", URL, bytes.NewReader(data))
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not create new request")
+	}
+	req.ContentLength = int64(len(data))
+
+	if c.token != "" {
+		req.Header.Add("Authorization", c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	defer func() {
+		if resp != nil && resp.Body != nil {
+			_ = resp.Body.Close()
+			_, _ = io.Copy(io.Discard, resp.Body)
+		}
+	}()
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not make share request")
+	}
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("could not share session: %s", string(data))
+	}
+
+	return &options.SessionInfo{
+		ServerURL:     c.serverURL.String(),
+		Token:         c.token,
+		CorrelationID: c.correlationID,
+		SecretKey:     readerSecretKey,
+		PublicKey:     publicKey,
+	}, nil
+}
+
+// SetCNAME makes the names of the session resolve to the external host target
+// through a CNAME record, the lookups still being recorded as interactions.
+// An empty target removes the record.
+func (c *Client) SetCNAME(target string) error {
+	if c.State.Load() == Closed {
+		return errors.New("client is closed")
+	}
+	cname := server.CNAMERequest{
+		CorrelationID: c.correlationID,
+		SecretKey:     c.secretKey,
+		Target:        target,
+	}
+	data, err := jsoniter.Marshal(cname)
+	if err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not marshal cname request")
+	}
+	URL := c.serverURL.String() + "/cname"
+	req, err := retryablehttp.NewRequest("POST", URL, bytes.NewReader(data))
+	if err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not create new request")
+	}
+	req.ContentLength = int64(len(data))
+
+	if c.token != "" {
+		req.Header.Add("Authorization", c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	defer func() {
+		if resp != nil && resp.Body != nil {
+			_ = resp.Body.Close()
+			_, _ = io.Copy(io.Discard, resp.Body)
+		}
+	}()
+	if err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not make cname request")
+	}
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("could not set cname: %s", string(data))
+	}
+	return nil
+}
+
+// ReadHTTPTemplate reads a yaml http template, whose body can be read from
+// the file given as body-file
+func ReadHTTPTemplate(path string) (*storage.HTTPTemplate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config struct {
+		storage.HTTPTemplate `yaml:",inline"`
+		BodyFile             string `yaml:"body-file"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("could not decode http template: %w", err)
+	}
+	if config.BodyFile != "" {
+		body, err := os.ReadFile(config.BodyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read body of http template: %w", err)
+		}
+		config.Body = string(body)
+	}
+	return &config.HTTPTemplate, nil
+}
+
+// SetHTTPTemplate makes the http requests to the payloads of the session be
+// answered with the template, signed by the private key of the session, eg.
+// for stored-xss or ssrf content tests. A nil template restores the default
+// responses.
+func (c *Client) SetHTTPTemplate(template *storage.HTTPTemplate) error {
+	if c.State.Load() == Closed {
+		return errors.New("client is closed")
+	}
+	publicKey, err := encodePublicKey(c.pubKey)
+	if err != nil {
+		return err
+	}
+	request := server.HTTPTemplateRequest{
+		CorrelationID: c.correlationID,
+		SecretKey:     c.secretKey,
+		PublicKey:     publicKey,
+		Template:      template,
+	}
+	if template != nil {
+		digest := sha256.Sum256(template.SignedData())
+		request.Signature, err = rsa.SignPSS(rand.Reader, c.privKey, crypto.SHA256, digest[:], nil)
+		if err != nil {
+			return errorutil.NewWithErr(err).Msgf("could not sign http template")
+		}
+	}
+	data, err := jsoniter.Marshal(request)
+	if err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not marshal http template request")
+	}
+	URL := c.serverURL.String() + "/http-template"
+	req, err := retryablehttp.NewRequest("POST", URL, bytes.NewReader(data))
+	if err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not create new request")
+	}
+	req.ContentLength = int64(len(data))
+
+	if c.token != "" {
+		req.Header.Add("Authorization", c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	defer func() {
+		if resp != nil && resp.Body != nil {
+			_ = resp.Body.Close()
+			_, _ = io.Copy(io.Discard, resp.Body)
+		}
+	}()
+	if err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not make http template request")
+	}
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("could not set http template: %s", string(data))
+	}
+	return nil
+}
+
+// SetPayloadFile hosts a file of the session, eg. a javascript for blind xss
+// or a dtd for xxe, and returns the url it is served at. The file expires
+// with the session, empty data removes it.
+func (c *Client) SetPayloadFile(name, contentType string, data []byte) (string, error) {
+	if c.State.Load() == Closed {
+		return "", errors.New("client is closed")
+	}
+	file := server.PayloadFileRequest{
+		CorrelationID: c.correlationID,
+		SecretKey:     c.secretKey,
+		Name:          name,
+		ContentType:   contentType,
+		Data:          data,
+	}
+	payload, err := jsoniter.Marshal(file)
+	if err != nil {
+		return "", errorutil.NewWithErr(err).Msgf("could not marshal payload file request")
+	}
+	URL := c.serverURL.String() + "/payload-file"
+	req, err := retryablehttp.NewRequest("POST", URL, bytes.NewReader(payload))
+	if err != nil {
+		return "", errorutil.NewWithErr(err).Msgf("could not create new request")
+	}
+	req.ContentLength = int64(len(payload))
+
+	if c.token != "" {
+		req.Header.Add("Authorization", c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	defer func() {
+		if resp != nil && resp.Body != nil {
+			_ = resp.Body.Close()
+			_, _ = io.Copy(io.Discard, resp.Body)
+		}
+	}()
+	if err != nil {
+		return "", errorutil.NewWithErr(err).Msgf("could not make payload file request")
+	}
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("could not set payload file: %s", string(data))
+	}
+	return fmt.Sprintf("%s://%s/%s/%s", c.serverURL.Scheme, c.serverURL.Host, c.correlationID, name), nil
+}
+
+// Body returns the body of an interaction stored on disk by the server, the
+// id being its body-sha256
+func (c *Client) Body(id string) (io.ReadCloser, error) {
+	if c.State.Load() == Closed {
+		return nil, errors.New("client is closed")
+	}
+	request := server.BodyRequest{CorrelationID: c.correlationID, SecretKey: c.secretKey, ID: id}
+	payload, err := jsoniter.Marshal(request)
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not marshal body request")
+	}
+	URL := c.serverURL.String() + "/body"
+	req, err := retryablehttp.NewRequest("POST", URL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not create new request")
+	}
+	req.ContentLength = int64(len(payload))
+
+	if c.token != "" {
+		req.Header.Add("Authorization", c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not make body request")
+	}
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("could not get body: %s", string(data))
+	}
+	return resp.Body, nil
+}
+
+// SetDNSScript makes the lookups of the names of the session with the label
+// before their unique id (eg. rebind1.<id>.domain) answer the addresses in
+// turn, one per lookup and the last one repeated, for dns rebinding tests.
+// Empty answers remove the script.
+func (c *Client) SetDNSScript(label string, answers []string) error {
+	if c.State.Load() == Closed {
+		return errors.New("client is closed")
+	}
+	script := server.DNSScriptRequest{
+		CorrelationID: c.correlationID,
+		SecretKey:     c.secretKey,
+		Label:         label,
+		Answers:       answers,
+	}
+	data, err := jsoniter.Marshal(script)
+	if err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not marshal dns script request")
+	}
+	URL := c.serverURL.String() + "/dns-script"
+	req, err := retryablehttp.NewRequest("POST", URL, bytes.NewReader(data))
+	if err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not create new request")
+	}
+	req.ContentLength = int64(len(data))
+
+	if c.token != "" {
+		req.Header.Add("Authorization", c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	defer func() {
+		if resp != nil && resp.Body != nil {
+			_ = resp.Body.Close()
+			_, _ = io.Copy(io.Discard, resp.Body)
+		}
+	}()
+	if err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not make dns script request")
+	}
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("could not set dns script: %s", string(data))
+	}
+	return nil
+}
+
+// Inspect enables the inspect page of the session, showing its recent http
+// interactions in a browser to the holders of the returned url. Each call
+// returns a new url, revoking the previous one.
+func (c *Client) Inspect() (string, error) {
+	if c.State.Load() == Closed {
+		return "", errors.New("client is closed")
+	}
+	inspect := server.InspectRequest{
+		CorrelationID: c.correlationID,
+		SecretKey:     c.secretKey,
+	}
+	data, err := jsoniter.Marshal(inspect)
+	if err != nil {
+		return "", errorutil.NewWithErr(err).Msgf("could not marshal inspect request")
+	}
+	URL := c.serverURL.String() + "/inspect"
+	req, err := retryablehttp.NewRequest("POST", URL, bytes.NewReader(data))
+	if err != nil {
+		return "", errorutil.NewWithErr(err).Msgf("could not create new request")
+	}
+	req.ContentLength = int64(len(data))
+
+	if c.token != "" {
+		req.Header.Add("Authorization", c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	defer func() {
+		if resp != nil && resp.Body != nil {
+			_ = resp.Body.Close()
+			_, _ = io.Copy(io.Discard, resp.Body)
+		}
+	}()
+	if err != nil {
+		return "", errorutil.NewWithErr(err).Msgf("could not make inspect request")
+	}
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("could not enable inspect page: %s", string(data))
+	}
+	response := &server.InspectResponse{}
+	if err := jsoniter.NewDecoder(resp.Body).Decode(response); err != nil {
+		return "", errorutil.NewWithErr(err).Msgf("could not decode inspect response")
+	}
+	return response.URL, nil
+}
+
+// GenerateShareKeys generates a new RSA key pair for a teammate willing
+// to join a shared session. The returned session info only holds the keys.
+func GenerateShareKeys() (*options.SessionInfo, error) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not generate rsa private key")
+	}
+	publicKeyData, err := encodePublicKey(&priv.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &options.SessionInfo{
+		PrivateKey: string(x509.MarshalPKCS1PrivateKey(priv)),
+		PublicKey:  publicKeyData,
+	}, nil
+}
+
 // performRegistration registers the current client with the master server using the
 // provided RSA Public Key as well as Correlation Key.
 func (c *Client) performRegistration(serverURL string, payload []byte) error {
@@ -608,6 +1000,9 @@ func (c *Client) performRegistration(serverURL string, payload []byte) error {
 	if resp.StatusCode == http.StatusUnauthorized {
 		return errors.New("invalid token provided for interactsh server")
 	}
+	if resp.StatusCode == http.StatusConflict {
+		return storage.ErrCorrelationIdConflict
+	}
 	if resp.StatusCode != http.StatusOK {
 		data, _ := io.ReadAll(resp.Body)
 		return fmt.Errorf("could not register to server: %s", string(data))
@@ -634,23 +1029,53 @@ func (c *Client) URL() string {
 	if c.State.Load() == Closed {
 		return ""
 	}
-	data := make([]byte, c.CorrelationIdNonceLength)
-	_, _ = rand.Read(data)
-	randomData := zbase32.StdEncoding.EncodeToString(data)
-	if len(randomData) > c.CorrelationIdNonceLength {
-		randomData = randomData[:c.CorrelationIdNonceLength]
-	}
+	uniqueID, _ := c.newUniqueID("")
 
 	builder := &strings.Builder{}
-	builder.Grow(len(c.correlationID) + len(randomData) + len(c.serverURL.Host) + 1)
-	builder.WriteString(c.correlationID)
-	builder.WriteString(randomData)
+	builder.Grow(len(uniqueID) + len(c.serverURL.Host) + 1)
+	builder.WriteString(uniqueID)
 	builder.WriteString(".")
 	builder.WriteString(c.serverURL.Host)
 	URL := builder.String()
+	c.emitted(URL, uniqueID)
 	return URL
 }
 
+// emitted reports a generated payload to the OnPayload callback
+func (c *Client) emitted(payload, uniqueID string) {
+	if c.onPayload != nil {
+		c.onPayload(payload, uniqueID)
+	}
+}
+
+// maxUniqueIDAttempts is the number of nonces drawn for a unique id not emitted before
+const maxUniqueIDAttempts = 16
+
+// newUniqueID returns a unique id of the correlation id with a new nonce and
+// records its emission for the namespace, false if every drawn unique id was
+// already emitted (eg. without nonce)
+func (c *Client) newUniqueID(namespace string) (string, bool) {
+	var uniqueID string
+	for attempt := 0; attempt < maxUniqueIDAttempts; attempt++ {
+		data := make([]byte, c.CorrelationIdNonceLength)
+		_, _ = rand.Read(data)
+		randomData := zbase32.StdEncoding.EncodeToString(data)
+		if len(randomData) > c.CorrelationIdNonceLength {
+			randomData = randomData[:c.CorrelationIdNonceLength]
+		}
+		// the last nonce character is replaced with the checksum of the unique id
+		if c.correlationIdChecksum && len(randomData) > 0 {
+			randomData = randomData[:len(randomData)-1]
+			randomData += string(server.CorrelationIDChecksum(c.correlationID + randomData))
+		}
+		uniqueID = strings.ToLower(c.correlationID + randomData)
+		if c.emissions.emit(uniqueID, namespace, time.Now()) {
+			return uniqueID, true
+		}
+	}
+	return uniqueID, false
+}
+
 // decryptMessage decrypts an AES-256-RSA-OAEP encrypted message to string
 func (c *Client) decryptMessage(key string, secureMessage string) ([]byte, error) {
 	decodedKey, err := base64.StdEncoding.DecodeString(key)
@@ -690,10 +1115,19 @@ func (c *Client) decryptMessage(key string, secureMessage string) ([]byte, error
 }
 
 func (c *Client) SaveSessionTo(filename string) error {
+	data, err := c.marshalSession()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, os.ModePerm)
+}
+
+// marshalSession returns the session info of the client, keys included
+func (c *Client) marshalSession() ([]byte, error) {
 	privateKeyData := x509.MarshalPKCS1PrivateKey(c.privKey)
 	publicKeyData, err := encodePublicKey(c.pubKey)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	sessionInfo := &options.SessionInfo{
 		ServerURL:     c.serverURL.String(),
@@ -703,9 +1137,5 @@ func (c *Client) SaveSessionTo(filename string) error {
 		SecretKey:     c.secretKey,
 		PublicKey:     publicKeyData,
 	}
-	data, err := yaml.Marshal(sessionInfo)
-	if err != nil {
-		return err
-	}
-	return os.WriteFile(filename, data, os.ModePerm)
+	return yaml.Marshal(sessionInfo)
 }
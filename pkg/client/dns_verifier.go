@@ -0,0 +1,159 @@
+package client
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/projectdiscovery/interactsh/pkg/server"
+)
+
+// DNSResolutionRetention is the time the resolution of a payload is
+// remembered for after it was last polled, as the later requests for the
+// payload are answered from the resolver caches without a new resolution
+var DNSResolutionRetention = 24 * time.Hour
+
+// DNSVerifier flags http and smtp interactions which were not preceded by a
+// dns resolution of the same payload, typical of scanners connecting to the
+// server ip directly while spoofing the Host header.
+//
+// As the dns interaction may be polled after the one depending on it, the
+// verdict is delayed up to the grace period before the interaction is emitted.
+type DNSVerifier struct {
+	grace    time.Duration
+	callback InteractionCallback
+
+	mu       sync.Mutex
+	resolved map[string]resolution
+	pending  []*pendingInteraction
+	quit     chan struct{}
+	once     sync.Once
+}
+
+type pendingInteraction struct {
+	interaction *server.Interaction
+	deadline    time.Time
+}
+
+// resolution is the first dns resolution of a payload, remembered for the
+// retention after the last one was polled
+type resolution struct {
+	timestamp time.Time
+	deadline  time.Time
+}
+
+// NewDNSVerifier returns a verifier emitting the interactions to callback
+func NewDNSVerifier(grace time.Duration, callback InteractionCallback) *DNSVerifier {
+	v := &DNSVerifier{
+		grace:    grace,
+		callback: callback,
+		resolved: make(map[string]resolution),
+		quit:     make(chan struct{}),
+	}
+	go v.expire()
+	return v
+}
+
+// Verify processes an interaction, the callback is invoked once the verdict
+// is known, outside of the verifier lock
+func (v *DNSVerifier) Verify(interaction *server.Interaction) {
+	v.emit(v.verify(interaction))
+}
+
+// verify returns the interactions whose verdict is known after processing an interaction
+func (v *DNSVerifier) verify(interaction *server.Interaction) []*server.Interaction {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	id := strings.ToLower(interaction.UniqueID)
+	switch interaction.Protocol {
+	case "dns":
+		first, ok := v.resolved[id]
+		if !ok || interaction.Timestamp.Before(first.timestamp) {
+			first.timestamp = interaction.Timestamp
+		}
+		first.deadline = time.Now().Add(DNSResolutionRetention)
+		v.resolved[id] = first
+		ready := []*server.Interaction{interaction}
+
+		// release the pending interactions the resolution precedes
+		pending := v.pending[:0]
+		for _, item := range v.pending {
+			if strings.EqualFold(item.interaction.UniqueID, id) && !interaction.Timestamp.After(item.interaction.Timestamp) {
+				ready = append(ready, withVerdict(item.interaction, true))
+				continue
+			}
+			pending = append(pending, item)
+		}
+		v.pending = pending
+		return ready
+	case "http", "smtp":
+		if first, ok := v.resolved[id]; ok && !first.timestamp.After(interaction.Timestamp) {
+			return []*server.Interaction{withVerdict(interaction, true)}
+		}
+		v.pending = append(v.pending, &pendingInteraction{interaction: interaction, deadline: time.Now().Add(v.grace)})
+		return nil
+	default:
+		return []*server.Interaction{interaction}
+	}
+}
+
+// Close emits the pending interactions as unverified
+func (v *DNSVerifier) Close() error {
+	v.once.Do(func() { close(v.quit) })
+	v.mu.Lock()
+	ready := make([]*server.Interaction, 0, len(v.pending))
+	for _, item := range v.pending {
+		ready = append(ready, withVerdict(item.interaction, false))
+	}
+	v.pending = nil
+	v.mu.Unlock()
+
+	v.emit(ready)
+	return nil
+}
+
+func (v *DNSVerifier) emit(interactions []*server.Interaction) {
+	for _, interaction := range interactions {
+		v.callback(interaction)
+	}
+}
+
+// withVerdict sets whether the interaction was preceded by a dns resolution
+func withVerdict(interaction *server.Interaction, verified bool) *server.Interaction {
+	interaction.DNSVerified = &verified
+	return interaction
+}
+
+// expire emits the interactions pending for longer than the grace period as
+// unverified and forgets the resolutions not polled within the retention
+func (v *DNSVerifier) expire() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-v.quit:
+			return
+		case now := <-ticker.C:
+			v.mu.Lock()
+			var ready []*server.Interaction
+			pending := v.pending[:0]
+			for _, item := range v.pending {
+				if now.After(item.deadline) {
+					ready = append(ready, withVerdict(item.interaction, false))
+					continue
+				}
+				pending = append(pending, item)
+			}
+			v.pending = pending
+			for id, resolved := range v.resolved {
+				if now.After(resolved.deadline) {
+					delete(v.resolved, id)
+				}
+			}
+			v.mu.Unlock()
+
+			v.emit(ready)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/projectdiscovery/interactsh/pkg/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDNSVerifier(t *testing.T) {
+	var emitted []*server.Interaction
+	verifier := NewDNSVerifier(time.Hour, func(interaction *server.Interaction) {
+		emitted = append(emitted, interaction)
+	})
+	now := time.Now()
+
+	// resolution polled after the http interaction it precedes
+	verifier.Verify(&server.Interaction{Protocol: "http", UniqueID: "first", Timestamp: now})
+	require.Empty(t, emitted)
+	verifier.Verify(&server.Interaction{Protocol: "dns", UniqueID: "first", Timestamp: now.Add(-time.Second)})
+	require.Len(t, emitted, 2)
+	require.True(t, *emitted[1].DNSVerified)
+
+	// resolution already known
+	verifier.Verify(&server.Interaction{Protocol: "smtp", UniqueID: "first", Timestamp: now.Add(time.Second)})
+	require.Len(t, emitted, 3)
+	require.True(t, *emitted[2].DNSVerified)
+
+	// direct connection without resolution
+	verifier.Verify(&server.Interaction{Protocol: "http", UniqueID: "second", Timestamp: now})
+	require.Len(t, emitted, 3)
+	require.Nil(t, verifier.Close())
+	require.Len(t, emitted, 4)
+	require.False(t, *emitted[3].DNSVerified)
+}
+
+func TestDNSVerifierExpiry(t *testing.T) {
+	var verifier *DNSVerifier
+	emitted := make(chan *server.Interaction, 10)
+	verifier = NewDNSVerifier(10*time.Millisecond, func(interaction *server.Interaction) {
+		// the callback may use the verifier as it runs without its lock
+		verifier.mu.Lock()
+		verifier.mu.Unlock()
+		emitted <- interaction
+	})
+	defer verifier.Close()
+	now := time.Now()
+
+	verifier.Verify(&server.Interaction{Protocol: "dns", UniqueID: "first", Timestamp: now})
+	verifier.Verify(&server.Interaction{Protocol: "http", UniqueID: "second", Timestamp: now})
+	<-emitted
+	select {
+	case interaction := <-emitted:
+		require.False(t, *interaction.DNSVerified)
+	case <-time.After(5 * time.Second):
+		t.Fatal("pending interaction not expired")
+	}
+
+	// the resolution outlives the grace period, as later requests are
+	// answered from the resolver caches
+	verifier.Verify(&server.Interaction{Protocol: "http", UniqueID: "first", Timestamp: now.Add(time.Minute)})
+	select {
+	case interaction := <-emitted:
+		require.True(t, *interaction.DNSVerified)
+	case <-time.After(time.Second):
+		t.Fatal("interaction after the grace period not emitted")
+	}
+
+	// resolutions are forgotten after the retention
+	defer func(retention time.Duration) { DNSResolutionRetention = retention }(DNSResolutionRetention)
+	DNSResolutionRetention = 10 * time.Millisecond
+	verifier.Verify(&server.Interaction{Protocol: "dns", UniqueID: "first", Timestamp: now})
+	<-emitted
+	time.Sleep(1500 * time.Millisecond)
+	verifier.mu.Lock()
+	require.Empty(t, verifier.resolved)
+	verifier.mu.Unlock()
+}
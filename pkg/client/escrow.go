@@ -0,0 +1,153 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/projectdiscovery/interactsh/pkg/options"
+	"github.com/projectdiscovery/retryablehttp-go"
+	errorutil "github.com/projectdiscovery/utils/errors"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	// the default hash of the keys without hash preferences
+	_ "golang.org/x/crypto/ripemd160"
+	"gopkg.in/yaml.v3"
+)
+
+// escrowContentType is the content type of the sessions sent to the keyserver
+const escrowContentType = "application/pgp-encrypted"
+
+// ReadPGPKeys reads the armored pgp keys (public or private) of the files
+func ReadPGPKeys(paths ...string) (openpgp.EntityList, error) {
+	var keyring openpgp.EntityList
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		entities, err := openpgp.ReadArmoredKeyRing(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("could not read pgp keys of %s: %w", path, err)
+		}
+		keyring = append(keyring, entities...)
+	}
+	return keyring, nil
+}
+
+// EscrowSession encrypts the session, keys included, for the pgp public keys
+// of the authorized teammates and registers it on the team keyserver with a
+// PUT request on the correlation id, so that the session can be recovered if
+// this client is lost. The keyserver only ever stores encrypted sessions.
+func (c *Client) EscrowSession(keyserverURL string, recipients openpgp.EntityList) error {
+	if len(recipients) == 0 {
+		return errors.New("no escrow recipient")
+	}
+	data, err := c.marshalSession()
+	if err != nil {
+		return err
+	}
+	encrypted := &bytes.Buffer{}
+	armored, err := armor.Encode(encrypted, "PGP MESSAGE", nil)
+	if err != nil {
+		return err
+	}
+	plaintext, err := openpgp.Encrypt(armored, recipients, nil, nil, nil)
+	if err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not encrypt session")
+	}
+	if _, err := plaintext.Write(data); err != nil {
+		return err
+	}
+	if err := plaintext.Close(); err != nil {
+		return err
+	}
+	if err := armored.Close(); err != nil {
+		return err
+	}
+
+	req, err := retryablehttp.NewRequest(http.MethodPut, escrowURL(keyserverURL, c.correlationID), encrypted.Bytes())
+	if err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not create new request")
+	}
+	req.Header.Set("Content-Type", escrowContentType)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not make escrow request")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("could not escrow session: %s %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// RecoverSession fetches the escrowed session of a correlation id from the
+// team keyserver and decrypts it with the pgp private keys of the keyring,
+// the passphrase unlocking the encrypted private keys
+func RecoverSession(keyserverURL, correlationID string, keyring openpgp.EntityList, passphrase []byte) (*options.SessionInfo, error) {
+	req, err := retryablehttp.NewRequest(http.MethodGet, escrowURL(keyserverURL, correlationID), nil)
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not create new request")
+	}
+	resp, err := retryablehttp.NewClient(retryablehttp.DefaultOptionsSingle).Do(req)
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not make recover request")
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not recover session: %s %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	block, err := armor.Decode(bytes.NewReader(body))
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not decode escrowed session")
+	}
+	prompted := false
+	prompt := func(keys []openpgp.Key, symmetric bool) ([]byte, error) {
+		// the prompt is called again while no key is decrypted
+		if prompted || len(passphrase) == 0 {
+			return nil, errors.New("the private key is encrypted, a valid passphrase is required")
+		}
+		prompted = true
+		for _, key := range keys {
+			if key.PrivateKey != nil && key.PrivateKey.Encrypted {
+				_ = key.PrivateKey.Decrypt(passphrase)
+			}
+		}
+		return nil, nil
+	}
+	message, err := openpgp.ReadMessage(block.Body, keyring, prompt, nil)
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not decrypt escrowed session")
+	}
+	data, err := io.ReadAll(message.UnverifiedBody)
+	if err != nil {
+		return nil, err
+	}
+	sessionInfo := &options.SessionInfo{}
+	if err := yaml.Unmarshal(data, sessionInfo); err != nil {
+		return nil, err
+	}
+	if sessionInfo.CorrelationID != correlationID {
+		return nil, fmt.Errorf("the escrowed session is for %s", sessionInfo.CorrelationID)
+	}
+	return sessionInfo, nil
+}
+
+// escrowURL returns the keyserver url of a session
+func escrowURL(keyserverURL, correlationID string) string {
+	return strings.TrimSuffix(keyserverURL, "/") + "/" + url.PathEscape(correlationID)
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/projectdiscovery/retryablehttp-go"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+func TestEscrowSession(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		sessions = make(map[string][]byte)
+	)
+	keyserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			require.Equal(t, escrowContentType, r.Header.Get("Content-Type"))
+			sessions[r.URL.Path], _ = io.ReadAll(r.Body)
+		case http.MethodGet:
+			data, ok := sessions[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = w.Write(data)
+		}
+	}))
+	defer keyserver.Close()
+
+	teammate, err := openpgp.NewEntity("teammate", "", "teammate@example.com", nil)
+	require.Nil(t, err)
+	publicKey := filepath.Join(t.TempDir(), "teammate.asc")
+	file, err := os.Create(publicKey)
+	require.Nil(t, err)
+	armored, err := armor.Encode(file, openpgp.PublicKeyType, nil)
+	require.Nil(t, err)
+	require.Nil(t, teammate.Serialize(armored))
+	require.Nil(t, armored.Close())
+	require.Nil(t, file.Close())
+	recipients, err := ReadPGPKeys(publicKey)
+	require.Nil(t, err)
+	require.Len(t, recipients, 1)
+
+	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+	serverURL, _ := url.Parse("https://oast.example.com")
+	client := &Client{
+		correlationID: "c59e3crp82ke7bcnedq0",
+		secretKey:     "secret",
+		serverURL:     serverURL,
+		httpClient:    retryablehttp.NewClient(retryablehttp.DefaultOptionsSingle),
+		privKey:       privKey,
+		pubKey:        &privKey.PublicKey,
+	}
+	require.Nil(t, client.EscrowSession(keyserver.URL+"/sessions/", recipients))
+	require.NotContains(t, string(sessions["/sessions/c59e3crp82ke7bcnedq0"]), "secret")
+
+	sessionInfo, err := RecoverSession(keyserver.URL+"/sessions", "c59e3crp82ke7bcnedq0", openpgp.EntityList{teammate}, nil)
+	require.Nil(t, err)
+	require.Equal(t, "secret", sessionInfo.SecretKey)
+	require.Equal(t, "https://oast.example.com", sessionInfo.ServerURL)
+	require.NotEmpty(t, sessionInfo.PrivateKey)
+
+	other, err := openpgp.NewEntity("other", "", "other@example.com", nil)
+	require.Nil(t, err)
+	_, err = RecoverSession(keyserver.URL+"/sessions", "c59e3crp82ke7bcnedq0", openpgp.EntityList{other}, nil)
+	require.NotNil(t, err, "a teammate without escrowed key should not decrypt the session")
+	_, err = RecoverSession(keyserver.URL+"/sessions", "unknown", openpgp.EntityList{teammate}, nil)
+	require.NotNil(t, err)
+}
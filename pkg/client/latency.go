@@ -0,0 +1,72 @@
+package client
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/projectdiscovery/interactsh/pkg/server"
+)
+
+// MaxTrackedPayloads is the number of generated payloads whose emission time
+// is kept, the oldest being forgotten beyond it
+var MaxTrackedPayloads = 100000
+
+// payloadEmissions keeps the generation time and namespace of the payloads by unique id
+type payloadEmissions struct {
+	mu         sync.Mutex
+	times      map[string]time.Time
+	namespaces map[string]string
+	order      []string
+}
+
+// emit records the generation time and namespace of a payload, false if
+// the unique id was already emitted
+func (p *payloadEmissions) emit(uniqueID, namespace string, at time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.times == nil {
+		p.times = make(map[string]time.Time)
+		p.namespaces = make(map[string]string)
+	}
+	if _, ok := p.times[uniqueID]; ok {
+		return false
+	}
+	if len(p.order) >= MaxTrackedPayloads {
+		delete(p.times, p.order[0])
+		delete(p.namespaces, p.order[0])
+		p.order = p.order[1:]
+	}
+	p.times[uniqueID] = at
+	if namespace != "" {
+		p.namespaces[uniqueID] = namespace
+	}
+	p.order = append(p.order, uniqueID)
+	return true
+}
+
+// namespace returns the namespace a payload was generated for
+func (p *payloadEmissions) namespace(uniqueID string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.namespaces[strings.ToLower(uniqueID)]
+}
+
+// apply sets the emission time and namespace of the payload of an interaction
+// and the delay until the server received it, unless the payload is unknown
+func (p *payloadEmissions) apply(interaction *server.Interaction) {
+	p.mu.Lock()
+	emitted, ok := p.times[strings.ToLower(interaction.UniqueID)]
+	namespace := p.namespaces[strings.ToLower(interaction.UniqueID)]
+	p.mu.Unlock()
+	if !ok {
+		return
+	}
+	interaction.PayloadNamespace = namespace
+	// the server timestamp excludes the polling delay, the clocks of the
+	// client and server being assumed in sync
+	latency := interaction.Timestamp.Sub(emitted).Milliseconds()
+	interaction.PayloadEmitted = &emitted
+	interaction.PayloadLatency = &latency
+}
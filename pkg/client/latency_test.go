@@ -0,0 +1,32 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/projectdiscovery/interactsh/pkg/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPayloadEmissions(t *testing.T) {
+	emitted := time.Now()
+	emissions := &payloadEmissions{}
+	emissions.emit("c59e3crp82ke7bcnedq0cfjqdpeyyyyyy", "", emitted)
+
+	interaction := &server.Interaction{UniqueID: "C59E3CRP82KE7BCNEDQ0CFJQDPEYYYYYY", Timestamp: emitted.Add(1500 * time.Millisecond)}
+	emissions.apply(interaction)
+	require.NotNil(t, interaction.PayloadLatency)
+	require.EqualValues(t, 1500, *interaction.PayloadLatency)
+	require.True(t, interaction.PayloadEmitted.Equal(emitted))
+
+	unknown := &server.Interaction{UniqueID: "c59e3crp82ke7bcnedq0abcdefghijklm", Timestamp: emitted}
+	emissions.apply(unknown)
+	require.Nil(t, unknown.PayloadLatency)
+
+	defer func(max int) { MaxTrackedPayloads = max }(MaxTrackedPayloads)
+	MaxTrackedPayloads = 1
+	emissions.emit("c59e3crp82ke7bcnedq0abcdefghijklm", "", emitted)
+	interaction = &server.Interaction{UniqueID: "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy", Timestamp: emitted}
+	emissions.apply(interaction)
+	require.Nil(t, interaction.PayloadLatency, "the oldest payload should be forgotten")
+}
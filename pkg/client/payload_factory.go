@@ -0,0 +1,68 @@
+package client
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var (
+	// ErrInvalidNamespace is returned for a namespace that isn't a sequence of dns labels
+	ErrInvalidNamespace = errors.New("namespace must be dot separated dns labels")
+	// ErrPayloadCollision is returned when no payload different from the ones
+	// generated before could be drawn, eg. without nonce
+	ErrPayloadCollision = errors.New("couldn't generate a unique payload")
+	// ErrClientClosed is returned for the payloads of a closed client
+	ErrClientClosed = errors.New("client is closed")
+)
+
+// namespacePattern matches dot separated dns labels
+var namespacePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?(\.[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?)*$`)
+
+// PayloadFactory generates the payloads of a client prefixed with namespaces,
+// eg. the name of a check or parameter, from concurrent goroutines. Every
+// payload has a unique id never issued before by the client (among the last
+// MaxTrackedPayloads), and the interactions of a payload are polled with the
+// namespace it was generated for in their PayloadNamespace.
+type PayloadFactory struct {
+	client *Client
+}
+
+// PayloadFactory returns a payload factory of the client, safe for concurrent use
+func (c *Client) PayloadFactory() *PayloadFactory {
+	return &PayloadFactory{client: c}
+}
+
+// Payload returns a new payload of the namespace, as <namespace>.<unique-id>.<server>,
+// or a plain payload for an empty namespace
+func (f *PayloadFactory) Payload(namespace string) (string, error) {
+	if f.client.State.Load() == Closed {
+		return "", ErrClientClosed
+	}
+	namespace = strings.ToLower(namespace)
+	if namespace != "" && !namespacePattern.MatchString(namespace) {
+		return "", ErrInvalidNamespace
+	}
+	uniqueID, ok := f.client.newUniqueID(namespace)
+	if !ok {
+		return "", ErrPayloadCollision
+	}
+
+	builder := &strings.Builder{}
+	builder.Grow(len(namespace) + len(uniqueID) + len(f.client.serverURL.Host) + 2)
+	if namespace != "" {
+		builder.WriteString(namespace)
+		builder.WriteString(".")
+	}
+	builder.WriteString(uniqueID)
+	builder.WriteString(".")
+	builder.WriteString(f.client.serverURL.Host)
+	payload := builder.String()
+	f.client.emitted(payload, uniqueID)
+	return payload, nil
+}
+
+// Namespace returns the namespace a unique id was generated for, if any
+func (f *PayloadFactory) Namespace(uniqueID string) string {
+	return f.client.emissions.namespace(uniqueID)
+}
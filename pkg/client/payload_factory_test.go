@@ -0,0 +1,97 @@
+package client
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/projectdiscovery/interactsh/pkg/server"
+	"github.com/stretchr/testify/require"
+)
+
+func testFactoryClient(nonceLength int) *Client {
+	c := &Client{
+		correlationID:            "c59e3crp82ke7bcnedq0",
+		CorrelationIdNonceLength: nonceLength,
+		serverURL:                &url.URL{Scheme: "https", Host: "oast.example.com"},
+	}
+	c.State.Store(Idle)
+	return c
+}
+
+func TestPayloadFactory(t *testing.T) {
+	c := testFactoryClient(13)
+	factory := c.PayloadFactory()
+
+	var (
+		mu       sync.Mutex
+		payloads = make(map[string]string)
+		wg       sync.WaitGroup
+	)
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func(namespace string) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				payload, err := factory.Payload(namespace)
+				require.Nil(t, err)
+				mu.Lock()
+				payloads[payload] = namespace
+				mu.Unlock()
+			}
+		}([]string{"sqli", "ssrf.param-1", "XXE", ""}[worker%4])
+	}
+	wg.Wait()
+	require.Len(t, payloads, 4000)
+
+	for payload, namespace := range payloads {
+		require.True(t, strings.HasSuffix(payload, ".oast.example.com"), payload)
+		labels := strings.Split(strings.TrimSuffix(payload, ".oast.example.com"), ".")
+		uniqueID := labels[len(labels)-1]
+		require.Len(t, uniqueID, 33)
+		require.Equal(t, strings.ToLower(namespace), factory.Namespace(uniqueID))
+	}
+
+	// the polled interactions carry the namespace of their payload
+	payload, err := factory.Payload("rce")
+	require.Nil(t, err)
+	interaction := &server.Interaction{UniqueID: strings.Split(payload, ".")[1], Timestamp: time.Now()}
+	c.emissions.apply(interaction)
+	require.Equal(t, "rce", interaction.PayloadNamespace)
+
+	for _, namespace := range []string{"a_b", "-sqli", "sqli..param", "sqli.", strings.Repeat("a", 64)} {
+		_, err := factory.Payload(namespace)
+		require.ErrorIs(t, err, ErrInvalidNamespace, namespace)
+	}
+
+	c.State.Store(Closed)
+	_, err = factory.Payload("sqli")
+	require.ErrorIs(t, err, ErrClientClosed)
+}
+
+func TestPayloadFactoryCollision(t *testing.T) {
+	factory := testFactoryClient(0).PayloadFactory()
+	payload, err := factory.Payload("sqli")
+	require.Nil(t, err)
+	require.Equal(t, "sqli.c59e3crp82ke7bcnedq0.oast.example.com", payload)
+	_, err = factory.Payload("ssrf")
+	require.ErrorIs(t, err, ErrPayloadCollision)
+}
+
+func TestPayloadFactoryOnPayload(t *testing.T) {
+	c := testFactoryClient(13)
+	emitted := map[string]string{}
+	c.onPayload = func(payload, uniqueID string) {
+		emitted[payload] = uniqueID
+	}
+
+	URL := c.URL()
+	payload, err := c.PayloadFactory().Payload("sqli")
+	require.Nil(t, err)
+
+	require.Len(t, emitted, 2)
+	require.Equal(t, URL[:strings.Index(URL, ".")], emitted[URL])
+	require.True(t, strings.HasPrefix(payload, "sqli."+emitted[payload]+"."))
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/projectdiscovery/interactsh/pkg/server"
+)
+
+// webhookSecretName matches the names of the webhook secrets
+var webhookSecretName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// WebhookSecret is a secret the signatures of received webhooks are verified with
+type WebhookSecret struct {
+	// Name identifies the secret in the verdicts, not revealing the secret itself
+	Name string
+	// Secret is the signing secret of the webhook
+	Secret string
+}
+
+// ParseWebhookSecret parses a secret in the name=secret form, secrets
+// without name are named after their position
+func ParseWebhookSecret(value string, index int) (WebhookSecret, error) {
+	name, secret, ok := strings.Cut(value, "=")
+	// base64 secrets may end with padding
+	if !ok || secret == "" || !webhookSecretName.MatchString(name) {
+		name, secret = fmt.Sprintf("secret%d", index+1), value
+	}
+	if secret == "" {
+		return WebhookSecret{}, errors.New("empty webhook secret")
+	}
+	return WebhookSecret{Name: name, Secret: secret}, nil
+}
+
+// WebhookVerifier verifies the vendor signatures of http interactions with
+// the secrets of the client, so that misdirected production webhooks are
+// proven to originate from the holder of the secret
+type WebhookVerifier struct {
+	secrets []WebhookSecret
+}
+
+// NewWebhookVerifier returns a verifier of webhook signatures
+func NewWebhookVerifier(secrets []WebhookSecret) *WebhookVerifier {
+	return &WebhookVerifier{secrets: secrets}
+}
+
+// Verify sets the verdict of the webhook signature of an interaction, the
+// truncated requests can't be verified and are left without verdict
+func (v *WebhookVerifier) Verify(interaction *server.Interaction) {
+	signature := interaction.WebhookSignature
+	if signature == nil || interaction.Truncated {
+		return
+	}
+	body, err := requestBody(interaction.RawRequest)
+	if err != nil {
+		return
+	}
+	verified := false
+	for _, secret := range v.secrets {
+		if signature.Verify(body, secret.Secret) {
+			verified = true
+			signature.Secret = secret.Name
+			break
+		}
+	}
+	signature.Verified = &verified
+}
+
+// requestBody returns the body of a raw http request as signed by its sender,
+// the details appended by the server after the body are ignored
+func requestBody(rawRequest string) ([]byte, error) {
+	req, err := http.ReadRequest(bufio.NewReader(strings.NewReader(rawRequest)))
+	if err != nil {
+		return nil, err
+	}
+	defer req.Body.Close()
+	return io.ReadAll(req.Body)
+}
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"strings"
+	"testing"
+
+	"github.com/projectdiscovery/interactsh/pkg/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookVerifier(t *testing.T) {
+	request := httptest.NewRequest(http.MethodPost, "http://interact.sh/", strings.NewReader("Hello, World!"))
+	request.Header.Set("Content-Length", "13")
+	raw, err := httputil.DumpRequest(request, true)
+	require.Nil(t, err)
+	interaction := &server.Interaction{
+		Protocol: "http",
+		// the details appended by the server are not signed
+		RawRequest: string(raw) + "\n\nWebhook Signature: vendor=github\n",
+		WebhookSignature: &server.WebhookSignature{
+			Vendor:     "github",
+			Algorithm:  "sha256",
+			Signatures: []string{"757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17"},
+		},
+	}
+
+	secret, err := ParseWebhookSecret("github=It's a Secret to Everybody", 0)
+	require.Nil(t, err)
+	require.Equal(t, "github", secret.Name)
+	other, err := ParseWebhookSecret("c2VjcmV0=", 1)
+	require.Nil(t, err)
+	require.Equal(t, "secret2", other.Name)
+
+	NewWebhookVerifier([]WebhookSecret{other, secret}).Verify(interaction)
+	require.True(t, *interaction.WebhookSignature.Verified)
+	require.Equal(t, "github", interaction.WebhookSignature.Secret)
+
+	interaction.WebhookSignature.Secret = ""
+	NewWebhookVerifier([]WebhookSecret{other}).Verify(interaction)
+	require.False(t, *interaction.WebhookSignature.Verified)
+	require.Empty(t, interaction.WebhookSignature.Secret)
+}
@@ -15,6 +15,7 @@ type CLIClientOptions struct {
 	NumberOfPayloads         int
 	Output                   string
 	JSON                     bool
+	SaveBodies               string
 	StorePayload             bool
 	StorePayloadFile         string
 	Verbose                  bool
@@ -30,4 +31,33 @@ type CLIClientOptions struct {
 	Asn                      bool
 	DisableUpdateCheck       bool
 	KeepAliveInterval        time.Duration
+	Share                    goflags.StringSlice
+	ShareKeygen              bool
+	Join                     string
+	EscrowURL                string
+	EscrowKeys               goflags.StringSlice
+	EscrowRecover            string
+	EscrowIdentity           string
+	Inspect                  bool
+	Watch                    bool
+	Service                  string
+	ServiceName              string
+	Database                 string
+	Webhook                  string
+	WebhookDeadLetter        string
+	WebhookRate              string
+	WebhookProviderRates     goflags.StringSlice
+	WebhookBatch             time.Duration
+	Plugins                  goflags.StringSlice
+	UsageReport              bool
+	UsageReportFile          string
+	VerifyDNS                bool
+	VerifyDNSGrace           int
+	VerifyWebhook            goflags.StringSlice
+	StatsFile                string
+	CNAME                    string
+	DNSScripts               goflags.StringSlice
+	HTTPTemplate             string
+	PayloadFiles             goflags.StringSlice
+	CorrelationIdChecksum    bool
 }
@@ -1,6 +1,8 @@
 package options
 
 import (
+	"time"
+
 	"github.com/projectdiscovery/goflags"
 	"github.com/projectdiscovery/interactsh/pkg/server"
 )
@@ -15,10 +17,14 @@ type CLIServerOptions struct {
 	ListenIP                 string
 	HttpPort                 int
 	HttpsPort                int
+	DoT                      bool
+	DoTPort                  int
+	DoH                      bool
 	Hostmasters              []string
 	LdapWithFullLogger       bool
 	Eviction                 int
 	NoEviction               bool
+	GraceWindow              time.Duration
 	Responder                bool
 	Smb                      bool
 	SmbPort                  int
@@ -29,6 +35,9 @@ type CLIServerOptions struct {
 	FtpsPort                 int
 	LdapPort                 int
 	Ftp                      bool
+	Echo                     bool
+	EchoTcpPort              int
+	EchoUdpPort              int
 	Auth                     bool
 	HTTPIndex                string
 	HTTPDirectory            string
@@ -49,10 +58,103 @@ type CLIServerOptions struct {
 	DiskStoragePath          string
 	EnablePprof              bool
 	EnableMetrics            bool
+	StatsStore               string
 	Verbose                  bool
 	DisableUpdateCheck       bool
 	NoVersionHeader          bool
 	HeaderServer             string
+	DNSUncorrelated          string
+	DNSCatchAllIP            string
+	HTTPPersonalities        goflags.StringSlice
+	SelfSigned               bool
+	SelfSignedDir            string
+	EnvFile                  string
+	EncryptSecret            string
+	BodyCaptureSize          int
+	BodyDir                  string
+	BodyMaxSize              int
+	ACMECA                   string
+	ACMEAccountKey           string
+	ACMEEABKeyID             string
+	ACMEEABMACKey            string
+	ACMEChallenge            string
+	Region                   string
+	AdminAPI                 bool
+	EgressCap                int
+	SessionEgressCap         int
+	FuzzLatency              string
+	FuzzSize                 string
+	RegionConfig             string
+	SnapshotDir              string
+	SnapshotInterval         int
+	CompactionInterval       int
+	Restore                  string
+	NAT64Prefixes            goflags.StringSlice
+	TenantConfig             string
+	Webhook                  string
+	WebhookDeadLetter        string
+	WebhookRate              string
+	WebhookProviderRates     goflags.StringSlice
+	WebhookBatch             time.Duration
+	ClickHouse               string
+	ClickHouseTable          string
+	ClickHouseRetention      int
+	TLSVersions              goflags.StringSlice
+	TLSCiphers               goflags.StringSlice
+	TLSLegacy                goflags.StringSlice
+	TLSClientCert            goflags.StringSlice
+	DNSExfil                 bool
+	Inspect                  bool
+	LDAPEntry                string
+	CorrelationIdChecksum    bool
+	LDAPExploitMode          string
+	AdminSocket              string
+	User                     string
+	Ssh                      bool
+	SshPort                  int
+	Workers                  int
+	ShutdownTimeout          int
+	Mysql                    bool
+	MysqlPort                int
+	PacketCapture            string
+	PacketCaptureSize        int
+	Redis                    bool
+	RedisPort                int
+	RedisTLSPort             int
+	HTTPResponses            string
+	TcpPorts                 goflags.StringSlice
+	TcpCaptureSize           int
+	TcpPayloadRegex          string
+	UdpPorts                 goflags.StringSlice
+	DeceptionProfile         string
+	DNSRecords               string
+	DNSSEC                   bool
+	DNSSECDir                string
+	DNSForwarders            goflags.StringSlice
+	Imap                     bool
+	ImapPort                 int
+	ImapsPort                int
+	Pop3                     bool
+	Pop3Port                 int
+	Pop3sPort                int
+	OIDC                     bool
+	OIDCKey                  string
+	SAMLACSPath              string
+	Redirect                 bool
+	RedirectMaxHops          int
+	Tftp                     bool
+	TftpPort                 int
+	Snmp                     bool
+	SnmpPort                 int
+	SnmpTrapPort             int
+	Ntp                      bool
+	NtpPort                  int
+	Sip                      bool
+	SipPort                  int
+	Grpc                     bool
+	GrpcPort                 int
+	Icmp                     bool
+	Protocols                goflags.StringSlice
 }
 
 func (cliServerOptions *CLIServerOptions) AsServerOptions() *server.Options {
@@ -63,6 +165,8 @@ func (cliServerOptions *CLIServerOptions) AsServerOptions() *server.Options {
 		ListenIP:                 cliServerOptions.ListenIP,
 		HttpPort:                 cliServerOptions.HttpPort,
 		HttpsPort:                cliServerOptions.HttpsPort,
+		DoTPort:                  cliServerOptions.DoTPort,
+		DoH:                      cliServerOptions.DoH,
 		Hostmasters:              cliServerOptions.Hostmasters,
 		SmbPort:                  cliServerOptions.SmbPort,
 		SmtpPort:                 cliServerOptions.SmtpPort,
@@ -71,12 +175,33 @@ func (cliServerOptions *CLIServerOptions) AsServerOptions() *server.Options {
 		FtpPort:                  cliServerOptions.FtpPort,
 		FtpsPort:                 cliServerOptions.FtpsPort,
 		LdapPort:                 cliServerOptions.LdapPort,
+		SshPort:                  cliServerOptions.SshPort,
+		MysqlPort:                cliServerOptions.MysqlPort,
+		RedisPort:                cliServerOptions.RedisPort,
+		RedisTLSPort:             cliServerOptions.RedisTLSPort,
+		IMAPPort:                 cliServerOptions.ImapPort,
+		IMAPSPort:                cliServerOptions.ImapsPort,
+		POP3Port:                 cliServerOptions.Pop3Port,
+		POP3SPort:                cliServerOptions.Pop3sPort,
+		TFTPPort:                 cliServerOptions.TftpPort,
+		SNMPPort:                 cliServerOptions.SnmpPort,
+		SNMPTrapPort:             cliServerOptions.SnmpTrapPort,
+		NTPPort:                  cliServerOptions.NtpPort,
+		SIPPort:                  cliServerOptions.SipPort,
+		GRPCPort:                 cliServerOptions.GrpcPort,
+		LDAPFullLogger:           cliServerOptions.LdapWithFullLogger,
+		EchoTCPPort:              cliServerOptions.EchoTcpPort,
+		EchoUDPPort:              cliServerOptions.EchoUdpPort,
+		TCPCaptureSize:           cliServerOptions.TcpCaptureSize,
 		Auth:                     cliServerOptions.Auth,
 		HTTPIndex:                cliServerOptions.HTTPIndex,
 		HTTPDirectory:            cliServerOptions.HTTPDirectory,
 		Token:                    cliServerOptions.Token,
 		Version:                  Version,
 		DynamicResp:              cliServerOptions.DynamicResp,
+		SAMLACSPath:              cliServerOptions.SAMLACSPath,
+		Redirect:                 cliServerOptions.Redirect,
+		RedirectMaxHops:          cliServerOptions.RedirectMaxHops,
 		OriginURL:                cliServerOptions.OriginURL,
 		RootTLD:                  cliServerOptions.RootTLD,
 		FTPDirectory:             cliServerOptions.FTPDirectory,
@@ -92,5 +217,30 @@ func (cliServerOptions *CLIServerOptions) AsServerOptions() *server.Options {
 		EnableMetrics:            cliServerOptions.EnableMetrics,
 		NoVersionHeader:          cliServerOptions.NoVersionHeader,
 		HeaderServer:             cliServerOptions.HeaderServer,
+		DNSUncorrelated:          cliServerOptions.DNSUncorrelated,
+		DNSCatchAllIP:            cliServerOptions.DNSCatchAllIP,
+		HTTPPersonalities:        cliServerOptions.HTTPPersonalities,
+		BodyCaptureSize:          cliServerOptions.BodyCaptureSize,
+		EgressCap:                cliServerOptions.EgressCap,
+		AdminAPI:                 cliServerOptions.AdminAPI,
+		SessionEgressCap:         cliServerOptions.SessionEgressCap,
+		DNSExfil:                 cliServerOptions.DNSExfil,
+		CorrelationIdChecksum:    cliServerOptions.CorrelationIdChecksum,
+		AdminSocket:              cliServerOptions.AdminSocket,
+		Config:                   cliServerOptions.Sanitized(),
+		Workers:                  cliServerOptions.Workers,
 	}
 }
+
+// Sanitized returns a copy of the options without the token and secrets,
+// the webhook and clickhouse urls embedding credentials being removed too
+func (cliServerOptions *CLIServerOptions) Sanitized() *CLIServerOptions {
+	sanitized := *cliServerOptions
+	sanitized.Token = ""
+	sanitized.EncryptSecret = ""
+	sanitized.ACMEAccountKey = ""
+	sanitized.ACMEEABMACKey = ""
+	sanitized.Webhook = ""
+	sanitized.ClickHouse = ""
+	return &sanitized
+}
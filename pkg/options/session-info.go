@@ -1,5 +1,11 @@
 package options
 
+import (
+	"encoding/base64"
+
+	"gopkg.in/yaml.v3"
+)
+
 type SessionInfo struct {
 	ServerURL     string `yaml:"server-url"`
 	Token         string `yaml:"server-token"`
@@ -8,3 +14,28 @@ type SessionInfo struct {
 	SecretKey     string `yaml:"secret-key"`
 	PublicKey     string `yaml:"public-key"`
 }
+
+// ShareToken encodes the session info into a single line token that can be
+// handed to a teammate. The private key is never included.
+func (s *SessionInfo) ShareToken() (string, error) {
+	shared := *s
+	shared.PrivateKey = ""
+	data, err := yaml.Marshal(shared)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// ParseShareToken decodes a token generated with ShareToken
+func ParseShareToken(token string) (*SessionInfo, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+	sessionInfo := &SessionInfo{}
+	if err := yaml.Unmarshal(data, sessionInfo); err != nil {
+		return nil, err
+	}
+	return sessionInfo, nil
+}
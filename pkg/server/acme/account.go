@@ -0,0 +1,85 @@
+package acme
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/caddyserver/certmagic"
+	"github.com/mholt/acmez/acme"
+)
+
+// CAs are the well known acme directories selectable by name
+var CAs = map[string]string{
+	"letsencrypt":         certmagic.LetsEncryptProductionCA,
+	"letsencrypt-staging": certmagic.LetsEncryptStagingCA,
+	"zerossl":             certmagic.ZeroSSLProductionCA,
+}
+
+const (
+	// ChallengeDNS solves dns-01 challenges with the dns server, allowing wildcard certificates
+	ChallengeDNS = "dns"
+	// ChallengeTLSALPN solves tls-alpn-01 challenges on port 443, only the
+	// certificate of the domain itself can be issued as wildcards require dns-01
+	ChallengeTLSALPN = "tls-alpn"
+)
+
+// Challenges are the supported acme challenge types
+var Challenges = []string{ChallengeDNS, ChallengeTLSALPN}
+
+// AccountOptions contains the acme account configuration
+type AccountOptions struct {
+	// CA is the acme directory url or well known name, Let's Encrypt by default
+	CA string
+	// AccountKey is an existing PEM encoded account private key to reuse
+	AccountKey string
+	// EABKeyID is the key identifier for external account binding
+	EABKeyID string
+	// EABMACKey is the base64url encoded mac key for external account binding
+	EABMACKey string
+	// Challenge is the acme challenge type to solve, ChallengeDNS by default
+	Challenge string
+}
+
+// Validate validates the account options
+func (options *AccountOptions) Validate() error {
+	if (options.EABKeyID == "") != (options.EABMACKey == "") {
+		return errors.New("external account binding requires both key id and mac key")
+	}
+	if options.CA != "" && CAs[options.CA] == "" && !strings.HasPrefix(options.CA, "https://") {
+		return errors.New("acme ca must be a known name or an https directory url")
+	}
+	if options.Challenge != "" && !slices.Contains(Challenges, options.Challenge) {
+		return fmt.Errorf("acme challenge must be one of %s", strings.Join(Challenges, ","))
+	}
+	return nil
+}
+
+// tlsALPN reports whether tls-alpn-01 challenges are solved instead of dns-01
+func (options *AccountOptions) tlsALPN() bool {
+	return options != nil && options.Challenge == ChallengeTLSALPN
+}
+
+// apply configures the acme issuer with the account options
+func (options *AccountOptions) apply(issuer *certmagic.ACMEIssuer) {
+	if options == nil {
+		return
+	}
+	if options.CA != "" {
+		if directory, ok := CAs[options.CA]; ok {
+			issuer.CA = directory
+		} else {
+			issuer.CA = options.CA
+		}
+	}
+	if options.AccountKey != "" {
+		issuer.AccountKeyPEM = options.AccountKey
+	}
+	if options.EABKeyID != "" {
+		issuer.ExternalAccount = &acme.EAB{
+			KeyID:  options.EABKeyID,
+			MACKey: options.EABMACKey,
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package acme
+
+import (
+	"testing"
+
+	"github.com/caddyserver/certmagic"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountOptions(t *testing.T) {
+	require.NotNil(t, (&AccountOptions{EABKeyID: "kid"}).Validate())
+	require.NotNil(t, (&AccountOptions{CA: "http://ca.internal/directory"}).Validate())
+	require.Nil(t, (&AccountOptions{CA: "zerossl", EABKeyID: "kid", EABMACKey: "mac"}).Validate())
+	require.NotNil(t, (&AccountOptions{Challenge: "http"}).Validate())
+	require.Nil(t, (&AccountOptions{Challenge: ChallengeTLSALPN}).Validate())
+	require.True(t, (&AccountOptions{Challenge: ChallengeTLSALPN}).tlsALPN())
+
+	issuer := &certmagic.ACMEIssuer{CA: certmagic.LetsEncryptProductionCA}
+	var none *AccountOptions
+	none.apply(issuer)
+	require.Equal(t, certmagic.LetsEncryptProductionCA, issuer.CA)
+	require.Nil(t, issuer.ExternalAccount)
+
+	account := &AccountOptions{CA: "zerossl", AccountKey: "pem", EABKeyID: "kid", EABMACKey: "mac"}
+	account.apply(issuer)
+	require.Equal(t, certmagic.ZeroSSLProductionCA, issuer.CA)
+	require.Equal(t, "pem", issuer.AccountKeyPEM)
+	require.Equal(t, "kid", issuer.ExternalAccount.KeyID)
+	require.Equal(t, "mac", issuer.ExternalAccount.MACKey)
+
+	(&AccountOptions{CA: "https://acme.internal/directory"}).apply(issuer)
+	require.Equal(t, "https://acme.internal/directory", issuer.CA)
+}
@@ -6,10 +6,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/caddyserver/certmagic"
+	"github.com/mholt/acmez"
 	"github.com/pkg/errors"
 	"github.com/projectdiscovery/gologger"
 	"go.uber.org/zap"
@@ -27,8 +29,10 @@ type CertificateFiles struct {
 }
 
 // HandleWildcardCertificates handles ACME wildcard cert generation with DNS
-// challenge using certmagic library from caddyserver.
-func HandleWildcardCertificates(domain, email string, store *Provider, debug bool) ([]tls.Certificate, []CertificateFiles, error) {
+// challenge using certmagic library from caddyserver. The account options
+// allow reusing an existing account key and external account binding, or
+// solving tls-alpn challenges for the certificate of the domain itself.
+func HandleWildcardCertificates(domain, email string, store *Provider, account *AccountOptions, debug bool) ([]tls.Certificate, []CertificateFiles, error) {
 	logger, err := zap.NewProduction()
 	if err != nil {
 		return nil, nil, err
@@ -47,11 +51,21 @@ func HandleWildcardCertificates(domain, email string, store *Provider, debug boo
 	originalDomain := strings.TrimPrefix(domain, "*.")
 
 	certmagic.DefaultACME.CA = certmagic.LetsEncryptProductionCA
+	account.apply(&certmagic.DefaultACME)
 	if debug {
 		certmagic.DefaultACME.Logger = logger
 	}
 	certmagic.DefaultACME.DisableHTTPChallenge = true
 	certmagic.DefaultACME.DisableTLSALPNChallenge = true
+	domains := []string{domain, originalDomain}
+	if account.tlsALPN() {
+		// wildcard certificates can only be issued with dns-01 challenges
+		gologger.Warning().Msgf("Wildcard certificates require dns challenges, only %s is requested with tls-alpn", originalDomain)
+		certmagic.DefaultACME.DNS01Solver = nil
+		certmagic.DefaultACME.DisableTLSALPNChallenge = false
+		domain = originalDomain
+		domains = []string{originalDomain}
+	}
 
 	cfg := certmagic.NewDefault()
 	if debug {
@@ -61,9 +75,9 @@ func HandleWildcardCertificates(domain, email string, store *Provider, debug boo
 	var creating bool
 	if !certAlreadyExists(cfg, &certmagic.DefaultACME, domain) {
 		creating = true
-		gologger.Info().Msgf("Requesting SSL Certificate for:  [%s, %s]", domain, originalDomain)
+		gologger.Info().Msgf("Requesting SSL Certificate for:  [%s]", strings.Join(domains, ", "))
 	} else {
-		gologger.Info().Msgf("Loading existing SSL Certificate for:  [%s, %s]", domain, originalDomain)
+		gologger.Info().Msgf("Loading existing SSL Certificate for:  [%s]", strings.Join(domains, ", "))
 	}
 
 	// this obtains certificates or renews them if necessary
@@ -71,7 +85,6 @@ func HandleWildcardCertificates(domain, email string, store *Provider, debug boo
 		return nil, nil, syncErr
 	}
 
-	domains := []string{domain, originalDomain}
 	if syncErr := cfg.ManageSync(context.Background(), domains); syncErr != nil {
 		gologger.Error().Msgf("Could not manage certmagic certs: %s", syncErr)
 	}
@@ -148,6 +161,25 @@ func ExtractCaddyPaths(cfg *certmagic.Config, issuer certmagic.Issuer, domain st
 	return
 }
 
+// ServeTLSALPNChallenges answers the tls-alpn-01 challenges of certificate
+// renewals on a tls configuration, as the solver can't listen on the port
+// once the https server is serving it
+func ServeTLSALPNChallenges(config *tls.Config) {
+	getCertificate := config.GetCertificate
+	challenges := certmagic.NewDefault()
+	config.NextProtos = append(config.NextProtos, acmez.ACMETLS1Protocol)
+	config.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		if slices.Contains(hello.SupportedProtos, acmez.ACMETLS1Protocol) {
+			return challenges.GetCertificate(hello)
+		}
+		if getCertificate != nil {
+			return getCertificate(hello)
+		}
+		// falls back to the certificates of the configuration
+		return nil, nil
+	}
+}
+
 // BuildTlsConfigWithCertAndKeyPaths Build TlsConfig with certificates
 func BuildTlsConfigWithCertAndKeyPaths(certPath, privKeyPath, domain string) (*tls.Config, error) {
 	cert, err := tls.LoadX509KeyPair(certPath, privKeyPath)
@@ -0,0 +1,31 @@
+package acme
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/mholt/acmez"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeTLSALPNChallenges(t *testing.T) {
+	selfSigned, err := HandleSelfSignedCertificates(t.TempDir(), []string{"interact.sh"})
+	require.Nil(t, err)
+
+	config, err := BuildTlsConfigWithCerts("", selfSigned.Certificate)
+	require.Nil(t, err)
+	ServeTLSALPNChallenges(config)
+	require.Equal(t, []string{"h2", "http/1.1", acmez.ACMETLS1Protocol}, config.NextProtos)
+
+	// regular handshakes fall back to the certificates of the configuration
+	cert, err := config.GetCertificate(&tls.ClientHelloInfo{ServerName: "abc.interact.sh", SupportedProtos: []string{"h2"}})
+	require.Nil(t, err)
+	require.Nil(t, cert)
+
+	store := NewCertificateStore(selfSigned.Certificate)
+	config = &tls.Config{GetCertificate: store.GetCertificate}
+	ServeTLSALPNChallenges(config)
+	cert, err = config.GetCertificate(&tls.ClientHelloInfo{ServerName: "abc.interact.sh"})
+	require.Nil(t, err)
+	require.Equal(t, selfSigned.Certificate.Certificate[0], cert.Certificate[0])
+}
@@ -0,0 +1,78 @@
+package acme
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// CertificateStore holds the served certificates allowing to add
+// the ones of domains added at runtime
+type CertificateStore struct {
+	mu           sync.RWMutex
+	certificates []tls.Certificate
+}
+
+// NewCertificateStore returns a new certificate store
+func NewCertificateStore(certs ...tls.Certificate) *CertificateStore {
+	return &CertificateStore{certificates: certs}
+}
+
+// Add adds certificates to the store
+func (s *CertificateStore) Add(certs ...tls.Certificate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.certificates = append(s.certificates, certs...)
+}
+
+// Remove removes the certificates only covering a domain and its subdomains
+func (s *CertificateStore) Remove(domain string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.certificates[:0:0]
+	for _, cert := range s.certificates {
+		if !coversOnly(cert, domain) {
+			kept = append(kept, cert)
+		}
+	}
+	s.certificates = kept
+}
+
+// coversOnly reports whether the names of a certificate are a domain and its subdomains
+func coversOnly(cert tls.Certificate, domain string) bool {
+	leaf := cert.Leaf
+	if leaf == nil && len(cert.Certificate) > 0 {
+		leaf, _ = x509.ParseCertificate(cert.Certificate[0])
+	}
+	if leaf == nil || len(leaf.DNSNames) == 0 {
+		return false
+	}
+	for _, name := range leaf.DNSNames {
+		name = strings.TrimPrefix(strings.ToLower(name), "*.")
+		if name != domain && !strings.HasSuffix(name, "."+domain) {
+			return false
+		}
+	}
+	return true
+}
+
+// GetCertificate returns the certificate matching the client hello, or the
+// first one if none matches, it is meant to be used as tls.Config.GetCertificate
+func (s *CertificateStore) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.certificates) == 0 {
+		return nil, errors.New("no certificates available")
+	}
+	for i := range s.certificates {
+		if hello.SupportsCertificate(&s.certificates[i]) == nil {
+			return &s.certificates[i], nil
+		}
+	}
+	return &s.certificates[0], nil
+}
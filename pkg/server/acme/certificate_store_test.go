@@ -0,0 +1,45 @@
+package acme
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertificateStore(t *testing.T) {
+	first, err := HandleSelfSignedCertificates(t.TempDir(), []string{"interact.sh"})
+	require.Nil(t, err)
+	second, err := HandleSelfSignedCertificates(t.TempDir(), []string{"client.example"})
+	require.Nil(t, err)
+
+	store := NewCertificateStore()
+	_, err = store.GetCertificate(&tls.ClientHelloInfo{ServerName: "abc.interact.sh"})
+	require.NotNil(t, err)
+
+	store.Add(first.Certificate)
+	store.Add(second.Certificate)
+	hello := &tls.ClientHelloInfo{
+		ServerName:        "abc.client.example",
+		SignatureSchemes:  []tls.SignatureScheme{tls.ECDSAWithP256AndSHA256},
+		SupportedVersions: []uint16{tls.VersionTLS13},
+	}
+	cert, err := store.GetCertificate(hello)
+	require.Nil(t, err)
+	require.Equal(t, second.Certificate.Certificate[0], cert.Certificate[0])
+
+	hello.ServerName = "unknown.example"
+	cert, err = store.GetCertificate(hello)
+	require.Nil(t, err)
+	require.Equal(t, first.Certificate.Certificate[0], cert.Certificate[0])
+
+	// the certificates of removed domains are no longer served
+	store.Remove("client.example")
+	hello.ServerName = "abc.client.example"
+	cert, err = store.GetCertificate(hello)
+	require.Nil(t, err)
+	require.Equal(t, first.Certificate.Certificate[0], cert.Certificate[0])
+	store.Remove("interact.sh")
+	_, err = store.GetCertificate(hello)
+	require.NotNil(t, err)
+}
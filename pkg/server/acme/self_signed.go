@@ -0,0 +1,223 @@
+package acme
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/gologger"
+	fileutil "github.com/projectdiscovery/utils/file"
+)
+
+// SelfSigned is a persistent self-signed certificate authority and the
+// wildcard certificate it issued for the served domains
+type SelfSigned struct {
+	// Certificate is the wildcard certificate
+	Certificate tls.Certificate
+	// CertificateFiles are the paths of the wildcard certificate and key
+	CertificateFiles CertificateFiles
+	// CA is the PEM encoded certificate authority
+	CA []byte
+	// CAFingerprint is the SHA256 fingerprint of the certificate authority
+	CAFingerprint string
+	// Fingerprint is the SHA256 fingerprint of the wildcard certificate
+	Fingerprint string
+
+	mu      sync.RWMutex
+	dir     string
+	domains []string
+}
+
+// SelfSignedRenewBefore is the time before its expiration at which the
+// wildcard certificate is reissued by the certificate authority
+var SelfSignedRenewBefore = 30 * 24 * time.Hour
+
+const (
+	selfSignedCA      = "ca.pem"
+	selfSignedCAKey   = "ca.key"
+	selfSignedCert    = "cert.pem"
+	selfSignedCertKey = "cert.key"
+)
+
+// HandleSelfSignedCertificates loads the self-signed certificates from dir,
+// generating them on first run. The certificate authority is kept across
+// runs so that clients trusting it don't need to be reconfigured, the
+// wildcard certificate being reissued only when the domains change or it
+// is about to expire.
+func HandleSelfSignedCertificates(dir string, domains []string) (*SelfSigned, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrap(err, "could not create certificates directory")
+	}
+	caPath, caKeyPath := filepath.Join(dir, selfSignedCA), filepath.Join(dir, selfSignedCAKey)
+	certPath, keyPath := filepath.Join(dir, selfSignedCert), filepath.Join(dir, selfSignedCertKey)
+
+	if !fileutil.FileExists(caPath) || !fileutil.FileExists(caKeyPath) {
+		gologger.Info().Msgf("Generating self-signed certificate authority in %s", dir)
+		if err := generateCertificate(caPath, caKeyPath, nil, nil, nil); err != nil {
+			return nil, errors.Wrap(err, "could not generate certificate authority")
+		}
+	}
+	ca, err := tls.LoadX509KeyPair(caPath, caKeyPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not load certificate authority")
+	}
+	caCert, err := x509.ParseCertificate(ca.Certificate[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse certificate authority")
+	}
+
+	names := selfSignedNames(domains)
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil || !sameNames(cert, names) || expiresSoon(cert, time.Now()) {
+		gologger.Info().Msgf("Generating self-signed certificate for: [%s]", strings.Join(names, ", "))
+		if err := generateCertificate(certPath, keyPath, names, caCert, ca.PrivateKey); err != nil {
+			return nil, errors.Wrap(err, "could not generate certificate")
+		}
+		if cert, err = tls.LoadX509KeyPair(certPath, keyPath); err != nil {
+			return nil, errors.Wrap(err, "could not load certificate")
+		}
+	}
+	// serve the chain so that clients only need to trust the authority
+	cert.Certificate = append(cert.Certificate, ca.Certificate[0])
+
+	caPEM, err := os.ReadFile(caPath)
+	if err != nil {
+		return nil, err
+	}
+	return &SelfSigned{
+		Certificate:      cert,
+		CertificateFiles: CertificateFiles{CertPath: certPath, PrivKeyPath: keyPath},
+		CA:               caPEM,
+		CAFingerprint:    fingerprint(ca.Certificate[0]),
+		Fingerprint:      fingerprint(cert.Certificate[0]),
+		dir:              dir,
+		domains:          domains,
+	}, nil
+}
+
+// Renew reissues the wildcard certificate if it is about to expire,
+// reporting whether it was renewed
+func (s *SelfSigned) Renew() (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	renewed, err := HandleSelfSignedCertificates(s.dir, s.domains)
+	if err != nil {
+		return false, err
+	}
+	if renewed.Fingerprint == s.Fingerprint {
+		return false, nil
+	}
+	s.Certificate = renewed.Certificate
+	s.Fingerprint = renewed.Fingerprint
+	return true, nil
+}
+
+// GetCertificate returns the current wildcard certificate, it is meant to
+// be used as tls.Config.GetCertificate so that renewals are served
+func (s *SelfSigned) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cert := s.Certificate
+	return &cert, nil
+}
+
+// expiresSoon reports whether a certificate expires within the renewal period
+func expiresSoon(cert tls.Certificate, now time.Time) bool {
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return true
+	}
+	return now.Add(SelfSignedRenewBefore).After(leaf.NotAfter)
+}
+
+// selfSignedNames returns the sorted names covered by the wildcard certificate
+func selfSignedNames(domains []string) []string {
+	var names []string
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+		names = append(names, domain, "*."+domain)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sameNames(cert tls.Certificate, names []string) bool {
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return false
+	}
+	dnsNames := append([]string(nil), leaf.DNSNames...)
+	sort.Strings(dnsNames)
+	return strings.Join(dnsNames, ",") == strings.Join(names, ",")
+}
+
+// generateCertificate writes a new certificate and key, a certificate
+// authority is generated if no parent is provided
+func generateCertificate(certPath, keyPath string, names []string, parent *x509.Certificate, parentKey interface{}) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		NotBefore:    time.Now().Add(-time.Hour),
+	}
+	if parent == nil {
+		template.Subject = pkix.Name{Organization: []string{"Interactsh"}, CommonName: "Interactsh Self-Signed CA"}
+		template.NotAfter = template.NotBefore.AddDate(10, 0, 0)
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+		parent, parentKey = template, key
+	} else {
+		template.Subject = pkix.Name{Organization: []string{"Interactsh"}, CommonName: names[0]}
+		template.DNSNames = names
+		// stay below the maximum validity accepted by clients for leaf certificates
+		template.NotAfter = template.NotBefore.AddDate(0, 0, 397)
+		template.KeyUsage = x509.KeyUsageDigitalSignature
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		return err
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		return err
+	}
+	return os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+}
+
+// fingerprint returns the colon separated SHA256 fingerprint of a certificate
+func fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
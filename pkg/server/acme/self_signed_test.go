@@ -0,0 +1,54 @@
+package acme
+
+import (
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelfSignedCertificates(t *testing.T) {
+	dir := t.TempDir()
+
+	first, err := HandleSelfSignedCertificates(dir, []string{"interact.sh"})
+	require.Nil(t, err)
+	require.NotEmpty(t, first.CA)
+
+	// the certificates are kept across runs
+	second, err := HandleSelfSignedCertificates(dir, []string{"interact.sh"})
+	require.Nil(t, err)
+	require.Equal(t, first.CAFingerprint, second.CAFingerprint)
+	require.Equal(t, first.Fingerprint, second.Fingerprint)
+
+	// the wildcard certificate is reissued by the same authority on domain changes
+	third, err := HandleSelfSignedCertificates(dir, []string{"interact.sh", "oast.pro"})
+	require.Nil(t, err)
+	require.Equal(t, first.CAFingerprint, third.CAFingerprint)
+	require.NotEqual(t, first.Fingerprint, third.Fingerprint)
+
+	leaf, err := x509.ParseCertificate(third.Certificate.Certificate[0])
+	require.Nil(t, err)
+	ca, err := x509.ParseCertificate(third.Certificate.Certificate[1])
+	require.Nil(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	_, err = leaf.Verify(x509.VerifyOptions{DNSName: "abc.oast.pro", Roots: pool})
+	require.Nil(t, err)
+
+	// the wildcard certificate is reissued by the same authority once about to expire
+	renewed, err := third.Renew()
+	require.Nil(t, err)
+	require.False(t, renewed)
+	defer func(before time.Duration) { SelfSignedRenewBefore = before }(SelfSignedRenewBefore)
+	SelfSignedRenewBefore = 398 * 24 * time.Hour
+	renewed, err = third.Renew()
+	require.Nil(t, err)
+	require.True(t, renewed)
+	require.Equal(t, first.CAFingerprint, third.CAFingerprint)
+	served, err := third.GetCertificate(nil)
+	require.Nil(t, err)
+	require.Equal(t, third.Certificate.Certificate[0], served.Certificate[0])
+	require.NotEqual(t, leaf.Raw, served.Certificate[0])
+	require.Equal(t, ca.Raw, served.Certificate[1])
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/interactsh/pkg/storage"
+)
+
+// SessionRequest is an admin request targeting a session
+type SessionRequest struct {
+	// CorrelationID is the correlation id of the session
+	CorrelationID string `json:"correlation-id"`
+}
+
+// SessionsResponse is the response to admin session listings
+type SessionsResponse struct {
+	Sessions []*storage.SessionSummary `json:"sessions"`
+}
+
+// BansResponse is the response to admin ban listings
+type BansResponse struct {
+	Bans []string `json:"bans"`
+}
+
+// SourcesResponse is the response to admin source listings
+type SourcesResponse struct {
+	// Window is the time window in which the protocols of a source are aggregated
+	Window  string        `json:"window"`
+	Sources []SourceStats `json:"sources"`
+}
+
+// adminRoutes registers the admin api handlers wrapped with the given middleware
+func (h *HTTPServer) adminRoutes(router *http.ServeMux, wrap func(http.Handler) http.Handler) {
+	router.Handle("/admin/domains", wrap(http.HandlerFunc(h.domainsHandler)))
+	router.Handle("/admin/sessions", wrap(http.HandlerFunc(h.sessionsHandler)))
+	router.Handle("/admin/bans", wrap(http.HandlerFunc(h.bansHandler)))
+	router.Handle("/admin/stats", wrap(http.HandlerFunc(h.metricsHandler)))
+	router.Handle("/admin/sources", wrap(http.HandlerFunc(h.sourcesHandler)))
+	router.Handle("/admin/config", wrap(http.HandlerFunc(h.configHandler)))
+	router.Handle("/admin/reload", wrap(http.HandlerFunc(h.reloadHandler)))
+}
+
+// ServeAdminSocket serves the admin api on a unix socket only accessible to
+// the server user, requests on it are not authenticated
+func (h *HTTPServer) ServeAdminSocket(path string) error {
+	// a socket left by a previous run prevents listening
+	_ = os.Remove(path)
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		_ = listener.Close()
+		return err
+	}
+	router := &http.ServeMux{}
+	h.adminRoutes(router, func(handler http.Handler) http.Handler { return handler })
+	h.adminserver = &http.Server{Handler: router}
+	go func() {
+		if err := h.adminserver.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			gologger.Error().Msgf("Could not serve admin socket: %s\n", err)
+		}
+	}()
+	return nil
+}
+
+// CloseAdminSocket stops serving the admin socket
+func (h *HTTPServer) CloseAdminSocket() {
+	if h.adminserver != nil {
+		_ = h.adminserver.Close()
+	}
+}
+
+// sessionsHandler is a handler to list and evict sessions
+func (h *HTTPServer) sessionsHandler(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		_ = jsoniter.NewEncoder(w).Encode(&SessionsResponse{Sessions: h.options.Storage.Sessions()})
+	case http.MethodDelete:
+		r := &SessionRequest{}
+		if err := jsoniter.NewDecoder(req.Body).Decode(r); err != nil {
+			jsonError(w, fmt.Sprintf("could not decode json body: %s", err), http.StatusBadRequest)
+			return
+		}
+		if err := h.options.Storage.Evict(r.CorrelationID); errors.Is(err, storage.ErrCorrelationIdNotFound) {
+			jsonError(w, err.Error(), http.StatusNotFound)
+			return
+		} else if err != nil {
+			jsonError(w, fmt.Sprintf("could not evict session: %s", err), http.StatusBadRequest)
+			return
+		}
+		jsonMsg(w, "session evicted", http.StatusOK)
+		gologger.Info().Msgf("Evicted session %s\n", r.CorrelationID)
+	default:
+		jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// bansHandler is a handler to list, ban and unban correlation ids
+func (h *HTTPServer) bansHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		_ = jsoniter.NewEncoder(w).Encode(&BansResponse{Bans: h.options.Storage.Bans()})
+		return
+	}
+
+	r := &SessionRequest{}
+	if err := jsoniter.NewDecoder(req.Body).Decode(r); err != nil {
+		jsonError(w, fmt.Sprintf("could not decode json body: %s", err), http.StatusBadRequest)
+		return
+	}
+	switch req.Method {
+	case http.MethodPost:
+		if err := h.options.Storage.Ban(r.CorrelationID); err != nil {
+			jsonError(w, fmt.Sprintf("could not ban correlation id: %s", err), http.StatusBadRequest)
+			return
+		}
+		jsonMsg(w, "correlation id banned", http.StatusOK)
+		gologger.Info().Msgf("Banned correlation id %s\n", r.CorrelationID)
+	case http.MethodDelete:
+		if err := h.options.Storage.Unban(r.CorrelationID); err != nil {
+			jsonError(w, fmt.Sprintf("could not unban correlation id: %s", err), http.StatusBadRequest)
+			return
+		}
+		jsonMsg(w, "correlation id unbanned", http.StatusOK)
+		gologger.Info().Msgf("Unbanned correlation id %s\n", r.CorrelationID)
+	default:
+		jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// sourcesHandler is a handler returning the protocols attempted by each source within the window
+func (h *HTTPServer) sourcesHandler(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = jsoniter.NewEncoder(w).Encode(&SourcesResponse{
+		Window:  SourceWindow.String(),
+		Sources: h.options.Stats.Sources.Snapshot(time.Now()),
+	})
+}
+
+// configHandler is a handler returning the sanitized server configuration
+func (h *HTTPServer) configHandler(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = jsoniter.NewEncoder(w).Encode(h.options.Config)
+}
+
+// reloadHandler is a handler reloading the server options
+func (h *HTTPServer) reloadHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.options.OnReload == nil {
+		jsonError(w, "reload is not supported", http.StatusNotImplemented)
+		return
+	}
+	response, err := h.options.OnReload()
+	if err != nil {
+		jsonError(w, fmt.Sprintf("could not reload: %s", err), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = jsoniter.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminSocket(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bi3tab0")
+	options.Token = "secret"
+	options.Config = map[string]interface{}{"domains": []string{"interact.sh"}}
+	store := options.Storage
+
+	server, err := NewHTTPServer(options)
+	require.Nil(t, err)
+
+	socket := filepath.Join(t.TempDir(), "admin.sock")
+	require.Nil(t, server.ServeAdminSocket(socket))
+	defer server.CloseAdminSocket()
+	info, err := os.Stat(socket)
+	require.Nil(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	resp, err := client.Get("http://admin/admin/sessions")
+	require.Nil(t, err)
+	sessions := &SessionsResponse{}
+	require.Nil(t, jsoniter.NewDecoder(resp.Body).Decode(sessions))
+	_ = resp.Body.Close()
+	require.Len(t, sessions.Sessions, 1)
+	require.Equal(t, "c59e3crp82ke7bi3tab0", sessions.Sessions[0].CorrelationID)
+
+	resp, err = client.Post("http://admin/admin/bans", "application/json", strings.NewReader(`{"correlation-id":"c59e3crp82ke7bi3tab0"}`))
+	require.Nil(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, []string{"c59e3crp82ke7bi3tab0"}, store.Bans())
+	require.Empty(t, store.Sessions())
+
+	resp, err = client.Get("http://admin/admin/config")
+	require.Nil(t, err)
+	config := map[string]interface{}{}
+	require.Nil(t, jsoniter.NewDecoder(resp.Body).Decode(&config))
+	_ = resp.Body.Close()
+	require.Contains(t, config, "domains")
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+var (
+	// MaxBodyParts is the number of multipart parts recorded of a request body
+	MaxBodyParts = 64
+	// MaxBodyPartHead is the number of bytes recorded of each multipart part
+	MaxBodyPartHead = 256
+	// MaxBodyJSON is the size in bytes of the json bodies decoded
+	MaxBodyJSON = 64 * 1024
+)
+
+// BodyPart is a part of a multipart request body
+type BodyPart struct {
+	// Name is the form field name of the part
+	Name string `json:"name,omitempty"`
+	// FileName is the name of an uploaded file
+	FileName string `json:"file-name,omitempty"`
+	// ContentType is the content type of the part
+	ContentType string `json:"content-type,omitempty"`
+	// Size is the size in bytes of the part, as captured
+	Size int64 `json:"size"`
+	// Head is the start of the part, base64 encoded in json
+	Head []byte `json:"head,omitempty"`
+}
+
+// structuredBody is the decoded multipart or json body of a request
+type structuredBody struct {
+	parts []BodyPart
+	json  jsoniter.RawMessage
+}
+
+// parseStructuredBody decodes the multipart parts or the json document of a
+// request body, nil if the body is neither
+func parseStructuredBody(r *http.Request, body []byte) *structuredBody {
+	if len(body) == 0 {
+		return nil
+	}
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "" {
+		if parts := parseMultipartBody(body, params["boundary"]); len(parts) > 0 {
+			return &structuredBody{parts: parts}
+		}
+		return nil
+	}
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 || len(trimmed) > MaxBodyJSON || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return nil
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, trimmed); err != nil {
+		return nil
+	}
+	return &structuredBody{json: compact.Bytes()}
+}
+
+// parseMultipartBody returns the parts of a multipart body, up to the
+// truncated part of a captured body
+func parseMultipartBody(body []byte, boundary string) []BodyPart {
+	reader := multipart.NewReader(bytes.NewReader(body), boundary)
+	var parts []BodyPart
+	for len(parts) < MaxBodyParts {
+		part, err := reader.NextRawPart()
+		if err != nil {
+			break
+		}
+		head := &bytes.Buffer{}
+		size, _ := io.Copy(&limitedWriter{w: head, n: MaxBodyPartHead}, part)
+		parts = append(parts, BodyPart{
+			Name:        part.FormName(),
+			FileName:    part.FileName(),
+			ContentType: part.Header.Get("Content-Type"),
+			Size:        size,
+			Head:        head.Bytes(),
+		})
+	}
+	return parts
+}
+
+// limitedWriter writes up to n bytes, discarding the rest
+type limitedWriter struct {
+	w io.Writer
+	n int
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if l.n > 0 {
+		chunk := p[:min(len(p), l.n)]
+		_, _ = l.w.Write(chunk)
+		l.n -= len(chunk)
+	}
+	return len(p), nil
+}
+
+// marker is empty as multipart and json bodies are readable in the raw request
+func (b *structuredBody) marker() string {
+	return ""
+}
+
+// apply sets the structured body fields of the interaction
+func (b *structuredBody) apply(interaction *Interaction) {
+	if b == nil {
+		return
+	}
+	interaction.BodyParts = b.parts
+	interaction.BodyJSON = b.json
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStructuredBody(t *testing.T) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	_ = writer.WriteField("user", "admin")
+	file, _ := writer.CreateFormFile("upload", "shell.php")
+	_, _ = file.Write(bytes.Repeat([]byte("<?php system($_GET['c']); ?>"), 20))
+	_ = writer.Close()
+
+	request := httptest.NewRequest(http.MethodPost, "/upload", nil)
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	structured := parseStructuredBody(request, body.Bytes())
+	require.NotNil(t, structured)
+	require.Len(t, structured.parts, 2)
+	require.Equal(t, BodyPart{Name: "user", Size: 5, Head: []byte("admin")}, structured.parts[0])
+	require.Equal(t, "shell.php", structured.parts[1].FileName)
+	require.Equal(t, "application/octet-stream", structured.parts[1].ContentType)
+	require.EqualValues(t, 560, structured.parts[1].Size)
+	require.Len(t, structured.parts[1].Head, MaxBodyPartHead)
+
+	// the parts of a truncated body are recorded up to the truncation
+	structured = parseStructuredBody(request, body.Bytes()[:body.Len()/2])
+	require.NotNil(t, structured)
+	require.Equal(t, "user", structured.parts[0].Name)
+
+	request = httptest.NewRequest(http.MethodPost, "/api", nil)
+	structured = parseStructuredBody(request, []byte("{\n  \"url\": \"http://169.254.169.254/\",\n  \"id\": 12345678901234567890\n}"))
+	require.NotNil(t, structured)
+	interaction := &Interaction{}
+	structured.apply(interaction)
+	require.Equal(t, `{"url":"http://169.254.169.254/","id":12345678901234567890}`, string(interaction.BodyJSON))
+
+	require.Nil(t, parseStructuredBody(request, []byte("{not json")))
+	require.Nil(t, parseStructuredBody(request, []byte("a=1&b=2")))
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/gologger"
+)
+
+// bodyID matches the hex encoded sha256 digests naming the stored bodies
+var bodyID = regexp.MustCompile(`^[a-f0-9]{64}$`)
+
+// BodyStore keeps on disk the http bodies larger than the capture size, per
+// correlation id, so that they are streamed instead of being held in memory
+// and can be retrieved by the session owner
+type BodyStore struct {
+	dir     string
+	maxSize int64
+}
+
+// NewBodyStore returns a body store in the directory keeping up to maxSize
+// bytes of each body, removing the partial bodies of a previous run
+func NewBodyStore(dir string, maxSize int64) (*BodyStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	partials, _ := filepath.Glob(filepath.Join(dir, ".spill-*"))
+	for _, partial := range partials {
+		_ = os.Remove(partial)
+	}
+	return &BodyStore{dir: dir, maxSize: maxSize}, nil
+}
+
+// newCapture returns a body capture keeping limit bytes in memory, the whole
+// body being spilled to the store once larger
+func (s *BodyStore) newCapture(limit int) *bodyCapture {
+	capture := newBodyCapture(limit)
+	if s != nil && limit > 0 {
+		capture.store = s
+	}
+	return capture
+}
+
+// spill returns a new file for a body being captured
+func (s *BodyStore) spill() (*os.File, error) {
+	return os.CreateTemp(s.dir, ".spill-*")
+}
+
+// persist links the spilled body of a capture to the bodies of the correlation
+// id, setting the stored size of the interaction
+func (s *BodyStore) persist(correlationID string, capture *bodyCapture, interaction *Interaction) {
+	if s == nil || capture.spill == nil {
+		return
+	}
+	dir := filepath.Join(s.dir, correlationID)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		gologger.Warning().Msgf("Could not create body directory for %s: %s\n", correlationID, err)
+		return
+	}
+	name := filepath.Join(dir, capture.Sum())
+	// identical bodies are stored once
+	if err := os.Link(capture.spill.Name(), name); err != nil && !errors.Is(err, os.ErrExist) {
+		gologger.Warning().Msgf("Could not store body for %s: %s\n", correlationID, err)
+		return
+	}
+	interaction.BodyStored = capture.spilled
+}
+
+// open returns a stored body of the correlation id
+func (s *BodyStore) open(correlationID, id string) (*os.File, error) {
+	if !bodyID.MatchString(id) {
+		return nil, errors.New("invalid body id")
+	}
+	return os.Open(filepath.Join(s.dir, correlationID, id))
+}
+
+// remove deletes the stored bodies of a correlation id
+func (s *BodyStore) remove(correlationID string) {
+	if s == nil || correlationID == "" || strings.ContainsAny(correlationID, `/\.`) {
+		return
+	}
+	_ = os.RemoveAll(filepath.Join(s.dir, correlationID))
+}
+
+// Sweep deletes the stored bodies of the correlation ids no longer registered
+func (s *BodyStore) Sweep(registered func(correlationID string) bool) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && !registered(entry.Name()) {
+			s.remove(entry.Name())
+		}
+	}
+}
+
+// BodyRequest is a request for a body stored on disk of a session interaction
+type BodyRequest struct {
+	// CorrelationID is an ID for correlation with requests.
+	CorrelationID string `json:"correlation-id"`
+	// SecretKey is the secretKey of the session owner or of a reader.
+	SecretKey string `json:"secret-key"`
+	// ID is the body-sha256 of the interaction.
+	ID string `json:"id"`
+}
+
+// bodyHandler is a handler streaming the stored bodies of a session
+func (h *HTTPServer) bodyHandler(w http.ResponseWriter, req *http.Request) {
+	r := &BodyRequest{}
+	if err := jsoniter.NewDecoder(req.Body).Decode(r); err != nil {
+		gologger.Warning().Msgf("Could not decode json body: %s\n", err)
+		jsonError(w, fmt.Sprintf("could not decode json body: %s", err), http.StatusBadRequest)
+		return
+	}
+	correlationID := strings.ToLower(r.CorrelationID)
+	item, err := h.options.Storage.GetCacheItem(correlationID)
+	if err != nil || !item.ReadableBy(r.SecretKey) {
+		jsonError(w, "invalid correlation id or secret key", http.StatusUnauthorized)
+		return
+	}
+	file, err := h.options.BodyStore.open(correlationID, r.ID)
+	if err != nil {
+		jsonError(w, "body not found", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if info, err := file.Stat(); err == nil {
+		w.Header().Set("Content-Length", fmt.Sprint(info.Size()))
+	}
+	_, _ = io.Copy(w, file)
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBodyStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewBodyStore(dir, 64)
+	require.Nil(t, err)
+
+	body := bytes.Repeat([]byte("0123456789"), 10)
+	capture := store.newCapture(16)
+	for i := 0; i < len(body); i += 7 {
+		_, _ = capture.Write(body[i:min(i+7, len(body))])
+	}
+	require.True(t, capture.Truncated())
+	require.Len(t, capture.Bytes(), 16)
+
+	interaction := &Interaction{}
+	store.persist("c59e3crp82ke7bcnedq0", capture, interaction)
+	capture.close()
+	require.EqualValues(t, 64, interaction.BodyStored)
+	partials, _ := filepath.Glob(filepath.Join(dir, ".spill-*"))
+	require.Empty(t, partials)
+
+	data, err := os.ReadFile(filepath.Join(dir, "c59e3crp82ke7bcnedq0", capture.Sum()))
+	require.Nil(t, err)
+	require.Equal(t, body[:64], data)
+
+	// bodies within the capture size aren't spilled
+	small := store.newCapture(1024)
+	_, _ = small.Write(body)
+	require.Nil(t, small.spill)
+
+	// the stored bodies are served to the session owner
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0")
+	db := options.Storage
+	item, err := db.GetCacheItem("c59e3crp82ke7bcnedq0")
+	require.Nil(t, err)
+	item.SecretKey = "secret"
+	options.BodyStore = store
+	server, err := NewHTTPServer(options)
+	require.Nil(t, err)
+
+	recorder := httptest.NewRecorder()
+	server.bodyHandler(recorder, httptest.NewRequest(http.MethodPost, "/body", strings.NewReader(`{"correlation-id":"c59e3crp82ke7bcnedq0","secret-key":"secret","id":"`+capture.Sum()+`"}`)))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, body[:64], recorder.Body.Bytes())
+	recorder = httptest.NewRecorder()
+	server.bodyHandler(recorder, httptest.NewRequest(http.MethodPost, "/body", strings.NewReader(`{"correlation-id":"c59e3crp82ke7bcnedq0","secret-key":"other","id":"`+capture.Sum()+`"}`)))
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+	recorder = httptest.NewRecorder()
+	server.bodyHandler(recorder, httptest.NewRequest(http.MethodPost, "/body", strings.NewReader(`{"correlation-id":"c59e3crp82ke7bcnedq0","secret-key":"secret","id":"../../etc/passwd"}`)))
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+
+	store.Sweep(func(string) bool { return false })
+	_, err = os.Stat(filepath.Join(dir, "c59e3crp82ke7bcnedq0"))
+	require.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"os"
+
+	"github.com/projectdiscovery/gologger"
+)
+
+// bodyCapture streams a request body computing its total size and sha256
+// digest while keeping only the first limit bytes in memory.
+type bodyCapture struct {
+	limit int
+	head  bytes.Buffer
+	hash  hash.Hash
+	size  int64
+
+	// store receives the whole body once larger than the limit, if any
+	store   *BodyStore
+	spill   *os.File
+	spilled int64
+}
+
+// newBodyCapture returns a new body capture keeping up to limit bytes,
+// a limit lower or equal to zero keeps the whole body.
+func newBodyCapture(limit int) *bodyCapture {
+	return &bodyCapture{limit: limit, hash: sha256.New()}
+}
+
+// Write implements io.Writer
+func (c *bodyCapture) Write(p []byte) (int, error) {
+	c.size += int64(len(p))
+	_, _ = c.hash.Write(p)
+	remaining := len(p)
+	if c.limit > 0 {
+		remaining = c.limit - c.head.Len()
+	}
+	if remaining > len(p) {
+		remaining = len(p)
+	}
+	if remaining > 0 {
+		c.head.Write(p[:remaining])
+	}
+	if remaining < 0 {
+		remaining = 0
+	}
+	if c.store != nil && remaining < len(p) {
+		c.spillWrite(p[remaining:])
+	}
+	return len(p), nil
+}
+
+// spillWrite writes the part of the body exceeding the limit to the store,
+// starting with the captured head
+func (c *bodyCapture) spillWrite(p []byte) {
+	if c.spill == nil {
+		spill, err := c.store.spill()
+		if err != nil {
+			gologger.Warning().Msgf("Could not spill body to disk: %s\n", err)
+			c.store = nil
+			return
+		}
+		c.spill = spill
+		c.spillBytes(c.head.Bytes())
+	}
+	if c.spill != nil {
+		c.spillBytes(p)
+	}
+}
+
+// spillBytes writes to the spilled body up to the maximum size of the store
+func (c *bodyCapture) spillBytes(p []byte) {
+	if c.store.maxSize > 0 && c.spilled+int64(len(p)) > c.store.maxSize {
+		p = p[:max(c.store.maxSize-c.spilled, 0)]
+	}
+	n, err := c.spill.Write(p)
+	c.spilled += int64(n)
+	if err != nil {
+		gologger.Warning().Msgf("Could not spill body to disk: %s\n", err)
+		c.close()
+		c.spill, c.store = nil, nil
+	}
+}
+
+// close removes the spilled body, kept only if linked to correlation ids
+func (c *bodyCapture) close() {
+	if c.spill == nil {
+		return
+	}
+	_ = c.spill.Close()
+	_ = os.Remove(c.spill.Name())
+}
+
+// Bytes returns the captured part of the body
+func (c *bodyCapture) Bytes() []byte {
+	return c.head.Bytes()
+}
+
+// Truncated reports whether the body exceeded the capture limit
+func (c *bodyCapture) Truncated() bool {
+	return c != nil && c.size > int64(c.head.Len())
+}
+
+// Sum returns the hex encoded sha256 digest of the full body
+func (c *bodyCapture) Sum() string {
+	return hex.EncodeToString(c.hash.Sum(nil))
+}
+
+// marker returns the partial capture marker appended to truncated raw requests
+func (c *bodyCapture) marker() string {
+	if !c.Truncated() {
+		return ""
+	}
+	return fmt.Sprintf("\n[truncated: captured %d of %d bytes, sha256 %s]", c.head.Len(), c.size, c.Sum())
+}
+
+// apply sets the partial capture fields of a truncated interaction
+func (c *bodyCapture) apply(interaction *Interaction) {
+	if !c.Truncated() {
+		return
+	}
+	interaction.Truncated = true
+	interaction.BodySize = c.size
+	interaction.BodySHA256 = c.Sum()
+}
+
+// captureLimit returns the body capture limit in bytes
+func (options *Options) captureLimit() int {
+	return options.BodyCaptureSize * 1024
+}
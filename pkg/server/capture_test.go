@@ -0,0 +1,68 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBodyCapture(t *testing.T) {
+	capture := newBodyCapture(4)
+	_, _ = capture.Write([]byte("ab"))
+	_, _ = capture.Write([]byte("cdef"))
+	require.Equal(t, "abcd", string(capture.Bytes()))
+	require.True(t, capture.Truncated())
+
+	sum := sha256.Sum256([]byte("abcdef"))
+	interaction := &Interaction{}
+	capture.apply(interaction)
+	require.True(t, interaction.Truncated)
+	require.EqualValues(t, 6, interaction.BodySize)
+	require.Equal(t, hex.EncodeToString(sum[:]), interaction.BodySHA256)
+
+	capture = newBodyCapture(0)
+	_, _ = capture.Write([]byte("abcdef"))
+	require.False(t, capture.Truncated())
+	require.Empty(t, capture.marker())
+}
+
+func TestHTTPBodyCapture(t *testing.T) {
+	options := newTestOptions(t, "interact.sh")
+	options.RootTLD = true
+	options.BodyCaptureSize = 1
+	store := options.Storage
+
+	server, err := NewHTTPServer(options)
+	require.Nil(t, err)
+
+	body := strings.Repeat("A", 4096)
+	req := httptest.NewRequest("POST", "http://interact.sh/", strings.NewReader(body))
+	server.logger(http.HandlerFunc(server.defaultHandler)).ServeHTTP(httptest.NewRecorder(), req)
+
+	item, err := store.GetCacheItem("interact.sh")
+	require.Nil(t, err)
+	require.Len(t, item.Data, 1)
+
+	interaction := &Interaction{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[0]), interaction))
+	require.True(t, interaction.Truncated)
+	require.EqualValues(t, 4096, interaction.BodySize)
+	require.Contains(t, interaction.RawRequest, "[truncated: captured 1024 of 4096 bytes")
+	require.NotContains(t, interaction.RawRequest, strings.Repeat("A", 1025))
+
+	// the handlers read the whole body beyond the capture
+	var read []byte
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		read, _ = io.ReadAll(r.Body)
+	})
+	req = httptest.NewRequest("POST", "http://interact.sh/register", strings.NewReader(body))
+	server.logger(handler).ServeHTTP(httptest.NewRecorder(), req)
+	require.Equal(t, body, string(read))
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// scannerSignature identifies a fingerprint probe of a network scanner
+type scannerSignature struct {
+	// scanner is the name of the scanner (eg. nmap, masscan)
+	scanner string
+	// probe is the name of the probe, as in nmap-service-probes when sent by nmap
+	probe string
+	// udp is set for the probes sent as datagrams
+	udp bool
+	// exact, prefix and contains match the whole payload, its start or a part of it
+	exact, prefix, contains string
+}
+
+// scannerSignatures are the known probes, the more specific first
+var scannerSignatures = []scannerSignature{
+	{scanner: "nmap", probe: "FourOhFourRequest", contains: "/nice%20ports%2C/Tri%6Eity.txt%2ebak"},
+	{scanner: "nmap", probe: "NSE", contains: "Nmap Scripting Engine"},
+	{scanner: "masscan", probe: "Banner", contains: "masscan/"},
+	{scanner: "zgrab", probe: "Banner", contains: "zgrab/"},
+	{scanner: "nmap", probe: "GetRequest", exact: "GET / HTTP/1.0\r\n\r\n"},
+	{scanner: "nmap", probe: "HTTPOptions", exact: "OPTIONS / HTTP/1.0\r\n\r\n"},
+	{scanner: "nmap", probe: "RTSPRequest", exact: "OPTIONS / RTSP/1.0\r\n\r\n"},
+	{scanner: "nmap", probe: "GenericLines", exact: "\r\n\r\n"},
+	{scanner: "nmap", probe: "Help", exact: "HELP\r\n"},
+	{scanner: "nmap", probe: "RPCCheck", prefix: "\x80\x00\x00\x28\x72\xfe\x1d\x13"},
+	{scanner: "nmap", probe: "DNSVersionBindReqTCP", prefix: "\x00\x1e\x00\x06\x01\x00\x00\x01\x00\x00\x00\x00\x00\x00\x07version\x04bind"},
+	{scanner: "nmap", probe: "DNSStatusRequestTCP", exact: "\x00\x0c\x00\x00\x10\x00\x00\x00\x00\x00\x00\x00\x00\x00"},
+	{scanner: "nmap", probe: "X11Probe", exact: "l\x00\x0b\x00\x00\x00\x00\x00\x00\x00\x00\x00"},
+	{scanner: "nmap", probe: "SMBProgNeg", prefix: "\x00\x00\x00\xa4\xff\x53\x4d\x42\x72"},
+	{scanner: "nmap", probe: "DNSVersionBindReq", udp: true, prefix: "\x00\x06\x01\x00\x00\x01\x00\x00\x00\x00\x00\x00\x07version\x04bind"},
+	{scanner: "nmap", probe: "DNSStatusRequest", udp: true, exact: "\x00\x00\x10\x00\x00\x00\x00\x00\x00\x00\x00\x00"},
+	{scanner: "nmap", probe: "NTPRequest", udp: true, prefix: "\xe3\x00\x04\xfa\x00\x01\x00\x00\x00\x01\x00\x00"},
+	{scanner: "nmap", probe: "SNMPv1public", udp: true, prefix: "\x30\x82\x00\x2f\x02\x01\x00\x04\x06public\xa0"},
+	{scanner: "nmap", probe: "SNMPv3GetRequest", udp: true, prefix: "\x30\x3a\x02\x01\x03\x30\x0f\x02\x02\x4a\x69"},
+}
+
+// matchScannerProbe returns the scanner and probe (eg. nmap:GetRequest) of the
+// first signature matching a payload received over tcp or udp, or an empty
+// string. The signatures matching a part of the payload apply to both.
+func matchScannerProbe(payload []byte, udp bool) string {
+	for _, signature := range scannerSignatures {
+		if signature.udp != udp && signature.contains == "" {
+			continue
+		}
+		switch {
+		case signature.exact != "" && string(payload) == signature.exact,
+			signature.prefix != "" && bytes.HasPrefix(payload, []byte(signature.prefix)),
+			signature.contains != "" && bytes.Contains(payload, []byte(signature.contains)):
+			return signature.scanner + ":" + signature.probe
+		}
+	}
+	return ""
+}
+
+// DeceptionProfile holds the fake banners and answers sent by the catch-all
+// servers to the fingerprint probes of network scanners
+type DeceptionProfile struct {
+	// Banners are the greetings sent by the tcp catch-all server as soon as a
+	// client connects, by port, like the ssh, ftp or smtp servers do
+	Banners map[int]string `yaml:"banners"`
+	// DefaultBanner is the greeting sent on the ports without banner, if any
+	DefaultBanner string `yaml:"default-banner"`
+	// Responses are the answers to the recognized probes by name (eg.
+	// nmap:GetRequest), or by scanner name for all its probes (eg. masscan)
+	Responses map[string]string `yaml:"responses"`
+}
+
+// NewDeceptionProfile loads a yaml or json deception profile
+func NewDeceptionProfile(path string) (*DeceptionProfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	profile := &DeceptionProfile{}
+	if err := yaml.Unmarshal(data, profile); err != nil {
+		return nil, err
+	}
+	for port := range profile.Banners {
+		if port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid banner port %d", port)
+		}
+	}
+	return profile, nil
+}
+
+// banner returns the greeting of a port, the profile may be nil
+func (p *DeceptionProfile) banner(port int) string {
+	if p == nil {
+		return ""
+	}
+	if banner, ok := p.Banners[port]; ok {
+		return banner
+	}
+	return p.DefaultBanner
+}
+
+// response returns the answer to a recognized probe, the profile may be nil
+func (p *DeceptionProfile) response(probe string) string {
+	if p == nil || probe == "" {
+		return ""
+	}
+	if response, ok := p.Responses[probe]; ok {
+		return response
+	}
+	scanner, _, _ := strings.Cut(probe, ":")
+	return p.Responses[scanner]
+}
+
+// scannerProbe is a scanner probe recognized in a http request
+type scannerProbe string
+
+// parseScannerProbe returns the scanner probe matching a dumped http request
+func parseScannerProbe(dump []byte) scannerProbe {
+	return scannerProbe(matchScannerProbe(dump, false))
+}
+
+func (s scannerProbe) marker() string {
+	return ""
+}
+
+// apply tags the interaction with the scanner probe
+func (s scannerProbe) apply(interaction *Interaction) {
+	if s != "" {
+		interaction.ScannerProbe = string(s)
+	}
+}
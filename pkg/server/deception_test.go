@@ -0,0 +1,51 @@
+package server
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchScannerProbe(t *testing.T) {
+	require.Equal(t, "nmap:GetRequest", matchScannerProbe([]byte("GET / HTTP/1.0\r\n\r\n"), false))
+	require.Equal(t, "nmap:FourOhFourRequest", matchScannerProbe([]byte("GET /nice%20ports%2C/Tri%6Eity.txt%2ebak HTTP/1.0\r\n\r\n"), false))
+	require.Equal(t, "masscan:Banner", matchScannerProbe([]byte("GET / HTTP/1.0\r\nUser-Agent: masscan/1.3 (https://github.com/robertdavidgraham/masscan)\r\n\r\n"), false))
+	require.Equal(t, "nmap:DNSStatusRequest", matchScannerProbe([]byte("\x00\x00\x10\x00\x00\x00\x00\x00\x00\x00\x00\x00"), true))
+	// the datagram probes are not matched over tcp
+	require.Empty(t, matchScannerProbe([]byte("\x00\x00\x10\x00\x00\x00\x00\x00\x00\x00\x00\x00"), false))
+	require.Empty(t, matchScannerProbe([]byte("GET / HTTP/1.1\r\nHost: interact.sh\r\n\r\n"), false))
+}
+
+func TestDeceptionProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deception.yaml")
+	require.Nil(t, os.WriteFile(path, []byte(`banners:
+  2222: "SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.6\r\n"
+default-banner: "220 ProFTPD Server ready.\r\n"
+responses:
+  nmap:GetRequest: "HTTP/1.1 200 OK\r\nServer: Apache/2.4.57\r\n\r\n"
+  nmap: "500 Syntax error\r\n"
+`), 0o600))
+	profile, err := NewDeceptionProfile(path)
+	require.Nil(t, err)
+	require.Equal(t, "SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.6\r\n", profile.banner(2222))
+	require.Equal(t, "500 Syntax error\r\n", profile.response("nmap:Help"))
+
+	server, err := NewTCPServer(&Options{Stats: &Metrics{}, DeceptionProfile: profile, CorrelationIdLength: 20, CorrelationIdNonceLength: 13})
+	require.Nil(t, err)
+	client, conn := net.Pipe()
+	go server.handleConn(conn, nil)
+
+	banner := make([]byte, len(profile.DefaultBanner))
+	_, err = io.ReadFull(client, banner)
+	require.Nil(t, err)
+	require.Equal(t, profile.DefaultBanner, string(banner))
+	_, err = client.Write([]byte("GET / HTTP/1.0\r\n\r\n"))
+	require.Nil(t, err)
+	response, err := io.ReadAll(client)
+	require.Nil(t, err)
+	require.Equal(t, "HTTP/1.1 200 OK\r\nServer: Apache/2.4.57\r\n\r\n", string(response))
+}
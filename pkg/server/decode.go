@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	// DecodeMinLength is the minimum length of the values decoded, shorter
+	// values decode to printable strings too often to be meaningful
+	DecodeMinLength = 8
+	// DecodeMaxCandidates is the maximum number of decoded candidates of an interaction
+	DecodeMaxCandidates = 16
+)
+
+// DecodedCandidate is a printable string decoded from a part of an interaction
+type DecodedCandidate struct {
+	// Source is the encoded value
+	Source string `json:"source"`
+	// Encoding is the encoding of the value (hex, base32, base64 or base64url)
+	Encoding string `json:"encoding"`
+	// Value is the decoded value
+	Value string `json:"value"`
+}
+
+// payloadDecoders are the encodings attempted on values, the ones surviving
+// the dns case folding first
+var payloadDecoders = []struct {
+	encoding string
+	decode   func(string) ([]byte, error)
+}{
+	{"hex", func(value string) ([]byte, error) { return hex.DecodeString(strings.ToLower(value)) }},
+	{"base32", func(value string) ([]byte, error) {
+		return base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(strings.TrimRight(value, "=")))
+	}},
+	{"base64", func(value string) ([]byte, error) {
+		return base64.RawStdEncoding.DecodeString(strings.TrimRight(value, "="))
+	}},
+	{"base64url", func(value string) ([]byte, error) {
+		return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	}},
+}
+
+// decodeCandidates attempts to decode the values with the common payload
+// encodings, returning the ones decoding to printable strings
+func decodeCandidates(values ...string) []DecodedCandidate {
+	var candidates []DecodedCandidate
+	seen := make(map[string]struct{})
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if len(value) < DecodeMinLength {
+			continue
+		}
+		if _, ok := seen[value]; ok {
+			continue
+		}
+		seen[value] = struct{}{}
+		for _, decoder := range payloadDecoders {
+			decoded, err := decoder.decode(value)
+			if err != nil || !isPrintable(decoded) {
+				continue
+			}
+			candidates = append(candidates, DecodedCandidate{Source: value, Encoding: decoder.encoding, Value: string(decoded)})
+			if len(candidates) >= DecodeMaxCandidates {
+				return candidates
+			}
+			break
+		}
+	}
+	return candidates
+}
+
+// isPrintable reports whether the data is a printable utf-8 string
+func isPrintable(data []byte) bool {
+	if len(data) == 0 || !utf8.Valid(data) {
+		return false
+	}
+	for _, r := range string(data) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// labelValues returns the dns labels to decode, individually and joined
+// as long values are split across labels
+func labelValues(labels []string) []string {
+	values := append([]string{}, labels...)
+	if len(labels) > 1 {
+		values = append(values, strings.Join(labels, ""))
+	}
+	return values
+}
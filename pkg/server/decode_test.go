@@ -0,0 +1,32 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeCandidates(t *testing.T) {
+	candidates := decodeCandidates("726f6f743a783a30", "OJXW65B2PA5DA===", "cm9vdDp4OjA=", "cm9vdD94Pz8_", "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy", "short", "726f6f743a783a30")
+	require.Equal(t, []DecodedCandidate{
+		{Source: "726f6f743a783a30", Encoding: "hex", Value: "root:x:0"},
+		{Source: "OJXW65B2PA5DA===", Encoding: "base32", Value: "root:x:0"},
+		{Source: "cm9vdDp4OjA=", Encoding: "base64", Value: "root:x:0"},
+		{Source: "cm9vdD94Pz8_", Encoding: "base64url", Value: "root?x???"},
+	}, candidates)
+
+	candidates = decodeCandidates(labelValues([]string{"nbswy3dpeb3w64tm", "mqqgc3tporugk4q"})...)
+	require.Len(t, candidates, 3)
+	require.Equal(t, "hello worl", candidates[0].Value)
+	require.Equal(t, "d another", candidates[1].Value)
+	require.Equal(t, "hello world another", candidates[2].Value)
+}
+
+func TestRequestCandidates(t *testing.T) {
+	req := httptest.NewRequest("GET", "http://interact.sh/x/aWQ9MChyb290KQ==?q=dWlkPTAocm9vdCk%3D&a=b", nil)
+	candidates := requestCandidates(req, []string{"6964"})
+	require.Len(t, candidates, 2)
+	require.Equal(t, "id=0(root)", candidates[0].Value)
+	require.Equal(t, "uid=0(root)", candidates[1].Value)
+}
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/miekg/dns"
+	"gopkg.in/yaml.v3"
+)
+
+// dnsRecordsOrigin is the origin the relative names of the static records are
+// parsed with, replaced by the served domain of each query
+const dnsRecordsOrigin = "interactsh-served-domain."
+
+// dnsRecordsTTL is the ttl of the static records defined without one
+const dnsRecordsTTL = 3600
+
+// dnsRecordEntry is a static record of a yaml records file
+type dnsRecordEntry struct {
+	// Name is relative to every served domain, @ being the domain itself, or
+	// absolute when ending with a dot. A leading * matches any subdomain.
+	Name  string `yaml:"name"`
+	Type  string `yaml:"type"`
+	Value string `yaml:"value"`
+	TTL   uint32 `yaml:"ttl"`
+}
+
+// DNSRecords are static records answered by the dns server for specific
+// names (eg. spf and dkim records, auxiliary hosts), the queries being still
+// recorded as interactions
+type DNSRecords struct {
+	// relative are the records of names relative to the served domains, by
+	// lowercase name relative to the domain (@ for the domain itself)
+	relative map[string][]dns.RR
+	// absolute are the records of absolute names, by lowercase fqdn
+	absolute map[string][]dns.RR
+}
+
+// NewDNSRecords loads static records from a yaml file, or a zone file
+// whose relative names are relative to every served domain
+func NewDNSRecords(path string) (*DNSRecords, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var records []dns.RR
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+		records, err = parseDNSRecordEntries(data)
+	default:
+		records, err = parseZoneRecords(data, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r := &DNSRecords{relative: make(map[string][]dns.RR), absolute: make(map[string][]dns.RR)}
+	for _, record := range records {
+		name := strings.ToLower(record.Header().Name)
+		switch {
+		case name == dnsRecordsOrigin:
+			r.relative["@"] = append(r.relative["@"], record)
+		case strings.HasSuffix(name, "."+dnsRecordsOrigin):
+			name = strings.TrimSuffix(name, "."+dnsRecordsOrigin)
+			r.relative[name] = append(r.relative[name], record)
+		default:
+			r.absolute[name] = append(r.absolute[name], record)
+		}
+	}
+	return r, nil
+}
+
+// parseZoneRecords parses the records of a zone file, without includes
+func parseZoneRecords(data []byte, path string) ([]dns.RR, error) {
+	parser := dns.NewZoneParser(bytes.NewReader(data), dnsRecordsOrigin, path)
+	parser.SetDefaultTTL(dnsRecordsTTL)
+	var records []dns.RR
+	for record, ok := parser.Next(); ok; record, ok = parser.Next() {
+		records = append(records, record)
+	}
+	if err := parser.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// parseDNSRecordEntries parses the records of a yaml file
+func parseDNSRecordEntries(data []byte) ([]dns.RR, error) {
+	var entries []dnsRecordEntry
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	records := make([]dns.RR, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Name == "" || entry.Type == "" || entry.Value == "" {
+			return nil, fmt.Errorf("dns record requires a name, type and value")
+		}
+		name := entry.Name
+		switch {
+		case name == "@":
+			name = dnsRecordsOrigin
+		case !strings.HasSuffix(name, "."):
+			name += "." + dnsRecordsOrigin
+		}
+		ttl := entry.TTL
+		if ttl == 0 {
+			ttl = dnsRecordsTTL
+		}
+		if strings.EqualFold(entry.Type, "TXT") {
+			// txt values are split in strings of at most 255 bytes, as dkim keys exceed it
+			records = append(records, &dns.TXT{Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: ttl}, Txt: splitTXT(entry.Value)})
+			continue
+		}
+		record, err := dns.NewRR(fmt.Sprintf("%s %d IN %s %s", name, ttl, strings.ToUpper(entry.Type), entry.Value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s record %s: %w", entry.Type, entry.Name, err)
+		}
+		if record == nil {
+			return nil, fmt.Errorf("invalid %s record %s", entry.Type, entry.Name)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// splitTXT splits a txt value in strings of at most 255 bytes
+func splitTXT(value string) []string {
+	var parts []string
+	for len(value) > 255 {
+		parts = append(parts, value[:255])
+		value = value[255:]
+	}
+	return append(parts, value)
+}
+
+// lookup returns the static records answering a query for a name of the
+// served domain, the cname of the name answering any query type. The records
+// may be nil.
+func (r *DNSRecords) lookup(name, domain string, qtype uint16) []dns.RR {
+	if r == nil {
+		return nil
+	}
+	name = strings.ToLower(dns.Fqdn(name))
+	candidates := r.candidates(name, domain)
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	var answers []dns.RR
+	for _, record := range candidates {
+		rrtype := record.Header().Rrtype
+		if qtype == dns.TypeANY || rrtype == qtype || rrtype == dns.TypeCNAME {
+			answer := dns.Copy(record)
+			answer.Header().Name = name
+			answers = append(answers, answer)
+		}
+	}
+	return answers
+}
+
+// has reports whether static records are defined for the name
+func (r *DNSRecords) has(name, domain string) bool {
+	return r != nil && len(r.candidates(strings.ToLower(dns.Fqdn(name)), domain)) > 0
+}
+
+// candidates returns the records of the lowercase fqdn, the exact names
+// having precedence over the closest wildcard
+func (r *DNSRecords) candidates(name, domain string) []dns.RR {
+	if records, ok := r.absolute[name]; ok {
+		return records
+	}
+	relative := ""
+	if domain != "" {
+		dotDomain := strings.ToLower(dns.Fqdn(domain))
+		switch {
+		case name == dotDomain:
+			relative = "@"
+		case strings.HasSuffix(name, "."+dotDomain):
+			relative = strings.TrimSuffix(name, "."+dotDomain)
+		}
+	}
+	if relative != "" {
+		if records, ok := r.relative[relative]; ok {
+			return records
+		}
+	}
+
+	// wildcards match the names below them, not the name itself
+	labels := dns.SplitDomainName(name)
+	for i := 1; i < len(labels); i++ {
+		if records, ok := r.absolute["*."+strings.Join(labels[i:], ".")+"."]; ok {
+			return records
+		}
+	}
+	if relative != "" && relative != "@" {
+		labels := strings.Split(relative, ".")
+		for i := 1; i < len(labels); i++ {
+			if records, ok := r.relative["*."+strings.Join(labels[i:], ".")]; ok {
+				return records
+			}
+		}
+		if records, ok := r.relative["*"]; ok {
+			return records
+		}
+	}
+	return nil
+}
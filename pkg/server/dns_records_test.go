@@ -0,0 +1,101 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDNSRecordsYAML(t *testing.T) {
+	dkim := "v=DKIM1; k=rsa; p=" + strings.Repeat("A", 300)
+	path := filepath.Join(t.TempDir(), "records.yaml")
+	require.Nil(t, os.WriteFile(path, []byte(`
+- name: "@"
+  type: TXT
+  value: "v=spf1 ip4:192.0.2.1 -all"
+- name: selector._domainkey
+  type: TXT
+  value: "`+dkim+`"
+- name: www
+  type: CNAME
+  value: example.github.io.
+  ttl: 60
+- name: "*.aux"
+  type: A
+  value: 192.0.2.10
+- name: "@"
+  type: MX
+  value: "10 mx.example.net."
+`), 0600))
+	records, err := NewDNSRecords(path)
+	require.Nil(t, err)
+
+	answers := records.lookup("interact.sh.", "interact.sh", dns.TypeTXT)
+	require.Len(t, answers, 1)
+	require.Equal(t, []string{"v=spf1 ip4:192.0.2.1 -all"}, answers[0].(*dns.TXT).Txt)
+	require.Empty(t, records.lookup("interact.sh.", "interact.sh", dns.TypeA), "apex address should be served as usual")
+	require.Len(t, records.lookup("oast.example.com.", "oast.example.com", dns.TypeMX), 1)
+
+	answers = records.lookup("Selector._domainkey.interact.sh.", "interact.sh", dns.TypeTXT)
+	require.Len(t, answers, 1)
+	require.Equal(t, dkim, strings.Join(answers[0].(*dns.TXT).Txt, ""))
+
+	answers = records.lookup("www.interact.sh.", "interact.sh", dns.TypeA)
+	require.Len(t, answers, 1)
+	require.Equal(t, "example.github.io.", answers[0].(*dns.CNAME).Target)
+	require.Equal(t, uint32(60), answers[0].Header().Ttl)
+
+	answers = records.lookup("host.aux.interact.sh.", "interact.sh", dns.TypeA)
+	require.Len(t, answers, 1)
+	require.Equal(t, "host.aux.interact.sh.", answers[0].Header().Name)
+	require.Equal(t, "192.0.2.10", answers[0].(*dns.A).A.String())
+	require.Empty(t, records.lookup("aux.interact.sh.", "interact.sh", dns.TypeA))
+	require.False(t, records.has("c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh.", "interact.sh"))
+}
+
+func TestDNSRecordsZoneFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.zone")
+	require.Nil(t, os.WriteFile(path, []byte(`$TTL 300
+@          IN TXT  "v=spf1 -all"
+mail       IN A    192.0.2.20
+mail       IN AAAA 2001:db8::20
+aux.example.org. IN A 192.0.2.30
+`), 0600))
+	records, err := NewDNSRecords(path)
+	require.Nil(t, err)
+
+	require.Len(t, records.lookup("mail.interact.sh.", "interact.sh", dns.TypeAAAA), 1)
+	require.Len(t, records.lookup("mail.interact.sh.", "interact.sh", dns.TypeANY), 2)
+	answers := records.lookup("aux.example.org.", "", dns.TypeA)
+	require.Len(t, answers, 1)
+	require.Equal(t, uint32(300), answers[0].Header().Ttl)
+
+	var nilRecords *DNSRecords
+	require.Nil(t, nilRecords.lookup("mail.interact.sh.", "interact.sh", dns.TypeA))
+
+	require.Nil(t, os.WriteFile(path, []byte("mail IN A not-an-ip\n"), 0600))
+	_, err = NewDNSRecords(path)
+	require.NotNil(t, err)
+}
+
+func TestDNSServerStaticRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.yaml")
+	require.Nil(t, os.WriteFile(path, []byte("- {name: status, type: A, value: 192.0.2.40}\n"), 0600))
+	records, err := NewDNSRecords(path)
+	require.Nil(t, err)
+
+	options := &Options{
+		Domains:                  []string{"interact.sh"},
+		IPAddress:                "192.0.2.1",
+		CorrelationIdLength:      20,
+		CorrelationIdNonceLength: 13,
+		DNSRecords:               records,
+	}
+	server := NewDNSServer("udp", options)
+	require.False(t, server.isUncorrelated("status.interact.sh."))
+	require.True(t, server.isUncorrelated("random.interact.sh."))
+}
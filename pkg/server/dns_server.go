@@ -3,11 +3,12 @@ package server
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
 	"os"
 	"strings"
-	"sync/atomic"
+	"sync"
 	"time"
 
 	jsoniter "github.com/json-iterator/go"
@@ -15,45 +16,55 @@ import (
 	"github.com/pkg/errors"
 	"github.com/projectdiscovery/gologger"
 	"github.com/projectdiscovery/interactsh/pkg/server/acme"
-	stringsutil "github.com/projectdiscovery/utils/strings"
 	"gopkg.in/yaml.v3"
 )
 
+const (
+	// DNSUncorrelatedAnswer answers queries without a correlation id with the server address
+	DNSUncorrelatedAnswer = "answer"
+	// DNSUncorrelatedNXDomain answers queries without a correlation id with NXDOMAIN
+	DNSUncorrelatedNXDomain = "nxdomain"
+	// DNSUncorrelatedCatchAll answers queries without a correlation id with the catch-all address
+	DNSUncorrelatedCatchAll = "catch-all"
+)
+
+// dnsForwardTimeout is the time after which an upstream resolver is considered unavailable
+const dnsForwardTimeout = 5 * time.Second
+
+// DNSUncorrelatedModes are the supported responses to queries without a correlation id
+var DNSUncorrelatedModes = []string{DNSUncorrelatedAnswer, DNSUncorrelatedNXDomain, DNSUncorrelatedCatchAll}
+
 // DNSServer is a DNS server instance that listens on port 53.
 type DNSServer struct {
-	options       *Options
-	mxDomains     map[string]string
-	nsDomains     map[string][]string
-	ipAddress     net.IP
-	timeToLive    uint32
-	server        *dns.Server
-	customRecords *customDNSRecords
-	TxtRecord     string // used for ACME verification
+	options    *Options
+	ipAddress  net.IP
+	timeToLive uint32
+	server     *dns.Server
+	catchAllIP net.IP
+	exfil      *exfilReassembler
+	forwarder  *dns.Client
+	workers    []*dns.Server
+	mu         sync.Mutex
+	TxtRecord  string // used for ACME verification
+	// transport is the encrypted transport (doh or dot) recorded in the interactions
+	transport string
 }
 
 // NewDNSServer returns a new DNS server.
 func NewDNSServer(network string, options *Options) *DNSServer {
-	mxDomains := make(map[string]string)
-	nsDomains := make(map[string][]string)
-
-	for _, domain := range options.Domains {
-		dotdomain := dns.Fqdn(domain)
-
-		mxDomain := fmt.Sprintf("mail.%s", dotdomain)
-		mxDomains[dotdomain] = mxDomain
-
-		ns1Domain := fmt.Sprintf("ns1.%s", dotdomain)
-		ns2Domain := fmt.Sprintf("ns2.%s", dotdomain)
-		nsDomains[dotdomain] = []string{ns1Domain, ns2Domain}
-	}
-
 	server := &DNSServer{
-		options:       options,
-		ipAddress:     net.ParseIP(options.IPAddress),
-		mxDomains:     mxDomains,
-		nsDomains:     nsDomains,
-		timeToLive:    3600,
-		customRecords: newCustomDNSRecordsServer(options.CustomRecords),
+		options:    options,
+		ipAddress:  net.ParseIP(options.IPAddress),
+		timeToLive: 3600,
+		catchAllIP: net.ParseIP(options.DNSCatchAllIP),
+	}
+	// the custom records are read before the first query to report errors at startup
+	options.customDNSRecords()
+	if options.DNSExfil {
+		server.exfil = newExfilReassembler()
+	}
+	if len(options.DNSForwarders) > 0 {
+		server.forwarder = &dns.Client{Net: network, Timeout: dnsForwardTimeout}
 	}
 	server.server = &dns.Server{
 		Addr:    options.ListenIP + fmt.Sprintf(":%d", options.DnsPort),
@@ -63,18 +74,123 @@ func NewDNSServer(network string, options *Options) *DNSServer {
 	return server
 }
 
+// nameServers returns the name servers of a served domain, domains can
+// be added and removed at runtime so they are derived on each query
+func (h *DNSServer) nameServers(dotDomain string) ([]string, bool) {
+	if !h.isServedDomain(dotDomain) {
+		return nil, false
+	}
+	return []string{fmt.Sprintf("ns1.%s", dotDomain), fmt.Sprintf("ns2.%s", dotDomain)}, true
+}
+
+// mailServer returns the mail server of a served domain
+func (h *DNSServer) mailServer(dotDomain string) (string, bool) {
+	if !h.isServedDomain(dotDomain) {
+		return "", false
+	}
+	return fmt.Sprintf("mail.%s", dotDomain), true
+}
+
+func (h *DNSServer) isServedDomain(dotDomain string) bool {
+	for _, domain := range h.options.GetDomains() {
+		if dns.Fqdn(domain) == dotDomain {
+			return true
+		}
+	}
+	return false
+}
+
+// NewDoTServer returns a dns over tls server answering as the dns server
+func NewDoTServer(options *Options, tlsConfig *tls.Config) *DNSServer {
+	server := NewDNSServer("tcp", options)
+	server.transport = "dot"
+	server.server.Addr = options.ListenIP + fmt.Sprintf(":%d", options.DoTPort)
+	server.server.TLSConfig = options.listenerTLSConfig("dot", tlsConfig)
+	return server
+}
+
 // ListenAndServe listens on dns ports for the server.
 func (h *DNSServer) ListenAndServe(dnsAlive chan bool) {
 	dnsAlive <- true
-	if err := h.server.ListenAndServe(); err != nil {
+	if err := h.listen(); err != nil {
+		gologger.Error().Msgf("Could not listen for %s DNS on %s (%s)\n", strings.ToUpper(h.server.Net), h.server.Addr, err)
+		dnsAlive <- false
+		return
+	}
+	for worker := 1; worker < h.options.Workers; worker++ {
+		go h.serveWorker()
+	}
+	if err := h.server.ActivateAndServe(); err != nil {
 		gologger.Error().Msgf("Could not listen for %s DNS on %s (%s)\n", strings.ToUpper(h.server.Net), h.server.Addr, err)
 		dnsAlive <- false
 	}
 }
 
+// serveWorker serves the dns queries on another socket of the dns address
+func (h *DNSServer) serveWorker() {
+	worker := &dns.Server{Addr: h.server.Addr, Net: h.server.Net, TLSConfig: h.server.TLSConfig, Handler: h}
+	if err := listenDNS(h.options.Listeners, worker); err != nil {
+		gologger.Error().Msgf("Could not listen for %s DNS worker on %s (%s)\n", strings.ToUpper(worker.Net), worker.Addr, err)
+		return
+	}
+	h.mu.Lock()
+	h.workers = append(h.workers, worker)
+	h.mu.Unlock()
+	if err := worker.ActivateAndServe(); err != nil {
+		gologger.Error().Msgf("Could not serve %s DNS worker on %s (%s)\n", strings.ToUpper(worker.Net), worker.Addr, err)
+	}
+}
+
+// Close shuts down the dns server and its workers
+func (h *DNSServer) Close() {
+	h.mu.Lock()
+	servers := append([]*dns.Server{h.server}, h.workers...)
+	h.mu.Unlock()
+	for _, server := range servers {
+		_ = server.Shutdown()
+	}
+}
+
+// Shutdown shuts down the dns server and its workers until the context is
+// done, then stores the incomplete exfil streams
+func (h *DNSServer) Shutdown(ctx context.Context) error {
+	h.mu.Lock()
+	servers := append([]*dns.Server{h.server}, h.workers...)
+	h.mu.Unlock()
+	var err error
+	for _, server := range servers {
+		// the servers not started yet can't be shut down
+		if shutdownErr := server.ShutdownContext(ctx); shutdownErr != nil && ctx.Err() != nil {
+			err = shutdownErr
+		}
+	}
+	h.flushExfil()
+	return err
+}
+
+// listen sets the socket the dns server is activated with
+func (h *DNSServer) listen() error {
+	return listenDNS(h.options.Listeners, h.server)
+}
+
+// listenDNS sets the socket a dns server is activated with
+func listenDNS(listeners *Listeners, server *dns.Server) error {
+	if server.Net == "udp" {
+		conn, err := listeners.ListenPacket(server.Net, server.Addr)
+		server.PacketConn = conn
+		return err
+	}
+	listener, err := listeners.Listen(server.Net, server.Addr)
+	if err == nil && server.TLSConfig != nil {
+		listener = tls.NewListener(listener, server.TLSConfig)
+	}
+	server.Listener = listener
+	return err
+}
+
 // ServeDNS is the default handler for DNS queries.
 func (h *DNSServer) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
-	atomic.AddUint64(&h.options.Stats.Dns, 1)
+	h.options.Stats.RecordSource("dns", w.RemoteAddr().String())
 
 	m := new(dns.Msg)
 	m.SetReply(r)
@@ -84,6 +200,10 @@ func (h *DNSServer) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 	if len(r.Question) == 0 {
 		return
 	}
+	if h.forwarder != nil && !h.isOwnName(r.Question[0].Name) {
+		h.forward(w, r)
+		return
+	}
 
 	isDNSChallenge := false
 	for _, question := range r.Question {
@@ -107,14 +227,24 @@ func (h *DNSServer) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 			case dns.TypeNS:
 				h.handleNS(domain, m)
 			case dns.TypeA, dns.TypeAAAA:
-				h.handleACNAMEANY(domain, m)
+				h.handleACNAMEANY(domain, question.Qtype, h.ipAddress, m)
 			}
 
 			gologger.Debug().Msgf("Got acme dns response: \n%s\n", m.String())
+		} else if h.options.DNSUncorrelated != "" && h.options.DNSUncorrelated != DNSUncorrelatedAnswer && h.isUncorrelated(domain) {
+			h.handleUncorrelated(domain, question.Qtype, m)
+		} else if records := h.options.getDNSRecords().lookup(domain, h.options.domainOf(domain), question.Qtype); len(records) > 0 {
+			m.Answer = append(m.Answer, records...)
+		} else if address, ok := h.rebindAnswer(domain, question.Qtype); ok {
+			h.handleRebind(domain, question.Qtype, address, m)
+		} else if address, ok := h.scriptedAnswer(domain, question.Qtype); ok {
+			h.handleRebind(domain, question.Qtype, address, m)
+		} else if target := h.cnameTarget(domain, question.Qtype); target != "" {
+			m.Answer = append(m.Answer, &dns.CNAME{Hdr: dns.RR_Header{Name: domain, Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: 0}, Target: target})
 		} else {
 			switch question.Qtype {
 			case dns.TypeA, dns.TypeAAAA, dns.TypeCNAME, dns.TypeANY:
-				h.handleACNAMEANY(domain, m)
+				h.handleACNAMEANY(domain, question.Qtype, h.answerAddress(domain, w, r), m)
 			case dns.TypeMX:
 				h.handleMX(domain, m)
 			case dns.TypeNS:
@@ -130,9 +260,58 @@ func (h *DNSServer) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 		// Write interaction for first question and dns request
 		h.handleInteraction(r.Question[0].Name, w, r, m)
 	}
+	if h.options.DNSSEC != nil {
+		if err := h.signResponse(r, m); err != nil {
+			gologger.Warning().Msgf("Could not sign DNS response: %s\n", err)
+		}
+	}
+	if h.options.ResponseFuzz != nil {
+		h.fuzzDNS(r, m)
+	}
 
 	if err := w.WriteMsg(m); err != nil {
 		gologger.Warning().Msgf("Could not write DNS response: \n%s\n %s\n", m.String(), err)
+	} else {
+		h.options.Stats.Bandwidth.Record("dns", h.options.sessionOf(r.Question[0].Name), m.Len())
+	}
+}
+
+// isOwnName reports whether the name is one of the served domains or their subdomains
+func (h *DNSServer) isOwnName(name string) bool {
+	name = strings.ToLower(dns.Fqdn(name))
+	for _, domain := range h.options.GetDomains() {
+		dotDomain := strings.ToLower(dns.Fqdn(domain))
+		if name == dotDomain || strings.HasSuffix(name, "."+dotDomain) {
+			return true
+		}
+	}
+	return false
+}
+
+// forward answers a query for a name outside of the served domains with the
+// response of the first upstream resolver answering it, when the server is
+// used as the resolver of an internal network
+func (h *DNSServer) forward(w dns.ResponseWriter, r *dns.Msg) {
+	question := r.Question[0]
+	var (
+		response *dns.Msg
+		err      error
+	)
+	for _, upstream := range h.options.DNSForwarders {
+		if response, _, err = h.forwarder.Exchange(r, upstream); err == nil {
+			break
+		}
+		gologger.Debug().Msgf("Could not forward DNS query %s to %s: %s\n", question.Name, upstream, err)
+	}
+	if response == nil {
+		response = new(dns.Msg)
+		response.SetRcode(r, dns.RcodeServerFailure)
+	}
+	response.Id = r.Id
+	gologger.Info().Msgf("Forwarded %s query for %s from %s: %s\n", dns.TypeToString[question.Qtype], question.Name, w.RemoteAddr(), dns.RcodeToString[response.Rcode])
+
+	if err := w.WriteMsg(response); err != nil {
+		gologger.Warning().Msgf("Could not write forwarded DNS response: %s\n", err)
 	}
 }
 
@@ -152,25 +331,89 @@ func (h *DNSServer) handleACMETXTChallenge(zone string, m *dns.Msg) error {
 	return nil
 }
 
+// clientSubnet returns the network of the edns client subnet option of a
+// query, the address of the client behind the recursive resolver
+func clientSubnet(r *dns.Msg) *net.IPNet {
+	opt := r.IsEdns0()
+	if opt == nil {
+		return nil
+	}
+	for _, option := range opt.Option {
+		subnet, ok := option.(*dns.EDNS0_SUBNET)
+		// a zero source prefix asks not to reveal the client network
+		if !ok || subnet.Address == nil || subnet.SourceNetmask == 0 {
+			continue
+		}
+		bits := 32
+		if subnet.Family == 2 {
+			bits = 128
+		}
+		mask := net.CIDRMask(int(subnet.SourceNetmask), bits)
+		if mask == nil {
+			return nil
+		}
+		return &net.IPNet{IP: subnet.Address.Mask(mask), Mask: mask}
+	}
+	return nil
+}
+
+// clientSubnetString returns the edns client subnet of a query in cidr
+// notation, or an empty string
+func clientSubnetString(r *dns.Msg) string {
+	if subnet := clientSubnet(r); subnet != nil {
+		return subnet.String()
+	}
+	return ""
+}
+
+// answerAddress returns the address of the tenant of the name, the address of the
+// region nearest to the resolver (or its client subnet) in multi-region mode, or
+// the server address
+func (h *DNSServer) answerAddress(zone string, w dns.ResponseWriter, r *dns.Msg) net.IP {
+	if tenant := h.options.tenantOf(zone); tenant != nil && tenant.ip != nil {
+		return tenant.ip
+	}
+	if h.options.Regions == nil {
+		return h.ipAddress
+	}
+	var source net.IP
+	if subnet := clientSubnet(r); subnet != nil {
+		source = subnet.IP
+	}
+	if source == nil {
+		if addr, ok := w.RemoteAddr().(*net.UDPAddr); ok {
+			source = addr.IP
+		} else if addr, ok := w.RemoteAddr().(*net.TCPAddr); ok {
+			source = addr.IP
+		}
+	}
+	if steered := h.options.Regions.Steer(source); steered != nil {
+		return steered
+	}
+	return h.ipAddress
+}
+
 // handleACNAMEANY handles A, CNAME or ANY queries for DNS server
-func (h *DNSServer) handleACNAMEANY(zone string, m *dns.Msg) {
+func (h *DNSServer) handleACNAMEANY(zone string, qtype uint16, ipAddress net.IP, m *dns.Msg) {
 	nsHeader := dns.RR_Header{Name: zone, Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: h.timeToLive}
 
 	// If we have a custom record serve it, or default IP
-	record := h.customRecords.checkCustomResponse(zone)
+	record := h.options.customDNSRecords().checkCustomResponse(zone)
 	switch {
 	case record != "":
-		h.resultFunction(nsHeader, zone, net.ParseIP(record), m)
+		h.resultFunction(nsHeader, zone, qtype, net.ParseIP(record), m)
 	default:
-		h.resultFunction(nsHeader, zone, h.ipAddress, m)
+		h.resultFunction(nsHeader, zone, qtype, ipAddress, m)
 	}
 }
 
-func (h *DNSServer) resultFunction(nsHeader dns.RR_Header, zone string, ipAddress net.IP, m *dns.Msg) {
-	m.Answer = append(m.Answer, &dns.A{Hdr: dns.RR_Header{Name: zone, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: h.timeToLive}, A: ipAddress})
-	dotDomains := []string{zone, dns.Fqdn(h.options.Domains[0])}
+func (h *DNSServer) resultFunction(nsHeader dns.RR_Header, zone string, qtype uint16, ipAddress net.IP, m *dns.Msg) {
+	if answer := h.addressRecord(zone, qtype, ipAddress); answer != nil {
+		m.Answer = append(m.Answer, answer)
+	}
+	dotDomains := []string{zone, dns.Fqdn(h.options.GetDomains()[0])}
 	for _, dotDomain := range dotDomains {
-		if nsDomains, ok := h.nsDomains[dotDomain]; ok {
+		if nsDomains, ok := h.nameServers(dotDomain); ok {
 			for _, nsDomain := range nsDomains {
 				m.Ns = append(m.Ns, &dns.NS{Hdr: nsHeader, Ns: nsDomain})
 				m.Extra = append(m.Extra, &dns.A{Hdr: dns.RR_Header{Name: nsDomain, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: h.timeToLive}, A: h.ipAddress})
@@ -180,12 +423,127 @@ func (h *DNSServer) resultFunction(nsHeader dns.RR_Header, zone string, ipAddres
 	}
 }
 
+// addressRecord returns the A or AAAA record of the address matching the query type.
+// AAAA queries for an IPv4 address get an empty answer so that DNS64 resolvers
+// synthesize the AAAA record from the A one instead of dropping a mismatched answer.
+func (h *DNSServer) addressRecord(zone string, qtype uint16, ipAddress net.IP) dns.RR {
+	header := dns.RR_Header{Name: zone, Class: dns.ClassINET, Ttl: h.timeToLive}
+	if ipv4 := ipAddress.To4(); ipv4 != nil || ipAddress == nil {
+		if qtype == dns.TypeAAAA {
+			return nil
+		}
+		header.Rrtype = dns.TypeA
+		return &dns.A{Hdr: header, A: ipAddress}
+	}
+	if qtype == dns.TypeA {
+		return nil
+	}
+	header.Rrtype = dns.TypeAAAA
+	return &dns.AAAA{Hdr: header, AAAA: ipAddress}
+}
+
+// cnameTarget returns the external host registered by the session of the
+// correlation id contained in the name, if any
+func (h *DNSServer) cnameTarget(zone string, qtype uint16) string {
+	switch qtype {
+	case dns.TypeA, dns.TypeAAAA, dns.TypeCNAME, dns.TypeANY:
+	default:
+		return ""
+	}
+	for _, part := range strings.Split(strings.ToLower(zone), ".") {
+		if !h.options.isCorrelationID(part) {
+			continue
+		}
+		item, err := h.options.Storage.GetCacheItem(part[:h.options.CorrelationIdLength])
+		if err != nil {
+			continue
+		}
+		item.Lock()
+		target := item.CNAME
+		item.Unlock()
+		if target != "" {
+			return target
+		}
+	}
+	return ""
+}
+
+// scriptedAnswer returns the next address of the dns script registered by
+// the session for the labels preceding the correlation id of the name, and
+// whether such a script exists. The address is nil when the script has no
+// address of the queried family.
+func (h *DNSServer) scriptedAnswer(zone string, qtype uint16) (net.IP, bool) {
+	if qtype != dns.TypeA && qtype != dns.TypeAAAA {
+		return nil, false
+	}
+	parts := strings.Split(strings.ToLower(strings.TrimSuffix(zone, ".")), ".")
+	for i, part := range parts {
+		if !h.options.isCorrelationID(part) {
+			continue
+		}
+		item, err := h.options.Storage.GetCacheItem(part[:h.options.CorrelationIdLength])
+		if err != nil {
+			continue
+		}
+		answer, ok := item.NextDNSAnswer(strings.Join(parts[:i], "."), qtype == dns.TypeAAAA)
+		if !ok {
+			continue
+		}
+		return net.ParseIP(answer), true
+	}
+	return nil, false
+}
+
+// isUncorrelated reports whether the name is neither a record of the
+// server (apex, name servers, mail, custom and static records) nor contains a correlation id
+func (h *DNSServer) isUncorrelated(zone string) bool {
+	dotZone := strings.ToLower(dns.Fqdn(zone))
+	for _, domain := range h.options.GetDomains() {
+		dotDomain := strings.ToLower(dns.Fqdn(domain))
+		switch dotZone {
+		case dotDomain, "mail." + dotDomain, "ns1." + dotDomain, "ns2." + dotDomain:
+			return false
+		}
+	}
+	if h.options.customDNSRecords().checkCustomResponse(zone) != "" || h.options.getDNSRecords().has(zone, h.options.domainOf(zone)) {
+		return false
+	}
+	for _, part := range strings.Split(dotZone, ".") {
+		if h.options.isCorrelationID(part) {
+			return false
+		}
+	}
+	return true
+}
+
+// handleUncorrelated answers a query without a correlation id according to the configured mode
+func (h *DNSServer) handleUncorrelated(zone string, qtype uint16, m *dns.Msg) {
+	switch h.options.DNSUncorrelated {
+	case DNSUncorrelatedNXDomain:
+		m.Rcode = dns.RcodeNameError
+		// negative responses carry the zone soa in the authority section
+		soa := &dns.Msg{}
+		h.handleSOA(zone, soa)
+		m.Ns = append(m.Ns, soa.Answer...)
+	case DNSUncorrelatedCatchAll:
+		switch qtype {
+		case dns.TypeA, dns.TypeAAAA, dns.TypeCNAME, dns.TypeANY:
+			nsHeader := dns.RR_Header{Name: zone, Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: h.timeToLive}
+			h.resultFunction(nsHeader, zone, qtype, h.catchAllIP, m)
+		case dns.TypeMX:
+			h.handleMX(zone, m)
+		case dns.TypeTXT:
+			h.handleTXT(zone, m)
+		}
+	}
+}
+
 func (h *DNSServer) handleMX(zone string, m *dns.Msg) {
 	nsHdr := dns.RR_Header{Name: zone, Rrtype: dns.TypeMX, Class: dns.ClassINET, Ttl: h.timeToLive}
 
-	dotDomains := []string{zone, dns.Fqdn(h.options.Domains[0])}
+	dotDomains := []string{zone, dns.Fqdn(h.options.GetDomains()[0])}
 	for _, dotDomain := range dotDomains {
-		if mxdomain, ok := h.mxDomains[dotDomain]; ok {
+		if mxdomain, ok := h.mailServer(dotDomain); ok {
 			m.Answer = append(m.Answer, &dns.MX{Hdr: nsHdr, Mx: mxdomain, Preference: 1})
 			return
 		}
@@ -195,9 +553,9 @@ func (h *DNSServer) handleMX(zone string, m *dns.Msg) {
 func (h *DNSServer) handleNS(zone string, m *dns.Msg) {
 	nsHeader := dns.RR_Header{Name: zone, Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: h.timeToLive}
 
-	dotDomains := []string{zone, dns.Fqdn(h.options.Domains[0])}
+	dotDomains := []string{zone, dns.Fqdn(h.options.GetDomains()[0])}
 	for _, dotDomain := range dotDomains {
-		if nsDomains, ok := h.nsDomains[dotDomain]; ok {
+		if nsDomains, ok := h.nameServers(dotDomain); ok {
 			for _, nsDomain := range nsDomains {
 				m.Answer = append(m.Answer, &dns.NS{Hdr: nsHeader, Ns: nsDomain})
 			}
@@ -208,9 +566,9 @@ func (h *DNSServer) handleNS(zone string, m *dns.Msg) {
 
 func (h *DNSServer) handleSOA(zone string, m *dns.Msg) {
 	nsHdr := dns.RR_Header{Name: zone, Rrtype: dns.TypeSOA, Class: dns.ClassINET}
-	dotDomains := []string{zone, dns.Fqdn(h.options.Domains[0])}
+	dotDomains := []string{zone, dns.Fqdn(h.options.GetDomains()[0])}
 	for _, dotDomain := range dotDomains {
-		if nsDomains, ok := h.nsDomains[dotDomain]; ok {
+		if nsDomains, ok := h.nameServers(dotDomain); ok {
 			for _, nsDomain := range nsDomains {
 				m.Answer = append(m.Answer, &dns.SOA{Hdr: nsHdr, Ns: nsDomain, Mbox: acme.CertificateAuthority, Serial: 1, Expire: 60, Minttl: 60})
 				return
@@ -220,7 +578,11 @@ func (h *DNSServer) handleSOA(zone string, m *dns.Msg) {
 }
 
 func (h *DNSServer) handleTXT(zone string, m *dns.Msg) {
-	m.Answer = append(m.Answer, &dns.TXT{Hdr: dns.RR_Header{Name: zone, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 0}, Txt: []string{h.TxtRecord}})
+	txt := h.TxtRecord
+	if tenant := h.options.tenantOf(zone); tenant != nil && tenant.TXT != "" {
+		txt = tenant.TXT
+	}
+	m.Answer = append(m.Answer, &dns.TXT{Hdr: dns.RR_Header{Name: zone, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 0}, Txt: []string{txt}})
 }
 
 func toQType(ttype uint16) (rtype string) {
@@ -248,23 +610,19 @@ func toQType(ttype uint16) (rtype string) {
 // handleInteraction handles an interaction for the DNS server
 func (h *DNSServer) handleInteraction(domain string, w dns.ResponseWriter, r *dns.Msg, m *dns.Msg) {
 	var uniqueID, fullID string
+	var labels []string
 
+	original := domain
+	domain = NormalizeHost(domain)
 	requestMsg := r.String()
 	responseMsg := m.String()
 
 	gologger.Debug().Msgf("New DNS request: %s\n", requestMsg)
 
-	var foundDomain string
-	for _, configuredDomain := range h.options.Domains {
-		configuredDotDomain := dns.Fqdn(configuredDomain)
-		if stringsutil.HasSuffixI(domain, configuredDotDomain) {
-			foundDomain = configuredDomain
-			break
-		}
-	}
+	foundDomain := h.options.domainOf(domain)
 
 	// if root-tld is enabled stores any interaction towards the main domain
-	if h.options.RootTLD && foundDomain != "" {
+	if h.options.isRootTLD() && foundDomain != "" {
 		correlationID := foundDomain
 		host, _, _ := net.SplitHostPort(w.RemoteAddr().String())
 		interaction := &Interaction{
@@ -272,11 +630,16 @@ func (h *DNSServer) handleInteraction(domain string, w dns.ResponseWriter, r *dn
 			UniqueID:      domain,
 			FullId:        domain,
 			QType:         toQType(r.Question[0].Qtype),
+			DNSTransport:  h.transport,
+			ClientSubnet:  clientSubnetString(r),
 			RawRequest:    requestMsg,
 			RawResponse:   responseMsg,
 			RemoteAddress: host,
 			Timestamp:     time.Now(),
+			Domain:        foundDomain,
+			OriginalHost:  originalHost(original, domain),
 		}
+		h.options.applyNAT64(interaction)
 
 		if nil != h.options.OnResult {
 			h.options.OnResult(interaction)
@@ -299,13 +662,13 @@ func (h *DNSServer) handleInteraction(domain string, w dns.ResponseWriter, r *dn
 			if h.options.isCorrelationID(part) {
 				uniqueID = part
 				fullID = part
+				labels = parts[:i]
 				if i+1 <= len(parts) {
 					fullID = strings.Join(parts[:i+1], ".")
 				}
 			}
 		}
 	}
-	uniqueID = strings.ToLower(uniqueID)
 
 	if uniqueID != "" {
 		correlationID := uniqueID[:h.options.CorrelationIdLength]
@@ -315,11 +678,19 @@ func (h *DNSServer) handleInteraction(domain string, w dns.ResponseWriter, r *dn
 			UniqueID:      uniqueID,
 			FullId:        fullID,
 			QType:         toQType(r.Question[0].Qtype),
+			DNSTransport:  h.transport,
+			ClientSubnet:  clientSubnetString(r),
 			RawRequest:    requestMsg,
 			RawResponse:   responseMsg,
 			RemoteAddress: host,
 			Timestamp:     time.Now(),
+			Domain:        foundDomain,
+			OriginalHost:  originalHost(original, domain),
+			Decoded:       decodeCandidates(labelValues(labels)...),
 		}
+		h.options.applyNAT64(interaction)
+		h.options.ResponseFuzz.apply(interaction)
+		applyRebind(interaction, labels, m)
 		buffer := &bytes.Buffer{}
 		if err := jsoniter.NewEncoder(buffer).Encode(interaction); err != nil {
 			gologger.Warning().Msgf("Could not encode dns interaction: %s\n", err)
@@ -329,6 +700,71 @@ func (h *DNSServer) handleInteraction(domain string, w dns.ResponseWriter, r *dn
 				gologger.Warning().Msgf("Could not store dns interaction: %s\n", err)
 			}
 		}
+		if qtype := r.Question[0].Qtype; qtype == dns.TypeA || qtype == dns.TypeAAAA {
+			h.options.AddressLookups.record(correlationID, uniqueID, fullID, host)
+			h.options.UNCChains.recordLookup(correlationID, fullID, host)
+		}
+		if h.exfil != nil {
+			h.handleExfil(correlationID, uniqueID, labels, host)
+		}
+	}
+}
+
+// handleExfil reassembles the data exfiltrated in chunks across queries,
+// storing it as a synthetic interaction once all the chunks are received
+func (h *DNSServer) handleExfil(correlationID, uniqueID string, labels []string, host string) {
+	data, chunks, ok := h.exfil.add(uniqueID, labels, time.Now())
+	if !ok {
+		return
+	}
+	decoded, encoding := decodeExfil(data)
+	h.storeExfil(correlationID, &Interaction{
+		Protocol:      "dns",
+		UniqueID:      uniqueID,
+		FullId:        uniqueID,
+		QType:         "EXFIL",
+		RawRequest:    data,
+		RemoteAddress: host,
+		Timestamp:     time.Now(),
+		ExfilData:     decoded,
+		ExfilEncoding: encoding,
+		ExfilChunks:   chunks,
+	})
+}
+
+// flushExfil stores the incomplete exfil streams with the chunks received
+// so far, as the streams are lost when the server stops
+func (h *DNSServer) flushExfil() {
+	if h.exfil == nil {
+		return
+	}
+	for _, partial := range h.exfil.drain(time.Now()) {
+		decoded, encoding := decodeExfil(partial.data)
+		h.storeExfil(partial.id[:h.options.CorrelationIdLength], &Interaction{
+			Protocol:      "dns",
+			UniqueID:      partial.id,
+			FullId:        partial.id,
+			QType:         "EXFIL",
+			RawRequest:    partial.data,
+			Timestamp:     time.Now(),
+			ExfilData:     decoded,
+			ExfilEncoding: encoding,
+			ExfilChunks:   partial.chunks,
+			ExfilTotal:    partial.total,
+		})
+	}
+}
+
+func (h *DNSServer) storeExfil(correlationID string, interaction *Interaction) {
+	h.options.applyNAT64(interaction)
+	buffer := &bytes.Buffer{}
+	if err := jsoniter.NewEncoder(buffer).Encode(interaction); err != nil {
+		gologger.Warning().Msgf("Could not encode dns exfil interaction: %s\n", err)
+		return
+	}
+	gologger.Debug().Msgf("DNS Exfil Interaction: \n%s\n", buffer.String())
+	if err := h.options.Storage.AddInteraction(correlationID, buffer.Bytes()); err != nil {
+		gologger.Warning().Msgf("Could not store dns exfil interaction: %s\n", err)
 	}
 }
 
@@ -346,16 +782,26 @@ var defaultCustomRecords = map[string]string{
 }
 
 func newCustomDNSRecordsServer(input string) *customDNSRecords {
+	server, err := loadCustomDNSRecords(input)
+	if err != nil {
+		gologger.Error().Msgf("Could not read custom DNS records: %s", err)
+	}
+	return server
+}
+
+// loadCustomDNSRecords returns the default custom records with the ones of
+// the input file, the defaults only if it can't be read
+func loadCustomDNSRecords(input string) (*customDNSRecords, error) {
 	server := &customDNSRecords{records: make(map[string]string)}
 	for k, v := range defaultCustomRecords {
 		server.records[k] = v
 	}
 	if input != "" {
 		if err := server.readRecordsFromFile(input); err != nil {
-			gologger.Error().Msgf("Could not read custom DNS records: %s", err)
+			return server, err
 		}
 	}
-	return server
+	return server, nil
 }
 
 func (c *customDNSRecords) readRecordsFromFile(input string) error {
@@ -0,0 +1,179 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDNSUncorrelated(t *testing.T) {
+	options := &Options{
+		Domains:                  []string{"interact.sh"},
+		IPAddress:                "192.0.2.1",
+		DNSCatchAllIP:            "192.0.2.99",
+		CorrelationIdLength:      20,
+		CorrelationIdNonceLength: 13,
+	}
+	server := NewDNSServer("udp", options)
+
+	require.False(t, server.isUncorrelated("interact.sh."))
+	require.False(t, server.isUncorrelated("ns1.interact.sh."))
+	require.False(t, server.isUncorrelated("aws.interact.sh."))
+	require.False(t, server.isUncorrelated("c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh."))
+	require.True(t, server.isUncorrelated("random.interact.sh."))
+
+	options.DNSUncorrelated = DNSUncorrelatedNXDomain
+	m := &dns.Msg{}
+	server.handleUncorrelated("random.interact.sh.", dns.TypeA, m)
+	require.Equal(t, dns.RcodeNameError, m.Rcode)
+	require.Empty(t, m.Answer)
+	require.Len(t, m.Ns, 1)
+
+	options.DNSUncorrelated = DNSUncorrelatedCatchAll
+	m = &dns.Msg{}
+	server.handleUncorrelated("random.interact.sh.", dns.TypeA, m)
+	require.Len(t, m.Answer, 1)
+	require.Equal(t, "192.0.2.99", m.Answer[0].(*dns.A).A.String())
+}
+
+func TestDNSCNAME(t *testing.T) {
+	options := newTestOptions(t)
+	options.IPAddress = "192.0.2.1"
+	store := options.Storage
+
+	server := NewDNSServer("udp", options)
+
+	name := "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh."
+	require.Nil(t, store.SetID("c59e3crp82ke7bcnedq0"))
+	require.Empty(t, server.cnameTarget(name, dns.TypeA))
+
+	require.Nil(t, store.SetCNAME("c59e3crp82ke7bcnedq0", "", "victim.example.com."))
+	require.Equal(t, "victim.example.com.", server.cnameTarget(name, dns.TypeA))
+	require.Empty(t, server.cnameTarget(name, dns.TypeMX))
+	require.Empty(t, server.cnameTarget("other.interact.sh.", dns.TypeA))
+}
+
+func TestCNAMEHandler(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0")
+	server, err := NewHTTPServer(options)
+	require.Nil(t, err)
+
+	setCNAME := func(target string) int {
+		recorder := httptest.NewRecorder()
+		server.cnameHandler(recorder, httptest.NewRequest(http.MethodPost, "/cname", strings.NewReader(`{"correlation-id":"c59e3crp82ke7bcnedq0","target":"`+target+`"}`)))
+		return recorder.Code
+	}
+	// the served domains would create resolution loops
+	require.Equal(t, http.StatusBadRequest, setCNAME("interact.sh"))
+	require.Equal(t, http.StatusBadRequest, setCNAME("Sub.Interact.sh."))
+	// domains merely ending with a served one are external hosts
+	require.Equal(t, http.StatusOK, setCNAME("evilinteract.sh"))
+	item, err := options.Storage.GetCacheItem("c59e3crp82ke7bcnedq0")
+	require.Nil(t, err)
+	require.Equal(t, "evilinteract.sh.", item.CNAME)
+}
+
+func TestDNSAddressRecord(t *testing.T) {
+	server := NewDNSServer("udp", &Options{Domains: []string{"interact.sh"}, IPAddress: "192.0.2.1"})
+
+	require.Equal(t, "192.0.2.1", server.addressRecord("interact.sh.", dns.TypeA, server.ipAddress).(*dns.A).A.String())
+	// no answer lets dns64 resolvers synthesize the AAAA record from the A one
+	require.Nil(t, server.addressRecord("interact.sh.", dns.TypeAAAA, server.ipAddress))
+
+	ipv6 := net.ParseIP("2001:db8::1")
+	require.Equal(t, "2001:db8::1", server.addressRecord("interact.sh.", dns.TypeAAAA, ipv6).(*dns.AAAA).AAAA.String())
+	require.Nil(t, server.addressRecord("interact.sh.", dns.TypeA, ipv6))
+}
+
+func TestDNSForward(t *testing.T) {
+	upstreamConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+	upstream := &dns.Server{PacketConn: upstreamConn, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = append(m.Answer, &dns.A{Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60}, A: net.ParseIP("198.51.100.1")})
+		_ = w.WriteMsg(m)
+	})}
+	go func() { _ = upstream.ActivateAndServe() }()
+	defer func() { _ = upstream.Shutdown() }()
+
+	options := newTestOptions(t)
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+	listeners := NewListeners()
+	listeners.addPacketConn(conn)
+	options.IPAddress = "192.0.2.1"
+	options.ListenIP = "127.0.0.1"
+	options.DnsPort = conn.LocalAddr().(*net.UDPAddr).Port
+	options.DNSForwarders = []string{"127.0.0.1:1", upstreamConn.LocalAddr().String()}
+	options.Listeners = listeners
+	server := NewDNSServer("udp", options)
+	alive := make(chan bool, 2)
+	go server.ListenAndServe(alive)
+	require.True(t, <-alive)
+	defer func() { _ = server.server.Shutdown() }()
+
+	client := &dns.Client{}
+	// other domains are answered by the first upstream resolver answering
+	response, _, err := client.Exchange(new(dns.Msg).SetQuestion("intranet.corp.example.", dns.TypeA), conn.LocalAddr().String())
+	require.Nil(t, err)
+	require.Len(t, response.Answer, 1)
+	require.Equal(t, "198.51.100.1", response.Answer[0].(*dns.A).A.String())
+
+	// the served domains are answered as usual
+	response, _, err = client.Exchange(new(dns.Msg).SetQuestion("c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh.", dns.TypeA), conn.LocalAddr().String())
+	require.Nil(t, err)
+	require.Len(t, response.Answer, 1)
+	require.Equal(t, "192.0.2.1", response.Answer[0].(*dns.A).A.String())
+	require.True(t, server.isOwnName("INTERACT.SH"))
+	require.False(t, server.isOwnName("evilinteract.sh."))
+}
+
+func TestDNSClientSubnet(t *testing.T) {
+	query := new(dns.Msg).SetQuestion("interact.sh.", dns.TypeA)
+	require.Empty(t, clientSubnetString(query))
+
+	query.SetEdns0(1232, false)
+	opt := query.IsEdns0()
+	opt.Option = append(opt.Option, &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 24, Address: net.ParseIP("203.0.113.77")})
+	require.Equal(t, "203.0.113.0/24", clientSubnetString(query))
+
+	opt.Option[0] = &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 2, SourceNetmask: 56, Address: net.ParseIP("2001:db8:1:2::1")}
+	require.Equal(t, "2001:db8:1::/56", clientSubnetString(query))
+
+	// the client asked not to reveal its network
+	opt.Option[0] = &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 0, Address: net.IPv4zero}
+	require.Empty(t, clientSubnetString(query))
+}
+
+func TestDNSScript(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0")
+	options.IPAddress = "192.0.2.1"
+	store := options.Storage
+
+	server := NewDNSServer("udp", options)
+	exchange := func(name string, qtype uint16) *dns.Msg {
+		writer := &dohResponseWriter{remote: &net.UDPAddr{IP: net.ParseIP("198.51.100.1"), Port: 53}}
+		server.ServeDNS(writer, new(dns.Msg).SetQuestion(name, qtype))
+		return writer.msg
+	}
+	name := "rebind1.c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh."
+	require.Equal(t, "192.0.2.1", exchange(name, dns.TypeA).Answer[0].(*dns.A).A.String())
+
+	require.Nil(t, store.SetDNSScript("c59e3crp82ke7bcnedq0", "", "rebind1", []string{"203.0.113.10", "127.0.0.1"}))
+	for _, expected := range []string{"203.0.113.10", "127.0.0.1", "127.0.0.1"} {
+		response := exchange(name, dns.TypeA)
+		require.Len(t, response.Answer, 1)
+		require.Equal(t, expected, response.Answer[0].(*dns.A).A.String())
+		require.EqualValues(t, 0, response.Answer[0].Header().Ttl)
+	}
+	// no ipv6 address is scripted
+	require.Empty(t, exchange(name, dns.TypeAAAA).Answer)
+	// other labels are answered as usual
+	require.Equal(t, "192.0.2.1", exchange("c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh.", dns.TypeA).Answer[0].(*dns.A).A.String())
+}
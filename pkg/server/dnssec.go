@@ -0,0 +1,253 @@
+package server
+
+import (
+	"crypto"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Validity of the signatures computed for each response, the inception being
+// in the past to accept resolvers with a late clock
+const (
+	dnssecInception  = time.Hour
+	dnssecExpiration = 7 * 24 * time.Hour
+	// dnssecTTL is the ttl of the keys and denial of existence records
+	dnssecTTL = 60
+)
+
+// dnssecDeniedTypes are the types listed by the nsec records of the empty
+// answers, the queried type being removed. They only need to exclude the
+// queried type for validators to accept the denial.
+var dnssecDeniedTypes = []uint16{dns.TypeA, dns.TypeMX, dns.TypeTXT, dns.TypeAAAA, dns.TypeRRSIG, dns.TypeNSEC}
+
+// DNSSEC signs the answers for the served domains on the fly, with a key
+// signing key and a zone signing key per domain kept in a directory so that
+// the ds records registered at the parent zones remain valid across restarts
+type DNSSEC struct {
+	dir string
+
+	mu    sync.Mutex
+	zones map[string]*dnssecZone
+}
+
+// dnssecZone holds the keys of a zone
+type dnssecZone struct {
+	name      string
+	ksk, zsk  *dns.DNSKEY
+	kskSigner crypto.Signer
+	zskSigner crypto.Signer
+}
+
+// NewDNSSEC returns a signer whose keys are kept in the directory, the keys
+// of each zone being generated on first use
+func NewDNSSEC(dir string) (*DNSSEC, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("could not create dnssec keys directory: %w", err)
+	}
+	return &DNSSEC{dir: dir, zones: make(map[string]*dnssecZone)}, nil
+}
+
+// DS returns the ds record of a domain to register at its parent zone
+func (d *DNSSEC) DS(domain string) (*dns.DS, error) {
+	zone, err := d.zone(domain)
+	if err != nil {
+		return nil, err
+	}
+	return zone.ksk.ToDS(dns.SHA256), nil
+}
+
+// zone returns the keys of a domain, loaded from the directory or generated
+func (d *DNSSEC) zone(domain string) (*dnssecZone, error) {
+	name := strings.ToLower(dns.Fqdn(domain))
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if zone, ok := d.zones[name]; ok {
+		return zone, nil
+	}
+	zone := &dnssecZone{name: name}
+	var err error
+	if zone.ksk, zone.kskSigner, err = d.key(name, "ksk", 257); err != nil {
+		return nil, err
+	}
+	if zone.zsk, zone.zskSigner, err = d.key(name, "zsk", 256); err != nil {
+		return nil, err
+	}
+	d.zones[name] = zone
+	return zone, nil
+}
+
+// key loads a key of the zone from the directory, generating it if missing
+func (d *DNSSEC) key(zone, kind string, flags uint16) (*dns.DNSKEY, crypto.Signer, error) {
+	base := filepath.Join(d.dir, strings.TrimSuffix(zone, ".")+"."+kind)
+	if public, err := os.ReadFile(base + ".key"); err == nil {
+		rr, err := dns.NewRR(string(public))
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not parse %s: %w", base+".key", err)
+		}
+		key, ok := rr.(*dns.DNSKEY)
+		if !ok {
+			return nil, nil, fmt.Errorf("%s is not a dnskey", base+".key")
+		}
+		file, err := os.Open(base + ".private")
+		if err != nil {
+			return nil, nil, err
+		}
+		defer file.Close()
+		private, err := key.ReadPrivateKey(file, base+".private")
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not parse %s: %w", base+".private", err)
+		}
+		signer, ok := private.(crypto.Signer)
+		if !ok {
+			return nil, nil, fmt.Errorf("unsupported private key %s", base+".private")
+		}
+		return key, signer, nil
+	}
+
+	key := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: zone, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: dnssecTTL},
+		Flags:     flags,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+	private, err := key.Generate(256)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := os.WriteFile(base+".private", []byte(key.PrivateKeyString(private)), 0600); err != nil {
+		return nil, nil, err
+	}
+	if err := os.WriteFile(base+".key", []byte(key.String()+"\n"), 0644); err != nil {
+		return nil, nil, err
+	}
+	return key, private.(crypto.Signer), nil
+}
+
+// signResponse answers the dnskey queries of the served domains and signs
+// the responses to queries with the dnssec ok bit. Empty answers get a nsec
+// record denying the queried type, nxdomain answers being turned into empty
+// answers (black lies) as the names can't be enumerated.
+func (h *DNSServer) signResponse(r, m *dns.Msg) error {
+	question := r.Question[0]
+	domain := h.options.domainOf(question.Name)
+	if domain == "" {
+		return nil
+	}
+	zone, err := h.options.DNSSEC.zone(domain)
+	if err != nil {
+		return err
+	}
+	if question.Qtype == dns.TypeDNSKEY && strings.EqualFold(dns.Fqdn(question.Name), zone.name) && len(m.Answer) == 0 {
+		m.Answer = append(m.Answer, dns.Copy(zone.ksk), dns.Copy(zone.zsk))
+	}
+	opt := r.IsEdns0()
+	if opt == nil || !opt.Do() {
+		return nil
+	}
+
+	if len(m.Answer) > 0 {
+		// the authority and glue records are optional, keeping signed responses small
+		m.Ns, m.Extra = nil, nil
+	} else {
+		types := []uint16{dns.TypeRRSIG, dns.TypeNSEC}
+		if m.Rcode == dns.RcodeNameError {
+			m.Rcode = dns.RcodeSuccess
+		} else {
+			types = types[:0]
+			for _, rrtype := range dnssecDeniedTypes {
+				if rrtype != question.Qtype {
+					types = append(types, rrtype)
+				}
+			}
+		}
+		sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+		soa := &dns.Msg{}
+		h.handleSOA(zone.name, soa)
+		m.Ns = append(soa.Answer, &dns.NSEC{
+			Hdr:        dns.RR_Header{Name: question.Name, Rrtype: dns.TypeNSEC, Class: dns.ClassINET, Ttl: dnssecTTL},
+			NextDomain: "\\000." + question.Name,
+			TypeBitMap: types,
+		})
+		m.Extra = nil
+	}
+	for _, section := range []*[]dns.RR{&m.Answer, &m.Ns} {
+		signed, err := zone.sign(*section)
+		if err != nil {
+			return err
+		}
+		*section = signed
+	}
+
+	size := opt.UDPSize()
+	if size < dns.MinMsgSize {
+		size = dns.MinMsgSize
+	}
+	m.SetEdns0(size, true)
+	if h.server.Net == "udp" {
+		m.Truncate(int(size))
+	}
+	return nil
+}
+
+// sign appends the signatures of the rrsets of the zone to the records
+func (z *dnssecZone) sign(records []dns.RR) ([]dns.RR, error) {
+	type rrsetKey struct {
+		name          string
+		rrtype, class uint16
+	}
+	var (
+		keys   []rrsetKey
+		rrsets = make(map[rrsetKey][]dns.RR)
+	)
+	for _, record := range records {
+		header := record.Header()
+		if header.Rrtype == dns.TypeRRSIG || header.Rrtype == dns.TypeOPT || !dns.IsSubDomain(z.name, strings.ToLower(header.Name)) {
+			continue
+		}
+		key := rrsetKey{strings.ToLower(header.Name), header.Rrtype, header.Class}
+		if _, ok := rrsets[key]; !ok {
+			keys = append(keys, key)
+		}
+		rrsets[key] = append(rrsets[key], record)
+	}
+
+	now := time.Now()
+	for _, key := range keys {
+		rrset := rrsets[key]
+		// the records of a rrset share the smallest ttl
+		ttl := rrset[0].Header().Ttl
+		for _, record := range rrset {
+			if record.Header().Ttl < ttl {
+				ttl = record.Header().Ttl
+			}
+		}
+		for _, record := range rrset {
+			record.Header().Ttl = ttl
+		}
+		signingKey, signer := z.zsk, z.zskSigner
+		if key.rrtype == dns.TypeDNSKEY {
+			signingKey, signer = z.ksk, z.kskSigner
+		}
+		rrsig := &dns.RRSIG{
+			Hdr:        dns.RR_Header{Name: rrset[0].Header().Name, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: ttl},
+			Algorithm:  signingKey.Algorithm,
+			KeyTag:     signingKey.KeyTag(),
+			SignerName: z.name,
+			Inception:  uint32(now.Add(-dnssecInception).Unix()),
+			Expiration: uint32(now.Add(dnssecExpiration).Unix()),
+		}
+		if err := rrsig.Sign(signer, rrset); err != nil {
+			return nil, err
+		}
+		records = append(records, rrsig)
+	}
+	return records, nil
+}
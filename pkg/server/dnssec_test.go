@@ -0,0 +1,57 @@
+package server
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDNSSEC(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0")
+
+	dir := t.TempDir()
+	dnssec, err := NewDNSSEC(dir)
+	require.Nil(t, err)
+	options.IPAddress = "192.0.2.1"
+	options.DNSSEC = dnssec
+	server := NewDNSServer("udp", options)
+	exchange := func(name string, qtype uint16) *dns.Msg {
+		query := new(dns.Msg).SetQuestion(name, qtype)
+		query.SetEdns0(1232, true)
+		writer := &dohResponseWriter{remote: &net.UDPAddr{IP: net.ParseIP("198.51.100.1"), Port: 53}}
+		server.ServeDNS(writer, query)
+		require.NotNil(t, writer.msg)
+		return writer.msg
+	}
+	zone, err := dnssec.zone("interact.sh")
+	require.Nil(t, err)
+
+	response := exchange("interact.sh.", dns.TypeDNSKEY)
+	require.Len(t, response.Answer, 3)
+	rrsig := response.Answer[2].(*dns.RRSIG)
+	require.Equal(t, zone.ksk.KeyTag(), rrsig.KeyTag)
+	require.Nil(t, rrsig.Verify(zone.ksk, response.Answer[:2]))
+
+	response = exchange("c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh.", dns.TypeA)
+	require.Len(t, response.Answer, 2)
+	require.Nil(t, response.Answer[1].(*dns.RRSIG).Verify(zone.zsk, response.Answer[:1]))
+	require.True(t, response.IsEdns0().Do())
+
+	response = exchange("c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh.", dns.TypeSRV)
+	require.Empty(t, response.Answer)
+	require.Equal(t, dns.RcodeSuccess, response.Rcode)
+	require.Len(t, response.Ns, 4)
+	nsec := response.Ns[1].(*dns.NSEC)
+	require.NotContains(t, nsec.TypeBitMap, dns.TypeSRV)
+	require.Contains(t, nsec.TypeBitMap, dns.TypeA)
+	require.Nil(t, response.Ns[3].(*dns.RRSIG).Verify(zone.zsk, []dns.RR{nsec}))
+
+	// the keys are loaded back from the directory
+	reloaded, err := NewDNSSEC(dir)
+	require.Nil(t, err)
+	ds, err := reloaded.DS("interact.sh")
+	require.Nil(t, err)
+	require.Equal(t, zone.ksk.ToDS(dns.SHA256).Digest, ds.Digest)
+}
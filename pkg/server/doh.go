@@ -0,0 +1,120 @@
+package server
+
+import (
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/projectdiscovery/gologger"
+)
+
+const (
+	// dohPath is the path of the dns over https endpoint (RFC 8484)
+	dohPath = "/dns-query"
+	// dohContentType is the media type of the dns messages of dns over https
+	dohContentType = "application/dns-message"
+)
+
+// dohHandler answers the dns over https queries with the dns server, sent
+// as the dns parameter of get requests or the body of post requests
+func (h *HTTPServer) dohHandler(w http.ResponseWriter, req *http.Request) {
+	var packed []byte
+	switch req.Method {
+	case http.MethodGet:
+		// the parameter is base64url encoded without padding, tolerated anyway
+		data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(req.URL.Query().Get("dns"), "="))
+		if err != nil {
+			http.Error(w, "invalid dns parameter", http.StatusBadRequest)
+			return
+		}
+		packed = data
+	case http.MethodPost:
+		if contentType := req.Header.Get("Content-Type"); !strings.EqualFold(contentType, dohContentType) {
+			http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+			return
+		}
+		data, err := io.ReadAll(io.LimitReader(req.Body, dns.MaxMsgSize))
+		if err != nil {
+			http.Error(w, "could not read dns message", http.StatusBadRequest)
+			return
+		}
+		packed = data
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	msg := &dns.Msg{}
+	if err := msg.Unpack(packed); err != nil || len(msg.Question) == 0 {
+		http.Error(w, "invalid dns message", http.StatusBadRequest)
+		return
+	}
+	writer := &dohResponseWriter{local: requestLocalAddr(req), remote: h.dohRemoteAddr(req)}
+	h.doh.ServeDNS(writer, msg)
+	if writer.msg == nil {
+		http.Error(w, "no dns response", http.StatusInternalServerError)
+		return
+	}
+	response, err := writer.msg.Pack()
+	if err != nil {
+		gologger.Warning().Msgf("Could not pack DoH response: %s\n", err)
+		http.Error(w, "could not pack dns response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", dohContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(response)))
+	_, _ = w.Write(response)
+}
+
+// dohRemoteAddr returns the address of the dns over https client, taken from
+// the origin ip header behind a reverse proxy
+func (h *HTTPServer) dohRemoteAddr(req *http.Request) net.Addr {
+	if originIP := req.Header.Get(h.options.OriginIPHeader); originIP != "" {
+		return &net.TCPAddr{IP: net.ParseIP(originIP)}
+	}
+	host, port, _ := net.SplitHostPort(req.RemoteAddr)
+	portNumber, _ := strconv.Atoi(port)
+	return &net.TCPAddr{IP: net.ParseIP(host), Port: portNumber}
+}
+
+// requestLocalAddr returns the local address of the connection of a request
+func requestLocalAddr(req *http.Request) net.Addr {
+	if addr, ok := req.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		return addr
+	}
+	return &net.TCPAddr{}
+}
+
+// dohResponseWriter keeps the dns response written by the dns server for
+// the http response
+type dohResponseWriter struct {
+	local, remote net.Addr
+	msg           *dns.Msg
+}
+
+func (w *dohResponseWriter) LocalAddr() net.Addr  { return w.local }
+func (w *dohResponseWriter) RemoteAddr() net.Addr { return w.remote }
+
+func (w *dohResponseWriter) WriteMsg(msg *dns.Msg) error {
+	w.msg = msg
+	return nil
+}
+
+func (w *dohResponseWriter) Write(data []byte) (int, error) {
+	msg := &dns.Msg{}
+	if err := msg.Unpack(data); err != nil {
+		return 0, err
+	}
+	w.msg = msg
+	return len(data), nil
+}
+
+func (w *dohResponseWriter) Close() error        { return nil }
+func (w *dohResponseWriter) TsigStatus() error   { return nil }
+func (w *dohResponseWriter) TsigTimersOnly(bool) {}
+func (w *dohResponseWriter) Hijack()             {}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/miekg/dns"
+	"github.com/projectdiscovery/interactsh/pkg/server/acme"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoH(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0")
+	options.IPAddress = "192.0.2.1"
+	options.DoH = true
+	store := options.Storage
+
+	server, err := NewHTTPServer(options)
+	require.Nil(t, err)
+
+	query, err := new(dns.Msg).SetQuestion("c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh.", dns.TypeA).Pack()
+	require.Nil(t, err)
+
+	requests := []*http.Request{
+		httptest.NewRequest(http.MethodGet, "https://interact.sh/dns-query?dns="+base64.RawURLEncoding.EncodeToString(query), nil),
+		httptest.NewRequest(http.MethodPost, "https://interact.sh/dns-query", bytes.NewReader(query)),
+	}
+	requests[1].Header.Set("Content-Type", dohContentType)
+	for _, req := range requests {
+		recorder := httptest.NewRecorder()
+		server.dohHandler(recorder, req)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Equal(t, dohContentType, recorder.Header().Get("Content-Type"))
+
+		response := &dns.Msg{}
+		require.Nil(t, response.Unpack(recorder.Body.Bytes()))
+		require.Len(t, response.Answer, 1)
+		require.Equal(t, "192.0.2.1", response.Answer[0].(*dns.A).A.String())
+	}
+
+	item, err := store.GetCacheItem("c59e3crp82ke7bcnedq0")
+	require.Nil(t, err)
+	require.Len(t, item.Data, 2)
+	interaction := &Interaction{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[0]), interaction))
+	require.Equal(t, "dns", interaction.Protocol)
+	require.Equal(t, "doh", interaction.DNSTransport)
+	require.Equal(t, "192.0.2.1", interaction.RemoteAddress)
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "https://interact.sh/dns-query", bytes.NewReader(query))
+	server.dohHandler(recorder, req)
+	require.Equal(t, http.StatusUnsupportedMediaType, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	server.dohHandler(recorder, httptest.NewRequest(http.MethodGet, "https://interact.sh/dns-query?dns=AAAA", nil))
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+	body, _ := io.ReadAll(recorder.Body)
+	require.Contains(t, string(body), "invalid dns message")
+}
+
+func TestDoT(t *testing.T) {
+	selfSigned, err := acme.HandleSelfSignedCertificates(t.TempDir(), []string{"interact.sh"})
+	require.Nil(t, err)
+	tlsConfig, err := acme.BuildTlsConfigWithCerts("", selfSigned.Certificate)
+	require.Nil(t, err)
+
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0")
+	store := options.Storage
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	listeners := NewListeners()
+	listeners.addListener(listener)
+	options.IPAddress = "192.0.2.1"
+	options.ListenIP = "127.0.0.1"
+	options.DoTPort = listener.Addr().(*net.TCPAddr).Port
+	options.Listeners = listeners
+	server := NewDoTServer(options, tlsConfig)
+	alive := make(chan bool, 2)
+	go server.ListenAndServe(alive)
+	require.True(t, <-alive)
+	defer server.Close()
+
+	client := &dns.Client{Net: "tcp-tls", TLSConfig: &tls.Config{InsecureSkipVerify: true}}
+	response, _, err := client.Exchange(new(dns.Msg).SetQuestion("c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh.", dns.TypeA), listener.Addr().String())
+	require.Nil(t, err)
+	require.Len(t, response.Answer, 1)
+	require.Equal(t, "192.0.2.1", response.Answer[0].(*dns.A).A.String())
+
+	item, err := store.GetCacheItem("c59e3crp82ke7bcnedq0")
+	require.Nil(t, err)
+	require.Len(t, item.Data, 1)
+	interaction := &Interaction{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[0]), interaction))
+	require.Equal(t, "dot", interaction.DNSTransport)
+}
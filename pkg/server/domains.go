@@ -0,0 +1,100 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// GetDomains returns a copy of the served domains, which can
+// be added and removed at runtime through the admin api
+func (options *Options) GetDomains() []string {
+	options.domainsMu.RLock()
+	defer options.domainsMu.RUnlock()
+
+	return append([]string(nil), options.Domains...)
+}
+
+// normalizeDomain returns the normalized name of a served domain, or an
+// error if it isn't a valid domain name
+func normalizeDomain(domain string) (string, error) {
+	domain = strings.TrimSuffix(NormalizeHost(domain), ".")
+	if _, ok := dns.IsDomainName(domain); !ok || !strings.Contains(domain, ".") {
+		return "", errors.New("invalid domain")
+	}
+	return domain, nil
+}
+
+// AddDomain adds a served domain, kept when the configured domains are reloaded
+func (options *Options) AddDomain(domain string) error {
+	domain, err := normalizeDomain(domain)
+	if err != nil {
+		return err
+	}
+
+	options.domainsMu.Lock()
+	defer options.domainsMu.Unlock()
+
+	for _, served := range options.Domains {
+		if strings.EqualFold(served, domain) {
+			return errors.New("domain is already served")
+		}
+	}
+	options.Domains = append(options.Domains, domain)
+	options.runtimeDomains = append(options.runtimeDomains, domain)
+	return nil
+}
+
+// RemoveDomain removes a served domain, the primary domain can't be removed
+func (options *Options) RemoveDomain(domain string) error {
+	domain = strings.TrimSuffix(domain, ".")
+
+	options.domainsMu.Lock()
+	defer options.domainsMu.Unlock()
+
+	for i, served := range options.Domains {
+		if !strings.EqualFold(served, domain) {
+			continue
+		}
+		if i == 0 {
+			return errors.New("the primary domain can't be removed")
+		}
+		options.Domains = append(options.Domains[:i:i], options.Domains[i+1:]...)
+		options.runtimeDomains = removeDomain(options.runtimeDomains, domain)
+		return nil
+	}
+	return errors.New("domain is not served")
+}
+
+// removeDomain returns the domains without a domain
+func removeDomain(domains []string, domain string) []string {
+	kept := domains[:0:0]
+	for _, served := range domains {
+		if !strings.EqualFold(served, domain) {
+			kept = append(kept, served)
+		}
+	}
+	return kept
+}
+
+// domainOf returns the served domain a host name (with an optional port)
+// belongs to, the most specific one when served domains are nested, or an
+// empty string
+func (options *Options) domainOf(host string) string {
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	host = strings.TrimSuffix(host, ".")
+	var found string
+	for _, domain := range options.GetDomains() {
+		if len(domain) <= len(found) {
+			continue
+		}
+		if strings.EqualFold(host, domain) || (len(host) > len(domain) && host[len(host)-len(domain)-1] == '.' && strings.EqualFold(host[len(host)-len(domain):], domain)) {
+			found = domain
+		}
+	}
+	return found
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuntimeDomains(t *testing.T) {
+	added := make(chan string, 1)
+	options := &Options{
+		Domains:                  []string{"interact.sh"},
+		IPAddress:                "192.0.2.1",
+		AdminAPI:                 true,
+		OnDomainAdd:              func(domain string) { added <- domain },
+		Stats:                    &Metrics{},
+		CorrelationIdLength:      20,
+		CorrelationIdNonceLength: 13,
+	}
+	server, err := NewHTTPServer(options)
+	require.Nil(t, err)
+	dnsServer := NewDNSServer("udp", options)
+
+	_, ok := dnsServer.nameServers("client.example.")
+	require.False(t, ok)
+
+	w := httptest.NewRecorder()
+	server.domainsHandler(w, httptest.NewRequest(http.MethodPost, "/admin/domains", strings.NewReader(`{"domain":"Client.Example."}`)))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "client.example", <-added)
+	require.Equal(t, []string{"interact.sh", "client.example"}, options.GetDomains())
+
+	nameServers, ok := dnsServer.nameServers("client.example.")
+	require.True(t, ok)
+	require.Equal(t, []string{"ns1.client.example.", "ns2.client.example."}, nameServers)
+	require.False(t, dnsServer.isUncorrelated("mail.client.example."))
+
+	w = httptest.NewRecorder()
+	server.domainsHandler(w, httptest.NewRequest(http.MethodPost, "/admin/domains", strings.NewReader(`{"domain":"client.example"}`)))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	server.domainsHandler(w, httptest.NewRequest(http.MethodGet, "/admin/domains", nil))
+	require.Contains(t, w.Body.String(), `"domains":["interact.sh","client.example"]`)
+
+	require.NotNil(t, options.RemoveDomain("interact.sh"))
+	w = httptest.NewRecorder()
+	server.domainsHandler(w, httptest.NewRequest(http.MethodDelete, "/admin/domains", strings.NewReader(`{"domain":"client.example"}`)))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, []string{"interact.sh"}, options.GetDomains())
+
+	m := &dns.Msg{}
+	dnsServer.handleNS("client.example.", m)
+	require.Equal(t, "ns1.interact.sh.", m.Answer[0].(*dns.NS).Ns)
+}
+
+func TestDomainOf(t *testing.T) {
+	options := &Options{Domains: []string{"interact.sh", "oast.example.com", "example.com"}, CorrelationIdLength: 20, CorrelationIdNonceLength: 13}
+
+	require.Equal(t, "interact.sh", options.domainOf("c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh."))
+	require.Equal(t, "interact.sh", options.domainOf("interact.sh:8080"))
+	// the most specific nested domain
+	require.Equal(t, "oast.example.com", options.domainOf("c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.oast.example.com"))
+	require.Equal(t, "example.com", options.domainOf("c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.example.com"))
+	// suffixes which aren't labels of the domain
+	require.Empty(t, options.domainOf("c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.notinteract.sh"))
+
+	ldapServer := &LDAPServer{options: options}
+	matches := ldapServer.ldapMatches("cn=c59e3crp82ke7bcnedq0cfjqdpeyyyyyy,dc=oast,dc=example,dc=com")
+	require.Len(t, matches, 1)
+	require.Equal(t, "oast.example.com", matches[0].domain)
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"errors"
+	"sync"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/interactsh/pkg/storage"
+)
+
+// reasons of the interactions received but not stored
+const (
+	// DropUnregistered is an interaction of a correlation id without session
+	DropUnregistered = "unregistered"
+	// DropEvicted is an interaction of a session evicted from the full cache, or expired
+	DropEvicted = "evicted"
+	// DropEncryption is an interaction that could not be encrypted for its session
+	DropEncryption = "encryption"
+	// DropStorage is an interaction that could not be stored for another reason
+	DropStorage = "storage"
+)
+
+// DropStats contains the interactions received but not stored, by reason and protocol
+type DropStats struct {
+	Total     uint64            `json:"total"`
+	Reasons   map[string]uint64 `json:"reasons"`
+	Protocols map[string]uint64 `json:"protocols"`
+}
+
+// DropMetrics tracks the interactions received but not stored
+type DropMetrics struct {
+	mu        sync.RWMutex
+	total     uint64
+	reasons   map[string]uint64
+	protocols map[string]uint64
+}
+
+// Record accounts an interaction of the protocol dropped for the reason
+func (d *DropMetrics) Record(protocol, reason string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.reasons == nil {
+		d.reasons = make(map[string]uint64)
+		d.protocols = make(map[string]uint64)
+	}
+	d.total++
+	d.reasons[reason]++
+	if protocol != "" {
+		d.protocols[protocol]++
+	}
+}
+
+// Snapshot returns a copy of the drop statistics
+func (d *DropMetrics) Snapshot() DropStats {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	snapshot := DropStats{
+		Total:     d.total,
+		Reasons:   make(map[string]uint64, len(d.reasons)),
+		Protocols: make(map[string]uint64, len(d.protocols)),
+	}
+	for reason, n := range d.reasons {
+		snapshot.Reasons[reason] = n
+	}
+	for protocol, n := range d.protocols {
+		snapshot.Protocols[protocol] = n
+	}
+	return snapshot
+}
+
+// MarshalJSON encodes a snapshot of the drop statistics
+func (d *DropMetrics) MarshalJSON() ([]byte, error) {
+	return jsoniter.Marshal(d.Snapshot())
+}
+
+// dropReason returns the reason an interaction could not be stored
+func dropReason(err error) string {
+	switch {
+	case errors.Is(err, storage.ErrCorrelationIdEvicted):
+		return DropEvicted
+	case errors.Is(err, storage.ErrCorrelationIdNotFound):
+		return DropUnregistered
+	case errors.Is(err, storage.ErrEncryptionFailed):
+		return DropEncryption
+	}
+	return DropStorage
+}
+
+// WrapDropStorage returns a storage logging and accounting in the statistics
+// of the options the interactions that could not be stored
+func WrapDropStorage(store storage.Storage, options *Options) storage.Storage {
+	return &dropStorage{Storage: store, options: options}
+}
+
+// dropStorage accounts the interactions that could not be stored
+type dropStorage struct {
+	storage.Storage
+	options *Options
+}
+
+// AddInteraction stores the interaction, accounting it when dropped
+func (s *dropStorage) AddInteraction(correlationID string, data []byte) error {
+	err := s.Storage.AddInteraction(correlationID, data)
+	if err != nil {
+		s.options.recordDrop(correlationID, data, err)
+	}
+	return err
+}
+
+// AddInteractionWithId stores the interaction, accounting it when dropped
+func (s *dropStorage) AddInteractionWithId(id string, data []byte) error {
+	err := s.Storage.AddInteractionWithId(id, data)
+	if err != nil {
+		s.options.recordDrop(id, data, err)
+	}
+	return err
+}
+
+// recordDrop logs and accounts an interaction of the id that could not be stored
+func (options *Options) recordDrop(id string, data []byte, err error) {
+	protocol := jsoniter.Get(data, "protocol").ToString()
+	reason := dropReason(err)
+	if options.Stats != nil {
+		options.Stats.Dropped.Record(protocol, reason)
+	}
+	gologger.Verbose().Str("protocol", protocol).Str("reason", reason).Str("id", id).Msgf("Dropped interaction: %s", err)
+}
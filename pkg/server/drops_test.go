@@ -0,0 +1,36 @@
+package server
+
+import (
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/interactsh/pkg/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDropStorage(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0")
+	options.Storage = WrapDropStorage(options.Storage, options)
+
+	data, err := jsoniter.Marshal(&Interaction{Protocol: "dns"})
+	require.Nil(t, err)
+	require.Nil(t, options.Storage.AddInteraction("c59e3crp82ke7bcnedq0", data))
+	require.ErrorIs(t, options.Storage.AddInteraction("c59e3crp82ke7bcnedq1", data), storage.ErrCorrelationIdNotFound)
+	require.NotNil(t, options.Storage.AddInteractionWithId("token", data))
+
+	stats := options.Stats.Dropped.Snapshot()
+	require.EqualValues(t, 2, stats.Total)
+	require.Equal(t, map[string]uint64{DropUnregistered: 2}, stats.Reasons)
+	require.Equal(t, map[string]uint64{"dns": 2}, stats.Protocols)
+
+	encoded, err := jsoniter.Marshal(options.Stats)
+	require.Nil(t, err)
+	require.EqualValues(t, 2, jsoniter.Get(encoded, "dropped", "total").ToInt())
+}
+
+func TestDropReason(t *testing.T) {
+	require.Equal(t, DropEvicted, dropReason(storage.ErrCorrelationIdEvicted))
+	require.Equal(t, DropUnregistered, dropReason(storage.ErrCorrelationIdNotFound))
+	require.Equal(t, DropEncryption, dropReason(storage.ErrEncryptionFailed))
+	require.Equal(t, DropStorage, dropReason(storage.ErrCorrelationIdBanned))
+}
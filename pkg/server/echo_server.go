@@ -0,0 +1,181 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/gologger"
+	stringsutil "github.com/projectdiscovery/utils/strings"
+)
+
+const (
+	// echoMaxSize is the maximum size of a tcp conversation or udp datagram recorded
+	echoMaxSize = 64 * 1024
+	// echoTimeout is the idle timeout of a tcp echo connection
+	echoTimeout = 30 * time.Second
+)
+
+// EchoServer is a tcp or udp server sending back exactly what it receives,
+// so that fuzzing harnesses can use it as a remote echo oracle while data
+// containing correlation ids is recorded as interactions
+type EchoServer struct {
+	options  *Options
+	network  string
+	port     int
+	listener net.Listener
+	conn     net.PacketConn
+	mu       sync.Mutex
+}
+
+// NewEchoServer returns a new echo server for the tcp or udp network
+func NewEchoServer(network string, port int, options *Options) (*EchoServer, error) {
+	if network != "tcp" && network != "udp" {
+		return nil, fmt.Errorf("unsupported echo network %s", network)
+	}
+	return &EchoServer{options: options, network: network, port: port}, nil
+}
+
+// ListenAndServe listens on the echo port
+func (h *EchoServer) ListenAndServe(echoAlive chan bool) {
+	address := net.JoinHostPort(h.options.ListenIP, fmt.Sprint(h.port))
+	var err error
+	if h.network == "udp" {
+		err = h.serveUDP(address, echoAlive)
+	} else {
+		err = h.serveTCP(address, echoAlive)
+	}
+	if err != nil && !errors.Is(err, net.ErrClosed) {
+		gologger.Error().Msgf("Could not serve %s echo on port %d: %s\n", h.network, h.port, err)
+		echoAlive <- false
+	}
+}
+
+func (h *EchoServer) serveTCP(address string, echoAlive chan bool) error {
+	listener, err := h.options.Listeners.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	h.listener = listener
+	h.mu.Unlock()
+	echoAlive <- true
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go h.handleConn(conn)
+	}
+}
+
+func (h *EchoServer) handleConn(conn net.Conn) {
+	defer conn.Close()
+
+	recorded := &bytes.Buffer{}
+	buffer := make([]byte, 4096)
+	for {
+		_ = conn.SetDeadline(time.Now().Add(echoTimeout))
+		n, err := conn.Read(buffer)
+		if n > 0 {
+			if recorded.Len() < echoMaxSize {
+				recorded.Write(buffer[:min(n, echoMaxSize-recorded.Len())])
+			}
+			written, werr := conn.Write(buffer[:n])
+			h.options.Stats.Bandwidth.Record("tcp-echo", "", written)
+			if werr != nil {
+				break
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				gologger.Debug().Msgf("Echo tcp connection from %s closed: %s\n", conn.RemoteAddr(), err)
+			}
+			break
+		}
+	}
+	h.recordInteraction(conn.RemoteAddr(), recorded.String())
+}
+
+func (h *EchoServer) serveUDP(address string, echoAlive chan bool) error {
+	conn, err := h.options.Listeners.ListenPacket("udp", address)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	h.conn = conn
+	h.mu.Unlock()
+	echoAlive <- true
+
+	buffer := make([]byte, echoMaxSize)
+	for {
+		n, addr, err := conn.ReadFrom(buffer)
+		if err != nil {
+			return err
+		}
+		written, _ := conn.WriteTo(buffer[:n], addr)
+		h.options.Stats.Bandwidth.Record("udp-echo", "", written)
+		h.recordInteraction(addr, string(buffer[:n]))
+	}
+}
+
+// recordInteraction stores the received data for each correlation id it contains
+func (h *EchoServer) recordInteraction(remoteAddr net.Addr, data string) {
+	h.options.Stats.RecordSource("echo", remoteAddr.String())
+
+	if data == "" {
+		return
+	}
+	gologger.Debug().Msgf("New %s echo request from %s: %q\n", h.network, remoteAddr, data)
+
+	host, _, _ := net.SplitHostPort(remoteAddr.String())
+	seen := make(map[string]struct{})
+	for _, chunk := range stringsutil.SplitAny(data, ".\r\n\t \"'") {
+		for part := range stringsutil.SlideWithLength(chunk, h.options.GetIdLength()) {
+			uniqueID := strings.ToLower(part)
+			if _, ok := seen[uniqueID]; ok || !h.options.isCorrelationID(uniqueID) {
+				continue
+			}
+			seen[uniqueID] = struct{}{}
+
+			interaction := &Interaction{
+				Protocol:      h.network + "-echo",
+				UniqueID:      uniqueID,
+				FullId:        chunk,
+				RawRequest:    data,
+				RawResponse:   data,
+				RemoteAddress: host,
+				Timestamp:     time.Now(),
+			}
+			h.options.applyNAT64(interaction)
+			buffer := &bytes.Buffer{}
+			if err := jsoniter.NewEncoder(buffer).Encode(interaction); err != nil {
+				gologger.Warning().Msgf("Could not encode echo interaction: %s\n", err)
+				continue
+			}
+			gologger.Debug().Msgf("%s\n", buffer.String())
+			if err := h.options.Storage.AddInteraction(uniqueID[:h.options.CorrelationIdLength], buffer.Bytes()); err != nil {
+				gologger.Warning().Msgf("Could not store echo interaction: %s\n", err)
+			}
+		}
+	}
+}
+
+// Close stops the echo server
+func (h *EchoServer) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.listener != nil {
+		_ = h.listener.Close()
+	}
+	if h.conn != nil {
+		_ = h.conn.Close()
+	}
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEchoServer(t *testing.T) {
+	options := &Options{ListenIP: "127.0.0.1", Stats: &Metrics{}, CorrelationIdLength: 20, CorrelationIdNonceLength: 13}
+
+	for _, network := range []string{"tcp", "udp"} {
+		echoServer, err := NewEchoServer(network, 0, options)
+		require.Nil(t, err)
+		alive := make(chan bool, 1)
+		go echoServer.ListenAndServe(alive)
+		require.True(t, <-alive)
+
+		var address string
+		if network == "tcp" {
+			address = echoServer.listener.Addr().String()
+		} else {
+			address = echoServer.conn.LocalAddr().String()
+		}
+		conn, err := net.Dial(network, address)
+		require.Nil(t, err)
+
+		_, err = conn.Write([]byte("ping\x00\xff"))
+		require.Nil(t, err)
+		response := make([]byte, 6)
+		_, err = io.ReadFull(conn, response)
+		require.Nil(t, err)
+		require.Equal(t, "ping\x00\xff", string(response), network)
+
+		conn.Close()
+		echoServer.Close()
+	}
+
+	_, err := NewEchoServer("sctp", 0, options)
+	require.NotNil(t, err)
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"encoding/base32"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ExfilTimeout is the time after which incomplete exfil streams are discarded
+	ExfilTimeout = 5 * time.Minute
+	// ExfilMaxChunks is the maximum number of chunks of an exfil stream
+	ExfilMaxChunks = 4096
+	// ExfilMaxStreams is the maximum number of exfil streams reassembled at once
+	ExfilMaxStreams = 1024
+)
+
+var exfilBase32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// exfilStream is an exfil stream being reassembled
+type exfilStream struct {
+	total   int
+	chunks  map[int]string
+	updated time.Time
+}
+
+// exfilReassembler reassembles data exfiltrated in chunks across dns queries.
+//
+// Chunks are the labels preceding the correlation id with a seq-total sequence
+// label, either first or last, eg. 0-3.4142.c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.oast.fun
+// with a zero based seq. Streams are identified by the correlation id with its nonce.
+type exfilReassembler struct {
+	mu      sync.Mutex
+	streams map[string]*exfilStream
+}
+
+// newExfilReassembler returns a new exfil reassembler
+func newExfilReassembler() *exfilReassembler {
+	return &exfilReassembler{streams: make(map[string]*exfilStream)}
+}
+
+// parseExfilLabels returns the sequence and chunk data of the labels of a query
+func parseExfilLabels(labels []string) (int, int, string, bool) {
+	if len(labels) < 2 {
+		return 0, 0, "", false
+	}
+	for _, position := range []int{0, len(labels) - 1} {
+		seqValue, totalValue, ok := strings.Cut(labels[position], "-")
+		if !ok {
+			continue
+		}
+		seq, err := strconv.Atoi(seqValue)
+		if err != nil {
+			continue
+		}
+		total, err := strconv.Atoi(totalValue)
+		if err != nil || seq < 0 || seq >= total || total > ExfilMaxChunks {
+			continue
+		}
+		data := make([]string, 0, len(labels)-1)
+		data = append(data, labels[:position]...)
+		data = append(data, labels[position+1:]...)
+		return seq, total, strings.Join(data, ""), true
+	}
+	return 0, 0, "", false
+}
+
+// add records the chunk labels of a query for the stream, returning the
+// stream data and number of chunks once complete
+func (r *exfilReassembler) add(id string, labels []string, now time.Time) (string, int, bool) {
+	seq, total, data, ok := parseExfilLabels(labels)
+	if !ok {
+		return "", 0, false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for streamID, stream := range r.streams {
+		if now.Sub(stream.updated) > ExfilTimeout {
+			delete(r.streams, streamID)
+		}
+	}
+	stream, ok := r.streams[id]
+	if !ok || stream.total != total {
+		if !ok && len(r.streams) >= ExfilMaxStreams {
+			return "", 0, false
+		}
+		stream = &exfilStream{total: total, chunks: make(map[int]string)}
+		r.streams[id] = stream
+	}
+	// resolvers retry queries, repeated chunks are ignored
+	if _, ok := stream.chunks[seq]; !ok {
+		stream.chunks[seq] = data
+	}
+	stream.updated = now
+	if len(stream.chunks) < stream.total {
+		return "", 0, false
+	}
+	delete(r.streams, id)
+	return stream.join(), stream.total, true
+}
+
+// join returns the data of the received chunks in sequence order
+func (s *exfilStream) join() string {
+	seqs := make([]int, 0, len(s.chunks))
+	for seq := range s.chunks {
+		seqs = append(seqs, seq)
+	}
+	sort.Ints(seqs)
+	var builder strings.Builder
+	for _, seq := range seqs {
+		builder.WriteString(s.chunks[seq])
+	}
+	return builder.String()
+}
+
+// exfilPartial is the data of an incomplete exfil stream
+type exfilPartial struct {
+	id     string
+	data   string
+	chunks int
+	total  int
+}
+
+// drain removes the incomplete streams not yet expired, returning their
+// received chunks in order
+func (r *exfilReassembler) drain(now time.Time) []exfilPartial {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var partials []exfilPartial
+	for id, stream := range r.streams {
+		delete(r.streams, id)
+		if now.Sub(stream.updated) > ExfilTimeout {
+			continue
+		}
+		partials = append(partials, exfilPartial{id: id, data: stream.join(), chunks: len(stream.chunks), total: stream.total})
+	}
+	sort.Slice(partials, func(i, j int) bool { return partials[i].id < partials[j].id })
+	return partials
+}
+
+// decodeExfil decodes the reassembled data as hex or base32, the encodings
+// surviving the dns case folding, falling back to the raw data
+func decodeExfil(data string) ([]byte, string) {
+	if decoded, err := hex.DecodeString(strings.ToLower(data)); err == nil {
+		return decoded, "hex"
+	}
+	if decoded, err := exfilBase32.DecodeString(strings.ToUpper(data)); err == nil {
+		return decoded, "base32"
+	}
+	return []byte(data), "raw"
+}
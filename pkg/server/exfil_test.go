@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExfilReassembler(t *testing.T) {
+	reassembler := newExfilReassembler()
+	now := time.Now()
+
+	_, _, ok := reassembler.add("id", []string{"1-3", "6c6c"}, now)
+	require.False(t, ok)
+	// sequence label last and a repeated chunk
+	_, _, ok = reassembler.add("id", []string{"6865", "0-3"}, now)
+	require.False(t, ok)
+	_, _, ok = reassembler.add("id", []string{"6865", "0-3"}, now)
+	require.False(t, ok)
+	data, chunks, ok := reassembler.add("id", []string{"2-3", "6f", "21"}, now)
+	require.True(t, ok)
+	require.Equal(t, 3, chunks)
+	require.Equal(t, "68656c6c6f21", data)
+	require.Empty(t, reassembler.streams)
+
+	// incomplete streams expire
+	_, _, ok = reassembler.add("other", []string{"0-2", "61"}, now)
+	require.False(t, ok)
+	_, _, ok = reassembler.add("id", []string{"0-2", "61"}, now.Add(ExfilTimeout+time.Second))
+	require.False(t, ok)
+	require.Len(t, reassembler.streams, 1)
+
+	for _, labels := range [][]string{{"6865"}, {"a-b", "61"}, {"3-3", "61"}, {"61", "62"}} {
+		_, _, ok := reassembler.add("invalid", labels, now)
+		require.False(t, ok, labels)
+	}
+}
+
+func TestDecodeExfil(t *testing.T) {
+	decoded, encoding := decodeExfil("68656C6C6F")
+	require.Equal(t, "hello", string(decoded))
+	require.Equal(t, "hex", encoding)
+
+	decoded, encoding = decodeExfil("nbswy3dp")
+	require.Equal(t, "hello", string(decoded))
+	require.Equal(t, "base32", encoding)
+
+	decoded, encoding = decodeExfil("hello-world")
+	require.Equal(t, "hello-world", string(decoded))
+	require.Equal(t, "raw", encoding)
+}
+
+func TestDNSExfil(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0")
+	options.IPAddress = "192.0.2.1"
+	options.DNSExfil = true
+	store := options.Storage
+
+	server := NewDNSServer("udp", options)
+	for _, name := range []string{"1-2.6C6F.c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh.", "0-2.68656c.c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh."} {
+		r := new(dns.Msg).SetQuestion(name, dns.TypeA)
+		server.handleInteraction(name, &noopResponseWriter{}, r, new(dns.Msg).SetReply(r))
+	}
+
+	item, err := store.GetCacheItem("c59e3crp82ke7bcnedq0")
+	require.Nil(t, err)
+	require.Len(t, item.Data, 3)
+	interaction := &Interaction{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[2]), interaction))
+	require.Equal(t, "EXFIL", interaction.QType)
+	require.Equal(t, 2, interaction.ExfilChunks)
+	require.Equal(t, "hex", interaction.ExfilEncoding)
+	require.Equal(t, "hello", string(interaction.ExfilData))
+
+	// the incomplete streams are stored on shutdown
+	name := "0-3.6869.c59e3crp82ke7bcnedq0cfjqdpeyyyyzz.interact.sh."
+	r := new(dns.Msg).SetQuestion(name, dns.TypeA)
+	server.handleInteraction(name, &noopResponseWriter{}, r, new(dns.Msg).SetReply(r))
+	require.Nil(t, server.Shutdown(context.Background()))
+	item, err = store.GetCacheItem("c59e3crp82ke7bcnedq0")
+	require.Nil(t, err)
+	require.Len(t, item.Data, 5)
+	interaction = &Interaction{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[4]), interaction))
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyzz", interaction.UniqueID)
+	require.Equal(t, 1, interaction.ExfilChunks)
+	require.Equal(t, 3, interaction.ExfilTotal)
+	require.Equal(t, "hi", string(interaction.ExfilData))
+}
+
+// noopResponseWriter is a dns response writer from a fixed remote address
+type noopResponseWriter struct {
+	dns.ResponseWriter
+}
+
+func (w *noopResponseWriter) RemoteAddr() net.Addr {
+	return &net.UDPAddr{IP: net.ParseIP("192.0.2.10"), Port: 53}
+}
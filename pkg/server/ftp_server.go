@@ -5,9 +5,10 @@ import (
 	"crypto/tls"
 	"fmt"
 	"io"
+	"mime"
 	"os"
+	"path"
 	"strings"
-	"sync/atomic"
 	"time"
 
 	jsoniter "github.com/json-iterator/go"
@@ -42,6 +43,7 @@ func NewFTPServer(options *Options) (*FTPServer, error) {
 	}
 
 	nopDriver := NewNopDriver(driver)
+	nopDriver.captureLimit = options.captureLimit()
 
 	opt := &ftpserver.Options{
 		Name:   "interactsh-ftp",
@@ -106,12 +108,22 @@ func (h *FTPServer) ListenAndServe(tlsConfig *tls.Config, ftpAlive chan bool, ft
 	}()
 
 	ftpAlive <- true
-	if err := h.ftpServer.ListenAndServe(); err != nil {
+	if err := h.serve(); err != nil {
 		gologger.Error().Msgf("Could not serve ftp on port 21: %s\n", err)
 		ftpAlive <- false
 	}
 }
 
+// serve listens on the ftp port and serves it, ftps is served by its server
+// as its data connections rely on the tls configuration it loads
+func (h *FTPServer) serve() error {
+	listener, err := h.options.Listeners.Listen("tcp", fmt.Sprintf(":%d", h.options.FtpPort))
+	if err != nil {
+		return err
+	}
+	return h.ftpServer.Serve(listener)
+}
+
 func (h *FTPServer) Close() {
 	_ = h.ftpServer.Shutdown()
 	if h.ftpsServer != nil {
@@ -120,7 +132,12 @@ func (h *FTPServer) Close() {
 }
 
 func (h *FTPServer) recordInteraction(remoteAddress, data string) {
-	atomic.AddUint64(&h.options.Stats.Ftp, 1)
+	h.recordCapturedInteraction(remoteAddress, data, nil, nil)
+}
+
+// recordCapturedInteraction records an interaction carrying an uploaded body
+func (h *FTPServer) recordCapturedInteraction(remoteAddress, data string, capture *bodyCapture, sniff *bodySniff) {
+	h.options.Stats.RecordSource("ftp", remoteAddress)
 
 	if data == "" {
 		return
@@ -131,12 +148,15 @@ func (h *FTPServer) recordInteraction(remoteAddress, data string) {
 		RawRequest:    data,
 		Timestamp:     time.Now(),
 	}
+	capture.apply(interaction)
+	sniff.apply(interaction)
+	h.options.applyNAT64(interaction)
 	buffer := &bytes.Buffer{}
 	if err := jsoniter.NewEncoder(buffer).Encode(interaction); err != nil {
 		gologger.Warning().Msgf("Could not encode ftp interaction: %s\n", err)
 	} else {
 		gologger.Debug().Msgf("FTP Interaction: \n%s\n", buffer.String())
-		if err := h.options.Storage.AddInteractionWithId(h.options.Token, buffer.Bytes()); err != nil {
+		if err := h.options.Storage.AddInteractionWithId(h.options.GetToken(), buffer.Bytes()); err != nil {
 			gologger.Warning().Msgf("Could not store ftp interaction: %s\n", err)
 		}
 	}
@@ -230,7 +250,17 @@ func (h *FTPServer) AfterFilePut(ctx *ftpserver.Context, dstPath string, size in
 	b.WriteString(ctx.Param)
 	b.WriteString("\n")
 	b.WriteString("uploaded " + dstPath)
-	h.recordInteraction(ctx.Sess.RemoteAddr().String(), b.String())
+	capture, _ := ctx.Data[uploadCaptureKey].(*bodyCapture)
+	var sniff *bodySniff
+	if capture != nil && capture.size > 0 {
+		// the extension of the uploaded file hints the formats without signature
+		sniff = sniffBody(mime.TypeByExtension(path.Ext(dstPath)), capture.Bytes())
+		b.WriteString("\n\n")
+		b.Write(capture.Bytes())
+		b.WriteString(capture.marker())
+		b.WriteString(sniff.marker())
+	}
+	h.recordCapturedInteraction(ctx.Sess.RemoteAddr().String(), b.String(), capture, sniff)
 }
 func (h *FTPServer) AfterFileDeleted(ctx *ftpserver.Context, dstPath string, err error) {
 	var b strings.Builder
@@ -248,6 +278,7 @@ func (h *FTPServer) AfterFileDownloaded(ctx *ftpserver.Context, dstPath string,
 	b.WriteString(ctx.Param)
 	b.WriteString("\n")
 	b.WriteString("downloaded file " + dstPath)
+	h.options.Stats.Bandwidth.Record("ftp", "", int(size))
 	h.recordInteraction(ctx.Sess.RemoteAddr().String(), b.String())
 }
 func (h *FTPServer) AfterCurDirChanged(ctx *ftpserver.Context, oldCurDir, newCurDir string, err error) {
@@ -284,8 +315,12 @@ func (a *NopAuth) CheckPasswd(ctx *ftpserver.Context, name, pass string) (bool,
 	return true, nil
 }
 
+// uploadCaptureKey is the context key of the uploaded file body capture
+const uploadCaptureKey = "upload-capture"
+
 type NopDriver struct {
-	driver ftpserver.Driver
+	driver       ftpserver.Driver
+	captureLimit int
 }
 
 func NewNopDriver(driver ftpserver.Driver) *NopDriver {
@@ -320,6 +355,12 @@ func (n *NopDriver) GetFile(c *ftpserver.Context, s1 string, k int64) (int64, io
 	return n.driver.GetFile(c, s1, k)
 }
 
+// PutFile discards the uploaded file keeping only its first part in the context
 func (n *NopDriver) PutFile(c *ftpserver.Context, s string, r io.Reader, k int64) (int64, error) {
-	return k, nil
+	capture := newBodyCapture(n.captureLimit)
+	size, err := io.Copy(capture, r)
+	if c.Data != nil {
+		c.Data[uploadCaptureKey] = capture
+	}
+	return size, err
 }
@@ -0,0 +1,220 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/miekg/dns"
+	stringsutil "github.com/projectdiscovery/utils/strings"
+)
+
+// Bounds of the fuzzed responses, the exponential distributions being unbounded
+var (
+	// MaxFuzzLatency is the maximum delay of a fuzzed response
+	MaxFuzzLatency = 5 * time.Minute
+	// MaxFuzzSize is the maximum size of a fuzzed http response
+	MaxFuzzSize = 100 * 1024 * 1024
+)
+
+// fuzzFiller pads the fuzzed http responses
+var fuzzFiller = bytes.Repeat([]byte(" "), 32*1024)
+
+// Distribution is a distribution of values, parsed from fixed:v, uniform:min-max,
+// exp:mean or choice:a,b,c
+type Distribution struct {
+	kind   string
+	values []float64
+}
+
+// ParseLatencyDistribution parses a distribution of durations (eg. uniform:100ms-5s)
+func ParseLatencyDistribution(spec string) (*Distribution, error) {
+	return parseDistribution(spec, func(value string) (float64, error) {
+		duration, err := time.ParseDuration(value)
+		return float64(duration), err
+	})
+}
+
+// ParseSizeDistribution parses a distribution of sizes (eg. exp:64KB)
+func ParseSizeDistribution(spec string) (*Distribution, error) {
+	return parseDistribution(spec, func(value string) (float64, error) {
+		size, err := units.FromHumanSize(value)
+		return float64(size), err
+	})
+}
+
+func parseDistribution(spec string, parse func(string) (float64, error)) (*Distribution, error) {
+	kind, arguments, ok := strings.Cut(strings.TrimSpace(spec), ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid distribution %q, expected kind:values", spec)
+	}
+	var raw []string
+	switch kind {
+	case "fixed", "exp":
+		raw = []string{arguments}
+	case "uniform":
+		min, max, ok := strings.Cut(arguments, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid uniform distribution %q, expected uniform:min-max", spec)
+		}
+		raw = []string{min, max}
+	case "choice":
+		raw = strings.Split(arguments, ",")
+	default:
+		return nil, fmt.Errorf("unknown distribution %q (fixed, uniform, exp, choice)", kind)
+	}
+	distribution := &Distribution{kind: kind}
+	for _, value := range raw {
+		parsed, err := parse(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid distribution value %q: %w", value, err)
+		}
+		if parsed < 0 {
+			return nil, fmt.Errorf("invalid negative distribution value %q", value)
+		}
+		distribution.values = append(distribution.values, parsed)
+	}
+	if kind == "uniform" && distribution.values[0] > distribution.values[1] {
+		return nil, fmt.Errorf("invalid uniform distribution %q, min is greater than max", spec)
+	}
+	return distribution, nil
+}
+
+// draw returns a value of the distribution bounded by max
+func (d *Distribution) draw(rng *rand.Rand, max float64) float64 {
+	if d == nil {
+		return 0
+	}
+	var value float64
+	switch d.kind {
+	case "fixed":
+		value = d.values[0]
+	case "uniform":
+		value = d.values[0] + rng.Float64()*(d.values[1]-d.values[0])
+	case "exp":
+		value = rng.ExpFloat64() * d.values[0]
+	case "choice":
+		value = d.values[rng.Intn(len(d.values))]
+	}
+	return math.Min(value, max)
+}
+
+// ResponseFuzz varies the latency and size of the http and dns responses to
+// the payloads, for studying how fetchers handle slow or large responses. The
+// values are drawn with a seed derived from the unique id, so that the
+// responses of a payload are reproducible.
+type ResponseFuzz struct {
+	Latency *Distribution
+	Size    *Distribution
+}
+
+// fuzzDraw is the latency and size of the responses to a payload
+type fuzzDraw struct {
+	latency time.Duration
+	size    int
+}
+
+// draw returns the latency and size of the responses to a unique id
+func (f *ResponseFuzz) draw(uniqueID string) (fuzzDraw, bool) {
+	if f == nil || uniqueID == "" {
+		return fuzzDraw{}, false
+	}
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(strings.ToLower(uniqueID)))
+	seed := int64(hash.Sum64())
+	// each value has its own source, set regardless of the other distribution
+	return fuzzDraw{
+		latency: time.Duration(f.Latency.draw(rand.New(rand.NewSource(seed)), float64(MaxFuzzLatency))),
+		size:    int(f.Size.draw(rand.New(rand.NewSource(seed+1)), float64(MaxFuzzSize))),
+	}, true
+}
+
+// apply sets the latency and size of the responses to the payload of an interaction
+func (f *ResponseFuzz) apply(interaction *Interaction) {
+	draw, ok := f.draw(interaction.UniqueID)
+	if !ok {
+		return
+	}
+	interaction.ResponseLatency = draw.latency.Milliseconds()
+	interaction.ResponseSize = draw.size
+}
+
+// wait delays a response by the latency, returning false if the request is
+// canceled in the meantime
+func (d fuzzDraw) wait(done <-chan struct{}) bool {
+	if d.latency <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d.latency)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// uniqueIDOf returns the first unique id contained in a name
+func (options *Options) uniqueIDOf(name string) string {
+	for _, part := range strings.Split(strings.ToLower(name), ".") {
+		for chunk := range stringsutil.SlideWithLength(part, options.GetIdLength()) {
+			if options.isCorrelationID(chunk) {
+				return chunk
+			}
+		}
+	}
+	return ""
+}
+
+// writeFuzzed writes a http response after its latency, padded with spaces
+// up to its size unless encoded or the egress caps are reached
+func (h *HTTPServer) writeFuzzed(w http.ResponseWriter, r *http.Request, status int, data []byte, draw fuzzDraw) {
+	if !draw.wait(r.Context().Done()) {
+		return
+	}
+	session := h.options.sessionOf(r.Host)
+	padding := 0
+	if draw.size > len(data) && w.Header().Get("Content-Encoding") == "" && !h.options.egressCapped(session) {
+		padding = draw.size - len(data)
+		w.Header().Set("Content-Length", strconv.Itoa(draw.size))
+	}
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+	for written := 0; written < padding; {
+		chunk := fuzzFiller[:min(len(fuzzFiller), padding-written)]
+		if _, err := w.Write(chunk); err != nil {
+			break
+		}
+		written += len(chunk)
+	}
+	if padding > 0 {
+		h.options.Stats.Bandwidth.Record("http", session, padding)
+	}
+}
+
+// fuzzDNS pads a dns response with txt records up to the size of its payload
+// and delays it by the latency
+func (h *DNSServer) fuzzDNS(r, m *dns.Msg) {
+	draw, ok := h.options.ResponseFuzz.draw(h.options.uniqueIDOf(r.Question[0].Name))
+	if !ok {
+		return
+	}
+	size := min(draw.size, dns.MaxMsgSize-512)
+	filler := strings.Repeat(" ", 255)
+	m.Compress = true
+	for m.Len() < size {
+		m.Extra = append(m.Extra, &dns.TXT{
+			Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 0},
+			Txt: []string{filler},
+		})
+	}
+	draw.wait(nil)
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDistribution(t *testing.T) {
+	latency, err := ParseLatencyDistribution("uniform:100ms-2s")
+	require.Nil(t, err)
+	fuzz := &ResponseFuzz{Latency: latency}
+	draw, ok := fuzz.draw("c59e3crp82ke7bcnedq0cfjqdpeyyyyyy")
+	require.True(t, ok)
+	require.GreaterOrEqual(t, draw.latency, 100*time.Millisecond)
+	require.LessOrEqual(t, draw.latency, 2*time.Second)
+	again, _ := fuzz.draw("C59E3CRP82KE7BCNEDQ0CFJQDPEYYYYYY")
+	require.Equal(t, draw, again, "the draws of a payload should be reproducible")
+
+	size, err := ParseSizeDistribution("choice:1KB,2KB")
+	require.Nil(t, err)
+	fuzz.Size = size
+	draw, _ = fuzz.draw("c59e3crp82ke7bcnedq0cfjqdpeyyyyyy")
+	require.Contains(t, []int{1000, 2000}, draw.size)
+	require.Equal(t, again.latency, draw.latency, "the latency shouldn't depend on the size distribution")
+
+	_, ok = (*ResponseFuzz)(nil).draw("c59e3crp82ke7bcnedq0cfjqdpeyyyyyy")
+	require.False(t, ok)
+	for _, spec := range []string{"5s", "normal:1s", "uniform:2s-1s", "uniform:1s", "fixed:-1s"} {
+		_, err := ParseLatencyDistribution(spec)
+		require.NotNil(t, err, spec)
+	}
+}
+
+func TestResponseFuzz(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0")
+	store := options.Storage
+
+	size, err := ParseSizeDistribution("fixed:4KB")
+	require.Nil(t, err)
+	latency, err := ParseLatencyDistribution("fixed:50ms")
+	require.Nil(t, err)
+	options.IPAddress = "192.0.2.1"
+	options.ResponseFuzz = &ResponseFuzz{Latency: latency, Size: size}
+	server, err := NewHTTPServer(options)
+	require.Nil(t, err)
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "http://c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh/", nil)
+	start := time.Now()
+	server.logger(http.HandlerFunc(server.defaultHandler))(recorder, req)
+	require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, 4000, recorder.Body.Len())
+	require.Equal(t, "4000", recorder.Header().Get("Content-Length"))
+
+	item, err := store.GetCacheItem("c59e3crp82ke7bcnedq0")
+	require.Nil(t, err)
+	require.Len(t, item.Data, 1)
+	interaction := &Interaction{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[0]), interaction))
+	require.EqualValues(t, 50, interaction.ResponseLatency)
+	require.Equal(t, 4000, interaction.ResponseSize)
+
+	dnsServer := NewDNSServer("udp", options)
+	writer := &dohResponseWriter{remote: &net.UDPAddr{IP: net.ParseIP("198.51.100.1"), Port: 53}}
+	dnsServer.ServeDNS(writer, new(dns.Msg).SetQuestion("c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh.", dns.TypeA))
+	require.Len(t, writer.msg.Answer, 1)
+	require.GreaterOrEqual(t, writer.msg.Len(), 4000)
+	require.NotEmpty(t, writer.msg.Extra)
+}
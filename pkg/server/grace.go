@@ -0,0 +1,133 @@
+package server
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/projectdiscovery/interactsh/pkg/storage"
+)
+
+var (
+	// MaxGraceInteractions is the number of interactions buffered for the unregistered correlation ids
+	MaxGraceInteractions = 10000
+	// MaxGraceInteractionsPerID is the number of interactions buffered for a correlation id
+	MaxGraceInteractionsPerID = 100
+)
+
+// graceInteraction is an interaction of a correlation id not registered yet
+type graceInteraction struct {
+	correlationID string
+	data          []byte
+	time          time.Time
+}
+
+// GraceBuffer keeps the interactions of the correlation ids not registered yet
+// during the window, which are stored if their id registers meanwhile, as
+// payloads may fire before the clients finish registering
+type GraceBuffer struct {
+	window  time.Duration
+	options *Options
+
+	mu      sync.Mutex
+	pending []graceInteraction
+	counts  map[string]int
+}
+
+// NewGraceBuffer returns a buffer of the interactions of the unregistered ids
+// during the window, the expired ones being accounted as dropped in the options
+func NewGraceBuffer(window time.Duration, options *Options) *GraceBuffer {
+	return &GraceBuffer{window: window, options: options, counts: make(map[string]int)}
+}
+
+// add buffers an interaction, false when the buffer or the id quota is full
+func (g *GraceBuffer) add(correlationID string, data []byte) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := time.Now()
+	g.expire(now)
+	if len(g.pending) >= MaxGraceInteractions || g.counts[correlationID] >= MaxGraceInteractionsPerID {
+		return false
+	}
+	g.pending = append(g.pending, graceInteraction{correlationID: correlationID, data: data, time: now})
+	g.counts[correlationID]++
+	return true
+}
+
+// take removes and returns the buffered interactions of a correlation id, in order
+func (g *GraceBuffer) take(correlationID string) [][]byte {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.expire(time.Now())
+	if g.counts[correlationID] == 0 {
+		return nil
+	}
+	var taken [][]byte
+	kept := g.pending[:0]
+	for _, interaction := range g.pending {
+		if interaction.correlationID == correlationID {
+			taken = append(taken, interaction.data)
+			continue
+		}
+		kept = append(kept, interaction)
+	}
+	g.pending = kept
+	delete(g.counts, correlationID)
+	return taken
+}
+
+// Sweep drops the interactions buffered for longer than the window
+func (g *GraceBuffer) Sweep() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.expire(time.Now())
+}
+
+// expire drops the expired interactions, accounted as unregistered
+func (g *GraceBuffer) expire(now time.Time) {
+	expired := 0
+	for expired < len(g.pending) && now.Sub(g.pending[expired].time) > g.window {
+		interaction := g.pending[expired]
+		if g.counts[interaction.correlationID]--; g.counts[interaction.correlationID] <= 0 {
+			delete(g.counts, interaction.correlationID)
+		}
+		g.options.recordDrop(interaction.correlationID, interaction.data, storage.ErrCorrelationIdNotFound)
+		expired++
+	}
+	g.pending = g.pending[expired:]
+}
+
+// WrapStorage returns a storage buffering the interactions of the unregistered
+// correlation ids, stored when the ids are registered
+func (g *GraceBuffer) WrapStorage(store storage.Storage) storage.Storage {
+	return &graceStorage{Storage: store, grace: g}
+}
+
+// graceStorage buffers the interactions of the unregistered correlation ids
+type graceStorage struct {
+	storage.Storage
+	grace *GraceBuffer
+}
+
+// AddInteraction stores the interaction or buffers it if its id is not registered,
+// the interactions of the evicted sessions are not buffered
+func (s *graceStorage) AddInteraction(correlationID string, data []byte) error {
+	err := s.Storage.AddInteraction(correlationID, data)
+	if errors.Is(err, storage.ErrCorrelationIdNotFound) && !errors.Is(err, storage.ErrCorrelationIdEvicted) && s.grace.add(correlationID, data) {
+		return nil
+	}
+	return err
+}
+
+// SetIDPublicKey registers the correlation id and stores its buffered interactions
+func (s *graceStorage) SetIDPublicKey(correlationID, secretKey, publicKey string) error {
+	if err := s.Storage.SetIDPublicKey(correlationID, secretKey, publicKey); err != nil {
+		return err
+	}
+	for _, data := range s.grace.take(correlationID) {
+		if err := s.Storage.AddInteraction(correlationID, data); err != nil {
+			s.grace.options.recordDrop(correlationID, data, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraceStorage(t *testing.T) {
+	options := newTestOptions(t)
+	store := options.Storage
+
+	grace := NewGraceBuffer(time.Minute, options)
+	options.Storage = WrapDropStorage(grace.WrapStorage(store), options)
+
+	data, err := jsoniter.Marshal(&Interaction{Protocol: "dns"})
+	require.Nil(t, err)
+	// the interactions fired before the registration are buffered
+	require.Nil(t, options.Storage.AddInteraction("c59e3crp82ke7bcnedq0", data))
+	require.Nil(t, options.Storage.AddInteraction("c59e3crp82ke7bcnedq0", data))
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+	publicKey, err := x509.MarshalPKIXPublicKey(key.Public())
+	require.Nil(t, err)
+	encoded := base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: publicKey}))
+	require.Nil(t, options.Storage.SetIDPublicKey("c59e3crp82ke7bcnedq0", "secret", encoded))
+
+	interactions, _, err := store.GetInteractions("c59e3crp82ke7bcnedq0", "secret")
+	require.Nil(t, err)
+	require.Len(t, interactions, 2)
+	require.EqualValues(t, 0, options.Stats.Dropped.Snapshot().Total)
+
+	// the interactions of ids never registered are dropped once expired
+	require.Nil(t, options.Storage.AddInteraction("c59e3crp82ke7bcnedq1", data))
+	grace.window = 0
+	grace.Sweep()
+	require.Equal(t, map[string]uint64{DropUnregistered: 1}, options.Stats.Dropped.Snapshot().Reasons)
+	require.Empty(t, grace.take("c59e3crp82ke7bcnedq1"))
+}
+
+func TestGraceBufferLimits(t *testing.T) {
+	defaultLimit := MaxGraceInteractionsPerID
+	MaxGraceInteractionsPerID = 1
+	defer func() { MaxGraceInteractionsPerID = defaultLimit }()
+
+	grace := NewGraceBuffer(time.Minute, &Options{})
+	require.True(t, grace.add("c59e3crp82ke7bcnedq0", nil))
+	require.False(t, grace.add("c59e3crp82ke7bcnedq0", nil))
+	require.True(t, grace.add("c59e3crp82ke7bcnedq1", nil))
+	require.Len(t, grace.take("c59e3crp82ke7bcnedq0"), 1)
+	require.Len(t, grace.pending, 1)
+}
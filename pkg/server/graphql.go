@@ -0,0 +1,272 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// graphQLTypenameQuery matches the { __typename } queries used to detect graphql endpoints
+var graphQLTypenameQuery = regexp.MustCompile(`^\s*(?:query\s*\w*\s*)?\{\s*__typename\s*\}\s*$`)
+
+// GraphQLOperation is an operation of a graphql request
+type GraphQLOperation struct {
+	// Query is the graphql document of the operation
+	Query string `json:"query"`
+	// OperationName is the name of the operation to execute in the document
+	OperationName string `json:"operation-name,omitempty"`
+	// Variables are the json encoded variables of the operation
+	Variables string `json:"variables,omitempty"`
+	// Introspection reports whether the operation queries the schema
+	Introspection bool `json:"introspection,omitempty"`
+}
+
+// graphQLRequest is the list of operations of a (batched) graphql request
+type graphQLRequest struct {
+	operations []GraphQLOperation
+	batch      bool
+}
+
+// graphQLField is a field of the canned schema, with its arguments as "name: Type"
+type graphQLField struct {
+	name string
+	kind string
+	args []string
+}
+
+// graphQLSchema is the canned schema answered to introspection queries, with
+// the fields usually probed by api scanners
+var graphQLSchema = []struct {
+	name   string
+	fields []graphQLField
+}{
+	{"Query", []graphQLField{
+		{"me", "User", nil},
+		{"user", "User", []string{"id: ID!"}},
+		{"users", "[User!]!", []string{"first: Int", "after: String"}},
+		{"search", "[User!]!", []string{"query: String!"}},
+	}},
+	{"Mutation", []graphQLField{
+		{"login", "AuthPayload", []string{"username: String!", "password: String!"}},
+		{"updateProfile", "User", []string{"id: ID!", "email: String", "role: String"}},
+		{"importFromUrl", "Boolean", []string{"url: String!"}},
+		{"sendWebhook", "Boolean", []string{"url: String!", "payload: String"}},
+	}},
+	{"User", []graphQLField{
+		{"id", "ID!", nil},
+		{"username", "String!", nil},
+		{"email", "String", nil},
+		{"role", "String", nil},
+		{"apiKey", "String", nil},
+	}},
+	{"AuthPayload", []graphQLField{
+		{"token", "String", nil},
+		{"user", "User", nil},
+	}},
+}
+
+// graphQLScalars are the scalar types of the canned schema
+var graphQLScalars = []string{"ID", "String", "Int", "Boolean"}
+
+// graphQLIntrospection is the introspection result of the canned schema
+var graphQLIntrospection = buildGraphQLIntrospection()
+
+// isGraphQLPath reports whether a path segment is graphql (eg. /graphql, /api/graphql, /graphql/<id>)
+func isGraphQLPath(path string) bool {
+	for _, segment := range strings.Split(path, "/") {
+		if strings.EqualFold(segment, "graphql") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseGraphQLRequest returns the operations of a graphql request, from the
+// query string, a json (batched) body or an application/graphql body
+func parseGraphQLRequest(r *http.Request, body []byte) *graphQLRequest {
+	if !isGraphQLPath(r.URL.Path) {
+		return nil
+	}
+	request := &graphQLRequest{}
+	type payload struct {
+		Query         string              `json:"query"`
+		OperationName string              `json:"operationName"`
+		Variables     jsoniter.RawMessage `json:"variables"`
+	}
+	var payloads []payload
+	body = bytes.TrimSpace(body)
+	switch {
+	case r.Method == http.MethodGet:
+		values := r.URL.Query()
+		payloads = append(payloads, payload{Query: values.Get("query"), OperationName: values.Get("operationName"), Variables: jsoniter.RawMessage(values.Get("variables"))})
+	case strings.HasPrefix(r.Header.Get("Content-Type"), "application/graphql"):
+		payloads = append(payloads, payload{Query: string(body)})
+	case bytes.HasPrefix(body, []byte("[")):
+		request.batch = true
+		if err := jsoniter.Unmarshal(body, &payloads); err != nil {
+			return nil
+		}
+	default:
+		var single payload
+		if err := jsoniter.Unmarshal(body, &single); err != nil {
+			return nil
+		}
+		payloads = append(payloads, single)
+	}
+	for _, payload := range payloads {
+		if payload.Query == "" {
+			continue
+		}
+		variables := string(payload.Variables)
+		if variables == "null" {
+			variables = ""
+		}
+		request.operations = append(request.operations, GraphQLOperation{
+			Query:         payload.Query,
+			OperationName: payload.OperationName,
+			Variables:     variables,
+			Introspection: strings.Contains(payload.Query, "__schema") || strings.Contains(payload.Query, "__type("),
+		})
+	}
+	if len(request.operations) == 0 {
+		return nil
+	}
+	return request
+}
+
+// marker returns the description of the operations appended to the raw request
+func (g *graphQLRequest) marker() string {
+	if g == nil {
+		return ""
+	}
+	var builder strings.Builder
+	for i, operation := range g.operations {
+		builder.WriteString("\n\nGraphQL Operation")
+		if g.batch {
+			builder.WriteString(fmt.Sprintf(" %d", i+1))
+		}
+		if operation.OperationName != "" {
+			builder.WriteString(" " + operation.OperationName)
+		}
+		if operation.Introspection {
+			builder.WriteString(" (introspection)")
+		}
+		builder.WriteString(":\n" + operation.Query + "\n")
+		if operation.Variables != "" {
+			builder.WriteString("Variables: " + operation.Variables + "\n")
+		}
+	}
+	return builder.String()
+}
+
+// apply sets the graphql fields of the interaction
+func (g *graphQLRequest) apply(interaction *Interaction) {
+	if g == nil {
+		return
+	}
+	interaction.GraphQLOperations = g.operations
+}
+
+// writeGraphQLResponse answers the operations, introspection queries with the
+// canned schema and the other queries with an authentication error
+func writeGraphQLResponse(w http.ResponseWriter, request *graphQLRequest) {
+	w.Header().Set("Content-Type", "application/json")
+	if request == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = jsoniter.NewEncoder(w).Encode(map[string]interface{}{"errors": []interface{}{map[string]string{"message": "Must provide query string."}}})
+		return
+	}
+	var responses []interface{}
+	for _, operation := range request.operations {
+		switch {
+		case operation.Introspection:
+			responses = append(responses, graphQLIntrospection)
+		case graphQLTypenameQuery.MatchString(operation.Query):
+			responses = append(responses, map[string]interface{}{"data": map[string]string{"__typename": "Query"}})
+		default:
+			responses = append(responses, map[string]interface{}{
+				"data":   nil,
+				"errors": []interface{}{map[string]interface{}{"message": "Unauthorized", "extensions": map[string]string{"code": "UNAUTHENTICATED"}}},
+			})
+		}
+	}
+	if request.batch {
+		_ = jsoniter.NewEncoder(w).Encode(responses)
+		return
+	}
+	_ = jsoniter.NewEncoder(w).Encode(responses[0])
+}
+
+// buildGraphQLIntrospection returns the introspection result of the canned schema
+func buildGraphQLIntrospection() map[string]interface{} {
+	var types []interface{}
+	for _, scalar := range graphQLScalars {
+		types = append(types, graphQLType("SCALAR", scalar, nil))
+	}
+	for _, object := range graphQLSchema {
+		var fields []interface{}
+		for _, field := range object.fields {
+			args := []interface{}{}
+			for _, arg := range field.args {
+				name, kind, _ := strings.Cut(arg, ": ")
+				args = append(args, map[string]interface{}{"name": name, "description": nil, "type": graphQLTypeRef(kind), "defaultValue": nil})
+			}
+			fields = append(fields, map[string]interface{}{
+				"name":              field.name,
+				"description":       nil,
+				"args":              args,
+				"type":              graphQLTypeRef(field.kind),
+				"isDeprecated":      false,
+				"deprecationReason": nil,
+			})
+		}
+		types = append(types, graphQLType("OBJECT", object.name, fields))
+	}
+	return map[string]interface{}{"data": map[string]interface{}{"__schema": map[string]interface{}{
+		"queryType":        map[string]string{"name": "Query"},
+		"mutationType":     map[string]string{"name": "Mutation"},
+		"subscriptionType": nil,
+		"types":            types,
+		"directives":       []interface{}{},
+	}}}
+}
+
+// graphQLType returns the introspection of a scalar or object type
+func graphQLType(kind, name string, fields []interface{}) map[string]interface{} {
+	introspection := map[string]interface{}{
+		"kind":          kind,
+		"name":          name,
+		"description":   nil,
+		"fields":        nil,
+		"inputFields":   nil,
+		"interfaces":    nil,
+		"enumValues":    nil,
+		"possibleTypes": nil,
+	}
+	if kind == "OBJECT" {
+		introspection["fields"] = fields
+		introspection["interfaces"] = []interface{}{}
+	}
+	return introspection
+}
+
+// graphQLTypeRef returns the introspection of a type reference (eg. [User!]!)
+func graphQLTypeRef(kind string) map[string]interface{} {
+	if strings.HasSuffix(kind, "!") {
+		return map[string]interface{}{"kind": "NON_NULL", "name": nil, "ofType": graphQLTypeRef(strings.TrimSuffix(kind, "!"))}
+	}
+	if strings.HasPrefix(kind, "[") && strings.HasSuffix(kind, "]") {
+		return map[string]interface{}{"kind": "LIST", "name": nil, "ofType": graphQLTypeRef(kind[1 : len(kind)-1])}
+	}
+	named := "OBJECT"
+	for _, scalar := range graphQLScalars {
+		if scalar == kind {
+			named = "SCALAR"
+		}
+	}
+	return map[string]interface{}{"kind": named, "name": kind, "ofType": nil}
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGraphQLRequest(t *testing.T) {
+	require.True(t, isGraphQLPath("/api/GraphQL"))
+	require.False(t, isGraphQLPath("/graphql.php"))
+
+	request := parseGraphQLRequest(httptest.NewRequest(http.MethodGet, `/graphql?query={__typename}&variables=null`, nil), nil)
+	require.Equal(t, []GraphQLOperation{{Query: "{__typename}"}}, request.operations)
+
+	body := `[{"query":"query IntrospectionQuery { __schema { types { name } } }","operationName":"IntrospectionQuery"},{"query":"mutation { importFromUrl(url: $url) }","variables":{"url":"http://x"}}]`
+	request = parseGraphQLRequest(httptest.NewRequest(http.MethodPost, "/graphql", nil), []byte(body))
+	require.True(t, request.batch)
+	require.Equal(t, []GraphQLOperation{
+		{Query: "query IntrospectionQuery { __schema { types { name } } }", OperationName: "IntrospectionQuery", Introspection: true},
+		{Query: "mutation { importFromUrl(url: $url) }", Variables: `{"url":"http://x"}`},
+	}, request.operations)
+
+	require.Nil(t, parseGraphQLRequest(httptest.NewRequest(http.MethodGet, "/api", nil), nil))
+	require.Nil(t, parseGraphQLRequest(httptest.NewRequest(http.MethodPost, "/graphql", nil), []byte("not json")))
+}
+
+func TestGraphQLEndpoint(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0")
+	store := options.Storage
+
+	server, err := NewHTTPServer(options)
+	require.Nil(t, err)
+
+	// the correlation id is in the path of an endpoint on the main domain
+	body := `{"query":"{ __schema { queryType { name } types { name kind } } }"}`
+	request := httptest.NewRequest(http.MethodPost, "http://interact.sh/graphql/c59e3crp82ke7bcnedq0cfjqdpeyyyyyy", strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	server.nontlsserver.Handler.ServeHTTP(recorder, request)
+	var response struct {
+		Data struct {
+			Schema struct {
+				QueryType struct {
+					Name string `json:"name"`
+				} `json:"queryType"`
+				Types []struct {
+					Name string `json:"name"`
+				} `json:"types"`
+			} `json:"__schema"`
+		} `json:"data"`
+	}
+	require.Nil(t, jsoniter.Unmarshal(recorder.Body.Bytes(), &response))
+	require.Equal(t, "Query", response.Data.Schema.QueryType.Name)
+	require.Len(t, response.Data.Schema.Types, len(graphQLScalars)+len(graphQLSchema))
+
+	item, err := store.GetCacheItem("c59e3crp82ke7bcnedq0")
+	require.Nil(t, err)
+	require.Len(t, item.Data, 1)
+	interaction := &Interaction{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[0]), interaction))
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy", interaction.FullId)
+	require.Len(t, interaction.GraphQLOperations, 1)
+	require.True(t, interaction.GraphQLOperations[0].Introspection)
+	require.Contains(t, interaction.RawRequest, "GraphQL Operation (introspection):")
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// grpcFrameHeaderSize is the size of the compressed flag and length prefix of a grpc message
+const grpcFrameHeaderSize = 5
+
+// grpcRequest is a grpc call received over http/2, its length prefixed
+// messages are kept as raw protobuf as the schema is unknown
+type grpcRequest struct {
+	method string
+	frames []string
+}
+
+// isGRPCRequest reports whether the request is a grpc call
+func isGRPCRequest(r *http.Request) bool {
+	return r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc")
+}
+
+// parseGRPCRequest returns the grpc call of the request with the hex encoded
+// messages of its body, nil if the request is not a grpc call
+func parseGRPCRequest(r *http.Request, body []byte) *grpcRequest {
+	if !isGRPCRequest(r) {
+		return nil
+	}
+	return newGRPCRequest(r.URL.Path, body)
+}
+
+// newGRPCRequest returns the grpc call of the method with the hex encoded
+// length prefixed messages of the body
+func newGRPCRequest(method string, body []byte) *grpcRequest {
+	request := &grpcRequest{method: method}
+	for len(body) >= grpcFrameHeaderSize {
+		length := binary.BigEndian.Uint32(body[1:grpcFrameHeaderSize])
+		body = body[grpcFrameHeaderSize:]
+		// the last message is partial when the body capture is truncated
+		if uint64(length) > uint64(len(body)) {
+			length = uint32(len(body))
+		}
+		request.frames = append(request.frames, hex.EncodeToString(body[:length]))
+		body = body[length:]
+	}
+	return request
+}
+
+// marker returns the description of the grpc call appended to the raw request
+func (g *grpcRequest) marker() string {
+	if g == nil {
+		return ""
+	}
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("\n\ngRPC Method: %s\n", g.method))
+	for i, frame := range g.frames {
+		builder.WriteString(fmt.Sprintf("Message %d (%d bytes): %s\n", i+1, len(frame)/2, frame))
+	}
+	return builder.String()
+}
+
+// apply sets the grpc fields of the interaction
+func (g *grpcRequest) apply(interaction *Interaction) {
+	if g == nil {
+		return
+	}
+	interaction.GRPCMethod = g.method
+	interaction.GRPCMessages = g.frames
+}
+
+// writeGRPCResponse answers a grpc call with an empty message, which decodes
+// as the default value of any protobuf message, and an OK status
+func writeGRPCResponse(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/grpc")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(make([]byte, grpcFrameHeaderSize))
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", "0")
+}
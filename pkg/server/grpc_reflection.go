@@ -0,0 +1,324 @@
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// protobuf wire types
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+// grpc status codes answered by the grpc server
+const (
+	grpcStatusOK            = 0
+	grpcStatusNotFound      = 5
+	grpcStatusUnimplemented = 12
+	grpcStatusInternal      = 13
+)
+
+// grpcReflectionServices are the server reflection services answered by the grpc server
+var grpcReflectionServices = []string{"grpc.reflection.v1.ServerReflection", "grpc.reflection.v1alpha.ServerReflection"}
+
+// grpcHealthService is the health service answered with SERVING
+const grpcHealthService = "grpc.health.v1.Health"
+
+// grpcDecoyField is a field of a decoy message
+type grpcDecoyField struct {
+	name     string
+	kind     string
+	number   int
+	repeated bool
+}
+
+// grpcDecoyMethod is a method of a decoy service, with its input and output message names
+type grpcDecoyMethod struct {
+	name   string
+	input  string
+	output string
+}
+
+// grpcDecoyFile is a proto file of the decoy schema listed and described by
+// the server reflection, with the services usually probed in microservices
+type grpcDecoyFile struct {
+	name     string
+	pkg      string
+	messages map[string][]grpcDecoyField
+	services map[string][]grpcDecoyMethod
+}
+
+// grpcProtoTypes are the protobuf field types of the decoy message fields
+var grpcProtoTypes = map[string]uint64{"bool": 8, "string": 9, "bytes": 12, "int32": 5, "int64": 3}
+
+var grpcDecoyFiles = []grpcDecoyFile{
+	{
+		name: "internal/admin/v1/admin.proto",
+		pkg:  "internal.admin.v1",
+		messages: map[string][]grpcDecoyField{
+			"CommandRequest":  {{"command", "string", 1, false}, {"args", "string", 2, true}},
+			"CommandResponse": {{"output", "string", 1, false}, {"exit_code", "int32", 2, false}},
+			"FetchRequest":    {{"url", "string", 1, false}, {"headers", "string", 2, true}},
+			"FetchResponse":   {{"status", "int32", 1, false}, {"body", "bytes", 2, false}},
+			"ConfigRequest":   {{"key", "string", 1, false}},
+			"ConfigResponse":  {{"value", "string", 1, false}},
+		},
+		services: map[string][]grpcDecoyMethod{
+			"AdminService": {
+				{"Exec", "CommandRequest", "CommandResponse"},
+				{"FetchURL", "FetchRequest", "FetchResponse"},
+				{"GetConfig", "ConfigRequest", "ConfigResponse"},
+			},
+		},
+	},
+	{
+		name: "internal/users/v1/users.proto",
+		pkg:  "internal.users.v1",
+		messages: map[string][]grpcDecoyField{
+			"GetUserRequest":    {{"id", "string", 1, false}},
+			"ListUsersRequest":  {{"page_size", "int32", 1, false}, {"page_token", "string", 2, false}},
+			"ListUsersResponse": {{"users", "User", 1, true}, {"next_page_token", "string", 2, false}},
+			"User":              {{"id", "string", 1, false}, {"email", "string", 2, false}, {"api_key", "string", 3, false}, {"admin", "bool", 4, false}},
+		},
+		services: map[string][]grpcDecoyMethod{
+			"UserService": {
+				{"GetUser", "GetUserRequest", "User"},
+				{"ListUsers", "ListUsersRequest", "ListUsersResponse"},
+			},
+		},
+	},
+}
+
+// grpcServiceNames returns the services listed by the server reflection
+func grpcServiceNames() []string {
+	names := []string{grpcHealthService}
+	names = append(names, grpcReflectionServices...)
+	for _, file := range grpcDecoyFiles {
+		for _, service := range sortedKeys(file.services) {
+			names = append(names, file.pkg+"."+service)
+		}
+	}
+	return names
+}
+
+// isGRPCDecoyMethod reports whether the full method (/package.Service/Method) is a decoy method
+func isGRPCDecoyMethod(fullMethod string) bool {
+	service, method, ok := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !ok {
+		return false
+	}
+	for _, file := range grpcDecoyFiles {
+		for name, methods := range file.services {
+			if file.pkg+"."+name != service {
+				continue
+			}
+			for _, decoy := range methods {
+				if decoy.name == method {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// defines reports whether the file defines the symbol, a package, service,
+// method or message name
+func (f *grpcDecoyFile) defines(symbol string) bool {
+	if symbol == f.pkg {
+		return true
+	}
+	name, ok := strings.CutPrefix(symbol, f.pkg+".")
+	if !ok {
+		return false
+	}
+	if _, ok := f.messages[name]; ok {
+		return true
+	}
+	service, method, _ := strings.Cut(name, ".")
+	methods, ok := f.services[service]
+	if !ok {
+		return false
+	}
+	if method == "" {
+		return true
+	}
+	for _, decoy := range methods {
+		if decoy.name == method {
+			return true
+		}
+	}
+	return false
+}
+
+// descriptor returns the encoded FileDescriptorProto of the file
+func (f *grpcDecoyFile) descriptor() []byte {
+	var data []byte
+	data = appendProtoBytes(data, 1, []byte(f.name))
+	data = appendProtoBytes(data, 2, []byte(f.pkg))
+	for _, name := range sortedKeys(f.messages) {
+		message := appendProtoBytes(nil, 1, []byte(name))
+		for _, field := range f.messages[name] {
+			encoded := appendProtoBytes(nil, 1, []byte(field.name))
+			encoded = appendProtoVarint(encoded, 3, uint64(field.number))
+			label := uint64(1)
+			if field.repeated {
+				label = 3
+			}
+			encoded = appendProtoVarint(encoded, 4, label)
+			if kind, ok := grpcProtoTypes[field.kind]; ok {
+				encoded = appendProtoVarint(encoded, 5, kind)
+			} else {
+				// message type
+				encoded = appendProtoVarint(encoded, 5, 11)
+				encoded = appendProtoBytes(encoded, 6, []byte("."+f.pkg+"."+field.kind))
+			}
+			message = appendProtoBytes(message, 2, encoded)
+		}
+		data = appendProtoBytes(data, 4, message)
+	}
+	for _, name := range sortedKeys(f.services) {
+		service := appendProtoBytes(nil, 1, []byte(name))
+		for _, method := range f.services[name] {
+			encoded := appendProtoBytes(nil, 1, []byte(method.name))
+			encoded = appendProtoBytes(encoded, 2, []byte("."+f.pkg+"."+method.input))
+			encoded = appendProtoBytes(encoded, 3, []byte("."+f.pkg+"."+method.output))
+			service = appendProtoBytes(service, 2, encoded)
+		}
+		data = appendProtoBytes(data, 6, service)
+	}
+	return appendProtoBytes(data, 12, []byte("proto3"))
+}
+
+// grpcReflectionResponse answers a ServerReflectionRequest with the decoy
+// service list or file descriptors, and returns the described request
+func grpcReflectionResponse(request []byte) ([]byte, string) {
+	response := []byte{}
+	fields, err := parseProtoFields(request)
+	if err != nil {
+		return appendProtoBytes(response, 7, grpcReflectionError(grpcStatusInternal, "invalid reflection request")), "invalid"
+	}
+	for _, field := range fields {
+		if field.number == 1 && field.wireType == protoBytes {
+			response = appendProtoBytes(response, 1, field.value)
+		}
+	}
+	response = appendProtoBytes(response, 2, request)
+	for _, field := range fields {
+		value := string(field.value)
+		switch field.number {
+		case 7:
+			var services []byte
+			for _, name := range grpcServiceNames() {
+				services = appendProtoBytes(services, 1, appendProtoBytes(nil, 1, []byte(name)))
+			}
+			return appendProtoBytes(response, 6, services), "list_services"
+		case 3, 4:
+			kind := "file_by_filename " + value
+			if field.number == 4 {
+				kind = "file_containing_symbol " + value
+			}
+			for _, file := range grpcDecoyFiles {
+				if (field.number == 3 && file.name == value) || (field.number == 4 && file.defines(value)) {
+					return appendProtoBytes(response, 4, appendProtoBytes(nil, 1, file.descriptor())), kind
+				}
+			}
+			return appendProtoBytes(response, 7, grpcReflectionError(grpcStatusNotFound, "not found: "+value)), kind
+		case 5, 6:
+			return appendProtoBytes(response, 7, grpcReflectionError(grpcStatusNotFound, "extensions not found")), "extensions"
+		}
+	}
+	return appendProtoBytes(response, 7, grpcReflectionError(grpcStatusUnimplemented, "unsupported reflection request")), "unknown"
+}
+
+// grpcReflectionError returns an encoded reflection ErrorResponse
+func grpcReflectionError(code uint64, message string) []byte {
+	return appendProtoBytes(appendProtoVarint(nil, 1, code), 2, []byte(message))
+}
+
+// protoField is a field of an encoded protobuf message
+type protoField struct {
+	number   int
+	wireType int
+	value    []byte
+}
+
+// parseProtoFields returns the fields of an encoded protobuf message, the
+// value of the varint and fixed fields is kept encoded
+func parseProtoFields(data []byte) ([]protoField, error) {
+	var fields []protoField
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 || tag>>3 == 0 {
+			return nil, errors.New("invalid protobuf tag")
+		}
+		data = data[n:]
+		field := protoField{number: int(tag >> 3), wireType: int(tag & 0x07)}
+		switch field.wireType {
+		case protoVarint:
+			_, n = binary.Uvarint(data)
+			if n <= 0 {
+				return nil, errors.New("invalid protobuf varint")
+			}
+		case protoFixed64:
+			n = 8
+		case protoFixed32:
+			n = 4
+		case protoBytes:
+			length, size := binary.Uvarint(data)
+			if size <= 0 || length > uint64(len(data)-size) {
+				return nil, errors.New("invalid protobuf length")
+			}
+			data = data[size:]
+			n = int(length)
+		default:
+			return nil, errors.New("unsupported protobuf wire type")
+		}
+		if n > len(data) {
+			return nil, errors.New("truncated protobuf field")
+		}
+		field.value = data[:n]
+		fields = append(fields, field)
+		data = data[n:]
+	}
+	return fields, nil
+}
+
+// protoStrings returns the printable strings of an encoded protobuf message,
+// with the ones of its nested messages
+func protoStrings(data []byte, depth int) []string {
+	fields, err := parseProtoFields(data)
+	if err != nil || depth > 8 {
+		return nil
+	}
+	var values []string
+	for _, field := range fields {
+		if field.wireType != protoBytes || len(field.value) == 0 {
+			continue
+		}
+		// printable values are strings, the others may be nested messages
+		if utf8.Valid(field.value) && strings.IndexFunc(string(field.value), func(r rune) bool { return !unicode.IsPrint(r) && !unicode.IsSpace(r) }) < 0 {
+			values = append(values, string(field.value))
+			continue
+		}
+		values = append(values, protoStrings(field.value, depth+1)...)
+	}
+	return values
+}
+
+func appendProtoVarint(data []byte, number int, value uint64) []byte {
+	data = binary.AppendUvarint(data, uint64(number)<<3|protoVarint)
+	return binary.AppendUvarint(data, value)
+}
+
+func appendProtoBytes(data []byte, number int, value []byte) []byte {
+	data = binary.AppendUvarint(data, uint64(number)<<3|protoBytes)
+	data = binary.AppendUvarint(data, uint64(len(value)))
+	return append(data, value...)
+}
@@ -0,0 +1,269 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/gologger"
+	stringsutil "github.com/projectdiscovery/utils/strings"
+	"golang.org/x/net/http2"
+)
+
+const (
+	// grpcMaxMessage is the maximum size of a message received by the grpc server
+	grpcMaxMessage = 1 << 20
+	// grpcTimeout is the idle time after which a grpc connection is closed
+	grpcTimeout = 30 * time.Second
+)
+
+// GRPCServer is a grpc server over cleartext http/2 (h2c) and http/2 over
+// tls on the same port, answering server reflection with a decoy service
+// list and recording the method calls with their metadata and messages
+type GRPCServer struct {
+	options  *Options
+	listener net.Listener
+	http2    *http2.Server
+	mu       sync.Mutex
+}
+
+// NewGRPCServer returns a new grpc server
+func NewGRPCServer(options *Options) (*GRPCServer, error) {
+	return &GRPCServer{options: options, http2: &http2.Server{IdleTimeout: grpcTimeout}}, nil
+}
+
+// ListenAndServe listens on the grpc port, tls client hellos are answered
+// when a tls configuration is available
+func (h *GRPCServer) ListenAndServe(tlsConfig *tls.Config, grpcAlive chan bool) {
+	listener, err := h.options.Listeners.Listen("tcp", net.JoinHostPort(h.options.ListenIP, fmt.Sprint(h.options.GRPCPort)))
+	if err != nil {
+		gologger.Error().Msgf("Could not serve grpc on port %d: %s\n", h.options.GRPCPort, err)
+		grpcAlive <- false
+		return
+	}
+	h.mu.Lock()
+	h.listener = listener
+	h.mu.Unlock()
+	if tlsConfig != nil {
+		tlsConfig = h.options.listenerTLSConfig("grpc", tlsConfig)
+		tlsConfig.NextProtos = []string{http2.NextProtoTLS}
+	}
+	grpcAlive <- true
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				gologger.Error().Msgf("Could not accept grpc connection: %s\n", err)
+			}
+			return
+		}
+		go h.handleConn(conn, tlsConfig)
+	}
+}
+
+func (h *GRPCServer) handleConn(conn net.Conn, tlsConfig *tls.Config) {
+	defer conn.Close()
+
+	h.options.Stats.RecordSource("grpc", conn.RemoteAddr().String())
+	_ = conn.SetReadDeadline(time.Now().Add(grpcTimeout))
+	reader := bufio.NewReader(conn)
+	conn = &bufferedConn{Conn: conn, reader: reader}
+	if first, err := reader.Peek(1); err == nil && first[0] == tlsRecordHandshake && tlsConfig != nil {
+		tlsConn := tls.Server(conn, tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			gologger.Debug().Msgf("Grpc tls handshake from %s failed: %s\n", conn.RemoteAddr(), err)
+			return
+		}
+		conn = tlsConn
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+	h.http2.ServeConn(conn, &http2.ServeConnOpts{Handler: h})
+}
+
+// ServeHTTP answers a grpc call, reflection requests are answered as they
+// are received and the other calls once the client closes its stream
+func (h *GRPCServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !isGRPCRequest(r) {
+		http.Error(w, "invalid grpc content type", http.StatusUnsupportedMediaType)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	service, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	reflection := stringsutil.EqualFoldAny(service, grpcReflectionServices...)
+	metadata := grpcMetadata(r)
+	ids := make(map[string]string)
+	h.correlate(ids, r.Host)
+	if r.TLS != nil {
+		h.correlate(ids, r.TLS.ServerName)
+	}
+	for _, value := range metadata {
+		h.correlate(ids, value)
+	}
+
+	capture := newBodyCapture(h.options.captureLimit())
+	body := io.TeeReader(r.Body, capture)
+	var requests []string
+	status, message := grpcStatusOK, ""
+	for {
+		data, err := readGRPCMessage(body)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				status, message = grpcStatusInternal, err.Error()
+			}
+			break
+		}
+		h.correlate(ids, protoStrings(data, 0)...)
+		if reflection {
+			response, request := grpcReflectionResponse(data)
+			requests = append(requests, request)
+			writeGRPCMessage(w, response)
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+	switch {
+	case reflection || status != grpcStatusOK:
+	case service == grpcHealthService:
+		// status SERVING
+		writeGRPCMessage(w, appendProtoVarint(nil, 1, 1))
+	case isGRPCDecoyMethod(r.URL.Path):
+		writeGRPCMessage(w, nil)
+	default:
+		status, message = grpcStatusUnimplemented, fmt.Sprintf("unknown method %s", r.URL.Path)
+	}
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", fmt.Sprint(status))
+	if message != "" {
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", message)
+	}
+
+	if len(ids) == 0 {
+		return
+	}
+	call := newGRPCRequest(r.URL.Path, capture.Bytes())
+	req, _ := httputil.DumpRequest(r, false)
+	raw := string(req) + capture.marker() + call.marker()
+	for _, request := range requests {
+		raw += fmt.Sprintf("Reflection Request: %s\n", request)
+	}
+	response := fmt.Sprintf("Grpc-Status: %d\n", status)
+	if message != "" {
+		response += fmt.Sprintf("Grpc-Message: %s\n", message)
+	}
+	h.recordInteraction(r, raw, response, ids, metadata, requests, capture, call)
+}
+
+// grpcMetadata returns the metadata of a grpc call, the request headers
+// without the http/2 and grpc framing ones
+func grpcMetadata(r *http.Request) map[string]string {
+	metadata := make(map[string]string)
+	for name, values := range r.Header {
+		name = strings.ToLower(name)
+		if name == "content-type" || name == "te" {
+			continue
+		}
+		metadata[name] = strings.Join(values, ", ")
+	}
+	return metadata
+}
+
+// readGRPCMessage reads a length prefixed grpc message
+func readGRPCMessage(reader io.Reader) ([]byte, error) {
+	header := make([]byte, grpcFrameHeaderSize)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, errors.New("truncated grpc message")
+		}
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header[1:])
+	if length > grpcMaxMessage {
+		return nil, fmt.Errorf("grpc message of %d bytes exceeds the maximum size", length)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return nil, errors.New("truncated grpc message")
+	}
+	return data, nil
+}
+
+// writeGRPCMessage writes an uncompressed length prefixed grpc message
+func writeGRPCMessage(w io.Writer, data []byte) {
+	header := make([]byte, grpcFrameHeaderSize)
+	binary.BigEndian.PutUint32(header[1:], uint32(len(data)))
+	_, _ = w.Write(append(header, data...))
+}
+
+// correlate adds the correlation ids found in the values to the ids of a call
+func (h *GRPCServer) correlate(ids map[string]string, values ...string) {
+	for _, value := range values {
+		for _, chunk := range stringsutil.SplitAny(value, ".@/\\:;,=()`'\"<>?& \r\n\t") {
+			normalized := NormalizeHost(chunk)
+			for part := range stringsutil.SlideWithLength(normalized, h.options.GetIdLength()) {
+				if _, ok := ids[part]; !ok && h.options.isCorrelationID(part) {
+					ids[part] = normalized
+				}
+			}
+		}
+	}
+}
+
+// recordInteraction stores the call for each of its correlation ids
+func (h *GRPCServer) recordInteraction(r *http.Request, raw, response string, ids, metadata map[string]string, reflection []string, capture *bodyCapture, call *grpcRequest) {
+	host, _, _ := net.SplitHostPort(r.RemoteAddr)
+	for uniqueID, fullID := range ids {
+		interaction := &Interaction{
+			Protocol:       "grpc",
+			UniqueID:       uniqueID,
+			FullId:         fullID,
+			RawRequest:     raw,
+			RawResponse:    response,
+			RemoteAddress:  host,
+			Timestamp:      time.Now(),
+			GRPCMetadata:   metadata,
+			GRPCReflection: reflection,
+		}
+		if r.TLS != nil {
+			interaction.TLSServerName = r.TLS.ServerName
+		}
+		capture.apply(interaction)
+		call.apply(interaction)
+		h.options.applyNAT64(interaction)
+		buffer := &bytes.Buffer{}
+		if err := jsoniter.NewEncoder(buffer).Encode(interaction); err != nil {
+			gologger.Warning().Msgf("Could not encode grpc interaction: %s\n", err)
+			continue
+		}
+		gologger.Debug().Msgf("%s\n", buffer.String())
+		if err := h.options.Storage.AddInteraction(uniqueID[:h.options.CorrelationIdLength], buffer.Bytes()); err != nil {
+			gologger.Warning().Msgf("Could not store grpc interaction: %s\n", err)
+		}
+	}
+}
+
+// Close stops the grpc server
+func (h *GRPCServer) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.listener != nil {
+		_ = h.listener.Close()
+	}
+}
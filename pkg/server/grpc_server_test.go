@@ -0,0 +1,123 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+)
+
+func TestGRPCReflectionResponse(t *testing.T) {
+	// list_services
+	response, request := grpcReflectionResponse(appendProtoBytes(appendProtoBytes(nil, 1, []byte("localhost")), 7, []byte("*")))
+	require.Equal(t, "list_services", request)
+	fields, err := parseProtoFields(response)
+	require.Nil(t, err)
+	require.Equal(t, []byte("localhost"), fields[0].value)
+	require.Equal(t, 6, fields[2].number)
+	var services []string
+	entries, err := parseProtoFields(fields[2].value)
+	require.Nil(t, err)
+	for _, entry := range entries {
+		services = append(services, protoStrings(entry.value, 0)...)
+	}
+	require.Equal(t, grpcServiceNames(), services)
+	require.Contains(t, services, "internal.admin.v1.AdminService")
+
+	// file_containing_symbol of a decoy method
+	response, request = grpcReflectionResponse(appendProtoBytes(nil, 4, []byte("internal.users.v1.UserService.GetUser")))
+	require.Equal(t, "file_containing_symbol internal.users.v1.UserService.GetUser", request)
+	fields, err = parseProtoFields(response)
+	require.Nil(t, err)
+	require.Equal(t, 4, fields[1].number)
+	require.Contains(t, string(fields[1].value), ".internal.users.v1.GetUserRequest")
+
+	response, _ = grpcReflectionResponse(appendProtoBytes(nil, 4, []byte("unknown.Service")))
+	fields, err = parseProtoFields(response)
+	require.Nil(t, err)
+	require.Equal(t, 7, fields[1].number)
+
+	require.Equal(t, []string{"http://c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh", "x-id"}, protoStrings(appendProtoBytes(appendProtoBytes(nil, 1, []byte("http://c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh")), 2, appendProtoBytes(nil, 1, []byte("x-id"))), 0))
+}
+
+func TestGRPCServer(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0")
+	store := options.Storage
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	listeners := NewListeners()
+	listeners.addListener(listener)
+	options.ListenIP = "127.0.0.1"
+	options.GRPCPort = listener.Addr().(*net.TCPAddr).Port
+	options.Listeners = listeners
+	server, err := NewGRPCServer(options)
+	require.Nil(t, err)
+	alive := make(chan bool, 1)
+	go server.ListenAndServe(nil, alive)
+	require.True(t, <-alive)
+	defer server.Close()
+
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, listener.Addr().String())
+		},
+	}}
+	call := func(host, method string, messages ...[]byte) ([]byte, *http.Response) {
+		body := &bytes.Buffer{}
+		for _, message := range messages {
+			writeGRPCMessage(body, message)
+		}
+		request, err := http.NewRequest(http.MethodPost, "http://"+host+method, body)
+		require.Nil(t, err)
+		request.Header.Set("Content-Type", "application/grpc")
+		request.Header.Set("X-Request-Id", "42")
+		response, err := client.Do(request)
+		require.Nil(t, err)
+		data, err := io.ReadAll(response.Body)
+		require.Nil(t, err)
+		response.Body.Close()
+		return data, response
+	}
+
+	// reflection with the correlation id in the authority
+	data, response := call("c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh", "/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo", appendProtoBytes(nil, 7, []byte("*")))
+	require.Equal(t, "0", response.Trailer.Get("Grpc-Status"))
+	message, err := readGRPCMessage(bytes.NewReader(data))
+	require.Nil(t, err)
+	require.Contains(t, string(message), "internal.users.v1.UserService")
+
+	// decoy method with the correlation id in a message field
+	data, response = call("127.0.0.1", "/internal.admin.v1.AdminService/FetchURL", appendProtoBytes(nil, 1, []byte("http://c59e3crp82ke7bcnedq0cfjqdpeyyyyzz.interact.sh/")))
+	require.Equal(t, "0", response.Trailer.Get("Grpc-Status"))
+	require.Equal(t, make([]byte, grpcFrameHeaderSize), data)
+
+	// unknown method
+	_, response = call("127.0.0.1", "/unknown.Service/Method", nil)
+	require.Equal(t, "12", response.Trailer.Get("Grpc-Status"))
+
+	item, err := store.GetCacheItem("c59e3crp82ke7bcnedq0")
+	require.Nil(t, err)
+	require.Len(t, item.Data, 2)
+	interactions := make(map[string]*Interaction)
+	for _, data := range item.Data {
+		interaction := &Interaction{}
+		require.Nil(t, jsoniter.Unmarshal([]byte(data), interaction))
+		require.Equal(t, "grpc", interaction.Protocol)
+		require.Equal(t, "42", interaction.GRPCMetadata["x-request-id"])
+		interactions[interaction.GRPCMethod] = interaction
+	}
+	require.Equal(t, []string{"list_services"}, interactions["/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo"].GRPCReflection)
+	fetch := interactions["/internal.admin.v1.AdminService/FetchURL"]
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyzz", fetch.FullId)
+	require.Len(t, fetch.GRPCMessages, 1)
+	require.Contains(t, fetch.RawRequest, "gRPC Method: /internal.admin.v1.AdminService/FetchURL")
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+)
+
+func TestGRPCCapture(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0")
+	store := options.Storage
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	server, err := NewHTTPServer(options)
+	require.Nil(t, err)
+	go func() { _ = server.nontlsserver.Serve(listener) }()
+	defer server.nontlsserver.Close()
+
+	// grpc call over cleartext http/2 with prior knowledge
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, listener.Addr().String())
+		},
+	}}
+	body := []byte{0, 0, 0, 0, 3, 0x0a, 0x01, 0x41, 0, 0, 0, 0, 2, 0x08, 0x01}
+	request, err := http.NewRequest(http.MethodPost, "http://c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh/grpc.health.v1.Health/Check", bytes.NewReader(body))
+	require.Nil(t, err)
+	request.Header.Set("Content-Type", "application/grpc")
+	response, err := client.Do(request)
+	require.Nil(t, err)
+	data, err := io.ReadAll(response.Body)
+	require.Nil(t, err)
+	response.Body.Close()
+	require.Equal(t, "HTTP/2.0", response.Proto)
+	require.Equal(t, make([]byte, 5), data)
+	require.Equal(t, "0", response.Trailer.Get("Grpc-Status"))
+
+	item, err := store.GetCacheItem("c59e3crp82ke7bcnedq0")
+	require.Nil(t, err)
+	require.Len(t, item.Data, 1)
+	interaction := &Interaction{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[0]), interaction))
+	require.Equal(t, "http", interaction.Protocol)
+	require.Equal(t, "/grpc.health.v1.Health/Check", interaction.GRPCMethod)
+	require.Equal(t, []string{"0a0141", "0801"}, interaction.GRPCMessages)
+	require.Contains(t, interaction.RawRequest, "POST /grpc.health.v1.Health/Check HTTP/2.0")
+	require.Contains(t, interaction.RawRequest, "Message 1 (3 bytes): 0a0141")
+}
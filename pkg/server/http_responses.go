@@ -0,0 +1,137 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// HTTPResponse is a response served for a path on every domain and session,
+// for validators expecting a literal body (eg. /healthz, robots.txt, security.txt)
+type HTTPResponse struct {
+	// Path is the request path, a trailing * matches the paths with its prefix
+	Path string `yaml:"path" json:"path"`
+	// Status is the status code of the response, 200 if zero
+	Status int `yaml:"status,omitempty" json:"status,omitempty"`
+	// ContentType is the value of the Content-Type header, if any
+	ContentType string `yaml:"content-type,omitempty" json:"content-type,omitempty"`
+	// Headers are additional headers sent with the response
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	// Cookies are the request cookies required by the response, a * value
+	// matching any value of the cookie
+	Cookies map[string]string `yaml:"cookies,omitempty" json:"cookies,omitempty"`
+	// SetCookies are the cookies set by the response, scoped to the request host
+	SetCookies map[string]string `yaml:"set-cookies,omitempty" json:"set-cookies,omitempty"`
+	// Body is the response body, supports {DOMAIN} and {COOKIE:name} placeholders
+	Body string `yaml:"body,omitempty" json:"body,omitempty"`
+	// BodyFile is a file whose content is served as body instead of Body
+	BodyFile string `yaml:"body-file,omitempty" json:"body-file,omitempty"`
+}
+
+// NewHTTPResponses loads a yaml or json file of global http responses
+func NewHTTPResponses(path string) ([]*HTTPResponse, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config struct {
+		Responses []*HTTPResponse `yaml:"responses"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	paths := make(map[string]struct{})
+	for _, response := range config.Responses {
+		if !strings.HasPrefix(response.Path, "/") {
+			return nil, errors.New("http responses require a path starting with /")
+		}
+		// responses of a path differ by the cookies they require
+		key := response.Path
+		for _, name := range sortedKeys(response.Cookies) {
+			key += fmt.Sprintf(";%s=%s", name, response.Cookies[name])
+		}
+		if _, ok := paths[key]; ok {
+			return nil, fmt.Errorf("duplicate http response for path %s", response.Path)
+		}
+		paths[key] = struct{}{}
+		if response.Status == 0 {
+			response.Status = http.StatusOK
+		}
+		if response.Status < 100 || response.Status > 999 {
+			return nil, fmt.Errorf("invalid status %d for http response %s", response.Status, response.Path)
+		}
+		if response.BodyFile != "" {
+			body, err := os.ReadFile(response.BodyFile)
+			if err != nil {
+				return nil, fmt.Errorf("could not read body of http response %s: %w", response.Path, err)
+			}
+			response.Body = string(body)
+		}
+	}
+	return config.Responses, nil
+}
+
+// httpResponseOf returns the configured response of a request, exact paths
+// taking precedence over the longest matching prefix, then the responses
+// requiring the most cookies over the others
+func (options *Options) httpResponseOf(req *http.Request) *HTTPResponse {
+	var (
+		match       *HTTPResponse
+		matchLength int
+	)
+	for _, response := range options.getHTTPResponses() {
+		length := len(response.Path)
+		if response.Path == req.URL.Path {
+			// exact paths rank above any prefix
+			length = math.MaxInt32
+		} else if prefix, ok := strings.CutSuffix(response.Path, "*"); !ok || !strings.HasPrefix(req.URL.Path, prefix) {
+			continue
+		}
+		if !response.matchesCookies(req) {
+			continue
+		}
+		if match == nil || length > matchLength || (length == matchLength && len(response.Cookies) > len(match.Cookies)) {
+			match, matchLength = response, length
+		}
+	}
+	return match
+}
+
+// matchesCookies reports whether the request has the cookies required by the response
+func (response *HTTPResponse) matchesCookies(req *http.Request) bool {
+	for name, value := range response.Cookies {
+		cookie, err := req.Cookie(name)
+		if err != nil || (value != "*" && cookie.Value != value) {
+			return false
+		}
+	}
+	return true
+}
+
+// write writes the response, replacing the {DOMAIN} and {COOKIE:name}
+// placeholders of the body and the cookies set
+func (response *HTTPResponse) write(w http.ResponseWriter, req *http.Request, domain string) {
+	replacer := func(value string) string {
+		value = strings.ReplaceAll(value, "{DOMAIN}", domain)
+		for _, cookie := range req.Cookies() {
+			value = strings.ReplaceAll(value, "{COOKIE:"+cookie.Name+"}", cookie.Value)
+		}
+		return value
+	}
+	for key, value := range response.Headers {
+		w.Header().Set(key, value)
+	}
+	if response.ContentType != "" {
+		w.Header().Set("Content-Type", response.ContentType)
+	}
+	for _, name := range sortedKeys(response.SetCookies) {
+		http.SetCookie(w, &http.Cookie{Name: name, Value: replacer(response.SetCookies[name]), Path: "/", HttpOnly: true})
+	}
+	w.WriteHeader(response.Status)
+	_, _ = w.Write([]byte(replacer(response.Body)))
+}
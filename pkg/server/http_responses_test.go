@@ -0,0 +1,141 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPResponses(t *testing.T) {
+	dir := t.TempDir()
+	security := filepath.Join(dir, "security.txt")
+	require.Nil(t, os.WriteFile(security, []byte("Contact: mailto:security@{DOMAIN}\n"), 0600))
+	config := `responses:
+  - path: /healthz
+    content-type: application/json
+    body: '{"status":"ok"}'
+  - path: /.well-known/security.txt
+    body-file: ` + security + `
+  - path: /verify/*
+    status: 202
+    headers:
+      X-Verify: interactsh
+    body: verified
+`
+	path := filepath.Join(dir, "responses.yaml")
+	require.Nil(t, os.WriteFile(path, []byte(config), 0600))
+	responses, err := NewHTTPResponses(path)
+	require.Nil(t, err)
+	require.Len(t, responses, 3)
+
+	options := &Options{
+		Domains:                  []string{"interact.sh"},
+		HTTPResponses:            responses,
+		Stats:                    &Metrics{},
+		CorrelationIdLength:      20,
+		CorrelationIdNonceLength: 13,
+	}
+	server, err := NewHTTPServer(options)
+	require.Nil(t, err)
+
+	recorder := httptest.NewRecorder()
+	server.defaultHandler(recorder, httptest.NewRequest(http.MethodGet, "http://c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh/healthz", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+	require.Equal(t, `{"status":"ok"}`, recorder.Body.String())
+
+	recorder = httptest.NewRecorder()
+	server.defaultHandler(recorder, httptest.NewRequest(http.MethodGet, "http://interact.sh/.well-known/security.txt", nil))
+	require.Equal(t, "Contact: mailto:security@interact.sh\n", recorder.Body.String())
+
+	recorder = httptest.NewRecorder()
+	server.defaultHandler(recorder, httptest.NewRequest(http.MethodGet, "http://interact.sh/verify/abc", nil))
+	require.Equal(t, http.StatusAccepted, recorder.Code)
+	require.Equal(t, "interactsh", recorder.Header().Get("X-Verify"))
+	require.Equal(t, "verified", recorder.Body.String())
+
+	// the other paths are served as usual
+	recorder = httptest.NewRecorder()
+	server.defaultHandler(recorder, httptest.NewRequest(http.MethodGet, "http://interact.sh/robots.txt", nil))
+	require.Contains(t, recorder.Body.String(), "User-agent: *")
+
+	require.Nil(t, os.WriteFile(path, []byte("responses:\n  - path: healthz\n"), 0600))
+	_, err = NewHTTPResponses(path)
+	require.NotNil(t, err)
+}
+
+func TestHTTPResponsesCookies(t *testing.T) {
+	config := `responses:
+  - path: /authorize
+    status: 302
+    headers:
+      Location: /callback
+    set-cookies:
+      state: authorized
+  - path: /callback
+    status: 401
+    body: missing state
+  - path: /callback
+    cookies:
+      state: authorized
+    set-cookies:
+      session: '{COOKIE:state}-{DOMAIN}'
+    body: code for {COOKIE:state}
+  - path: /profile
+    cookies:
+      session: '*'
+    body: '{"user":"{COOKIE:session}"}'
+`
+	path := filepath.Join(t.TempDir(), "responses.yaml")
+	require.Nil(t, os.WriteFile(path, []byte(config), 0600))
+	responses, err := NewHTTPResponses(path)
+	require.Nil(t, err)
+	require.Len(t, responses, 4)
+
+	options := &Options{
+		Domains:                  []string{"interact.sh"},
+		HTTPResponses:            responses,
+		Stats:                    &Metrics{},
+		CorrelationIdLength:      20,
+		CorrelationIdNonceLength: 13,
+	}
+	server, err := NewHTTPServer(options)
+	require.Nil(t, err)
+	serve := func(path string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, "http://c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh"+path, nil)
+		for _, cookie := range cookies {
+			request.AddCookie(cookie)
+		}
+		recorder := httptest.NewRecorder()
+		server.defaultHandler(recorder, request)
+		return recorder
+	}
+
+	recorder := serve("/authorize")
+	require.Equal(t, http.StatusFound, recorder.Code)
+	cookies := recorder.Result().Cookies()
+	require.Len(t, cookies, 1)
+	require.Equal(t, "state", cookies[0].Name)
+	require.Equal(t, "authorized", cookies[0].Value)
+
+	// the callback varies with the cookie set by the previous step
+	require.Equal(t, http.StatusUnauthorized, serve("/callback").Code)
+	recorder = serve("/callback", cookies[0])
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, "code for authorized", recorder.Body.String())
+	cookies = recorder.Result().Cookies()
+	require.Len(t, cookies, 1)
+	require.Equal(t, "authorized-interact.sh", cookies[0].Value)
+
+	require.Equal(t, `{"user":"authorized-interact.sh"}`, serve("/profile", cookies[0]).Body.String())
+	require.NotContains(t, serve("/profile").Body.String(), "user")
+
+	// responses of a path requiring the same cookies are duplicates
+	require.Nil(t, os.WriteFile(path, []byte("responses:\n  - path: /a\n    cookies:\n      s: x\n  - path: /a\n    cookies:\n      s: x\n"), 0600))
+	_, err = NewHTTPResponses(path)
+	require.NotNil(t, err)
+}
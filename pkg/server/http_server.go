@@ -2,15 +2,20 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/http/httputil"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -18,8 +23,12 @@ import (
 	"time"
 
 	jsoniter "github.com/json-iterator/go"
+	"github.com/miekg/dns"
 	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/interactsh/pkg/storage"
 	stringsutil "github.com/projectdiscovery/utils/strings"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 // HTTPServer is a http server instance that listens both
@@ -28,8 +37,12 @@ type HTTPServer struct {
 	options       *Options
 	tlsserver     http.Server
 	nontlsserver  http.Server
+	adminserver   *http.Server
 	customBanner  string
 	staticHandler http.Handler
+	personalities *personalities
+	// doh answers the dns over https queries, if enabled
+	doh *DNSServer
 }
 
 type noopLogger struct {
@@ -69,16 +82,57 @@ func NewHTTPServer(options *Options) (*HTTPServer, error) {
 			server.customBanner = string(data)
 		}
 	}
+	personalities, err := parsePersonalities(options.HTTPPersonalities)
+	if err != nil {
+		return nil, err
+	}
+	server.personalities = personalities
+
 	router := &http.ServeMux{}
 	router.Handle("/", server.logger(server.corsMiddleware(http.HandlerFunc(server.defaultHandler))))
-	router.Handle("/register", server.corsMiddleware(server.authMiddleware(http.HandlerFunc(server.registerHandler))))
-	router.Handle("/deregister", server.corsMiddleware(server.authMiddleware(http.HandlerFunc(server.deregisterHandler))))
-	router.Handle("/poll", server.corsMiddleware(server.authMiddleware(http.HandlerFunc(server.pollHandler))))
+	router.Handle("/register", server.corsMiddleware(server.clientAuthMiddleware(http.HandlerFunc(server.registerHandler))))
+	router.Handle("/deregister", server.corsMiddleware(server.clientAuthMiddleware(http.HandlerFunc(server.deregisterHandler))))
+	router.Handle("/poll", server.corsMiddleware(server.clientAuthMiddleware(http.HandlerFunc(server.pollHandler))))
+	router.Handle("/share", server.corsMiddleware(server.clientAuthMiddleware(http.HandlerFunc(server.shareHandler))))
+	router.Handle("/cname", server.corsMiddleware(server.clientAuthMiddleware(http.HandlerFunc(server.cnameHandler))))
+	router.Handle("/dns-script", server.corsMiddleware(server.clientAuthMiddleware(http.HandlerFunc(server.dnsScriptHandler))))
+	router.Handle("/http-template", server.corsMiddleware(server.clientAuthMiddleware(http.HandlerFunc(server.httpTemplateHandler))))
+	router.Handle("/payload-file", server.corsMiddleware(server.clientAuthMiddleware(http.HandlerFunc(server.payloadFileHandler))))
+	if server.options.BodyStore != nil {
+		router.Handle("/body", server.corsMiddleware(server.clientAuthMiddleware(http.HandlerFunc(server.bodyHandler))))
+	}
+	if server.options.InspectLog != nil {
+		router.Handle("/inspect", server.corsMiddleware(server.clientAuthMiddleware(http.HandlerFunc(server.inspectHandler))))
+		// the inspect pages are authenticated by their key
+		router.Handle(inspectPathPrefix, http.HandlerFunc(server.inspectPageHandler))
+	}
+	if server.options.AdminAPI {
+		server.adminRoutes(router, func(handler http.Handler) http.Handler {
+			return server.corsMiddleware(server.authMiddleware(handler))
+		})
+	}
+	if server.options.Regions != nil {
+		router.Handle("/region/announce", server.corsMiddleware(server.authMiddleware(http.HandlerFunc(server.regionAnnounceHandler))))
+		router.Handle("/region/withdraw", server.corsMiddleware(server.authMiddleware(http.HandlerFunc(server.regionWithdrawHandler))))
+		router.Handle("/region/forward", server.corsMiddleware(server.authMiddleware(http.HandlerFunc(server.regionForwardHandler))))
+	}
+	if len(server.options.CACertificate) > 0 {
+		router.Handle("/ca.pem", server.corsMiddleware(http.HandlerFunc(server.caHandler)))
+	}
+	if server.options.DoH {
+		server.doh = NewDNSServer("tcp", options)
+		server.doh.transport = "doh"
+		router.Handle(dohPath, http.HandlerFunc(server.dohHandler))
+	}
 	if server.options.EnableMetrics {
 		router.Handle("/metrics", server.corsMiddleware(server.authMiddleware(http.HandlerFunc(server.metricsHandler))))
+		if server.options.Rollups != nil {
+			router.Handle("/metrics/history", server.corsMiddleware(server.authMiddleware(http.HandlerFunc(server.metricsHistoryHandler))))
+		}
 	}
 	server.tlsserver = http.Server{Addr: options.ListenIP + fmt.Sprintf(":%d", options.HttpsPort), Handler: router, ErrorLog: log.New(&noopLogger{}, "", 0)}
-	server.nontlsserver = http.Server{Addr: options.ListenIP + fmt.Sprintf(":%d", options.HttpPort), Handler: router, ErrorLog: log.New(&noopLogger{}, "", 0)}
+	// cleartext http/2 (h2c) is accepted with prior knowledge or upgrade, as by grpc clients
+	server.nontlsserver = http.Server{Addr: options.ListenIP + fmt.Sprintf(":%d", options.HttpPort), Handler: h2c.NewHandler(router, &http2.Server{}), ErrorLog: log.New(&noopLogger{}, "", 0)}
 	return server, nil
 }
 
@@ -88,42 +142,95 @@ func (h *HTTPServer) ListenAndServe(tlsConfig *tls.Config, httpAlive, httpsAlive
 		if tlsConfig == nil {
 			return
 		}
-		h.tlsserver.TLSConfig = tlsConfig
+		h.tlsserver.TLSConfig = h.options.listenerTLSConfig("http", tlsConfig)
 
 		httpsAlive <- true
-		if err := h.tlsserver.ListenAndServeTLS("", ""); err != nil {
+		if err := h.serve(&h.tlsserver, true); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			gologger.Error().Msgf("Could not serve http on tls: %s\n", err)
 			httpsAlive <- false
 		}
 	}()
 
 	httpAlive <- true
-	if err := h.nontlsserver.ListenAndServe(); err != nil {
+	if err := h.serve(&h.nontlsserver, false); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		httpAlive <- false
 		gologger.Error().Msgf("Could not serve http: %s\n", err)
 	}
 }
 
-func (h *HTTPServer) logger(handler http.Handler) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		req, _ := httputil.DumpRequest(r, true)
-		reqString := string(req)
-
-		gologger.Debug().Msgf("New HTTP request: \n\n%s\n", reqString)
-		rec := httptest.NewRecorder()
-		handler.ServeHTTP(rec, r)
+// Close stops the http and https servers
+func (h *HTTPServer) Close() {
+	_ = h.tlsserver.Close()
+	_ = h.nontlsserver.Close()
+}
 
-		resp, _ := httputil.DumpResponse(rec.Result(), true)
-		respString := string(resp)
+// Shutdown stops the http and https servers, waiting for the open requests
+// until the context is done
+func (h *HTTPServer) Shutdown(ctx context.Context) error {
+	return errors.Join(h.tlsserver.Shutdown(ctx), h.nontlsserver.Shutdown(ctx))
+}
 
-		for k, v := range rec.Header() {
-			w.Header()[k] = v
+// serve listens on the http server address and serves it
+func (h *HTTPServer) serve(server *http.Server, useTLS bool) error {
+	serveListener := func(listener net.Listener) error {
+		if useTLS {
+			return server.ServeTLS(h.options.fingerprintListener(listener), "", "")
+		}
+		return server.Serve(listener)
+	}
+	listener, err := h.options.Listeners.Listen("tcp", server.Addr)
+	if err != nil {
+		return err
+	}
+	// the workers accept on their own sockets of the address
+	for worker := 1; worker < h.options.Workers; worker++ {
+		workerListener, err := h.options.Listeners.Listen("tcp", server.Addr)
+		if err != nil {
+			_ = listener.Close()
+			return err
 		}
-		data := rec.Body.Bytes()
+		go func() {
+			_ = serveListener(workerListener)
+		}()
+	}
+	return serveListener(listener)
+}
 
-		w.WriteHeader(rec.Result().StatusCode)
-		_, _ = w.Write(data)
+// requestDetail is a detail parsed from a http request and its body, described
+// in the raw request and set on its interactions
+type requestDetail interface {
+	marker() string
+	apply(interaction *Interaction)
+}
+
+// capturedBody is a request body read through its capture
+type capturedBody struct {
+	io.Reader
+	body io.ReadCloser
+}
 
+// Close closes the request body
+func (b *capturedBody) Close() error {
+	return b.body.Close()
+}
+
+func (h *HTTPServer) logger(handler http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// stream the body keeping only the first part in memory, the
+		// handlers reading the whole body through the capture
+		capture := h.options.BodyStore.newCapture(h.options.captureLimit())
+		defer capture.close()
+		body := r.Body
+		if body == nil {
+			body = http.NoBody
+		}
+		if limit := h.options.captureLimit(); limit > 0 {
+			_, _ = io.CopyN(capture, body, int64(limit))
+		} else {
+			_, _ = io.Copy(capture, body)
+		}
+		head := capture.Bytes()
+		r.Body = io.NopCloser(bytes.NewReader(head))
 		var host string
 		// Check if the client's ip should be taken from a custom header (eg reverse proxy)
 		if originIP := r.Header.Get(h.options.OriginIPHeader); originIP != "" {
@@ -132,21 +239,99 @@ func (h *HTTPServer) logger(handler http.Handler) http.HandlerFunc {
 			host, _, _ = net.SplitHostPort(r.RemoteAddr)
 		}
 
+		// the dumped request contains the captured part of the body
+		req, _ := httputil.DumpRequest(r, true)
+		r.Body = &capturedBody{Reader: io.MultiReader(bytes.NewReader(head), io.TeeReader(body, capture)), body: body}
+		details := []requestDetail{
+			capture,
+			parseGRPCRequest(r, capture.Bytes()),
+			parseXMLRequest(r, capture.Bytes()),
+			parseSAMLRequest(r, capture.Bytes(), h.options.SAMLACSPath),
+			parseOAuthRequest(r, capture.Bytes()),
+			h.options.redirectOf(r),
+			parseGraphQLRequest(r, capture.Bytes()),
+			parseStructuredBody(r, capture.Bytes()),
+			parseRegistryRequest(r),
+			parsePackageRequest(r),
+			parseWebhookSignature(r),
+			parseScannerProbe(req),
+			sniffBody(r.Header.Get("Content-Type"), capture.Bytes()),
+			h.options.UNCChains.link(r, h.options.sessionOf(r.Host), host),
+			h.options.requestTLSFingerprint(r),
+		}
+		clientTimestamp := httpClientTimestamp(r.Header)
+		// websocket handshakes are answered on the hijacked connection
+		var (
+			ws         *websocketConn
+			respString string
+		)
+		if isWebSocketRequest(r) {
+			ws, respString = h.upgradeWebSocket(w, r)
+		}
+		if ws == nil {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+
+			result := rec.Result()
+			resp, _ := httputil.DumpResponse(result, true)
+			respString = string(resp)
+
+			for k, v := range rec.Header() {
+				// trailers are sent after the body
+				if k == "Trailer" || strings.HasPrefix(k, http.TrailerPrefix) {
+					continue
+				}
+				w.Header()[k] = v
+			}
+			data := rec.Body.Bytes()
+
+			if draw, ok := h.options.ResponseFuzz.draw(h.options.uniqueIDOf(r.Host)); ok {
+				// the response is delayed once the interactions are stored
+				defer h.writeFuzzed(w, r, result.StatusCode, data, draw)
+			} else {
+				w.WriteHeader(result.StatusCode)
+				_, _ = w.Write(data)
+				for k, v := range result.Trailer {
+					w.Header()[http.TrailerPrefix+k] = v
+				}
+			}
+			h.options.Stats.Bandwidth.Record("http", h.options.sessionOf(r.Host), len(resp))
+		}
+		// the rest of the body unread by the handlers completes its size and digest
+		_, _ = io.Copy(capture, body)
+		_ = body.Close()
+		reqString := string(req)
+		for _, detail := range details {
+			reqString += detail.marker()
+		}
+		gologger.Debug().Msgf("New HTTP request: \n\n%s\n", reqString)
+
+		requestHost := NormalizeHost(r.Host)
+		original := originalHost(r.Host, requestHost)
+		domain := h.options.domainOf(requestHost)
+
 		// if root-tld is enabled stores any interaction towards the main domain
-		if h.options.RootTLD {
-			for _, domain := range h.options.Domains {
-				if h.options.RootTLD && stringsutil.HasSuffixI(r.Host, domain) {
+		if h.options.isRootTLD() {
+			for _, domain := range h.options.GetDomains() {
+				if h.options.isRootTLD() && stringsutil.HasSuffixI(requestHost, domain) {
 					ID := domain
 					host, _, _ := net.SplitHostPort(r.RemoteAddr)
 					interaction := &Interaction{
-						Protocol:      "http",
-						UniqueID:      r.Host,
-						FullId:        r.Host,
-						RawRequest:    reqString,
-						RawResponse:   respString,
-						RemoteAddress: host,
-						Timestamp:     time.Now(),
+						Protocol:        "http",
+						UniqueID:        requestHost,
+						FullId:          requestHost,
+						RawRequest:      reqString,
+						RawResponse:     respString,
+						RemoteAddress:   host,
+						Timestamp:       time.Now(),
+						ClientTimestamp: clientTimestamp,
+						Domain:          domain,
+						OriginalHost:    original,
 					}
+					for _, detail := range details {
+						detail.apply(interaction)
+					}
+					h.options.applyNAT64(interaction)
 					buffer := &bytes.Buffer{}
 					if err := jsoniter.NewEncoder(buffer).Encode(interaction); err != nil {
 						gologger.Warning().Msgf("Could not encode root tld http interaction: %s\n", err)
@@ -160,46 +345,101 @@ func (h *HTTPServer) logger(handler http.Handler) http.HandlerFunc {
 			}
 		}
 
+		// correlation ids of the request, also receiving its websocket frames
+		ids := make(map[string]string)
 		if h.options.ScanEverywhere {
 			chunks := stringsutil.SplitAny(reqString, ".\n\t\"'")
 			for _, chunk := range chunks {
 				for part := range stringsutil.SlideWithLength(chunk, h.options.GetIdLength()) {
 					normalizedPart := strings.ToLower(part)
 					if h.options.isCorrelationID(normalizedPart) {
-						h.handleInteraction(normalizedPart, part, reqString, respString, host)
+						ids[normalizedPart] = part
+						h.handleInteraction(normalizedPart, part, reqString, respString, host, details, clientTimestamp, "", domain, requestCandidates(r, nil))
 					}
 				}
 			}
 		} else {
-			parts := strings.Split(r.Host, ".")
+			parts := strings.Split(requestHost, ".")
 			for i, part := range parts {
 				for partChunk := range stringsutil.SlideWithLength(part, h.options.GetIdLength()) {
-					normalizedPartChunk := strings.ToLower(partChunk)
-					if h.options.isCorrelationID(normalizedPartChunk) {
+					if h.options.isCorrelationID(partChunk) {
 						fullID := part
 						if i+1 <= len(parts) {
 							fullID = strings.Join(parts[:i+1], ".")
 						}
-						h.handleInteraction(normalizedPartChunk, fullID, reqString, respString, host)
+						ids[partChunk] = fullID
+						h.handleInteraction(partChunk, fullID, reqString, respString, host, details, clientTimestamp, original, domain, requestCandidates(r, parts[:i]))
 					}
 				}
 			}
+			// graphql clients, image and package names often use an endpoint url on a fixed host
+			if isGraphQLPath(r.URL.Path) || isRegistryPath(r.URL.Path) || isPackagePath(r.URL.Path) {
+				for _, segment := range strings.Split(r.URL.Path, "/") {
+					normalized := NormalizeHost(segment)
+					for part := range stringsutil.SlideWithLength(normalized, h.options.GetIdLength()) {
+						if _, ok := ids[part]; !ok && h.options.isCorrelationID(part) {
+							ids[part] = normalized
+							h.handleInteraction(part, normalized, reqString, respString, host, details, clientTimestamp, original, domain, requestCandidates(r, nil))
+						}
+					}
+				}
+			}
+		}
+		// hosted payload files are fetched from any host
+		if id, file := h.options.payloadFileOf(r.URL.Path); file != nil {
+			recorded := false
+			for uniqueID := range ids {
+				recorded = recorded || strings.HasPrefix(uniqueID, id)
+			}
+			if !recorded {
+				ids[id] = id
+				h.handleInteraction(id, strings.TrimPrefix(r.URL.Path, "/"), reqString, respString, host, details, clientTimestamp, original, domain, requestCandidates(r, nil))
+			}
+		}
+		if ws != nil {
+			h.serveWebSocket(ws, host, ids)
 		}
 	}
 }
 
-func (h *HTTPServer) handleInteraction(uniqueID, fullID, reqString, respString, hostPort string) {
+// requestCandidates returns the values decoded from the host labels preceding
+// the correlation id, the path segments and the query values of a request
+func requestCandidates(r *http.Request, labels []string) []DecodedCandidate {
+	values := labelValues(labels)
+	values = append(values, strings.Split(r.URL.Path, "/")...)
+	for _, pair := range strings.Split(r.URL.RawQuery, "&") {
+		_, value, _ := strings.Cut(pair, "=")
+		if unescaped, err := url.PathUnescape(value); err == nil {
+			values = append(values, unescaped)
+		}
+	}
+	return decodeCandidates(values...)
+}
+
+func (h *HTTPServer) handleInteraction(uniqueID, fullID, reqString, respString, hostPort string, details []requestDetail, clientTimestamp *time.Time, originalHost, domain string, decoded []DecodedCandidate) {
 	correlationID := uniqueID[:h.options.CorrelationIdLength]
 
 	interaction := &Interaction{
-		Protocol:      "http",
-		UniqueID:      uniqueID,
-		FullId:        fullID,
-		RawRequest:    reqString,
-		RawResponse:   respString,
-		RemoteAddress: hostPort,
-		Timestamp:     time.Now(),
+		Protocol:        "http",
+		UniqueID:        uniqueID,
+		FullId:          fullID,
+		RawRequest:      reqString,
+		RawResponse:     respString,
+		RemoteAddress:   hostPort,
+		Timestamp:       time.Now(),
+		ClientTimestamp: clientTimestamp,
+		Domain:          domain,
+		OriginalHost:    originalHost,
+		Decoded:         decoded,
+	}
+	for _, detail := range details {
+		detail.apply(interaction)
+		if capture, ok := detail.(*bodyCapture); ok {
+			h.options.BodyStore.persist(correlationID, capture, interaction)
+		}
 	}
+	h.options.applyNAT64(interaction)
+	h.options.ResponseFuzz.apply(interaction)
 	buffer := &bytes.Buffer{}
 	if err := jsoniter.NewEncoder(buffer).Encode(interaction); err != nil {
 		gologger.Warning().Msgf("Could not encode http interaction: %s\n", err)
@@ -209,6 +449,7 @@ func (h *HTTPServer) handleInteraction(uniqueID, fullID, reqString, respString,
 		if err := h.options.Storage.AddInteraction(correlationID, buffer.Bytes()); err != nil {
 			gologger.Warning().Msgf("Could not store http interaction: %s\n", err)
 		}
+		h.recordInspected(correlationID, interaction)
 	}
 }
 
@@ -228,9 +469,9 @@ func extractServerDomain(h *HTTPServer, req *http.Request) string {
 
 	var domain string
 	// use first domain as default (todo: should be extracted from certificate)
-	if len(h.options.Domains) > 0 {
+	if domains := h.options.GetDomains(); len(domains) > 0 {
 		// attempts to extract the domain name from host header
-		for _, configuredDomain := range h.options.Domains {
+		for _, configuredDomain := range domains {
 			if stringsutil.HasSuffixI(req.Host, configuredDomain) {
 				domain = configuredDomain
 				break
@@ -238,7 +479,7 @@ func extractServerDomain(h *HTTPServer, req *http.Request) string {
 		}
 		// fallback to first domain in case of unknown host header
 		if domain == "" {
-			domain = h.options.Domains[0]
+			domain = domains[0]
 		}
 	}
 	return domain
@@ -246,15 +487,103 @@ func extractServerDomain(h *HTTPServer, req *http.Request) string {
 
 // defaultHandler is a handler for default collaborator requests
 func (h *HTTPServer) defaultHandler(w http.ResponseWriter, req *http.Request) {
-	atomic.AddUint64(&h.options.Stats.Http, 1)
+	source := req.RemoteAddr
+	if originIP := req.Header.Get(h.options.OriginIPHeader); originIP != "" {
+		source = originIP
+	}
+	h.options.Stats.RecordSource("http", source)
 
 	domain := extractServerDomain(h, req)
-	w.Header().Set("Server", domain)
-	if !h.options.NoVersionHeader {
-		w.Header().Set("X-Interactsh-Version", h.options.Version)
+	personality := h.personalities.get(req.Host)
+	// personalities don't send the version header which would give them away
+	switch {
+	case personality != nil:
+		if h.options.HeaderServer != "" {
+			w.Header().Set("Server", h.options.HeaderServer)
+		} else {
+			w.Header().Set("Server", personality.Server)
+		}
+		for key, value := range personality.Headers {
+			w.Header().Set(key, value)
+		}
+	default:
+		w.Header().Set("Server", domain)
+		if !h.options.NoVersionHeader {
+			w.Header().Set("X-Interactsh-Version", h.options.Version)
+		}
+	}
+
+	if isGRPCRequest(req) {
+		writeGRPCResponse(w)
+		return
+	}
+	if id, file := h.options.payloadFileOf(req.URL.Path); file != nil {
+		if h.options.egressCapped(id) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		writePayloadFile(w, path.Base(req.URL.Path), file)
+		return
+	}
+	// the responses uploaded by the session owner take precedence over the server ones
+	if template := h.options.httpTemplateOf(req.Host); template != nil {
+		if h.options.egressCapped(h.options.sessionOf(req.Host)) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		writeHTTPTemplate(w, template)
+		return
+	}
+	if response := h.options.httpResponseOf(req); response != nil {
+		response.write(w, req, domain)
+		return
+	}
+	if h.options.OIDC != nil && h.options.OIDC.serve(w, req) {
+		return
+	}
+	if h.options.SAMLACSPath != "" && req.URL.Path == h.options.SAMLACSPath {
+		// assertions are accepted without validation, as by a misconfigured service provider
+		fmt.Fprintf(w, "<html><head></head><body>%s</body></html>", h.options.URLReflection(req.Host))
+		return
+	}
+	if isGraphQLPath(req.URL.Path) {
+		body, _ := io.ReadAll(req.Body)
+		writeGraphQLResponse(w, parseGraphQLRequest(req, body))
+		return
+	}
+	if request := parseRegistryRequest(req); request != nil {
+		writeRegistryResponse(w, req, request)
+		return
+	}
+	if request := parsePackageRequest(req); request != nil {
+		writePackageResponse(w, req, request)
+		return
+	}
+	if req.URL.Path == oauthCallbackPath {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, oauthBridgePage, h.options.URLReflection(req.Host))
+		return
+	}
+	if redirect := h.options.redirectOf(req); redirect != nil {
+		redirect.write(w, h.options.URLReflection(req.Host))
+		return
 	}
 
 	reflection := h.options.URLReflection(req.Host)
+	// soap and xml posts are echoed back as xml
+	if req.Method == http.MethodPost && req.Body != nil && !stringsutil.HasPrefixI(req.URL.Path, "/s/") {
+		if body, _ := io.ReadAll(req.Body); isXMLRequest(req, body) {
+			writeXMLResponse(w, req, body, reflection)
+			return
+		}
+	}
+	// payload content is no longer served once the egress caps are reached
+	static := stringsutil.HasPrefixI(req.URL.Path, "/s/") && h.staticHandler != nil
+	dynamic := h.options.DynamicResp && (len(req.URL.Query()) > 0 || stringsutil.HasPrefixI(req.URL.Path, "/b64_body:"))
+	if (static || dynamic) && h.options.egressCapped(h.options.sessionOf(req.Host)) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
 	if stringsutil.HasPrefixI(req.URL.Path, "/s/") && h.staticHandler != nil {
 		if h.options.DynamicResp && len(req.URL.Query()) > 0 {
 			values := req.URL.Query()
@@ -278,8 +607,12 @@ func (h *HTTPServer) defaultHandler(w http.ResponseWriter, req *http.Request) {
 		}
 		h.staticHandler.ServeHTTP(w, req)
 	} else if req.URL.Path == "/" && reflection == "" {
-		if h.customBanner != "" {
+		if tenant := h.options.tenantOf(req.Host); tenant != nil && tenant.index != "" {
+			fmt.Fprint(w, strings.ReplaceAll(tenant.index, "{DOMAIN}", domain))
+		} else if h.customBanner != "" {
 			fmt.Fprint(w, strings.ReplaceAll(h.customBanner, "{DOMAIN}", domain))
+		} else if personality != nil {
+			fmt.Fprint(w, personality.Index)
 		} else {
 			fmt.Fprintf(w, banner, domain)
 		}
@@ -296,6 +629,11 @@ func (h *HTTPServer) defaultHandler(w http.ResponseWriter, req *http.Request) {
 			writeResponseFromDynamicRequest(w, req)
 			return
 		}
+		if personality != nil {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, personality.NotFound, reflection)
+			return
+		}
 		fmt.Fprintf(w, "<html><head></head><body>%s</body></html>", reflection)
 	}
 }
@@ -366,11 +704,30 @@ func (h *HTTPServer) registerHandler(w http.ResponseWriter, req *http.Request) {
 
 	atomic.AddInt64(&h.options.Stats.Sessions, 1)
 
-	if err := h.options.Storage.SetIDPublicKey(r.CorrelationID, r.SecretKey, r.PublicKey); err != nil {
+	tenant := h.options.Tenants.ByToken(req.Header.Get("Authorization"))
+	if err := h.options.Storage.SetIDPublicKey(r.CorrelationID, r.SecretKey, r.PublicKey); errors.Is(err, storage.ErrCorrelationIdExists) {
+		// re-registration of a resumed session
+		if tenant != nil {
+			h.options.Tenants.Register(r.CorrelationID, tenant)
+		}
+		jsonMsg(w, "registration successful", http.StatusOK)
+		return
+	} else if errors.Is(err, storage.ErrCorrelationIdConflict) {
+		gologger.Warning().Msgf("Could not register %s: %s\n", r.CorrelationID, err)
+		jsonError(w, err.Error(), http.StatusConflict)
+		return
+	} else if errors.Is(err, storage.ErrCorrelationIdBanned) {
+		gologger.Warning().Msgf("Could not register %s: %s\n", r.CorrelationID, err)
+		jsonError(w, err.Error(), http.StatusForbidden)
+		return
+	} else if err != nil {
 		gologger.Warning().Msgf("Could not set id and public key for %s: %s\n", r.CorrelationID, err)
 		jsonError(w, fmt.Sprintf("could not set id and public key: %s", err), http.StatusBadRequest)
 		return
 	}
+	if tenant != nil {
+		h.options.Tenants.Register(r.CorrelationID, tenant)
+	}
 	jsonMsg(w, "registration successful", http.StatusOK)
 	gologger.Debug().Msgf("Registered correlationID %s for key\n", r.CorrelationID)
 }
@@ -399,10 +756,243 @@ func (h *HTTPServer) deregisterHandler(w http.ResponseWriter, req *http.Request)
 		jsonError(w, fmt.Sprintf("could not remove id: %s", err), http.StatusBadRequest)
 		return
 	}
+	if _, err := h.options.Storage.GetCacheItem(r.CorrelationID); err != nil {
+		if h.options.Tenants != nil {
+			h.options.Tenants.Deregister(r.CorrelationID)
+		}
+		h.options.InspectLog.remove(r.CorrelationID)
+		h.options.BodyStore.remove(r.CorrelationID)
+		h.options.Stats.Bandwidth.Forget(r.CorrelationID)
+	}
 	jsonMsg(w, "deregistration successful", http.StatusOK)
 	gologger.Debug().Msgf("Deregistered correlationID %s for key\n", r.CorrelationID)
 }
 
+// ShareRequest is a request for granting read access on a session to a teammate.
+type ShareRequest struct {
+	// CorrelationID is an ID for correlation with requests.
+	CorrelationID string `json:"correlation-id"`
+	// SecretKey is the secretKey of the session owner.
+	SecretKey string `json:"secret-key"`
+	// ReaderSecretKey is the secret-key the teammate will poll with.
+	ReaderSecretKey string `json:"reader-secret-key"`
+	// ReaderPublicKey is the public RSA Key of the teammate.
+	ReaderPublicKey string `json:"reader-public-key"`
+}
+
+// shareHandler is a handler for session share requests
+func (h *HTTPServer) shareHandler(w http.ResponseWriter, req *http.Request) {
+	r := &ShareRequest{}
+	if err := jsoniter.NewDecoder(req.Body).Decode(r); err != nil {
+		gologger.Warning().Msgf("Could not decode json body: %s\n", err)
+		jsonError(w, fmt.Sprintf("could not decode json body: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.options.Storage.ShareID(r.CorrelationID, r.SecretKey, r.ReaderSecretKey, r.ReaderPublicKey); err != nil {
+		gologger.Warning().Msgf("Could not share id %s: %s\n", r.CorrelationID, err)
+		jsonError(w, fmt.Sprintf("could not share id: %s", err), http.StatusBadRequest)
+		return
+	}
+	jsonMsg(w, "share successful", http.StatusOK)
+	gologger.Debug().Msgf("Shared correlationID %s with reader key\n", r.CorrelationID)
+}
+
+// CNAMERequest is a request for resolving the names of a session to an external host.
+type CNAMERequest struct {
+	// CorrelationID is an ID for correlation with requests.
+	CorrelationID string `json:"correlation-id"`
+	// SecretKey is the secretKey of the session owner.
+	SecretKey string `json:"secret-key"`
+	// Target is the external host name, empty to remove it.
+	Target string `json:"target"`
+}
+
+// cnameHandler is a handler for session cname requests
+func (h *HTTPServer) cnameHandler(w http.ResponseWriter, req *http.Request) {
+	r := &CNAMERequest{}
+	if err := jsoniter.NewDecoder(req.Body).Decode(r); err != nil {
+		gologger.Warning().Msgf("Could not decode json body: %s\n", err)
+		jsonError(w, fmt.Sprintf("could not decode json body: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	target := strings.TrimSuffix(strings.ToLower(r.Target), ".")
+	if target != "" {
+		if _, ok := dns.IsDomainName(target); !ok {
+			jsonError(w, "invalid cname target", http.StatusBadRequest)
+			return
+		}
+		// pointing to the served domains would create resolution loops
+		if h.options.domainOf(target) != "" {
+			jsonError(w, "cname target can't be a served domain", http.StatusBadRequest)
+			return
+		}
+		target = dns.Fqdn(target)
+	}
+
+	if err := h.options.Storage.SetCNAME(r.CorrelationID, r.SecretKey, target); err != nil {
+		gologger.Warning().Msgf("Could not set cname for id %s: %s\n", r.CorrelationID, err)
+		jsonError(w, fmt.Sprintf("could not set cname: %s", err), http.StatusBadRequest)
+		return
+	}
+	jsonMsg(w, "cname successful", http.StatusOK)
+	gologger.Debug().Msgf("Set cname %s for correlationID %s\n", target, r.CorrelationID)
+}
+
+// MaxDNSScriptAnswers is the number of answers of a dns script
+var MaxDNSScriptAnswers = 16
+
+// DNSScriptRequest is a request for answering the lookups of a subdomain
+// label of a session with a sequence of addresses, eg. for dns rebinding.
+type DNSScriptRequest struct {
+	// CorrelationID is an ID for correlation with requests.
+	CorrelationID string `json:"correlation-id"`
+	// SecretKey is the secretKey of the session owner.
+	SecretKey string `json:"secret-key"`
+	// Label is the subdomain preceding the unique id in the names (eg. rebind1), empty for the unique id itself.
+	Label string `json:"label"`
+	// Answers are the addresses answered to the successive lookups, the last one
+	// being repeated, empty to remove the script.
+	Answers []string `json:"answers"`
+}
+
+// dnsScriptHandler is a handler for session dns script requests
+func (h *HTTPServer) dnsScriptHandler(w http.ResponseWriter, req *http.Request) {
+	r := &DNSScriptRequest{}
+	if err := jsoniter.NewDecoder(req.Body).Decode(r); err != nil {
+		gologger.Warning().Msgf("Could not decode json body: %s\n", err)
+		jsonError(w, fmt.Sprintf("could not decode json body: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	label := strings.Trim(strings.ToLower(r.Label), ".")
+	if label != "" {
+		if _, ok := dns.IsDomainName(label); !ok {
+			jsonError(w, "invalid dns script label", http.StatusBadRequest)
+			return
+		}
+	}
+	if len(r.Answers) > MaxDNSScriptAnswers {
+		jsonError(w, fmt.Sprintf("at most %d dns script answers can be set", MaxDNSScriptAnswers), http.StatusBadRequest)
+		return
+	}
+	answers := make([]string, 0, len(r.Answers))
+	for _, answer := range r.Answers {
+		ip := net.ParseIP(strings.TrimSpace(answer))
+		if ip == nil {
+			jsonError(w, fmt.Sprintf("invalid dns script answer %q", answer), http.StatusBadRequest)
+			return
+		}
+		answers = append(answers, ip.String())
+	}
+
+	if err := h.options.Storage.SetDNSScript(r.CorrelationID, r.SecretKey, label, answers); err != nil {
+		gologger.Warning().Msgf("Could not set dns script for id %s: %s\n", r.CorrelationID, err)
+		jsonError(w, fmt.Sprintf("could not set dns script: %s", err), http.StatusBadRequest)
+		return
+	}
+	jsonMsg(w, "dns script successful", http.StatusOK)
+	gologger.Debug().Msgf("Set dns script %s for correlationID %s\n", label, r.CorrelationID)
+}
+
+// DomainRequest is a request to add or remove a served domain
+type DomainRequest struct {
+	Domain string `json:"domain"`
+}
+
+// DomainsResponse is the response listing the served domains
+type DomainsResponse struct {
+	Domains []string `json:"domains"`
+}
+
+// domainsHandler is a handler for listing, adding and removing served domains at runtime
+func (h *HTTPServer) domainsHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		_ = jsoniter.NewEncoder(w).Encode(&DomainsResponse{Domains: h.options.GetDomains()})
+		return
+	}
+
+	r := &DomainRequest{}
+	if err := jsoniter.NewDecoder(req.Body).Decode(r); err != nil {
+		jsonError(w, fmt.Sprintf("could not decode json body: %s", err), http.StatusBadRequest)
+		return
+	}
+	switch req.Method {
+	case http.MethodPost:
+		if err := h.options.AddDomain(r.Domain); err != nil {
+			jsonError(w, fmt.Sprintf("could not add domain: %s", err), http.StatusBadRequest)
+			return
+		}
+		domain := strings.TrimSuffix(strings.ToLower(r.Domain), ".")
+		if h.options.isRootTLD() {
+			_ = h.options.Storage.SetID(domain)
+		}
+		// certificates are issued in background as acme challenges take time
+		if h.options.OnDomainAdd != nil {
+			go h.options.OnDomainAdd(domain)
+		}
+		jsonMsg(w, "domain added", http.StatusOK)
+		gologger.Info().Msgf("Added domain %s\n", domain)
+	case http.MethodDelete:
+		if err := h.options.RemoveDomain(r.Domain); err != nil {
+			jsonError(w, fmt.Sprintf("could not remove domain: %s", err), http.StatusBadRequest)
+			return
+		}
+		if h.options.OnDomainRemove != nil {
+			h.options.OnDomainRemove(strings.TrimSuffix(strings.ToLower(r.Domain), "."))
+		}
+		jsonMsg(w, "domain removed", http.StatusOK)
+		gologger.Info().Msgf("Removed domain %s\n", r.Domain)
+	default:
+		jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// regionAnnounceHandler is a handler for heartbeats of the peer region nodes
+func (h *HTTPServer) regionAnnounceHandler(w http.ResponseWriter, req *http.Request) {
+	r := &RegionAnnouncement{}
+	if err := jsoniter.NewDecoder(req.Body).Decode(r); err != nil {
+		jsonError(w, fmt.Sprintf("could not decode json body: %s", err), http.StatusBadRequest)
+		return
+	}
+	if err := h.options.Regions.Announce(r.Name, r.IP); err != nil {
+		jsonError(w, fmt.Sprintf("could not announce region: %s", err), http.StatusBadRequest)
+		return
+	}
+	jsonMsg(w, "announce successful", http.StatusOK)
+}
+
+// regionWithdrawHandler is a handler for the shutdown of the peer region nodes
+func (h *HTTPServer) regionWithdrawHandler(w http.ResponseWriter, req *http.Request) {
+	r := &RegionAnnouncement{}
+	if err := jsoniter.NewDecoder(req.Body).Decode(r); err != nil {
+		jsonError(w, fmt.Sprintf("could not decode json body: %s", err), http.StatusBadRequest)
+		return
+	}
+	if err := h.options.Regions.Withdraw(r.Name); err != nil {
+		jsonError(w, fmt.Sprintf("could not withdraw region: %s", err), http.StatusBadRequest)
+		return
+	}
+	jsonMsg(w, "withdraw successful", http.StatusOK)
+}
+
+// regionForwardHandler is a handler for interactions forwarded by the peer region nodes
+func (h *HTTPServer) regionForwardHandler(w http.ResponseWriter, req *http.Request) {
+	r := &RegionForward{}
+	if err := jsoniter.NewDecoder(req.Body).Decode(r); err != nil {
+		jsonError(w, fmt.Sprintf("could not decode json body: %s", err), http.StatusBadRequest)
+		return
+	}
+	if err := h.options.Regions.Deliver(r); err != nil {
+		jsonError(w, fmt.Sprintf("could not store interaction: %s", err), http.StatusNotFound)
+		return
+	}
+	jsonMsg(w, "forward successful", http.StatusOK)
+	gologger.Debug().Msgf("Stored forwarded interaction for correlationID %s\n", r.CorrelationID)
+}
+
 // PollResponse is the response for a polling request
 type PollResponse struct {
 	Data    []string `json:"data"`
@@ -432,17 +1022,19 @@ func (h *HTTPServer) pollHandler(w http.ResponseWriter, req *http.Request) {
 	}
 
 	// At this point the client is authenticated, so we return also the data related to the auth token
+	// which is not shared with tenants
 	var tlddata, extradata []string
-	if h.options.RootTLD {
-		for _, domain := range h.options.Domains {
+	isTenant := h.options.Tenants.ByToken(req.Header.Get("Authorization")) != nil
+	if h.options.isRootTLD() && !isTenant {
+		for _, domain := range h.options.GetDomains() {
 			interactions, _ := h.options.Storage.GetInteractionsWithId(domain)
 			// root domains interaction are not encrypted
 			tlddata = append(tlddata, interactions...)
 		}
 	}
-	if h.options.Token != "" {
+	if h.options.GetToken() != "" && !isTenant {
 		// auth token interactions are not encrypted
-		extradata, _ = h.options.Storage.GetInteractionsWithId(h.options.Token)
+		extradata, _ = h.options.Storage.GetInteractionsWithId(h.options.GetToken())
 	}
 	response := &PollResponse{Data: data, AESKey: aesKey, TLDData: tlddata, Extra: extradata}
 
@@ -496,8 +1088,65 @@ func (h *HTTPServer) authMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// clientAuthMiddleware authenticates clients with the server token or a tenant one
+func (h *HTTPServer) clientAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !h.checkToken(req) && h.options.Tenants.ByToken(req.Header.Get("Authorization")) == nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
 func (h *HTTPServer) checkToken(req *http.Request) bool {
-	return !h.options.Auth || h.options.Auth && h.options.Token == req.Header.Get("Authorization")
+	return h.options.checkAuth(req.Header.Get("Authorization"))
+}
+
+// caHandler is a handler for downloading the self-signed certificate authority
+func (h *HTTPServer) caHandler(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/x-pem-file")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"interactsh-ca.pem\"")
+	_, _ = w.Write(h.options.CACertificate)
+}
+
+// metricsHistoryHandler is a handler for /metrics/history endpoint, returning
+// the rolled-up statistics of a resolution (hour or day) and dimension
+// (protocol, domain or token, all if empty) since a time or duration
+func (h *HTTPServer) metricsHistoryHandler(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	resolution := query.Get("resolution")
+	switch resolution {
+	case "":
+		resolution = RollupHour
+	case RollupHour, RollupDay:
+	default:
+		jsonError(w, "resolution must be hour or day", http.StatusBadRequest)
+		return
+	}
+	dimension := query.Get("dimension")
+	switch dimension {
+	case "", RollupProtocol, RollupDomain, RollupToken:
+	default:
+		jsonError(w, "dimension must be protocol, domain or token", http.StatusBadRequest)
+		return
+	}
+	since := time.Now().Add(-24 * time.Hour)
+	if resolution == RollupDay {
+		since = time.Now().Add(-30 * 24 * time.Hour)
+	}
+	if value := query.Get("since"); value != "" {
+		if duration, err := time.ParseDuration(value); err == nil {
+			since = time.Now().Add(-duration)
+		} else if since, err = time.Parse(time.RFC3339, value); err != nil {
+			jsonError(w, "since must be a duration or rfc3339 time", http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	_ = jsoniter.NewEncoder(w).Encode(h.options.Rollups.Query(resolution, dimension, since))
 }
 
 // metricsHandler is a handler for /metrics endpoint
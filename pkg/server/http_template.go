@@ -0,0 +1,108 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/interactsh/pkg/storage"
+	"golang.org/x/net/http/httpguts"
+)
+
+var (
+	// MaxHTTPTemplateBody is the size in bytes of the body of an http template
+	MaxHTTPTemplateBody = 1024 * 1024
+	// MaxHTTPTemplateHeaders is the number of headers of an http template
+	MaxHTTPTemplateHeaders = 32
+)
+
+// HTTPTemplateRequest is a request for serving a response to the http
+// requests of a session, signed by the private key of the session.
+type HTTPTemplateRequest struct {
+	// CorrelationID is an ID for correlation with requests.
+	CorrelationID string `json:"correlation-id"`
+	// SecretKey is the secretKey of the session owner.
+	SecretKey string `json:"secret-key"`
+	// PublicKey is the public key the session was registered with.
+	PublicKey string `json:"public-key"`
+	// Template is the response served, nil to serve the default responses again.
+	Template *storage.HTTPTemplate `json:"template,omitempty"`
+	// Signature is the rsa-pss sha256 signature of the template signed data.
+	Signature []byte `json:"signature,omitempty"`
+}
+
+// validateHTTPTemplate checks the status, headers and body size of a template
+func validateHTTPTemplate(template *storage.HTTPTemplate) error {
+	if template.Status != 0 && (template.Status < 100 || template.Status > 999) {
+		return fmt.Errorf("invalid status %d", template.Status)
+	}
+	if len(template.Body) > MaxHTTPTemplateBody {
+		return fmt.Errorf("body larger than %d bytes", MaxHTTPTemplateBody)
+	}
+	if len(template.Headers) > MaxHTTPTemplateHeaders {
+		return fmt.Errorf("at most %d headers can be set", MaxHTTPTemplateHeaders)
+	}
+	for key, value := range template.Headers {
+		if !httpguts.ValidHeaderFieldName(key) || !httpguts.ValidHeaderFieldValue(value) {
+			return fmt.Errorf("invalid header %q", key)
+		}
+	}
+	if !httpguts.ValidHeaderFieldValue(template.ContentType) {
+		return fmt.Errorf("invalid content type %q", template.ContentType)
+	}
+	return nil
+}
+
+// httpTemplateHandler is a handler for session http template requests
+func (h *HTTPServer) httpTemplateHandler(w http.ResponseWriter, req *http.Request) {
+	r := &HTTPTemplateRequest{}
+	if err := jsoniter.NewDecoder(http.MaxBytesReader(w, req.Body, int64(2*MaxHTTPTemplateBody))).Decode(r); err != nil {
+		gologger.Warning().Msgf("Could not decode json body: %s\n", err)
+		jsonError(w, fmt.Sprintf("could not decode json body: %s", err), http.StatusBadRequest)
+		return
+	}
+	if r.Template != nil {
+		if err := validateHTTPTemplate(r.Template); err != nil {
+			jsonError(w, fmt.Sprintf("invalid http template: %s", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := h.options.Storage.SetHTTPTemplate(r.CorrelationID, r.SecretKey, r.PublicKey, r.Template, r.Signature); err != nil {
+		gologger.Warning().Msgf("Could not set http template for id %s: %s\n", r.CorrelationID, err)
+		jsonError(w, fmt.Sprintf("could not set http template: %s", err), http.StatusBadRequest)
+		return
+	}
+	jsonMsg(w, "http template successful", http.StatusOK)
+	gologger.Debug().Msgf("Set http template for correlationID %s\n", r.CorrelationID)
+}
+
+// httpTemplateOf returns the http template of the session of a host, if any
+func (options *Options) httpTemplateOf(host string) *storage.HTTPTemplate {
+	session := options.sessionOf(host)
+	if session == "" || options.Storage == nil {
+		return nil
+	}
+	item, err := options.Storage.GetCacheItem(session)
+	if err != nil {
+		return nil
+	}
+	return item.GetHTTPTemplate()
+}
+
+// writeHTTPTemplate writes the response of an http template
+func writeHTTPTemplate(w http.ResponseWriter, template *storage.HTTPTemplate) {
+	for key, value := range template.Headers {
+		w.Header().Set(key, value)
+	}
+	if template.ContentType != "" {
+		w.Header().Set("Content-Type", template.ContentType)
+	}
+	status := template.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(template.Body))
+}
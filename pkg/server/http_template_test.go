@@ -0,0 +1,44 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/projectdiscovery/interactsh/pkg/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPTemplate(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0")
+	store := options.Storage
+	item, err := store.GetCacheItem("c59e3crp82ke7bcnedq0")
+	require.Nil(t, err)
+	item.HTTPTemplate = &storage.HTTPTemplate{Status: http.StatusFound, ContentType: "text/html", Headers: map[string]string{"Location": "http://169.254.169.254/"}, Body: "<script>alert(1)</script>"}
+
+	options.IPAddress = "192.0.2.1"
+	server, err := NewHTTPServer(options)
+	require.Nil(t, err)
+
+	recorder := httptest.NewRecorder()
+	server.defaultHandler(recorder, httptest.NewRequest(http.MethodGet, "http://c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh/x", nil))
+	require.Equal(t, http.StatusFound, recorder.Code)
+	require.Equal(t, "text/html", recorder.Header().Get("Content-Type"))
+	require.Equal(t, "http://169.254.169.254/", recorder.Header().Get("Location"))
+	require.Equal(t, "<script>alert(1)</script>", recorder.Body.String())
+
+	// other sessions are served the default responses
+	recorder = httptest.NewRecorder()
+	server.defaultHandler(recorder, httptest.NewRequest(http.MethodGet, "http://c59e3crp82ke7bcnedq1cfjqdpeyyyyyy.interact.sh/x", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.NotContains(t, recorder.Body.String(), "alert")
+
+	require.Nil(t, validateHTTPTemplate(item.HTTPTemplate))
+	for _, template := range []*storage.HTTPTemplate{
+		{Status: 42},
+		{Headers: map[string]string{"X-Injected\r\nSet-Cookie": "a"}},
+		{Headers: map[string]string{"X-Header": "a\r\nSet-Cookie: b"}},
+	} {
+		require.NotNil(t, validateHTTPTemplate(template))
+	}
+}
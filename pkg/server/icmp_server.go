@@ -0,0 +1,273 @@
+package server
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/gologger"
+	stringsutil "github.com/projectdiscovery/utils/strings"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	// icmpPairingWindow is the time after an address lookup in which the echo
+	// requests without correlation id are paired with it
+	icmpPairingWindow = 10 * time.Second
+	// icmpRunTimeout is the time without echo requests after which a ping run
+	// (source and echo identifier) is recorded again
+	icmpRunTimeout = time.Minute
+	// lookupTrackerSize is the maximum number of lookups kept during the window
+	lookupTrackerSize = 4096
+	// icmpCaptureSize is the number of echo data bytes recorded
+	icmpCaptureSize = 1024
+	// icmpProtocolIPv4 and icmpProtocolIPv6 are the ip protocol numbers of icmp
+	icmpProtocolIPv4 = 1
+	icmpProtocolIPv6 = 58
+)
+
+// addressLookup is an address lookup of a correlation id
+type addressLookup struct {
+	correlationID string
+	uniqueID      string
+	fullID        string
+	source        string
+	time          time.Time
+}
+
+// LookupTracker keeps the recent address lookups of correlation ids, with
+// which the icmp server pairs the echo requests whose data has no correlation
+// id, as ping resolves the host name before sending them
+type LookupTracker struct {
+	window  time.Duration
+	mu      sync.Mutex
+	lookups []addressLookup
+}
+
+// NewLookupTracker returns a new tracker of the lookups made during the window
+func NewLookupTracker(window time.Duration) *LookupTracker {
+	return &LookupTracker{window: window}
+}
+
+// record adds an address lookup, the tracker may be nil
+func (t *LookupTracker) record(correlationID, uniqueID, fullID, source string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	t.prune(now)
+	if len(t.lookups) >= lookupTrackerSize {
+		t.lookups = t.lookups[1:]
+	}
+	t.lookups = append(t.lookups, addressLookup{correlationID: correlationID, uniqueID: uniqueID, fullID: fullID, source: source, time: now})
+}
+
+// pair returns the lookup an echo request from the source is paired with, the
+// latest lookup made by the source itself, or the only correlation id looked up
+// during the window so that concurrent pings of different ids are not mixed
+func (t *LookupTracker) pair(source string) (addressLookup, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.prune(time.Now())
+	for i := len(t.lookups) - 1; i >= 0; i-- {
+		if t.lookups[i].source == source {
+			return t.lookups[i], true
+		}
+	}
+	var paired addressLookup
+	for _, lookup := range t.lookups {
+		if paired.correlationID != "" && paired.correlationID != lookup.correlationID {
+			return addressLookup{}, false
+		}
+		paired = lookup
+	}
+	return paired, paired.correlationID != ""
+}
+
+func (t *LookupTracker) prune(now time.Time) {
+	expired := 0
+	for expired < len(t.lookups) && now.Sub(t.lookups[expired].time) > t.window {
+		expired++
+	}
+	t.lookups = t.lookups[expired:]
+}
+
+// ICMPServer records the icmp echo requests received by the host whose data
+// contains a correlation id, or following an address lookup of one, from raw
+// sockets. The echo replies are still sent by the kernel.
+type ICMPServer struct {
+	options *Options
+	conns   []*icmp.PacketConn
+	runs    map[string]time.Time
+	mu      sync.Mutex
+}
+
+// NewICMPServer opens the raw icmp sockets, which requires CAP_NET_RAW so it
+// must be called before dropping privileges. The ipv6 socket is optional.
+func NewICMPServer(options *Options) (*ICMPServer, error) {
+	address := "0.0.0.0"
+	if ip := net.ParseIP(options.ListenIP); ip != nil && ip.To4() != nil {
+		address = ip.String()
+	}
+	conn, err := icmp.ListenPacket("ip4:icmp", address)
+	if err != nil {
+		return nil, fmt.Errorf("could not open icmp socket (CAP_NET_RAW is required): %w", err)
+	}
+	server := &ICMPServer{options: options, conns: []*icmp.PacketConn{conn}, runs: make(map[string]time.Time)}
+	if conn6, err := icmp.ListenPacket("ip6:ipv6-icmp", "::"); err == nil {
+		server.conns = append(server.conns, conn6)
+	} else {
+		gologger.Debug().Msgf("Could not open icmpv6 socket: %s\n", err)
+	}
+	if options.AddressLookups == nil {
+		options.AddressLookups = NewLookupTracker(icmpPairingWindow)
+	}
+	return server, nil
+}
+
+// ListenAndServe reads the echo requests of the icmp sockets
+func (h *ICMPServer) ListenAndServe(icmpAlive chan bool) {
+	icmpAlive <- true
+
+	var wg sync.WaitGroup
+	for _, conn := range h.conns {
+		wg.Add(1)
+		go func(conn *icmp.PacketConn) {
+			defer wg.Done()
+			h.serve(conn)
+		}(conn)
+	}
+	wg.Wait()
+}
+
+func (h *ICMPServer) serve(conn *icmp.PacketConn) {
+	protocol := icmpProtocolIPv4
+	if conn.IPv6PacketConn() != nil {
+		protocol = icmpProtocolIPv6
+	}
+	buffer := make([]byte, udpMaxDatagram)
+	for {
+		n, addr, err := conn.ReadFrom(buffer)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				gologger.Error().Msgf("Could not read icmp packet: %s\n", err)
+			}
+			return
+		}
+		message, err := icmp.ParseMessage(protocol, buffer[:n])
+		if err != nil || (message.Type != ipv4.ICMPTypeEcho && message.Type != ipv6.ICMPTypeEchoRequest) {
+			continue
+		}
+		if echo, ok := message.Body.(*icmp.Echo); ok {
+			h.handleEcho(addr, echo)
+		}
+	}
+}
+
+// handleEcho records the first echo request of a ping run for the
+// correlation ids of its data, or the lookup it is paired with
+func (h *ICMPServer) handleEcho(addr net.Addr, echo *icmp.Echo) {
+	source := addr.String()
+	if host, _, err := net.SplitHostPort(source); err == nil {
+		source = host
+	}
+	h.options.Stats.RecordSource("icmp", source)
+
+	run := fmt.Sprintf("%s/%d", source, echo.ID)
+	now := time.Now()
+	h.mu.Lock()
+	last, seen := h.runs[run]
+	h.runs[run] = now
+	if len(h.runs) > lookupTrackerSize {
+		for key, value := range h.runs {
+			if now.Sub(value) > icmpRunTimeout {
+				delete(h.runs, key)
+			}
+		}
+	}
+	h.mu.Unlock()
+	if seen && now.Sub(last) <= icmpRunTimeout {
+		return
+	}
+
+	ids := make(map[string]string)
+	for _, chunk := range icmpDataStrings(echo.Data) {
+		for _, part := range stringsutil.SplitAny(chunk, ".@/\\:;,=()`'\"<>?& ") {
+			normalized := NormalizeHost(part)
+			for candidate := range stringsutil.SlideWithLength(normalized, h.options.GetIdLength()) {
+				if _, ok := ids[candidate]; !ok && h.options.isCorrelationID(candidate) {
+					ids[candidate] = normalized
+				}
+			}
+		}
+	}
+	paired := false
+	if len(ids) == 0 {
+		lookup, ok := h.options.AddressLookups.pair(source)
+		if !ok {
+			return
+		}
+		ids[lookup.uniqueID] = lookup.fullID
+		paired = true
+	}
+
+	data := echo.Data
+	if len(data) > icmpCaptureSize {
+		data = data[:icmpCaptureSize]
+	}
+	message := fmt.Sprintf("Type=echo-request\nIdentifier=%d\nSequence=%d\nSize=%d\n", echo.ID, echo.Seq, len(echo.Data))
+	if paired {
+		message += "Paired=address lookup\n"
+	}
+	message += "\n" + hex.Dump(data)
+	gologger.Debug().Msgf("New ICMP echo request from %s (identifier %d)\n", source, echo.ID)
+
+	for uniqueID, fullID := range ids {
+		interaction := &Interaction{
+			Protocol:      "icmp",
+			UniqueID:      uniqueID,
+			FullId:        fullID,
+			RawRequest:    message,
+			RawBytes:      data,
+			RemoteAddress: source,
+			Timestamp:     now,
+			ICMPPaired:    paired,
+		}
+		h.options.applyNAT64(interaction)
+		buffer := &bytes.Buffer{}
+		if err := jsoniter.NewEncoder(buffer).Encode(interaction); err != nil {
+			gologger.Warning().Msgf("Could not encode icmp interaction: %s\n", err)
+			continue
+		}
+		gologger.Debug().Msgf("%s\n", buffer.String())
+		if err := h.options.Storage.AddInteraction(uniqueID[:h.options.CorrelationIdLength], buffer.Bytes()); err != nil {
+			gologger.Warning().Msgf("Could not store icmp interaction: %s\n", err)
+		}
+	}
+}
+
+// icmpDataStrings returns the printable runs of the echo data, where the
+// timestamp of the ping tools precedes the pattern
+func icmpDataStrings(data []byte) []string {
+	return strings.FieldsFunc(string(data), func(r rune) bool {
+		return r > unicode.MaxASCII || !unicode.IsPrint(r)
+	})
+}
+
+// Close closes the icmp sockets
+func (h *ICMPServer) Close() {
+	for _, conn := range h.conns {
+		_ = conn.Close()
+	}
+}
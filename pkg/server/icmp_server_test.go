@@ -0,0 +1,64 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/icmp"
+)
+
+func TestLookupTracker(t *testing.T) {
+	tracker := NewLookupTracker(time.Minute)
+	tracker.record("c59e3crp82ke7bcnedq0", "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy", "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh", "10.0.0.53")
+	lookup, ok := tracker.pair("192.0.2.1")
+	require.True(t, ok)
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy", lookup.uniqueID)
+
+	// concurrent lookups of different ids are only paired with their source
+	tracker.record("c59e3crp82ke7bcnedq1", "c59e3crp82ke7bcnedq1cfjqdpeyyyyyy", "c59e3crp82ke7bcnedq1cfjqdpeyyyyyy.interact.sh", "192.0.2.2")
+	_, ok = tracker.pair("192.0.2.1")
+	require.False(t, ok)
+	lookup, ok = tracker.pair("192.0.2.2")
+	require.True(t, ok)
+	require.Equal(t, "c59e3crp82ke7bcnedq1", lookup.correlationID)
+
+	var empty *LookupTracker
+	empty.record("c59e3crp82ke7bcnedq0", "", "", "")
+}
+
+func TestICMPServerEcho(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0")
+	options.AddressLookups = NewLookupTracker(icmpPairingWindow)
+	store := options.Storage
+
+	server := &ICMPServer{options: options, runs: make(map[string]time.Time)}
+
+	// correlation id in the echo data after the ping timestamp
+	data := append([]byte{0x8f, 0x3a, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00}, "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy"...)
+	server.handleEcho(&net.IPAddr{IP: net.ParseIP("192.0.2.1")}, &icmp.Echo{ID: 1, Seq: 1, Data: data})
+	// the following echo requests of the run are not recorded
+	server.handleEcho(&net.IPAddr{IP: net.ParseIP("192.0.2.1")}, &icmp.Echo{ID: 1, Seq: 2, Data: data})
+	// echo request without data paired with the lookup of the host name
+	server.handleEcho(&net.IPAddr{IP: net.ParseIP("192.0.2.2")}, &icmp.Echo{ID: 7, Seq: 1})
+	options.AddressLookups.record("c59e3crp82ke7bcnedq0", "c59e3crp82ke7bcnedq0cfjqdpeyyyyzz", "c59e3crp82ke7bcnedq0cfjqdpeyyyyzz.interact.sh", "10.0.0.53")
+	server.handleEcho(&net.IPAddr{IP: net.ParseIP("192.0.2.2")}, &icmp.Echo{ID: 8, Seq: 1})
+
+	item, err := store.GetCacheItem("c59e3crp82ke7bcnedq0")
+	require.Nil(t, err)
+	require.Len(t, item.Data, 2)
+	interactions := make(map[string]*Interaction)
+	for _, data := range item.Data {
+		interaction := &Interaction{}
+		require.Nil(t, jsoniter.Unmarshal([]byte(data), interaction))
+		require.Equal(t, "icmp", interaction.Protocol)
+		interactions[interaction.RemoteAddress] = interaction
+	}
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy", interactions["192.0.2.1"].FullId)
+	require.False(t, interactions["192.0.2.1"].ICMPPaired)
+	require.Contains(t, interactions["192.0.2.1"].RawRequest, "Identifier=1\nSequence=1\n")
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyzz.interact.sh", interactions["192.0.2.2"].FullId)
+	require.True(t, interactions["192.0.2.2"].ICMPPaired)
+}
@@ -0,0 +1,204 @@
+package server
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/projectdiscovery/gologger"
+)
+
+// imapLiteral matches the literal announced at the end of an imap command line
+var imapLiteral = regexp.MustCompile(`\{(\d+)(\+?)\}$`)
+
+// IMAPServer is an imap server rejecting every login, the LOGIN commands of
+// users containing correlation ids are recorded with the credentials and the
+// commands sent by the client
+type IMAPServer struct {
+	mailServer
+}
+
+// NewIMAPServer returns a new imap server
+func NewIMAPServer(options *Options) (*IMAPServer, error) {
+	return &IMAPServer{mailServer: mailServer{options: options}}, nil
+}
+
+// ListenAndServe listens on the imap port, and on the imaps port when a tls
+// configuration is available
+func (h *IMAPServer) ListenAndServe(tlsConfig *tls.Config, imapAlive, imapsAlive chan bool) {
+	if tlsConfig != nil {
+		tlsConfig = h.options.listenerTLSConfig("imap", tlsConfig)
+	}
+	go func() {
+		if tlsConfig == nil {
+			return
+		}
+		handle := func(conn net.Conn) { h.handleConn(conn, tlsConfig, true) }
+		if err := h.serve(h.options.IMAPSPort, imapsAlive, handle); err != nil {
+			gologger.Error().Msgf("Could not serve imap with tls on port %d: %s\n", h.options.IMAPSPort, err)
+			imapsAlive <- false
+		}
+	}()
+	handle := func(conn net.Conn) { h.handleConn(conn, tlsConfig, false) }
+	if err := h.serve(h.options.IMAPPort, imapAlive, handle); err != nil {
+		gologger.Error().Msgf("Could not serve imap on port %d: %s\n", h.options.IMAPPort, err)
+		imapAlive <- false
+	}
+}
+
+func (h *IMAPServer) handleConn(conn net.Conn, tlsConfig *tls.Config, implicitTLS bool) {
+	defer conn.Close()
+
+	h.options.Stats.RecordSource("imap", conn.RemoteAddr().String())
+	session := newMailSession(h.options, "imap", conn)
+	if implicitTLS {
+		if err := session.startTLS(tlsConfig); err != nil {
+			gologger.Debug().Msgf("Imap tls handshake from %s failed: %s\n", conn.RemoteAddr(), err)
+			return
+		}
+	}
+	capabilities := func() string {
+		if tlsConfig != nil && !session.isTLS() {
+			return "IMAP4rev1 ID STARTTLS"
+		}
+		return "IMAP4rev1 ID"
+	}
+	if err := session.write(fmt.Sprintf("* OK [CAPABILITY %s] IMAP4rev1 Service Ready\r\n", capabilities())); err != nil {
+		return
+	}
+
+	for {
+		tag, command, args, err := readIMAPCommand(session)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				gologger.Debug().Msgf("Imap connection from %s closed: %s\n", session.conn.RemoteAddr(), err)
+			}
+			return
+		}
+		var reply string
+		switch command {
+		case "CAPABILITY":
+			reply = fmt.Sprintf("* CAPABILITY %s\r\n%s OK CAPABILITY completed\r\n", capabilities(), tag)
+		case "ID":
+			reply = fmt.Sprintf("* ID NIL\r\n%s OK ID completed\r\n", tag)
+		case "NOOP":
+			reply = fmt.Sprintf("%s OK NOOP completed\r\n", tag)
+		case "STARTTLS":
+			if tlsConfig == nil || session.isTLS() {
+				reply = fmt.Sprintf("%s BAD STARTTLS not available\r\n", tag)
+				break
+			}
+			if err := session.write(fmt.Sprintf("%s OK Begin TLS negotiation now\r\n", tag)); err != nil {
+				return
+			}
+			if err := session.startTLS(tlsConfig); err != nil {
+				gologger.Debug().Msgf("Imap starttls from %s failed: %s\n", conn.RemoteAddr(), err)
+				return
+			}
+			continue
+		case "LOGIN":
+			if len(args) < 2 {
+				reply = fmt.Sprintf("%s BAD Invalid arguments\r\n", tag)
+				break
+			}
+			session.recordLogin(args[0], args[1])
+			reply = fmt.Sprintf("%s NO [AUTHENTICATIONFAILED] Authentication failed\r\n", tag)
+		case "LOGOUT":
+			_ = session.write(fmt.Sprintf("* BYE Logging out\r\n%s OK LOGOUT completed\r\n", tag))
+			return
+		default:
+			reply = fmt.Sprintf("%s BAD Command not recognized\r\n", tag)
+		}
+		if err := session.write(reply); err != nil {
+			return
+		}
+	}
+}
+
+// readIMAPCommand reads a tagged command with its arguments, the literals
+// announced at the end of the line are read inline as quoted strings
+func readIMAPCommand(session *mailSession) (tag, command string, args []string, err error) {
+	line, err := session.readLine()
+	if err != nil {
+		return "", "", nil, err
+	}
+	for {
+		match := imapLiteral.FindStringSubmatch(line)
+		if match == nil {
+			break
+		}
+		size, _ := strconv.Atoi(match[1])
+		if size > mailMaxLine {
+			return "", "", nil, errors.New("literal too long")
+		}
+		// synchronizing literals wait for a continuation request
+		if match[2] == "" {
+			if err := session.write("+ Ready for literal data\r\n"); err != nil {
+				return "", "", nil, err
+			}
+		}
+		literal := make([]byte, size)
+		if _, err := io.ReadFull(session.reader, literal); err != nil {
+			return "", "", nil, err
+		}
+		_, _ = session.transcript.Write(literal)
+		rest, err := session.readLine()
+		if err != nil {
+			return "", "", nil, err
+		}
+		line = line[:len(line)-len(match[0])] + quoteIMAPString(string(literal)) + rest
+	}
+
+	fields := parseIMAPArguments(line)
+	if len(fields) < 2 {
+		return "", "", nil, fmt.Errorf("invalid imap command %q", line)
+	}
+	return fields[0], strings.ToUpper(fields[1]), fields[2:], nil
+}
+
+// parseIMAPArguments splits a command line into atoms and quoted strings
+func parseIMAPArguments(line string) []string {
+	var (
+		args    []string
+		current strings.Builder
+		quoted  bool
+		inArg   bool
+	)
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quoted && c == '\\' && i+1 < len(line):
+			i++
+			current.WriteByte(line[i])
+		case quoted && c == '"':
+			quoted = false
+		case quoted:
+			current.WriteByte(c)
+		case c == '"':
+			quoted, inArg = true, true
+		case c == ' ':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteByte(c)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args
+}
+
+// quoteIMAPString returns the value as an imap quoted string
+func quoteIMAPString(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
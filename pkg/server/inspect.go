@@ -0,0 +1,223 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"sync"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/gologger"
+)
+
+const (
+	// inspectPathPrefix is the path of the inspect pages, followed by the correlation id
+	inspectPathPrefix = "/inspect/"
+	// inspectHistory is the number of recent http interactions shown per session
+	inspectHistory = 50
+	// inspectSessions is the maximum number of sessions whose interactions are kept
+	inspectSessions = 1024
+	// inspectRefresh is the interval in seconds the inspect pages are reloaded at
+	inspectRefresh = 5
+)
+
+// InspectLog keeps in memory the recent http interactions of the sessions with
+// an inspect page, which are stored encrypted for the clients otherwise
+type InspectLog struct {
+	mu       sync.Mutex
+	sessions map[string][]*Interaction
+	// order are the correlation ids from the least recently recorded
+	order []string
+}
+
+// NewInspectLog returns an empty log of the inspected sessions
+func NewInspectLog() *InspectLog {
+	return &InspectLog{sessions: make(map[string][]*Interaction)}
+}
+
+// record adds an interaction of a session, the log may be nil
+func (l *InspectLog) record(correlationID string, interaction *Interaction) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	interactions, ok := l.sessions[correlationID]
+	if ok {
+		l.removeOrder(correlationID)
+	} else if len(l.order) >= inspectSessions {
+		delete(l.sessions, l.order[0])
+		l.order = l.order[1:]
+	}
+	if len(interactions) >= inspectHistory {
+		interactions = interactions[1:]
+	}
+	l.sessions[correlationID] = append(interactions, interaction)
+	l.order = append(l.order, correlationID)
+}
+
+// recent returns the recorded interactions of a session, the latest first
+func (l *InspectLog) recent(correlationID string) []*Interaction {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	interactions := l.sessions[correlationID]
+	recent := make([]*Interaction, 0, len(interactions))
+	for i := len(interactions) - 1; i >= 0; i-- {
+		recent = append(recent, interactions[i])
+	}
+	return recent
+}
+
+// remove forgets the interactions of a session, the log may be nil
+func (l *InspectLog) remove(correlationID string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.sessions[correlationID]; ok {
+		delete(l.sessions, correlationID)
+		l.removeOrder(correlationID)
+	}
+}
+
+func (l *InspectLog) removeOrder(correlationID string) {
+	for i, id := range l.order {
+		if id == correlationID {
+			l.order = append(l.order[:i:i], l.order[i+1:]...)
+			return
+		}
+	}
+}
+
+// InspectRequest is a request to enable or disable the inspect page of a session
+type InspectRequest struct {
+	// CorrelationID is an ID for correlation with requests.
+	CorrelationID string `json:"correlation-id"`
+	// SecretKey is the secretKey of the session owner.
+	SecretKey string `json:"secret-key"`
+	// Disable removes the inspect page and its recorded interactions.
+	Disable bool `json:"disable,omitempty"`
+}
+
+// InspectResponse is the response to an inspect request
+type InspectResponse struct {
+	// URL is the address of the inspect page, including its key
+	URL string `json:"url,omitempty"`
+}
+
+// inspectHandler is a handler for enabling the inspect page of a session, a
+// new key is generated each time so that a previous url is revoked
+func (h *HTTPServer) inspectHandler(w http.ResponseWriter, req *http.Request) {
+	r := &InspectRequest{}
+	if err := jsoniter.NewDecoder(req.Body).Decode(r); err != nil {
+		gologger.Warning().Msgf("Could not decode json body: %s\n", err)
+		jsonError(w, fmt.Sprintf("could not decode json body: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	var key string
+	if !r.Disable {
+		buffer := make([]byte, 16)
+		if _, err := rand.Read(buffer); err != nil {
+			jsonError(w, fmt.Sprintf("could not generate inspect key: %s", err), http.StatusInternalServerError)
+			return
+		}
+		key = hex.EncodeToString(buffer)
+	}
+	if err := h.options.Storage.SetInspectKey(r.CorrelationID, r.SecretKey, key); err != nil {
+		gologger.Warning().Msgf("Could not set inspect key for id %s: %s\n", r.CorrelationID, err)
+		jsonError(w, fmt.Sprintf("could not set inspect key: %s", err), http.StatusBadRequest)
+		return
+	}
+	response := &InspectResponse{}
+	if r.Disable {
+		h.options.InspectLog.remove(r.CorrelationID)
+	} else {
+		scheme := "http"
+		if req.TLS != nil {
+			scheme = "https"
+		}
+		response.URL = fmt.Sprintf("%s://%s%s%s?key=%s", scheme, req.Host, inspectPathPrefix, r.CorrelationID, key)
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = jsoniter.NewEncoder(w).Encode(response)
+	gologger.Debug().Msgf("Set inspect page for correlationID %s (disabled: %v)\n", r.CorrelationID, r.Disable)
+}
+
+// inspectPageHandler renders the recent http interactions of a session to
+// the holders of its inspect key
+func (h *HTTPServer) inspectPageHandler(w http.ResponseWriter, req *http.Request) {
+	correlationID := strings.TrimPrefix(req.URL.Path, inspectPathPrefix)
+	key := req.URL.Query().Get("key")
+	item, err := h.options.Storage.GetCacheItem(correlationID)
+	if err != nil || key == "" || subtle.ConstantTimeCompare([]byte(item.GetInspectKey()), []byte(key)) != 1 {
+		http.NotFound(w, req)
+		return
+	}
+
+	// the page holds its key, it must not leak through referrers or caches
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	page := &inspectPage{CorrelationID: correlationID, Refresh: inspectRefresh, Interactions: h.options.InspectLog.recent(correlationID)}
+	if err := inspectTemplate.Execute(w, page); err != nil {
+		gologger.Warning().Msgf("Could not render inspect page for %s: %s\n", correlationID, err)
+	}
+}
+
+// recordInspected records an http interaction for the inspect page of its session
+func (h *HTTPServer) recordInspected(correlationID string, interaction *Interaction) {
+	if h.options.InspectLog == nil {
+		return
+	}
+	if item, err := h.options.Storage.GetCacheItem(correlationID); err == nil && item.GetInspectKey() != "" {
+		h.options.InspectLog.record(correlationID, interaction)
+	}
+}
+
+// inspectPage is the data of the inspect page template
+type inspectPage struct {
+	CorrelationID string
+	Refresh       int
+	Interactions  []*Interaction
+}
+
+var inspectTemplate = template.Must(template.New("inspect").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="{{.Refresh}}">
+<meta name="referrer" content="no-referrer">
+<title>Interactions of {{.CorrelationID}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+details { border: 1px solid #ccc; margin-bottom: 1em; padding: 0.5em; }
+summary { cursor: pointer; }
+pre { background: #f5f5f5; padding: 0.5em; overflow-x: auto; white-space: pre-wrap; word-break: break-all; }
+</style>
+</head>
+<body>
+<h1>HTTP interactions of {{.CorrelationID}}</h1>
+<p>The {{len .Interactions}} most recent requests are shown, the page is reloaded every {{.Refresh}} seconds.</p>
+{{range .Interactions}}<details open>
+<summary>{{.Timestamp.Format "2006-01-02 15:04:05 MST"}} from {{.RemoteAddress}} to {{.FullId}}</summary>
+<h3>Request</h3>
+<pre>{{.RawRequest}}</pre>
+<h3>Response</h3>
+<pre>{{.RawResponse}}</pre>
+</details>
+{{else}}<p>No interactions received yet.</p>
+{{end}}</body>
+</html>
+`))
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspectLog(t *testing.T) {
+	log := NewInspectLog()
+	for i := 0; i < inspectHistory+2; i++ {
+		log.record("c59e3crp82ke7bcnedq0", &Interaction{RawRequest: string(rune('a' + i%26))})
+	}
+	recent := log.recent("c59e3crp82ke7bcnedq0")
+	require.Len(t, recent, inspectHistory)
+	// the latest interactions are first
+	require.Equal(t, string(rune('a'+(inspectHistory+1)%26)), recent[0].RawRequest)
+
+	log.remove("c59e3crp82ke7bcnedq0")
+	require.Empty(t, log.recent("c59e3crp82ke7bcnedq0"))
+
+	var disabled *InspectLog
+	disabled.record("c59e3crp82ke7bcnedq0", &Interaction{})
+	disabled.remove("c59e3crp82ke7bcnedq0")
+}
+
+func TestInspectPage(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0")
+	options.InspectLog = NewInspectLog()
+
+	server, err := NewHTTPServer(options)
+	require.Nil(t, err)
+	handler := server.nontlsserver.Handler
+
+	// interactions received before the inspect page is enabled are not kept
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh/before", nil))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "http://interact.sh/inspect", strings.NewReader(`{"correlation-id":"c59e3crp82ke7bcnedq0"}`)))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	response := &InspectResponse{}
+	require.Nil(t, jsoniter.NewDecoder(recorder.Body).Decode(response))
+	inspectURL, err := url.Parse(response.URL)
+	require.Nil(t, err)
+	require.Equal(t, "/inspect/c59e3crp82ke7bcnedq0", inspectURL.Path)
+
+	request := httptest.NewRequest(http.MethodPost, "http://c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh/callback", strings.NewReader("<script>alert(1)</script>"))
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, response.URL, nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, "no-referrer", recorder.Header().Get("Referrer-Policy"))
+	page := recorder.Body.String()
+	require.Contains(t, page, "POST http://c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh/callback")
+	require.Contains(t, page, "&lt;script&gt;alert(1)&lt;/script&gt;")
+	require.NotContains(t, page, "/before")
+
+	// a wrong key is not found
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://interact.sh/inspect/c59e3crp82ke7bcnedq0?key=00", nil))
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+
+	// disabling revokes the key
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "http://interact.sh/inspect", strings.NewReader(`{"correlation-id":"c59e3crp82ke7bcnedq0","disable":true}`)))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, response.URL, nil))
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// javaClassDesc and javaProxyClassDesc are the type codes of the class
+	// descriptors of a java serialization stream
+	javaClassDesc      = 0x72
+	javaProxyClassDesc = 0x7d
+	// maxJavaClasses is the number of class names kept from a stream
+	maxJavaClasses = 64
+)
+
+var (
+	// javaStreamMagic is the magic and version starting a java serialization stream
+	javaStreamMagic = []byte("\xac\xed\x00\x05")
+	// javaClassName matches the binary names of classes and arrays
+	javaClassName = regexp.MustCompile(`^(?:\[+(?:[BCDFIJSZ]|L[\w$.]+;)|[A-Za-z_$][\w$]*(?:\.[A-Za-z_$][\w$]*)*)$`)
+)
+
+// javaGadgetClasses are the classes (or packages, ending with a dot) of the
+// known deserialization gadget chains, by chain
+var javaGadgetClasses = []struct {
+	class string
+	chain string
+}{
+	{"org.apache.commons.collections.functors.", "CommonsCollections"},
+	{"org.apache.commons.collections.map.LazyMap", "CommonsCollections"},
+	{"org.apache.commons.collections.keyvalue.TiedMapEntry", "CommonsCollections"},
+	{"org.apache.commons.collections4.functors.", "CommonsCollections4"},
+	{"org.apache.commons.collections4.comparators.TransformingComparator", "CommonsCollections4"},
+	{"org.apache.commons.beanutils.BeanComparator", "CommonsBeanutils"},
+	{"com.sun.org.apache.xalan.internal.xsltc.trax.TemplatesImpl", "TemplatesImpl"},
+	{"org.apache.xalan.xsltc.trax.TemplatesImpl", "TemplatesImpl"},
+	{"sun.reflect.annotation.AnnotationInvocationHandler", "AnnotationInvocationHandler"},
+	{"javax.management.BadAttributeValueExpException", "BadAttributeValueExpException"},
+	{"com.sun.rowset.JdbcRowSetImpl", "JdbcRowSetImpl"},
+	{"org.codehaus.groovy.runtime.ConvertedClosure", "Groovy"},
+	{"org.codehaus.groovy.runtime.MethodClosure", "Groovy"},
+	{"org.springframework.beans.factory.ObjectFactory", "Spring"},
+	{"org.springframework.core.SerializableTypeWrapper", "Spring"},
+	{"org.springframework.aop.framework.JdkDynamicAopProxy", "Spring"},
+	{"org.hibernate.engine.spi.TypedValue", "Hibernate"},
+	{"org.hibernate.tuple.component.AbstractComponentTuplizer", "Hibernate"},
+	{"com.mchange.v2.c3p0.", "C3P0"},
+	{"clojure.", "Clojure"},
+	{"bsh.XThis", "BeanShell"},
+	{"org.mozilla.javascript.", "MozillaRhino"},
+	{"org.python.core.", "Jython"},
+	{"com.sun.syndication.feed.impl.ObjectBean", "ROME"},
+	{"org.apache.commons.fileupload.disk.DiskFileItem", "FileUpload"},
+	{"org.apache.wicket.util.upload.DiskFileItem", "Wicket"},
+	{"net.sf.json.JSONObject", "JSON"},
+	{"org.jboss.interceptor.", "JBossInterceptors"},
+	{"com.vaadin.data.util.PropertysetItem", "Vaadin"},
+	{"org.apache.myfaces.view.facelets.el.ValueExpressionMethodExpression", "Myfaces"},
+}
+
+// javaStream are the classes described in a java serialization stream, with
+// the gadget chains they belong to
+type javaStream struct {
+	classes []string
+	gadgets []string
+}
+
+// parseJavaStream returns the classes of the java serialization streams found
+// in the data, nil if there are none. The class descriptors are only scanned
+// for and the objects never instantiated, so truncated and nested streams
+// (eg. in ldap attributes) are supported.
+func parseJavaStream(data []byte) *javaStream {
+	start := bytes.Index(data, javaStreamMagic)
+	if start < 0 {
+		return nil
+	}
+	stream := &javaStream{}
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if !seen[name] && len(stream.classes) < maxJavaClasses {
+			seen[name] = true
+			stream.classes = append(stream.classes, name)
+		}
+	}
+	data = data[start+len(javaStreamMagic):]
+	for i := 0; i < len(data); i++ {
+		switch data[i] {
+		case javaClassDesc:
+			if name, ok := javaUTF(data[i+1:]); ok {
+				add(name)
+				i += 2 + len(name)
+			}
+		case javaProxyClassDesc:
+			// the interfaces of a dynamic proxy class
+			if i+5 > len(data) {
+				continue
+			}
+			count := binary.BigEndian.Uint32(data[i+1:])
+			if count == 0 || count > maxJavaClasses {
+				continue
+			}
+			offset := i + 5
+			var interfaces []string
+			for j := uint32(0); j < count; j++ {
+				name, ok := javaUTF(data[offset:])
+				if !ok {
+					break
+				}
+				interfaces = append(interfaces, name)
+				offset += 2 + len(name)
+			}
+			if len(interfaces) == int(count) {
+				for _, name := range interfaces {
+					add(name)
+				}
+				i = offset - 1
+			}
+		}
+	}
+	if len(stream.classes) == 0 {
+		return nil
+	}
+	stream.gadgets = javaGadgets(stream.classes)
+	return stream
+}
+
+// javaUTF returns the class name encoded as modified utf-8 with its length
+func javaUTF(data []byte) (string, bool) {
+	if len(data) < 2 {
+		return "", false
+	}
+	length := int(binary.BigEndian.Uint16(data))
+	if length == 0 || length > len(data)-2 {
+		return "", false
+	}
+	name := string(data[2 : 2+length])
+	return name, javaClassName.MatchString(name)
+}
+
+// javaGadgets returns the gadget chains the classes belong to
+func javaGadgets(classes []string) []string {
+	chains := make(map[string]bool)
+	var hashMap, url bool
+	for _, class := range classes {
+		for _, gadget := range javaGadgetClasses {
+			if class == gadget.class || (strings.HasSuffix(gadget.class, ".") && strings.HasPrefix(class, gadget.class)) {
+				chains[gadget.chain] = true
+			}
+		}
+		hashMap = hashMap || class == "java.util.HashMap"
+		url = url || class == "java.net.URL"
+	}
+	// an url key of a hash map is resolved on deserialization
+	if hashMap && url {
+		chains["URLDNS"] = true
+	}
+	gadgets := make([]string, 0, len(chains))
+	for chain := range chains {
+		gadgets = append(gadgets, chain)
+	}
+	sort.Strings(gadgets)
+	return gadgets
+}
+
+// marker describes the classes and gadget chains in the raw request
+func (s *javaStream) marker() string {
+	if s == nil {
+		return ""
+	}
+	marker := fmt.Sprintf("\n\nJava Classes: %s\n", strings.Join(s.classes, ", "))
+	if len(s.gadgets) > 0 {
+		marker += fmt.Sprintf("Java Gadget Chains: %s\n", strings.Join(s.gadgets, ", "))
+	}
+	return marker
+}
+
+// apply sets the java fields of the interaction
+func (s *javaStream) apply(interaction *Interaction) {
+	if s == nil {
+		return
+	}
+	interaction.JavaClasses = s.classes
+	interaction.JavaGadgets = s.gadgets
+}
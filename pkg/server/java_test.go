@@ -0,0 +1,49 @@
+package server
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// javaClassDescriptor returns the descriptor of a class without fields
+func javaClassDescriptor(name string) []byte {
+	data := []byte{javaClassDesc}
+	data = binary.BigEndian.AppendUint16(data, uint16(len(name)))
+	data = append(data, name...)
+	// serialVersionUID, SC_SERIALIZABLE flag, no fields, end of block data and no super class
+	data = append(data, 0, 0, 0, 0, 0, 0, 0, 1, 0x02, 0, 0, 0x78, 0x70)
+	return data
+}
+
+func TestParseJavaStream(t *testing.T) {
+	urldns := append([]byte("\xac\xed\x00\x05\x73"), javaClassDescriptor("java.util.HashMap")...)
+	urldns = append(urldns, 0x73)
+	urldns = append(urldns, javaClassDescriptor("java.net.URL")...)
+	stream := parseJavaStream(urldns)
+	require.NotNil(t, stream)
+	require.Equal(t, []string{"java.util.HashMap", "java.net.URL"}, stream.classes)
+	require.Equal(t, []string{"URLDNS"}, stream.gadgets)
+
+	// streams are found in ldap attribute values, with proxy interfaces
+	proxy := []byte{javaProxyClassDesc, 0, 0, 0, 1, 0, 13}
+	proxy = append(proxy, "java.util.Map"...)
+	attribute := append([]byte("\x30\x82\x01\x00javaSerializedData\x04\x82\x00\xff\xac\xed\x00\x05\x73"), proxy...)
+	attribute = append(attribute, javaClassDescriptor("sun.reflect.annotation.AnnotationInvocationHandler")...)
+	attribute = append(attribute, javaClassDescriptor("org.apache.commons.collections.functors.InvokerTransformer")...)
+	stream = parseJavaStream(attribute)
+	require.NotNil(t, stream)
+	require.Equal(t, []string{"java.util.Map", "sun.reflect.annotation.AnnotationInvocationHandler", "org.apache.commons.collections.functors.InvokerTransformer"}, stream.classes)
+	require.Equal(t, []string{"AnnotationInvocationHandler", "CommonsCollections"}, stream.gadgets)
+	require.Contains(t, stream.marker(), "Java Gadget Chains: AnnotationInvocationHandler, CommonsCollections")
+
+	interaction := &Interaction{RawBytes: attribute}
+	applyLDAPJavaStream(interaction)
+	require.Len(t, interaction.JavaClasses, 3)
+	require.Contains(t, interaction.RawRequest, "Java Classes: java.util.Map")
+
+	require.Nil(t, parseJavaStream([]byte("no stream")))
+	// class descriptors must carry binary class names
+	require.Nil(t, parseJavaStream([]byte("\xac\xed\x00\x05\x73\x72\x00\x03a b")))
+}
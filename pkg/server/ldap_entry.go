@@ -0,0 +1,128 @@
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	message "github.com/lor00x/goldap/message"
+	ldap "github.com/projectdiscovery/ldapserver"
+	"gopkg.in/yaml.v3"
+)
+
+// LDAPAttribute is an attribute of the ldap search result entry
+type LDAPAttribute struct {
+	Name   string   `yaml:"name" json:"name"`
+	Values []string `yaml:"values" json:"values"`
+}
+
+// LDAPEntry is the entry returned to ldap search requests
+type LDAPEntry struct {
+	// DN is the dn of the entry, supports {BASE} placeholders for the requested base dn
+	DN string `yaml:"dn" json:"dn"`
+	// Attributes are the attributes of the entry in order
+	Attributes []LDAPAttribute `yaml:"attributes" json:"attributes"`
+}
+
+const (
+	// LDAPExploitReference answers searches for correlation ids with a jndi remote
+	// reference whose factory class is loaded from the http server
+	LDAPExploitReference = "reference"
+	// LDAPExploitSerialized answers searches for correlation ids with jndi serialized data
+	LDAPExploitSerialized = "serialized"
+)
+
+// LDAPExploitModes are the supported jndi response modes to searches for correlation ids
+var LDAPExploitModes = []string{LDAPExploitReference, LDAPExploitSerialized}
+
+// ldapReferenceFactory is the factory class name of the answered jndi references
+const ldapReferenceFactory = "Interactsh"
+
+// DefaultLDAPEntry is the entry returned when no template is configured
+var DefaultLDAPEntry = &LDAPEntry{
+	DN: "cn=interactsh, {BASE}",
+	Attributes: []LDAPAttribute{
+		{Name: "mail", Values: []string{"interact@s.h", "interact@s.h"}},
+		{Name: "company", Values: []string{"aaa"}},
+		{Name: "department", Values: []string{"bbbb"}},
+		{Name: "l", Values: []string{"cccc"}},
+		{Name: "mobile", Values: []string{"123456789"}},
+		{Name: "telephoneNumber", Values: []string{"123456789"}},
+		{Name: "cn", Values: []string{"interact"}},
+	},
+}
+
+// NewLDAPEntry loads a yaml or json ldap search result entry template
+func NewLDAPEntry(path string) (*LDAPEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	entry := &LDAPEntry{}
+	if err := yaml.Unmarshal(data, entry); err != nil {
+		return nil, err
+	}
+	if entry.DN == "" {
+		return nil, errors.New("ldap entry requires a dn")
+	}
+	for _, attribute := range entry.Attributes {
+		if attribute.Name == "" || len(attribute.Values) == 0 {
+			return nil, errors.New("ldap entry attributes require a name and values")
+		}
+	}
+	return entry, nil
+}
+
+// searchResult returns the search result entry for the requested base dn
+func (entry *LDAPEntry) searchResult(baseObject string) message.SearchResultEntry {
+	result := ldap.NewSearchResultEntry(strings.ReplaceAll(entry.DN, "{BASE}", baseObject))
+	for _, attribute := range entry.Attributes {
+		values := make([]message.AttributeValue, 0, len(attribute.Values))
+		for _, value := range attribute.Values {
+			values = append(values, message.AttributeValue(value))
+		}
+		result.AddAttribute(message.AttributeDescription(attribute.Name), values...)
+	}
+	return result
+}
+
+// ldapReferenceEntry returns the jndi entry answered to searches for a correlation id
+// in exploit mode. The codebase points back at the http server on the correlation id
+// subdomain so that the class loading of vulnerable clients is recorded as an http
+// interaction, validating the whole chain. No class is served for the factory and the
+// serialized data is a plain java string, nothing is executed by the clients.
+func (options *Options) ldapReferenceEntry(uniqueID string) *LDAPEntry {
+	codebase := fmt.Sprintf("http://%s.%s", uniqueID, options.GetDomains()[0])
+	if options.HttpPort != 0 && options.HttpPort != 80 {
+		codebase += fmt.Sprintf(":%d", options.HttpPort)
+	}
+	codebase += "/"
+
+	entry := &LDAPEntry{
+		DN: "{BASE}",
+		Attributes: []LDAPAttribute{
+			{Name: "javaClassName", Values: []string{"java.lang.String"}},
+			{Name: "javaCodeBase", Values: []string{codebase}},
+		},
+	}
+	switch options.LDAPExploitMode {
+	case LDAPExploitSerialized:
+		entry.Attributes = append(entry.Attributes, LDAPAttribute{Name: "javaSerializedData", Values: []string{string(javaSerializedString(codebase))}})
+	default:
+		entry.Attributes = append(entry.Attributes,
+			LDAPAttribute{Name: "objectClass", Values: []string{"javaNamingReference"}},
+			LDAPAttribute{Name: "javaFactory", Values: []string{ldapReferenceFactory}},
+		)
+	}
+	return entry
+}
+
+// javaSerializedString returns the java serialization stream of a string
+func javaSerializedString(value string) []byte {
+	// stream magic and version followed by a TC_STRING
+	data := []byte{0xac, 0xed, 0x00, 0x05, 0x74}
+	data = binary.BigEndian.AppendUint16(data, uint16(len(value)))
+	return append(data, value...)
+}
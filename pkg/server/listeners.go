@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// systemdListenFdsStart is the first file descriptor passed by systemd socket activation
+const systemdListenFdsStart = 3
+
+// Listeners are the sockets handed to the servers instead of listening on their
+// address, either inherited from systemd socket activation or bound before the
+// privileges of the process are dropped. Servers listen themselves on the
+// addresses without a socket.
+type Listeners struct {
+	// ReusePort binds the sockets with SO_REUSEPORT, allowing several
+	// sockets on the same address to balance the load of multiple workers
+	ReusePort bool
+
+	mu      sync.Mutex
+	streams map[string][]net.Listener
+	packets map[string][]net.PacketConn
+}
+
+// NewListeners returns an empty set of listeners
+func NewListeners() *Listeners {
+	return &Listeners{
+		streams: make(map[string][]net.Listener),
+		packets: make(map[string][]net.PacketConn),
+	}
+}
+
+// InheritSystemd adds the sockets passed to the process by systemd socket
+// activation (LISTEN_PID and LISTEN_FDS) and returns their addresses
+func (l *Listeners) InheritSystemd() ([]string, error) {
+	if pid, err := strconv.Atoi(os.Getenv("LISTEN_PID")); err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, nil
+	}
+	// the sockets are not passed again to child processes
+	_ = os.Unsetenv("LISTEN_PID")
+	_ = os.Unsetenv("LISTEN_FDS")
+	_ = os.Unsetenv("LISTEN_FDNAMES")
+
+	var addresses []string
+	for fd := systemdListenFdsStart; fd < systemdListenFdsStart+count; fd++ {
+		file := os.NewFile(uintptr(fd), fmt.Sprintf("systemd-fd-%d", fd))
+		if listener, err := net.FileListener(file); err == nil {
+			l.addListener(listener)
+			addresses = append(addresses, listener.Addr().Network()+"/"+listener.Addr().String())
+		} else if conn, err := net.FilePacketConn(file); err == nil {
+			l.addPacketConn(conn)
+			addresses = append(addresses, conn.LocalAddr().Network()+"/"+conn.LocalAddr().String())
+		} else {
+			_ = file.Close()
+			return addresses, fmt.Errorf("unsupported socket passed as file descriptor %d: %s", fd, err)
+		}
+		// the listeners hold a duplicate of the descriptor
+		_ = file.Close()
+	}
+	return addresses, nil
+}
+
+// Bind listens on a stream or packet network address and keeps the socket
+// for the server listening on it. With ReusePort each call binds another
+// socket on the address, one per worker.
+func (l *Listeners) Bind(network, address string) error {
+	l.mu.Lock()
+	bound := len(l.streams[listenerKey(network, address)]) + len(l.packets[listenerKey(network, address)])
+	l.mu.Unlock()
+	if bound > 0 && !l.ReusePort {
+		return nil
+	}
+	config := l.listenConfig()
+	if strings.HasPrefix(network, "udp") {
+		conn, err := config.ListenPacket(context.Background(), network, address)
+		if err != nil {
+			return err
+		}
+		l.addPacketConn(conn)
+		return nil
+	}
+	listener, err := config.Listen(context.Background(), network, address)
+	if err != nil {
+		return err
+	}
+	l.addListener(listener)
+	return nil
+}
+
+// Listen returns a socket kept for the stream network address, or listens on it
+func (l *Listeners) Listen(network, address string) (net.Listener, error) {
+	if l != nil {
+		key := listenerKey(network, address)
+		l.mu.Lock()
+		kept := l.streams[key]
+		if len(kept) > 0 {
+			l.streams[key] = kept[1:]
+		}
+		l.mu.Unlock()
+		if len(kept) > 0 {
+			return kept[0], nil
+		}
+	}
+	config := l.listenConfig()
+	return config.Listen(context.Background(), network, address)
+}
+
+// ListenPacket returns a socket kept for the packet network address, or listens on it
+func (l *Listeners) ListenPacket(network, address string) (net.PacketConn, error) {
+	if l != nil {
+		key := listenerKey(network, address)
+		l.mu.Lock()
+		kept := l.packets[key]
+		if len(kept) > 0 {
+			l.packets[key] = kept[1:]
+		}
+		l.mu.Unlock()
+		if len(kept) > 0 {
+			return kept[0], nil
+		}
+	}
+	config := l.listenConfig()
+	return config.ListenPacket(context.Background(), network, address)
+}
+
+// listenConfig returns the configuration new sockets are bound with
+func (l *Listeners) listenConfig() *net.ListenConfig {
+	if l != nil && l.ReusePort {
+		return &net.ListenConfig{Control: reusePortControl}
+	}
+	return &net.ListenConfig{}
+}
+
+func (l *Listeners) addListener(listener net.Listener) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := listenerKey(listener.Addr().Network(), listener.Addr().String())
+	l.streams[key] = append(l.streams[key], listener)
+}
+
+func (l *Listeners) addPacketConn(conn net.PacketConn) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := listenerKey(conn.LocalAddr().Network(), conn.LocalAddr().String())
+	l.packets[key] = append(l.packets[key], conn)
+}
+
+// listenerKey returns the key of a network address, where the address families
+// of the network and the unspecified addresses are not distinguished
+func listenerKey(network, address string) string {
+	network = strings.TrimRight(network, "46")
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return network + "/" + address
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "*"
+	}
+	return network + "/" + net.JoinHostPort(host, port)
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestListeners(t *testing.T) {
+	require.Equal(t, "tcp/*:53", listenerKey("tcp4", "0.0.0.0:53"))
+	require.Equal(t, "tcp/*:53", listenerKey("tcp", "[::]:53"))
+	require.Equal(t, "udp/*:53", listenerKey("udp", ":53"))
+	require.Equal(t, "tcp/127.0.0.1:53", listenerKey("tcp", "127.0.0.1:53"))
+
+	listeners := NewListeners()
+	listener, err := net.Listen("tcp", "0.0.0.0:0")
+	require.Nil(t, err)
+	defer listener.Close()
+	listeners.addListener(listener)
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	kept, err := listeners.Listen("tcp", fmt.Sprintf(":%d", port))
+	require.Nil(t, err)
+	require.Equal(t, listener, kept)
+	// the socket is handed once, the address is then listened on again
+	_, err = listeners.Listen("tcp", fmt.Sprintf(":%d", port))
+	require.NotNil(t, err)
+
+	var none *Listeners
+	conn, err := none.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+	_ = conn.Close()
+
+	t.Setenv("LISTEN_PID", fmt.Sprint(os.Getpid()+1))
+	t.Setenv("LISTEN_FDS", "1")
+	inherited, err := listeners.InheritSystemd()
+	require.Nil(t, err)
+	require.Empty(t, inherited)
+}
+
+func TestListenersReusePort(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("SO_REUSEPORT load balancing is tested on linux")
+	}
+	listeners := NewListeners()
+	listeners.ReusePort = true
+	conn, err := listeners.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer conn.Close()
+
+	// every bind adds a worker socket on the same address
+	address := conn.LocalAddr().String()
+	require.Nil(t, listeners.Bind("udp", address))
+	require.Nil(t, listeners.Bind("udp", address))
+	require.Len(t, listeners.packets[listenerKey("udp", address)], 2)
+	for i := 0; i < 3; i++ {
+		worker, err := listeners.ListenPacket("udp", address)
+		require.Nil(t, err)
+		require.Equal(t, address, worker.LocalAddr().String())
+		defer worker.Close()
+	}
+
+	listeners.ReusePort = false
+	require.Nil(t, listeners.Bind("tcp", "127.0.0.1:0"))
+	listener, err := listeners.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer listener.Close()
+	_, err = listeners.Listen("tcp", listener.Addr().String())
+	require.NotNil(t, err)
+}
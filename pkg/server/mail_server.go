@@ -0,0 +1,177 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/gologger"
+	stringsutil "github.com/projectdiscovery/utils/strings"
+)
+
+const (
+	// mailTimeout is the idle time after which an imap or pop3 connection is closed
+	mailTimeout = 30 * time.Second
+	// mailMaxLine bounds the length of an imap or pop3 command line
+	mailMaxLine = 8192
+)
+
+// mailServer holds the listeners of the imap and pop3 servers
+type mailServer struct {
+	options   *Options
+	listeners []net.Listener
+	mu        sync.Mutex
+}
+
+func (h *mailServer) serve(port int, alive chan bool, handle func(conn net.Conn)) error {
+	listener, err := h.options.Listeners.Listen("tcp", net.JoinHostPort(h.options.ListenIP, fmt.Sprint(port)))
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	h.listeners = append(h.listeners, listener)
+	h.mu.Unlock()
+	alive <- true
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go handle(conn)
+	}
+}
+
+// Close stops the server
+func (h *mailServer) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, listener := range h.listeners {
+		_ = listener.Close()
+	}
+}
+
+// mailSession is an imap or pop3 connection with the transcript of the
+// commands sent by the client
+type mailSession struct {
+	options    *Options
+	protocol   string
+	conn       net.Conn
+	reader     *bufio.Reader
+	serverName string
+	transcript *bodyCapture
+}
+
+func newMailSession(options *Options, protocol string, conn net.Conn) *mailSession {
+	return &mailSession{
+		options:    options,
+		protocol:   protocol,
+		conn:       conn,
+		reader:     bufio.NewReader(conn),
+		transcript: newBodyCapture(options.captureLimit()),
+	}
+}
+
+// startTLS upgrades the connection to tls, for implicit tls ports and
+// STARTTLS/STLS commands
+func (s *mailSession) startTLS(tlsConfig *tls.Config) error {
+	tlsConn := tls.Server(s.conn, tlsConfig)
+	_ = tlsConn.SetDeadline(time.Now().Add(mailTimeout))
+	if err := tlsConn.Handshake(); err != nil {
+		return err
+	}
+	s.serverName = tlsConn.ConnectionState().ServerName
+	s.conn = tlsConn
+	s.reader = bufio.NewReader(tlsConn)
+	return nil
+}
+
+// isTLS reports whether the connection was upgraded to tls
+func (s *mailSession) isTLS() bool {
+	_, ok := s.conn.(*tls.Conn)
+	return ok
+}
+
+// readLine reads a command line terminated by \r\n or \n and adds it to the transcript
+func (s *mailSession) readLine() (string, error) {
+	_ = s.conn.SetDeadline(time.Now().Add(mailTimeout))
+	var line []byte
+	for {
+		chunk, isPrefix, err := s.reader.ReadLine()
+		if err != nil {
+			return "", err
+		}
+		line = append(line, chunk...)
+		if len(line) > mailMaxLine {
+			return "", errors.New("line too long")
+		}
+		if !isPrefix {
+			break
+		}
+	}
+	_, _ = s.transcript.Write(append(line, '\n'))
+	return string(line), nil
+}
+
+// write sends a reply to the client
+func (s *mailSession) write(reply string) error {
+	_, err := s.conn.Write([]byte(reply))
+	return err
+}
+
+// recordLogin stores a login attempt with the transcript of the connection for
+// each correlation id of the tls server name and the user
+func (s *mailSession) recordLogin(user, password string) {
+	ids := make(map[string]string)
+	for _, value := range []string{s.serverName, user} {
+		for _, chunk := range stringsutil.SplitAny(value, ".@/\\:;,=()`'\" \r\n\t") {
+			normalized := NormalizeHost(chunk)
+			for part := range stringsutil.SlideWithLength(normalized, s.options.GetIdLength()) {
+				if _, ok := ids[part]; !ok && s.options.isCorrelationID(part) {
+					ids[part] = normalized
+				}
+			}
+		}
+	}
+
+	host, _, _ := net.SplitHostPort(s.conn.RemoteAddr().String())
+	var message bytes.Buffer
+	if s.serverName != "" {
+		message.WriteString(fmt.Sprintf("ServerName=%s\n\n", s.serverName))
+	}
+	message.Write(s.transcript.Bytes())
+	message.WriteString(s.transcript.marker())
+	for uniqueID, fullID := range ids {
+		interaction := &Interaction{
+			Protocol:      s.protocol,
+			UniqueID:      uniqueID,
+			FullId:        fullID,
+			RawRequest:    message.String(),
+			RemoteAddress: host,
+			Timestamp:     time.Now(),
+			TLSServerName: s.serverName,
+			MailUser:      user,
+			MailPassword:  password,
+		}
+		s.transcript.apply(interaction)
+		s.options.applyNAT64(interaction)
+		buffer := &bytes.Buffer{}
+		if err := jsoniter.NewEncoder(buffer).Encode(interaction); err != nil {
+			gologger.Warning().Msgf("Could not encode %s interaction: %s\n", s.protocol, err)
+			continue
+		}
+		gologger.Debug().Msgf("%s\n", buffer.String())
+		if err := s.options.Storage.AddInteraction(uniqueID[:s.options.CorrelationIdLength], buffer.Bytes()); err != nil {
+			gologger.Warning().Msgf("Could not store %s interaction: %s\n", s.protocol, err)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"bufio"
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIMAPArguments(t *testing.T) {
+	require.Equal(t, []string{"a1", "LOGIN", "user", "pass word"}, parseIMAPArguments(`a1 LOGIN user "pass word"`))
+	require.Equal(t, []string{"a1", "LOGIN", `quo"te`, `back\slash`, ""}, parseIMAPArguments(`a1 LOGIN "quo\"te" "back\\slash" ""`))
+	require.Equal(t, `"a\"b\\c"`, quoteIMAPString(`a"b\c`))
+}
+
+// newMailTestServer returns the options of a mail server listening on two local ports
+func newMailTestServer(t *testing.T, correlationIDs ...string) (*Options, net.Listener, net.Listener) {
+	plain, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	secure, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	listeners := NewListeners()
+	listeners.addListener(plain)
+	listeners.addListener(secure)
+	options := newTestOptions(t, correlationIDs...)
+	options.ListenIP = "127.0.0.1"
+	options.Listeners = listeners
+	options.BodyCaptureSize = 1
+	return options, plain, secure
+}
+
+func TestIMAPServer(t *testing.T) {
+	options, plain, secure := newMailTestServer(t, "c59e3crp82ke7bcnedq0")
+	options.IMAPPort = plain.Addr().(*net.TCPAddr).Port
+	options.IMAPSPort = secure.Addr().(*net.TCPAddr).Port
+	certificate, err := localhostCertificate()
+	require.Nil(t, err)
+	server, err := NewIMAPServer(options)
+	require.Nil(t, err)
+	alive, tlsAlive := make(chan bool, 1), make(chan bool, 1)
+	go server.ListenAndServe(&tls.Config{Certificates: []tls.Certificate{certificate}}, alive, tlsAlive)
+	require.True(t, <-alive)
+	require.True(t, <-tlsAlive)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", plain.Addr().String())
+	require.Nil(t, err)
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	require.Nil(t, err)
+	require.Equal(t, "* OK [CAPABILITY IMAP4rev1 ID STARTTLS] IMAP4rev1 Service Ready\r\n", line)
+
+	// the password is sent as a synchronizing literal
+	_, err = conn.Write([]byte("a1 LOGIN \"c59e3crp82ke7bcnedq0cfjqdpeyyyyyy@interact.sh\" {6}\r\n"))
+	require.Nil(t, err)
+	line, err = reader.ReadString('\n')
+	require.Nil(t, err)
+	require.Equal(t, "+ Ready for literal data\r\n", line)
+	_, err = conn.Write([]byte("s3cr\"t\r\n"))
+	require.Nil(t, err)
+	line, err = reader.ReadString('\n')
+	require.Nil(t, err)
+	require.Equal(t, "a1 NO [AUTHENTICATIONFAILED] Authentication failed\r\n", line)
+	_, err = conn.Write([]byte("a2 LOGOUT\r\n"))
+	require.Nil(t, err)
+	line, _ = reader.ReadString('\n')
+	require.Equal(t, "* BYE Logging out\r\n", line)
+
+	item, err := options.Storage.GetCacheItem("c59e3crp82ke7bcnedq0")
+	require.Nil(t, err)
+	require.Len(t, item.Data, 1)
+	interaction := &Interaction{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[0]), interaction))
+	require.Equal(t, "imap", interaction.Protocol)
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy", interaction.FullId)
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy@interact.sh", interaction.MailUser)
+	require.Equal(t, "s3cr\"t", interaction.MailPassword)
+	require.Contains(t, interaction.RawRequest, "a1 LOGIN")
+}
+
+func TestPOP3Server(t *testing.T) {
+	options, plain, secure := newMailTestServer(t, "c59e3crp82ke7bcnedq0")
+	options.POP3Port = plain.Addr().(*net.TCPAddr).Port
+	options.POP3SPort = secure.Addr().(*net.TCPAddr).Port
+	certificate, err := localhostCertificate()
+	require.Nil(t, err)
+	server, err := NewPOP3Server(options)
+	require.Nil(t, err)
+	alive, tlsAlive := make(chan bool, 1), make(chan bool, 1)
+	go server.ListenAndServe(&tls.Config{Certificates: []tls.Certificate{certificate}}, alive, tlsAlive)
+	require.True(t, <-alive)
+	require.True(t, <-tlsAlive)
+	defer server.Close()
+
+	// the connection is upgraded with stls, the server name correlates the login
+	conn, err := net.Dial("tcp", plain.Addr().String())
+	require.Nil(t, err)
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	require.Nil(t, err)
+	require.Equal(t, "+OK POP3 server ready\r\n", line)
+	_, err = conn.Write([]byte("STLS\r\n"))
+	require.Nil(t, err)
+	line, err = reader.ReadString('\n')
+	require.Nil(t, err)
+	require.Equal(t, "+OK Begin TLS negotiation\r\n", line)
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: "c59e3crp82ke7bcnedq0cfjqdpeyyyyzz.interact.sh", InsecureSkipVerify: true})
+	_, err = tlsConn.Write([]byte("USER admin\r\nPASS hunter2\r\nQUIT\r\n"))
+	require.Nil(t, err)
+	reader = bufio.NewReader(tlsConn)
+	for _, expected := range []string{"+OK\r\n", "-ERR [AUTH] Authentication failed\r\n", "+OK Bye\r\n"} {
+		line, err = reader.ReadString('\n')
+		require.Nil(t, err)
+		require.Equal(t, expected, line)
+	}
+
+	item, err := options.Storage.GetCacheItem("c59e3crp82ke7bcnedq0")
+	require.Nil(t, err)
+	require.Len(t, item.Data, 1)
+	interaction := &Interaction{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[0]), interaction))
+	require.Equal(t, "pop3", interaction.Protocol)
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyzz", interaction.FullId)
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyzz.interact.sh", interaction.TLSServerName)
+	require.Equal(t, "admin", interaction.MailUser)
+	require.Equal(t, "hunter2", interaction.MailPassword)
+	require.Equal(t, "ServerName=c59e3crp82ke7bcnedq0cfjqdpeyyyyzz.interact.sh\n\nSTLS\nUSER admin\nPASS hunter2\n", interaction.RawRequest)
+}
@@ -1,25 +1,388 @@
 package server
 
 import (
+	"container/list"
+	"net"
 	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	units "github.com/docker/go-units"
+	jsoniter "github.com/json-iterator/go"
 	"github.com/mackerelio/go-osstat/network"
 	"github.com/projectdiscovery/interactsh/pkg/storage"
+	stringsutil "github.com/projectdiscovery/utils/strings"
 )
 
 type Metrics struct {
-	Dns      uint64                `json:"dns"`
-	Ftp      uint64                `json:"ftp"`
-	Http     uint64                `json:"http"`
-	Ldap     uint64                `json:"ldap"`
-	Smb      uint64                `json:"smb"`
-	Smtp     uint64                `json:"smtp"`
-	Sessions int64                 `json:"sessions"`
-	Cache    *storage.CacheMetrics `json:"cache"`
-	Memory   *MemoryMetrics        `json:"memory"`
-	Cpu      *CpuStats             `json:"cpu"`
-	Network  *NetworkStats         `json:"network"`
+	Dns       uint64                `json:"dns"`
+	Ftp       uint64                `json:"ftp"`
+	Http      uint64                `json:"http"`
+	Ldap      uint64                `json:"ldap"`
+	Smb       uint64                `json:"smb"`
+	Smtp      uint64                `json:"smtp"`
+	Ssh       uint64                `json:"ssh"`
+	Mysql     uint64                `json:"mysql"`
+	Redis     uint64                `json:"redis"`
+	Tcp       uint64                `json:"tcp"`
+	Udp       uint64                `json:"udp"`
+	Websocket uint64                `json:"websocket"`
+	Imap      uint64                `json:"imap"`
+	Pop3      uint64                `json:"pop3"`
+	Tftp      uint64                `json:"tftp"`
+	Snmp      uint64                `json:"snmp"`
+	Ntp       uint64                `json:"ntp"`
+	Sip       uint64                `json:"sip"`
+	Grpc      uint64                `json:"grpc"`
+	Icmp      uint64                `json:"icmp"`
+	Sessions  int64                 `json:"sessions"`
+	Protocols ProtocolMetrics       `json:"protocols"`
+	Bandwidth BandwidthMetrics      `json:"bandwidth"`
+	Dropped   DropMetrics           `json:"dropped"`
+	Sources   SourceMetrics         `json:"-"`
+	Cache     *storage.CacheMetrics `json:"cache"`
+	Memory    *MemoryMetrics        `json:"memory"`
+	Cpu       *CpuStats             `json:"cpu"`
+	Network   *NetworkStats         `json:"network"`
+}
+
+// Record accounts a request received by the protocol server
+func (m *Metrics) Record(protocol string) {
+	switch protocol {
+	case "dns":
+		atomic.AddUint64(&m.Dns, 1)
+	case "ftp":
+		atomic.AddUint64(&m.Ftp, 1)
+	case "http":
+		atomic.AddUint64(&m.Http, 1)
+	case "ldap":
+		atomic.AddUint64(&m.Ldap, 1)
+	case "smb":
+		atomic.AddUint64(&m.Smb, 1)
+	case "smtp":
+		atomic.AddUint64(&m.Smtp, 1)
+	case "ssh":
+		atomic.AddUint64(&m.Ssh, 1)
+	case "mysql":
+		atomic.AddUint64(&m.Mysql, 1)
+	case "redis":
+		atomic.AddUint64(&m.Redis, 1)
+	case "tcp":
+		atomic.AddUint64(&m.Tcp, 1)
+	case "udp":
+		atomic.AddUint64(&m.Udp, 1)
+	case "websocket":
+		atomic.AddUint64(&m.Websocket, 1)
+	case "imap":
+		atomic.AddUint64(&m.Imap, 1)
+	case "pop3":
+		atomic.AddUint64(&m.Pop3, 1)
+	case "tftp":
+		atomic.AddUint64(&m.Tftp, 1)
+	case "snmp":
+		atomic.AddUint64(&m.Snmp, 1)
+	case "ntp":
+		atomic.AddUint64(&m.Ntp, 1)
+	case "sip":
+		atomic.AddUint64(&m.Sip, 1)
+	case "grpc":
+		atomic.AddUint64(&m.Grpc, 1)
+	case "icmp":
+		atomic.AddUint64(&m.Icmp, 1)
+	}
+	m.Protocols.Record(protocol, time.Now())
+}
+
+// RecordSource accounts a request received by the protocol server from a
+// source address, with or without port
+func (m *Metrics) RecordSource(protocol, source string) {
+	m.Record(protocol)
+	if host, _, err := net.SplitHostPort(source); err == nil {
+		source = host
+	}
+	if source != "" {
+		m.Sources.Record(source, protocol, time.Now())
+	}
+}
+
+// ProtocolStats contains the interaction statistics of a protocol
+type ProtocolStats struct {
+	Count     uint64    `json:"count"`
+	FirstSeen time.Time `json:"first-seen"`
+	LastSeen  time.Time `json:"last-seen"`
+	// Rate is the number of interactions per minute between first and last seen
+	Rate float64 `json:"rate"`
+}
+
+// ProtocolMetrics tracks the interaction statistics of each protocol
+type ProtocolMetrics struct {
+	mu        sync.RWMutex
+	protocols map[string]*ProtocolStats
+}
+
+// Record accounts an interaction of the protocol received at timestamp
+func (p *ProtocolMetrics) Record(protocol string, timestamp time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.protocols == nil {
+		p.protocols = make(map[string]*ProtocolStats)
+	}
+	stats, ok := p.protocols[protocol]
+	if !ok {
+		stats = &ProtocolStats{FirstSeen: timestamp, LastSeen: timestamp}
+		p.protocols[protocol] = stats
+	}
+	stats.Count++
+	if timestamp.Before(stats.FirstSeen) {
+		stats.FirstSeen = timestamp
+	}
+	if timestamp.After(stats.LastSeen) {
+		stats.LastSeen = timestamp
+	}
+	if elapsed := stats.LastSeen.Sub(stats.FirstSeen); elapsed > 0 {
+		stats.Rate = float64(stats.Count) / elapsed.Minutes()
+	}
+}
+
+// Snapshot returns a copy of the statistics of each protocol
+func (p *ProtocolMetrics) Snapshot() map[string]ProtocolStats {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	snapshot := make(map[string]ProtocolStats, len(p.protocols))
+	for protocol, stats := range p.protocols {
+		snapshot[protocol] = *stats
+	}
+	return snapshot
+}
+
+// MarshalJSON encodes a snapshot of the statistics
+func (p *ProtocolMetrics) MarshalJSON() ([]byte, error) {
+	return jsoniter.Marshal(p.Snapshot())
+}
+
+// MaxBandwidthSessions is the maximum number of sessions whose served bytes
+// are tracked individually, the least recently served one is forgotten
+// when the maximum is reached
+var MaxBandwidthSessions = 10000
+
+// BandwidthStats contains the bytes served in total, per protocol and per session
+type BandwidthStats struct {
+	Total     uint64            `json:"total"`
+	Protocols map[string]uint64 `json:"protocols"`
+	Sessions  map[string]uint64 `json:"sessions"`
+}
+
+// BandwidthMetrics tracks the bytes served per protocol and per session
+type BandwidthMetrics struct {
+	mu        sync.RWMutex
+	total     uint64
+	protocols map[string]uint64
+	sessions  map[string]*list.Element
+	// recent orders the sessions from the most recently served
+	recent *list.List
+}
+
+// sessionBandwidth is the bytes served for a session
+type sessionBandwidth struct {
+	session string
+	served  uint64
+}
+
+// Record accounts bytes served by the protocol server for a session, the
+// session is the correlation id of the request and may be empty
+func (b *BandwidthMetrics) Record(protocol, session string, n int) {
+	if n <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.protocols == nil {
+		b.protocols = make(map[string]uint64)
+		b.sessions = make(map[string]*list.Element)
+		b.recent = list.New()
+	}
+	b.total += uint64(n)
+	b.protocols[protocol] += uint64(n)
+	if session == "" {
+		return
+	}
+	element, ok := b.sessions[session]
+	if !ok {
+		if len(b.sessions) >= MaxBandwidthSessions {
+			oldest := b.recent.Back()
+			b.recent.Remove(oldest)
+			delete(b.sessions, oldest.Value.(*sessionBandwidth).session)
+		}
+		element = b.recent.PushFront(&sessionBandwidth{session: session})
+		b.sessions[session] = element
+	}
+	element.Value.(*sessionBandwidth).served += uint64(n)
+	b.recent.MoveToFront(element)
+}
+
+// Forget stops tracking the bytes served for a session, eg. once deregistered
+func (b *BandwidthMetrics) Forget(session string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if element, ok := b.sessions[session]; ok {
+		b.recent.Remove(element)
+		delete(b.sessions, session)
+	}
+}
+
+// Served returns the bytes served in total and for the session
+func (b *BandwidthMetrics) Served(session string) (total, sessionTotal uint64) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if element, ok := b.sessions[session]; ok {
+		sessionTotal = element.Value.(*sessionBandwidth).served
+	}
+	return b.total, sessionTotal
+}
+
+// Snapshot returns a copy of the bandwidth statistics
+func (b *BandwidthMetrics) Snapshot() BandwidthStats {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	snapshot := BandwidthStats{
+		Total:     b.total,
+		Protocols: make(map[string]uint64, len(b.protocols)),
+		Sessions:  make(map[string]uint64, len(b.sessions)),
+	}
+	for protocol, n := range b.protocols {
+		snapshot.Protocols[protocol] = n
+	}
+	for session, element := range b.sessions {
+		snapshot.Sessions[session] = element.Value.(*sessionBandwidth).served
+	}
+	return snapshot
+}
+
+// MarshalJSON encodes a snapshot of the bandwidth statistics
+func (b *BandwidthMetrics) MarshalJSON() ([]byte, error) {
+	return jsoniter.Marshal(b.Snapshot())
+}
+
+// SourceWindow is the time window in which the protocols attempted by a source are aggregated
+var SourceWindow = time.Hour
+
+// MaxSources is the maximum number of sources tracked within the window
+var MaxSources = 10000
+
+// SourceStats contains the protocols attempted by a source within the window
+type SourceStats struct {
+	Source    string            `json:"source"`
+	Protocols map[string]uint64 `json:"protocols"`
+	FirstSeen time.Time         `json:"first-seen"`
+	LastSeen  time.Time         `json:"last-seen"`
+}
+
+// SourceMetrics tracks the protocols attempted by each source address
+type SourceMetrics struct {
+	mu      sync.Mutex
+	sources map[string]*SourceStats
+}
+
+// Record accounts a request of the protocol received from a source at timestamp,
+// the least recently seen source is forgotten when the maximum is reached
+func (s *SourceMetrics) Record(source, protocol string, timestamp time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sources == nil {
+		s.sources = make(map[string]*SourceStats)
+	}
+	stats, ok := s.sources[source]
+	if ok && timestamp.Sub(stats.LastSeen) > SourceWindow {
+		ok = false
+	}
+	if !ok {
+		if _, exists := s.sources[source]; !exists && len(s.sources) >= MaxSources {
+			s.evict(timestamp)
+		}
+		stats = &SourceStats{Source: source, Protocols: make(map[string]uint64), FirstSeen: timestamp}
+		s.sources[source] = stats
+	}
+	stats.Protocols[protocol]++
+	if timestamp.After(stats.LastSeen) {
+		stats.LastSeen = timestamp
+	}
+}
+
+// evict forgets the sources not seen within the window, or the least
+// recently seen one if all are
+func (s *SourceMetrics) evict(now time.Time) {
+	var oldest *SourceStats
+	for source, stats := range s.sources {
+		if now.Sub(stats.LastSeen) > SourceWindow {
+			delete(s.sources, source)
+			continue
+		}
+		if oldest == nil || stats.LastSeen.Before(oldest.LastSeen) {
+			oldest = stats
+		}
+	}
+	if len(s.sources) >= MaxSources && oldest != nil {
+		delete(s.sources, oldest.Source)
+	}
+}
+
+// Snapshot returns the sources seen within the window before now, the most
+// recently seen first
+func (s *SourceMetrics) Snapshot(now time.Time) []SourceStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := make([]SourceStats, 0, len(s.sources))
+	for source, stats := range s.sources {
+		if now.Sub(stats.LastSeen) > SourceWindow {
+			delete(s.sources, source)
+			continue
+		}
+		copied := *stats
+		copied.Protocols = make(map[string]uint64, len(stats.Protocols))
+		for protocol, count := range stats.Protocols {
+			copied.Protocols[protocol] = count
+		}
+		snapshot = append(snapshot, copied)
+	}
+	sort.Slice(snapshot, func(i, j int) bool {
+		return snapshot[i].LastSeen.After(snapshot[j].LastSeen)
+	})
+	return snapshot
+}
+
+// sessionOf returns the correlation id contained in a name, if any
+func (options *Options) sessionOf(name string) string {
+	for _, part := range strings.Split(strings.ToLower(name), ".") {
+		for chunk := range stringsutil.SlideWithLength(part, options.GetIdLength()) {
+			if options.isCorrelationID(chunk) {
+				return chunk[:options.CorrelationIdLength]
+			}
+		}
+	}
+	return ""
+}
+
+// egressCapped reports whether the egress caps are reached globally or for the session
+func (options *Options) egressCapped(session string) bool {
+	if options.EgressCap <= 0 && options.SessionEgressCap <= 0 {
+		return false
+	}
+	total, sessionTotal := options.Stats.Bandwidth.Served(session)
+	if options.EgressCap > 0 && total >= uint64(options.EgressCap)*1024*1024 {
+		return true
+	}
+	return options.SessionEgressCap > 0 && session != "" && sessionTotal >= uint64(options.SessionEgressCap)*1024*1024
 }
 
 func GetCacheMetrics(options *Options) *storage.CacheMetrics {
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProtocolMetrics(t *testing.T) {
+	metrics := &Metrics{}
+	metrics.Record("smtp")
+	require.EqualValues(t, 1, metrics.Smtp)
+
+	now := time.Now()
+	protocols := &ProtocolMetrics{}
+	protocols.Record("ldap", now)
+	protocols.Record("ldap", now.Add(2*time.Minute))
+	protocols.Record("ftp", now.Add(-time.Minute))
+	require.Len(t, protocols.Snapshot(), 2)
+
+	stats := protocols.Snapshot()["ldap"]
+	require.EqualValues(t, 2, stats.Count)
+	require.True(t, stats.FirstSeen.Equal(now))
+	require.Equal(t, 1.0, stats.Rate)
+
+	data, err := jsoniter.Marshal(metrics)
+	require.Nil(t, err)
+	require.Contains(t, string(data), `"protocols":{"smtp":{"count":1`)
+}
+
+func TestBandwidthMetrics(t *testing.T) {
+	options := &Options{
+		Stats:                    &Metrics{},
+		SessionEgressCap:         1,
+		CorrelationIdLength:      20,
+		CorrelationIdNonceLength: 13,
+	}
+	session := options.sessionOf("C59E3CRP82KE7BCNEDQ0CFJQDPEYYYYYY.interact.sh")
+	require.Equal(t, "c59e3crp82ke7bcnedq0", session)
+	require.Empty(t, options.sessionOf("interact.sh"))
+
+	options.Stats.Bandwidth.Record("dns", "", 100)
+	options.Stats.Bandwidth.Record("http", session, 1024*1024)
+	require.True(t, options.egressCapped(session))
+	require.False(t, options.egressCapped("other"))
+
+	options.EgressCap = 2
+	require.False(t, options.egressCapped(""))
+	options.Stats.Bandwidth.Record("http", "", 1024*1024)
+	require.True(t, options.egressCapped(""))
+
+	stats := options.Stats.Bandwidth.Snapshot()
+	require.EqualValues(t, 2*1024*1024+100, stats.Total)
+	require.EqualValues(t, 100, stats.Protocols["dns"])
+	require.Len(t, stats.Sessions, 1)
+
+	data, err := jsoniter.Marshal(options.Stats)
+	require.Nil(t, err)
+	require.Contains(t, string(data), `"bandwidth":{"total":2097252`)
+}
+
+func TestBandwidthMetricsEviction(t *testing.T) {
+	defer func(max int) { MaxBandwidthSessions = max }(MaxBandwidthSessions)
+	MaxBandwidthSessions = 2
+
+	bandwidth := &BandwidthMetrics{}
+	bandwidth.Record("http", "first", 10)
+	bandwidth.Record("http", "second", 20)
+	bandwidth.Record("http", "first", 10)
+
+	// the least recently served session makes room for the new ones
+	bandwidth.Record("http", "third", 30)
+	_, served := bandwidth.Served("third")
+	require.EqualValues(t, 30, served)
+	_, served = bandwidth.Served("first")
+	require.EqualValues(t, 20, served)
+	_, served = bandwidth.Served("second")
+	require.EqualValues(t, 0, served)
+
+	bandwidth.Forget("first")
+	total, served := bandwidth.Served("first")
+	require.EqualValues(t, 0, served)
+	require.EqualValues(t, 70, total)
+	require.Len(t, bandwidth.Snapshot().Sessions, 1)
+}
+
+func TestSourceMetrics(t *testing.T) {
+	metrics := &Metrics{}
+	metrics.RecordSource("dns", "192.0.2.1:53124")
+	metrics.RecordSource("http", "192.0.2.1")
+	metrics.RecordSource("http", "192.0.2.1:40000")
+	require.EqualValues(t, 2, metrics.Http)
+
+	now := time.Now()
+	sources := metrics.Sources.Snapshot(now)
+	require.Len(t, sources, 1)
+	require.Equal(t, "192.0.2.1", sources[0].Source)
+	require.Equal(t, map[string]uint64{"dns": 1, "http": 2}, sources[0].Protocols)
+
+	// sources not seen within the window are forgotten
+	metrics.Sources.Record("192.0.2.2", "ssh", now.Add(SourceWindow))
+	sources = metrics.Sources.Snapshot(now.Add(SourceWindow + time.Minute))
+	require.Len(t, sources, 1)
+	require.Equal(t, "192.0.2.2", sources[0].Source)
+
+	defer func(max int) { MaxSources = max }(MaxSources)
+	MaxSources = 1
+	metrics.Sources.Record("192.0.2.3", "ftp", now.Add(SourceWindow+2*time.Minute))
+	sources = metrics.Sources.Snapshot(now.Add(SourceWindow + 2*time.Minute))
+	require.Len(t, sources, 1)
+	require.Equal(t, "192.0.2.3", sources[0].Source)
+}
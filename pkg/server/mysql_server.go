@@ -0,0 +1,485 @@
+//go:build !no_mysql
+
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/gologger"
+	stringsutil "github.com/projectdiscovery/utils/strings"
+)
+
+const (
+	// MySQLServerVersion is the version announced by the mysql server
+	MySQLServerVersion = "5.7.42-log"
+	// mysqlTimeout is the time after which a mysql connection is closed
+	mysqlTimeout = 30 * time.Second
+	// mysqlMaxPacket is the largest payload of a mysql packet
+	mysqlMaxPacket = 1<<24 - 1
+)
+
+// mysql capability flags
+const (
+	mysqlClientLongPassword uint32 = 1 << iota
+	mysqlClientFoundRows
+	mysqlClientLongFlag
+	mysqlClientConnectWithDB
+	mysqlClientNoSchema
+	mysqlClientCompress
+	mysqlClientODBC
+	mysqlClientLocalFiles
+	mysqlClientIgnoreSpace
+	mysqlClientProtocol41
+	mysqlClientInteractive
+	mysqlClientSSL
+	mysqlClientIgnoreSigpipe
+	mysqlClientTransactions
+	mysqlClientReserved
+	mysqlClientSecureConnection
+	mysqlClientMultiStatements
+	mysqlClientMultiResults
+	mysqlClientPSMultiResults
+	mysqlClientPluginAuth
+	mysqlClientConnectAttrs
+	mysqlClientPluginAuthLenencClientData
+)
+
+// mysqlCapabilityNames are the names of the capability flags recorded for a client
+var mysqlCapabilityNames = []string{
+	"LONG_PASSWORD", "FOUND_ROWS", "LONG_FLAG", "CONNECT_WITH_DB", "NO_SCHEMA", "COMPRESS",
+	"ODBC", "LOCAL_FILES", "IGNORE_SPACE", "PROTOCOL_41", "INTERACTIVE", "SSL", "IGNORE_SIGPIPE",
+	"TRANSACTIONS", "RESERVED", "SECURE_CONNECTION", "MULTI_STATEMENTS", "MULTI_RESULTS",
+	"PS_MULTI_RESULTS", "PLUGIN_AUTH", "CONNECT_ATTRS", "PLUGIN_AUTH_LENENC_CLIENT_DATA",
+}
+
+// mysqlServerCapabilities are the capabilities announced by the server, without
+// ssl and compression so that the clients talk in the clear
+const mysqlServerCapabilities = mysqlClientLongPassword | mysqlClientFoundRows | mysqlClientLongFlag |
+	mysqlClientConnectWithDB | mysqlClientLocalFiles | mysqlClientProtocol41 | mysqlClientTransactions |
+	mysqlClientSecureConnection | mysqlClientMultiStatements | mysqlClientMultiResults |
+	mysqlClientPluginAuth | mysqlClientConnectAttrs | mysqlClientPluginAuthLenencClientData
+
+// mysql commands
+const (
+	mysqlComQuit  = 0x01
+	mysqlComQuery = 0x03
+)
+
+// mysqlLoadDataLocal matches the file of a LOAD DATA LOCAL INFILE statement
+var mysqlLoadDataLocal = regexp.MustCompile(`(?is)^\s*LOAD\s+DATA\s+(?:LOW_PRIORITY\s+|CONCURRENT\s+)?LOCAL\s+INFILE\s+(?:'([^']*)'|"([^"]*)")`)
+
+// MySQLServer is a fake mysql server accepting every login, the handshakes and
+// LOAD DATA LOCAL INFILE requests of connections containing correlation ids in
+// their user, database, connection attributes or statements are recorded
+type MySQLServer struct {
+	options      *Options
+	listener     net.Listener
+	connectionID uint32
+	mu           sync.Mutex
+}
+
+// NewMySQLServer returns a new mysql server
+func NewMySQLServer(options *Options) (*MySQLServer, error) {
+	return &MySQLServer{options: options}, nil
+}
+
+// ListenAndServe listens on the mysql port
+func (h *MySQLServer) ListenAndServe(mysqlAlive chan bool) {
+	listener, err := h.options.Listeners.Listen("tcp", net.JoinHostPort(h.options.ListenIP, fmt.Sprint(h.options.MysqlPort)))
+	if err != nil {
+		gologger.Error().Msgf("Could not serve mysql on port %d: %s\n", h.options.MysqlPort, err)
+		mysqlAlive <- false
+		return
+	}
+	h.mu.Lock()
+	h.listener = listener
+	h.mu.Unlock()
+	mysqlAlive <- true
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				gologger.Error().Msgf("Could not serve mysql on port %d: %s\n", h.options.MysqlPort, err)
+				mysqlAlive <- false
+			}
+			return
+		}
+		go h.handleConn(conn)
+	}
+}
+
+// mysqlHandshake is the handshake response of a mysql client
+type mysqlHandshake struct {
+	capabilities uint32
+	user         string
+	database     string
+	authPlugin   string
+	attributes   map[string]string
+}
+
+// mysqlSession is a mysql client connection
+type mysqlSession struct {
+	conn     net.Conn
+	reader   *bufio.Reader
+	sequence byte
+	// ids are the correlation ids of the connection with their full id
+	ids map[string]string
+}
+
+func (h *MySQLServer) handleConn(conn net.Conn) {
+	defer conn.Close()
+
+	h.options.Stats.RecordSource("mysql", conn.RemoteAddr().String())
+	_ = conn.SetDeadline(time.Now().Add(mysqlTimeout))
+	session := &mysqlSession{conn: conn, reader: bufio.NewReader(conn), ids: make(map[string]string)}
+
+	if err := session.writePacket(h.greeting()); err != nil {
+		return
+	}
+	payload, err := session.readPacket()
+	if err != nil {
+		gologger.Debug().Msgf("MySQL connection from %s closed: %s\n", conn.RemoteAddr(), err)
+		return
+	}
+	handshake, err := parseMySQLHandshake(payload)
+	if err != nil {
+		gologger.Debug().Msgf("Invalid mysql handshake from %s: %s\n", conn.RemoteAddr(), err)
+		return
+	}
+	gologger.Debug().Msgf("New mysql handshake from %s as %q\n", conn.RemoteAddr(), handshake.user)
+
+	var message strings.Builder
+	message.WriteString("Type=Handshake\n")
+	message.WriteString(fmt.Sprintf("User=%s\n", handshake.user))
+	message.WriteString(fmt.Sprintf("Database=%s\n", handshake.database))
+	message.WriteString(fmt.Sprintf("AuthPlugin=%s\n", handshake.authPlugin))
+	message.WriteString(fmt.Sprintf("Capabilities=%s\n", strings.Join(mysqlCapabilities(handshake.capabilities), ",")))
+	values := []string{handshake.user, handshake.database}
+	for _, name := range sortedKeys(handshake.attributes) {
+		message.WriteString(fmt.Sprintf("Attribute.%s=%s\n", name, handshake.attributes[name]))
+		values = append(values, handshake.attributes[name])
+	}
+	h.correlate(session, values...)
+	h.recordInteraction(session, handshake, &Interaction{RawRequest: message.String()})
+
+	// every login is accepted
+	if err := session.writePacket(mysqlOK()); err != nil {
+		return
+	}
+	for {
+		session.sequence = 0
+		payload, err := session.readPacket()
+		if err != nil || len(payload) == 0 || payload[0] == mysqlComQuit {
+			return
+		}
+		if payload[0] == mysqlComQuery {
+			query := string(payload[1:])
+			gologger.Debug().Msgf("New mysql query from %s: %q\n", conn.RemoteAddr(), query)
+			if match := mysqlLoadDataLocal.FindStringSubmatch(query); match != nil {
+				if err := h.handleLoadDataLocal(session, handshake, query, match[1]+match[2]); err != nil {
+					return
+				}
+				continue
+			}
+		}
+		if err := session.writePacket(mysqlOK()); err != nil {
+			return
+		}
+	}
+}
+
+// handleLoadDataLocal requests the file of a LOAD DATA LOCAL INFILE statement
+// and records the statement with the received content
+func (h *MySQLServer) handleLoadDataLocal(session *mysqlSession, handshake *mysqlHandshake, query, file string) error {
+	if err := session.writePacket(append([]byte{0xfb}, file...)); err != nil {
+		return err
+	}
+	capture := newBodyCapture(h.options.captureLimit())
+	for {
+		payload, err := session.readPacket()
+		if err != nil {
+			return err
+		}
+		// the content ends with an empty packet
+		if len(payload) == 0 {
+			break
+		}
+		_, _ = capture.Write(payload)
+	}
+
+	var message strings.Builder
+	message.WriteString("Type=LoadDataLocalInfile\n")
+	message.WriteString(fmt.Sprintf("Query=%s\n", query))
+	message.WriteString(fmt.Sprintf("File=%s\n\n", file))
+	message.Write(capture.Bytes())
+	message.WriteString(capture.marker())
+
+	h.correlate(session, query)
+	interaction := &Interaction{RawRequest: message.String(), MySQLInfile: file}
+	capture.apply(interaction)
+	h.recordInteraction(session, handshake, interaction)
+	return session.writePacket(mysqlOK())
+}
+
+// correlate adds the correlation ids found in the values to the connection
+func (h *MySQLServer) correlate(session *mysqlSession, values ...string) {
+	for _, value := range values {
+		for _, chunk := range stringsutil.SplitAny(value, ".@/\\:;,=()`'\" \r\n\t") {
+			normalized := NormalizeHost(chunk)
+			for part := range stringsutil.SlideWithLength(normalized, h.options.GetIdLength()) {
+				if _, ok := session.ids[part]; !ok && h.options.isCorrelationID(part) {
+					session.ids[part] = normalized
+				}
+			}
+		}
+	}
+}
+
+// recordInteraction stores the interaction for each correlation id of the connection
+func (h *MySQLServer) recordInteraction(session *mysqlSession, handshake *mysqlHandshake, interaction *Interaction) {
+	host, _, _ := net.SplitHostPort(session.conn.RemoteAddr().String())
+	interaction.Protocol = "mysql"
+	interaction.RemoteAddress = host
+	interaction.MySQLUser = handshake.user
+	interaction.MySQLDatabase = handshake.database
+	interaction.MySQLCapabilities = mysqlCapabilities(handshake.capabilities)
+	interaction.MySQLAttributes = handshake.attributes
+	h.options.applyNAT64(interaction)
+
+	for uniqueID, fullID := range session.ids {
+		interaction.UniqueID = uniqueID
+		interaction.FullId = fullID
+		interaction.Timestamp = time.Now()
+		buffer := &bytes.Buffer{}
+		if err := jsoniter.NewEncoder(buffer).Encode(interaction); err != nil {
+			gologger.Warning().Msgf("Could not encode mysql interaction: %s\n", err)
+			continue
+		}
+		gologger.Debug().Msgf("%s\n", buffer.String())
+		if err := h.options.Storage.AddInteraction(uniqueID[:h.options.CorrelationIdLength], buffer.Bytes()); err != nil {
+			gologger.Warning().Msgf("Could not store mysql interaction: %s\n", err)
+		}
+	}
+}
+
+// greeting returns the initial handshake packet of the server
+func (h *MySQLServer) greeting() []byte {
+	// the scramble must not contain nul bytes
+	scramble := make([]byte, 20)
+	_, _ = rand.Read(scramble)
+	for i := range scramble {
+		scramble[i] = 'A' + scramble[i]%26
+	}
+
+	var packet bytes.Buffer
+	packet.WriteByte(10)
+	packet.WriteString(MySQLServerVersion)
+	packet.WriteByte(0)
+	_ = binary.Write(&packet, binary.LittleEndian, atomic.AddUint32(&h.connectionID, 1))
+	packet.Write(scramble[:8])
+	packet.WriteByte(0)
+	_ = binary.Write(&packet, binary.LittleEndian, uint16(mysqlServerCapabilities&0xffff))
+	// utf8_general_ci
+	packet.WriteByte(0x21)
+	// SERVER_STATUS_AUTOCOMMIT
+	_ = binary.Write(&packet, binary.LittleEndian, uint16(0x0002))
+	_ = binary.Write(&packet, binary.LittleEndian, uint16(mysqlServerCapabilities>>16))
+	packet.WriteByte(byte(len(scramble) + 1))
+	packet.Write(make([]byte, 10))
+	packet.Write(scramble[8:])
+	packet.WriteByte(0)
+	packet.WriteString("mysql_native_password")
+	packet.WriteByte(0)
+	return packet.Bytes()
+}
+
+// Close stops the mysql server
+func (h *MySQLServer) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.listener != nil {
+		_ = h.listener.Close()
+	}
+}
+
+// readPacket reads the payload of a mysql packet
+func (s *mysqlSession) readPacket() ([]byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(s.reader, header); err != nil {
+		return nil, err
+	}
+	length := int(uint32(header[0]) | uint32(header[1])<<8 | uint32(header[2])<<16)
+	s.sequence = header[3] + 1
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(s.reader, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// writePacket writes a mysql packet with the next sequence id
+func (s *mysqlSession) writePacket(payload []byte) error {
+	if len(payload) > mysqlMaxPacket {
+		return errors.New("mysql packet too large")
+	}
+	length := len(payload)
+	packet := append([]byte{byte(length), byte(length >> 8), byte(length >> 16), s.sequence}, payload...)
+	s.sequence++
+	_, err := s.conn.Write(packet)
+	return err
+}
+
+// mysqlOK returns an ok packet without affected rows
+func mysqlOK() []byte {
+	return []byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00}
+}
+
+// mysqlCapabilities returns the names of the capability flags
+func mysqlCapabilities(flags uint32) []string {
+	var names []string
+	for i, name := range mysqlCapabilityNames {
+		if flags&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// parseMySQLHandshake parses the handshake response of a client
+func parseMySQLHandshake(payload []byte) (*mysqlHandshake, error) {
+	reader := &mysqlReader{data: payload}
+	handshake := &mysqlHandshake{}
+	if len(payload) >= 2 && uint32(binary.LittleEndian.Uint16(payload))&mysqlClientProtocol41 == 0 {
+		// pre 4.1 clients only send the user before the scramble
+		handshake.capabilities = uint32(binary.LittleEndian.Uint16(payload))
+		reader.skip(5)
+		handshake.user = reader.nulString()
+		return handshake, reader.err
+	}
+	capabilities := reader.next(4)
+	if reader.err != nil {
+		return nil, reader.err
+	}
+	handshake.capabilities = binary.LittleEndian.Uint32(capabilities)
+	// max packet size, character set and filler
+	reader.skip(4 + 1 + 23)
+	handshake.user = reader.nulString()
+	switch {
+	case handshake.capabilities&mysqlClientPluginAuthLenencClientData != 0:
+		reader.skip(int(reader.lenEncInt()))
+	case handshake.capabilities&mysqlClientSecureConnection != 0:
+		reader.skip(int(reader.readByte()))
+	default:
+		reader.nulString()
+	}
+	if handshake.capabilities&mysqlClientConnectWithDB != 0 && !reader.done() {
+		handshake.database = reader.nulString()
+	}
+	if handshake.capabilities&mysqlClientPluginAuth != 0 && !reader.done() {
+		handshake.authPlugin = reader.nulString()
+	}
+	if handshake.capabilities&mysqlClientConnectAttrs != 0 && !reader.done() {
+		attributes := &mysqlReader{data: reader.next(int(reader.lenEncInt()))}
+		handshake.attributes = make(map[string]string)
+		for !attributes.done() && attributes.err == nil {
+			key := attributes.lenEncString()
+			value := attributes.lenEncString()
+			if attributes.err == nil {
+				handshake.attributes[key] = value
+			}
+		}
+	}
+	return handshake, reader.err
+}
+
+// mysqlReader reads the fields of a mysql packet payload
+type mysqlReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *mysqlReader) done() bool {
+	return r.pos >= len(r.data)
+}
+
+func (r *mysqlReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.pos+n > len(r.data) {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	value := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return value
+}
+
+func (r *mysqlReader) skip(n int) {
+	r.next(n)
+}
+
+func (r *mysqlReader) readByte() byte {
+	if value := r.next(1); value != nil {
+		return value[0]
+	}
+	return 0
+}
+
+func (r *mysqlReader) nulString() string {
+	if r.err != nil {
+		return ""
+	}
+	end := bytes.IndexByte(r.data[r.pos:], 0)
+	if end < 0 {
+		r.err = io.ErrUnexpectedEOF
+		return ""
+	}
+	value := string(r.data[r.pos : r.pos+end])
+	r.pos += end + 1
+	return value
+}
+
+func (r *mysqlReader) lenEncInt() uint64 {
+	first := r.readByte()
+	var size int
+	switch first {
+	case 0xfc:
+		size = 2
+	case 0xfd:
+		size = 3
+	case 0xfe:
+		size = 8
+	default:
+		return uint64(first)
+	}
+	var value uint64
+	for i, b := range r.next(size) {
+		value |= uint64(b) << (8 * i)
+	}
+	// lengths are bounded by the packet
+	if value > mysqlMaxPacket {
+		r.err = errors.New("invalid length encoded integer")
+		return 0
+	}
+	return value
+}
+
+func (r *mysqlReader) lenEncString() string {
+	return string(r.next(int(r.lenEncInt())))
+}
@@ -0,0 +1,21 @@
+//go:build no_mysql
+
+package server
+
+import "errors"
+
+// MySQLServer is a placeholder for the mysql server excluded by the no_mysql build tag
+type MySQLServer struct{}
+
+// NewMySQLServer returns an error as mysql support is not compiled in
+func NewMySQLServer(options *Options) (*MySQLServer, error) {
+	return nil, errors.New("mysql support is not compiled in (no_mysql build tag)")
+}
+
+// ListenAndServe is a no-op as mysql support is not compiled in
+func (h *MySQLServer) ListenAndServe(mysqlAlive chan bool) {
+	mysqlAlive <- false
+}
+
+// Close is a no-op as mysql support is not compiled in
+func (h *MySQLServer) Close() {}
@@ -0,0 +1,97 @@
+//go:build !no_mysql
+
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMySQLServer(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0")
+	store := options.Storage
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	listeners := NewListeners()
+	listeners.addListener(listener)
+
+	options.ListenIP = "127.0.0.1"
+	options.MysqlPort = listener.Addr().(*net.TCPAddr).Port
+	options.Listeners = listeners
+	options.BodyCaptureSize = 1
+	server, err := NewMySQLServer(options)
+	require.Nil(t, err)
+	alive := make(chan bool, 1)
+	go server.ListenAndServe(alive)
+	require.True(t, <-alive)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	require.Nil(t, err)
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	client := &mysqlSession{conn: conn, reader: bufio.NewReader(conn)}
+
+	greeting, err := client.readPacket()
+	require.Nil(t, err)
+	require.Equal(t, byte(10), greeting[0])
+
+	capabilities := mysqlClientProtocol41 | mysqlClientSecureConnection | mysqlClientConnectWithDB |
+		mysqlClientPluginAuth | mysqlClientConnectAttrs | mysqlClientLocalFiles
+	var response bytes.Buffer
+	_ = binary.Write(&response, binary.LittleEndian, capabilities)
+	response.Write(make([]byte, 4+1+23))
+	response.WriteString("c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh\x00")
+	response.Write([]byte{3, 1, 2, 3})
+	response.WriteString("app\x00mysql_native_password\x00")
+	response.Write([]byte{19, 12})
+	response.WriteString("program_name")
+	response.Write([]byte{5})
+	response.WriteString("mysql")
+	require.Nil(t, client.writePacket(response.Bytes()))
+
+	ok, err := client.readPacket()
+	require.Nil(t, err)
+	require.Equal(t, byte(0x00), ok[0])
+
+	client.sequence = 0
+	require.Nil(t, client.writePacket([]byte("\x03LOAD DATA LOCAL INFILE '/etc/passwd' INTO TABLE t")))
+	request, err := client.readPacket()
+	require.Nil(t, err)
+	require.Equal(t, "\xfb/etc/passwd", string(request))
+	require.Nil(t, client.writePacket(bytes.Repeat([]byte("A"), 2048)))
+	require.Nil(t, client.writePacket(nil))
+	ok, err = client.readPacket()
+	require.Nil(t, err)
+	require.Equal(t, byte(0x00), ok[0])
+
+	client.sequence = 0
+	require.Nil(t, client.writePacket([]byte{mysqlComQuit}))
+
+	item, err := store.GetCacheItem("c59e3crp82ke7bcnedq0")
+	require.Nil(t, err)
+	require.Len(t, item.Data, 2)
+
+	handshake := &Interaction{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[0]), handshake))
+	require.Equal(t, "mysql", handshake.Protocol)
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy", handshake.UniqueID)
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh", handshake.MySQLUser)
+	require.Equal(t, "app", handshake.MySQLDatabase)
+	require.Contains(t, handshake.MySQLCapabilities, "LOCAL_FILES")
+	require.Equal(t, map[string]string{"program_name": "mysql"}, handshake.MySQLAttributes)
+
+	infile := &Interaction{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[1]), infile))
+	require.Equal(t, "/etc/passwd", infile.MySQLInfile)
+	require.True(t, infile.Truncated)
+	require.EqualValues(t, 2048, infile.BodySize)
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"fmt"
+	"net"
+)
+
+// NAT64WellKnownPrefixes are the well-known and local-use NAT64 prefixes (RFC 6052, RFC 8215)
+// always recognized in remote addresses
+var NAT64WellKnownPrefixes = []string{"64:ff9b::/96", "64:ff9b:1::/48"}
+
+var nat64WellKnownNetworks, _ = ParseNAT64Prefixes(NAT64WellKnownPrefixes)
+
+// ParseNAT64Prefixes parses NAT64 prefixes, only the prefix lengths defined by RFC 6052 are allowed
+func ParseNAT64Prefixes(prefixes []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, prefix := range prefixes {
+		ip, network, err := net.ParseCIDR(prefix)
+		if err != nil || ip.To4() != nil {
+			return nil, fmt.Errorf("invalid nat64 prefix %s", prefix)
+		}
+		switch ones, _ := network.Mask.Size(); ones {
+		case 32, 40, 48, 56, 64, 96:
+		default:
+			return nil, fmt.Errorf("invalid nat64 prefix length for %s (32, 40, 48, 56, 64 or 96)", prefix)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// embeddedIPv4 extracts the IPv4 address embedded in an IPv6 address with the
+// given NAT64 prefix, skipping the reserved bits 64 to 71 (RFC 6052 section 2.2)
+func embeddedIPv4(ip net.IP, prefix *net.IPNet) net.IP {
+	ip = ip.To16()
+	if ip == nil || ip.To4() != nil || !prefix.Contains(ip) {
+		return nil
+	}
+	var octets []byte
+	switch ones, _ := prefix.Mask.Size(); ones {
+	case 32:
+		octets = ip[4:8]
+	case 40:
+		octets = []byte{ip[5], ip[6], ip[7], ip[9]}
+	case 48:
+		octets = []byte{ip[6], ip[7], ip[9], ip[10]}
+	case 56:
+		octets = []byte{ip[7], ip[9], ip[10], ip[11]}
+	case 64:
+		octets = ip[9:13]
+	case 96:
+		octets = ip[12:16]
+	default:
+		return nil
+	}
+	return net.IPv4(octets[0], octets[1], octets[2], octets[3])
+}
+
+// applyNAT64 records the NAT64 prefix and the embedded IPv4 address of an
+// interaction whose remote address was translated from IPv4
+func (options *Options) applyNAT64(interaction *Interaction) {
+	host, _, err := net.SplitHostPort(interaction.RemoteAddress)
+	if err != nil {
+		host = interaction.RemoteAddress
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.To4() != nil {
+		return
+	}
+	for _, networks := range [][]*net.IPNet{options.NAT64Prefixes, nat64WellKnownNetworks} {
+		for _, prefix := range networks {
+			if ipv4 := embeddedIPv4(ip, prefix); ipv4 != nil {
+				interaction.NAT64Prefix = prefix.String()
+				interaction.EmbeddedIPv4 = ipv4.String()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedIPv4(t *testing.T) {
+	// RFC 6052 section 2.4 examples of 192.0.2.33
+	examples := map[string]string{
+		"2001:db8::/32":           "2001:db8:c000:221::",
+		"2001:db8:100::/40":       "2001:db8:1c0:2:21::",
+		"2001:db8:122::/48":       "2001:db8:122:c000:2:2100::",
+		"2001:db8:122:300::/56":   "2001:db8:122:3c0:0:221::",
+		"2001:db8:122:344::/64":   "2001:db8:122:344:c0:2:2100:0",
+		"2001:db8:122:344::/96":   "2001:db8:122:344::192.0.2.33",
+		NAT64WellKnownPrefixes[0]: "64:ff9b::192.0.2.33",
+	}
+	for prefix, address := range examples {
+		networks, err := ParseNAT64Prefixes([]string{prefix})
+		require.Nil(t, err)
+		require.Equal(t, "192.0.2.33", embeddedIPv4(net.ParseIP(address), networks[0]).String(), prefix)
+	}
+
+	_, err := ParseNAT64Prefixes([]string{"2001:db8::/44"})
+	require.NotNil(t, err)
+}
+
+func TestApplyNAT64(t *testing.T) {
+	networks, err := ParseNAT64Prefixes([]string{"2001:db8:122:344::/96"})
+	require.Nil(t, err)
+	options := &Options{NAT64Prefixes: networks}
+
+	interaction := &Interaction{RemoteAddress: "[2001:db8:122:344::c000:221]:4444"}
+	options.applyNAT64(interaction)
+	require.Equal(t, "2001:db8:122:344::/96", interaction.NAT64Prefix)
+	require.Equal(t, "192.0.2.33", interaction.EmbeddedIPv4)
+
+	interaction = &Interaction{RemoteAddress: "64:ff9b::198.51.100.7"}
+	options.applyNAT64(interaction)
+	require.Equal(t, "64:ff9b::/96", interaction.NAT64Prefix)
+	require.Equal(t, "198.51.100.7", interaction.EmbeddedIPv4)
+
+	interaction = &Interaction{RemoteAddress: "192.0.2.33"}
+	options.applyNAT64(interaction)
+	require.Empty(t, interaction.NAT64Prefix)
+}
@@ -0,0 +1,29 @@
+package server
+
+import (
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// NormalizeHost returns the lowercase ascii form of a host name, internationalized
+// labels are mapped and converted to punycode so that payloads match whatever
+// the case randomization (dns 0x20) or idn handling applied by the remote host
+func NormalizeHost(host string) string {
+	if ascii, err := idna.Lookup.ToASCII(host); err == nil {
+		return strings.ToLower(ascii)
+	}
+	// names with labels invalid for lookups (eg. underscores) are only converted
+	if ascii, err := idna.Punycode.ToASCII(host); err == nil {
+		return strings.ToLower(ascii)
+	}
+	return strings.ToLower(host)
+}
+
+// originalHost returns the host name as received when it differs from its normalized form
+func originalHost(host, normalized string) string {
+	if host == normalized {
+		return ""
+	}
+	return host
+}
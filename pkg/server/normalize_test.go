@@ -0,0 +1,51 @@
+package server
+
+import (
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeHost(t *testing.T) {
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh.", NormalizeHost("C59E3crp82ke7BCNEDq0cfjqdpeyyyyyy.Interact.SH."))
+	require.Equal(t, "abc.xn--r8jz45g.jp", NormalizeHost("abc.例え.jp"))
+	require.Equal(t, "abc.xn--r8jz45g.jp", NormalizeHost("ABC.xn--R8JZ45G.jp"))
+	// fullwidth characters are mapped to their ascii form
+	require.Equal(t, "c59e.interact.sh", NormalizeHost("ｃ59e.interact.sh"))
+	require.Equal(t, "_acme-challenge.interact.sh", NormalizeHost("_ACME-challenge.interact.sh"))
+
+	require.Empty(t, originalHost("interact.sh", "interact.sh"))
+	require.Equal(t, "Interact.sh", originalHost("Interact.sh", "interact.sh"))
+}
+
+func TestSMTPMixedCase(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0")
+	store := options.Storage
+
+	server, err := NewSMTPServer(options)
+	require.Nil(t, err)
+	remote := (&noopResponseWriter{}).RemoteAddr()
+	require.Nil(t, server.defaultHandler(remote, "a@b.c", []string{"test@C59E3CRP82KE7BCNEDQ0CFJQDPEYYYYYY.interact.sh"}, []byte("Subject: test\r\n\r\ntest")))
+
+	item, err := store.GetCacheItem("c59e3crp82ke7bcnedq0")
+	require.Nil(t, err)
+	require.Len(t, item.Data, 1)
+	interaction := &Interaction{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[0]), interaction))
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy", interaction.FullId)
+	require.Equal(t, "C59E3CRP82KE7BCNEDQ0CFJQDPEYYYYYY.interact.sh", interaction.OriginalHost)
+
+	// dns 0x20 randomized names are correlated with their original form recorded
+	dnsServer := NewDNSServer("udp", options)
+	name := "C59e3CRP82ke7bcnedQ0cfjqdpeyyyyyy.interact.sh."
+	r := new(dns.Msg).SetQuestion(name, dns.TypeA)
+	dnsServer.handleInteraction(name, &noopResponseWriter{}, r, new(dns.Msg).SetReply(r))
+	item, err = store.GetCacheItem("c59e3crp82ke7bcnedq0")
+	require.Nil(t, err)
+	require.Len(t, item.Data, 2)
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[1]), interaction))
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy", interaction.UniqueID)
+	require.Equal(t, name, interaction.OriginalHost)
+}
@@ -0,0 +1,221 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/gologger"
+	stringsutil "github.com/projectdiscovery/utils/strings"
+)
+
+const (
+	// ntpPacketSize is the size of a client/server packet without extensions
+	ntpPacketSize = 48
+	// ntpEpochOffset is the number of seconds between the ntp (1900) and unix epochs
+	ntpEpochOffset = 2208988800
+)
+
+// ntp association modes
+const (
+	ntpModeClient  = 3
+	ntpModeServer  = 4
+	ntpModeControl = 6
+	ntpModePrivate = 7
+)
+
+var (
+	ntpModeNames = map[byte]string{1: "symmetric-active", 2: "symmetric-passive", 3: "client", 4: "server", 5: "broadcast", 6: "control", 7: "private"}
+	// ntpControlOpcodes are the mode 6 (ntpq) opcodes
+	ntpControlOpcodes = map[byte]string{1: "readstat", 2: "readvar", 3: "writevar", 4: "readclock", 5: "writeclock", 6: "settrap", 7: "asyncmsg", 8: "configure", 9: "saveconfig", 10: "readmru", 11: "readordlist", 12: "reqnonce", 31: "unsettrap"}
+	// ntpPrivateRequests are the mode 7 (ntpdc) request codes
+	ntpPrivateRequests = map[byte]string{0: "peer-list", 1: "peer-list-sum", 2: "peer-info", 4: "sys-info", 6: "sys-stats", 20: "monlist", 42: "monlist"}
+)
+
+// NTPServer is a ntp server answering client requests with its time, and
+// recording them with the control (mode 6) and private (mode 7) queries such
+// as readvar and monlist, which are never answered. The requests with
+// correlation ids in their data are recorded for them, the others for the
+// server token.
+type NTPServer struct {
+	options *Options
+	conn    net.PacketConn
+	mu      sync.Mutex
+}
+
+// NewNTPServer returns a new ntp server
+func NewNTPServer(options *Options) (*NTPServer, error) {
+	return &NTPServer{options: options}, nil
+}
+
+// ListenAndServe listens on the ntp port
+func (h *NTPServer) ListenAndServe(ntpAlive chan bool) {
+	conn, err := h.options.Listeners.ListenPacket("udp", net.JoinHostPort(h.options.ListenIP, fmt.Sprint(h.options.NTPPort)))
+	if err != nil {
+		gologger.Error().Msgf("Could not serve ntp on port %d: %s\n", h.options.NTPPort, err)
+		ntpAlive <- false
+		return
+	}
+	h.mu.Lock()
+	h.conn = conn
+	h.mu.Unlock()
+	ntpAlive <- true
+
+	buffer := make([]byte, udpMaxDatagram)
+	for {
+		n, addr, err := conn.ReadFrom(buffer)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				gologger.Error().Msgf("Could not read ntp request: %s\n", err)
+			}
+			return
+		}
+		received := time.Now()
+		if n == 0 {
+			continue
+		}
+		h.options.Stats.RecordSource("ntp", addr.String())
+		packet := buffer[:n]
+		h.recordInteraction(addr, packet)
+		// only client requests are answered, with a packet of the same size
+		if packet[0]&0x07 == ntpModeClient && n >= ntpPacketSize {
+			_, _ = conn.WriteTo(ntpServerPacket(packet, received), addr)
+		}
+	}
+}
+
+// ntpServerPacket returns the answer to a client request
+func ntpServerPacket(request []byte, received time.Time) []byte {
+	response := make([]byte, ntpPacketSize)
+	// no leap second warning, version of the request, server mode
+	response[0] = request[0]&0x38 | ntpModeServer
+	response[1] = 1
+	response[2] = request[2]
+	response[3] = 0xec // 2^-20 seconds precision
+	copy(response[12:16], "LOCL")
+	now := time.Now()
+	putNTPTimestamp(response[16:], now)
+	// the originate timestamp is the transmit timestamp of the request
+	copy(response[24:32], request[40:48])
+	putNTPTimestamp(response[32:], received)
+	putNTPTimestamp(response[40:], now)
+	return response
+}
+
+// putNTPTimestamp writes a 64 bits ntp timestamp
+func putNTPTimestamp(data []byte, t time.Time) {
+	binary.BigEndian.PutUint32(data, uint32(t.Unix()+ntpEpochOffset))
+	binary.BigEndian.PutUint32(data[4:], uint32((uint64(t.Nanosecond())<<32)/uint64(time.Second)))
+}
+
+// describeNTPPacket returns the mode, the request (opcode or request code) and the data of a packet
+func describeNTPPacket(packet []byte) (mode, request string, data []byte) {
+	mode = ntpModeNames[packet[0]&0x07]
+	if mode == "" {
+		mode = "reserved"
+	}
+	switch packet[0] & 0x07 {
+	case ntpModeControl:
+		if len(packet) >= 12 {
+			request = ntpControlOpcodes[packet[1]&0x1f]
+			if request == "" {
+				request = fmt.Sprintf("opcode-%d", packet[1]&0x1f)
+			}
+			count := int(binary.BigEndian.Uint16(packet[10:12]))
+			data = packet[12:min(len(packet), 12+count)]
+		}
+	case ntpModePrivate:
+		if len(packet) >= 4 {
+			request = ntpPrivateRequests[packet[3]]
+			if request == "" {
+				request = fmt.Sprintf("request-%d", packet[3])
+			}
+		}
+	}
+	return mode, request, data
+}
+
+// recordInteraction stores the packet for each correlation id of its data,
+// or for the server token when it contains none
+func (h *NTPServer) recordInteraction(remoteAddr net.Addr, packet []byte) {
+	host, _, _ := net.SplitHostPort(remoteAddr.String())
+	mode, request, data := describeNTPPacket(packet)
+	message := fmt.Sprintf("Mode=%s\nVersion=%d\n", mode, packet[0]>>3&0x07)
+	if request != "" {
+		message += fmt.Sprintf("Request=%s\n", request)
+	}
+	if len(data) > 0 {
+		message += fmt.Sprintf("Data=%s\n", data)
+	}
+	message += "\n" + hex.Dump(packet)
+	gologger.Debug().Msgf("New NTP %s %s request from %s\n", mode, request, remoteAddr)
+
+	newInteraction := func(uniqueID, fullID string) *Interaction {
+		interaction := &Interaction{
+			Protocol:        "ntp",
+			UniqueID:        uniqueID,
+			FullId:          fullID,
+			RawRequest:      message,
+			RawBytes:        packet,
+			RemoteAddress:   host,
+			Timestamp:       time.Now(),
+			ClientTimestamp: ntpClientTimestamp(packet),
+			NTPMode:         mode,
+			NTPRequest:      request,
+		}
+		h.options.applyNAT64(interaction)
+		return interaction
+	}
+
+	ids := make(map[string]string)
+	for _, chunk := range stringsutil.SplitAny(string(data), ".@/\\:;,=()`'\"<>?& \r\n\t\x00") {
+		normalized := NormalizeHost(chunk)
+		for part := range stringsutil.SlideWithLength(normalized, h.options.GetIdLength()) {
+			if _, ok := ids[part]; !ok && h.options.isCorrelationID(part) {
+				ids[part] = normalized
+			}
+		}
+	}
+	if len(ids) == 0 {
+		if h.options.GetToken() == "" {
+			return
+		}
+		h.store(newInteraction("", ""), func(data []byte) error {
+			return h.options.Storage.AddInteractionWithId(h.options.GetToken(), data)
+		})
+		return
+	}
+	for uniqueID, fullID := range ids {
+		correlationID := uniqueID[:h.options.CorrelationIdLength]
+		h.store(newInteraction(uniqueID, fullID), func(data []byte) error {
+			return h.options.Storage.AddInteraction(correlationID, data)
+		})
+	}
+}
+
+func (h *NTPServer) store(interaction *Interaction, add func(data []byte) error) {
+	buffer := &bytes.Buffer{}
+	if err := jsoniter.NewEncoder(buffer).Encode(interaction); err != nil {
+		gologger.Warning().Msgf("Could not encode ntp interaction: %s\n", err)
+		return
+	}
+	gologger.Debug().Msgf("%s\n", buffer.String())
+	if err := add(buffer.Bytes()); err != nil {
+		gologger.Warning().Msgf("Could not store ntp interaction: %s\n", err)
+	}
+}
+
+// Close stops the ntp server
+func (h *NTPServer) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.conn != nil {
+		_ = h.conn.Close()
+	}
+}
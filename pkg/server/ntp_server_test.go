@@ -0,0 +1,91 @@
+package server
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/interactsh/pkg/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNTPServer(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0", "token")
+	store := options.Storage
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+	listeners := NewListeners()
+	listeners.addPacketConn(conn)
+	options.ListenIP = "127.0.0.1"
+	options.NTPPort = conn.LocalAddr().(*net.UDPAddr).Port
+	options.Listeners = listeners
+	options.Token = "token"
+	server, err := NewNTPServer(options)
+	require.Nil(t, err)
+	alive := make(chan bool, 1)
+	go server.ListenAndServe(alive)
+	require.True(t, <-alive)
+	defer server.Close()
+
+	client, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer client.Close()
+	_ = client.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// v4 client request, answered with the transmit timestamp as originate timestamp
+	request := make([]byte, ntpPacketSize)
+	request[0] = 4<<3 | ntpModeClient
+	copy(request[40:], "\x01\x02\x03\x04\x05\x06\x07\x08")
+	_, err = client.WriteTo(request, conn.LocalAddr())
+	require.Nil(t, err)
+	buffer := make([]byte, 1024)
+	n, _, err := client.ReadFrom(buffer)
+	require.Nil(t, err)
+	require.Equal(t, ntpPacketSize, n)
+	require.Equal(t, byte(4<<3|ntpModeServer), buffer[0])
+	require.Equal(t, request[40:], buffer[24:32])
+	seconds := int64(binary.BigEndian.Uint32(buffer[40:])) - ntpEpochOffset
+	require.True(t, seconds > time.Now().Unix()-5 && seconds <= time.Now().Unix())
+
+	// readvar with the correlation id in the variable names, not answered
+	data := "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy"
+	control := []byte{2<<3 | ntpModeControl, 2, 0, 1, 0, 0, 0, 0, 0, 0, 0, byte(len(data))}
+	_, err = client.WriteTo(append(control, data...), conn.LocalAddr())
+	require.Nil(t, err)
+	// monlist
+	_, err = client.WriteTo([]byte{0x17, 0x00, 0x03, 0x2a, 0x00, 0x00, 0x00, 0x00}, conn.LocalAddr())
+	require.Nil(t, err)
+
+	var item *storage.CorrelationData
+	for i := 0; i < 50; i++ {
+		if item, err = store.GetCacheItem("token"); err == nil && len(item.Data) == 2 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	require.Len(t, item.Data, 2)
+	var requests []string
+	for _, data := range item.Data {
+		interaction := &Interaction{}
+		require.Nil(t, jsoniter.Unmarshal([]byte(data), interaction))
+		require.Equal(t, "ntp", interaction.Protocol)
+		requests = append(requests, interaction.NTPMode+"/"+interaction.NTPRequest)
+	}
+	require.ElementsMatch(t, []string{"client/", "private/monlist"}, requests)
+
+	item, err = store.GetCacheItem("c59e3crp82ke7bcnedq0")
+	require.Nil(t, err)
+	require.Len(t, item.Data, 1)
+	interaction := &Interaction{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[0]), interaction))
+	require.Equal(t, "readvar", interaction.NTPRequest)
+	require.Contains(t, interaction.RawRequest, "Data=c59e3crp82ke7bcnedq0cfjqdpeyyyyyy\n")
+
+	// no control or private response was sent
+	_ = client.SetDeadline(time.Now().Add(100 * time.Millisecond))
+	_, _, err = client.ReadFrom(buffer)
+	require.NotNil(t, err)
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// oauthCallbackPath is the path of the oauth redirect uri
+const oauthCallbackPath = "/oauth/callback"
+
+// oauthBridgePage is served on the callback path, the fragment of implicit and
+// hybrid flows never reaches the server so it is posted back by the page
+const oauthBridgePage = `<html><head><title>Signing in</title></head><body><script>
+if (location.hash.length > 1) {
+  fetch(location.pathname, {method: "POST", keepalive: true, headers: {"Content-Type": "application/x-www-form-urlencoded"}, body: "fragment=" + encodeURIComponent(location.hash.substring(1))});
+}
+</script>%s</body></html>`
+
+// oauthCallback is an authorization response received on the callback path
+type oauthCallback struct {
+	mode   string
+	params url.Values
+}
+
+// parseOAuthRequest returns the authorization response of a request to the
+// callback path, from the query, a form post or the fragment posted by the bridge
+func parseOAuthRequest(r *http.Request, body []byte) *oauthCallback {
+	if r.URL.Path != oauthCallbackPath {
+		return nil
+	}
+	callback := &oauthCallback{mode: "query", params: r.URL.Query()}
+	if r.Method == http.MethodPost && strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil
+		}
+		callback.mode, callback.params = "form_post", form
+		if fragment := form.Get("fragment"); fragment != "" && len(form) == 1 {
+			if params, err := url.ParseQuery(fragment); err == nil {
+				callback.mode, callback.params = "fragment", params
+			}
+		}
+	}
+	if len(callback.params) == 0 {
+		return nil
+	}
+	return callback
+}
+
+// marker returns the description of the authorization response appended to the raw request
+func (o *oauthCallback) marker() string {
+	if o == nil {
+		return ""
+	}
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("\n\nOAuth Callback (%s):\n", o.mode))
+	for _, name := range sortedKeys(o.params) {
+		builder.WriteString(fmt.Sprintf("%s: %s\n", name, o.params.Get(name)))
+	}
+	return builder.String()
+}
+
+// apply sets the oauth fields of the interaction
+func (o *oauthCallback) apply(interaction *Interaction) {
+	if o == nil {
+		return
+	}
+	interaction.OAuthResponseMode = o.mode
+	interaction.OAuthCode = o.params.Get("code")
+	interaction.OAuthState = o.params.Get("state")
+	interaction.OAuthParams = make(map[string]string, len(o.params))
+	for name := range o.params {
+		interaction.OAuthParams[name] = o.params.Get(name)
+	}
+}
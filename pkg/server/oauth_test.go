@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOAuthRequest(t *testing.T) {
+	callback := parseOAuthRequest(httptest.NewRequest(http.MethodGet, "/oauth/callback?code=abc&state=xyz", nil), nil)
+	require.NotNil(t, callback)
+	require.Equal(t, "query", callback.mode)
+	require.Equal(t, "\n\nOAuth Callback (query):\ncode: abc\nstate: xyz\n", callback.marker())
+
+	// response_mode=form_post
+	body := "id_token=eyJ&state=xyz"
+	request := httptest.NewRequest(http.MethodPost, "/oauth/callback", strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	callback = parseOAuthRequest(request, []byte(body))
+	require.Equal(t, "form_post", callback.mode)
+	require.Equal(t, "eyJ", callback.params.Get("id_token"))
+
+	require.Nil(t, parseOAuthRequest(httptest.NewRequest(http.MethodGet, "/oauth/callback", nil), nil))
+	require.Nil(t, parseOAuthRequest(httptest.NewRequest(http.MethodGet, "/callback?code=abc", nil), nil))
+}
+
+func TestOAuthCallbackFragment(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0")
+	store := options.Storage
+
+	server, err := NewHTTPServer(options)
+	require.Nil(t, err)
+	serve := func(request *http.Request) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		server.nontlsserver.Handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	// the bridge page posts the fragment back to the callback
+	recorder := serve(httptest.NewRequest(http.MethodGet, "http://c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh/oauth/callback", nil))
+	require.Contains(t, recorder.Body.String(), "location.hash")
+	body := "fragment=" + url.QueryEscape("access_token=t0k3n&token_type=bearer&state=xyz")
+	request := httptest.NewRequest(http.MethodPost, "http://c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh/oauth/callback", strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	serve(request)
+
+	item, err := store.GetCacheItem("c59e3crp82ke7bcnedq0")
+	require.Nil(t, err)
+	require.Len(t, item.Data, 2)
+	interaction := &Interaction{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[1]), interaction))
+	require.Equal(t, "fragment", interaction.OAuthResponseMode)
+	require.Equal(t, "xyz", interaction.OAuthState)
+	require.Equal(t, map[string]string{"access_token": "t0k3n", "token_type": "bearer", "state": "xyz"}, interaction.OAuthParams)
+}
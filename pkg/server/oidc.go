@@ -0,0 +1,178 @@
+package server
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const (
+	// oidcDiscoveryPath is the path of the openid discovery document
+	oidcDiscoveryPath = "/.well-known/openid-configuration"
+	// oauthMetadataPath is the path of the oauth authorization server metadata
+	oauthMetadataPath = "/.well-known/oauth-authorization-server"
+	// oidcJWKSPath is the path of the json web key set
+	oidcJWKSPath = "/.well-known/jwks.json"
+	// oidcX5UPath is the path of the pem certificate of the signing key, for x5u headers
+	oidcX5UPath = "/.well-known/x5u.pem"
+)
+
+// OIDCProvider serves openid discovery documents with the json web key set
+// and certificate of a signing key, for every domain and session
+type OIDCProvider struct {
+	jwk         map[string]interface{}
+	alg         string
+	certificate []byte
+}
+
+// NewOIDCProvider returns an oidc provider publishing the pem private key
+// (rsa or ecdsa) of the path, a rsa key is generated and saved to the path
+// if it doesn't exist
+func NewOIDCProvider(path, domain string) (*OIDCProvider, error) {
+	signer, err := loadOIDCKey(path)
+	if errors.Is(err, os.ErrNotExist) {
+		signer, err = generateOIDCKey(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	publicDER, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: domain},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(10, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, signer.Public(), signer)
+	if err != nil {
+		return nil, err
+	}
+	thumbprint := sha256.Sum256(certificate)
+	kid := sha256.Sum256(publicDER)
+
+	provider := &OIDCProvider{
+		jwk: map[string]interface{}{
+			"use":      "sig",
+			"kid":      base64.RawURLEncoding.EncodeToString(kid[:12]),
+			"x5c":      []string{base64.StdEncoding.EncodeToString(certificate)},
+			"x5t#S256": base64.RawURLEncoding.EncodeToString(thumbprint[:]),
+		},
+		certificate: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate}),
+	}
+	switch key := signer.Public().(type) {
+	case *rsa.PublicKey:
+		provider.alg = "RS256"
+		provider.jwk["kty"] = "RSA"
+		provider.jwk["n"] = base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+		provider.jwk["e"] = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		provider.alg = map[int]string{256: "ES256", 384: "ES384", 521: "ES512"}[key.Curve.Params().BitSize]
+		provider.jwk["kty"] = "EC"
+		provider.jwk["crv"] = key.Curve.Params().Name
+		provider.jwk["x"] = base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, size)))
+		provider.jwk["y"] = base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, size)))
+	}
+	provider.jwk["alg"] = provider.alg
+	return provider, nil
+}
+
+// loadOIDCKey reads a pkcs8, pkcs1 or sec1 pem private key
+func loadOIDCKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no pem private key in %s", path)
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		switch key := key.(type) {
+		case *rsa.PrivateKey:
+			return key, nil
+		case *ecdsa.PrivateKey:
+			return key, nil
+		}
+		return nil, fmt.Errorf("unsupported private key type %T, expected rsa or ecdsa", key)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("could not parse private key in %s", path)
+}
+
+// generateOIDCKey generates a rsa private key and saves it to the path
+func generateOIDCKey(path string) (crypto.Signer, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// serve writes the oidc document of the request path, it returns false for the other paths
+func (provider *OIDCProvider) serve(w http.ResponseWriter, req *http.Request) bool {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	// the issuer is the request host, so each session has its own
+	issuer := fmt.Sprintf("%s://%s", scheme, req.Host)
+
+	var document interface{}
+	switch req.URL.Path {
+	case oidcDiscoveryPath, oauthMetadataPath:
+		document = map[string]interface{}{
+			"issuer":                                issuer,
+			"authorization_endpoint":                issuer + "/oauth2/authorize",
+			"token_endpoint":                        issuer + "/oauth2/token",
+			"userinfo_endpoint":                     issuer + "/oauth2/userinfo",
+			"jwks_uri":                              issuer + oidcJWKSPath,
+			"response_types_supported":              []string{"code", "id_token", "token id_token"},
+			"subject_types_supported":               []string{"public"},
+			"id_token_signing_alg_values_supported": []string{provider.alg},
+			"scopes_supported":                      []string{"openid", "email", "profile"},
+		}
+	case oidcJWKSPath:
+		document = map[string]interface{}{"keys": []interface{}{provider.jwk}}
+	case oidcX5UPath:
+		w.Header().Set("Content-Type", "application/pem-certificate-chain")
+		_, _ = w.Write(provider.certificate)
+		return true
+	default:
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = jsoniter.NewEncoder(w).Encode(document)
+	return true
+}
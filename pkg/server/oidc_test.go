@@ -0,0 +1,74 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOIDCProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "oidc.pem")
+	provider, err := NewOIDCProvider(path, "interact.sh")
+	require.Nil(t, err)
+	require.Equal(t, "RS256", provider.alg)
+	// the generated key is saved and reused
+	_, err = loadOIDCKey(path)
+	require.Nil(t, err)
+	reloaded, err := NewOIDCProvider(path, "interact.sh")
+	require.Nil(t, err)
+	require.Equal(t, provider.jwk["n"], reloaded.jwk["n"])
+
+	server, err := NewHTTPServer(&Options{
+		Domains:                  []string{"interact.sh"},
+		OIDC:                     provider,
+		Stats:                    &Metrics{},
+		CorrelationIdLength:      20,
+		CorrelationIdNonceLength: 13,
+	})
+	require.Nil(t, err)
+	serve := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		server.defaultHandler(recorder, httptest.NewRequest(http.MethodGet, "http://c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh"+path, nil))
+		return recorder
+	}
+
+	var discovery map[string]interface{}
+	require.Nil(t, jsoniter.Unmarshal(serve(oidcDiscoveryPath).Body.Bytes(), &discovery))
+	require.Equal(t, "http://c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh", discovery["issuer"])
+	require.Equal(t, "http://c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh/.well-known/jwks.json", discovery["jwks_uri"])
+
+	var jwks struct {
+		Keys []map[string]interface{} `json:"keys"`
+	}
+	require.Nil(t, jsoniter.Unmarshal(serve(oidcJWKSPath).Body.Bytes(), &jwks))
+	require.Len(t, jwks.Keys, 1)
+	require.Equal(t, "RSA", jwks.Keys[0]["kty"])
+	require.Equal(t, "AQAB", jwks.Keys[0]["e"])
+
+	block, _ := pem.Decode(serve(oidcX5UPath).Body.Bytes())
+	require.NotNil(t, block)
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	require.Nil(t, err)
+	require.Equal(t, "interact.sh", certificate.Subject.CommonName)
+
+	// ecdsa keys are published as ec jwks
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	der, err := x509.MarshalECPrivateKey(key)
+	require.Nil(t, err)
+	require.Nil(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600))
+	provider, err = NewOIDCProvider(path, "interact.sh")
+	require.Nil(t, err)
+	require.Equal(t, "ES256", provider.alg)
+	require.Equal(t, "P-256", provider.jwk["crv"])
+}
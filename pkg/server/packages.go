@@ -0,0 +1,272 @@
+package server
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"hash"
+	"html"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const (
+	// packageDecoyVersion is the version offered for any package, higher than
+	// the internal versions so that the clients pick it and download it
+	packageDecoyVersion = "99.0.0"
+	// packageTimestamp is the fixed update time of the decoy packages, so that
+	// the maven metadata matches its checksums
+	packageTimestamp = "20240101000000"
+
+	npmPathPrefix   = "/npm/"
+	pypiPathPrefix  = "/simple/"
+	mavenPathPrefix = "/maven2/"
+)
+
+// pypiNameSeparators are the runs of characters replaced by a dash in normalized pypi names (PEP 503)
+var pypiNameSeparators = regexp.MustCompile(`[-_.]+`)
+
+// packageRequest is a request of a package index (npm registry, pypi simple
+// index or maven repository)
+type packageRequest struct {
+	ecosystem string
+	name      string
+	version   string
+	userAgent string
+	// download is set for the requests of package archives
+	download bool
+	// checksum is the extension of a maven checksum request (sha1 or md5)
+	checksum string
+}
+
+// isPackagePath reports whether the path is a package index path
+func isPackagePath(path string) bool {
+	return strings.HasPrefix(path, npmPathPrefix) || path+"/" == pypiPathPrefix || strings.HasPrefix(path, pypiPathPrefix) || strings.HasPrefix(path, mavenPathPrefix)
+}
+
+// parsePackageRequest returns the package and version requested from a
+// package index with the user agent of the client
+func parsePackageRequest(r *http.Request) *packageRequest {
+	var request *packageRequest
+	switch urlPath := r.URL.Path; {
+	case strings.HasPrefix(urlPath, npmPathPrefix):
+		request = parseNPMPath(strings.TrimPrefix(urlPath, npmPathPrefix))
+	case urlPath+"/" == pypiPathPrefix || strings.HasPrefix(urlPath, pypiPathPrefix):
+		request = parsePyPIPath(strings.TrimPrefix(urlPath+"/", pypiPathPrefix))
+	case strings.HasPrefix(urlPath, mavenPathPrefix):
+		request = parseMavenPath(strings.TrimPrefix(urlPath, mavenPathPrefix))
+	default:
+		return nil
+	}
+	request.userAgent = r.UserAgent()
+	return request
+}
+
+// parseNPMPath parses the packument (name), version (name/version) and tarball
+// (name/-/file.tgz) paths of the npm registry, scoped names included
+func parseNPMPath(urlPath string) *packageRequest {
+	request := &packageRequest{ecosystem: "npm"}
+	segments := strings.Split(strings.Trim(urlPath, "/"), "/")
+	length := 1
+	if strings.HasPrefix(segments[0], "@") && len(segments) > 1 {
+		length = 2
+	}
+	request.name = strings.Join(segments[:length], "/")
+	switch rest := segments[length:]; {
+	case len(rest) == 2 && rest[0] == "-":
+		request.download = true
+		request.version = strings.TrimSuffix(strings.TrimPrefix(rest[1], path.Base(request.name)+"-"), ".tgz")
+	case len(rest) == 1:
+		request.version = rest[0]
+	}
+	return request
+}
+
+// parsePyPIPath parses the index, project (name/) and archive (name/file)
+// paths of the pypi simple repository api
+func parsePyPIPath(urlPath string) *packageRequest {
+	request := &packageRequest{ecosystem: "pypi"}
+	name, file, _ := strings.Cut(strings.Trim(urlPath, "/"), "/")
+	request.name = strings.ToLower(pypiNameSeparators.ReplaceAllString(name, "-"))
+	if file == "" {
+		return request
+	}
+	request.download = true
+	if strings.HasSuffix(file, ".whl") {
+		// the dashes of the project name are escaped in wheels, the version follows it
+		if parts := strings.Split(file, "-"); len(parts) > 1 {
+			request.version = parts[1]
+		}
+		return request
+	}
+	archive := strings.TrimSuffix(strings.TrimSuffix(file, ".tar.gz"), ".zip")
+	if version, ok := strings.CutPrefix(archive, name+"-"); ok {
+		request.version = version
+	} else if index := strings.LastIndex(archive, "-"); index >= 0 {
+		request.version = archive[index+1:]
+	}
+	return request
+}
+
+// parseMavenPath parses the metadata (group/artifact/maven-metadata.xml) and
+// artifact (group/artifact/version/file) paths of a maven repository, the
+// name is the group and artifact ids joined by a colon
+func parseMavenPath(urlPath string) *packageRequest {
+	request := &packageRequest{ecosystem: "maven"}
+	segments := strings.Split(strings.Trim(urlPath, "/"), "/")
+	file := segments[len(segments)-1]
+	for _, checksum := range []string{"sha1", "md5"} {
+		if strings.HasSuffix(file, "."+checksum) {
+			request.checksum = checksum
+			file = strings.TrimSuffix(file, "."+checksum)
+		}
+	}
+	switch {
+	case file == "maven-metadata.xml" && len(segments) >= 3:
+		request.name = strings.Join(segments[:len(segments)-2], ".") + ":" + segments[len(segments)-2]
+	case len(segments) >= 4:
+		request.download = true
+		request.version = segments[len(segments)-2]
+		request.name = strings.Join(segments[:len(segments)-3], ".") + ":" + segments[len(segments)-3]
+	default:
+		request.name = strings.Join(segments, ".")
+	}
+	return request
+}
+
+// marker returns the description of the package request appended to the raw request
+func (q *packageRequest) marker() string {
+	if q == nil {
+		return ""
+	}
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("\n\nPackage Ecosystem: %s\n", q.ecosystem))
+	if q.name != "" {
+		builder.WriteString(fmt.Sprintf("Package: %s\n", q.name))
+	}
+	if q.version != "" {
+		builder.WriteString(fmt.Sprintf("Version: %s\n", q.version))
+	}
+	if q.userAgent != "" {
+		builder.WriteString(fmt.Sprintf("Client: %s\n", q.userAgent))
+	}
+	return builder.String()
+}
+
+// apply sets the package fields of the interaction
+func (q *packageRequest) apply(interaction *Interaction) {
+	if q == nil {
+		return
+	}
+	interaction.PackageEcosystem = q.ecosystem
+	interaction.PackageName = q.name
+	interaction.PackageVersion = q.version
+	interaction.PackageUserAgent = q.userAgent
+}
+
+// writePackageResponse answers a package index request with the decoy version
+// of the package, the archives themselves are not found
+func writePackageResponse(w http.ResponseWriter, r *http.Request, request *packageRequest) {
+	if request.download {
+		http.NotFound(w, r)
+		return
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	base := fmt.Sprintf("%s://%s", scheme, r.Host)
+	switch request.ecosystem {
+	case "npm":
+		writeNPMResponse(w, request, base)
+	case "pypi":
+		writePyPIResponse(w, request)
+	case "maven":
+		writeMavenResponse(w, r, request)
+	}
+}
+
+func writeNPMResponse(w http.ResponseWriter, request *packageRequest, base string) {
+	w.Header().Set("Content-Type", "application/json")
+	file := fmt.Sprintf("%s-%s.tgz", path.Base(request.name), packageDecoyVersion)
+	version := map[string]interface{}{
+		"name":    request.name,
+		"version": packageDecoyVersion,
+		"dist": map[string]interface{}{
+			"tarball": fmt.Sprintf("%s%s%s/-/%s", base, npmPathPrefix, request.name, file),
+			"shasum":  packageChecksum(sha1.New, file),
+		},
+	}
+	if request.version != "" {
+		_ = jsoniter.NewEncoder(w).Encode(version)
+		return
+	}
+	_ = jsoniter.NewEncoder(w).Encode(map[string]interface{}{
+		"name":      request.name,
+		"dist-tags": map[string]string{"latest": packageDecoyVersion},
+		"versions":  map[string]interface{}{packageDecoyVersion: version},
+	})
+}
+
+func writePyPIResponse(w http.ResponseWriter, request *packageRequest) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if request.name == "" {
+		_, _ = w.Write([]byte("<!DOCTYPE html>\n<html><head><meta name=\"pypi:repository-version\" content=\"1.0\"><title>Simple index</title></head><body></body></html>\n"))
+		return
+	}
+	name := html.EscapeString(request.name)
+	file := fmt.Sprintf("%s-%s.tar.gz", name, packageDecoyVersion)
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html><head><meta name=\"pypi:repository-version\" content=\"1.0\"><title>Links for %s</title></head><body><h1>Links for %s</h1><a href=\"%s%s/%s\">%s</a><br/></body></html>\n", name, name, pypiPathPrefix, name, file, file)
+}
+
+// mavenMetadata is the maven-metadata.xml of an artifact
+type mavenMetadata struct {
+	XMLName    xml.Name `xml:"metadata"`
+	GroupID    string   `xml:"groupId"`
+	ArtifactID string   `xml:"artifactId"`
+	Versioning struct {
+		Latest      string   `xml:"latest"`
+		Release     string   `xml:"release"`
+		Versions    []string `xml:"versions>version"`
+		LastUpdated string   `xml:"lastUpdated"`
+	} `xml:"versioning"`
+}
+
+func writeMavenResponse(w http.ResponseWriter, r *http.Request, request *packageRequest) {
+	metadata := &mavenMetadata{}
+	metadata.GroupID, metadata.ArtifactID, _ = strings.Cut(request.name, ":")
+	metadata.Versioning.Latest = packageDecoyVersion
+	metadata.Versioning.Release = packageDecoyVersion
+	metadata.Versioning.Versions = []string{packageDecoyVersion}
+	metadata.Versioning.LastUpdated = packageTimestamp
+	data, _ := xml.MarshalIndent(metadata, "", "  ")
+	data = append([]byte(xml.Header), data...)
+
+	switch request.checksum {
+	case "sha1":
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte(packageChecksum(sha1.New, string(data))))
+	case "md5":
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte(packageChecksum(md5.New, string(data))))
+	default:
+		w.Header().Set("Content-Type", "application/xml")
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		if r.Method != http.MethodHead {
+			_, _ = w.Write(data)
+		}
+	}
+}
+
+// packageChecksum returns the hex checksum of a value
+func packageChecksum(newHash func() hash.Hash, value string) string {
+	h := newHash()
+	_, _ = h.Write([]byte(value))
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"crypto/sha1"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePackageRequest(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/npm/@acme%2finternal-lib", nil)
+	request.Header.Set("User-Agent", "npm/10.2.4 node/v20.11.0 linux x64")
+	require.Equal(t, &packageRequest{ecosystem: "npm", name: "@acme/internal-lib", userAgent: "npm/10.2.4 node/v20.11.0 linux x64"}, parsePackageRequest(request))
+
+	tarball := parsePackageRequest(httptest.NewRequest(http.MethodGet, "/npm/@acme/internal-lib/-/internal-lib-99.0.0.tgz", nil))
+	require.True(t, tarball.download)
+	require.Equal(t, "99.0.0", tarball.version)
+
+	require.Equal(t, &packageRequest{ecosystem: "pypi", name: "acme-utils"}, parsePackageRequest(httptest.NewRequest(http.MethodGet, "/simple/Acme_Utils/", nil)))
+	require.Equal(t, "1.2.0", parsePackageRequest(httptest.NewRequest(http.MethodGet, "/simple/acme-utils/acme-utils-1.2.0.tar.gz", nil)).version)
+	require.Equal(t, "1.2.0", parsePackageRequest(httptest.NewRequest(http.MethodGet, "/simple/acme-utils/acme_utils-1.2.0-py3-none-any.whl", nil)).version)
+	require.Equal(t, "", parsePackageRequest(httptest.NewRequest(http.MethodGet, "/simple", nil)).name)
+
+	require.Equal(t, &packageRequest{ecosystem: "maven", name: "com.acme:core", checksum: "sha1"}, parsePackageRequest(httptest.NewRequest(http.MethodGet, "/maven2/com/acme/core/maven-metadata.xml.sha1", nil)))
+	artifact := parsePackageRequest(httptest.NewRequest(http.MethodGet, "/maven2/com/acme/core/99.0.0/core-99.0.0.jar", nil))
+	require.Equal(t, "com.acme:core", artifact.name)
+	require.Equal(t, "99.0.0", artifact.version)
+	require.True(t, artifact.download)
+
+	require.Nil(t, parsePackageRequest(httptest.NewRequest(http.MethodGet, "/simplex", nil)))
+}
+
+func TestPackageEndpoints(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0")
+	store := options.Storage
+
+	server, err := NewHTTPServer(options)
+	require.Nil(t, err)
+	serve := func(path string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, "http://interact.sh"+path, nil)
+		request.Header.Set("User-Agent", "pip/24.0")
+		recorder := httptest.NewRecorder()
+		server.nontlsserver.Handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	// packument of a package named after the correlation id
+	recorder := serve("/npm/c59e3crp82ke7bcnedq0cfjqdpeyyyyyy")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var packument struct {
+		DistTags map[string]string `json:"dist-tags"`
+		Versions map[string]struct {
+			Dist struct {
+				Tarball string `json:"tarball"`
+			} `json:"dist"`
+		} `json:"versions"`
+	}
+	require.Nil(t, jsoniter.Unmarshal(recorder.Body.Bytes(), &packument))
+	require.Equal(t, packageDecoyVersion, packument.DistTags["latest"])
+	require.Equal(t, "http://interact.sh/npm/c59e3crp82ke7bcnedq0cfjqdpeyyyyyy/-/c59e3crp82ke7bcnedq0cfjqdpeyyyyyy-99.0.0.tgz", packument.Versions[packageDecoyVersion].Dist.Tarball)
+
+	recorder = serve("/simple/acme-utils/")
+	require.Contains(t, recorder.Body.String(), `<a href="/simple/acme-utils/acme-utils-99.0.0.tar.gz">`)
+
+	recorder = serve("/maven2/com/acme/core/maven-metadata.xml")
+	require.Contains(t, recorder.Body.String(), "<release>99.0.0</release>")
+	require.Equal(t, packageChecksum(sha1.New, recorder.Body.String()), serve("/maven2/com/acme/core/maven-metadata.xml.sha1").Body.String())
+	require.Equal(t, http.StatusNotFound, serve("/maven2/com/acme/core/99.0.0/core-99.0.0.jar").Code)
+
+	item, err := store.GetCacheItem("c59e3crp82ke7bcnedq0")
+	require.Nil(t, err)
+	require.Len(t, item.Data, 1)
+	interaction := &Interaction{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[0]), interaction))
+	require.Equal(t, "npm", interaction.PackageEcosystem)
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy", interaction.PackageName)
+	require.Equal(t, "pip/24.0", interaction.PackageUserAgent)
+	require.Contains(t, interaction.RawRequest, "Package: c59e3crp82ke7bcnedq0cfjqdpeyyyyyy")
+}
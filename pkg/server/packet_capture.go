@@ -0,0 +1,411 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/gologger"
+)
+
+// CaptureSummaryInterval is the interval at which the packets captured on
+// ports without a listener are summarized as interactions
+var CaptureSummaryInterval = time.Minute
+
+const (
+	// pcapSnapLength is the maximum length of a captured packet
+	pcapSnapLength = 65535
+	// pcapLinkTypeRaw is the link type of packets starting with the ip header
+	pcapLinkTypeRaw = 101
+	// captureMaxSources is the maximum number of sources listed in a summary
+	captureMaxSources = 20
+	// captureReadTimeout is the time after which a read of the capture socket returns
+	captureReadTimeout = time.Second
+)
+
+// captureSocket is a raw socket receiving the ip packets addressed to the host
+type captureSocket interface {
+	// ReadPacket reads an incoming ip packet, returning zero bytes on timeout
+	ReadPacket(buffer []byte) (int, error)
+	Close() error
+}
+
+// droppedPacket is a tcp syn or udp datagram captured on a port
+type droppedPacket struct {
+	network string
+	source  net.IP
+	port    int
+}
+
+// droppedSummary accounts the packets captured on a port during an interval
+type droppedSummary struct {
+	network  string
+	port     int
+	packets  int
+	sources  map[string]struct{}
+	first    time.Time
+	last     time.Time
+	overflow bool
+}
+
+// PacketCapture records the tcp syns and udp datagrams received on ports
+// without a listener to a pcap file, and summarizes them periodically as
+// interactions, showing which additional listeners would be worth enabling
+type PacketCapture struct {
+	options   *Options
+	socket    captureSocket
+	listened  map[string]struct{}
+	ephemeral [2]int
+
+	mu        sync.Mutex
+	writer    io.WriteCloser
+	summaries map[string]*droppedSummary
+	done      chan struct{}
+}
+
+// NewPacketCapture opens the raw capture socket and the pcap file, rotated
+// once larger than maxSize bytes (unlimited if zero), listened are the
+// network/port pairs of the enabled services (eg. tcp/80, udp/53).
+// The socket requires CAP_NET_RAW, so it must be opened before dropping privileges.
+func NewPacketCapture(options *Options, path string, maxSize int64, listened []string) (*PacketCapture, error) {
+	socket, err := openCaptureSocket()
+	if err != nil {
+		return nil, err
+	}
+	file, err := openPcapFile(path, maxSize)
+	if err != nil {
+		_ = socket.Close()
+		return nil, err
+	}
+	capture := newPacketCapture(options, file, listened)
+	capture.socket = socket
+	return capture, nil
+}
+
+func newPacketCapture(options *Options, writer io.WriteCloser, listened []string) *PacketCapture {
+	capture := &PacketCapture{
+		options:   options,
+		listened:  make(map[string]struct{}),
+		ephemeral: ephemeralPortRange(),
+		writer:    writer,
+		summaries: make(map[string]*droppedSummary),
+		done:      make(chan struct{}),
+	}
+	for _, item := range listened {
+		capture.listened[item] = struct{}{}
+	}
+	return capture
+}
+
+// Start captures the packets and summarizes them in the background
+func (c *PacketCapture) Start() {
+	go c.capture()
+	go func() {
+		ticker := time.NewTicker(CaptureSummaryInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.summarize()
+			case <-c.done:
+				return
+			}
+		}
+	}()
+}
+
+func (c *PacketCapture) capture() {
+	defer c.socket.Close()
+
+	buffer := make([]byte, pcapSnapLength)
+	for {
+		select {
+		case <-c.done:
+			return
+		default:
+		}
+		n, err := c.socket.ReadPacket(buffer)
+		if err != nil {
+			select {
+			case <-c.done:
+			default:
+				gologger.Warning().Msgf("Could not capture packets: %s\n", err)
+			}
+			return
+		}
+		if n > 0 {
+			c.handlePacket(buffer[:n], time.Now())
+		}
+	}
+}
+
+// handlePacket records the packet if it is dropped on a port without a listener
+func (c *PacketCapture) handlePacket(packet []byte, timestamp time.Time) {
+	dropped, ok := parseDroppedPacket(packet)
+	if !ok || dropped.source.IsLoopback() {
+		return
+	}
+	if _, ok := c.listened[fmt.Sprintf("%s/%d", dropped.network, dropped.port)]; ok {
+		return
+	}
+	// windows tries smb before falling back to webdav for unc paths
+	if dropped.network == "tcp" && dropped.port == 445 {
+		c.options.UNCChains.recordSMB(dropped.source.String())
+	}
+	// replies to the outgoing udp requests of the host
+	if dropped.network == "udp" && dropped.port >= c.ephemeral[0] && dropped.port <= c.ephemeral[1] {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-c.done:
+		// the pcap file is closed
+		return
+	default:
+	}
+	if err := c.writeRecord(packet, timestamp); err != nil {
+		gologger.Warning().Msgf("Could not write captured packet: %s\n", err)
+	}
+	key := fmt.Sprintf("%s/%d", dropped.network, dropped.port)
+	summary, ok := c.summaries[key]
+	if !ok {
+		summary = &droppedSummary{network: dropped.network, port: dropped.port, sources: make(map[string]struct{}), first: timestamp}
+		c.summaries[key] = summary
+	}
+	summary.packets++
+	summary.last = timestamp
+	if len(summary.sources) < captureMaxSources {
+		summary.sources[dropped.source.String()] = struct{}{}
+	} else if _, ok := summary.sources[dropped.source.String()]; !ok {
+		summary.overflow = true
+	}
+}
+
+// summarize stores an interaction per port with packets captured since the last summary
+func (c *PacketCapture) summarize() {
+	c.mu.Lock()
+	summaries := c.summaries
+	c.summaries = make(map[string]*droppedSummary)
+	if file, ok := c.writer.(*pcapFile); ok {
+		select {
+		case <-c.done:
+		default:
+			if err := file.Flush(); err != nil {
+				gologger.Warning().Msgf("Could not write captured packets: %s\n", err)
+			}
+		}
+	}
+	c.mu.Unlock()
+
+	keys := make([]string, 0, len(summaries))
+	for key := range summaries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		summary := summaries[key]
+		sources := make([]string, 0, len(summary.sources))
+		for source := range summary.sources {
+			sources = append(sources, source)
+		}
+		sort.Strings(sources)
+		if summary.overflow {
+			sources = append(sources, "...")
+		}
+
+		var message strings.Builder
+		message.WriteString(fmt.Sprintf("Network=%s\n", summary.network))
+		message.WriteString(fmt.Sprintf("Port=%d\n", summary.port))
+		message.WriteString(fmt.Sprintf("Packets=%d\n", summary.packets))
+		message.WriteString(fmt.Sprintf("FirstSeen=%s\n", summary.first.Format(time.RFC3339)))
+		message.WriteString(fmt.Sprintf("LastSeen=%s\n", summary.last.Format(time.RFC3339)))
+		message.WriteString(fmt.Sprintf("Sources=%s\n", strings.Join(sources, ",")))
+
+		interaction := &Interaction{
+			Protocol:       "dropped",
+			RawRequest:     message.String(),
+			RemoteAddress:  sources[0],
+			Timestamp:      summary.last,
+			DroppedPort:    key,
+			DroppedPackets: summary.packets,
+		}
+		buffer := &bytes.Buffer{}
+		if err := jsoniter.NewEncoder(buffer).Encode(interaction); err != nil {
+			gologger.Warning().Msgf("Could not encode dropped packets interaction: %s\n", err)
+			continue
+		}
+		gologger.Debug().Msgf("Dropped packets: \n%s\n", buffer.String())
+		if err := c.options.Storage.AddInteractionWithId(c.options.GetToken(), buffer.Bytes()); err != nil {
+			gologger.Warning().Msgf("Could not store dropped packets interaction: %s\n", err)
+		}
+	}
+}
+
+// Close stops the capture, summarizing the pending packets
+func (c *PacketCapture) Close() {
+	c.mu.Lock()
+	close(c.done)
+	_ = c.writer.Close()
+	c.mu.Unlock()
+	c.summarize()
+}
+
+// pcapHeader returns the global header of a pcap file
+func pcapHeader() []byte {
+	header := make([]byte, 24)
+	binary.LittleEndian.PutUint32(header[0:], 0xa1b2c3d4)
+	binary.LittleEndian.PutUint16(header[4:], 2)
+	binary.LittleEndian.PutUint16(header[6:], 4)
+	binary.LittleEndian.PutUint32(header[16:], pcapSnapLength)
+	binary.LittleEndian.PutUint32(header[20:], pcapLinkTypeRaw)
+	return header
+}
+
+// writeRecord writes a packet record to the pcap file
+func (c *PacketCapture) writeRecord(packet []byte, timestamp time.Time) error {
+	record := make([]byte, 16, 16+len(packet))
+	binary.LittleEndian.PutUint32(record[0:], uint32(timestamp.Unix()))
+	binary.LittleEndian.PutUint32(record[4:], uint32(timestamp.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(record[8:], uint32(len(packet)))
+	binary.LittleEndian.PutUint32(record[12:], uint32(len(packet)))
+	_, err := c.writer.Write(append(record, packet...))
+	return err
+}
+
+// pcapFile is a buffered pcap file renamed with a .1 suffix once larger
+// than its maximum size, the previous one being replaced
+type pcapFile struct {
+	path    string
+	maxSize int64
+	file    *os.File
+	buffer  *bufio.Writer
+	size    int64
+}
+
+// openPcapFile creates a pcap file rotated once larger than maxSize bytes, unlimited if zero
+func openPcapFile(path string, maxSize int64) (*pcapFile, error) {
+	f := &pcapFile{path: path, maxSize: maxSize}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open truncates the pcap file and writes its header
+func (f *pcapFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	f.file = file
+	f.buffer = bufio.NewWriterSize(file, 64*1024)
+	f.size = 0
+	_, err = f.write(pcapHeader())
+	return err
+}
+
+func (f *pcapFile) write(p []byte) (int, error) {
+	n, err := f.buffer.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Write writes a packet record, rotating the file when full
+func (f *pcapFile) Write(p []byte) (int, error) {
+	if f.maxSize > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	return f.write(p)
+}
+
+// rotate keeps the full file with a .1 suffix and starts a new one
+func (f *pcapFile) rotate() error {
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.path, f.path+".1"); err != nil {
+		return err
+	}
+	return f.open()
+}
+
+// Flush writes the buffered records to the file
+func (f *pcapFile) Flush() error {
+	return f.buffer.Flush()
+}
+
+// Close flushes and closes the file
+func (f *pcapFile) Close() error {
+	return errors.Join(f.buffer.Flush(), f.file.Close())
+}
+
+// parseDroppedPacket returns the destination of an ip packet carrying a tcp
+// syn or an udp datagram
+func parseDroppedPacket(packet []byte) (*droppedPacket, bool) {
+	if len(packet) < 1 {
+		return nil, false
+	}
+	var (
+		protocol  byte
+		source    net.IP
+		transport []byte
+	)
+	switch packet[0] >> 4 {
+	case 4:
+		headerLength := int(packet[0]&0x0f) * 4
+		if len(packet) < 20 || headerLength < 20 || len(packet) < headerLength {
+			return nil, false
+		}
+		// only the first fragment carries the transport header
+		if binary.BigEndian.Uint16(packet[6:])&0x1fff != 0 {
+			return nil, false
+		}
+		protocol = packet[9]
+		source = net.IP(packet[12:16])
+		transport = packet[headerLength:]
+	case 6:
+		if len(packet) < 40 {
+			return nil, false
+		}
+		protocol = packet[6]
+		source = net.IP(packet[8:24])
+		transport = packet[40:]
+	default:
+		return nil, false
+	}
+
+	switch protocol {
+	case 6:
+		if len(transport) < 14 {
+			return nil, false
+		}
+		// syn without ack, the connection attempts
+		if flags := transport[13]; flags&0x02 == 0 || flags&0x10 != 0 {
+			return nil, false
+		}
+		return &droppedPacket{network: "tcp", source: source, port: int(binary.BigEndian.Uint16(transport[2:]))}, true
+	case 17:
+		if len(transport) < 8 {
+			return nil, false
+		}
+		return &droppedPacket{network: "udp", source: source, port: int(binary.BigEndian.Uint16(transport[2:]))}, true
+	}
+	return nil, false
+}
+
+// errCaptureUnsupported is returned when raw packet capture is not available
+var errCaptureUnsupported = errors.New("packet capture is only supported on linux")
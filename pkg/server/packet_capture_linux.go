@@ -0,0 +1,69 @@
+//go:build linux
+
+package server
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// packetSocket is a linux packet socket receiving the ip packets of all interfaces
+type packetSocket struct {
+	fd int
+}
+
+func openCaptureSocket() (captureSocket, error) {
+	// cooked packets start with the ip header whatever the link layer
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, int(htons(unix.ETH_P_ALL)))
+	if err != nil {
+		return nil, fmt.Errorf("could not open packet socket (CAP_NET_RAW is required): %w", err)
+	}
+	// the reads time out to notice the capture being closed
+	timeout := unix.NsecToTimeval(int64(captureReadTimeout))
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &timeout); err != nil {
+		_ = unix.Close(fd)
+		return nil, err
+	}
+	return &packetSocket{fd: fd}, nil
+}
+
+// ReadPacket reads an ip packet addressed to the host
+func (s *packetSocket) ReadPacket(buffer []byte) (int, error) {
+	n, from, err := unix.Recvfrom(s.fd, buffer, 0)
+	if err == unix.EAGAIN || err == unix.EINTR {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if link, ok := from.(*unix.SockaddrLinklayer); !ok || link.Pkttype != unix.PACKET_HOST {
+		return 0, nil
+	}
+	if n > len(buffer) {
+		n = len(buffer)
+	}
+	return n, nil
+}
+
+func (s *packetSocket) Close() error {
+	return unix.Close(s.fd)
+}
+
+// ephemeralPortRange returns the local port range of the outgoing connections
+func ephemeralPortRange() [2]int {
+	ports := [2]int{32768, 60999}
+	if data, err := os.ReadFile("/proc/sys/net/ipv4/ip_local_port_range"); err == nil {
+		var low, high int
+		if _, err := fmt.Sscan(strings.TrimSpace(string(data)), &low, &high); err == nil {
+			ports = [2]int{low, high}
+		}
+	}
+	return ports
+}
+
+func htons(value uint16) uint16 {
+	return value<<8 | value>>8
+}
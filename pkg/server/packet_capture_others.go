@@ -0,0 +1,12 @@
+//go:build !linux
+
+package server
+
+func openCaptureSocket() (captureSocket, error) {
+	return nil, errCaptureUnsupported
+}
+
+// ephemeralPortRange returns the default local port range of the outgoing connections
+func ephemeralPortRange() [2]int {
+	return [2]int{49152, 65535}
+}
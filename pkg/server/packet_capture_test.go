@@ -0,0 +1,91 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+)
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// ipv4Packet returns an ipv4 packet with a transport header to the port
+func ipv4Packet(source string, protocol byte, port uint16, flags byte) []byte {
+	packet := make([]byte, 20+20)
+	packet[0] = 0x45
+	packet[9] = protocol
+	copy(packet[12:16], net.ParseIP(source).To4())
+	copy(packet[16:20], net.ParseIP("192.0.2.1").To4())
+	binary.BigEndian.PutUint16(packet[22:], port)
+	packet[33] = flags
+	return packet
+}
+
+func TestPacketCapture(t *testing.T) {
+	options := newTestOptions(t, "token")
+	store := options.Storage
+
+	pcap := &bytes.Buffer{}
+	options.Token = "token"
+	capture := newPacketCapture(options, nopWriteCloser{pcap}, []string{"tcp/80", "udp/53"})
+	pcap.Write(pcapHeader())
+
+	now := time.Now()
+	capture.handlePacket(ipv4Packet("198.51.100.7", 6, 8080, 0x02), now)
+	capture.handlePacket(ipv4Packet("198.51.100.8", 6, 8080, 0x02), now)
+	// listened ports, syn-acks and loopback sources are not recorded
+	capture.handlePacket(ipv4Packet("198.51.100.7", 6, 80, 0x02), now)
+	capture.handlePacket(ipv4Packet("198.51.100.7", 6, 8081, 0x12), now)
+	capture.handlePacket(ipv4Packet("127.0.0.1", 6, 8082, 0x02), now)
+	capture.handlePacket(ipv4Packet("198.51.100.7", 17, 53, 0), now)
+	capture.handlePacket(ipv4Packet("198.51.100.7", 17, 161, 0), now)
+	require.Equal(t, 24+3*(16+40), pcap.Len())
+
+	capture.summarize()
+	item, err := store.GetCacheItem("token")
+	require.Nil(t, err)
+	require.Len(t, item.Data, 2)
+
+	interaction := &Interaction{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[0]), interaction))
+	require.Equal(t, "dropped", interaction.Protocol)
+	require.Equal(t, "tcp/8080", interaction.DroppedPort)
+	require.Equal(t, 2, interaction.DroppedPackets)
+	require.Contains(t, interaction.RawRequest, "Sources=198.51.100.7,198.51.100.8")
+
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[1]), interaction))
+	require.Equal(t, "udp/161", interaction.DroppedPort)
+}
+
+func TestPcapFileRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dropped.pcap")
+	file, err := openPcapFile(path, 24+2*(16+40))
+	require.Nil(t, err)
+	capture := newPacketCapture(&Options{}, file, nil)
+
+	now := time.Now()
+	for port := uint16(8080); port < 8085; port++ {
+		require.Nil(t, capture.writeRecord(ipv4Packet("198.51.100.7", 6, port, 0x02), now))
+	}
+	require.Nil(t, file.Close())
+
+	// the full file is kept with a suffix, both starting with the pcap header
+	rotated, err := os.ReadFile(path + ".1")
+	require.Nil(t, err)
+	require.Len(t, rotated, 24+2*(16+40))
+	current, err := os.ReadFile(path)
+	require.Nil(t, err)
+	require.Len(t, current, 24+16+40)
+	require.Equal(t, pcapHeader(), current[:24])
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/asaskevich/govalidator"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/interactsh/pkg/storage"
+	"golang.org/x/net/http/httpguts"
+)
+
+// MaxPayloadFileSize is the size in bytes of a hosted payload file
+var MaxPayloadFileSize = 256 * 1024
+
+var payloadFileName = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]{0,63}$`)
+
+// PayloadFileRequest is a request for hosting a file of a session under
+// /<correlation-id>/<name>, eg. a javascript for blind xss, a dtd for xxe or a
+// class file for jndi injections.
+type PayloadFileRequest struct {
+	// CorrelationID is an ID for correlation with requests.
+	CorrelationID string `json:"correlation-id"`
+	// SecretKey is the secretKey of the session owner.
+	SecretKey string `json:"secret-key"`
+	// Name is the name of the file in the served path.
+	Name string `json:"name"`
+	// ContentType is the content type served, guessed from the name if empty.
+	ContentType string `json:"content-type,omitempty"`
+	// Data is the content of the file, empty to remove it.
+	Data []byte `json:"data,omitempty"`
+}
+
+// payloadFileHandler is a handler for session payload file requests
+func (h *HTTPServer) payloadFileHandler(w http.ResponseWriter, req *http.Request) {
+	r := &PayloadFileRequest{}
+	// the data is base64 encoded in the json body
+	if err := jsoniter.NewDecoder(http.MaxBytesReader(w, req.Body, int64(2*MaxPayloadFileSize))).Decode(r); err != nil {
+		gologger.Warning().Msgf("Could not decode json body: %s\n", err)
+		jsonError(w, fmt.Sprintf("could not decode json body: %s", err), http.StatusBadRequest)
+		return
+	}
+	if !payloadFileName.MatchString(r.Name) {
+		jsonError(w, "invalid payload file name", http.StatusBadRequest)
+		return
+	}
+	if len(r.Data) > MaxPayloadFileSize {
+		jsonError(w, fmt.Sprintf("payload files are limited to %d bytes", MaxPayloadFileSize), http.StatusBadRequest)
+		return
+	}
+	if !httpguts.ValidHeaderFieldValue(r.ContentType) {
+		jsonError(w, "invalid payload file content type", http.StatusBadRequest)
+		return
+	}
+
+	var file *storage.PayloadFile
+	if len(r.Data) > 0 {
+		file = &storage.PayloadFile{ContentType: r.ContentType, Data: r.Data}
+	}
+	if err := h.options.Storage.SetPayloadFile(strings.ToLower(r.CorrelationID), r.SecretKey, r.Name, file); err != nil {
+		gologger.Warning().Msgf("Could not set payload file for id %s: %s\n", r.CorrelationID, err)
+		jsonError(w, fmt.Sprintf("could not set payload file: %s", err), http.StatusBadRequest)
+		return
+	}
+	jsonMsg(w, "payload file successful", http.StatusOK)
+	gologger.Debug().Msgf("Set payload file %s for correlationID %s\n", r.Name, r.CorrelationID)
+}
+
+// payloadFileOf returns the correlation id and the hosted file of a
+// /<correlation-id>/<name> path, if any
+func (options *Options) payloadFileOf(urlPath string) (string, *storage.PayloadFile) {
+	id, name, ok := strings.Cut(strings.TrimPrefix(urlPath, "/"), "/")
+	if !ok || len(id) != options.CorrelationIdLength || !govalidator.IsAlphanumeric(id) || options.Storage == nil {
+		return "", nil
+	}
+	id = strings.ToLower(id)
+	item, err := options.Storage.GetCacheItem(id)
+	if err != nil {
+		return "", nil
+	}
+	file, ok := item.GetPayloadFile(name)
+	if !ok {
+		return "", nil
+	}
+	return id, file
+}
+
+// writePayloadFile writes a hosted file
+func writePayloadFile(w http.ResponseWriter, name string, file *storage.PayloadFile) {
+	contentType := file.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(name))
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType(file.Data)
+	}
+	w.Header().Set("Content-Type", contentType)
+	_, _ = w.Write(file.Data)
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPayloadFiles(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0")
+	options.IPAddress = "192.0.2.1"
+	store := options.Storage
+
+	server, err := NewHTTPServer(options)
+	require.Nil(t, err)
+
+	upload := func(request *PayloadFileRequest) int {
+		data, _ := jsoniter.Marshal(request)
+		recorder := httptest.NewRecorder()
+		server.payloadFileHandler(recorder, httptest.NewRequest(http.MethodPost, "http://interact.sh/payload-file", bytes.NewReader(data)))
+		return recorder.Code
+	}
+	require.Equal(t, http.StatusOK, upload(&PayloadFileRequest{CorrelationID: "c59e3crp82ke7bcnedq0", Name: "xss.js", Data: []byte("alert(document.domain)")}))
+	require.Equal(t, http.StatusBadRequest, upload(&PayloadFileRequest{CorrelationID: "c59e3crp82ke7bcnedq0", Name: "../x.js", Data: []byte("x")}))
+	require.Equal(t, http.StatusBadRequest, upload(&PayloadFileRequest{CorrelationID: "c59e3crp82ke7bcnedq0", Name: "big.js", Data: make([]byte, MaxPayloadFileSize+1)}))
+
+	// files are served on any host and fetching them is an interaction
+	recorder := httptest.NewRecorder()
+	server.logger(http.HandlerFunc(server.defaultHandler))(recorder, httptest.NewRequest(http.MethodGet, "http://interact.sh/c59e3crp82ke7bcnedq0/xss.js", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Contains(t, recorder.Header().Get("Content-Type"), "javascript")
+	require.Equal(t, "alert(document.domain)", recorder.Body.String())
+
+	item, err := store.GetCacheItem("c59e3crp82ke7bcnedq0")
+	require.Nil(t, err)
+	require.Len(t, item.Data, 1)
+	interaction := &Interaction{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[0]), interaction))
+	require.Equal(t, "c59e3crp82ke7bcnedq0/xss.js", interaction.FullId)
+
+	require.Equal(t, http.StatusOK, upload(&PayloadFileRequest{CorrelationID: "c59e3crp82ke7bcnedq0", Name: "xss.js"}))
+	recorder = httptest.NewRecorder()
+	server.defaultHandler(recorder, httptest.NewRequest(http.MethodGet, "http://interact.sh/c59e3crp82ke7bcnedq0/xss.js", nil))
+	require.NotContains(t, recorder.Body.String(), "alert")
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// Personality mimics the fingerprint of a well known http server, as some
+// SSRF validators check the response before following through
+type Personality struct {
+	// Server is the value of the Server header
+	Server string
+	// Headers are additional headers sent with every response
+	Headers map[string]string
+	// Index is the default page served on the root path
+	Index string
+	// NotFound is the error page served for unknown paths, %s is replaced
+	// with the url reflection kept in a comment
+	NotFound string
+}
+
+// Personalities are the available http server personalities
+var Personalities = map[string]*Personality{
+	"nginx": {
+		Server: "nginx/1.24.0",
+		Index: `<!DOCTYPE html>
+<html>
+<head>
+<title>Welcome to nginx!</title>
+</head>
+<body>
+<h1>Welcome to nginx!</h1>
+<p>If you see this page, the nginx web server is successfully installed and
+working. Further configuration is required.</p>
+<p><em>Thank you for using nginx.</em></p>
+</body>
+</html>
+`,
+		NotFound: `<html>
+<head><title>404 Not Found</title></head>
+<body>
+<center><h1>404 Not Found</h1></center>
+<hr><center>nginx/1.24.0</center>
+</body>
+</html>
+<!-- %s -->
+`,
+	},
+	"apache": {
+		Server: "Apache/2.4.57 (Unix)",
+		Index:  "<html><body><h1>It works!</h1></body></html>\n",
+		NotFound: `<!DOCTYPE HTML PUBLIC "-//IETF//DTD HTML 2.0//EN">
+<html><head>
+<title>404 Not Found</title>
+</head><body>
+<h1>Not Found</h1>
+<p>The requested URL was not found on this server.</p>
+</body></html>
+<!-- %s -->
+`,
+	},
+	"iis": {
+		Server:  "Microsoft-IIS/10.0",
+		Headers: map[string]string{"X-Powered-By": "ASP.NET"},
+		Index: `<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Strict//EN" "http://www.w3.org/TR/xhtml1/DTD/xhtml1-strict.dtd">
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head>
+<meta http-equiv="Content-Type" content="text/html; charset=iso-8859-1" />
+<title>IIS Windows Server</title>
+</head>
+<body>
+<div id="container">
+<a href="http://go.microsoft.com/fwlink/?linkid=66138&amp;clcid=0x409"><img src="iisstart.png" alt="IIS" width="960" height="600" /></a>
+</div>
+</body>
+</html>
+`,
+		NotFound: `<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Strict//EN" "http://www.w3.org/TR/xhtml1/DTD/xhtml1-strict.dtd">
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head>
+<meta http-equiv="Content-Type" content="text/html; charset=iso-8859-1"/>
+<title>404 - File or directory not found.</title>
+</head>
+<body>
+<div id="header"><h1>Server Error</h1></div>
+<div id="content">
+ <div class="content-container"><fieldset>
+  <h2>404 - File or directory not found.</h2>
+  <h3>The resource you are looking for might have been removed, had its name changed, or is temporarily unavailable.</h3>
+ </fieldset></div>
+</div>
+</body>
+</html>
+<!-- %s -->
+`,
+	},
+}
+
+// personalities holds the personality of each served domain
+type personalities struct {
+	fallback *Personality
+	domains  map[string]*Personality
+}
+
+// parsePersonalities parses personality entries in the personality or domain=personality format
+func parsePersonalities(entries []string) (*personalities, error) {
+	result := &personalities{domains: make(map[string]*Personality)}
+	for _, entry := range entries {
+		domain, name, found := strings.Cut(entry, "=")
+		if !found {
+			domain, name = "", entry
+		}
+		personality, ok := Personalities[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			names := make([]string, 0, len(Personalities))
+			for name := range Personalities {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("unknown http personality %s, valid values are %s", name, strings.Join(names, ","))
+		}
+		if domain == "" {
+			result.fallback = personality
+		} else {
+			result.domains[strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")] = personality
+		}
+	}
+	return result, nil
+}
+
+// get returns the personality of the longest domain the host belongs to,
+// ignoring its port, or the fallback one
+func (p *personalities) get(host string) *Personality {
+	if p == nil {
+		return nil
+	}
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+
+	personality, matched := p.fallback, ""
+	for domain, domainPersonality := range p.domains {
+		if len(domain) <= len(matched) {
+			continue
+		}
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			personality, matched = domainPersonality, domain
+		}
+	}
+	return personality
+}
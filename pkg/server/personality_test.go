@@ -0,0 +1,56 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPPersonality(t *testing.T) {
+	_, err := parsePersonalities([]string{"tomcat"})
+	require.NotNil(t, err)
+
+	options := &Options{
+		Domains:                  []string{"interact.sh", "oast.pro"},
+		HTTPPersonalities:        []string{"nginx", "oast.pro=iis"},
+		Stats:                    &Metrics{},
+		Version:                  "1.0.0",
+		CorrelationIdLength:      20,
+		CorrelationIdNonceLength: 13,
+	}
+	server, err := NewHTTPServer(options)
+	require.Nil(t, err)
+
+	w := httptest.NewRecorder()
+	server.defaultHandler(w, httptest.NewRequest("GET", "http://interact.sh/", nil))
+	require.Equal(t, "nginx/1.24.0", w.Header().Get("Server"))
+	require.Empty(t, w.Header().Get("X-Interactsh-Version"))
+	require.Contains(t, w.Body.String(), "Welcome to nginx!")
+
+	w = httptest.NewRecorder()
+	server.defaultHandler(w, httptest.NewRequest("GET", "http://c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.oast.pro/missing", nil))
+	resp := w.Result()
+	body, _ := io.ReadAll(resp.Body)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	require.Equal(t, "Microsoft-IIS/10.0", resp.Header.Get("Server"))
+	require.Equal(t, "ASP.NET", resp.Header.Get("X-Powered-By"))
+	require.Contains(t, string(body), "<!-- yyyyyyepdqjfc0qdencb7ek28prc3e95c -->")
+}
+
+func TestPersonalitiesMatch(t *testing.T) {
+	personalities, err := parsePersonalities([]string{"nginx", "oast.pro=iis", "eu.oast.pro=apache"})
+	require.Nil(t, err)
+
+	// the longest domain wins and the port is ignored
+	for i := 0; i < 10; i++ {
+		require.Equal(t, Personalities["apache"], personalities.get("c59e3crp82ke7bcnedq0.eu.oast.pro:8080"))
+	}
+	require.Equal(t, Personalities["iis"], personalities.get("OAST.PRO:443"))
+	require.Equal(t, Personalities["iis"], personalities.get("x.oast.pro."))
+	// domains match on a label boundary
+	require.Equal(t, Personalities["nginx"], personalities.get("toast.pro"))
+	require.Equal(t, Personalities["nginx"], personalities.get("[::1]:80"))
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/projectdiscovery/gologger"
+)
+
+// POP3Server is a pop3 server rejecting every login, the USER/PASS commands
+// of users containing correlation ids are recorded with the credentials and
+// the commands sent by the client
+type POP3Server struct {
+	mailServer
+}
+
+// NewPOP3Server returns a new pop3 server
+func NewPOP3Server(options *Options) (*POP3Server, error) {
+	return &POP3Server{mailServer: mailServer{options: options}}, nil
+}
+
+// ListenAndServe listens on the pop3 port, and on the pop3s port when a tls
+// configuration is available
+func (h *POP3Server) ListenAndServe(tlsConfig *tls.Config, pop3Alive, pop3sAlive chan bool) {
+	if tlsConfig != nil {
+		tlsConfig = h.options.listenerTLSConfig("pop3", tlsConfig)
+	}
+	go func() {
+		if tlsConfig == nil {
+			return
+		}
+		handle := func(conn net.Conn) { h.handleConn(conn, tlsConfig, true) }
+		if err := h.serve(h.options.POP3SPort, pop3sAlive, handle); err != nil {
+			gologger.Error().Msgf("Could not serve pop3 with tls on port %d: %s\n", h.options.POP3SPort, err)
+			pop3sAlive <- false
+		}
+	}()
+	handle := func(conn net.Conn) { h.handleConn(conn, tlsConfig, false) }
+	if err := h.serve(h.options.POP3Port, pop3Alive, handle); err != nil {
+		gologger.Error().Msgf("Could not serve pop3 on port %d: %s\n", h.options.POP3Port, err)
+		pop3Alive <- false
+	}
+}
+
+func (h *POP3Server) handleConn(conn net.Conn, tlsConfig *tls.Config, implicitTLS bool) {
+	defer conn.Close()
+
+	h.options.Stats.RecordSource("pop3", conn.RemoteAddr().String())
+	session := newMailSession(h.options, "pop3", conn)
+	if implicitTLS {
+		if err := session.startTLS(tlsConfig); err != nil {
+			gologger.Debug().Msgf("Pop3 tls handshake from %s failed: %s\n", conn.RemoteAddr(), err)
+			return
+		}
+	}
+	if err := session.write("+OK POP3 server ready\r\n"); err != nil {
+		return
+	}
+
+	var user string
+	for {
+		line, err := session.readLine()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				gologger.Debug().Msgf("Pop3 connection from %s closed: %s\n", session.conn.RemoteAddr(), err)
+			}
+			return
+		}
+		command, argument, _ := strings.Cut(line, " ")
+		var reply string
+		switch strings.ToUpper(command) {
+		case "CAPA":
+			reply = "+OK Capability list follows\r\nUSER\r\n"
+			if tlsConfig != nil && !session.isTLS() {
+				reply += "STLS\r\n"
+			}
+			reply += ".\r\n"
+		case "NOOP":
+			reply = "+OK\r\n"
+		case "STLS":
+			if tlsConfig == nil || session.isTLS() {
+				reply = "-ERR STLS not available\r\n"
+				break
+			}
+			if err := session.write("+OK Begin TLS negotiation\r\n"); err != nil {
+				return
+			}
+			if err := session.startTLS(tlsConfig); err != nil {
+				gologger.Debug().Msgf("Pop3 stls from %s failed: %s\n", conn.RemoteAddr(), err)
+				return
+			}
+			continue
+		case "USER":
+			user = argument
+			reply = "+OK\r\n"
+		case "PASS":
+			if user == "" {
+				reply = "-ERR USER first\r\n"
+				break
+			}
+			session.recordLogin(user, argument)
+			user = ""
+			reply = "-ERR [AUTH] Authentication failed\r\n"
+		case "QUIT":
+			_ = session.write("+OK Bye\r\n")
+			return
+		default:
+			reply = "-ERR Unknown command\r\n"
+		}
+		if err := session.write(reply); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,53 @@
+//go:build unix
+
+package server
+
+import (
+	"errors"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// DropPrivileges switches the process to the user and its primary group, or
+// the given group, from a user[:group] specification. It must be called by root
+// after binding the privileged ports.
+func DropPrivileges(spec string) error {
+	if os.Geteuid() != 0 {
+		return errors.New("dropping privileges requires running as root")
+	}
+	name, groupName, _ := strings.Cut(spec, ":")
+	account, err := user.Lookup(name)
+	if err != nil {
+		return err
+	}
+	gidValue := account.Gid
+	if groupName != "" {
+		group, err := user.LookupGroup(groupName)
+		if err != nil {
+			return err
+		}
+		gidValue = group.Gid
+	}
+	uid, err := strconv.Atoi(account.Uid)
+	if err != nil {
+		return err
+	}
+	gid, err := strconv.Atoi(gidValue)
+	if err != nil {
+		return err
+	}
+	if uid == 0 {
+		return errors.New("can't drop privileges to root")
+	}
+	// supplementary groups of root are dropped before the group and the user
+	if err := syscall.Setgroups([]int{gid}); err != nil {
+		return err
+	}
+	if err := syscall.Setgid(gid); err != nil {
+		return err
+	}
+	return syscall.Setuid(uid)
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package server
+
+import "errors"
+
+// DropPrivileges is not supported on this platform
+func DropPrivileges(spec string) error {
+	return errors.New("dropping privileges is not supported on this platform")
+}
@@ -0,0 +1,293 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/projectdiscovery/gologger"
+)
+
+// aliveBuffer is the number of states a server can report on an alive
+// channel without a reader, so that it never blocks once it is closed
+const aliveBuffer = 4
+
+// ListenerStatus is a change of state of a listener of a protocol server
+type ListenerStatus struct {
+	// Service is the name of the service listening (eg. HTTPS)
+	Service string
+	// Network is the network of the listener (eg. TCP)
+	Network string
+	// Port is the port of the listener, zero for raw sockets
+	Port int
+	// Alive is true when the listener starts and false when it stops
+	Alive bool
+	// Fatal reports that the interactsh server can't run without the listener
+	Fatal bool
+}
+
+// ReportListener reports the change of state of a listener to the status
+// callback of the options, protocol servers call it for each of their listeners
+func (options *Options) ReportListener(status ListenerStatus) {
+	if options.OnListenerStatus != nil {
+		options.OnListenerStatus(status)
+	}
+}
+
+// ProtocolServer is a protocol listener started and stopped uniformly by the
+// interactsh server. Custom protocol servers are compiled in by registering
+// their factory with RegisterProtocol.
+type ProtocolServer interface {
+	// Name returns the name the protocol is registered with
+	Name() string
+	// ListenAndServe serves until the context is cancelled or the server is
+	// closed, reporting its listeners with Options.ReportListener
+	ListenAndServe(ctx context.Context) error
+	// Close stops the server
+	Close()
+}
+
+// GracefulServer is a protocol server draining its open connections when it is
+// shut down, the other protocol servers are closed
+type GracefulServer interface {
+	// Shutdown stops accepting connections and waits for the open ones to
+	// finish until the context is done
+	Shutdown(ctx context.Context) error
+}
+
+// ProtocolFactory creates a protocol server from the server options and the
+// tls configuration, which is nil when no certificate is available
+type ProtocolFactory func(options *Options, tlsConfig *tls.Config) (ProtocolServer, error)
+
+var (
+	protocolsMu sync.RWMutex
+	protocols   = make(map[string]ProtocolFactory)
+)
+
+// RegisterProtocol registers the factory of a protocol server, usually from
+// the init function of the package implementing it. It panics if the name is
+// already registered or the factory is nil.
+func RegisterProtocol(name string, factory ProtocolFactory) {
+	protocolsMu.Lock()
+	defer protocolsMu.Unlock()
+	if factory == nil {
+		panic("server: nil factory registered for protocol " + name)
+	}
+	if _, ok := protocols[name]; ok {
+		panic("server: protocol registered twice: " + name)
+	}
+	protocols[name] = factory
+}
+
+// Protocols returns the sorted names of the registered protocols
+func Protocols() []string {
+	protocolsMu.RLock()
+	defer protocolsMu.RUnlock()
+	names := make([]string, 0, len(protocols))
+	for name := range protocols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewProtocolServer creates the server of a registered protocol
+func NewProtocolServer(name string, options *Options, tlsConfig *tls.Config) (ProtocolServer, error) {
+	protocolsMu.RLock()
+	factory, ok := protocols[name]
+	protocolsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown protocol %s (registered: %v)", name, Protocols())
+	}
+	return factory(options, tlsConfig)
+}
+
+// runningProtocol is a protocol server serving in its own goroutine
+type runningProtocol struct {
+	server ProtocolServer
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// ProtocolServers are the protocol servers started by the interactsh server,
+// which are stopped and reloaded by name
+type ProtocolServers struct {
+	options *Options
+	mu      sync.Mutex
+	running map[string]*runningProtocol
+	order   []string
+}
+
+// NewProtocolServers returns an empty set of protocol servers
+func NewProtocolServers(options *Options) *ProtocolServers {
+	return &ProtocolServers{options: options, running: make(map[string]*runningProtocol)}
+}
+
+// Start creates and serves the registered protocols, the protocols already
+// running are left untouched
+func (p *ProtocolServers) Start(tlsConfig *tls.Config, names ...string) error {
+	for _, name := range names {
+		p.mu.Lock()
+		_, running := p.running[name]
+		p.mu.Unlock()
+		if running {
+			continue
+		}
+		server, err := NewProtocolServer(name, p.options, tlsConfig)
+		if err != nil {
+			return fmt.Errorf("could not create %s server: %w", name, err)
+		}
+		p.serve(name, server)
+	}
+	return nil
+}
+
+// serve runs the server until it stops or is stopped by name
+func (p *ProtocolServers) serve(name string, server ProtocolServer) {
+	ctx, cancel := context.WithCancel(context.Background())
+	protocol := &runningProtocol{server: server, cancel: cancel, done: make(chan struct{})}
+	p.mu.Lock()
+	p.running[name] = protocol
+	p.order = append(p.order, name)
+	p.mu.Unlock()
+
+	go func() {
+		defer close(protocol.done)
+		if err := server.ListenAndServe(ctx); err != nil {
+			gologger.Warning().Msgf("The %s server has stopped: %s\n", name, err)
+		}
+	}()
+}
+
+// Get returns the running server of a protocol
+func (p *ProtocolServers) Get(name string) (ProtocolServer, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	protocol, ok := p.running[name]
+	if !ok {
+		return nil, false
+	}
+	return protocol.server, true
+}
+
+// Stop closes the running server of a protocol
+func (p *ProtocolServers) Stop(name string) {
+	if protocol := p.remove(name); protocol != nil {
+		protocol.cancel()
+		<-protocol.done
+	}
+}
+
+// Shutdown stops the running protocol servers in the reverse order they
+// started, the graceful servers drain their connections until the context is
+// done and the others are closed
+func (p *ProtocolServers) Shutdown(ctx context.Context) {
+	p.mu.Lock()
+	names := append([]string(nil), p.order...)
+	p.mu.Unlock()
+	for i := len(names) - 1; i >= 0; i-- {
+		protocol := p.remove(names[i])
+		if protocol == nil {
+			continue
+		}
+		if graceful, ok := protocol.server.(GracefulServer); ok {
+			if err := graceful.Shutdown(ctx); err != nil {
+				gologger.Warning().Msgf("Could not gracefully stop the %s server: %s\n", names[i], err)
+			}
+		}
+		protocol.cancel()
+		select {
+		case <-protocol.done:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// remove returns the running server of a protocol, which is no longer running
+func (p *ProtocolServers) remove(name string) *runningProtocol {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	protocol, ok := p.running[name]
+	if !ok {
+		return nil
+	}
+	delete(p.running, name)
+	for i, running := range p.order {
+		if running == name {
+			p.order = append(p.order[:i:i], p.order[i+1:]...)
+			break
+		}
+	}
+	return protocol
+}
+
+// Reload restarts the running protocol servers with a tls configuration, for
+// instance after renewing the certificates. Their ports are bound again, which
+// requires the privileges to bind them unless the sockets were kept.
+func (p *ProtocolServers) Reload(tlsConfig *tls.Config) error {
+	p.mu.Lock()
+	names := append([]string(nil), p.order...)
+	p.mu.Unlock()
+	for _, name := range names {
+		p.Stop(name)
+	}
+	return p.Start(tlsConfig, names...)
+}
+
+// Close stops the running protocol servers in the reverse order they started
+func (p *ProtocolServers) Close() {
+	p.mu.Lock()
+	names := append([]string(nil), p.order...)
+	p.mu.Unlock()
+	for i := len(names) - 1; i >= 0; i-- {
+		p.Stop(names[i])
+	}
+}
+
+// serveWithAlive adapts the servers reporting their listeners on alive
+// channels, with the listener of each channel in order. The statuses are
+// reported until the context is cancelled, then the server is closed.
+func serveWithAlive(ctx context.Context, options *Options, listeners []ListenerStatus, serve func(alive []chan bool) error, closeServer func()) error {
+	alive := make([]chan bool, len(listeners))
+	cases := make([]reflect.SelectCase, 0, len(listeners)+2)
+	for i := range alive {
+		alive[i] = make(chan bool, aliveBuffer)
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(alive[i])})
+	}
+	done := make(chan error, 1)
+	cases = append(cases,
+		reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+		reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(done)},
+	)
+	report := func(i int, up bool) {
+		status := listeners[i]
+		status.Alive = up
+		options.ReportListener(status)
+	}
+
+	go func() {
+		done <- serve(alive)
+	}()
+	for {
+		chosen, value, _ := reflect.Select(cases)
+		switch chosen {
+		case len(listeners):
+			closeServer()
+			return nil
+		case len(listeners) + 1:
+			// the states sent before the server returned are still reported
+			for i := range alive {
+				for len(alive[i]) > 0 {
+					report(i, <-alive[i])
+				}
+			}
+			err, _ := value.Interface().(error)
+			return err
+		default:
+			report(chosen, value.Bool())
+		}
+	}
+}
@@ -0,0 +1,507 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+)
+
+func init() {
+	RegisterProtocol("dns", newDNSProtocol)
+	RegisterProtocol("dot", newDoTProtocol)
+	RegisterProtocol("http", newHTTPProtocol)
+	RegisterProtocol("smtp", newSMTPProtocol)
+	RegisterProtocol("ldap", newLDAPProtocol)
+	RegisterProtocol("ftp", newFTPProtocol)
+	RegisterProtocol("smb", newSMBProtocol)
+	RegisterProtocol("responder", newResponderProtocol)
+	RegisterProtocol("echo", newEchoProtocol)
+	RegisterProtocol("ssh", newSSHProtocol)
+	RegisterProtocol("mysql", newMySQLProtocol)
+	RegisterProtocol("redis", newRedisProtocol)
+	RegisterProtocol("imap", newIMAPProtocol)
+	RegisterProtocol("pop3", newPOP3Protocol)
+	RegisterProtocol("tftp", newTFTPProtocol)
+	RegisterProtocol("snmp", newSNMPProtocol)
+	RegisterProtocol("ntp", newNTPProtocol)
+	RegisterProtocol("sip", newSIPProtocol)
+	RegisterProtocol("icmp", newICMPProtocol)
+	RegisterProtocol("grpc", newGRPCProtocol)
+	RegisterProtocol("tcp", newTCPProtocol)
+	RegisterProtocol("udp", newUDPProtocol)
+}
+
+// aliveProtocol adapts the servers reporting their listeners on alive
+// channels, with the listener of each channel in order
+type aliveProtocol struct {
+	name      string
+	options   *Options
+	listeners []ListenerStatus
+	serve     func(alive []chan bool) error
+	close     func()
+	// shutdown drains the open connections, the server is closed otherwise
+	shutdown func(ctx context.Context) error
+}
+
+func (p *aliveProtocol) Name() string { return p.name }
+
+func (p *aliveProtocol) ListenAndServe(ctx context.Context) error {
+	return serveWithAlive(ctx, p.options, p.listeners, p.serve, p.close)
+}
+
+func (p *aliveProtocol) Close() { p.close() }
+
+func (p *aliveProtocol) Shutdown(ctx context.Context) error {
+	if p.shutdown == nil {
+		p.close()
+		return nil
+	}
+	return p.shutdown(ctx)
+}
+
+func newDNSProtocol(options *Options, _ *tls.Config) (ProtocolServer, error) {
+	udp, tcp := NewDNSServer("udp", options), NewDNSServer("tcp", options)
+	return &aliveProtocol{
+		name:    "dns",
+		options: options,
+		listeners: []ListenerStatus{
+			{Service: "DNS", Network: "UDP", Port: options.DnsPort, Fatal: true},
+			{Service: "DNS", Network: "TCP", Port: options.DnsPort},
+		},
+		serve: func(alive []chan bool) error {
+			go tcp.ListenAndServe(alive[1])
+			udp.ListenAndServe(alive[0])
+			return nil
+		},
+		close: func() {
+			udp.Close()
+			tcp.Close()
+		},
+		shutdown: func(ctx context.Context) error {
+			return errors.Join(udp.Shutdown(ctx), tcp.Shutdown(ctx))
+		},
+	}, nil
+}
+
+func newDoTProtocol(options *Options, tlsConfig *tls.Config) (ProtocolServer, error) {
+	if tlsConfig == nil {
+		return nil, errors.New("dns over tls requires a certificate")
+	}
+	server := NewDoTServer(options, tlsConfig)
+	return &aliveProtocol{
+		name:      "dot",
+		options:   options,
+		listeners: []ListenerStatus{{Service: "DoT", Network: "TCP", Port: options.DoTPort}},
+		serve: func(alive []chan bool) error {
+			server.ListenAndServe(alive[0])
+			return nil
+		},
+		close:    server.Close,
+		shutdown: server.Shutdown,
+	}, nil
+}
+
+// httpProtocol serves http and https, the http server methods such as
+// ServeAdminSocket are available on the protocol server
+type httpProtocol struct {
+	*HTTPServer
+	protocol *aliveProtocol
+}
+
+func newHTTPProtocol(options *Options, tlsConfig *tls.Config) (ProtocolServer, error) {
+	server, err := NewHTTPServer(options)
+	if err != nil {
+		return nil, err
+	}
+	return &httpProtocol{HTTPServer: server, protocol: &aliveProtocol{
+		name:    "http",
+		options: options,
+		listeners: []ListenerStatus{
+			{Service: "HTTP", Network: "TCP", Port: options.HttpPort, Fatal: true},
+			{Service: "HTTPS", Network: "TCP", Port: options.HttpsPort},
+		},
+		serve: func(alive []chan bool) error {
+			server.ListenAndServe(tlsConfig, alive[0], alive[1])
+			return nil
+		},
+		close:    server.Close,
+		shutdown: server.Shutdown,
+	}}, nil
+}
+
+func (p *httpProtocol) Name() string { return p.protocol.Name() }
+
+func (p *httpProtocol) ListenAndServe(ctx context.Context) error {
+	return p.protocol.ListenAndServe(ctx)
+}
+
+func (p *httpProtocol) Close() { p.protocol.Close() }
+
+func (p *httpProtocol) Shutdown(ctx context.Context) error {
+	return p.protocol.Shutdown(ctx)
+}
+
+func newSMTPProtocol(options *Options, tlsConfig *tls.Config) (ProtocolServer, error) {
+	server, err := NewSMTPServer(options)
+	if err != nil {
+		return nil, err
+	}
+	return &aliveProtocol{
+		name:    "smtp",
+		options: options,
+		listeners: []ListenerStatus{
+			{Service: "SMTP", Network: "TCP", Port: options.SmtpPort},
+			{Service: "SMTPS", Network: "TCP", Port: options.SmtpsPort},
+		},
+		serve: func(alive []chan bool) error {
+			server.ListenAndServe(tlsConfig, alive[0], alive[1])
+			return nil
+		},
+		close: server.Close,
+	}, nil
+}
+
+func newLDAPProtocol(options *Options, tlsConfig *tls.Config) (ProtocolServer, error) {
+	server, err := NewLDAPServer(options, options.LDAPFullLogger)
+	if err != nil {
+		return nil, err
+	}
+	return &aliveProtocol{
+		name:      "ldap",
+		options:   options,
+		listeners: []ListenerStatus{{Service: "LDAP", Network: "TCP", Port: options.LdapPort}},
+		serve: func(alive []chan bool) error {
+			server.ListenAndServe(tlsConfig, alive[0])
+			return nil
+		},
+		close: func() {
+			_ = server.Close()
+		},
+		shutdown: server.Shutdown,
+	}, nil
+}
+
+func newFTPProtocol(options *Options, tlsConfig *tls.Config) (ProtocolServer, error) {
+	server, err := NewFTPServer(options)
+	if err != nil {
+		return nil, err
+	}
+	return &aliveProtocol{
+		name:    "ftp",
+		options: options,
+		listeners: []ListenerStatus{
+			{Service: "FTP", Network: "TCP", Port: options.FtpPort},
+			{Service: "FTPS", Network: "TCP", Port: options.FtpsPort},
+		},
+		serve: func(alive []chan bool) error {
+			server.ListenAndServe(tlsConfig, alive[0], alive[1])
+			return nil
+		},
+		close: server.Close,
+	}, nil
+}
+
+func newSMBProtocol(options *Options, _ *tls.Config) (ProtocolServer, error) {
+	server, err := NewSMBServer(options)
+	if err != nil {
+		return nil, err
+	}
+	return &aliveProtocol{
+		name:      "smb",
+		options:   options,
+		listeners: []ListenerStatus{{Service: "SMB", Network: "TCP", Port: options.SmbPort}},
+		serve: func(alive []chan bool) error {
+			return server.ListenAndServe(alive[0])
+		},
+		close: server.Close,
+	}, nil
+}
+
+func newResponderProtocol(options *Options, _ *tls.Config) (ProtocolServer, error) {
+	server, err := NewResponderServer(options)
+	if err != nil {
+		return nil, err
+	}
+	return &aliveProtocol{
+		name:      "responder",
+		options:   options,
+		listeners: []ListenerStatus{{Service: "Responder", Network: "TCP", Port: 445}},
+		serve: func(alive []chan bool) error {
+			return server.ListenAndServe(alive[0])
+		},
+		close: server.Close,
+	}, nil
+}
+
+func newEchoProtocol(options *Options, _ *tls.Config) (ProtocolServer, error) {
+	tcp, err := NewEchoServer("tcp", options.EchoTCPPort, options)
+	if err != nil {
+		return nil, err
+	}
+	udp, err := NewEchoServer("udp", options.EchoUDPPort, options)
+	if err != nil {
+		return nil, err
+	}
+	return &aliveProtocol{
+		name:    "echo",
+		options: options,
+		listeners: []ListenerStatus{
+			{Service: "Echo", Network: "TCP", Port: options.EchoTCPPort},
+			{Service: "Echo", Network: "UDP", Port: options.EchoUDPPort},
+		},
+		serve: func(alive []chan bool) error {
+			go udp.ListenAndServe(alive[1])
+			tcp.ListenAndServe(alive[0])
+			return nil
+		},
+		close: func() {
+			tcp.Close()
+			udp.Close()
+		},
+	}, nil
+}
+
+func newSSHProtocol(options *Options, _ *tls.Config) (ProtocolServer, error) {
+	server, err := NewSSHServer(options)
+	if err != nil {
+		return nil, err
+	}
+	return &aliveProtocol{
+		name:      "ssh",
+		options:   options,
+		listeners: []ListenerStatus{{Service: "SSH", Network: "TCP", Port: options.SshPort}},
+		serve: func(alive []chan bool) error {
+			server.ListenAndServe(alive[0])
+			return nil
+		},
+		close: server.Close,
+	}, nil
+}
+
+func newMySQLProtocol(options *Options, _ *tls.Config) (ProtocolServer, error) {
+	server, err := NewMySQLServer(options)
+	if err != nil {
+		return nil, err
+	}
+	return &aliveProtocol{
+		name:      "mysql",
+		options:   options,
+		listeners: []ListenerStatus{{Service: "MySQL", Network: "TCP", Port: options.MysqlPort}},
+		serve: func(alive []chan bool) error {
+			server.ListenAndServe(alive[0])
+			return nil
+		},
+		close: server.Close,
+	}, nil
+}
+
+func newRedisProtocol(options *Options, tlsConfig *tls.Config) (ProtocolServer, error) {
+	server, err := NewRedisServer(options)
+	if err != nil {
+		return nil, err
+	}
+	return &aliveProtocol{
+		name:    "redis",
+		options: options,
+		listeners: []ListenerStatus{
+			{Service: "Redis", Network: "TCP", Port: options.RedisPort},
+			{Service: "Redis", Network: "TLS", Port: options.RedisTLSPort},
+		},
+		serve: func(alive []chan bool) error {
+			server.ListenAndServe(tlsConfig, alive[0], alive[1])
+			return nil
+		},
+		close: server.Close,
+	}, nil
+}
+
+func newIMAPProtocol(options *Options, tlsConfig *tls.Config) (ProtocolServer, error) {
+	server, err := NewIMAPServer(options)
+	if err != nil {
+		return nil, err
+	}
+	return &aliveProtocol{
+		name:    "imap",
+		options: options,
+		listeners: []ListenerStatus{
+			{Service: "IMAP", Network: "TCP", Port: options.IMAPPort},
+			{Service: "IMAP", Network: "TLS", Port: options.IMAPSPort},
+		},
+		serve: func(alive []chan bool) error {
+			server.ListenAndServe(tlsConfig, alive[0], alive[1])
+			return nil
+		},
+		close: server.Close,
+	}, nil
+}
+
+func newPOP3Protocol(options *Options, tlsConfig *tls.Config) (ProtocolServer, error) {
+	server, err := NewPOP3Server(options)
+	if err != nil {
+		return nil, err
+	}
+	return &aliveProtocol{
+		name:    "pop3",
+		options: options,
+		listeners: []ListenerStatus{
+			{Service: "POP3", Network: "TCP", Port: options.POP3Port},
+			{Service: "POP3", Network: "TLS", Port: options.POP3SPort},
+		},
+		serve: func(alive []chan bool) error {
+			server.ListenAndServe(tlsConfig, alive[0], alive[1])
+			return nil
+		},
+		close: server.Close,
+	}, nil
+}
+
+func newTFTPProtocol(options *Options, _ *tls.Config) (ProtocolServer, error) {
+	server, err := NewTFTPServer(options)
+	if err != nil {
+		return nil, err
+	}
+	return &aliveProtocol{
+		name:      "tftp",
+		options:   options,
+		listeners: []ListenerStatus{{Service: "TFTP", Network: "UDP", Port: options.TFTPPort}},
+		serve: func(alive []chan bool) error {
+			server.ListenAndServe(alive[0])
+			return nil
+		},
+		close: server.Close,
+	}, nil
+}
+
+func newSNMPProtocol(options *Options, _ *tls.Config) (ProtocolServer, error) {
+	server, err := NewSNMPServer(options)
+	if err != nil {
+		return nil, err
+	}
+	return &aliveProtocol{
+		name:    "snmp",
+		options: options,
+		listeners: []ListenerStatus{
+			{Service: "SNMP", Network: "UDP", Port: options.SNMPPort},
+			{Service: "SNMP Trap", Network: "UDP", Port: options.SNMPTrapPort},
+		},
+		serve: func(alive []chan bool) error {
+			server.ListenAndServe(alive[0], alive[1])
+			return nil
+		},
+		close: server.Close,
+	}, nil
+}
+
+func newNTPProtocol(options *Options, _ *tls.Config) (ProtocolServer, error) {
+	server, err := NewNTPServer(options)
+	if err != nil {
+		return nil, err
+	}
+	return &aliveProtocol{
+		name:      "ntp",
+		options:   options,
+		listeners: []ListenerStatus{{Service: "NTP", Network: "UDP", Port: options.NTPPort}},
+		serve: func(alive []chan bool) error {
+			server.ListenAndServe(alive[0])
+			return nil
+		},
+		close: server.Close,
+	}, nil
+}
+
+func newSIPProtocol(options *Options, _ *tls.Config) (ProtocolServer, error) {
+	server, err := NewSIPServer(options)
+	if err != nil {
+		return nil, err
+	}
+	return &aliveProtocol{
+		name:    "sip",
+		options: options,
+		listeners: []ListenerStatus{
+			{Service: "SIP", Network: "UDP", Port: options.SIPPort},
+			{Service: "SIP", Network: "TCP", Port: options.SIPPort},
+		},
+		serve: func(alive []chan bool) error {
+			server.ListenAndServe(alive[0], alive[1])
+			return nil
+		},
+		close: server.Close,
+	}, nil
+}
+
+// newICMPProtocol opens the raw icmp sockets, so the icmp protocol is started
+// before dropping privileges
+func newICMPProtocol(options *Options, _ *tls.Config) (ProtocolServer, error) {
+	server, err := NewICMPServer(options)
+	if err != nil {
+		return nil, err
+	}
+	return &aliveProtocol{
+		name:      "icmp",
+		options:   options,
+		listeners: []ListenerStatus{{Service: "ICMP", Network: "RAW"}},
+		serve: func(alive []chan bool) error {
+			server.ListenAndServe(alive[0])
+			return nil
+		},
+		close: server.Close,
+	}, nil
+}
+
+func newGRPCProtocol(options *Options, tlsConfig *tls.Config) (ProtocolServer, error) {
+	server, err := NewGRPCServer(options)
+	if err != nil {
+		return nil, err
+	}
+	return &aliveProtocol{
+		name:      "grpc",
+		options:   options,
+		listeners: []ListenerStatus{{Service: "gRPC", Network: "TCP", Port: options.GRPCPort}},
+		serve: func(alive []chan bool) error {
+			server.ListenAndServe(tlsConfig, alive[0])
+			return nil
+		},
+		close: server.Close,
+	}, nil
+}
+
+func newTCPProtocol(options *Options, tlsConfig *tls.Config) (ProtocolServer, error) {
+	if len(options.TCPPorts) == 0 {
+		return nil, errors.New("no tcp catch-all ports")
+	}
+	server, err := NewTCPServer(options)
+	if err != nil {
+		return nil, err
+	}
+	return &aliveProtocol{
+		name:      "tcp",
+		options:   options,
+		listeners: []ListenerStatus{{Service: fmt.Sprintf("TCP Catch-All (%d ports)", len(options.TCPPorts)), Network: "TCP", Port: options.TCPPorts[0]}},
+		serve: func(alive []chan bool) error {
+			server.ListenAndServe(tlsConfig, alive[0])
+			return nil
+		},
+		close: server.Close,
+	}, nil
+}
+
+func newUDPProtocol(options *Options, _ *tls.Config) (ProtocolServer, error) {
+	if len(options.UDPPorts) == 0 {
+		return nil, errors.New("no udp catch-all ports")
+	}
+	server, err := NewUDPServer(options)
+	if err != nil {
+		return nil, err
+	}
+	return &aliveProtocol{
+		name:      "udp",
+		options:   options,
+		listeners: []ListenerStatus{{Service: fmt.Sprintf("UDP Catch-All (%d ports)", len(options.UDPPorts)), Network: "UDP", Port: options.UDPPorts[0]}},
+		serve: func(alive []chan bool) error {
+			server.ListenAndServe(alive[0])
+			return nil
+		},
+		close: server.Close,
+	}, nil
+}
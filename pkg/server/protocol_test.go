@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testProtocol is a custom protocol server reporting a listener on an alive channel
+type testProtocol struct {
+	options *Options
+	stop    chan struct{}
+}
+
+func (p *testProtocol) Name() string { return "test" }
+
+func (p *testProtocol) ListenAndServe(ctx context.Context) error {
+	listeners := []ListenerStatus{{Service: "Test", Network: "TCP", Port: 4242}}
+	return serveWithAlive(ctx, p.options, listeners, func(alive []chan bool) error {
+		alive[0] <- true
+		<-p.stop
+		return nil
+	}, p.Close)
+}
+
+func (p *testProtocol) Close() {
+	close(p.stop)
+}
+
+func init() {
+	RegisterProtocol("test", func(options *Options, _ *tls.Config) (ProtocolServer, error) {
+		return &testProtocol{options: options, stop: make(chan struct{})}, nil
+	})
+}
+
+func TestProtocolServers(t *testing.T) {
+	require.Contains(t, Protocols(), "test")
+	require.Contains(t, Protocols(), "http")
+
+	statuses := make(chan ListenerStatus, 4)
+	options := &Options{OnListenerStatus: func(status ListenerStatus) { statuses <- status }}
+	servers := NewProtocolServers(options)
+	require.NotNil(t, servers.Start(nil, "unknown"))
+
+	require.Nil(t, servers.Start(nil, "test"))
+	require.Equal(t, ListenerStatus{Service: "Test", Network: "TCP", Port: 4242, Alive: true}, <-statuses)
+	server, ok := servers.Get("test")
+	require.True(t, ok)
+	require.Equal(t, "test", server.Name())
+
+	// reloading creates a new server
+	require.Nil(t, servers.Reload(nil))
+	require.True(t, (<-statuses).Alive)
+	reloaded, ok := servers.Get("test")
+	require.True(t, ok)
+	require.True(t, server != reloaded)
+
+	servers.Close()
+	_, ok = servers.Get("test")
+	require.False(t, ok)
+}
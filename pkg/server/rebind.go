@@ -0,0 +1,195 @@
+package server
+
+import (
+	"encoding/hex"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Rebinding strategies encoded in the names, a-<ip>-b-<ip>-<strategy>.<id>.domain
+const (
+	// RebindRoundRobin alternates the addresses at each lookup
+	RebindRoundRobin = "rr"
+	// RebindFlip answers the first address to the first lookup and the second one afterwards
+	RebindFlip = "flip"
+	// RebindTime answers the first address during a window after the first
+	// lookup (time30 for 30 seconds) and the second one afterwards
+	RebindTime = "time"
+)
+
+var (
+	// RebindTimeWindow is the window of the time strategy without duration
+	RebindTimeWindow = 10 * time.Second
+	// RebindStateTTL is how long the lookups of a rebinding name are remembered
+	RebindStateTTL = time.Hour
+	// maxRebindStates is the number of rebinding names remembered before the stale ones are dropped
+	maxRebindStates = 100000
+)
+
+var rebindPattern = regexp.MustCompile(`^a-(.+)-b-(.+)-(rr|flip|time(\d*))$`)
+
+// rebind is a rebinding setup encoded in the labels preceding a unique id
+type rebind struct {
+	first, second net.IP
+	strategy      string
+	window        time.Duration
+}
+
+// parseRebind parses the labels preceding a unique id, the addresses being
+// ipv4 addresses or ipv6 ones as 32 hex digits (eg. a-198.51.100.7-b-127.0.0.1-rr)
+func parseRebind(prefix string) (*rebind, bool) {
+	match := rebindPattern.FindStringSubmatch(strings.ToLower(prefix))
+	if match == nil {
+		return nil, false
+	}
+	r := &rebind{first: parseRebindIP(match[1]), second: parseRebindIP(match[2]), strategy: match[3]}
+	if r.first == nil || r.second == nil {
+		return nil, false
+	}
+	if strings.HasPrefix(r.strategy, RebindTime) {
+		r.strategy = RebindTime
+		r.window = RebindTimeWindow
+		if match[4] != "" {
+			seconds, err := strconv.Atoi(match[4])
+			if err != nil {
+				return nil, false
+			}
+			r.window = time.Duration(seconds) * time.Second
+		}
+	}
+	return r, true
+}
+
+func parseRebindIP(value string) net.IP {
+	if len(value) == 32 {
+		if data, err := hex.DecodeString(value); err == nil {
+			return net.IP(data)
+		}
+	}
+	if ip := net.ParseIP(value); ip != nil && ip.To4() != nil {
+		return ip
+	}
+	return nil
+}
+
+// answer returns the address of a lookup given the previous lookups of the
+// name and the time of the first one
+func (r *rebind) answer(lookups int, first, now time.Time) net.IP {
+	switch r.strategy {
+	case RebindRoundRobin:
+		if lookups%2 == 1 {
+			return r.second
+		}
+	case RebindFlip:
+		if lookups > 0 {
+			return r.second
+		}
+	case RebindTime:
+		if lookups > 0 && now.Sub(first) >= r.window {
+			return r.second
+		}
+	}
+	return r.first
+}
+
+// rebindState counts the lookups of the rebinding names across the dns servers
+type rebindState struct {
+	mu      sync.Mutex
+	lookups map[string]*rebindLookups
+}
+
+type rebindLookups struct {
+	count       int
+	first, last time.Time
+}
+
+// next returns the number of previous lookups of a name and the time of the
+// first one, accounting the current lookup
+func (s *rebindState) next(key string, now time.Time) (int, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lookups == nil {
+		s.lookups = make(map[string]*rebindLookups)
+	}
+	entry, ok := s.lookups[key]
+	if !ok || now.Sub(entry.last) > RebindStateTTL {
+		if len(s.lookups) >= maxRebindStates {
+			for name, stale := range s.lookups {
+				if now.Sub(stale.last) > RebindStateTTL {
+					delete(s.lookups, name)
+				}
+			}
+		}
+		entry = &rebindLookups{first: now}
+		s.lookups[key] = entry
+	}
+	count := entry.count
+	entry.count++
+	entry.last = now
+	return count, entry.first
+}
+
+// rebindOf returns the rebinding setup of a name, if any
+func (options *Options) rebindOf(zone string) (*rebind, bool) {
+	parts := strings.Split(strings.ToLower(strings.TrimSuffix(zone, ".")), ".")
+	for i, part := range parts {
+		if i > 0 && options.isCorrelationID(part) {
+			return parseRebind(strings.Join(parts[:i], "."))
+		}
+	}
+	return nil, false
+}
+
+// rebindAnswer returns the address answered to an address lookup of a
+// rebinding name, and whether the name is a rebinding one. The address is
+// nil when its family differs from the queried one.
+func (h *DNSServer) rebindAnswer(zone string, qtype uint16) (net.IP, bool) {
+	if qtype != dns.TypeA && qtype != dns.TypeAAAA {
+		return nil, false
+	}
+	r, ok := h.options.rebindOf(zone)
+	if !ok {
+		return nil, false
+	}
+	// the lookups of each family are counted apart, as resolvers send both
+	now := time.Now()
+	lookups, first := h.options.rebinds.next(strings.ToLower(dns.Fqdn(zone))+"/"+dns.TypeToString[qtype], now)
+	return r.answer(lookups, first, now), true
+}
+
+// handleRebind answers a rebinding address with a zero ttl, so that the next
+// lookups reach the server
+func (h *DNSServer) handleRebind(zone string, qtype uint16, address net.IP, m *dns.Msg) {
+	if address == nil {
+		return
+	}
+	if record := h.addressRecord(zone, qtype, address); record != nil {
+		record.Header().Ttl = 0
+		m.Answer = append(m.Answer, record)
+	}
+}
+
+// applyRebind annotates the interaction of a rebinding name with the
+// strategy and the address answered
+func applyRebind(interaction *Interaction, labels []string, m *dns.Msg) {
+	r, ok := parseRebind(strings.Join(labels, "."))
+	if !ok {
+		return
+	}
+	interaction.RebindStrategy = r.strategy
+	for _, record := range m.Answer {
+		switch record := record.(type) {
+		case *dns.A:
+			interaction.RebindAnswer = record.A.String()
+		case *dns.AAAA:
+			interaction.RebindAnswer = record.AAAA.String()
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRebind(t *testing.T) {
+	r, ok := parseRebind("a-198.51.100.7-b-127.0.0.1-rr")
+	require.True(t, ok)
+	require.Equal(t, "198.51.100.7", r.first.String())
+	require.Equal(t, "127.0.0.1", r.second.String())
+	require.Equal(t, RebindRoundRobin, r.strategy)
+
+	r, ok = parseRebind("a-198.51.100.7-b-00000000000000000000000000000001-time30")
+	require.True(t, ok)
+	require.Equal(t, "::1", r.second.String())
+	require.Equal(t, RebindTime, r.strategy)
+	require.Equal(t, 30*time.Second, r.window)
+
+	now := time.Now()
+	require.Equal(t, "198.51.100.7", r.answer(0, now, now).String())
+	require.Equal(t, "198.51.100.7", r.answer(3, now.Add(-10*time.Second), now).String())
+	require.Equal(t, "::1", r.answer(3, now.Add(-30*time.Second), now).String())
+
+	for _, prefix := range []string{"rebind1", "a-198.51.100.7-b-127.0.0.1", "a-198.51.100.7-b-localhost-rr", "a-198.51.100.7-b-127.0.0.1-slow"} {
+		_, ok := parseRebind(prefix)
+		require.False(t, ok, prefix)
+	}
+}
+
+func TestDNSRebind(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0")
+	options.IPAddress = "192.0.2.1"
+	store := options.Storage
+
+	server := NewDNSServer("udp", options)
+	exchange := func(name string, qtype uint16) *dns.Msg {
+		writer := &dohResponseWriter{remote: &net.UDPAddr{IP: net.ParseIP("198.51.100.1"), Port: 53}}
+		server.ServeDNS(writer, new(dns.Msg).SetQuestion(name, qtype))
+		return writer.msg
+	}
+	answers := func(name string, lookups int) []string {
+		var addresses []string
+		for i := 0; i < lookups; i++ {
+			response := exchange(name, dns.TypeA)
+			require.Len(t, response.Answer, 1)
+			require.EqualValues(t, 0, response.Answer[0].Header().Ttl)
+			addresses = append(addresses, response.Answer[0].(*dns.A).A.String())
+		}
+		return addresses
+	}
+
+	name := "a-198.51.100.7-b-127.0.0.1-rr.c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh."
+	require.Equal(t, []string{"198.51.100.7", "127.0.0.1", "198.51.100.7"}, answers(name, 3))
+	// aaaa lookups don't advance the a lookups
+	require.Empty(t, exchange(name, dns.TypeAAAA).Answer)
+	require.Equal(t, []string{"127.0.0.1"}, answers(name, 1))
+
+	name = "a-198.51.100.7-b-127.0.0.1-flip.c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh."
+	require.Equal(t, []string{"198.51.100.7", "127.0.0.1", "127.0.0.1"}, answers(name, 3))
+
+	item, err := store.GetCacheItem("c59e3crp82ke7bcnedq0")
+	require.Nil(t, err)
+	require.Len(t, item.Data, 8)
+	interaction := &Interaction{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[6]), interaction))
+	require.Equal(t, RebindFlip, interaction.RebindStrategy)
+	require.Equal(t, "127.0.0.1", interaction.RebindAnswer)
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// redirectPath is the path of the open redirect to the to parameter
+	redirectPath = "/redirect"
+	// redirectChainPrefix is the path prefix of the /r/<n> redirect chains
+	redirectChainPrefix = "/r/"
+)
+
+// redirectStatuses are the statuses a redirect can be issued with
+var redirectStatuses = map[int]bool{
+	http.StatusMovedPermanently:  true,
+	http.StatusFound:             true,
+	http.StatusSeeOther:          true,
+	http.StatusTemporaryRedirect: true,
+	http.StatusPermanentRedirect: true,
+}
+
+// redirectRequest is a hop of a redirect chain, /r/<n> redirecting to /r/<n-1>
+// on the same host until /r/0 redirects to the to parameter, if any
+type redirectRequest struct {
+	hop      int
+	status   int
+	location string
+}
+
+// redirectOf returns the redirect of a request to the redirect endpoints of
+// a payload host, nil if the endpoints are disabled or the request isn't one
+func (options *Options) redirectOf(r *http.Request) *redirectRequest {
+	if !options.Redirect || (r.URL.Path != redirectPath && !strings.HasPrefix(r.URL.Path, redirectChainPrefix)) {
+		return nil
+	}
+	// the endpoints aren't an open redirect for the bare domains
+	if options.sessionOf(r.Host) == "" {
+		return nil
+	}
+	values := r.URL.Query()
+	request := &redirectRequest{status: http.StatusFound, location: values.Get("to")}
+	if status, err := strconv.Atoi(values.Get("status")); err == nil && redirectStatuses[status] {
+		request.status = status
+	}
+	if r.URL.Path == redirectPath {
+		if request.location == "" {
+			return nil
+		}
+		return request
+	}
+
+	hop, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, redirectChainPrefix))
+	if err != nil || hop < 0 {
+		return nil
+	}
+	request.hop = min(hop, options.RedirectMaxHops)
+	if request.hop > 0 {
+		next := &url.URL{Path: fmt.Sprintf("%s%d", redirectChainPrefix, request.hop-1), RawQuery: r.URL.RawQuery}
+		request.location = next.String()
+	}
+	return request
+}
+
+// write issues the redirect, the end of a chain without target being answered
+func (q *redirectRequest) write(w http.ResponseWriter, reflection string) {
+	if q.location == "" {
+		fmt.Fprintf(w, "<html><head></head><body>%s</body></html>", reflection)
+		return
+	}
+	// the location is set as is, http.Redirect would clean the path of relative targets
+	w.Header().Set("Location", q.location)
+	w.WriteHeader(q.status)
+}
+
+// marker describes the redirect issued in the raw request
+func (q *redirectRequest) marker() string {
+	if q == nil || q.location == "" {
+		return ""
+	}
+	return fmt.Sprintf("\n\nRedirect: %d to %s\n", q.status, q.location)
+}
+
+// apply sets the redirect fields of the interaction
+func (q *redirectRequest) apply(interaction *Interaction) {
+	if q == nil {
+		return
+	}
+	interaction.RedirectHop = q.hop
+	interaction.RedirectLocation = q.location
+}
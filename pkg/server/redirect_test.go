@@ -0,0 +1,59 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedirect(t *testing.T) {
+	options := &Options{
+		Domains:                  []string{"interact.sh"},
+		IPAddress:                "192.0.2.1",
+		Stats:                    &Metrics{},
+		CorrelationIdLength:      20,
+		CorrelationIdNonceLength: 13,
+		Redirect:                 true,
+		RedirectMaxHops:          5,
+	}
+	server, err := NewHTTPServer(options)
+	require.Nil(t, err)
+	const host = "http://c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh"
+
+	get := func(url string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		server.defaultHandler(recorder, httptest.NewRequest(http.MethodGet, url, nil))
+		return recorder
+	}
+
+	recorder := get(host + "/redirect?to=http://169.254.169.254/latest/meta-data/&status=307")
+	require.Equal(t, http.StatusTemporaryRedirect, recorder.Code)
+	require.Equal(t, "http://169.254.169.254/latest/meta-data/", recorder.Header().Get("Location"))
+
+	// chains keep the query until the last hop redirects to the target
+	recorder = get(host + "/r/2?to=gopher://127.0.0.1:6379/_INFO")
+	require.Equal(t, http.StatusFound, recorder.Code)
+	require.Equal(t, "/r/1?to=gopher://127.0.0.1:6379/_INFO", recorder.Header().Get("Location"))
+	recorder = get(host + "/r/0?to=gopher://127.0.0.1:6379/_INFO")
+	require.Equal(t, "gopher://127.0.0.1:6379/_INFO", recorder.Header().Get("Location"))
+	recorder = get(host + "/r/0")
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	// chains are capped to the maximum hops
+	require.Equal(t, "/r/4", get(host+"/r/1000").Header().Get("Location"))
+
+	// the bare domain isn't an open redirect
+	require.Nil(t, options.redirectOf(httptest.NewRequest(http.MethodGet, "http://interact.sh/redirect?to=http://example.com", nil)))
+	options.Redirect = false
+	require.Nil(t, options.redirectOf(httptest.NewRequest(http.MethodGet, host+"/redirect?to=http://example.com", nil)))
+
+	interaction := &Interaction{}
+	options.Redirect = true
+	redirect := options.redirectOf(httptest.NewRequest(http.MethodGet, host+"/r/3", nil))
+	redirect.apply(interaction)
+	require.Equal(t, 3, interaction.RedirectHop)
+	require.Equal(t, "/r/2", interaction.RedirectLocation)
+	require.Contains(t, redirect.marker(), "Redirect: 302 to /r/2")
+}
@@ -0,0 +1,286 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/gologger"
+	stringsutil "github.com/projectdiscovery/utils/strings"
+)
+
+const (
+	// RedisServerVersion is the version reported by the redis server
+	RedisServerVersion = "6.2.6"
+	// redisTimeout is the idle time after which a redis connection is closed
+	redisTimeout = 30 * time.Second
+	// redisMaxArguments and redisMaxBulk bound the size of a redis command
+	redisMaxArguments = 1024
+	redisMaxBulk      = 1 << 20
+)
+
+// redisReplies are the benign replies of the redis commands, the other
+// commands are answered with OK
+var redisReplies = map[string]string{
+	"PING":     "+PONG\r\n",
+	"GET":      "$-1\r\n",
+	"KEYS":     "*0\r\n",
+	"SCAN":     "*2\r\n$1\r\n0\r\n*0\r\n",
+	"DBSIZE":   ":0\r\n",
+	"EXISTS":   ":0\r\n",
+	"DEL":      ":0\r\n",
+	"TTL":      ":-2\r\n",
+	"TYPE":     "+none\r\n",
+	"LPUSH":    ":1\r\n",
+	"RPUSH":    ":1\r\n",
+	"PUBLISH":  ":0\r\n",
+	"COMMAND":  "*0\r\n",
+	"CLIENT":   "+OK\r\n",
+	"CONFIG":   "+OK\r\n",
+	"SELECT":   "+OK\r\n",
+	"FLUSHALL": "+OK\r\n",
+}
+
+// RedisServer is a redis server speaking RESP which accepts any command with
+// a benign reply and records the command stream of the connections, correlated
+// by the tls server name, the AUTH credentials or the command arguments
+type RedisServer struct {
+	options   *Options
+	listeners []net.Listener
+	mu        sync.Mutex
+}
+
+// NewRedisServer returns a new redis server
+func NewRedisServer(options *Options) (*RedisServer, error) {
+	return &RedisServer{options: options}, nil
+}
+
+// ListenAndServe listens on the redis port, and on the redis tls port when
+// a tls configuration is available
+func (h *RedisServer) ListenAndServe(tlsConfig *tls.Config, redisAlive, redissAlive chan bool) {
+	go func() {
+		if tlsConfig == nil {
+			return
+		}
+		if err := h.serve(h.options.RedisTLSPort, h.options.listenerTLSConfig("redis", tlsConfig), redissAlive); err != nil {
+			gologger.Error().Msgf("Could not serve redis with tls on port %d: %s\n", h.options.RedisTLSPort, err)
+			redissAlive <- false
+		}
+	}()
+	if err := h.serve(h.options.RedisPort, nil, redisAlive); err != nil {
+		gologger.Error().Msgf("Could not serve redis on port %d: %s\n", h.options.RedisPort, err)
+		redisAlive <- false
+	}
+}
+
+func (h *RedisServer) serve(port int, tlsConfig *tls.Config, alive chan bool) error {
+	listener, err := h.options.Listeners.Listen("tcp", net.JoinHostPort(h.options.ListenIP, fmt.Sprint(port)))
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	h.listeners = append(h.listeners, listener)
+	h.mu.Unlock()
+	alive <- true
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go h.handleConn(conn, tlsConfig)
+	}
+}
+
+func (h *RedisServer) handleConn(conn net.Conn, tlsConfig *tls.Config) {
+	defer conn.Close()
+
+	h.options.Stats.RecordSource("redis", conn.RemoteAddr().String())
+	var serverName string
+	if tlsConfig != nil {
+		tlsConn := tls.Server(conn, tlsConfig)
+		_ = tlsConn.SetDeadline(time.Now().Add(redisTimeout))
+		if err := tlsConn.Handshake(); err != nil {
+			gologger.Debug().Msgf("Redis tls handshake from %s failed: %s\n", conn.RemoteAddr(), err)
+			return
+		}
+		serverName = tlsConn.ConnectionState().ServerName
+		conn = tlsConn
+	}
+
+	capture := newBodyCapture(h.options.captureLimit())
+	// ids are the correlation ids of the connection with their full id
+	ids := make(map[string]string)
+	h.correlate(ids, serverName)
+	reader := bufio.NewReader(conn)
+	for {
+		_ = conn.SetDeadline(time.Now().Add(redisTimeout))
+		args, err := readRedisCommand(reader)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				gologger.Debug().Msgf("Redis connection from %s closed: %s\n", conn.RemoteAddr(), err)
+			}
+			break
+		}
+		if len(args) == 0 {
+			continue
+		}
+		_, _ = capture.Write([]byte(formatRedisCommand(args) + "\n"))
+		h.correlate(ids, args...)
+
+		command := strings.ToUpper(args[0])
+		reply, ok := redisReplies[command]
+		switch {
+		case command == "ECHO" && len(args) > 1:
+			reply = fmt.Sprintf("$%d\r\n%s\r\n", len(args[1]), args[1])
+		case command == "INFO":
+			info := fmt.Sprintf("# Server\r\nredis_version:%s\r\nredis_mode:standalone\r\nos:Linux 5.15.0-91-generic x86_64\r\ntcp_port:%d\r\n", RedisServerVersion, h.options.RedisPort)
+			reply = fmt.Sprintf("$%d\r\n%s\r\n", len(info), info)
+		case !ok:
+			reply = "+OK\r\n"
+		}
+		if _, err := conn.Write([]byte(reply)); err != nil || command == "QUIT" {
+			break
+		}
+	}
+	if capture.size == 0 {
+		return
+	}
+
+	var message strings.Builder
+	if serverName != "" {
+		message.WriteString(fmt.Sprintf("ServerName=%s\n\n", serverName))
+	}
+	message.Write(capture.Bytes())
+	message.WriteString(capture.marker())
+	h.recordInteraction(conn.RemoteAddr(), serverName, message.String(), capture, ids)
+}
+
+// correlate adds the correlation ids found in the values to the ids of a connection
+func (h *RedisServer) correlate(ids map[string]string, values ...string) {
+	for _, value := range values {
+		for _, chunk := range stringsutil.SplitAny(value, ".@/\\:;,=()`'\" \r\n\t") {
+			normalized := NormalizeHost(chunk)
+			for part := range stringsutil.SlideWithLength(normalized, h.options.GetIdLength()) {
+				if _, ok := ids[part]; !ok && h.options.isCorrelationID(part) {
+					ids[part] = normalized
+				}
+			}
+		}
+	}
+}
+
+// recordInteraction stores the command stream for each correlation id of the connection
+func (h *RedisServer) recordInteraction(remoteAddr net.Addr, serverName, stream string, capture *bodyCapture, ids map[string]string) {
+	host, _, _ := net.SplitHostPort(remoteAddr.String())
+	for uniqueID, fullID := range ids {
+		interaction := &Interaction{
+			Protocol:      "redis",
+			UniqueID:      uniqueID,
+			FullId:        fullID,
+			RawRequest:    stream,
+			RemoteAddress: host,
+			Timestamp:     time.Now(),
+			TLSServerName: serverName,
+		}
+		capture.apply(interaction)
+		h.options.applyNAT64(interaction)
+		buffer := &bytes.Buffer{}
+		if err := jsoniter.NewEncoder(buffer).Encode(interaction); err != nil {
+			gologger.Warning().Msgf("Could not encode redis interaction: %s\n", err)
+			continue
+		}
+		gologger.Debug().Msgf("%s\n", buffer.String())
+		if err := h.options.Storage.AddInteraction(uniqueID[:h.options.CorrelationIdLength], buffer.Bytes()); err != nil {
+			gologger.Warning().Msgf("Could not store redis interaction: %s\n", err)
+		}
+	}
+}
+
+// Close stops the redis server
+func (h *RedisServer) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, listener := range h.listeners {
+		_ = listener.Close()
+	}
+}
+
+// readRedisCommand reads a command as a RESP array of bulk strings, or as an
+// inline command of space separated arguments
+func readRedisCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := readRedisLine(reader)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+	count, err := strconv.Atoi(line[1:])
+	if err != nil || count < 0 || count > redisMaxArguments {
+		return nil, fmt.Errorf("invalid multibulk length %q", line)
+	}
+	args := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		header, err := readRedisLine(reader)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(header, "$") {
+			return nil, fmt.Errorf("expected bulk string, got %q", header)
+		}
+		length, err := strconv.Atoi(header[1:])
+		if err != nil || length < 0 || length > redisMaxBulk {
+			return nil, fmt.Errorf("invalid bulk length %q", header)
+		}
+		data := make([]byte, length+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		args = append(args, string(data[:length]))
+	}
+	return args, nil
+}
+
+// readRedisLine reads a line terminated by \r\n or \n
+func readRedisLine(reader *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := reader.ReadLine()
+		if err != nil {
+			return "", err
+		}
+		line = append(line, chunk...)
+		if len(line) > redisMaxBulk {
+			return "", errors.New("line too long")
+		}
+		if !isPrefix {
+			return string(line), nil
+		}
+	}
+}
+
+// formatRedisCommand returns the command with its arguments quoted when needed
+func formatRedisCommand(args []string) string {
+	formatted := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.IndexFunc(arg, func(r rune) bool { return unicode.IsSpace(r) || !unicode.IsPrint(r) || r == '"' }) >= 0 {
+			arg = strconv.Quote(arg)
+		}
+		formatted[i] = arg
+	}
+	return strings.Join(formatted, " ")
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"bufio"
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisServer(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0")
+	store := options.Storage
+
+	plain, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	secure, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	listeners := NewListeners()
+	listeners.addListener(plain)
+	listeners.addListener(secure)
+
+	options.ListenIP = "127.0.0.1"
+	options.RedisPort = plain.Addr().(*net.TCPAddr).Port
+	options.RedisTLSPort = secure.Addr().(*net.TCPAddr).Port
+	options.Listeners = listeners
+	certificate, err := localhostCertificate()
+	require.Nil(t, err)
+	server, err := NewRedisServer(options)
+	require.Nil(t, err)
+	alive, tlsAlive := make(chan bool, 1), make(chan bool, 1)
+	go server.ListenAndServe(&tls.Config{Certificates: []tls.Certificate{certificate}}, alive, tlsAlive)
+	require.True(t, <-alive)
+	require.True(t, <-tlsAlive)
+	defer server.Close()
+
+	// resp commands with the correlation id in the auth payload and inline commands
+	conn, err := net.Dial("tcp", plain.Addr().String())
+	require.Nil(t, err)
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+	_, err = conn.Write([]byte("*2\r\n$4\r\nAUTH\r\n$33\r\nc59e3crp82ke7bcnedq0cfjqdpeyyyyyy\r\n"))
+	require.Nil(t, err)
+	line, err := reader.ReadString('\n')
+	require.Nil(t, err)
+	require.Equal(t, "+OK\r\n", line)
+	_, err = conn.Write([]byte("PING\r\nCONFIG SET dir /var/www\r\nQUIT\r\n"))
+	require.Nil(t, err)
+	line, _ = reader.ReadString('\n')
+	require.Equal(t, "+PONG\r\n", line)
+	_, _ = reader.ReadString('\n')
+	_, _ = reader.ReadString('\n')
+	_, err = reader.ReadString('\n')
+	require.NotNil(t, err)
+	conn.Close()
+
+	// the tls server name correlates the connection
+	tlsConn, err := tls.Dial("tcp", secure.Addr().String(), &tls.Config{ServerName: "c59e3crp82ke7bcnedq0cfjqdpeyyyyzz.interact.sh", InsecureSkipVerify: true})
+	require.Nil(t, err)
+	_ = tlsConn.SetDeadline(time.Now().Add(5 * time.Second))
+	_, err = tlsConn.Write([]byte("*1\r\n$4\r\nQUIT\r\n"))
+	require.Nil(t, err)
+	_, _ = bufio.NewReader(tlsConn).ReadString('\n')
+	_, err = bufio.NewReader(tlsConn).ReadString('\n')
+	require.NotNil(t, err)
+	tlsConn.Close()
+
+	item, err := store.GetCacheItem("c59e3crp82ke7bcnedq0")
+	require.Nil(t, err)
+	require.Len(t, item.Data, 2)
+
+	interaction := &Interaction{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[0]), interaction))
+	require.Equal(t, "redis", interaction.Protocol)
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy", interaction.UniqueID)
+	require.Equal(t, "AUTH c59e3crp82ke7bcnedq0cfjqdpeyyyyyy\nPING\nCONFIG SET dir /var/www\nQUIT\n", interaction.RawRequest)
+
+	interaction = &Interaction{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[1]), interaction))
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyzz", interaction.UniqueID)
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyzz.interact.sh", interaction.TLSServerName)
+}
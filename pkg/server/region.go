@@ -0,0 +1,363 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/interactsh/pkg/storage"
+	"go.uber.org/ratelimit"
+	"gopkg.in/yaml.v3"
+)
+
+// RegionHeartbeat is the interval at which nodes announce themselves to their peers,
+// a region not announced within three intervals is no longer answered
+var RegionHeartbeat = 30 * time.Second
+
+var (
+	// RegionForwardQueue is the number of interactions waiting to be forwarded
+	// to the peers, the ones beyond it being dropped
+	RegionForwardQueue = 1024
+	// RegionForwardWorkers is the number of workers forwarding interactions to the peers
+	RegionForwardWorkers = 4
+	// RegionForwardRate is the number of interactions forwarded to the peers per second
+	RegionForwardRate = 100
+	// RegionUnknownTTL is the time the interactions of correlation ids refused
+	// by every peer are no longer forwarded for
+	RegionUnknownTTL = time.Minute
+)
+
+// regionMaxUnknown is the number of correlation ids refused by the peers that are remembered
+const regionMaxUnknown = 10000
+
+// Region is a deployment region of a multi-region setup
+type Region struct {
+	// Name is the unique name of the region
+	Name string `yaml:"name"`
+	// URL is the base url of the region node receiving forwarded interactions
+	URL string `yaml:"url"`
+	// IP is the address answered to resolvers of the region, learnt from
+	// the node announcements when empty
+	IP string `yaml:"ip,omitempty"`
+	// Prefixes are the resolver networks steered to the region
+	Prefixes []string `yaml:"prefixes"`
+
+	networks []*net.IPNet
+	ip       net.IP
+	lastSeen time.Time
+	// withdrawn is set when the region node shuts down, until it announces itself again
+	withdrawn bool
+}
+
+// Regions is a multi-region setup where dns answers are steered to the
+// nearest region and interactions are forwarded to the session home node
+type Regions struct {
+	// Local is the name of the region of this node
+	Local   string
+	Regions []*Region `yaml:"regions"`
+
+	mu        sync.RWMutex
+	token     string
+	store     storage.Storage
+	client    *http.Client
+	done      chan struct{}
+	closeOnce sync.Once
+
+	forwards chan *RegionForward
+	limiter  ratelimit.Limiter
+	// unknown are the correlation ids refused by every peer, by expiration time
+	unknownMu sync.Mutex
+	unknown   map[string]time.Time
+}
+
+// RegionAnnouncement is the heartbeat sent by a node to its peers
+type RegionAnnouncement struct {
+	Name string `json:"name"`
+	IP   string `json:"ip"`
+}
+
+// RegionForward is an interaction forwarded to the session home node
+type RegionForward struct {
+	CorrelationID string `json:"correlation-id"`
+	Data          []byte `json:"data"`
+}
+
+// NewRegions loads the multi-region configuration file for the local region,
+// peers are authenticated with the shared server token
+func NewRegions(path, local, token string) (*Regions, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	regions := &Regions{
+		Local:    local,
+		token:    token,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		done:     make(chan struct{}),
+		forwards: make(chan *RegionForward, RegionForwardQueue),
+		limiter:  ratelimit.New(RegionForwardRate),
+		unknown:  make(map[string]time.Time),
+	}
+	if err := yaml.Unmarshal(data, regions); err != nil {
+		return nil, err
+	}
+	var found bool
+	for _, region := range regions.Regions {
+		if region.Name == local {
+			found = true
+		}
+		if region.IP != "" {
+			if region.ip = net.ParseIP(region.IP); region.ip == nil {
+				return nil, fmt.Errorf("invalid ip %s for region %s", region.IP, region.Name)
+			}
+		}
+		for _, prefix := range region.Prefixes {
+			_, network, err := net.ParseCIDR(prefix)
+			if err != nil {
+				return nil, fmt.Errorf("invalid prefix %s for region %s", prefix, region.Name)
+			}
+			region.networks = append(region.networks, network)
+		}
+		region.URL = strings.TrimSuffix(region.URL, "/")
+	}
+	if !found {
+		return nil, fmt.Errorf("region %s not found in %s", local, path)
+	}
+	return regions, nil
+}
+
+// Steer returns the address of the live region whose prefixes contain the
+// source address with the longest match, or nil if none matches
+func (r *Regions) Steer(source net.IP) net.IP {
+	if r == nil || source == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var (
+		steered net.IP
+		longest = -1
+	)
+	for _, region := range r.Regions {
+		if !r.alive(region) {
+			continue
+		}
+		for _, network := range region.networks {
+			if !network.Contains(source) {
+				continue
+			}
+			if ones, _ := network.Mask.Size(); ones > longest {
+				steered, longest = region.ip, ones
+			}
+		}
+	}
+	return steered
+}
+
+// alive reports whether the region can be answered, statically addressed
+// regions are always considered alive
+func (r *Regions) alive(region *Region) bool {
+	if region.ip == nil || region.withdrawn {
+		return false
+	}
+	return region.IP != "" || region.Name == r.Local || time.Since(region.lastSeen) < 3*RegionHeartbeat
+}
+
+// Announce records the address announced by a region node
+func (r *Regions) Announce(name, ip string) error {
+	address := net.ParseIP(ip)
+	if address == nil {
+		return fmt.Errorf("invalid ip %s", ip)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, region := range r.Regions {
+		if region.Name != name {
+			continue
+		}
+		if region.IP == "" {
+			region.ip = address
+		}
+		region.lastSeen = time.Now()
+		region.withdrawn = false
+		return nil
+	}
+	return fmt.Errorf("unknown region %s", name)
+}
+
+// Withdraw records the shutdown of a region node, whose resolvers are no
+// longer steered to it until it announces itself again
+func (r *Regions) Withdraw(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, region := range r.Regions {
+		if region.Name == name {
+			region.withdrawn = true
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown region %s", name)
+}
+
+// Start announces the local node with its address to the peers periodically
+func (r *Regions) Start(ip string) {
+	_ = r.Announce(r.Local, ip)
+	announcement := &RegionAnnouncement{Name: r.Local, IP: ip}
+
+	ticker := time.NewTicker(RegionHeartbeat)
+	go func() {
+		defer ticker.Stop()
+		for {
+			r.broadcast("/region/announce", announcement)
+			select {
+			case <-ticker.C:
+			case <-r.done:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the announcements to the peers
+func (r *Regions) Close() {
+	r.closeOnce.Do(func() {
+		close(r.done)
+	})
+}
+
+// Deregister stops the announcements and withdraws the local node from the
+// peers, so that they stop steering resolvers to it before it shuts down
+func (r *Regions) Deregister() {
+	r.Close()
+	_ = r.Withdraw(r.Local)
+	r.broadcast("/region/withdraw", &RegionAnnouncement{Name: r.Local})
+}
+
+// WrapStorage returns a storage forwarding interactions for correlation ids
+// unknown to the local node to the peers, the node of the session storing them.
+// The interactions are forwarded in background by a bounded queue of workers.
+func (r *Regions) WrapStorage(store storage.Storage) storage.Storage {
+	r.store = store
+	for i := 0; i < RegionForwardWorkers; i++ {
+		go r.forwardWorker()
+	}
+	return &regionStorage{Storage: store, regions: r}
+}
+
+// enqueue queues an interaction to be forwarded to the peers, unless its
+// correlation id was refused by every peer recently or the queue is full
+func (r *Regions) enqueue(forward *RegionForward) {
+	if r.isUnknown(forward.CorrelationID, time.Now()) {
+		return
+	}
+	select {
+	case r.forwards <- forward:
+	default:
+		gologger.Debug().Msgf("Could not forward interaction for %s: queue full\n", forward.CorrelationID)
+	}
+}
+
+// forwardWorker forwards the queued interactions to the peers at the forward rate
+func (r *Regions) forwardWorker() {
+	for {
+		select {
+		case forward := <-r.forwards:
+			if r.isUnknown(forward.CorrelationID, time.Now()) {
+				continue
+			}
+			r.limiter.Take()
+			if !r.broadcast("/region/forward", forward) {
+				r.setUnknown(forward.CorrelationID, time.Now())
+			}
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// isUnknown reports whether a correlation id was refused by every peer recently
+func (r *Regions) isUnknown(correlationID string, now time.Time) bool {
+	r.unknownMu.Lock()
+	defer r.unknownMu.Unlock()
+
+	expiration, ok := r.unknown[correlationID]
+	return ok && now.Before(expiration)
+}
+
+// setUnknown remembers a correlation id refused by every peer
+func (r *Regions) setUnknown(correlationID string, now time.Time) {
+	r.unknownMu.Lock()
+	defer r.unknownMu.Unlock()
+
+	if len(r.unknown) >= regionMaxUnknown {
+		for id, expiration := range r.unknown {
+			if !now.Before(expiration) {
+				delete(r.unknown, id)
+			}
+		}
+		if len(r.unknown) >= regionMaxUnknown {
+			return
+		}
+	}
+	r.unknown[correlationID] = now.Add(RegionUnknownTTL)
+}
+
+// Deliver stores an interaction forwarded by a peer in the local storage
+func (r *Regions) Deliver(forward *RegionForward) error {
+	return r.store.AddInteraction(forward.CorrelationID, forward.Data)
+}
+
+// broadcast sends a request to every peer region, reporting whether a peer accepted it
+func (r *Regions) broadcast(path string, value interface{}) bool {
+	body, err := jsoniter.Marshal(value)
+	if err != nil {
+		return false
+	}
+	var accepted bool
+	for _, region := range r.Regions {
+		if region.Name == r.Local || region.URL == "" {
+			continue
+		}
+		req, err := http.NewRequest(http.MethodPost, region.URL+path, bytes.NewReader(body))
+		if err != nil {
+			continue
+		}
+		req.Header.Set("Authorization", r.token)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := r.client.Do(req)
+		if err != nil {
+			gologger.Debug().Msgf("Could not reach region %s: %s\n", region.Name, err)
+			continue
+		}
+		_ = resp.Body.Close()
+		accepted = accepted || resp.StatusCode == http.StatusOK
+	}
+	return accepted
+}
+
+// regionStorage forwards interactions for unknown correlation ids to the peers
+type regionStorage struct {
+	storage.Storage
+	regions *Regions
+}
+
+// AddInteraction stores the interaction locally or queues it to be forwarded
+// to the peers, still returning the not found error of the local storage
+func (s *regionStorage) AddInteraction(correlationID string, data []byte) error {
+	err := s.Storage.AddInteraction(correlationID, data)
+	if errors.Is(err, storage.ErrCorrelationIdNotFound) {
+		s.regions.enqueue(&RegionForward{CorrelationID: correlationID, Data: data})
+	}
+	return err
+}
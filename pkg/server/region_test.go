@@ -0,0 +1,78 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/interactsh/pkg/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegions(t *testing.T) {
+	forwarded := make(chan *RegionForward, 1)
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "token", r.Header.Get("Authorization"))
+		if r.URL.Path == "/region/forward" {
+			forward := &RegionForward{}
+			require.Nil(t, jsoniter.NewDecoder(r.Body).Decode(forward))
+			forwarded <- forward
+		}
+	}))
+	defer peer.Close()
+
+	config := `regions:
+  - name: eu
+    ip: 192.0.2.1
+    prefixes: [0.0.0.0/0]
+  - name: us
+    url: ` + peer.URL + `
+    prefixes: [198.51.100.0/24, 198.51.0.0/16]
+`
+	path := filepath.Join(t.TempDir(), "regions.yaml")
+	require.Nil(t, os.WriteFile(path, []byte(config), 0600))
+
+	_, err := NewRegions(path, "ap", "token")
+	require.NotNil(t, err)
+	regions, err := NewRegions(path, "eu", "token")
+	require.Nil(t, err)
+
+	// us has not announced itself yet
+	require.Equal(t, "192.0.2.1", regions.Steer(net.ParseIP("198.51.100.7")).String())
+	require.Nil(t, regions.Announce("us", "203.0.113.9"))
+	require.Equal(t, "203.0.113.9", regions.Steer(net.ParseIP("198.51.100.7")).String())
+	require.Equal(t, "192.0.2.1", regions.Steer(net.ParseIP("10.0.0.1")).String())
+	require.NotNil(t, regions.Announce("ap", "203.0.113.10"))
+
+	// a withdrawn region is answered again once it announces itself
+	require.Nil(t, regions.Withdraw("us"))
+	require.Equal(t, "192.0.2.1", regions.Steer(net.ParseIP("198.51.100.7")).String())
+	require.Nil(t, regions.Announce("us", "203.0.113.9"))
+	require.Equal(t, "203.0.113.9", regions.Steer(net.ParseIP("198.51.100.7")).String())
+
+	wrapped := regions.WrapStorage(newTestOptions(t).Storage)
+	// the not found error is returned to the drop accounting and grace buffer
+	require.ErrorIs(t, wrapped.AddInteraction("c59e3crp82ke7bcnedq0", []byte("data")), storage.ErrCorrelationIdNotFound)
+
+	select {
+	case forward := <-forwarded:
+		require.Equal(t, "c59e3crp82ke7bcnedq0", forward.CorrelationID)
+		require.Equal(t, "data", string(forward.Data))
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "interaction was not forwarded")
+	}
+	require.NotNil(t, regions.Deliver(&RegionForward{CorrelationID: "c59e3crp82ke7bcnedq0"}))
+
+	// the correlation ids refused by every peer are not forwarded for a while
+	now := time.Now()
+	regions.setUnknown("c59e3crp82ke7bcnedq1", now)
+	require.True(t, regions.isUnknown("c59e3crp82ke7bcnedq1", now))
+	require.False(t, regions.isUnknown("c59e3crp82ke7bcnedq1", now.Add(RegionUnknownTTL)))
+	regions.enqueue(&RegionForward{CorrelationID: "c59e3crp82ke7bcnedq1"})
+	require.Len(t, regions.forwards, 0)
+}
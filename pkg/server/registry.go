@@ -0,0 +1,197 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const (
+	// registryTokenPath is the token endpoint of the bearer challenge
+	registryTokenPath = "/v2/token"
+	// registryManifestType is the media type of the manifests answered
+	registryManifestType = "application/vnd.docker.distribution.manifest.v2+json"
+)
+
+// registryRoute matches the repository routes of the registry api (eg. /v2/library/alpine/manifests/latest)
+var registryRoute = regexp.MustCompile(`^/v2/(.+?)/(manifests|blobs|tags)/(.+)$`)
+
+// registryRequest is a docker registry api v2 request
+type registryRequest struct {
+	operation     string
+	repository    string
+	reference     string
+	authorization string
+}
+
+// isRegistryPath reports whether the path is a docker registry api v2 path
+func isRegistryPath(path string) bool {
+	return path == "/v2" || strings.HasPrefix(path, "/v2/")
+}
+
+// parseRegistryRequest returns the operation of a registry api request with
+// the requested image and the authorization sent by the client
+func parseRegistryRequest(r *http.Request) *registryRequest {
+	if !isRegistryPath(r.URL.Path) {
+		return nil
+	}
+	request := &registryRequest{operation: "unknown", authorization: registryAuthorization(r.Header.Get("Authorization"))}
+	switch path := r.URL.Path; {
+	case path == "/v2" || path == "/v2/":
+		request.operation = "ping"
+	case path == registryTokenPath:
+		request.operation = "token"
+		// the scope is repository:name:actions
+		for _, scope := range r.URL.Query()["scope"] {
+			if parts := strings.Split(scope, ":"); len(parts) >= 3 && parts[0] == "repository" {
+				request.repository = strings.Join(parts[1:len(parts)-1], ":")
+			}
+		}
+	case path == "/v2/_catalog":
+		request.operation = "catalog"
+	default:
+		match := registryRoute.FindStringSubmatch(path)
+		if match == nil {
+			break
+		}
+		request.repository = match[1]
+		switch {
+		case match[2] == "manifests":
+			request.operation, request.reference = "manifest", match[3]
+		case match[2] == "tags":
+			request.operation = "tags"
+		case strings.HasPrefix(match[3], "uploads"):
+			request.operation = "upload"
+		default:
+			request.operation, request.reference = "blob", match[3]
+		}
+	}
+	return request
+}
+
+// registryAuthorization describes an authorization header, with the user of basic credentials
+func registryAuthorization(header string) string {
+	scheme, credentials, _ := strings.Cut(header, " ")
+	if !strings.EqualFold(scheme, "basic") {
+		return scheme
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(credentials))
+	if err != nil {
+		return scheme
+	}
+	user, _, _ := strings.Cut(string(decoded), ":")
+	return scheme + " " + user
+}
+
+// marker returns the description of the registry request appended to the raw request
+func (q *registryRequest) marker() string {
+	if q == nil {
+		return ""
+	}
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("\n\nRegistry Operation: %s\n", q.operation))
+	if q.repository != "" {
+		image := q.repository
+		if q.reference != "" {
+			separator := ":"
+			if strings.Contains(q.reference, ":") {
+				separator = "@"
+			}
+			image += separator + q.reference
+		}
+		builder.WriteString(fmt.Sprintf("Image: %s\n", image))
+	}
+	if q.authorization != "" {
+		builder.WriteString(fmt.Sprintf("Authorization: %s\n", q.authorization))
+	}
+	return builder.String()
+}
+
+// apply sets the registry fields of the interaction
+func (q *registryRequest) apply(interaction *Interaction) {
+	if q == nil {
+		return
+	}
+	interaction.RegistryOperation = q.operation
+	interaction.RegistryRepository = q.repository
+	interaction.RegistryReference = q.reference
+	interaction.RegistryAuthorization = q.authorization
+}
+
+// writeRegistryResponse answers a registry api request, the requests without
+// authorization are challenged with a bearer token any credentials get, so
+// that clients send the credentials configured for the registry
+func writeRegistryResponse(w http.ResponseWriter, r *http.Request, request *registryRequest) {
+	w.Header().Set("Docker-Distribution-Api-Version", "registry/2.0")
+	w.Header().Set("Content-Type", "application/json")
+	if r.Header.Get("Authorization") == "" && request.operation != "token" {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		challenge := fmt.Sprintf(`Bearer realm="%s://%s%s",service="%s"`, scheme, r.Host, registryTokenPath, r.Host)
+		if request.repository != "" {
+			challenge += fmt.Sprintf(`,scope="repository:%s:pull"`, request.repository)
+		}
+		w.Header().Set("Www-Authenticate", challenge)
+		writeRegistryError(w, http.StatusUnauthorized, "UNAUTHORIZED", "authentication required")
+		return
+	}
+	switch request.operation {
+	case "ping":
+		_, _ = w.Write([]byte("{}"))
+	case "token":
+		token := registryDigest(r.Host, time.Now().String())[len("sha256:"):]
+		_ = jsoniter.NewEncoder(w).Encode(map[string]interface{}{"token": token, "access_token": token, "expires_in": 300, "issued_at": time.Now().UTC().Format(time.RFC3339)})
+	case "catalog":
+		_ = jsoniter.NewEncoder(w).Encode(map[string]interface{}{"repositories": []string{}})
+	case "tags":
+		_ = jsoniter.NewEncoder(w).Encode(map[string]interface{}{"name": request.repository, "tags": []string{"latest"}})
+	case "manifest":
+		manifest, _ := jsoniter.MarshalIndent(map[string]interface{}{
+			"schemaVersion": 2,
+			"mediaType":     registryManifestType,
+			"config": map[string]interface{}{
+				"mediaType": "application/vnd.docker.container.image.v1+json",
+				"size":      1472,
+				"digest":    registryDigest(request.repository, request.reference, "config"),
+			},
+			"layers": []interface{}{map[string]interface{}{
+				"mediaType": "application/vnd.docker.image.rootfs.diff.tar.gzip",
+				"size":      3408729,
+				"digest":    registryDigest(request.repository, request.reference, "layer"),
+			}},
+		}, "", "   ")
+		w.Header().Set("Content-Type", registryManifestType)
+		w.Header().Set("Docker-Content-Digest", registryDigest(string(manifest)))
+		w.Header().Set("Content-Length", fmt.Sprint(len(manifest)))
+		if r.Method != http.MethodHead {
+			_, _ = w.Write(manifest)
+		}
+	case "blob":
+		writeRegistryError(w, http.StatusNotFound, "BLOB_UNKNOWN", "blob unknown to registry")
+	case "upload":
+		writeRegistryError(w, http.StatusForbidden, "DENIED", "requested access to the resource is denied")
+	default:
+		writeRegistryError(w, http.StatusNotFound, "NAME_UNKNOWN", "repository name not known to registry")
+	}
+}
+
+// writeRegistryError writes a registry api error
+func writeRegistryError(w http.ResponseWriter, status int, code, message string) {
+	w.WriteHeader(status)
+	_ = jsoniter.NewEncoder(w).Encode(map[string]interface{}{"errors": []interface{}{map[string]interface{}{"code": code, "message": message, "detail": nil}}})
+}
+
+// registryDigest returns a sha256 digest of the values
+func registryDigest(values ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(values, "\x00")))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
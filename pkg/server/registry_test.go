@@ -0,0 +1,66 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRegistryRequest(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/v2/token?service=interact.sh&scope=repository:acme/internal-lib:pull", nil)
+	request.SetBasicAuth("ci-bot", "secret")
+	require.Equal(t, &registryRequest{operation: "token", repository: "acme/internal-lib", authorization: "Basic ci-bot"}, parseRegistryRequest(request))
+
+	require.Equal(t, &registryRequest{operation: "manifest", repository: "library/alpine", reference: "sha256:abc"}, parseRegistryRequest(httptest.NewRequest(http.MethodHead, "/v2/library/alpine/manifests/sha256:abc", nil)))
+	require.Equal(t, "upload", parseRegistryRequest(httptest.NewRequest(http.MethodPost, "/v2/app/blobs/uploads/", nil)).operation)
+	require.Equal(t, "tags", parseRegistryRequest(httptest.NewRequest(http.MethodGet, "/v2/app/tags/list", nil)).operation)
+	require.Equal(t, "ping", parseRegistryRequest(httptest.NewRequest(http.MethodGet, "/v2/", nil)).operation)
+	require.Nil(t, parseRegistryRequest(httptest.NewRequest(http.MethodGet, "/v2.php", nil)))
+}
+
+func TestRegistryEndpoint(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0")
+	store := options.Storage
+
+	server, err := NewHTTPServer(options)
+	require.Nil(t, err)
+
+	// anonymous pings are challenged with the token endpoint
+	recorder := httptest.NewRecorder()
+	server.nontlsserver.Handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://interact.sh/v2/", nil))
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+	require.Equal(t, `Bearer realm="http://interact.sh/v2/token",service="interact.sh"`, recorder.Header().Get("Www-Authenticate"))
+	require.Equal(t, "registry/2.0", recorder.Header().Get("Docker-Distribution-Api-Version"))
+
+	// manifest of an image named after the correlation id
+	request := httptest.NewRequest(http.MethodGet, "http://interact.sh/v2/c59e3crp82ke7bcnedq0cfjqdpeyyyyyy/app/manifests/latest", nil)
+	request.Header.Set("Authorization", "Bearer token")
+	recorder = httptest.NewRecorder()
+	server.nontlsserver.Handler.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, registryManifestType, recorder.Header().Get("Content-Type"))
+	require.Equal(t, registryDigest(recorder.Body.String()), recorder.Header().Get("Docker-Content-Digest"))
+	var manifest struct {
+		SchemaVersion int `json:"schemaVersion"`
+		Layers        []struct {
+			Digest string `json:"digest"`
+		} `json:"layers"`
+	}
+	require.Nil(t, jsoniter.Unmarshal(recorder.Body.Bytes(), &manifest))
+	require.Equal(t, 2, manifest.SchemaVersion)
+	require.Len(t, manifest.Layers, 1)
+
+	item, err := store.GetCacheItem("c59e3crp82ke7bcnedq0")
+	require.Nil(t, err)
+	require.Len(t, item.Data, 1)
+	interaction := &Interaction{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[0]), interaction))
+	require.Equal(t, "manifest", interaction.RegistryOperation)
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy/app", interaction.RegistryRepository)
+	require.Equal(t, "latest", interaction.RegistryReference)
+	require.Equal(t, "Bearer", interaction.RegistryAuthorization)
+	require.Contains(t, interaction.RawRequest, "Image: c59e3crp82ke7bcnedq0cfjqdpeyyyyyy/app:latest")
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/projectdiscovery/gologger"
+	stringsutil "github.com/projectdiscovery/utils/strings"
+)
+
+// ReloadOptions are the server options changed at runtime by a reload,
+// without restarting the protocol servers
+type ReloadOptions struct {
+	// Domains replace the configured domains, the first one being the primary
+	// domain, the domains added through the admin api being kept
+	Domains []string
+	// Token replaces the token of the server, the current one is kept if empty
+	Token string
+	// RootTLD enables the wildcard interactions of the served domains
+	RootTLD bool
+	// HTTPResponses replace the global http responses
+	HTTPResponses []*HTTPResponse
+	// CustomRecords is the file of custom dns records loaded again
+	CustomRecords string
+	// DNSRecords replace the static dns records
+	DNSRecords *DNSRecords
+}
+
+// ReloadResponse is the response to admin reload requests
+type ReloadResponse struct {
+	// Restarted are the protocol servers restarted as their ports changed
+	Restarted []string `json:"restarted,omitempty"`
+}
+
+// Reload applies reloaded options to the running servers. The records and
+// storage ids of the new domains and token are created as at startup.
+func (options *Options) Reload(reload *ReloadOptions) error {
+	if len(reload.Domains) == 0 {
+		return errors.New("no domains specified")
+	}
+	domains := make([]string, 0, len(reload.Domains))
+	for _, domain := range reload.Domains {
+		normalized, err := normalizeDomain(domain)
+		if err != nil {
+			return fmt.Errorf("%w: %s", err, domain)
+		}
+		if !stringsutil.EqualFoldAny(normalized, domains...) {
+			domains = append(domains, normalized)
+		}
+	}
+	records, err := loadCustomDNSRecords(reload.CustomRecords)
+	if err != nil {
+		return err
+	}
+
+	options.reloadMu.Lock()
+	if reload.RootTLD && !options.Auth && reload.Token == "" {
+		options.reloadMu.Unlock()
+		return errors.New("wildcard interactions require a token")
+	}
+	tokenChanged := reload.Token != "" && reload.Token != options.Token
+	if tokenChanged {
+		options.Token = reload.Token
+		options.Auth = true
+	}
+	options.RootTLD = reload.RootTLD
+	options.HTTPResponses = reload.HTTPResponses
+	options.CustomRecords = reload.CustomRecords
+	options.dnsRecords = records
+	options.DNSRecords = reload.DNSRecords
+	options.reloadMu.Unlock()
+
+	options.domainsMu.Lock()
+	for _, domain := range options.runtimeDomains {
+		if !stringsutil.EqualFoldAny(domain, domains...) {
+			domains = append(domains, domain)
+			gologger.Info().Msgf("Kept domain %s added at runtime\n", domain)
+		}
+	}
+	var added, removed []string
+	for _, domain := range domains {
+		if !stringsutil.EqualFoldAny(domain, options.Domains...) {
+			added = append(added, domain)
+		}
+	}
+	for _, domain := range options.Domains {
+		if !stringsutil.EqualFoldAny(domain, domains...) {
+			removed = append(removed, domain)
+		}
+	}
+	options.Domains = domains
+	options.domainsMu.Unlock()
+
+	if tokenChanged {
+		options.ensureID(reload.Token)
+	}
+	if reload.RootTLD {
+		for _, domain := range domains {
+			options.ensureID(domain)
+		}
+	}
+	for _, domain := range removed {
+		gologger.Info().Msgf("Removed domain %s\n", domain)
+		if options.OnDomainRemove != nil {
+			options.OnDomainRemove(domain)
+		}
+	}
+	for _, domain := range added {
+		gologger.Info().Msgf("Added domain %s\n", domain)
+		// certificates are issued in background as acme challenges take time
+		if options.OnDomainAdd != nil {
+			go options.OnDomainAdd(domain)
+		}
+	}
+	return nil
+}
+
+// ensureID creates an unencrypted storage id unless it exists, keeping its interactions
+func (options *Options) ensureID(id string) {
+	if _, err := options.Storage.GetCacheItem(id); err != nil {
+		_ = options.Storage.SetID(id)
+	}
+}
+
+// GetToken returns the token of the server, which can be changed by a reload
+func (options *Options) GetToken() string {
+	options.reloadMu.RLock()
+	defer options.reloadMu.RUnlock()
+
+	return options.Token
+}
+
+// checkAuth reports whether the authorization header holds the server token,
+// or authentication is disabled
+func (options *Options) checkAuth(authorization string) bool {
+	options.reloadMu.RLock()
+	defer options.reloadMu.RUnlock()
+
+	return !options.Auth || options.Token == authorization
+}
+
+// isRootTLD reports whether the wildcard interactions of the served domains are recorded
+func (options *Options) isRootTLD() bool {
+	options.reloadMu.RLock()
+	defer options.reloadMu.RUnlock()
+
+	return options.RootTLD
+}
+
+// getHTTPResponses returns the global http responses
+func (options *Options) getHTTPResponses() []*HTTPResponse {
+	options.reloadMu.RLock()
+	defer options.reloadMu.RUnlock()
+
+	return options.HTTPResponses
+}
+
+// getDNSRecords returns the static dns records
+func (options *Options) getDNSRecords() *DNSRecords {
+	options.reloadMu.RLock()
+	defer options.reloadMu.RUnlock()
+
+	return options.DNSRecords
+}
+
+// customDNSRecords returns the custom dns records, loaded from their file on first use
+func (options *Options) customDNSRecords() *customDNSRecords {
+	options.reloadMu.RLock()
+	records := options.dnsRecords
+	options.reloadMu.RUnlock()
+	if records != nil {
+		return records
+	}
+
+	options.reloadMu.Lock()
+	defer options.reloadMu.Unlock()
+	if options.dnsRecords == nil {
+		options.dnsRecords = newCustomDNSRecordsServer(options.CustomRecords)
+	}
+	return options.dnsRecords
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptionsReload(t *testing.T) {
+	options := newTestOptions(t)
+	store := options.Storage
+
+	added := make(chan string, 1)
+	options.Auth = true
+	options.Token = "old-token"
+	options.RootTLD = true
+	options.OnDomainAdd = func(domain string) { added <- domain }
+	require.Nil(t, store.SetID("interact.sh"))
+	require.Nil(t, store.AddInteractionWithId("interact.sh", []byte("{}")))
+
+	records := filepath.Join(t.TempDir(), "records.yaml")
+	require.Nil(t, os.WriteFile(records, []byte("internal: 10.0.0.1\n"), 0600))
+	require.Nil(t, options.Reload(&ReloadOptions{
+		Domains:       []string{"interact.sh", "oast.example.com"},
+		Token:         "new-token",
+		RootTLD:       true,
+		HTTPResponses: []*HTTPResponse{{Path: "/healthz", Status: http.StatusOK}},
+		CustomRecords: records,
+	}))
+	require.Equal(t, "oast.example.com", <-added)
+	require.Equal(t, []string{"interact.sh", "oast.example.com"}, options.GetDomains())
+	require.True(t, options.checkAuth("new-token"))
+	require.False(t, options.checkAuth("old-token"))
+	require.Equal(t, "10.0.0.1", options.customDNSRecords().checkCustomResponse("internal.interact.sh"))
+	require.NotNil(t, options.httpResponseOf(httptest.NewRequest(http.MethodGet, "/healthz", nil)))
+
+	// the ids of the token and domains are created, keeping the existing interactions
+	_, err := store.GetCacheItem("new-token")
+	require.Nil(t, err)
+	_, err = store.GetCacheItem("oast.example.com")
+	require.Nil(t, err)
+	item, err := store.GetCacheItem("interact.sh")
+	require.Nil(t, err)
+	require.Len(t, item.Data, 1)
+
+	// an unreadable records file leaves the options unchanged
+	require.NotNil(t, options.Reload(&ReloadOptions{Domains: []string{"interact.sh"}, CustomRecords: filepath.Join(t.TempDir(), "missing.yaml")}))
+	require.Len(t, options.GetDomains(), 2)
+	require.NotNil(t, options.Reload(&ReloadOptions{}))
+	require.NotNil(t, options.Reload(&ReloadOptions{Domains: []string{"interact.sh", "in valid"}}))
+	require.Len(t, options.GetDomains(), 2)
+
+	// the domains added at runtime are kept, the removed ones notified
+	var removed []string
+	options.OnDomainRemove = func(domain string) { removed = append(removed, domain) }
+	require.Nil(t, options.AddDomain("client.example"))
+	require.Nil(t, options.Reload(&ReloadOptions{Domains: []string{"Interact.sh."}, RootTLD: true}))
+	require.Equal(t, []string{"interact.sh", "client.example"}, options.GetDomains())
+	require.Equal(t, []string{"oast.example.com"}, removed)
+
+	// wildcard interactions can't be enabled without authentication
+	unauthenticated := &Options{Domains: []string{"interact.sh"}, Storage: store}
+	require.NotNil(t, unauthenticated.Reload(&ReloadOptions{Domains: []string{"interact.sh"}, RootTLD: true}))
+}
+
+func TestReloadEndpoint(t *testing.T) {
+	server, err := NewHTTPServer(&Options{Domains: []string{"interact.sh"}, Stats: &Metrics{}, AdminAPI: true})
+	require.Nil(t, err)
+
+	recorder := httptest.NewRecorder()
+	server.nontlsserver.Handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "http://interact.sh/admin/reload", nil))
+	require.Equal(t, http.StatusNotImplemented, recorder.Code)
+
+	server.options.OnReload = func() (*ReloadResponse, error) {
+		return &ReloadResponse{Restarted: []string{"ldap"}}, nil
+	}
+	recorder = httptest.NewRecorder()
+	server.nontlsserver.Handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "http://interact.sh/admin/reload", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Contains(t, recorder.Body.String(), `{"restarted":["ldap"]}`)
+}
@@ -1,3 +1,5 @@
+//go:build !no_responder
+
 package server
 
 import (
@@ -81,6 +83,7 @@ func (h *ResponderServer) ListenAndServe(responderAlive chan bool) error {
 	// This fetches the content at each change.
 	go func() {
 		for data := range ch {
+			h.options.UNCChains.recordSMBLog(data)
 			for searchTerm, extractAfter := range responderMonitorList {
 				if strings.Contains(data, searchTerm) {
 					responderData, err := stringsutil.After(data, extractAfter)
@@ -100,7 +103,7 @@ func (h *ResponderServer) ListenAndServe(responderAlive chan bool) error {
 						gologger.Warning().Msgf("Could not encode responder interaction: %s\n", err)
 					} else {
 						gologger.Debug().Msgf("Responder Interaction: \n%s\n", buffer.String())
-						if err := h.options.Storage.AddInteractionWithId(h.options.Token, buffer.Bytes()); err != nil {
+						if err := h.options.Storage.AddInteractionWithId(h.options.GetToken(), buffer.Bytes()); err != nil {
 							gologger.Warning().Msgf("Could not store dns interaction: %s\n", err)
 						}
 					}
@@ -113,7 +116,9 @@ func (h *ResponderServer) ListenAndServe(responderAlive chan bool) error {
 }
 
 func (h *ResponderServer) Close() {
-	_ = h.cmd.Process.Kill()
+	if h.cmd != nil && h.cmd.Process != nil {
+		_ = h.cmd.Process.Kill()
+	}
 	if fileutil.FolderExists(h.tmpFolder) {
 		os.RemoveAll(h.tmpFolder)
 	}
@@ -0,0 +1,22 @@
+//go:build no_responder
+
+package server
+
+import "errors"
+
+// ResponderServer is a placeholder for the responder server excluded by the no_responder build tag
+type ResponderServer struct{}
+
+// NewResponderServer returns an error as responder support is not compiled in
+func NewResponderServer(options *Options) (*ResponderServer, error) {
+	return nil, errors.New("responder support is not compiled in (no_responder build tag)")
+}
+
+// ListenAndServe is a no-op as responder support is not compiled in
+func (h *ResponderServer) ListenAndServe(responderAlive chan bool) error {
+	responderAlive <- false
+	return nil
+}
+
+// Close is a no-op as responder support is not compiled in
+func (h *ResponderServer) Close() {}
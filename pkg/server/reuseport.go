@@ -0,0 +1,22 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package server
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// reusePortControl sets SO_REUSEPORT on a socket before it is bound, the
+// kernel balances the connections and packets across the sockets of an address
+func reusePortControl(network, address string, conn syscall.RawConn) error {
+	var errSetsockopt error
+	err := conn.Control(func(fd uintptr) {
+		errSetsockopt = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return errSetsockopt
+}
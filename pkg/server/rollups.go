@@ -0,0 +1,307 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/interactsh/pkg/storage"
+)
+
+// Retention of the rolled-up statistics, much longer than the interactions
+// as each series keeps a counter per bucket
+var (
+	// RollupHourlyRetention is how long the hourly buckets are kept
+	RollupHourlyRetention = 30 * 24 * time.Hour
+	// RollupDailyRetention is how long the daily buckets are kept
+	RollupDailyRetention = 2 * 365 * 24 * time.Hour
+	// RollupSaveInterval is the delay between two saves of the changed statistics
+	RollupSaveInterval = time.Minute
+)
+
+// Resolutions and dimensions of the rolled-up statistics
+const (
+	RollupHour = "hour"
+	RollupDay  = "day"
+
+	RollupProtocol = "protocol"
+	RollupDomain   = "domain"
+	RollupToken    = "token"
+)
+
+// rollupDefaultToken is the token dimension of the sessions not registered by a tenant
+const rollupDefaultToken = "default"
+
+// rollupKey identifies a series of counters
+type rollupKey struct {
+	resolution, dimension, value string
+}
+
+// RollupPoint is the number of interactions of a bucket
+type RollupPoint struct {
+	Time  time.Time `json:"time"`
+	Count uint64    `json:"count"`
+}
+
+// RollupSeries are the counters of a dimension value (eg. protocol dns) at a resolution
+type RollupSeries struct {
+	Resolution string        `json:"resolution"`
+	Dimension  string        `json:"dimension"`
+	Value      string        `json:"value"`
+	Points     []RollupPoint `json:"points"`
+}
+
+// rollupFile is the stored form of the series, the buckets by unix time
+type rollupFile struct {
+	Series []rollupFileSeries `json:"series"`
+}
+
+type rollupFileSeries struct {
+	Resolution string           `json:"resolution"`
+	Dimension  string           `json:"dimension"`
+	Value      string           `json:"value"`
+	Buckets    map[int64]uint64 `json:"buckets"`
+}
+
+// Rollups keeps hourly and daily interaction counts per protocol, domain and
+// token in a local file, so that trends remain available once the
+// interactions are evicted. Tokens are identified by the name of their
+// tenant, the others being counted as default.
+type Rollups struct {
+	path string
+
+	mu     sync.Mutex
+	series map[rollupKey]map[int64]uint64
+	dirty  bool
+
+	done chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+// NewRollups loads the statistics of the file, which is created on first save
+func NewRollups(path string) (*Rollups, error) {
+	r := &Rollups{path: path, series: make(map[rollupKey]map[int64]uint64), done: make(chan struct{})}
+	data, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, err
+	default:
+		file := &rollupFile{}
+		if err := jsoniter.Unmarshal(data, file); err != nil {
+			return nil, fmt.Errorf("could not decode statistics: %w", err)
+		}
+		for _, series := range file.Series {
+			r.series[rollupKey{series.Resolution, series.Dimension, series.Value}] = series.Buckets
+		}
+	}
+	r.wg.Add(1)
+	go r.saveLoop()
+	return r, nil
+}
+
+// Record accounts an interaction in the hourly and daily buckets of its
+// protocol, domain and token
+func (r *Rollups) Record(protocol, domain, token string, timestamp time.Time) {
+	if token == "" {
+		token = rollupDefaultToken
+	}
+	timestamp = timestamp.UTC()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, resolution := range []string{RollupHour, RollupDay} {
+		bucket := rollupBucket(resolution, timestamp)
+		for _, dimension := range [][2]string{{RollupProtocol, protocol}, {RollupDomain, domain}, {RollupToken, token}} {
+			if dimension[1] == "" {
+				continue
+			}
+			key := rollupKey{resolution, dimension[0], dimension[1]}
+			buckets, ok := r.series[key]
+			if !ok {
+				buckets = make(map[int64]uint64)
+				r.series[key] = buckets
+			}
+			buckets[bucket]++
+		}
+	}
+	r.dirty = true
+}
+
+// rollupBucket returns the unix time of the start of the bucket of a timestamp
+func rollupBucket(resolution string, timestamp time.Time) int64 {
+	if resolution == RollupDay {
+		return timestamp.Truncate(24 * time.Hour).Unix()
+	}
+	return timestamp.Truncate(time.Hour).Unix()
+}
+
+// Query returns the series of a resolution since a time, sorted by dimension
+// and value, all the dimensions being returned if dimension is empty
+func (r *Rollups) Query(resolution, dimension string, since time.Time) []RollupSeries {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	size := time.Hour
+	if resolution == RollupDay {
+		size = 24 * time.Hour
+	}
+	var result []RollupSeries
+	for key, buckets := range r.series {
+		if key.resolution != resolution || (dimension != "" && key.dimension != dimension) {
+			continue
+		}
+		series := RollupSeries{Resolution: key.resolution, Dimension: key.dimension, Value: key.value}
+		for bucket, count := range buckets {
+			if bucketTime := time.Unix(bucket, 0).UTC(); bucketTime.Add(size).After(since) {
+				series.Points = append(series.Points, RollupPoint{Time: bucketTime, Count: count})
+			}
+		}
+		if len(series.Points) == 0 {
+			continue
+		}
+		sort.Slice(series.Points, func(i, j int) bool { return series.Points[i].Time.Before(series.Points[j].Time) })
+		result = append(result, series)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Dimension != result[j].Dimension {
+			return result[i].Dimension < result[j].Dimension
+		}
+		return result[i].Value < result[j].Value
+	})
+	return result
+}
+
+func (r *Rollups) saveLoop() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(RollupSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.Save(); err != nil {
+				gologger.Warning().Msgf("Could not save statistics: %s\n", err)
+			}
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// Save drops the expired buckets and writes the statistics if changed
+func (r *Rollups) Save() error {
+	r.mu.Lock()
+	if !r.dirty {
+		r.mu.Unlock()
+		return nil
+	}
+	r.prune(time.Now())
+	file := &rollupFile{Series: make([]rollupFileSeries, 0, len(r.series))}
+	for key, buckets := range r.series {
+		copied := make(map[int64]uint64, len(buckets))
+		for bucket, count := range buckets {
+			copied[bucket] = count
+		}
+		file.Series = append(file.Series, rollupFileSeries{Resolution: key.resolution, Dimension: key.dimension, Value: key.value, Buckets: copied})
+	}
+	r.dirty = false
+	r.mu.Unlock()
+
+	data, err := jsoniter.Marshal(file)
+	if err != nil {
+		return err
+	}
+	// the file is replaced at once so that a crash doesn't leave it truncated
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), r.path)
+}
+
+// prune drops the buckets older than their retention and the empty series
+func (r *Rollups) prune(now time.Time) {
+	for key, buckets := range r.series {
+		retention := RollupHourlyRetention
+		if key.resolution == RollupDay {
+			retention = RollupDailyRetention
+		}
+		oldest := now.Add(-retention).Unix()
+		for bucket := range buckets {
+			if bucket < oldest {
+				delete(buckets, bucket)
+			}
+		}
+		if len(buckets) == 0 {
+			delete(r.series, key)
+		}
+	}
+}
+
+// Close saves the statistics
+func (r *Rollups) Close() error {
+	r.once.Do(func() { close(r.done) })
+	r.wg.Wait()
+	return r.Save()
+}
+
+// WrapStorage returns a storage accounting the stored interactions in the
+// statistics, the tenants of the sessions being looked up in the options
+func (r *Rollups) WrapStorage(store storage.Storage, options *Options) storage.Storage {
+	return &rollupStorage{Storage: store, rollups: r, options: options}
+}
+
+// rollupStorage accounts the interactions stored in the statistics
+type rollupStorage struct {
+	storage.Storage
+	rollups *Rollups
+	options *Options
+}
+
+// AddInteraction stores the interaction and accounts it for the token of the session
+func (s *rollupStorage) AddInteraction(correlationID string, data []byte) error {
+	if err := s.Storage.AddInteraction(correlationID, data); err != nil {
+		return err
+	}
+	var token string
+	if tenant := s.options.Tenants.bySession(correlationID); tenant != nil {
+		token = tenant.Name
+	}
+	s.record(data, token)
+	return nil
+}
+
+// AddInteractionWithId stores the interaction and accounts it for the default token
+func (s *rollupStorage) AddInteractionWithId(id string, data []byte) error {
+	if err := s.Storage.AddInteractionWithId(id, data); err != nil {
+		return err
+	}
+	s.record(data, "")
+	return nil
+}
+
+func (s *rollupStorage) record(data []byte, token string) {
+	interaction := &Interaction{}
+	if err := jsoniter.Unmarshal(data, interaction); err != nil {
+		return
+	}
+	timestamp := interaction.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	s.rollups.Record(interaction.Protocol, interaction.Domain, token, timestamp)
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRollups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.json")
+	rollups, err := NewRollups(path)
+	require.Nil(t, err)
+
+	now := time.Now().UTC()
+	hour := now.Truncate(time.Hour)
+	rollups.Record("dns", "interact.sh", "", now)
+	rollups.Record("dns", "interact.sh", "acme", now)
+	rollups.Record("http", "", "acme", now.Add(-2*time.Hour))
+	// older than the hourly retention, kept in the daily buckets
+	rollups.Record("dns", "interact.sh", "", now.Add(-40*24*time.Hour))
+	require.Nil(t, rollups.Close())
+
+	rollups, err = NewRollups(path)
+	require.Nil(t, err)
+	defer rollups.Close()
+
+	series := rollups.Query(RollupHour, RollupProtocol, now.Add(-time.Hour))
+	require.Len(t, series, 1)
+	require.Equal(t, "dns", series[0].Value)
+	require.Equal(t, []RollupPoint{{Time: hour, Count: 2}}, series[0].Points)
+
+	series = rollups.Query(RollupHour, RollupToken, now.Add(-24*time.Hour))
+	require.Len(t, series, 2)
+	require.Equal(t, "acme", series[0].Value)
+	require.Len(t, series[0].Points, 2)
+	require.Equal(t, "default", series[1].Value)
+
+	series = rollups.Query(RollupDay, RollupDomain, now.Add(-365*24*time.Hour))
+	require.Len(t, series, 1)
+	require.Len(t, series[0].Points, 2)
+	require.Len(t, rollups.Query(RollupHour, "", now.Add(-365*24*time.Hour)), 5)
+}
+
+func TestRollupStorage(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0")
+	store := options.Storage
+
+	rollups, err := NewRollups(filepath.Join(t.TempDir(), "stats.json"))
+	require.Nil(t, err)
+	defer rollups.Close()
+	options.Tenants = &Tenants{}
+	options.EnableMetrics = true
+	options.Rollups = rollups
+	options.Tenants.Register("c59e3crp82ke7bcnedq0", &Tenant{Name: "acme"})
+	options.Storage = rollups.WrapStorage(store, options)
+
+	data, err := jsoniter.Marshal(&Interaction{Protocol: "smtp", Domain: "interact.sh", Timestamp: time.Now()})
+	require.Nil(t, err)
+	require.Nil(t, options.Storage.AddInteraction("c59e3crp82ke7bcnedq0", data))
+	require.NotNil(t, options.Storage.AddInteraction("unknown", data))
+
+	server, err := NewHTTPServer(options)
+	require.Nil(t, err)
+	recorder := httptest.NewRecorder()
+	server.metricsHistoryHandler(recorder, httptest.NewRequest(http.MethodGet, "/metrics/history?dimension=token&since=1h", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var series []RollupSeries
+	require.Nil(t, jsoniter.Unmarshal(recorder.Body.Bytes(), &series))
+	require.Len(t, series, 1)
+	require.Equal(t, "acme", series[0].Value)
+	require.EqualValues(t, 1, series[0].Points[0].Count)
+
+	recorder = httptest.NewRecorder()
+	server.metricsHistoryHandler(recorder, httptest.NewRequest(http.MethodGet, "/metrics/history?resolution=week", nil))
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+}
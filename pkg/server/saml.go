@@ -0,0 +1,175 @@
+package server
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// samlMaxSize is the maximum size of an inflated saml response of the redirect binding
+const samlMaxSize = 1024 * 1024
+
+// SAMLAssertion is an assertion of a saml response received on the acs path,
+// decoded without validating its signature
+type SAMLAssertion struct {
+	// Issuer is the entity id of the identity provider issuing the assertion
+	Issuer string `json:"issuer,omitempty"`
+	// Subject is the name id of the authenticated user
+	Subject string `json:"subject,omitempty"`
+	// Recipient is the acs url the assertion is intended for
+	Recipient string `json:"recipient,omitempty"`
+	// Audiences are the service providers the assertion is restricted to
+	Audiences []string `json:"audiences,omitempty"`
+	// NotBefore is the start of the validity of the assertion
+	NotBefore string `json:"not-before,omitempty"`
+	// NotOnOrAfter is the end of the validity of the assertion
+	NotOnOrAfter string `json:"not-on-or-after,omitempty"`
+	// Attributes are the attribute values of the user by name
+	Attributes map[string][]string `json:"attributes,omitempty"`
+	// Signed reports whether the assertion carries a signature
+	Signed bool `json:"signed,omitempty"`
+	// Encrypted reports whether the assertion is encrypted, its content is not decoded
+	Encrypted bool `json:"encrypted,omitempty"`
+}
+
+// samlResponse is a saml response posted (or redirected) to the acs path
+type samlResponse struct {
+	Destination  string `xml:"Destination,attr"`
+	InResponseTo string `xml:"InResponseTo,attr"`
+	Issuer       string `xml:"Issuer"`
+	Status       struct {
+		Code struct {
+			Value string `xml:"Value,attr"`
+		} `xml:"StatusCode"`
+	} `xml:"Status"`
+	Signature  *struct{} `xml:"Signature"`
+	Assertions []struct {
+		Issuer    string    `xml:"Issuer"`
+		Signature *struct{} `xml:"Signature"`
+		Subject   struct {
+			NameID       string `xml:"NameID"`
+			Confirmation struct {
+				Data struct {
+					Recipient string `xml:"Recipient,attr"`
+				} `xml:"SubjectConfirmationData"`
+			} `xml:"SubjectConfirmation"`
+		} `xml:"Subject"`
+		Conditions struct {
+			NotBefore    string   `xml:"NotBefore,attr"`
+			NotOnOrAfter string   `xml:"NotOnOrAfter,attr"`
+			Audiences    []string `xml:"AudienceRestriction>Audience"`
+		} `xml:"Conditions"`
+		Attributes []struct {
+			Name   string   `xml:"Name,attr"`
+			Values []string `xml:"AttributeValue"`
+		} `xml:"AttributeStatement>Attribute"`
+	} `xml:"Assertion"`
+	EncryptedAssertions []struct{} `xml:"EncryptedAssertion"`
+
+	relayState string
+	assertions []SAMLAssertion
+}
+
+// parseSAMLRequest returns the saml response of a request to the acs path, from
+// the form of the post binding or the query of the redirect binding
+func parseSAMLRequest(r *http.Request, body []byte, acsPath string) *samlResponse {
+	if acsPath == "" || r.URL.Path != acsPath {
+		return nil
+	}
+	values := r.URL.Query()
+	if r.Method == http.MethodPost {
+		if form, err := url.ParseQuery(string(body)); err == nil && form.Get("SAMLResponse") != "" {
+			values = form
+		}
+	}
+	encoded := values.Get("SAMLResponse")
+	if encoded == "" {
+		return nil
+	}
+	data, err := decodeSAMLResponse(encoded)
+	if err != nil {
+		return nil
+	}
+	response := &samlResponse{relayState: values.Get("RelayState")}
+	if err := xml.Unmarshal(data, response); err != nil {
+		return nil
+	}
+	for _, assertion := range response.Assertions {
+		decoded := SAMLAssertion{
+			Issuer:       strings.TrimSpace(assertion.Issuer),
+			Subject:      strings.TrimSpace(assertion.Subject.NameID),
+			Recipient:    assertion.Subject.Confirmation.Data.Recipient,
+			NotBefore:    assertion.Conditions.NotBefore,
+			NotOnOrAfter: assertion.Conditions.NotOnOrAfter,
+			Signed:       assertion.Signature != nil,
+		}
+		for _, audience := range assertion.Conditions.Audiences {
+			decoded.Audiences = append(decoded.Audiences, strings.TrimSpace(audience))
+		}
+		for _, attribute := range assertion.Attributes {
+			if decoded.Attributes == nil {
+				decoded.Attributes = make(map[string][]string)
+			}
+			for _, value := range attribute.Values {
+				decoded.Attributes[attribute.Name] = append(decoded.Attributes[attribute.Name], strings.TrimSpace(value))
+			}
+		}
+		response.assertions = append(response.assertions, decoded)
+	}
+	for range response.EncryptedAssertions {
+		response.assertions = append(response.assertions, SAMLAssertion{Encrypted: true})
+	}
+	return response
+}
+
+// decodeSAMLResponse decodes a base64 saml response, inflating it for the redirect binding
+func decodeSAMLResponse(encoded string) ([]byte, error) {
+	encoded = strings.Join(strings.Fields(encoded), "")
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("<")) {
+		return data, nil
+	}
+	return io.ReadAll(io.LimitReader(flate.NewReader(bytes.NewReader(data)), samlMaxSize))
+}
+
+// marker returns the description of the saml response appended to the raw request
+func (s *samlResponse) marker() string {
+	if s == nil {
+		return ""
+	}
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("\n\nSAML Response: issuer=%s destination=%s status=%s signed=%t\n", strings.TrimSpace(s.Issuer), s.Destination, s.Status.Code.Value, s.Signature != nil))
+	if s.relayState != "" {
+		builder.WriteString(fmt.Sprintf("RelayState: %s\n", s.relayState))
+	}
+	for _, assertion := range s.assertions {
+		if assertion.Encrypted {
+			builder.WriteString("Encrypted assertion\n")
+			continue
+		}
+		builder.WriteString(fmt.Sprintf("Assertion: issuer=%s subject=%s recipient=%s audiences=%s not-before=%s not-on-or-after=%s signed=%t\n", assertion.Issuer, assertion.Subject, assertion.Recipient, strings.Join(assertion.Audiences, ","), assertion.NotBefore, assertion.NotOnOrAfter, assertion.Signed))
+		for _, name := range sortedKeys(assertion.Attributes) {
+			builder.WriteString(fmt.Sprintf("Attribute %s: %s\n", name, strings.Join(assertion.Attributes[name], ",")))
+		}
+	}
+	return builder.String()
+}
+
+// apply sets the saml fields of the interaction
+func (s *samlResponse) apply(interaction *Interaction) {
+	if s == nil {
+		return
+	}
+	interaction.SAMLIssuer = strings.TrimSpace(s.Issuer)
+	interaction.SAMLDestination = s.Destination
+	interaction.SAMLAssertions = s.assertions
+}
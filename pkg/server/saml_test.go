@@ -0,0 +1,87 @@
+package server
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+)
+
+const samlTestResponse = `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" Destination="http://c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh/saml/acs">
+  <saml:Issuer>https://idp.example.com</saml:Issuer>
+  <samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/></samlp:Status>
+  <saml:Assertion>
+    <saml:Issuer>https://idp.example.com</saml:Issuer>
+    <ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#"/>
+    <saml:Subject>
+      <saml:NameID>admin@example.com</saml:NameID>
+      <saml:SubjectConfirmation><saml:SubjectConfirmationData Recipient="https://sp.example.com/acs"/></saml:SubjectConfirmation>
+    </saml:Subject>
+    <saml:Conditions NotBefore="2024-01-01T00:00:00Z" NotOnOrAfter="2024-01-01T00:05:00Z">
+      <saml:AudienceRestriction><saml:Audience>https://sp.example.com</saml:Audience></saml:AudienceRestriction>
+    </saml:Conditions>
+    <saml:AttributeStatement>
+      <saml:Attribute Name="role"><saml:AttributeValue>admin</saml:AttributeValue><saml:AttributeValue>user</saml:AttributeValue></saml:Attribute>
+    </saml:AttributeStatement>
+  </saml:Assertion>
+  <saml:EncryptedAssertion/>
+</samlp:Response>`
+
+func TestParseSAMLRequest(t *testing.T) {
+	// redirect binding, deflated in the query
+	buffer := &bytes.Buffer{}
+	writer, err := flate.NewWriter(buffer, flate.DefaultCompression)
+	require.Nil(t, err)
+	_, _ = writer.Write([]byte(samlTestResponse))
+	require.Nil(t, writer.Close())
+	request := httptest.NewRequest(http.MethodGet, "/saml/acs?RelayState=state&SAMLResponse="+url.QueryEscape(base64.StdEncoding.EncodeToString(buffer.Bytes())), nil)
+	response := parseSAMLRequest(request, nil, "/saml/acs")
+	require.NotNil(t, response)
+	require.Equal(t, "state", response.relayState)
+	require.Len(t, response.assertions, 2)
+	require.True(t, response.assertions[1].Encrypted)
+
+	require.Nil(t, parseSAMLRequest(request, nil, ""))
+	require.Nil(t, parseSAMLRequest(request, nil, "/acs"))
+}
+
+func TestSAMLACS(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0")
+	options.SAMLACSPath = "/saml/acs"
+	store := options.Storage
+
+	server, err := NewHTTPServer(options)
+	require.Nil(t, err)
+	form := url.Values{"SAMLResponse": {base64.StdEncoding.EncodeToString([]byte(samlTestResponse))}}
+	request := httptest.NewRequest(http.MethodPost, "http://c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh/saml/acs", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	server.nontlsserver.Handler.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	item, err := store.GetCacheItem("c59e3crp82ke7bcnedq0")
+	require.Nil(t, err)
+	require.Len(t, item.Data, 1)
+	interaction := &Interaction{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[0]), interaction))
+	require.Equal(t, "https://idp.example.com", interaction.SAMLIssuer)
+	require.Equal(t, "http://c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh/saml/acs", interaction.SAMLDestination)
+	require.Equal(t, []SAMLAssertion{{
+		Issuer:       "https://idp.example.com",
+		Subject:      "admin@example.com",
+		Recipient:    "https://sp.example.com/acs",
+		Audiences:    []string{"https://sp.example.com"},
+		NotBefore:    "2024-01-01T00:00:00Z",
+		NotOnOrAfter: "2024-01-01T00:05:00Z",
+		Attributes:   map[string][]string{"role": {"admin", "user"}},
+		Signed:       true,
+	}, {Encrypted: true}}, interaction.SAMLAssertions)
+	require.Contains(t, interaction.RawRequest, "Attribute role: admin,user\n")
+}
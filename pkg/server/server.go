@@ -2,9 +2,13 @@ package server
 
 import (
 	"crypto/tls"
+	"net"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
 
+	jsoniter "github.com/json-iterator/go"
 	"github.com/projectdiscovery/interactsh/pkg/server/acme"
 	"github.com/projectdiscovery/interactsh/pkg/storage"
 	stringsutil "github.com/projectdiscovery/utils/strings"
@@ -20,6 +24,15 @@ type Interaction struct {
 	FullId string `json:"full-id"`
 	// QType is the question type for the interaction
 	QType string `json:"q-type,omitempty"`
+	// DNSTransport is the encrypted transport (doh or dot) of a dns query, empty for plain dns
+	DNSTransport string `json:"dns-transport,omitempty"`
+	// ClientSubnet is the edns client subnet of a dns query, the network of the
+	// client behind the recursive resolver reported as remote address
+	ClientSubnet string `json:"client-subnet,omitempty"`
+	// RebindStrategy is the rebinding strategy (rr, flip or time) encoded in the name of a dns query
+	RebindStrategy string `json:"rebind-strategy,omitempty"`
+	// RebindAnswer is the address answered to a rebinding dns query, empty if none
+	RebindAnswer string `json:"rebind-answer,omitempty"`
 	// RawRequest is the raw request received by the interactsh server.
 	RawRequest string `json:"raw-request,omitempty"`
 	// RawResponse is the raw response sent by the interactsh server.
@@ -31,18 +44,211 @@ type Interaction struct {
 	// Timestamp is the timestamp for the interaction
 	Timestamp time.Time           `json:"timestamp"`
 	AsnInfo   []map[string]string `json:"asninfo,omitempty"`
+	// ClientTimestamp is the timestamp embedded by the remote host in the
+	// protocol data (http and smtp Date headers), if any
+	ClientTimestamp *time.Time `json:"client-timestamp,omitempty"`
+	// DNSVerified reports whether the interaction was preceded by a dns resolution
+	// of the same payload, it is only set by clients verifying http and smtp interactions
+	DNSVerified *bool `json:"dns-verified,omitempty"`
+	// PayloadEmitted is the time the payload was generated, it is only set by
+	// clients for the payloads they generated
+	PayloadEmitted *time.Time `json:"payload-emitted,omitempty"`
+	// PayloadLatency is the delay in milliseconds from the payload generation
+	// to the interaction
+	PayloadLatency *int64 `json:"payload-latency-ms,omitempty"`
+	// PayloadNamespace is the namespace the payload was generated for, it is
+	// only set by clients for the payloads of their payload factories
+	PayloadNamespace string `json:"payload-namespace,omitempty"`
+	// Truncated reports whether the request body exceeded the capture limit
+	// and only its first part was stored
+	Truncated bool `json:"truncated,omitempty"`
+	// BodySize is the total size of a truncated request body
+	BodySize int64 `json:"body-size,omitempty"`
+	// BodySHA256 is the sha256 digest of the full truncated request body
+	BodySHA256 string `json:"body-sha256,omitempty"`
+	// BodyStored is the number of bytes of the truncated body stored on disk by
+	// the server, retrievable by the session owner with the body-sha256
+	BodyStored int64 `json:"body-stored,omitempty"`
+	// BodyType is the type sniffed from the captured body of a http, smtp or ftp
+	// interaction (eg. json, xml, java-serialized, protobuf, zip)
+	BodyType string `json:"body-type,omitempty"`
+	// BodyTags are the tags of the sniffed body (eg. base64, jar, java-gadget)
+	BodyTags []string `json:"body-tags,omitempty"`
+	// BodyPreview is a printable preview of the start of the sniffed body
+	BodyPreview string `json:"body-preview,omitempty"`
+	// BodyParts are the parts of a multipart request body, with the start of the uploaded files
+	BodyParts []BodyPart `json:"body-parts,omitempty"`
+	// BodyJSON is the json request body, compacted
+	BodyJSON jsoniter.RawMessage `json:"body-json,omitempty"`
+	// JavaClasses are the classes described in a java serialized body or ldap attribute
+	JavaClasses []string `json:"java-classes,omitempty"`
+	// JavaGadgets are the known deserialization gadget chains of the java classes (eg. CommonsCollections, URLDNS)
+	JavaGadgets []string `json:"java-gadgets,omitempty"`
+	// NAT64Prefix is the NAT64 prefix of the remote address, if translated from IPv4
+	NAT64Prefix string `json:"nat64-prefix,omitempty"`
+	// EmbeddedIPv4 is the IPv4 address of the remote host embedded in its NAT64 address
+	EmbeddedIPv4 string `json:"embedded-ipv4,omitempty"`
+	// ExfilData is the decoded data reassembled from the chunks of dns exfil queries
+	ExfilData []byte `json:"exfil-data,omitempty"`
+	// ExfilEncoding is the encoding of the exfil chunks (hex, base32 or raw)
+	ExfilEncoding string `json:"exfil-encoding,omitempty"`
+	// ExfilChunks is the number of reassembled exfil chunks
+	ExfilChunks int `json:"exfil-chunks,omitempty"`
+	// ExfilTotal is the number of chunks of an exfil stream stored incomplete when the server stopped
+	ExfilTotal int `json:"exfil-total,omitempty"`
+	// Domain is the served domain the interaction arrived on, for servers
+	// serving several domains
+	Domain string `json:"domain,omitempty"`
+	// OriginalHost is the host name as received when it differs from the normalized
+	// one correlated (letter case or internationalized labels)
+	OriginalHost string `json:"original-host,omitempty"`
+	// Decoded are the printable strings decoded from the encoded values of the
+	// dns labels, http path and query or ldap dn of the interaction
+	Decoded []DecodedCandidate `json:"decoded,omitempty"`
+	// RawBytes is the raw request as received on the wire (BER encoded ldap messages,
+	// udp datagrams), base64 encoded in json
+	RawBytes []byte `json:"raw-bytes,omitempty"`
+	// BindUser is the dn of an ldap bind request
+	BindUser string `json:"bind-user,omitempty"`
+	// BindPassword is the password of an ldap simple bind request
+	BindPassword string `json:"bind-password,omitempty"`
+	// SSHClientVersion is the banner of the ssh client
+	SSHClientVersion string `json:"ssh-client-version,omitempty"`
+	// SSHUser is the user of an ssh authentication attempt
+	SSHUser string `json:"ssh-user,omitempty"`
+	// SSHAuthMethod is the method of an ssh authentication attempt (password, publickey or keyboard-interactive)
+	SSHAuthMethod string `json:"ssh-auth-method,omitempty"`
+	// SSHCredential is the password or the public key fingerprint offered by the ssh client
+	SSHCredential string `json:"ssh-credential,omitempty"`
+	// MySQLUser is the user of a mysql handshake
+	MySQLUser string `json:"mysql-user,omitempty"`
+	// MySQLDatabase is the database selected in a mysql handshake
+	MySQLDatabase string `json:"mysql-database,omitempty"`
+	// MySQLCapabilities are the capability flags of the mysql client
+	MySQLCapabilities []string `json:"mysql-capabilities,omitempty"`
+	// MySQLAttributes are the connection attributes sent by the mysql client
+	MySQLAttributes map[string]string `json:"mysql-attributes,omitempty"`
+	// MySQLInfile is the file of a LOAD DATA LOCAL INFILE statement
+	MySQLInfile string `json:"mysql-infile,omitempty"`
+	// DroppedPort is the network/port without a listener of a dropped packets summary
+	DroppedPort string `json:"dropped-port,omitempty"`
+	// DroppedPackets is the number of packets captured on the port during the summary interval
+	DroppedPackets int `json:"dropped-packets,omitempty"`
+	// TLSServerName is the server name indicated by the client in the tls handshake
+	TLSServerName string `json:"tls-server-name,omitempty"`
+	// GRPCMethod is the full method name (/package.Service/Method) of a grpc call
+	GRPCMethod string `json:"grpc-method,omitempty"`
+	// GRPCMessages are the hex encoded protobuf messages of a grpc call
+	GRPCMessages []string `json:"grpc-messages,omitempty"`
+	// LocalPort is the port of the tcp or udp catch-all (or snmp) server the request was received on
+	LocalPort int `json:"local-port,omitempty"`
+	// WebSocketFrame is the sequence number of a data frame received on a websocket
+	WebSocketFrame int `json:"websocket-frame,omitempty"`
+	// MailUser is the user of an imap or pop3 login
+	MailUser string `json:"mail-user,omitempty"`
+	// MailPassword is the password of an imap or pop3 login
+	MailPassword string `json:"mail-password,omitempty"`
+	// XMLDoctype is the DOCTYPE declaration of an xml request body
+	XMLDoctype string `json:"xml-doctype,omitempty"`
+	// XMLEntities are the entities declared in the DOCTYPE of an xml request body
+	XMLEntities []XMLEntity `json:"xml-entities,omitempty"`
+	// XXE reports whether the DOCTYPE loads external entities or declares parameter entities
+	XXE bool `json:"xxe,omitempty"`
+	// SAMLIssuer is the issuer of a saml response received on the acs path
+	SAMLIssuer string `json:"saml-issuer,omitempty"`
+	// SAMLDestination is the destination of a saml response received on the acs path
+	SAMLDestination string `json:"saml-destination,omitempty"`
+	// SAMLAssertions are the assertions of a saml response received on the acs path
+	SAMLAssertions []SAMLAssertion `json:"saml-assertions,omitempty"`
+	// WebhookSignature is the vendor signature of a webhook request
+	WebhookSignature *WebhookSignature `json:"webhook-signature,omitempty"`
+	// TFTPFile is the file name of a tftp request
+	TFTPFile string `json:"tftp-file,omitempty"`
+	// TFTPOperation is the operation (read or write) of a tftp request
+	TFTPOperation string `json:"tftp-operation,omitempty"`
+	// OAuthResponseMode is the response mode (query, form_post or fragment) of an oauth callback
+	OAuthResponseMode string `json:"oauth-response-mode,omitempty"`
+	// OAuthCode is the authorization code of an oauth callback
+	OAuthCode string `json:"oauth-code,omitempty"`
+	// OAuthState is the state of an oauth callback
+	OAuthState string `json:"oauth-state,omitempty"`
+	// OAuthParams are the parameters of an oauth callback (eg. access_token, id_token, error)
+	OAuthParams map[string]string `json:"oauth-params,omitempty"`
+	// SNMPVersion is the version (v1 or v2c) of a snmp message
+	SNMPVersion string `json:"snmp-version,omitempty"`
+	// SNMPCommunity is the community of a snmp message
+	SNMPCommunity string `json:"snmp-community,omitempty"`
+	// SNMPPDU is the pdu type of a snmp message (eg. get-request, trap-v2)
+	SNMPPDU string `json:"snmp-pdu,omitempty"`
+	// SNMPVarbinds are the variable bindings of a snmp message
+	SNMPVarbinds []SNMPVarbind `json:"snmp-varbinds,omitempty"`
+	// GraphQLOperations are the operations of a graphql request
+	GraphQLOperations []GraphQLOperation `json:"graphql-operations,omitempty"`
+	// NTPMode is the association mode of a ntp request (eg. client, control, private)
+	NTPMode string `json:"ntp-mode,omitempty"`
+	// NTPRequest is the control opcode or private request code of a ntp request (eg. readvar, monlist)
+	NTPRequest string `json:"ntp-request,omitempty"`
+	// SIPMethod is the method of a sip request (eg. OPTIONS, REGISTER)
+	SIPMethod string `json:"sip-method,omitempty"`
+	// SIPRequestURI is the Request-URI of a sip request
+	SIPRequestURI string `json:"sip-request-uri,omitempty"`
+	// SIPTransport is the transport (udp or tcp) of a sip request
+	SIPTransport string `json:"sip-transport,omitempty"`
+	// GRPCMetadata is the metadata of a call received by the grpc server
+	GRPCMetadata map[string]string `json:"grpc-metadata,omitempty"`
+	// GRPCReflection are the server reflection requests of a call received by the grpc server
+	GRPCReflection []string `json:"grpc-reflection,omitempty"`
+	// RegistryOperation is the docker registry api operation of a request (eg. ping, token, manifest)
+	RegistryOperation string `json:"registry-operation,omitempty"`
+	// RegistryRepository is the image repository of a docker registry api request
+	RegistryRepository string `json:"registry-repository,omitempty"`
+	// RegistryReference is the tag or digest of a docker registry api request
+	RegistryReference string `json:"registry-reference,omitempty"`
+	// RegistryAuthorization is the authorization scheme of a docker registry api request, with the user of basic credentials
+	RegistryAuthorization string `json:"registry-authorization,omitempty"`
+	// PackageEcosystem is the package index of a request (npm, pypi or maven)
+	PackageEcosystem string `json:"package-ecosystem,omitempty"`
+	// PackageName is the package requested from a package index, the group and artifact ids for maven
+	PackageName string `json:"package-name,omitempty"`
+	// PackageVersion is the package version requested from a package index
+	PackageVersion string `json:"package-version,omitempty"`
+	// PackageUserAgent is the user agent of the client requesting a package
+	PackageUserAgent string `json:"package-user-agent,omitempty"`
+	// ICMPPaired reports whether an icmp echo request was attributed to the preceding address lookup of a correlation id
+	ICMPPaired bool `json:"icmp-paired,omitempty"`
+	// ScannerProbe is the fingerprint probe of a network scanner matching the request (eg. nmap:GetRequest)
+	ScannerProbe string `json:"scanner-probe,omitempty"`
+	// ResponseLatency is the delay in milliseconds of the fuzzed responses to the payload
+	ResponseLatency int64 `json:"response-latency-ms,omitempty"`
+	// ResponseSize is the size in bytes of the fuzzed responses to the payload
+	ResponseSize int `json:"response-size,omitempty"`
+	// RedirectHop is the number of hops left in the /r/<n> redirect chain of the request
+	RedirectHop int `json:"redirect-hop,omitempty"`
+	// RedirectLocation is the location the request was redirected to by the redirect endpoints
+	RedirectLocation string `json:"redirect-location,omitempty"`
+	// UNCChain links the webdav request to the lookup and smb attempt of the unc path opened
+	UNCChain *UNCChain `json:"unc-chain,omitempty"`
+	// TLS is the fingerprint of the tls client hello with the negotiated parameters
+	TLS *TLSFingerprint `json:"tls,omitempty"`
 }
 
 // Options contains configuration options for the servers
 type Options struct {
 	// Domains is the list domains for the instance.
-	Domains []string
+	Domains   []string
+	domainsMu sync.RWMutex
+	// runtimeDomains are the domains added through the admin api
+	runtimeDomains []string
 	// IPAddress is the IP address of the current server.
 	IPAddress string
 	// ListenIP is the IP address to listen servers on
 	ListenIP string
 	// DomainPort is the port to listen DNS servers on
 	DnsPort int
+	// DoTPort is the port to listen the dns over tls server on
+	DoTPort int
+	// DoH answers dns over https queries on /dns-query of the http servers
+	DoH bool
 	// HttpPort is the port to listen HTTP server on
 	HttpPort int
 	// HttpsPort is the port to listen HTTPS server on
@@ -61,6 +267,61 @@ type Options struct {
 	FtpsPort int
 	// FtpPort is the port to listen Ftp server on
 	LdapPort int
+	// SshPort is the port to listen Ssh server on
+	SshPort int
+	// MysqlPort is the port to listen Mysql server on
+	MysqlPort int
+	// RedisPort is the port to listen Redis server on
+	RedisPort int
+	// RedisTLSPort is the port to listen Redis tls server on
+	RedisTLSPort int
+	// IMAPPort is the port to listen IMAP server on
+	IMAPPort int
+	// IMAPSPort is the port to listen IMAP tls server on
+	IMAPSPort int
+	// POP3Port is the port to listen POP3 server on
+	POP3Port int
+	// POP3SPort is the port to listen POP3 tls server on
+	POP3SPort int
+	// TFTPPort is the port to listen TFTP server on
+	TFTPPort int
+	// SNMPPort is the port to listen SNMP agent on
+	SNMPPort int
+	// SNMPTrapPort is the port to listen SNMP trap receiver on
+	SNMPTrapPort int
+	// NTPPort is the port to listen NTP server on
+	NTPPort int
+	// SIPPort is the port to listen SIP server on, with udp and tcp
+	SIPPort int
+	// GRPCPort is the port to listen gRPC server on, with h2c and tls
+	GRPCPort int
+	// AddressLookups are the recent address lookups of correlation ids, tracked for the icmp server
+	AddressLookups *LookupTracker
+	// UNCChains are the recent lookups and smb attempts linked to the webdav requests, nil when disabled
+	UNCChains *UNCChainTracker
+	// tlsHellos are the connections with a tls fingerprint by remote address
+	tlsHellos sync.Map
+	// LDAPFullLogger logs all the ldap requests, not only the correlated ones
+	LDAPFullLogger bool
+	// OnListenerStatus is called when a listener of a protocol server starts or stops
+	OnListenerStatus func(ListenerStatus)
+	// EchoTCPPort is the port to listen the tcp echo server on
+	EchoTCPPort int
+	// EchoUDPPort is the port to listen the udp echo server on
+	EchoUDPPort int
+	// InspectLog keeps the http interactions of the sessions with an inspect page, nil when disabled
+	InspectLog *InspectLog
+	// TCPPorts are the extra ports to listen the tcp catch-all server on
+	TCPPorts []int
+	// TCPCaptureSize is the number of payload bytes recorded by the tcp catch-all server
+	TCPCaptureSize int
+	// TCPPayloadRegex restricts the tcp catch-all correlation to its matches
+	// (first group if any) instead of the whole payload, if set
+	TCPPayloadRegex *regexp.Regexp
+	// UDPPorts are the extra ports to listen the udp catch-all server on
+	UDPPorts []int
+	// DeceptionProfile holds the banners and probe answers of the catch-all servers, if any
+	DeceptionProfile *DeceptionProfile
 	// Hostmaster is the hostmaster email for the server.
 	Hostmasters []string
 	// Storage is a storage for interaction data storage
@@ -85,12 +346,19 @@ type Options struct {
 	CorrelationIdLength int
 	// CorrelationIdNonceLength of the unique identifier
 	CorrelationIdNonceLength int
+	// CorrelationIdChecksum requires the last nonce character of unique ids to be
+	// their checksum, reducing false correlations on random tokens
+	CorrelationIdChecksum bool
 	// Certificate Path
 	CertificatePath string
 	// Private Key Path
 	PrivateKeyPath string
 	// CustomRecords is a file containing custom DNS records
 	CustomRecords string
+	// DNSRecords are the static records answered for specific names, if any
+	DNSRecords *DNSRecords
+	// DNSSEC signs the responses for the served domains, if enabled
+	DNSSEC *DNSSEC
 	// HTTP header containing origin IP
 	OriginIPHeader string
 	// Version is the version of interactsh server
@@ -103,10 +371,85 @@ type Options struct {
 	DynamicResp bool
 	// EnableMetrics enables metrics endpoint
 	EnableMetrics bool
+	// Rollups keeps the long-term statistics served on /metrics/history, if any
+	Rollups *Rollups
 	// ServerToken hide server version in HTTP response X-Interactsh-Version header
 	NoVersionHeader bool
 	// HeaderServer use custom string in HTTP response Server header instead of domain
 	HeaderServer string
+	// HTTPPersonalities are the http server personalities to mimic, optionally per domain (domain=personality)
+	HTTPPersonalities []string
+	// HTTPResponses are the responses served for their path on every domain and session
+	HTTPResponses []*HTTPResponse
+	// OIDC serves openid discovery documents and the jwks of a signing key, if set
+	OIDC *OIDCProvider
+	// SAMLACSPath is the path accepting saml responses on every domain, disabled if empty
+	SAMLACSPath string
+	// Redirect serves the /redirect?to= and /r/<n> redirect chain endpoints on the payload hosts
+	Redirect bool
+	// RedirectMaxHops is the maximum length of a /r/<n> redirect chain
+	RedirectMaxHops int
+	// DNSUncorrelated is the response to dns queries without a correlation id
+	DNSUncorrelated string
+	// DNSForwarders are the upstream resolvers (host:port) queries for names outside
+	// of the served domains are forwarded to, they are answered as usual if empty
+	DNSForwarders []string
+	// DNSCatchAllIP is the address answered to queries without a correlation id in catch-all mode
+	DNSCatchAllIP string
+	// AdminAPI enables the authenticated admin api to manage domains at runtime
+	AdminAPI bool
+	// OnDomainAdd is called when a domain is added at runtime
+	OnDomainAdd func(domain string)
+	// OnDomainRemove is called when a domain is removed at runtime or by a reload
+	OnDomainRemove func(domain string)
+	// Regions is the multi-region setup the server is part of, if any
+	Regions *Regions
+	// TLSPolicies are the tls policies of the configured listeners
+	TLSPolicies map[string]TLSPolicy
+	// Tenants are the customers of the server authenticating with their own
+	// token and whose payloads are answered with their own content, if any
+	Tenants *Tenants
+	// EgressCap is the maximum MB served in total after which static files
+	// and dynamic responses are no longer served (zero disables the cap)
+	EgressCap int
+	// SessionEgressCap is the maximum MB served per session after which static
+	// files and dynamic responses are no longer served for it (zero disables the cap)
+	SessionEgressCap int
+	// ResponseFuzz varies the latency and size of the http and dns responses to the payloads, if any
+	ResponseFuzz *ResponseFuzz
+	// BodyCaptureSize is the maximum size in KB of request bodies stored, larger
+	// bodies are hashed and truncated (zero stores bodies in full)
+	BodyCaptureSize int
+	// BodyStore keeps on disk the http bodies larger than the capture size, nil when disabled
+	BodyStore *BodyStore
+	// NAT64Prefixes are the NAT64 prefixes recognized in remote addresses
+	// in addition to the well-known ones
+	NAT64Prefixes []*net.IPNet
+	// LDAPEntry is the entry returned to ldap search requests, DefaultLDAPEntry if nil
+	LDAPEntry *LDAPEntry
+	// LDAPExploitMode is the jndi response to searches for correlation ids (reference
+	// or serialized), searches are answered with LDAPEntry if empty
+	LDAPExploitMode string
+	// DNSExfil enables the reassembly of data exfiltrated in chunks across dns queries
+	DNSExfil bool
+	// AdminSocket is the unix socket path the admin api is served on without authentication
+	AdminSocket string
+	// Config is the sanitized server configuration returned by the admin api
+	Config interface{}
+	// OnReload is called to reload the server options through the admin api, if set
+	OnReload func() (*ReloadResponse, error)
+	// reloadMu guards the options changed by a reload (token, wildcard, http responses and dns records)
+	reloadMu sync.RWMutex
+	// rebinds are the lookups of the rebinding names shared by the dns servers
+	rebinds rebindState
+	// dnsRecords are the custom dns records loaded from CustomRecords
+	dnsRecords *customDNSRecords
+	// Listeners are the sockets inherited from systemd or bound before dropping
+	// privileges, servers listen themselves on the other addresses
+	Listeners *Listeners
+	// Workers is the number of sockets and serving loops of the dns and http
+	// servers on their ports, sharing them with SO_REUSEPORT
+	Workers int
 
 	ACMEStore *acme.Provider
 	Stats     *Metrics
@@ -114,6 +457,8 @@ type Options struct {
 
 	Certificates []tls.Certificate
 	CertFiles    []acme.CertificateFiles
+	// CACertificate is the PEM encoded self-signed certificate authority offered for download
+	CACertificate []byte
 }
 type OnResultCallback func(out interface{})
 
@@ -1,14 +1,52 @@
 package server
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/projectdiscovery/interactsh/pkg/settings"
+	"github.com/projectdiscovery/interactsh/pkg/storage"
 	"github.com/stretchr/testify/require"
 )
 
+// newTestOptions returns the options of a server for the interact.sh domain
+// backed by an in-memory storage, with the correlation ids registered
+func newTestOptions(t *testing.T, correlationIDs ...string) *Options {
+	t.Helper()
+	store, err := storage.New(&storage.DefaultOptions)
+	require.Nil(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+	for _, correlationID := range correlationIDs {
+		require.Nil(t, store.SetID(correlationID))
+	}
+	return &Options{
+		Domains:                  []string{"interact.sh"},
+		CorrelationIdLength:      20,
+		CorrelationIdNonceLength: 13,
+		Stats:                    &Metrics{},
+		Storage:                  store,
+	}
+}
+
 func TestGetURLIDComponent(t *testing.T) {
 	options := Options{CorrelationIdLength: settings.CorrelationIdLengthDefault, CorrelationIdNonceLength: settings.CorrelationIdNonceLengthDefault}
 	random := options.getURLIDComponent("c6rj61aciaeutn2ae680cg5ugboyyyyyn.interactsh.com")
 	require.Equal(t, "c6rj61aciaeutn2ae680cg5ugboyyyyyn", random, "could not get correct component")
 }
+
+func TestCorrelationIDChecksum(t *testing.T) {
+	options := Options{CorrelationIdLength: settings.CorrelationIdLengthDefault, CorrelationIdNonceLength: settings.CorrelationIdNonceLengthDefault}
+	id := "c6rj61aciaeutn2ae680cg5ugboyyyyy"
+	valid := id + string(CorrelationIDChecksum(id))
+	invalid := id + "n"
+	if valid == invalid {
+		invalid = id + "y"
+	}
+	require.True(t, options.isCorrelationID(invalid))
+
+	options.CorrelationIdChecksum = true
+	require.True(t, options.isCorrelationID(valid))
+	// resolvers randomizing the case of names don't break the checksum
+	require.True(t, options.isCorrelationID(strings.ToUpper(valid)))
+	require.False(t, options.isCorrelationID(invalid))
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+)
+
+// WebhookSignature is the signature of a webhook following a vendor scheme
+// (github, stripe or slack). The server can't verify it without the secret of
+// the webhook, the clients holding it do.
+type WebhookSignature struct {
+	// Vendor is the signature scheme of the webhook (github, stripe or slack)
+	Vendor string `json:"vendor"`
+	// Header is the header holding the signature
+	Header string `json:"header"`
+	// Algorithm is the hmac hash function (sha1 or sha256)
+	Algorithm string `json:"algorithm"`
+	// Timestamp is the signed timestamp of the stripe and slack schemes
+	Timestamp string `json:"timestamp,omitempty"`
+	// Signatures are the hex encoded signatures, stripe sends one per active secret
+	Signatures []string `json:"signatures"`
+	// Verified reports whether a secret of the client matched the signature,
+	// it is only set by clients verifying webhooks
+	Verified *bool `json:"verified,omitempty"`
+	// Secret is the name of the client secret matching the signature
+	Secret string `json:"secret,omitempty"`
+}
+
+// parseWebhookSignature returns the signature of a webhook request from the
+// headers of the supported vendors, the sha256 github header being preferred
+func parseWebhookSignature(r *http.Request) *WebhookSignature {
+	if value := r.Header.Get("X-Hub-Signature-256"); value != "" {
+		return &WebhookSignature{Vendor: "github", Header: "X-Hub-Signature-256", Algorithm: "sha256", Signatures: []string{strings.TrimPrefix(value, "sha256=")}}
+	}
+	if value := r.Header.Get("X-Hub-Signature"); value != "" {
+		return &WebhookSignature{Vendor: "github", Header: "X-Hub-Signature", Algorithm: "sha1", Signatures: []string{strings.TrimPrefix(value, "sha1=")}}
+	}
+	if value := r.Header.Get("Stripe-Signature"); value != "" {
+		signature := &WebhookSignature{Vendor: "stripe", Header: "Stripe-Signature", Algorithm: "sha256"}
+		// t=timestamp,v1=signature[,v1=signature],v0=test signature
+		for _, item := range strings.Split(value, ",") {
+			key, val, _ := strings.Cut(strings.TrimSpace(item), "=")
+			switch key {
+			case "t":
+				signature.Timestamp = val
+			case "v1":
+				signature.Signatures = append(signature.Signatures, val)
+			}
+		}
+		return signature
+	}
+	if value := r.Header.Get("X-Slack-Signature"); value != "" {
+		return &WebhookSignature{Vendor: "slack", Header: "X-Slack-Signature", Algorithm: "sha256", Timestamp: r.Header.Get("X-Slack-Request-Timestamp"), Signatures: []string{strings.TrimPrefix(value, "v0=")}}
+	}
+	return nil
+}
+
+// Verify reports whether the signature of the webhook body was computed with the secret
+func (s *WebhookSignature) Verify(body []byte, secret string) bool {
+	var newHash func() hash.Hash
+	switch s.Algorithm {
+	case "sha1":
+		newHash = sha1.New
+	case "sha256":
+		newHash = sha256.New
+	default:
+		return false
+	}
+	mac := hmac.New(newHash, []byte(secret))
+	switch s.Vendor {
+	case "stripe":
+		mac.Write([]byte(s.Timestamp + "."))
+	case "slack":
+		mac.Write([]byte("v0:" + s.Timestamp + ":"))
+	}
+	mac.Write(body)
+	expected := mac.Sum(nil)
+	for _, signature := range s.Signatures {
+		if decoded, err := hex.DecodeString(signature); err == nil && hmac.Equal(decoded, expected) {
+			return true
+		}
+	}
+	return false
+}
+
+// marker returns the description of the webhook signature appended to the raw request
+func (s *WebhookSignature) marker() string {
+	if s == nil {
+		return ""
+	}
+	marker := fmt.Sprintf("\n\nWebhook Signature: vendor=%s header=%s algorithm=%s signatures=%s", s.Vendor, s.Header, s.Algorithm, strings.Join(s.Signatures, ","))
+	if s.Timestamp != "" {
+		marker += " timestamp=" + s.Timestamp
+	}
+	return marker + "\n"
+}
+
+// apply sets the webhook signature of the interaction
+func (s *WebhookSignature) apply(interaction *Interaction) {
+	if s == nil {
+		return
+	}
+	interaction.WebhookSignature = s
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookSignature(t *testing.T) {
+	// example of the github documentation
+	request := httptest.NewRequest(http.MethodPost, "http://interact.sh/", strings.NewReader("Hello, World!"))
+	request.Header.Set("X-Hub-Signature", "sha1=01dc10d0c83e72ed246219cdd91669667fe2ca59")
+	request.Header.Set("X-Hub-Signature-256", "sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17")
+	signature := parseWebhookSignature(request)
+	require.NotNil(t, signature)
+	require.Equal(t, "github", signature.Vendor)
+	require.Equal(t, "sha256", signature.Algorithm)
+	require.True(t, signature.Verify([]byte("Hello, World!"), "It's a Secret to Everybody"))
+	require.False(t, signature.Verify([]byte("Hello, World!"), "another secret"))
+	require.Contains(t, signature.marker(), "vendor=github header=X-Hub-Signature-256")
+
+	request = httptest.NewRequest(http.MethodPost, "http://interact.sh/", nil)
+	request.Header.Set("Stripe-Signature", "t=1492774577,v1=5257a869e7ecebeda32affa62cdca3fa51cad7e77a0e56ff536d0ce8e108d8bd,v0=6ffbb59b2300aae63f272406069a9788598b792a944a07aba816edb039989a39")
+	signature = parseWebhookSignature(request)
+	require.Equal(t, "1492774577", signature.Timestamp)
+	require.Equal(t, []string{"5257a869e7ecebeda32affa62cdca3fa51cad7e77a0e56ff536d0ce8e108d8bd"}, signature.Signatures)
+
+	request = httptest.NewRequest(http.MethodPost, "http://interact.sh/", nil)
+	request.Header.Set("X-Slack-Request-Timestamp", "1531420618")
+	request.Header.Set("X-Slack-Signature", "v0=a2114d57b48eac39b9ad189dd8316235a7b4a8d21a10bd27519666489c69b503")
+	signature = parseWebhookSignature(request)
+	// example of the slack documentation
+	require.True(t, signature.Verify([]byte("token=xyzz0WbapA4vBCDEFasx0q6G&team_id=T1DC2JH3J&team_domain=testteamnow&channel_id=G8PSS9T3V&channel_name=foobar&user_id=U2CERLKJA&user_name=roadrunner&command=%2Fwebhook-collect&text=&response_url=https%3A%2F%2Fhooks.slack.com%2Fcommands%2FT1DC2JH3J%2F397700885554%2F96rGlfmibIGlgcZRskXaIFfN&trigger_id=398738663015.47445629121.803a0bc887a14d10d2c447fce8b6703c"), "8f742231b10e8888abcd99yyyzzz85a5"))
+
+	require.Nil(t, parseWebhookSignature(httptest.NewRequest(http.MethodPost, "http://interact.sh/", nil)))
+}
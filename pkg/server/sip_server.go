@@ -0,0 +1,351 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/gologger"
+	stringsutil "github.com/projectdiscovery/utils/strings"
+)
+
+const (
+	// SIPServerAgent is the user agent reported by the sip server
+	SIPServerAgent = "Asterisk PBX 18.12.1"
+	// sipMaxMessage is the maximum size of a sip message
+	sipMaxMessage = 64 * 1024
+	// sipMaxLine is the maximum size of a sip request or header line
+	sipMaxLine = 8 * 1024
+	// sipTimeout is the idle time after which a sip tcp connection is closed
+	sipTimeout = 30 * time.Second
+	// sipAllow are the methods answered with 200 OK, the others with 405
+	sipAllow = "OPTIONS, REGISTER"
+)
+
+// sipCompactHeaders are the full names of the compact header forms
+var sipCompactHeaders = map[string]string{"v": "Via", "f": "From", "t": "To", "i": "Call-ID", "m": "Contact", "l": "Content-Length", "c": "Content-Type", "k": "Supported", "s": "Subject", "e": "Content-Encoding"}
+
+// sipHeader is a header of a sip message
+type sipHeader struct {
+	name  string
+	value string
+}
+
+// sipMessage is a sip request
+type sipMessage struct {
+	method     string
+	requestURI string
+	headers    []sipHeader
+	body       []byte
+	raw        []byte
+}
+
+// SIPServer is a sip server on udp and tcp answering OPTIONS and REGISTER
+// requests with 200 OK, and recording the requests whose Request-URI host
+// contains a correlation id
+type SIPServer struct {
+	options  *Options
+	listener net.Listener
+	conn     net.PacketConn
+	mu       sync.Mutex
+}
+
+// NewSIPServer returns a new sip server
+func NewSIPServer(options *Options) (*SIPServer, error) {
+	return &SIPServer{options: options}, nil
+}
+
+// ListenAndServe listens on the sip port with udp and tcp
+func (h *SIPServer) ListenAndServe(sipUdpAlive, sipTcpAlive chan bool) {
+	address := net.JoinHostPort(h.options.ListenIP, fmt.Sprint(h.options.SIPPort))
+	go func() {
+		if err := h.serveTCP(address, sipTcpAlive); err != nil && !errors.Is(err, net.ErrClosed) {
+			gologger.Error().Msgf("Could not serve sip with tcp on port %d: %s\n", h.options.SIPPort, err)
+			sipTcpAlive <- false
+		}
+	}()
+	if err := h.serveUDP(address, sipUdpAlive); err != nil && !errors.Is(err, net.ErrClosed) {
+		gologger.Error().Msgf("Could not serve sip with udp on port %d: %s\n", h.options.SIPPort, err)
+		sipUdpAlive <- false
+	}
+}
+
+func (h *SIPServer) serveUDP(address string, sipUdpAlive chan bool) error {
+	conn, err := h.options.Listeners.ListenPacket("udp", address)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	h.conn = conn
+	h.mu.Unlock()
+	sipUdpAlive <- true
+
+	buffer := make([]byte, sipMaxMessage)
+	for {
+		n, addr, err := conn.ReadFrom(buffer)
+		if err != nil {
+			return err
+		}
+		h.options.Stats.RecordSource("sip", addr.String())
+		message, err := readSIPMessage(bufio.NewReaderSize(bytes.NewReader(buffer[:n]), sipMaxLine))
+		if err != nil || message == nil {
+			continue
+		}
+		response := message.response()
+		if response != nil {
+			_, _ = conn.WriteTo(response, addr)
+		}
+		h.recordInteraction(addr, "udp", message, response)
+	}
+}
+
+func (h *SIPServer) serveTCP(address string, sipTcpAlive chan bool) error {
+	listener, err := h.options.Listeners.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	h.listener = listener
+	h.mu.Unlock()
+	sipTcpAlive <- true
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go h.handleConn(conn)
+	}
+}
+
+func (h *SIPServer) handleConn(conn net.Conn) {
+	defer conn.Close()
+
+	h.options.Stats.RecordSource("sip", conn.RemoteAddr().String())
+	reader := bufio.NewReaderSize(conn, sipMaxLine)
+	for {
+		_ = conn.SetDeadline(time.Now().Add(sipTimeout))
+		message, err := readSIPMessage(reader)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				gologger.Debug().Msgf("Sip connection from %s closed: %s\n", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		if message == nil {
+			continue
+		}
+		response := message.response()
+		if response != nil {
+			if _, err := conn.Write(response); err != nil {
+				return
+			}
+		}
+		h.recordInteraction(conn.RemoteAddr(), "tcp", message, response)
+	}
+}
+
+// readSIPMessage reads a sip request with its body, or returns a nil message
+// for keepalives and responses
+func readSIPMessage(reader *bufio.Reader) (*sipMessage, error) {
+	raw := &bytes.Buffer{}
+	line, err := readSIPLine(reader, raw)
+	if err != nil {
+		return nil, err
+	}
+	// keepalives are empty lines
+	if line == "" {
+		return nil, nil
+	}
+	parts := strings.SplitN(line, " ", 3)
+	response := strings.HasPrefix(line, "SIP/")
+	if !response && (len(parts) != 3 || !strings.HasPrefix(parts[2], "SIP/")) {
+		return nil, fmt.Errorf("invalid sip request line %q", line)
+	}
+	message := &sipMessage{}
+	if !response {
+		message.method, message.requestURI = strings.ToUpper(parts[0]), parts[1]
+	}
+	for {
+		line, err := readSIPLine(reader, raw)
+		if err != nil {
+			return nil, err
+		}
+		if line == "" {
+			break
+		}
+		// folded lines continue the previous header
+		if (line[0] == ' ' || line[0] == '\t') && len(message.headers) > 0 {
+			message.headers[len(message.headers)-1].value += " " + strings.TrimSpace(line)
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid sip header %q", line)
+		}
+		name = strings.TrimSpace(name)
+		if full, ok := sipCompactHeaders[strings.ToLower(name)]; ok {
+			name = full
+		}
+		message.headers = append(message.headers, sipHeader{name: name, value: strings.TrimSpace(value)})
+	}
+	if value := message.header("Content-Length"); value != "" {
+		length, err := strconv.Atoi(value)
+		if err != nil || length < 0 || raw.Len()+length > sipMaxMessage {
+			return nil, fmt.Errorf("invalid sip content length %q", value)
+		}
+		message.body = make([]byte, length)
+		if _, err := io.ReadFull(reader, message.body); err != nil {
+			return nil, err
+		}
+		raw.Write(message.body)
+	}
+	if response {
+		return nil, nil
+	}
+	message.raw = raw.Bytes()
+	return message, nil
+}
+
+// readSIPLine reads a line terminated by \r\n or \n, appended to the raw message
+func readSIPLine(reader *bufio.Reader, raw *bytes.Buffer) (string, error) {
+	line, err := reader.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return "", errors.New("sip line too long")
+	}
+	if err != nil {
+		return "", err
+	}
+	if raw.Len()+len(line) > sipMaxMessage {
+		return "", errors.New("sip message too long")
+	}
+	raw.Write(line)
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+// header returns the first value of a header
+func (m *sipMessage) header(name string) string {
+	for _, header := range m.headers {
+		if strings.EqualFold(header.name, name) {
+			return header.value
+		}
+	}
+	return ""
+}
+
+// response returns the response to the request, or nil for an ACK
+func (m *sipMessage) response() []byte {
+	code, reason := 200, "OK"
+	switch m.method {
+	case "ACK":
+		return nil
+	case "OPTIONS", "REGISTER":
+	default:
+		code, reason = 405, "Method Not Allowed"
+	}
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("SIP/2.0 %d %s\r\n", code, reason))
+	for _, header := range m.headers {
+		if strings.EqualFold(header.name, "Via") {
+			builder.WriteString("Via: " + header.value + "\r\n")
+		}
+	}
+	builder.WriteString("From: " + m.header("From") + "\r\n")
+	to := m.header("To")
+	if !strings.Contains(strings.ToLower(to), ";tag=") {
+		// the tag is derived from the call id so that retransmissions get the same response
+		hash := fnv.New32a()
+		_, _ = hash.Write([]byte(m.header("Call-ID")))
+		to += fmt.Sprintf(";tag=%08x", hash.Sum32())
+	}
+	builder.WriteString("To: " + to + "\r\n")
+	builder.WriteString("Call-ID: " + m.header("Call-ID") + "\r\n")
+	builder.WriteString("CSeq: " + m.header("CSeq") + "\r\n")
+	if contact := m.header("Contact"); m.method == "REGISTER" && contact != "" {
+		builder.WriteString("Contact: " + contact + ";expires=3600\r\n")
+	}
+	builder.WriteString("Allow: " + sipAllow + "\r\n")
+	builder.WriteString("Server: " + SIPServerAgent + "\r\n")
+	builder.WriteString("Content-Length: 0\r\n\r\n")
+	return []byte(builder.String())
+}
+
+// sipURIHost returns the host of a sip uri (eg. sip:user@host:5060;transport=tcp)
+func sipURIHost(uri string) string {
+	if scheme, rest, ok := strings.Cut(uri, ":"); ok && (strings.EqualFold(scheme, "sip") || strings.EqualFold(scheme, "sips")) {
+		uri = rest
+	}
+	if index := strings.IndexAny(uri, ";?"); index >= 0 {
+		uri = uri[:index]
+	}
+	if index := strings.LastIndex(uri, "@"); index >= 0 {
+		uri = uri[index+1:]
+	}
+	if host, _, err := net.SplitHostPort(uri); err == nil {
+		return host
+	}
+	return strings.Trim(uri, "[]")
+}
+
+// recordInteraction stores the request for each correlation id of its Request-URI host
+func (h *SIPServer) recordInteraction(remoteAddr net.Addr, transport string, message *sipMessage, response []byte) {
+	host := sipURIHost(message.requestURI)
+	gologger.Debug().Msgf("New SIP %s request for %s from %s over %s\n", message.method, message.requestURI, remoteAddr, transport)
+
+	ids := make(map[string]string)
+	for _, chunk := range stringsutil.SplitAny(host, ".") {
+		normalized := NormalizeHost(chunk)
+		for part := range stringsutil.SlideWithLength(normalized, h.options.GetIdLength()) {
+			if _, ok := ids[part]; !ok && h.options.isCorrelationID(part) {
+				ids[part] = normalized
+			}
+		}
+	}
+
+	remoteHost, _, _ := net.SplitHostPort(remoteAddr.String())
+	for uniqueID, fullID := range ids {
+		interaction := &Interaction{
+			Protocol:      "sip",
+			UniqueID:      uniqueID,
+			FullId:        fullID,
+			RawRequest:    string(message.raw),
+			RawResponse:   string(response),
+			RemoteAddress: remoteHost,
+			Timestamp:     time.Now(),
+			SIPMethod:     message.method,
+			SIPRequestURI: message.requestURI,
+			SIPTransport:  transport,
+		}
+		h.options.applyNAT64(interaction)
+		buffer := &bytes.Buffer{}
+		if err := jsoniter.NewEncoder(buffer).Encode(interaction); err != nil {
+			gologger.Warning().Msgf("Could not encode sip interaction: %s\n", err)
+			continue
+		}
+		gologger.Debug().Msgf("%s\n", buffer.String())
+		if err := h.options.Storage.AddInteraction(uniqueID[:h.options.CorrelationIdLength], buffer.Bytes()); err != nil {
+			gologger.Warning().Msgf("Could not store sip interaction: %s\n", err)
+		}
+	}
+}
+
+// Close stops the sip server
+func (h *SIPServer) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.listener != nil {
+		_ = h.listener.Close()
+	}
+	if h.conn != nil {
+		_ = h.conn.Close()
+	}
+}
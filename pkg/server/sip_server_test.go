@@ -0,0 +1,111 @@
+package server
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/interactsh/pkg/storage"
+	"github.com/stretchr/testify/require"
+)
+
+const sipTestRegister = "REGISTER sip:c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh SIP/2.0\r\n" +
+	"v: SIP/2.0/UDP 10.0.0.1:5060;branch=z9hG4bK776asdhds\r\n" +
+	"From: <sip:alice@interact.sh>;tag=1928301774\r\n" +
+	"To: <sip:alice@interact.sh>\r\n" +
+	"i: a84b4c76e66710\r\n" +
+	"CSeq: 1 REGISTER\r\n" +
+	"Contact: <sip:alice@10.0.0.1>\r\n" +
+	"l: 0\r\n\r\n"
+
+func TestReadSIPMessage(t *testing.T) {
+	message, err := readSIPMessage(bufio.NewReader(strings.NewReader(sipTestRegister)))
+	require.Nil(t, err)
+	require.Equal(t, "REGISTER", message.method)
+	require.Equal(t, "a84b4c76e66710", message.header("Call-ID"))
+	require.Equal(t, sipTestRegister, string(message.raw))
+
+	response := string(message.response())
+	require.True(t, strings.HasPrefix(response, "SIP/2.0 200 OK\r\nVia: SIP/2.0/UDP 10.0.0.1:5060;branch=z9hG4bK776asdhds\r\n"))
+	require.Contains(t, response, "To: <sip:alice@interact.sh>;tag=")
+	require.Contains(t, response, "Contact: <sip:alice@10.0.0.1>;expires=3600\r\n")
+
+	message, err = readSIPMessage(bufio.NewReader(strings.NewReader("INVITE sip:bob@interact.sh SIP/2.0\r\nCSeq: 1 INVITE\r\nContent-Length: 4\r\n\r\nv=0\n")))
+	require.Nil(t, err)
+	require.Equal(t, "v=0\n", string(message.body))
+	require.True(t, strings.HasPrefix(string(message.response()), "SIP/2.0 405 Method Not Allowed\r\n"))
+
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh", sipURIHost("sips:alice@c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh:5061;transport=tcp"))
+	require.Equal(t, "::1", sipURIHost("sip:[::1]:5060"))
+
+	_, err = readSIPMessage(bufio.NewReader(strings.NewReader("GET / HTTP/1.1\r\n\r\n")))
+	require.NotNil(t, err)
+}
+
+func TestSIPServer(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0")
+	store := options.Storage
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	conn, err := net.ListenPacket("udp", listener.Addr().String())
+	require.Nil(t, err)
+	listeners := NewListeners()
+	listeners.addListener(listener)
+	listeners.addPacketConn(conn)
+	options.ListenIP = "127.0.0.1"
+	options.SIPPort = listener.Addr().(*net.TCPAddr).Port
+	options.Listeners = listeners
+	server, err := NewSIPServer(options)
+	require.Nil(t, err)
+	udpAlive, tcpAlive := make(chan bool, 1), make(chan bool, 1)
+	go server.ListenAndServe(udpAlive, tcpAlive)
+	require.True(t, <-udpAlive)
+	require.True(t, <-tcpAlive)
+	defer server.Close()
+
+	client, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer client.Close()
+	_ = client.SetDeadline(time.Now().Add(5 * time.Second))
+	_, err = client.WriteTo([]byte(sipTestRegister), conn.LocalAddr())
+	require.Nil(t, err)
+	buffer := make([]byte, 1024)
+	n, _, err := client.ReadFrom(buffer)
+	require.Nil(t, err)
+	require.True(t, strings.HasPrefix(string(buffer[:n]), "SIP/2.0 200 OK\r\n"))
+
+	stream, err := net.Dial("tcp", listener.Addr().String())
+	require.Nil(t, err)
+	defer stream.Close()
+	_ = stream.SetDeadline(time.Now().Add(5 * time.Second))
+	_, err = stream.Write([]byte("\r\n\r\nOPTIONS sip:c59e3crp82ke7bcnedq0cfjqdpeyyyyzz.interact.sh SIP/2.0\r\nVia: SIP/2.0/TCP 10.0.0.1;branch=z9hG4bKx\r\nCall-ID: 1\r\nCSeq: 1 OPTIONS\r\nContent-Length: 0\r\n\r\n"))
+	require.Nil(t, err)
+	status, err := bufio.NewReader(stream).ReadString('\n')
+	require.Nil(t, err)
+	require.Equal(t, "SIP/2.0 200 OK\r\n", status)
+
+	var item *storage.CorrelationData
+	for i := 0; i < 50; i++ {
+		if item, err = store.GetCacheItem("c59e3crp82ke7bcnedq0"); err == nil && len(item.Data) == 2 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	require.Len(t, item.Data, 2)
+	interactions := make(map[string]*Interaction)
+	for _, data := range item.Data {
+		interaction := &Interaction{}
+		require.Nil(t, jsoniter.Unmarshal([]byte(data), interaction))
+		require.Equal(t, "sip", interaction.Protocol)
+		interactions[interaction.SIPTransport] = interaction
+	}
+	require.Equal(t, "REGISTER", interactions["udp"].SIPMethod)
+	require.Equal(t, sipTestRegister, interactions["udp"].RawRequest)
+	require.Equal(t, "OPTIONS", interactions["tcp"].SIPMethod)
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyzz", interactions["tcp"].FullId)
+	require.Equal(t, "sip:c59e3crp82ke7bcnedq0cfjqdpeyyyyzz.interact.sh", interactions["tcp"].SIPRequestURI)
+}
@@ -1,3 +1,5 @@
+//go:build !no_smb
+
 package server
 
 import (
@@ -7,7 +9,6 @@ import (
 	"os"
 	"os/exec"
 	"strings"
-	"sync/atomic"
 	"time"
 
 	jsoniter "github.com/json-iterator/go"
@@ -92,7 +93,8 @@ func (h *SMBServer) ListenAndServe(smbAlive chan bool) error {
 	// This fetches the content at each change.
 	go func() {
 		for data := range ch {
-			atomic.AddUint64(&h.options.Stats.Smb, 1)
+			h.options.UNCChains.recordSMBLog(data)
+			h.options.Stats.Record("smb")
 			for searchTerm, extractAfter := range smbMonitorList {
 				if strings.Contains(data, searchTerm) {
 					smbData, err := stringsutil.After(data, extractAfter)
@@ -112,7 +114,7 @@ func (h *SMBServer) ListenAndServe(smbAlive chan bool) error {
 						gologger.Warning().Msgf("Could not encode smb interaction: %s\n", err)
 					} else {
 						gologger.Debug().Msgf("SMB Interaction: \n%s\n", buffer.String())
-						if err := h.options.Storage.AddInteractionWithId(h.options.Token, buffer.Bytes()); err != nil {
+						if err := h.options.Storage.AddInteractionWithId(h.options.GetToken(), buffer.Bytes()); err != nil {
 							gologger.Warning().Msgf("Could not store dns interaction: %s\n", err)
 						}
 					}
@@ -125,7 +127,9 @@ func (h *SMBServer) ListenAndServe(smbAlive chan bool) error {
 }
 
 func (h *SMBServer) Close() {
-	_ = h.cmd.Process.Kill()
+	if h.cmd != nil && h.cmd.Process != nil {
+		_ = h.cmd.Process.Kill()
+	}
 	if fileutil.FileExists(h.tmpFile) {
 		os.RemoveAll(h.tmpFile)
 	}
@@ -0,0 +1,22 @@
+//go:build no_smb
+
+package server
+
+import "errors"
+
+// SMBServer is a placeholder for the smb server excluded by the no_smb build tag
+type SMBServer struct{}
+
+// NewSMBServer returns an error as smb support is not compiled in
+func NewSMBServer(options *Options) (*SMBServer, error) {
+	return nil, errors.New("smb support is not compiled in (no_smb build tag)")
+}
+
+// ListenAndServe is a no-op as smb support is not compiled in
+func (h *SMBServer) ListenAndServe(smbAlive chan bool) error {
+	smbAlive <- false
+	return nil
+}
+
+// Close is a no-op as smb support is not compiled in
+func (h *SMBServer) Close() {}
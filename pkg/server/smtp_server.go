@@ -3,10 +3,11 @@ package server
 import (
 	"bytes"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
 	"strings"
-	"sync/atomic"
+	"sync"
 	"time"
 
 	"git.mills.io/prologic/smtpd"
@@ -21,6 +22,8 @@ type SMTPServer struct {
 	options     *Options
 	smtpServer  smtpd.Server
 	smtpsServer smtpd.Server
+	listeners   []net.Listener
+	mu          sync.Mutex
 }
 
 // NewSMTPServer returns a new TLS & Non-TLS SMTP server.
@@ -52,18 +55,25 @@ func NewSMTPServer(options *Options) (*SMTPServer, error) {
 	return server, nil
 }
 
-// ListenAndServe listens on smtp and/or smtps ports for the server.
+// ListenAndServe listens on smtp and/or smtps ports for the server, the
+// plain ports offer STARTTLS and the autotls port implicit tls when a tls
+// configuration is available
 func (h *SMTPServer) ListenAndServe(tlsConfig *tls.Config, smtpAlive, smtpsAlive chan bool) {
+	if tlsConfig != nil {
+		tlsConfig = h.options.listenerTLSConfig("smtp", tlsConfig)
+		h.smtpServer.TLSConfig = tlsConfig
+		h.smtpsServer.TLSConfig = tlsConfig
+	}
 	go func() {
 		if tlsConfig == nil {
 			return
 		}
-		srv := &smtpd.Server{Addr: fmt.Sprintf("%s:%d", h.options.ListenIP, h.options.SmtpAutoTLSPort), Handler: h.defaultHandler, Appname: "interactsh", Hostname: h.options.Domains[0]}
+		srv := &smtpd.Server{Addr: fmt.Sprintf("%s:%d", h.options.ListenIP, h.options.SmtpAutoTLSPort), Handler: h.defaultHandler, Appname: "interactsh", Hostname: h.options.GetDomains()[0]}
 		srv.TLSConfig = tlsConfig
+		srv.TLSListener = true
 
 		smtpsAlive <- true
-		err := srv.ListenAndServe()
-		if err != nil {
+		if err := h.serve(srv); err != nil && !errors.Is(err, net.ErrClosed) {
 			gologger.Error().Msgf("Could not serve smtp with tls on port %d: %s\n", h.options.SmtpAutoTLSPort, err)
 			smtpsAlive <- false
 		}
@@ -71,43 +81,128 @@ func (h *SMTPServer) ListenAndServe(tlsConfig *tls.Config, smtpAlive, smtpsAlive
 
 	smtpAlive <- true
 	go func() {
-		if err := h.smtpServer.ListenAndServe(); err != nil {
+		if err := h.serve(&h.smtpServer); err != nil && !errors.Is(err, net.ErrClosed) {
 			smtpAlive <- false
 			gologger.Error().Msgf("Could not serve smtp on port %d: %s\n", h.options.SmtpPort, err)
 		}
 	}()
-	if err := h.smtpsServer.ListenAndServe(); err != nil {
+	if err := h.serve(&h.smtpsServer); err != nil && !errors.Is(err, net.ErrClosed) {
 		gologger.Error().Msgf("Could not serve smtp on port %d: %s\n", h.options.SmtpsPort, err)
 		smtpAlive <- false
 	}
 }
 
+// serve listens on the smtp server address, the connections received on a
+// tenant address are greeted with the banner of the tenant
+func (h *SMTPServer) serve(srv *smtpd.Server) error {
+	if srv.Timeout == 0 {
+		srv.Timeout = 5 * time.Minute
+	}
+	ln, err := h.options.Listeners.Listen("tcp", srv.Addr)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	h.listeners = append(h.listeners, ln)
+	h.mu.Unlock()
+	// the client hellos are recorded on implicit tls and starttls
+	ln = h.options.fingerprintListener(ln)
+	if srv.TLSConfig != nil && srv.TLSListener {
+		ln = tls.NewListener(ln, srv.TLSConfig)
+	}
+	if !h.options.Tenants.smtpBanners() {
+		return srv.Serve(ln)
+	}
+	defer ln.Close()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		server := *srv
+		if addr, ok := conn.LocalAddr().(*net.TCPAddr); ok {
+			if tenant := h.options.Tenants.byIP(addr.IP); tenant != nil && tenant.SMTPBanner != "" {
+				server.Appname = tenant.SMTPBanner
+				if len(tenant.Domains) > 0 {
+					server.Hostname = tenant.Domains[0]
+				}
+			}
+		}
+		go func() {
+			_ = server.Serve(&connListener{conn: conn})
+		}()
+	}
+}
+
+// Close stops the smtp servers
+func (h *SMTPServer) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, listener := range h.listeners {
+		_ = listener.Close()
+	}
+}
+
+// connListener is a listener returning a single accepted connection
+type connListener struct {
+	conn     net.Conn
+	accepted bool
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	if l.accepted {
+		return nil, net.ErrClosed
+	}
+	l.accepted = true
+	return l.conn, nil
+}
+
+// Close leaves the connection to the smtp session
+func (l *connListener) Close() error {
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
+
 // defaultHandler is a handler for default collaborator requests
 func (h *SMTPServer) defaultHandler(remoteAddr net.Addr, from string, to []string, data []byte) error {
-	atomic.AddUint64(&h.options.Stats.Smtp, 1)
+	h.options.Stats.RecordSource("smtp", remoteAddr.String())
 
-	var uniqueID, fullID string
+	var uniqueID, fullID, original, arrivalDomain string
 
-	dataString := string(data)
-	gologger.Debug().Msgf("New SMTP request: %s %s %s %s\n", remoteAddr, from, to, dataString)
+	gologger.Debug().Msgf("New SMTP request: %s %s %s %s\n", remoteAddr, from, to, string(data))
+	capture := newBodyCapture(h.options.captureLimit())
+	_, _ = capture.Write(data)
+	sniff := sniffMail(capture.Bytes())
+	dataString := string(capture.Bytes()) + capture.marker() + sniff.marker()
+	clientTimestamp := smtpClientTimestamp(data)
+	fingerprint := h.options.tlsFingerprint(remoteAddr.String())
 
 	// if root-tld is enabled stores any interaction towards the main domain
 	for _, addr := range to {
-		if h.options.RootTLD {
-			for _, domain := range h.options.Domains {
+		if h.options.isRootTLD() {
+			for _, domain := range h.options.GetDomains() {
 				if stringsutil.HasSuffixI(addr, domain) {
 					ID := domain
 					host, _, _ := net.SplitHostPort(remoteAddr.String())
 					address := addr[strings.LastIndex(addr, "@"):]
 					interaction := &Interaction{
-						Protocol:      "smtp",
-						UniqueID:      address,
-						FullId:        address,
-						RawRequest:    dataString,
-						SMTPFrom:      from,
-						RemoteAddress: host,
-						Timestamp:     time.Now(),
+						Protocol:        "smtp",
+						UniqueID:        address,
+						FullId:          address,
+						RawRequest:      dataString,
+						SMTPFrom:        from,
+						RemoteAddress:   host,
+						Timestamp:       time.Now(),
+						ClientTimestamp: clientTimestamp,
+						Domain:          domain,
 					}
+					capture.apply(interaction)
+					sniff.apply(interaction)
+					fingerprint.apply(interaction)
+					h.options.applyNAT64(interaction)
 					buffer := &bytes.Buffer{}
 					if err := jsoniter.NewEncoder(buffer).Encode(interaction); err != nil {
 						gologger.Warning().Msgf("Could not encode root tld SMTP interaction: %s\n", err)
@@ -124,11 +219,15 @@ func (h *SMTPServer) defaultHandler(remoteAddr net.Addr, from string, to []strin
 
 	for _, addr := range to {
 		if len(addr) > h.options.GetIdLength() && strings.Contains(addr, "@") {
-			parts := strings.Split(addr[strings.LastIndex(addr, "@")+1:], ".")
+			domain := addr[strings.LastIndex(addr, "@")+1:]
+			normalized := NormalizeHost(domain)
+			parts := strings.Split(normalized, ".")
 			for i, part := range parts {
 				if h.options.isCorrelationID(part) {
 					uniqueID = part
 					fullID = part
+					original = originalHost(domain, normalized)
+					arrivalDomain = h.options.domainOf(normalized)
 					if i+1 <= len(parts) {
 						fullID = strings.Join(parts[:i+1], ".")
 					}
@@ -141,14 +240,21 @@ func (h *SMTPServer) defaultHandler(remoteAddr net.Addr, from string, to []strin
 
 		correlationID := uniqueID[:h.options.CorrelationIdLength]
 		interaction := &Interaction{
-			Protocol:      "smtp",
-			UniqueID:      uniqueID,
-			FullId:        fullID,
-			RawRequest:    dataString,
-			SMTPFrom:      from,
-			RemoteAddress: host,
-			Timestamp:     time.Now(),
+			Protocol:        "smtp",
+			UniqueID:        uniqueID,
+			FullId:          fullID,
+			RawRequest:      dataString,
+			SMTPFrom:        from,
+			RemoteAddress:   host,
+			Timestamp:       time.Now(),
+			ClientTimestamp: clientTimestamp,
+			Domain:          arrivalDomain,
+			OriginalHost:    original,
 		}
+		capture.apply(interaction)
+		sniff.apply(interaction)
+		fingerprint.apply(interaction)
+		h.options.applyNAT64(interaction)
 		buffer := &bytes.Buffer{}
 		if err := jsoniter.NewEncoder(buffer).Encode(interaction); err != nil {
 			gologger.Warning().Msgf("Could not encode smtp interaction: %s\n", err)
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"crypto/tls"
+	"net"
+	"net/smtp"
+	"strings"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSMTPServerImplicitTLS(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0")
+	store := options.Storage
+
+	listeners := NewListeners()
+	ports := make([]int, 3)
+	addresses := make([]string, 3)
+	for i := range ports {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.Nil(t, err)
+		listeners.addListener(listener)
+		ports[i] = listener.Addr().(*net.TCPAddr).Port
+		addresses[i] = listener.Addr().String()
+	}
+	options.ListenIP = "127.0.0.1"
+	options.SmtpPort = ports[0]
+	options.SmtpsPort = ports[1]
+	options.SmtpAutoTLSPort = ports[2]
+	options.Listeners = listeners
+	certificate, err := localhostCertificate()
+	require.Nil(t, err)
+	server, err := NewSMTPServer(options)
+	require.Nil(t, err)
+	alive, tlsAlive := make(chan bool, 2), make(chan bool, 1)
+	go server.ListenAndServe(&tls.Config{Certificates: []tls.Certificate{certificate}}, alive, tlsAlive)
+	require.True(t, <-alive)
+	require.True(t, <-tlsAlive)
+
+	send := func(client *smtp.Client, to string) {
+		require.Nil(t, client.Mail("sender@example.com"))
+		require.Nil(t, client.Rcpt(to))
+		writer, err := client.Data()
+		require.Nil(t, err)
+		_, err = writer.Write([]byte("Subject: test\r\n\r\nbody\r\n"))
+		require.Nil(t, err)
+		require.Nil(t, writer.Close())
+		_ = client.Quit()
+	}
+
+	// implicit tls on the autotls port
+	conn, err := tls.Dial("tcp", addresses[2], &tls.Config{InsecureSkipVerify: true})
+	require.Nil(t, err)
+	client, err := smtp.NewClient(conn, "interact.sh")
+	require.Nil(t, err)
+	require.Nil(t, client.Hello("localhost"))
+	send(client, "user@c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh")
+
+	// starttls on the plain port
+	client, err = smtp.Dial(addresses[0])
+	require.Nil(t, err)
+	require.Nil(t, client.Hello("localhost"))
+	ok, _ := client.Extension("STARTTLS")
+	require.True(t, ok)
+	require.Nil(t, client.StartTLS(&tls.Config{InsecureSkipVerify: true}))
+	send(client, "user@c59e3crp82ke7bcnedq0cfjqdpeyyyyzz.interact.sh")
+
+	item, err := store.GetCacheItem("c59e3crp82ke7bcnedq0")
+	require.Nil(t, err)
+	require.Len(t, item.Data, 2)
+	var fullIDs []string
+	for _, data := range item.Data {
+		interaction := &Interaction{}
+		require.Nil(t, jsoniter.Unmarshal([]byte(data), interaction))
+		require.Equal(t, "smtp", interaction.Protocol)
+		require.Equal(t, "sender@example.com", interaction.SMTPFrom)
+		require.True(t, strings.Contains(interaction.RawRequest, "body"))
+		fullIDs = append(fullIDs, interaction.FullId)
+	}
+	require.ElementsMatch(t, []string{"c59e3crp82ke7bcnedq0cfjqdpeyyyyyy", "c59e3crp82ke7bcnedq0cfjqdpeyyyyzz"}, fullIDs)
+}
@@ -0,0 +1,271 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// MaxBodyPreview is the number of characters of the preview of a body
+var MaxBodyPreview = 160
+
+var (
+	// phpSerialized matches the start of a php serialized value
+	phpSerialized = regexp.MustCompile(`^(?:[aOC]:\d+:|s:\d+:"|i:-?\d+;|b:[01];|d:-?[\d.]+;|N;)`)
+	// base64Body matches a base64 (or base64url) encoded body without whitespace
+	base64Body = regexp.MustCompile(`^[A-Za-z0-9+/_-]{16,}={0,2}$`)
+	// smtpContentType matches the content type header of a mail
+	smtpContentType = regexp.MustCompile(`(?im)^content-type:\s*([^\r\n;]+)`)
+)
+
+// bodyMagics are the signatures of the binary formats sniffed from a body
+var bodyMagics = []struct {
+	kind   string
+	offset int
+	magic  string
+}{
+	{"java-serialized", 0, "\xac\xed\x00\x05"},
+	{"zip", 0, "PK\x03\x04"},
+	{"zip", 0, "PK\x05\x06"},
+	{"gzip", 0, "\x1f\x8b"},
+	{"bzip2", 0, "BZh"},
+	{"xz", 0, "\xfd7zXZ\x00"},
+	{"zstd", 0, "\x28\xb5\x2f\xfd"},
+	{"7z", 0, "7z\xbc\xaf\x27\x1c"},
+	{"rar", 0, "Rar!\x1a\x07"},
+	{"tar", 257, "ustar"},
+	{"python-pickle", 0, "\x80\x02"},
+	{"python-pickle", 0, "\x80\x03"},
+	{"python-pickle", 0, "\x80\x04"},
+	{"python-pickle", 0, "\x80\x05"},
+}
+
+// readableBodyTypes are the body types whose raw request is readable as is
+var readableBodyTypes = map[string]bool{"text": true, "json": true, "xml": true, "html": true, "form": true, "multipart": true}
+
+// bodySniff is the content type sniffed from a captured body, with its tags
+// and a printable preview for triage
+type bodySniff struct {
+	kind    string
+	tags    []string
+	preview string
+	// java are the classes of a java serialized body
+	java *javaStream
+}
+
+// sniffBody sniffs the type of a body from its content, the declared content
+// type only hinting formats without signature (protobuf, forms)
+func sniffBody(declared string, body []byte) *bodySniff {
+	return sniffBodyDepth(strings.ToLower(declared), body, 0)
+}
+
+func sniffBodyDepth(declared string, body []byte, depth int) *bodySniff {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	for _, signature := range bodyMagics {
+		if bytes.HasPrefix(body[min(signature.offset, len(body)):], []byte(signature.magic)) {
+			sniff := &bodySniff{kind: signature.kind, preview: binaryPreview(body)}
+			switch {
+			case signature.kind == "zip" && bytes.Contains(body, []byte("META-INF/")):
+				sniff.tags = append(sniff.tags, "jar")
+			case signature.kind == "java-serialized":
+				if sniff.java = parseJavaStream(body); sniff.java != nil && len(sniff.java.gadgets) > 0 {
+					sniff.tags = append(sniff.tags, "java-gadget")
+				}
+			}
+			return sniff
+		}
+	}
+	if strings.Contains(declared, "grpc") && len(body) > 5 && body[0] <= 1 {
+		// the length prefixed message of a grpc frame
+		body = body[5:]
+	}
+	if strings.Contains(declared, "protobuf") || strings.Contains(declared, "grpc") {
+		return &bodySniff{kind: "protobuf", preview: binaryPreview(body)}
+	}
+	if !isTextBody(body) {
+		if fields, err := parseProtoFields(body); err == nil && len(fields) > 0 && isProtobufLike(fields) {
+			return &bodySniff{kind: "protobuf", preview: binaryPreview(body)}
+		}
+		return &bodySniff{kind: "binary", preview: binaryPreview(body)}
+	}
+
+	trimmed := bytes.TrimSpace(body)
+	sniff := &bodySniff{kind: "text", preview: textPreview(trimmed)}
+	switch {
+	case (trimmed[0] == '{' || trimmed[0] == '[') && (jsoniter.Valid(trimmed) || strings.Contains(declared, "json")):
+		sniff.kind = "json"
+	case trimmed[0] == '<' && strings.HasPrefix(http.DetectContentType(trimmed), "text/html"):
+		sniff.kind = "html"
+	case trimmed[0] == '<' && isXMLBody(trimmed):
+		sniff.kind = "xml"
+	case phpSerialized.Match(trimmed):
+		sniff.kind = "php-serialized"
+	case strings.Contains(declared, "x-www-form-urlencoded"):
+		sniff.kind = "form"
+	case strings.HasPrefix(declared, "multipart/"):
+		sniff.kind = "multipart"
+	case depth == 0:
+		if decoded := decodeBase64Body(trimmed); decoded != nil {
+			inner := sniffBodyDepth("", decoded, depth+1)
+			if inner == nil || inner.kind == "text" || inner.kind == "binary" {
+				sniff.kind = "base64"
+				break
+			}
+			inner.tags = append([]string{"base64"}, inner.tags...)
+			return inner
+		}
+	}
+	return sniff
+}
+
+// isTextBody reports whether a body is printable utf-8 text, ignoring a rune
+// cut by the capture limit
+func isTextBody(body []byte) bool {
+	for i := 0; i < utf8.UTFMax && len(body) > 0 && !utf8.Valid(body); i++ {
+		body = body[:len(body)-1]
+	}
+	if !utf8.Valid(body) {
+		return false
+	}
+	for _, r := range string(body) {
+		if r < ' ' && r != '\t' && r != '\r' && r != '\n' && r != '\f' {
+			return false
+		}
+	}
+	return true
+}
+
+// isXMLBody reports whether a body starts with an xml declaration, directive or element
+func isXMLBody(body []byte) bool {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	token, err := decoder.RawToken()
+	if err != nil {
+		return false
+	}
+	switch token.(type) {
+	case xml.ProcInst, xml.Directive, xml.StartElement:
+		return true
+	}
+	return false
+}
+
+// isProtobufLike reports whether decoded protobuf fields have the small field
+// numbers of actual messages rather than random binary data
+func isProtobufLike(fields []protoField) bool {
+	for _, field := range fields {
+		if field.number > 1000 {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeBase64Body returns the decoded base64 body, nil if not base64
+func decodeBase64Body(body []byte) []byte {
+	compact := strings.Join(strings.Fields(string(body)), "")
+	if !base64Body.MatchString(compact) {
+		return nil
+	}
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		if decoded, err := encoding.DecodeString(compact); err == nil {
+			return decoded
+		}
+	}
+	return nil
+}
+
+// textPreview returns the start of a text body with the control characters
+// (eg. terminal escapes) replaced
+func textPreview(body []byte) string {
+	var builder strings.Builder
+	count := 0
+	for _, r := range string(body) {
+		if count == MaxBodyPreview {
+			builder.WriteString("...")
+			break
+		}
+		switch {
+		case r == '\n' || r == '\r' || r == '\t':
+			builder.WriteRune(' ')
+		case r == utf8.RuneError || !unicode.IsPrint(r):
+			builder.WriteRune('.')
+		default:
+			builder.WriteRune(r)
+		}
+		count++
+	}
+	return builder.String()
+}
+
+// binaryPreview returns the printable strings of a binary body, or the hex
+// encoding of its start if it has none
+func binaryPreview(body []byte) string {
+	var values []string
+	length := 0
+	for _, value := range bytes.FieldsFunc(body, func(r rune) bool { return r < ' ' || r > '~' }) {
+		if len(value) < 4 {
+			continue
+		}
+		values = append(values, string(value))
+		if length += len(value) + 1; length > MaxBodyPreview {
+			break
+		}
+	}
+	if len(values) == 0 {
+		return fmt.Sprintf("% x", body[:min(len(body), MaxBodyPreview/3)])
+	}
+	preview := strings.Join(values, " ")
+	if len(preview) > MaxBodyPreview {
+		preview = preview[:MaxBodyPreview] + "..."
+	}
+	return preview
+}
+
+// sniffMail sniffs the body of a mail, after its headers
+func sniffMail(data []byte) *bodySniff {
+	headers, body, ok := bytes.Cut(data, []byte("\r\n\r\n"))
+	if !ok {
+		headers, body, ok = bytes.Cut(data, []byte("\n\n"))
+	}
+	if !ok {
+		return nil
+	}
+	var declared string
+	if match := smtpContentType.FindSubmatch(headers); match != nil {
+		declared = string(match[1])
+	}
+	return sniffBody(declared, body)
+}
+
+// marker describes the unreadable bodies (serialized, archived or binary) in the raw request
+func (s *bodySniff) marker() string {
+	if s == nil || readableBodyTypes[s.kind] {
+		return ""
+	}
+	marker := fmt.Sprintf("\n\nBody Type: %s", s.kind)
+	if len(s.tags) > 0 {
+		marker += fmt.Sprintf(" (%s)", strings.Join(s.tags, ", "))
+	}
+	return marker + fmt.Sprintf("\nBody Preview: %s\n", s.preview) + s.java.marker()
+}
+
+// apply sets the body fields of the interaction
+func (s *bodySniff) apply(interaction *Interaction) {
+	if s == nil {
+		return
+	}
+	interaction.BodyType = s.kind
+	interaction.BodyTags = s.tags
+	interaction.BodyPreview = s.preview
+	s.java.apply(interaction)
+}
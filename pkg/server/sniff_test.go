@@ -0,0 +1,57 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSniffBody(t *testing.T) {
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	_, _ = writer.Write([]byte("payload"))
+	_ = writer.Close()
+	javaObject := append([]byte("\xac\xed\x00\x05\x73"), javaClassDescriptor("org.apache.commons.collections.map.LazyMap")...)
+
+	tests := []struct {
+		declared string
+		body     []byte
+		kind     string
+		tags     []string
+	}{
+		{"application/json", []byte(`{"a": [1, 2]}`), "json", nil},
+		{"", []byte(`<?xml version="1.0"?><a/>`), "xml", nil},
+		{"", []byte(`<html><body>x</body></html>`), "html", nil},
+		{"", []byte(`O:8:"stdClass":0:{}`), "php-serialized", nil},
+		{"application/x-www-form-urlencoded", []byte("a=1&b=2"), "form", nil},
+		{"", javaObject, "java-serialized", []string{"java-gadget"}},
+		{"", []byte(base64.StdEncoding.EncodeToString(javaObject)), "java-serialized", []string{"base64", "java-gadget"}},
+		{"", []byte("PK\x03\x04\x14\x00META-INF/MANIFEST.MF"), "zip", []string{"jar"}},
+		{"", compressed.Bytes(), "gzip", nil},
+		{"application/x-protobuf", []byte("\x0a\x03abc"), "protobuf", nil},
+		{"", []byte("\x0a\x05hello\x10\x96\x01"), "protobuf", nil},
+		{"", []byte("hello world"), "text", nil},
+	}
+	for _, test := range tests {
+		sniff := sniffBody(test.declared, test.body)
+		require.NotNil(t, sniff, test.kind)
+		require.Equal(t, test.kind, sniff.kind, string(test.body))
+		require.Equal(t, test.tags, sniff.tags, test.kind)
+	}
+	require.Nil(t, sniffBody("", []byte(" \r\n")))
+
+	// previews don't carry terminal escapes
+	sniff := sniffBody("", []byte("\x1b[31mred\x1b[0m"))
+	require.Equal(t, "binary", sniff.kind)
+	require.NotContains(t, sniff.preview, "\x1b")
+	require.Equal(t, "ok . done", sniffBody("", []byte("ok \u200b done")).preview)
+	require.Contains(t, sniffBody("", javaObject).marker(), "Body Type: java-serialized (java-gadget)")
+	require.Equal(t, []string{"CommonsCollections"}, sniffBody("", javaObject).java.gadgets)
+	require.Empty(t, sniffBody("", []byte(`{}`)).marker())
+
+	mail := sniffMail([]byte("From: a@b\r\nContent-Type: application/json; charset=utf-8\r\n\r\n{\"x\":1}"))
+	require.Equal(t, "json", mail.kind)
+}
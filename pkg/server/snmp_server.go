@@ -0,0 +1,382 @@
+package server
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/gologger"
+	stringsutil "github.com/projectdiscovery/utils/strings"
+)
+
+// snmp pdu types (context specific tags)
+const (
+	snmpGetRequest = iota
+	snmpGetNextRequest
+	snmpResponse
+	snmpSetRequest
+	snmpTrapV1
+	snmpGetBulkRequest
+	snmpInformRequest
+	snmpTrapV2
+	snmpReport
+)
+
+var (
+	snmpPDUNames     = []string{"get-request", "get-next-request", "response", "set-request", "trap", "get-bulk-request", "inform-request", "trap-v2", "report"}
+	snmpVersionNames = map[int]string{0: "v1", 1: "v2c"}
+)
+
+// snmp v1 error status of unknown oids
+const snmpNoSuchName = 2
+
+// SNMPVarbind is a variable binding of a snmp message
+type SNMPVarbind struct {
+	// OID is the object identifier of the variable
+	OID string `json:"oid"`
+	// Type is the type of the value (eg. octet-string, integer, timeticks)
+	Type string `json:"type"`
+	// Value is the value, hex encoded for binary octet strings
+	Value string `json:"value,omitempty"`
+}
+
+// SNMPServer is a snmp agent and trap receiver recording the v1 and v2c
+// messages it receives, the correlation ids are searched in the community
+// and the string values. Get requests are answered with unknown objects.
+type SNMPServer struct {
+	options *Options
+	conns   []net.PacketConn
+	mu      sync.Mutex
+}
+
+// snmpMessage is a decoded v1 or v2c message
+type snmpMessage struct {
+	version   int
+	community string
+	pduType   int
+	requestID int
+	varbinds  []snmpRawVarbind
+	// enterprise, agent address and trap types of v1 traps
+	trap string
+}
+
+// snmpRawVarbind is a variable binding as encoded on the wire
+type snmpRawVarbind struct {
+	Name  asn1.ObjectIdentifier
+	Value asn1.RawValue
+}
+
+// NewSNMPServer returns a new snmp server
+func NewSNMPServer(options *Options) (*SNMPServer, error) {
+	return &SNMPServer{options: options}, nil
+}
+
+// ListenAndServe listens on the snmp agent and trap ports
+func (h *SNMPServer) ListenAndServe(snmpAlive, snmpTrapAlive chan bool) {
+	var wg sync.WaitGroup
+	for _, listen := range []struct {
+		port  int
+		alive chan bool
+	}{{h.options.SNMPPort, snmpAlive}, {h.options.SNMPTrapPort, snmpTrapAlive}} {
+		conn, err := h.options.Listeners.ListenPacket("udp", net.JoinHostPort(h.options.ListenIP, fmt.Sprint(listen.port)))
+		if err != nil {
+			gologger.Error().Msgf("Could not serve snmp on port %d: %s\n", listen.port, err)
+			listen.alive <- false
+			continue
+		}
+		h.mu.Lock()
+		h.conns = append(h.conns, conn)
+		h.mu.Unlock()
+		listen.alive <- true
+		wg.Add(1)
+		go func(conn net.PacketConn, port int) {
+			defer wg.Done()
+			h.serve(conn, port)
+		}(conn, listen.port)
+	}
+	wg.Wait()
+}
+
+func (h *SNMPServer) serve(conn net.PacketConn, port int) {
+	buffer := make([]byte, udpMaxDatagram)
+	for {
+		n, addr, err := conn.ReadFrom(buffer)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				gologger.Error().Msgf("Could not read snmp message on port %d: %s\n", port, err)
+			}
+			return
+		}
+		h.options.Stats.RecordSource("snmp", addr.String())
+		message, err := parseSNMPMessage(buffer[:n])
+		if err != nil {
+			gologger.Debug().Msgf("Invalid snmp message from %s: %s\n", addr, err)
+			continue
+		}
+		h.recordInteraction(addr, port, message, buffer[:n])
+		if response := message.response(); response != nil {
+			_, _ = conn.WriteTo(response, addr)
+		}
+	}
+}
+
+// parseSNMPMessage decodes a v1 or v2c message
+func parseSNMPMessage(data []byte) (*snmpMessage, error) {
+	var envelope struct {
+		Version   int
+		Community []byte
+		PDU       asn1.RawValue
+	}
+	if _, err := asn1.Unmarshal(data, &envelope); err != nil {
+		return nil, err
+	}
+	if envelope.Version != 0 && envelope.Version != 1 {
+		return nil, fmt.Errorf("unsupported snmp version %d", envelope.Version)
+	}
+	pdu := envelope.PDU
+	if pdu.Class != asn1.ClassContextSpecific || !pdu.IsCompound || pdu.Tag >= len(snmpPDUNames) {
+		return nil, fmt.Errorf("unexpected snmp pdu tag %d", pdu.Tag)
+	}
+	message := &snmpMessage{version: envelope.Version, community: string(envelope.Community), pduType: pdu.Tag}
+
+	rest := pdu.Bytes
+	var err error
+	if message.pduType == snmpTrapV1 {
+		var trap struct {
+			Enterprise   asn1.ObjectIdentifier
+			AgentAddress asn1.RawValue
+			GenericTrap  int
+			SpecificTrap int
+			Timestamp    asn1.RawValue
+		}
+		if rest, err = unmarshalSNMPFields(rest, &trap.Enterprise, &trap.AgentAddress, &trap.GenericTrap, &trap.SpecificTrap, &trap.Timestamp); err != nil {
+			return nil, err
+		}
+		message.trap = fmt.Sprintf("enterprise=%s agent-address=%s generic-trap=%d specific-trap=%d", trap.Enterprise, formatSNMPValue(trap.AgentAddress), trap.GenericTrap, trap.SpecificTrap)
+	} else {
+		var errorStatus, errorIndex int
+		if rest, err = unmarshalSNMPFields(rest, &message.requestID, &errorStatus, &errorIndex); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := asn1.Unmarshal(rest, &message.varbinds); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// unmarshalSNMPFields decodes the successive fields of a pdu
+func unmarshalSNMPFields(data []byte, fields ...interface{}) ([]byte, error) {
+	for _, field := range fields {
+		var err error
+		if data, err = asn1.Unmarshal(data, field); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// snmpValueType returns the type name of a varbind value
+func snmpValueType(value asn1.RawValue) string {
+	switch value.Class {
+	case asn1.ClassUniversal:
+		switch value.Tag {
+		case asn1.TagInteger:
+			return "integer"
+		case asn1.TagOctetString:
+			return "octet-string"
+		case asn1.TagNull:
+			return "null"
+		case asn1.TagOID:
+			return "oid"
+		}
+	case asn1.ClassApplication:
+		switch value.Tag {
+		case 0:
+			return "ip-address"
+		case 1:
+			return "counter32"
+		case 2:
+			return "gauge32"
+		case 3:
+			return "timeticks"
+		case 4:
+			return "opaque"
+		case 6:
+			return "counter64"
+		}
+	case asn1.ClassContextSpecific:
+		switch value.Tag {
+		case 0:
+			return "no-such-object"
+		case 1:
+			return "no-such-instance"
+		case 2:
+			return "end-of-mib-view"
+		}
+	}
+	return fmt.Sprintf("tag-%d-%d", value.Class, value.Tag)
+}
+
+// formatSNMPValue returns the string representation of a varbind value
+func formatSNMPValue(value asn1.RawValue) string {
+	switch snmpValueType(value) {
+	case "integer":
+		var integer *big.Int
+		if _, err := asn1.Unmarshal(value.FullBytes, &integer); err == nil {
+			return integer.String()
+		}
+	case "octet-string":
+		if isPrintableSNMPString(value.Bytes) {
+			return string(value.Bytes)
+		}
+	case "oid":
+		var oid asn1.ObjectIdentifier
+		if _, err := asn1.Unmarshal(value.FullBytes, &oid); err == nil {
+			return oid.String()
+		}
+	case "ip-address":
+		if len(value.Bytes) == net.IPv4len {
+			return net.IP(value.Bytes).String()
+		}
+	case "counter32", "gauge32", "timeticks", "counter64":
+		return new(big.Int).SetBytes(value.Bytes).String()
+	case "null", "no-such-object", "no-such-instance", "end-of-mib-view":
+		return ""
+	}
+	return hex.EncodeToString(value.Bytes)
+}
+
+// isPrintableSNMPString reports whether an octet string is printable text
+func isPrintableSNMPString(data []byte) bool {
+	for _, r := range string(data) {
+		if r == unicode.ReplacementChar || (!unicode.IsPrint(r) && !unicode.IsSpace(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// decodedVarbinds returns the decoded variable bindings of the message
+func (m *snmpMessage) decodedVarbinds() []SNMPVarbind {
+	varbinds := make([]SNMPVarbind, 0, len(m.varbinds))
+	for _, varbind := range m.varbinds {
+		varbinds = append(varbinds, SNMPVarbind{OID: varbind.Name.String(), Type: snmpValueType(varbind.Value), Value: formatSNMPValue(varbind.Value)})
+	}
+	return varbinds
+}
+
+// response returns the response to a get or inform request, nil for the other pdus.
+// The requested objects are unknown, informs are acknowledged with their varbinds.
+func (m *snmpMessage) response() []byte {
+	errorStatus, errorIndex := 0, 0
+	varbinds := m.varbinds
+	switch m.pduType {
+	case snmpGetRequest, snmpGetNextRequest, snmpGetBulkRequest:
+		if m.version == 0 {
+			errorStatus, errorIndex = snmpNoSuchName, 1
+			break
+		}
+		varbinds = make([]snmpRawVarbind, len(m.varbinds))
+		for i, varbind := range m.varbinds {
+			varbinds[i] = snmpRawVarbind{Name: varbind.Name, Value: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0}}
+		}
+	case snmpInformRequest:
+	default:
+		return nil
+	}
+	var content []byte
+	for _, field := range []interface{}{m.requestID, errorStatus, errorIndex, varbinds} {
+		data, err := asn1.Marshal(field)
+		if err != nil {
+			return nil
+		}
+		content = append(content, data...)
+	}
+	response, err := asn1.Marshal(struct {
+		Version   int
+		Community []byte
+		PDU       asn1.RawValue
+	}{m.version, []byte(m.community), asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: snmpResponse, IsCompound: true, Bytes: content}})
+	if err != nil {
+		return nil
+	}
+	return response
+}
+
+// recordInteraction stores the message for each correlation id of its community and string values
+func (h *SNMPServer) recordInteraction(remoteAddr net.Addr, localPort int, message *snmpMessage, data []byte) {
+	host, _, _ := net.SplitHostPort(remoteAddr.String())
+	version, pduType := snmpVersionNames[message.version], snmpPDUNames[message.pduType]
+	varbinds := message.decodedVarbinds()
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("Port=%d\nVersion=%s\nCommunity=%s\nPDU=%s\n", localPort, version, message.community, pduType))
+	if message.trap != "" {
+		builder.WriteString(fmt.Sprintf("Trap=%s\n", message.trap))
+	}
+	candidates := []string{message.community}
+	for _, varbind := range varbinds {
+		builder.WriteString(fmt.Sprintf("%s = %s: %s\n", varbind.OID, varbind.Type, varbind.Value))
+		if varbind.Type == "octet-string" {
+			candidates = append(candidates, varbind.Value)
+		}
+	}
+	gologger.Debug().Msgf("New SNMP %s from %s: %s", pduType, remoteAddr, builder.String())
+
+	ids := make(map[string]string)
+	for _, candidate := range candidates {
+		for _, chunk := range stringsutil.SplitAny(candidate, ".@/\\:;,=()`'\"<>?& \r\n\t\x00") {
+			normalized := NormalizeHost(chunk)
+			for part := range stringsutil.SlideWithLength(normalized, h.options.GetIdLength()) {
+				if _, ok := ids[part]; !ok && h.options.isCorrelationID(part) {
+					ids[part] = normalized
+				}
+			}
+		}
+	}
+	for uniqueID, fullID := range ids {
+		interaction := &Interaction{
+			Protocol:      "snmp",
+			UniqueID:      uniqueID,
+			FullId:        fullID,
+			RawRequest:    builder.String(),
+			RawBytes:      data,
+			RemoteAddress: host,
+			Timestamp:     time.Now(),
+			LocalPort:     localPort,
+			SNMPVersion:   version,
+			SNMPCommunity: message.community,
+			SNMPPDU:       pduType,
+			SNMPVarbinds:  varbinds,
+		}
+		h.options.applyNAT64(interaction)
+		buffer := &bytes.Buffer{}
+		if err := jsoniter.NewEncoder(buffer).Encode(interaction); err != nil {
+			gologger.Warning().Msgf("Could not encode snmp interaction: %s\n", err)
+			continue
+		}
+		gologger.Debug().Msgf("%s\n", buffer.String())
+		if err := h.options.Storage.AddInteraction(uniqueID[:h.options.CorrelationIdLength], buffer.Bytes()); err != nil {
+			gologger.Warning().Msgf("Could not store snmp interaction: %s\n", err)
+		}
+	}
+}
+
+// Close stops the snmp server
+func (h *SNMPServer) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, conn := range h.conns {
+		_ = conn.Close()
+	}
+}
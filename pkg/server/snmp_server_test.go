@@ -0,0 +1,121 @@
+package server
+
+import (
+	"encoding/asn1"
+	"net"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/interactsh/pkg/storage"
+	"github.com/stretchr/testify/require"
+)
+
+// snmpTestMessage encodes a v1 or v2c message with the pdu fields
+func snmpTestMessage(t *testing.T, version int, community string, pduType int, fields ...interface{}) []byte {
+	var content []byte
+	for _, field := range fields {
+		data, err := asn1.Marshal(field)
+		require.Nil(t, err)
+		content = append(content, data...)
+	}
+	data, err := asn1.Marshal(struct {
+		Version   int
+		Community []byte
+		PDU       asn1.RawValue
+	}{version, []byte(community), asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: pduType, IsCompound: true, Bytes: content}})
+	require.Nil(t, err)
+	return data
+}
+
+func TestParseSNMPMessage(t *testing.T) {
+	sysUpTime := asn1.ObjectIdentifier{1, 3, 6, 1, 2, 1, 1, 3, 0}
+	message, err := parseSNMPMessage(snmpTestMessage(t, 0, "public", snmpTrapV1,
+		asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 9}, asn1.RawValue{Class: asn1.ClassApplication, Tag: 0, Bytes: []byte{10, 0, 0, 1}}, 6, 1,
+		asn1.RawValue{Class: asn1.ClassApplication, Tag: 3, Bytes: []byte{0x01, 0x00}},
+		[]snmpRawVarbind{{Name: sysUpTime, Value: asn1.RawValue{Class: asn1.ClassApplication, Tag: 3, Bytes: []byte{0x30, 0x39}}}}))
+	require.Nil(t, err)
+	require.Equal(t, "enterprise=1.3.6.1.4.1.9 agent-address=10.0.0.1 generic-trap=6 specific-trap=1", message.trap)
+	require.Equal(t, []SNMPVarbind{{OID: "1.3.6.1.2.1.1.3.0", Type: "timeticks", Value: "12345"}}, message.decodedVarbinds())
+	require.Nil(t, message.response())
+
+	// v1 gets are answered with no such name
+	message, err = parseSNMPMessage(snmpTestMessage(t, 0, "public", snmpGetRequest, 7, 0, 0, []snmpRawVarbind{{Name: sysUpTime, Value: asn1.NullRawValue}}))
+	require.Nil(t, err)
+	response, err := parseSNMPMessage(message.response())
+	require.Nil(t, err)
+	require.Equal(t, snmpResponse, response.pduType)
+	require.Equal(t, 7, response.requestID)
+
+	_, err = parseSNMPMessage(snmpTestMessage(t, 3, "public", snmpGetRequest, 1, 0, 0, []snmpRawVarbind{}))
+	require.NotNil(t, err)
+}
+
+func TestSNMPServer(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0")
+	store := options.Storage
+
+	agent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+	trap, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+	listeners := NewListeners()
+	listeners.addPacketConn(agent)
+	listeners.addPacketConn(trap)
+	options.ListenIP = "127.0.0.1"
+	options.SNMPPort = agent.LocalAddr().(*net.UDPAddr).Port
+	options.SNMPTrapPort = trap.LocalAddr().(*net.UDPAddr).Port
+	options.Listeners = listeners
+	server, err := NewSNMPServer(options)
+	require.Nil(t, err)
+	alive, trapAlive := make(chan bool, 1), make(chan bool, 1)
+	go server.ListenAndServe(alive, trapAlive)
+	require.True(t, <-alive)
+	require.True(t, <-trapAlive)
+	defer server.Close()
+
+	client, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer client.Close()
+	_ = client.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// v2c get with the correlation id in the community, answered with no such object
+	sysDescr := asn1.ObjectIdentifier{1, 3, 6, 1, 2, 1, 1, 1, 0}
+	_, err = client.WriteTo(snmpTestMessage(t, 1, "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy", snmpGetRequest, 42, 0, 0, []snmpRawVarbind{{Name: sysDescr, Value: asn1.NullRawValue}}), agent.LocalAddr())
+	require.Nil(t, err)
+	buffer := make([]byte, 1024)
+	n, _, err := client.ReadFrom(buffer)
+	require.Nil(t, err)
+	response, err := parseSNMPMessage(buffer[:n])
+	require.Nil(t, err)
+	require.Equal(t, 42, response.requestID)
+	require.Equal(t, []SNMPVarbind{{OID: "1.3.6.1.2.1.1.1.0", Type: "no-such-object"}}, response.decodedVarbinds())
+
+	// v2c trap with the correlation id in a string value
+	_, err = client.WriteTo(snmpTestMessage(t, 1, "public", snmpTrapV2, 1, 0, 0, []snmpRawVarbind{
+		{Name: sysDescr, Value: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagOctetString, Bytes: []byte("; nslookup c59e3crp82ke7bcnedq0cfjqdpeyyyyzz.interact.sh")}},
+	}), trap.LocalAddr())
+	require.Nil(t, err)
+
+	var item *storage.CorrelationData
+	for i := 0; i < 50; i++ {
+		if item, err = store.GetCacheItem("c59e3crp82ke7bcnedq0"); err == nil && len(item.Data) == 2 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	require.Len(t, item.Data, 2)
+	interactions := make(map[string]*Interaction)
+	for _, data := range item.Data {
+		interaction := &Interaction{}
+		require.Nil(t, jsoniter.Unmarshal([]byte(data), interaction))
+		require.Equal(t, "snmp", interaction.Protocol)
+		require.Equal(t, "v2c", interaction.SNMPVersion)
+		interactions[interaction.SNMPPDU] = interaction
+	}
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy", interactions["get-request"].SNMPCommunity)
+	require.Equal(t, options.SNMPPort, interactions["get-request"].LocalPort)
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyzz", interactions["trap-v2"].FullId)
+	require.Equal(t, options.SNMPTrapPort, interactions["trap-v2"].LocalPort)
+	require.Contains(t, interactions["trap-v2"].RawRequest, "1.3.6.1.2.1.1.1.0 = octet-string: ; nslookup")
+}
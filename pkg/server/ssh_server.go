@@ -0,0 +1,169 @@
+//go:build !no_ssh
+
+package server
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/gologger"
+	stringsutil "github.com/projectdiscovery/utils/strings"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// SSHServerVersion is the banner announced by the ssh server
+	SSHServerVersion = "SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.6"
+	// sshTimeout is the time after which an ssh connection is closed
+	sshTimeout = 30 * time.Second
+)
+
+// errSSHDenied is returned to every authentication attempt
+var errSSHDenied = errors.New("permission denied")
+
+// SSHServer is a minimal ssh server rejecting every authentication attempt,
+// the attempts of users containing correlation ids are recorded with the
+// client banner, the authentication method and the offered credentials
+type SSHServer struct {
+	options  *Options
+	config   *ssh.ServerConfig
+	listener net.Listener
+	mu       sync.Mutex
+}
+
+// NewSSHServer returns a new ssh server with an ephemeral ed25519 host key
+func NewSSHServer(options *Options) (*SSHServer, error) {
+	server := &SSHServer{options: options}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		return nil, err
+	}
+	server.config = &ssh.ServerConfig{
+		ServerVersion: SSHServerVersion,
+		PasswordCallback: func(meta ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			server.recordAuth(meta, "password", string(password))
+			return nil, errSSHDenied
+		},
+		PublicKeyCallback: func(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			server.recordAuth(meta, "publickey", fmt.Sprintf("%s %s", key.Type(), ssh.FingerprintSHA256(key)))
+			return nil, errSSHDenied
+		},
+		KeyboardInteractiveCallback: func(meta ssh.ConnMetadata, challenge ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+			answers, err := challenge("", "", []string{"Password: "}, []bool{false})
+			if err == nil && len(answers) > 0 {
+				server.recordAuth(meta, "keyboard-interactive", answers[0])
+			}
+			return nil, errSSHDenied
+		},
+	}
+	server.config.AddHostKey(signer)
+	return server, nil
+}
+
+// ListenAndServe listens on the ssh port
+func (h *SSHServer) ListenAndServe(sshAlive chan bool) {
+	listener, err := h.options.Listeners.Listen("tcp", net.JoinHostPort(h.options.ListenIP, fmt.Sprint(h.options.SshPort)))
+	if err != nil {
+		gologger.Error().Msgf("Could not serve ssh on port %d: %s\n", h.options.SshPort, err)
+		sshAlive <- false
+		return
+	}
+	h.mu.Lock()
+	h.listener = listener
+	h.mu.Unlock()
+	sshAlive <- true
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				gologger.Error().Msgf("Could not serve ssh on port %d: %s\n", h.options.SshPort, err)
+				sshAlive <- false
+			}
+			return
+		}
+		go h.handleConn(conn)
+	}
+}
+
+func (h *SSHServer) handleConn(conn net.Conn) {
+	defer conn.Close()
+
+	h.options.Stats.RecordSource("ssh", conn.RemoteAddr().String())
+	_ = conn.SetDeadline(time.Now().Add(sshTimeout))
+	// authentication always fails, so the handshake never completes
+	if _, _, _, err := ssh.NewServerConn(conn, h.config); err != nil {
+		gologger.Debug().Msgf("SSH connection from %s closed: %s\n", conn.RemoteAddr(), err)
+	}
+}
+
+// recordAuth stores an authentication attempt for each correlation id in the user
+func (h *SSHServer) recordAuth(meta ssh.ConnMetadata, method, credential string) {
+	user := meta.User()
+	gologger.Debug().Msgf("New ssh %s authentication from %s as %q\n", method, meta.RemoteAddr(), user)
+
+	var message strings.Builder
+	message.WriteString("Type=Auth\n")
+	message.WriteString(fmt.Sprintf("ClientVersion=%s\n", meta.ClientVersion()))
+	message.WriteString(fmt.Sprintf("User=%s\n", user))
+	message.WriteString(fmt.Sprintf("Method=%s\n", method))
+	message.WriteString(fmt.Sprintf("Credential=%s\n", credential))
+
+	host, _, _ := net.SplitHostPort(meta.RemoteAddr().String())
+	seen := make(map[string]struct{})
+	for _, chunk := range stringsutil.SplitAny(user, ".@") {
+		normalized := NormalizeHost(chunk)
+		for part := range stringsutil.SlideWithLength(normalized, h.options.GetIdLength()) {
+			if _, ok := seen[part]; ok || !h.options.isCorrelationID(part) {
+				continue
+			}
+			seen[part] = struct{}{}
+
+			interaction := &Interaction{
+				Protocol:         "ssh",
+				UniqueID:         part,
+				FullId:           normalized,
+				RawRequest:       message.String(),
+				RemoteAddress:    host,
+				Timestamp:        time.Now(),
+				OriginalHost:     originalHost(chunk, normalized),
+				SSHClientVersion: string(meta.ClientVersion()),
+				SSHUser:          user,
+				SSHAuthMethod:    method,
+				SSHCredential:    credential,
+			}
+			h.options.applyNAT64(interaction)
+			buffer := &bytes.Buffer{}
+			if err := jsoniter.NewEncoder(buffer).Encode(interaction); err != nil {
+				gologger.Warning().Msgf("Could not encode ssh interaction: %s\n", err)
+				continue
+			}
+			gologger.Debug().Msgf("%s\n", buffer.String())
+			if err := h.options.Storage.AddInteraction(part[:h.options.CorrelationIdLength], buffer.Bytes()); err != nil {
+				gologger.Warning().Msgf("Could not store ssh interaction: %s\n", err)
+			}
+		}
+	}
+}
+
+// Close stops the ssh server
+func (h *SSHServer) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.listener != nil {
+		_ = h.listener.Close()
+	}
+}
@@ -0,0 +1,21 @@
+//go:build no_ssh
+
+package server
+
+import "errors"
+
+// SSHServer is a placeholder for the ssh server excluded by the no_ssh build tag
+type SSHServer struct{}
+
+// NewSSHServer returns an error as ssh support is not compiled in
+func NewSSHServer(options *Options) (*SSHServer, error) {
+	return nil, errors.New("ssh support is not compiled in (no_ssh build tag)")
+}
+
+// ListenAndServe is a no-op as ssh support is not compiled in
+func (h *SSHServer) ListenAndServe(sshAlive chan bool) {
+	sshAlive <- false
+}
+
+// Close is a no-op as ssh support is not compiled in
+func (h *SSHServer) Close() {}
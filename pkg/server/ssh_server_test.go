@@ -0,0 +1,54 @@
+//go:build !no_ssh
+
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSSHServer(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0")
+	store := options.Storage
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	listeners := NewListeners()
+	listeners.addListener(listener)
+
+	options.ListenIP = "127.0.0.1"
+	options.SshPort = listener.Addr().(*net.TCPAddr).Port
+	options.Listeners = listeners
+	server, err := NewSSHServer(options)
+	require.Nil(t, err)
+	alive := make(chan bool, 1)
+	go server.ListenAndServe(alive)
+	require.True(t, <-alive)
+	defer server.Close()
+
+	_, err = ssh.Dial("tcp", listener.Addr().String(), &ssh.ClientConfig{
+		User:            "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy",
+		Auth:            []ssh.AuthMethod{ssh.Password("hunter2")},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		ClientVersion:   "SSH-2.0-scanner",
+		Timeout:         5 * time.Second,
+	})
+	require.NotNil(t, err)
+
+	item, err := store.GetCacheItem("c59e3crp82ke7bcnedq0")
+	require.Nil(t, err)
+	require.Len(t, item.Data, 1)
+
+	interaction := &Interaction{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[0]), interaction))
+	require.Equal(t, "ssh", interaction.Protocol)
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy", interaction.UniqueID)
+	require.Equal(t, "SSH-2.0-scanner", interaction.SSHClientVersion)
+	require.Equal(t, "password", interaction.SSHAuthMethod)
+	require.Equal(t, "hunter2", interaction.SSHCredential)
+}
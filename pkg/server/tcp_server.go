@@ -0,0 +1,283 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/gologger"
+	stringsutil "github.com/projectdiscovery/utils/strings"
+)
+
+const (
+	// DefaultTCPCaptureSize is the number of payload bytes recorded by default
+	DefaultTCPCaptureSize = 1024
+	// TCPMaxPorts is the maximum number of ports of the tcp catch-all server
+	TCPMaxPorts = 10000
+	// tcpTimeout is the time after which a tcp catch-all connection is closed
+	tcpTimeout = 10 * time.Second
+	// tcpIdleTimeout is the time without data after which the payload is considered complete
+	tcpIdleTimeout = 2 * time.Second
+	// tlsRecordHandshake is the first byte of a tls client hello
+	tlsRecordHandshake = 0x16
+)
+
+// TCPServer is a catch-all tcp server accepting connections on extra ports
+// and recording the first bytes sent by the clients, correlated by the tls
+// server name or the correlation ids found in the payload
+type TCPServer struct {
+	options   *Options
+	listeners []net.Listener
+	mu        sync.Mutex
+}
+
+// NewTCPServer returns a new tcp catch-all server
+func NewTCPServer(options *Options) (*TCPServer, error) {
+	return &TCPServer{options: options}, nil
+}
+
+// ParsePortRanges parses ports and port ranges (eg. 8000,1024-2048) into sorted unique ports
+func ParsePortRanges(values []string) ([]int, error) {
+	unique := make(map[int]struct{})
+	for _, value := range values {
+		first, last, isRange := strings.Cut(strings.TrimSpace(value), "-")
+		if !isRange {
+			last = first
+		}
+		start, err := strconv.Atoi(first)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %s", value)
+		}
+		end, err := strconv.Atoi(last)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %s", value)
+		}
+		if start < 1 || end > 65535 || start > end {
+			return nil, fmt.Errorf("invalid port range %s", value)
+		}
+		for port := start; port <= end; port++ {
+			unique[port] = struct{}{}
+			if len(unique) > TCPMaxPorts {
+				return nil, fmt.Errorf("too many ports, at most %d are supported", TCPMaxPorts)
+			}
+		}
+	}
+	ports := make([]int, 0, len(unique))
+	for port := range unique {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+	return ports, nil
+}
+
+// ListenAndServe listens on all the tcp ports, tls client hellos are answered
+// when a tls configuration is available
+func (h *TCPServer) ListenAndServe(tlsConfig *tls.Config, tcpAlive chan bool) {
+	for _, port := range h.options.TCPPorts {
+		listener, err := h.options.Listeners.Listen("tcp", net.JoinHostPort(h.options.ListenIP, fmt.Sprint(port)))
+		if err != nil {
+			gologger.Error().Msgf("Could not serve tcp on port %d: %s\n", port, err)
+			h.Close()
+			tcpAlive <- false
+			return
+		}
+		h.mu.Lock()
+		h.listeners = append(h.listeners, listener)
+		h.mu.Unlock()
+	}
+	if tlsConfig != nil {
+		tlsConfig = h.options.listenerTLSConfig("tcp", tlsConfig)
+	}
+	tcpAlive <- true
+
+	var wg sync.WaitGroup
+	h.mu.Lock()
+	for _, listener := range h.listeners {
+		wg.Add(1)
+		go func(listener net.Listener) {
+			defer wg.Done()
+			h.serve(listener, tlsConfig)
+		}(listener)
+	}
+	h.mu.Unlock()
+	wg.Wait()
+}
+
+func (h *TCPServer) serve(listener net.Listener, tlsConfig *tls.Config) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				gologger.Error().Msgf("Could not accept tcp connection on %s: %s\n", listener.Addr(), err)
+			}
+			return
+		}
+		go h.handleConn(conn, tlsConfig)
+	}
+}
+
+// bufferedConn is a connection whose reads go through a buffered reader,
+// keeping the peeked bytes for the tls handshake
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+func (h *TCPServer) handleConn(conn net.Conn, tlsConfig *tls.Config) {
+	defer conn.Close()
+
+	h.options.Stats.RecordSource("tcp", conn.RemoteAddr().String())
+	var localPort int
+	if addr, ok := conn.LocalAddr().(*net.TCPAddr); ok {
+		localPort = addr.Port
+	}
+	deadline := time.Now().Add(tcpTimeout)
+	_ = conn.SetDeadline(deadline)
+
+	// banner protocols (ssh, ftp, smtp) greet first, scanners wait for it
+	if banner := h.options.DeceptionProfile.banner(localPort); banner != "" {
+		_, _ = conn.Write([]byte(banner))
+	}
+	reader := bufio.NewReader(conn)
+	var serverName string
+	if first, err := reader.Peek(1); err == nil && first[0] == tlsRecordHandshake && tlsConfig != nil {
+		tlsConn := tls.Server(&bufferedConn{Conn: conn, reader: reader}, tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			gologger.Debug().Msgf("Tcp tls handshake from %s failed: %s\n", conn.RemoteAddr(), err)
+			return
+		}
+		serverName = tlsConn.ConnectionState().ServerName
+		conn = tlsConn
+		reader = bufio.NewReader(tlsConn)
+	}
+
+	payload := h.readPayload(conn, reader, deadline)
+	probe := matchScannerProbe(payload, false)
+	if probe != "" {
+		gologger.Debug().Msgf("Scanner probe %s from %s on tcp port %d\n", probe, conn.RemoteAddr(), localPort)
+		if response := h.options.DeceptionProfile.response(probe); response != "" {
+			_, _ = conn.Write([]byte(response))
+		}
+	}
+	ids := make(map[string]string)
+	h.correlate(ids, serverName)
+	h.correlate(ids, h.payloadValues(payload)...)
+	if len(ids) == 0 {
+		return
+	}
+
+	var message strings.Builder
+	message.WriteString(fmt.Sprintf("Port=%d\n", localPort))
+	if serverName != "" {
+		message.WriteString(fmt.Sprintf("ServerName=%s\n", serverName))
+	}
+	message.WriteString("\n")
+	message.Write(payload)
+	h.recordInteraction(conn.RemoteAddr(), localPort, serverName, message.String(), probe, ids)
+}
+
+// readPayload reads the first bytes sent by the client, until the capture
+// size is reached, the client stops sending or closes the connection
+func (h *TCPServer) readPayload(conn net.Conn, reader *bufio.Reader, deadline time.Time) []byte {
+	size := h.options.TCPCaptureSize
+	if size <= 0 {
+		size = DefaultTCPCaptureSize
+	}
+	payload := make([]byte, 0, size)
+	buffer := make([]byte, size)
+	for len(payload) < size {
+		idle := time.Now().Add(tcpIdleTimeout)
+		if idle.After(deadline) {
+			idle = deadline
+		}
+		_ = conn.SetReadDeadline(idle)
+		n, err := reader.Read(buffer[:size-len(payload)])
+		payload = append(payload, buffer[:n]...)
+		if err != nil {
+			break
+		}
+	}
+	return payload
+}
+
+// payloadValues returns the parts of the payload scanned for correlation ids,
+// the matches of the payload regex if configured or the whole payload
+func (h *TCPServer) payloadValues(payload []byte) []string {
+	if h.options.TCPPayloadRegex == nil {
+		return []string{string(payload)}
+	}
+	var values []string
+	for _, match := range h.options.TCPPayloadRegex.FindAllSubmatch(payload, -1) {
+		// the first group if any, the whole match otherwise
+		value := match[0]
+		if len(match) > 1 {
+			value = match[1]
+		}
+		values = append(values, string(value))
+	}
+	return values
+}
+
+// correlate adds the correlation ids found in the values to the ids of a connection
+func (h *TCPServer) correlate(ids map[string]string, values ...string) {
+	for _, value := range values {
+		for _, chunk := range stringsutil.SplitAny(value, ".@/\\:;,=()`'\"<>?& \r\n\t") {
+			normalized := NormalizeHost(chunk)
+			for part := range stringsutil.SlideWithLength(normalized, h.options.GetIdLength()) {
+				if _, ok := ids[part]; !ok && h.options.isCorrelationID(part) {
+					ids[part] = normalized
+				}
+			}
+		}
+	}
+}
+
+// recordInteraction stores the payload for each correlation id of the connection
+func (h *TCPServer) recordInteraction(remoteAddr net.Addr, localPort int, serverName, payload, probe string, ids map[string]string) {
+	host, _, _ := net.SplitHostPort(remoteAddr.String())
+	for uniqueID, fullID := range ids {
+		interaction := &Interaction{
+			Protocol:      "tcp",
+			UniqueID:      uniqueID,
+			FullId:        fullID,
+			RawRequest:    payload,
+			RemoteAddress: host,
+			Timestamp:     time.Now(),
+			TLSServerName: serverName,
+			LocalPort:     localPort,
+			ScannerProbe:  probe,
+		}
+		h.options.applyNAT64(interaction)
+		buffer := &bytes.Buffer{}
+		if err := jsoniter.NewEncoder(buffer).Encode(interaction); err != nil {
+			gologger.Warning().Msgf("Could not encode tcp interaction: %s\n", err)
+			continue
+		}
+		gologger.Debug().Msgf("%s\n", buffer.String())
+		if err := h.options.Storage.AddInteraction(uniqueID[:h.options.CorrelationIdLength], buffer.Bytes()); err != nil {
+			gologger.Warning().Msgf("Could not store tcp interaction: %s\n", err)
+		}
+	}
+}
+
+// Close stops the tcp catch-all server
+func (h *TCPServer) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, listener := range h.listeners {
+		_ = listener.Close()
+	}
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"regexp"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePortRanges(t *testing.T) {
+	ports, err := ParsePortRanges([]string{"8080", "1024-1026", "1025"})
+	require.Nil(t, err)
+	require.Equal(t, []int{1024, 1025, 1026, 8080}, ports)
+
+	for _, value := range []string{"0", "2048-1024", "1-70000", "http"} {
+		_, err = ParsePortRanges([]string{value})
+		require.NotNil(t, err, value)
+	}
+	_, err = ParsePortRanges([]string{"1-20000"})
+	require.NotNil(t, err)
+}
+
+func TestTCPServer(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0")
+	store := options.Storage
+
+	plain, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	secure, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	listeners := NewListeners()
+	listeners.addListener(plain)
+	listeners.addListener(secure)
+	plainPort, securePort := plain.Addr().(*net.TCPAddr).Port, secure.Addr().(*net.TCPAddr).Port
+
+	options.ListenIP = "127.0.0.1"
+	options.TCPPorts = []int{plainPort, securePort}
+	options.TCPCaptureSize = 100
+	options.TCPPayloadRegex = regexp.MustCompile(`Host: (\S+)`)
+	options.Listeners = listeners
+	certificate, err := localhostCertificate()
+	require.Nil(t, err)
+	server, err := NewTCPServer(options)
+	require.Nil(t, err)
+	alive := make(chan bool, 1)
+	go server.ListenAndServe(&tls.Config{Certificates: []tls.Certificate{certificate}}, alive)
+	require.True(t, <-alive)
+	defer server.Close()
+
+	// only the regex matches are correlated, the payload is truncated to the capture size
+	conn, err := net.Dial("tcp", plain.Addr().String())
+	require.Nil(t, err)
+	_, err = conn.Write([]byte("GET /c59e3crp82ke7bcnedq0cfjqdpeyyyyxx HTTP/1.1\r\nHost: c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh\r\n\r\n"))
+	require.Nil(t, err)
+	require.Nil(t, conn.(*net.TCPConn).CloseWrite())
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	require.NotNil(t, err)
+	conn.Close()
+
+	// the tls server name correlates the connection
+	tlsConn, err := tls.Dial("tcp", secure.Addr().String(), &tls.Config{ServerName: "c59e3crp82ke7bcnedq0cfjqdpeyyyyzz.interact.sh", InsecureSkipVerify: true})
+	require.Nil(t, err)
+	_, err = tlsConn.Write([]byte("hello"))
+	require.Nil(t, err)
+	require.Nil(t, tlsConn.CloseWrite())
+	_ = tlsConn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = tlsConn.Read(make([]byte, 1))
+	require.NotNil(t, err)
+	tlsConn.Close()
+
+	item, err := store.GetCacheItem("c59e3crp82ke7bcnedq0")
+	require.Nil(t, err)
+	require.Len(t, item.Data, 2)
+
+	interaction := &Interaction{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[0]), interaction))
+	require.Equal(t, "tcp", interaction.Protocol)
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy", interaction.UniqueID)
+	require.Equal(t, plainPort, interaction.LocalPort)
+	require.Equal(t, fmt.Sprintf("Port=%d\n\nGET /c59e3crp82ke7bcnedq0cfjqdpeyyyyxx HTTP/1.1\r\nHost: c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh", plainPort), interaction.RawRequest)
+
+	interaction = &Interaction{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[1]), interaction))
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyzz", interaction.UniqueID)
+	require.Equal(t, securePort, interaction.LocalPort)
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyzz.interact.sh", interaction.TLSServerName)
+	require.Contains(t, interaction.RawRequest, "hello")
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Tenant is a customer of a shared server authenticating with its own token,
+// whose payloads are answered with its own content
+type Tenant struct {
+	// Name is the unique name of the tenant
+	Name string `yaml:"name"`
+	// Token is the auth token of the tenant clients
+	Token string `yaml:"token"`
+	// Domains are the domains dedicated to the tenant, if any
+	Domains []string `yaml:"domains,omitempty"`
+	// IP is the address answered for the tenant payloads, smtp
+	// connections received on it are greeted with the tenant banner
+	IP string `yaml:"ip,omitempty"`
+	// HTTPIndex is the index file served to http requests, supports {DOMAIN} placeholders
+	HTTPIndex string `yaml:"http-index,omitempty"`
+	// SMTPBanner is the name the smtp server greets with
+	SMTPBanner string `yaml:"smtp-banner,omitempty"`
+	// TXT is the content of the txt records
+	TXT string `yaml:"txt,omitempty"`
+
+	index string
+	ip    net.IP
+}
+
+// Tenants are the tenants of a shared server
+type Tenants struct {
+	Tenants []*Tenant `yaml:"tenants"`
+
+	// sessions maps the correlation ids to the tenant that registered them
+	sessions sync.Map
+}
+
+// NewTenants loads the tenants configuration file
+func NewTenants(path string) (*Tenants, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tenants := &Tenants{}
+	if err := yaml.Unmarshal(data, tenants); err != nil {
+		return nil, err
+	}
+	tokens := make(map[string]struct{})
+	for _, tenant := range tenants.Tenants {
+		if tenant.Name == "" || tenant.Token == "" {
+			return nil, errors.New("tenants require a name and a token")
+		}
+		if _, ok := tokens[tenant.Token]; ok {
+			return nil, fmt.Errorf("duplicate token for tenant %s", tenant.Name)
+		}
+		tokens[tenant.Token] = struct{}{}
+		for i, domain := range tenant.Domains {
+			tenant.Domains[i] = strings.ToLower(strings.TrimSuffix(domain, "."))
+		}
+		if tenant.IP != "" {
+			if tenant.ip = net.ParseIP(tenant.IP); tenant.ip == nil {
+				return nil, fmt.Errorf("invalid ip %s for tenant %s", tenant.IP, tenant.Name)
+			}
+		}
+		if tenant.HTTPIndex != "" {
+			index, err := os.ReadFile(tenant.HTTPIndex)
+			if err != nil {
+				return nil, fmt.Errorf("could not read http index of tenant %s: %w", tenant.Name, err)
+			}
+			tenant.index = string(index)
+		}
+	}
+	return tenants, nil
+}
+
+// ByToken returns the tenant authenticating with the token, if any
+func (t *Tenants) ByToken(token string) *Tenant {
+	if t == nil || token == "" {
+		return nil
+	}
+	for _, tenant := range t.Tenants {
+		if tenant.Token == token {
+			return tenant
+		}
+	}
+	return nil
+}
+
+// Register records the correlation id as registered by the tenant
+func (t *Tenants) Register(correlationID string, tenant *Tenant) {
+	t.sessions.Store(correlationID, tenant)
+}
+
+// bySession returns the tenant that registered the correlation id, if any
+func (t *Tenants) bySession(correlationID string) *Tenant {
+	if t == nil {
+		return nil
+	}
+	if tenant, ok := t.sessions.Load(correlationID); ok {
+		return tenant.(*Tenant)
+	}
+	return nil
+}
+
+// Deregister forgets the tenant of the correlation id
+func (t *Tenants) Deregister(correlationID string) {
+	t.sessions.Delete(correlationID)
+}
+
+// byIP returns the tenant whose payloads are answered with the address, if any
+func (t *Tenants) byIP(ip net.IP) *Tenant {
+	if t == nil || ip == nil {
+		return nil
+	}
+	for _, tenant := range t.Tenants {
+		if tenant.ip.Equal(ip) {
+			return tenant
+		}
+	}
+	return nil
+}
+
+// smtpBanners reports whether any tenant greets smtp clients with its own banner
+func (t *Tenants) smtpBanners() bool {
+	if t == nil {
+		return false
+	}
+	for _, tenant := range t.Tenants {
+		if tenant.ip != nil && tenant.SMTPBanner != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// tenantOf returns the tenant of a name, from its dedicated domains
+// or from the session of the correlation id it contains
+func (options *Options) tenantOf(name string) *Tenant {
+	if options.Tenants == nil {
+		return nil
+	}
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	if host, _, err := net.SplitHostPort(name); err == nil {
+		name = host
+	}
+	for _, tenant := range options.Tenants.Tenants {
+		for _, domain := range tenant.Domains {
+			if name == domain || strings.HasSuffix(name, "."+domain) {
+				return tenant
+			}
+		}
+	}
+	if session := options.sessionOf(name); session != "" {
+		return options.Tenants.bySession(session)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenants(t *testing.T) {
+	dir := t.TempDir()
+	index := filepath.Join(dir, "index.html")
+	require.Nil(t, os.WriteFile(index, []byte("acme corp {DOMAIN}"), 0600))
+	config := `tenants:
+  - name: acme
+    token: acme-token
+    domains: [oast.acme.com]
+    http-index: ` + index + `
+    txt: acme-txt
+  - name: globex
+    token: globex-token
+    ip: 192.0.2.7
+    smtp-banner: globex
+`
+	path := filepath.Join(dir, "tenants.yaml")
+	require.Nil(t, os.WriteFile(path, []byte(config), 0600))
+	tenants, err := NewTenants(path)
+	require.Nil(t, err)
+	require.True(t, tenants.smtpBanners())
+
+	options := &Options{
+		Domains:                  []string{"interact.sh", "oast.acme.com"},
+		IPAddress:                "192.0.2.1",
+		Tenants:                  tenants,
+		Stats:                    &Metrics{},
+		CorrelationIdLength:      20,
+		CorrelationIdNonceLength: 13,
+	}
+	require.Equal(t, "acme", options.tenantOf("c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.oast.acme.com.").Name)
+	require.Nil(t, options.tenantOf("c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh"))
+
+	// sessions are answered with the content of the tenant that registered them
+	tenants.Register("c59e3crp82ke7bcnedq0", tenants.ByToken("globex-token"))
+	require.Equal(t, "globex", options.tenantOf("c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh").Name)
+	tenants.Deregister("c59e3crp82ke7bcnedq0")
+	require.Nil(t, options.tenantOf("c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh"))
+
+	server, err := NewHTTPServer(options)
+	require.Nil(t, err)
+	recorder := httptest.NewRecorder()
+	server.defaultHandler(recorder, httptest.NewRequest(http.MethodGet, "http://oast.acme.com/", nil))
+	require.Equal(t, "acme corp oast.acme.com", recorder.Body.String())
+
+	dnsServer := NewDNSServer("udp", options)
+	m := &dns.Msg{}
+	dnsServer.handleTXT("oast.acme.com.", m)
+	require.Equal(t, []string{"acme-txt"}, m.Answer[0].(*dns.TXT).Txt)
+}
@@ -0,0 +1,268 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/gologger"
+	stringsutil "github.com/projectdiscovery/utils/strings"
+)
+
+const (
+	// tftpBlockSize is the size of the data blocks of a transfer, the options
+	// negotiating another size are refused
+	tftpBlockSize = 512
+	// tftpTimeout is the time waited for a data block before acknowledging the previous one again
+	tftpTimeout = 5 * time.Second
+	// tftpRetries is the number of acknowledgements sent again before a transfer is given up
+	tftpRetries = 3
+)
+
+// tftp opcodes (rfc 1350)
+const (
+	tftpRRQ uint16 = iota + 1
+	tftpWRQ
+	tftpDATA
+	tftpACK
+	tftpERROR
+)
+
+// tftp error codes (rfc 1350)
+const (
+	tftpErrorFileNotFound = 1
+	tftpErrorIllegal      = 4
+	tftpErrorUnknownTID   = 5
+)
+
+// TFTPServer is a tftp server recording read and write requests, the
+// correlation ids are searched in the requested file names. Reads are
+// answered with file not found, writes are accepted and the uploaded file
+// recorded up to the body capture size.
+type TFTPServer struct {
+	options *Options
+	conn    net.PacketConn
+	mu      sync.Mutex
+}
+
+// tftpRequest is a read or write request
+type tftpRequest struct {
+	opcode   uint16
+	filename string
+	mode     string
+	options  []string
+}
+
+// NewTFTPServer returns a new tftp server
+func NewTFTPServer(options *Options) (*TFTPServer, error) {
+	return &TFTPServer{options: options}, nil
+}
+
+// ListenAndServe listens on the tftp port
+func (h *TFTPServer) ListenAndServe(tftpAlive chan bool) {
+	conn, err := h.options.Listeners.ListenPacket("udp", net.JoinHostPort(h.options.ListenIP, fmt.Sprint(h.options.TFTPPort)))
+	if err != nil {
+		gologger.Error().Msgf("Could not serve tftp on port %d: %s\n", h.options.TFTPPort, err)
+		tftpAlive <- false
+		return
+	}
+	h.mu.Lock()
+	h.conn = conn
+	h.mu.Unlock()
+	tftpAlive <- true
+
+	buffer := make([]byte, udpMaxDatagram)
+	for {
+		n, addr, err := conn.ReadFrom(buffer)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				gologger.Error().Msgf("Could not read tftp request: %s\n", err)
+			}
+			return
+		}
+		h.options.Stats.RecordSource("tftp", addr.String())
+		request, err := parseTFTPRequest(buffer[:n])
+		if err != nil {
+			gologger.Debug().Msgf("Invalid tftp request from %s: %s\n", addr, err)
+			sendTFTPError(conn, addr, tftpErrorIllegal, "Illegal TFTP operation")
+			continue
+		}
+		go h.handleRequest(conn, addr, request)
+	}
+}
+
+// parseTFTPRequest parses a read or write request with its options (rfc 2347)
+func parseTFTPRequest(packet []byte) (*tftpRequest, error) {
+	if len(packet) < 2 {
+		return nil, errors.New("short packet")
+	}
+	opcode := binary.BigEndian.Uint16(packet)
+	if opcode != tftpRRQ && opcode != tftpWRQ {
+		return nil, fmt.Errorf("unexpected opcode %d", opcode)
+	}
+	fields := strings.Split(string(packet[2:]), "\x00")
+	if len(fields) < 3 || fields[0] == "" {
+		return nil, errors.New("missing file name or mode")
+	}
+	request := &tftpRequest{opcode: opcode, filename: fields[0], mode: strings.ToLower(fields[1])}
+	for i := 2; i+1 < len(fields); i += 2 {
+		request.options = append(request.options, fields[i]+"="+fields[i+1])
+	}
+	return request, nil
+}
+
+func (h *TFTPServer) handleRequest(conn net.PacketConn, addr net.Addr, request *tftpRequest) {
+	if request.opcode == tftpRRQ {
+		h.recordInteraction(addr, request, nil)
+		sendTFTPError(conn, addr, tftpErrorFileNotFound, "File not found")
+		return
+	}
+	capture, err := h.receive(addr)
+	if err != nil {
+		gologger.Debug().Msgf("Could not receive tftp file %s from %s: %s\n", request.filename, addr, err)
+	}
+	h.recordInteraction(addr, request, capture)
+}
+
+// receive acknowledges a write request and receives the file from a new
+// transfer port, as a new transfer identifier
+func (h *TFTPServer) receive(addr net.Addr) (*bodyCapture, error) {
+	capture := newBodyCapture(h.options.captureLimit())
+	transfer, err := net.ListenPacket("udp", net.JoinHostPort(h.options.ListenIP, "0"))
+	if err != nil {
+		return capture, err
+	}
+	defer transfer.Close()
+
+	var block uint16
+	ack := func() {
+		packet := make([]byte, 4)
+		binary.BigEndian.PutUint16(packet, tftpACK)
+		binary.BigEndian.PutUint16(packet[2:], block)
+		_, _ = transfer.WriteTo(packet, addr)
+	}
+	ack()
+	buffer := make([]byte, 4+tftpBlockSize)
+	for retries := 0; ; {
+		_ = transfer.SetReadDeadline(time.Now().Add(tftpTimeout))
+		n, from, err := transfer.ReadFrom(buffer)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() && retries < tftpRetries {
+				retries++
+				ack()
+				continue
+			}
+			return capture, err
+		}
+		if from.String() != addr.String() {
+			sendTFTPError(transfer, from, tftpErrorUnknownTID, "Unknown transfer ID")
+			continue
+		}
+		if n < 4 {
+			continue
+		}
+		switch binary.BigEndian.Uint16(buffer) {
+		case tftpDATA:
+			// duplicated blocks are acknowledged again
+			if binary.BigEndian.Uint16(buffer[2:]) == block+1 {
+				block++
+				retries = 0
+				_, _ = capture.Write(buffer[4:n])
+			}
+			ack()
+			if n < len(buffer) {
+				return capture, nil
+			}
+		case tftpERROR:
+			return capture, fmt.Errorf("transfer aborted by the client: %s", strings.TrimRight(string(buffer[4:n]), "\x00"))
+		}
+	}
+}
+
+// sendTFTPError sends an error packet, which also ends the transfer
+func sendTFTPError(conn net.PacketConn, addr net.Addr, code uint16, message string) {
+	packet := make([]byte, 4, 5+len(message))
+	binary.BigEndian.PutUint16(packet, tftpERROR)
+	binary.BigEndian.PutUint16(packet[2:], code)
+	packet = append(append(packet, message...), 0)
+	_, _ = conn.WriteTo(packet, addr)
+}
+
+// recordInteraction stores the request, with the uploaded file of a write, for
+// each correlation id of the file name
+func (h *TFTPServer) recordInteraction(remoteAddr net.Addr, request *tftpRequest, capture *bodyCapture) {
+	host, _, _ := net.SplitHostPort(remoteAddr.String())
+	operation := "read"
+	if request.opcode == tftpWRQ {
+		operation = "write"
+	}
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("Operation=%s\nFilename=%s\nMode=%s\n", operation, request.filename, request.mode))
+	for _, option := range request.options {
+		builder.WriteString(fmt.Sprintf("Option=%s\n", option))
+	}
+	if capture != nil {
+		builder.WriteString(fmt.Sprintf("Size=%d\n\n", capture.size))
+		if data := capture.Bytes(); utf8.Valid(data) {
+			builder.Write(data)
+		} else {
+			builder.WriteString(hex.Dump(data))
+		}
+		builder.WriteString(capture.marker())
+	}
+	gologger.Debug().Msgf("New TFTP %s request from %s: %s\n", operation, remoteAddr, request.filename)
+
+	ids := make(map[string]string)
+	for _, chunk := range stringsutil.SplitAny(request.filename, ".@/\\:;,=_ ") {
+		normalized := NormalizeHost(chunk)
+		for part := range stringsutil.SlideWithLength(normalized, h.options.GetIdLength()) {
+			if _, ok := ids[part]; !ok && h.options.isCorrelationID(part) {
+				ids[part] = normalized
+			}
+		}
+	}
+	for uniqueID, fullID := range ids {
+		interaction := &Interaction{
+			Protocol:      "tftp",
+			UniqueID:      uniqueID,
+			FullId:        fullID,
+			RawRequest:    builder.String(),
+			RemoteAddress: host,
+			Timestamp:     time.Now(),
+			TFTPFile:      request.filename,
+			TFTPOperation: operation,
+		}
+		if capture != nil {
+			interaction.RawBytes = capture.Bytes()
+			capture.apply(interaction)
+		}
+		h.options.applyNAT64(interaction)
+		buffer := &bytes.Buffer{}
+		if err := jsoniter.NewEncoder(buffer).Encode(interaction); err != nil {
+			gologger.Warning().Msgf("Could not encode tftp interaction: %s\n", err)
+			continue
+		}
+		gologger.Debug().Msgf("%s\n", buffer.String())
+		if err := h.options.Storage.AddInteraction(uniqueID[:h.options.CorrelationIdLength], buffer.Bytes()); err != nil {
+			gologger.Warning().Msgf("Could not store tftp interaction: %s\n", err)
+		}
+	}
+}
+
+// Close stops the tftp server
+func (h *TFTPServer) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.conn != nil {
+		_ = h.conn.Close()
+	}
+}
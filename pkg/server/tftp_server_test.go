@@ -0,0 +1,92 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/interactsh/pkg/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTFTPServer(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0")
+	store := options.Storage
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+	listeners := NewListeners()
+	listeners.addPacketConn(conn)
+	options.ListenIP = "127.0.0.1"
+	options.TFTPPort = conn.LocalAddr().(*net.UDPAddr).Port
+	options.Listeners = listeners
+	options.BodyCaptureSize = 1
+	server, err := NewTFTPServer(options)
+	require.Nil(t, err)
+	alive := make(chan bool, 1)
+	go server.ListenAndServe(alive)
+	require.True(t, <-alive)
+	defer server.Close()
+
+	client, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer client.Close()
+	_ = client.SetDeadline(time.Now().Add(5 * time.Second))
+	buffer := make([]byte, 1024)
+	read := func() ([]byte, net.Addr) {
+		n, addr, err := client.ReadFrom(buffer)
+		require.Nil(t, err)
+		return buffer[:n], addr
+	}
+
+	// reads are answered with file not found
+	_, err = client.WriteTo([]byte("\x00\x01c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh/x.sh\x00octet\x00"), conn.LocalAddr())
+	require.Nil(t, err)
+	packet, _ := read()
+	require.Equal(t, "\x00\x05\x00\x01File not found\x00", string(packet))
+
+	// writes are acknowledged from a transfer port, the file is truncated to the capture size
+	_, err = client.WriteTo([]byte("\x00\x02c59e3crp82ke7bcnedq0cfjqdpeyyyyzz\x00octet\x00blksize\x001432\x00"), conn.LocalAddr())
+	require.Nil(t, err)
+	packet, transfer := read()
+	require.Equal(t, []byte{0, 4, 0, 0}, packet)
+	require.NotEqual(t, conn.LocalAddr().String(), transfer.String())
+	file := bytes.Repeat([]byte("a"), tftpBlockSize*2+100)
+	for block := 1; block <= 3; block++ {
+		data := make([]byte, 4)
+		binary.BigEndian.PutUint16(data, tftpDATA)
+		binary.BigEndian.PutUint16(data[2:], uint16(block))
+		data = append(data, file[(block-1)*tftpBlockSize:min(block*tftpBlockSize, len(file))]...)
+		_, err = client.WriteTo(data, transfer)
+		require.Nil(t, err)
+		packet, _ = read()
+		require.Equal(t, []byte{0, 4, 0, byte(block)}, packet)
+	}
+
+	var item *storage.CorrelationData
+	for i := 0; i < 50; i++ {
+		if item, err = store.GetCacheItem("c59e3crp82ke7bcnedq0"); err == nil && len(item.Data) == 2 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	require.Len(t, item.Data, 2)
+	interactions := make(map[string]*Interaction)
+	for _, data := range item.Data {
+		interaction := &Interaction{}
+		require.Nil(t, jsoniter.Unmarshal([]byte(data), interaction))
+		require.Equal(t, "tftp", interaction.Protocol)
+		interactions[interaction.TFTPOperation] = interaction
+	}
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy", interactions["read"].FullId)
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh/x.sh", interactions["read"].TFTPFile)
+	write := interactions["write"]
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyzz", write.FullId)
+	require.Contains(t, write.RawRequest, "Option=blksize=1432\nSize=1124\n")
+	require.Len(t, write.RawBytes, 1024)
+	require.True(t, write.Truncated)
+	require.Equal(t, int64(1124), write.BodySize)
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/http"
+	"net/mail"
+	"time"
+)
+
+// httpClientTimestamp returns the client timestamp from the http Date header
+func httpClientTimestamp(header http.Header) *time.Time {
+	value := header.Get("Date")
+	if value == "" {
+		return nil
+	}
+	timestamp, err := http.ParseTime(value)
+	if err != nil {
+		return nil
+	}
+	return &timestamp
+}
+
+// smtpClientTimestamp returns the client timestamp from the Date header of a mail
+func smtpClientTimestamp(data []byte) *time.Time {
+	message, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	timestamp, err := message.Header.Date()
+	if err != nil {
+		return nil
+	}
+	return &timestamp
+}
+
+// ntpClientTimestamp returns the client timestamp from the transmit timestamp
+// of an ntp client request
+func ntpClientTimestamp(packet []byte) *time.Time {
+	if len(packet) < ntpPacketSize || packet[0]&0x07 != ntpModeClient {
+		return nil
+	}
+	seconds := binary.BigEndian.Uint32(packet[40:44])
+	fraction := binary.BigEndian.Uint32(packet[44:48])
+	if seconds == 0 && fraction == 0 {
+		return nil
+	}
+	timestamp := time.Unix(int64(seconds)-ntpEpochOffset, int64((uint64(fraction)*uint64(time.Second))>>32))
+	return &timestamp
+}
+
+// ClockSkew returns the estimated clock skew of the remote host, as the
+// difference between its embedded timestamp and the server receive time.
+// A positive skew means the remote clock is ahead of the server one.
+func (interaction *Interaction) ClockSkew() (time.Duration, bool) {
+	if interaction.ClientTimestamp == nil {
+		return 0, false
+	}
+	return interaction.ClientTimestamp.Sub(interaction.Timestamp), true
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientTimestamp(t *testing.T) {
+	header := http.Header{}
+	require.Nil(t, httpClientTimestamp(header))
+	header.Set("Date", "Mon, 02 Jan 2006 15:04:05 GMT")
+	timestamp := httpClientTimestamp(header)
+	require.NotNil(t, timestamp)
+	require.Equal(t, time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC), timestamp.UTC())
+
+	timestamp = smtpClientTimestamp([]byte("From: a@example.com\r\nDate: Mon, 02 Jan 2006 17:04:05 +0200\r\n\r\nbody"))
+	require.NotNil(t, timestamp)
+	require.Equal(t, time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC), timestamp.UTC())
+	require.Nil(t, smtpClientTimestamp([]byte("no headers")))
+
+	request := make([]byte, ntpPacketSize)
+	request[0] = 0x23 // version 4, client mode
+	require.Nil(t, ntpClientTimestamp(request))
+	putNTPTimestamp(request[40:], time.Date(2006, 1, 2, 15, 4, 5, 500000000, time.UTC))
+	ntpTimestamp := ntpClientTimestamp(request)
+	require.NotNil(t, ntpTimestamp)
+	require.Less(t, ntpTimestamp.Sub(time.Date(2006, 1, 2, 15, 4, 5, 500000000, time.UTC)).Abs(), time.Microsecond)
+	request[0] = 0x24 // server mode
+	require.Nil(t, ntpClientTimestamp(request))
+
+	interaction := &Interaction{Timestamp: time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)}
+	_, ok := interaction.ClockSkew()
+	require.False(t, ok)
+	interaction.ClientTimestamp = timestamp
+	skew, ok := interaction.ClockSkew()
+	require.True(t, ok)
+	require.Equal(t, 5*time.Second, skew)
+}
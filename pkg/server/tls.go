@@ -0,0 +1,195 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// TLSListeners are the listeners whose tls policy can be configured
+var TLSListeners = []string{"http", "smtp", "ldap", "redis", "tcp", "imap", "pop3", "grpc", "dot"}
+
+// TLSClientCertListeners are the listeners recording the client certificates they request
+var TLSClientCertListeners = []string{"http", "smtp", "ldap"}
+
+// TLSPolicy is the tls policy of a listener, zero values leave the go defaults
+type TLSPolicy struct {
+	// Min and Max are the range of accepted tls versions
+	Min uint16
+	Max uint16
+	// CipherSuites are the accepted tls 1.0-1.2 cipher suites, tls 1.3
+	// ones are not configurable
+	CipherSuites []uint16
+	// Legacy accepts tls 1.0 and the insecure cipher suites for old clients
+	Legacy bool
+	// ClientCert requests a client certificate, neither required nor verified
+	ClientCert bool
+}
+
+// DefaultTLSPolicies are the policies of the listeners not configured explicitly,
+// ldap accepts legacy versions as we might be interacting with jurassic java
+var DefaultTLSPolicies = map[string]TLSPolicy{
+	"ldap": {Min: tls.VersionTLS10},
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseTLSPolicies parses the per listener tls policies from listener=min[-max]
+// version ranges (eg. ldap=1.0-1.2), listener=suite cipher suites using the go
+// names (eg. ldap=TLS_RSA_WITH_AES_128_CBC_SHA), the listeners accepting legacy clients
+// and the listeners requesting client certificates
+func ParseTLSPolicies(versions, ciphers, legacy, clientCerts []string) (map[string]TLSPolicy, error) {
+	policies := make(map[string]TLSPolicy)
+	policyOf := func(listener string) TLSPolicy {
+		if policy, ok := policies[listener]; ok {
+			return policy
+		}
+		return DefaultTLSPolicies[listener]
+	}
+
+	for _, listener := range legacy {
+		listener = strings.ToLower(strings.TrimSpace(listener))
+		if !slices.Contains(TLSListeners, listener) {
+			return nil, fmt.Errorf("invalid tls legacy listener %s, expected one of %s", listener, strings.Join(TLSListeners, ","))
+		}
+		policy := policyOf(listener)
+		policy.Legacy = true
+		policies[listener] = policy
+	}
+	for _, listener := range clientCerts {
+		listener = strings.ToLower(strings.TrimSpace(listener))
+		if !slices.Contains(TLSClientCertListeners, listener) {
+			return nil, fmt.Errorf("invalid tls client certificate listener %s, expected one of %s", listener, strings.Join(TLSClientCertListeners, ","))
+		}
+		policy := policyOf(listener)
+		policy.ClientCert = true
+		policies[listener] = policy
+	}
+	for _, value := range versions {
+		listener, versionRange, err := parseTLSListenerValue(value)
+		if err != nil {
+			return nil, err
+		}
+		policy := policyOf(listener)
+		minVersion, maxVersion, _ := strings.Cut(versionRange, "-")
+		if policy.Min, err = parseTLSVersion(minVersion); err != nil {
+			return nil, fmt.Errorf("%s for %s", err, listener)
+		}
+		policy.Max = 0
+		if maxVersion != "" {
+			if policy.Max, err = parseTLSVersion(maxVersion); err != nil {
+				return nil, fmt.Errorf("%s for %s", err, listener)
+			}
+			if policy.Max < policy.Min {
+				return nil, fmt.Errorf("invalid tls version range %s for %s", versionRange, listener)
+			}
+		}
+		policies[listener] = policy
+	}
+	for _, value := range ciphers {
+		listener, name, err := parseTLSListenerValue(value)
+		if err != nil {
+			return nil, err
+		}
+		policy := policyOf(listener)
+		suite, insecure := tlsCipherSuite(name)
+		switch {
+		case suite == nil:
+			return nil, fmt.Errorf("unknown or tls 1.3 cipher suite %s for %s", name, listener)
+		case insecure && !policy.Legacy:
+			return nil, fmt.Errorf("insecure cipher suite %s for %s requires the listener to accept legacy clients", name, listener)
+		}
+		policy.CipherSuites = append(policy.CipherSuites, suite.ID)
+		policies[listener] = policy
+	}
+	return policies, nil
+}
+
+// parseTLSListenerValue splits a listener=value tls policy value
+func parseTLSListenerValue(value string) (string, string, error) {
+	listener, data, ok := strings.Cut(value, "=")
+	listener = strings.ToLower(strings.TrimSpace(listener))
+	if !ok || !slices.Contains(TLSListeners, listener) {
+		return "", "", fmt.Errorf("invalid tls policy %s, expected listener=value with listener one of %s", value, strings.Join(TLSListeners, ","))
+	}
+	return listener, strings.TrimSpace(data), nil
+}
+
+// parseTLSVersion parses a 1.0-1.3 tls version
+func parseTLSVersion(value string) (uint16, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "3.0" || value == "ssl3" || value == "sslv3" {
+		return 0, fmt.Errorf("sslv3 is not supported by the go tls stack")
+	}
+	version, ok := tlsVersions[value]
+	if !ok {
+		return 0, fmt.Errorf("invalid tls version %s", value)
+	}
+	return version, nil
+}
+
+// tlsCipherSuite returns the configurable tls 1.0-1.2 cipher suite with the name
+func tlsCipherSuite(name string) (*tls.CipherSuite, bool) {
+	for _, suites := range [][]*tls.CipherSuite{tls.CipherSuites(), tls.InsecureCipherSuites()} {
+		for _, suite := range suites {
+			if suite.Name == name && !slices.Equal(suite.SupportedVersions, []uint16{tls.VersionTLS13}) {
+				return suite, suite.Insecure
+			}
+		}
+	}
+	return nil, false
+}
+
+// legacyCipherSuites returns all the tls 1.0-1.2 cipher suites, including the insecure ones
+func legacyCipherSuites() []uint16 {
+	var ids []uint16
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if !slices.Equal(suite.SupportedVersions, []uint16{tls.VersionTLS13}) {
+			ids = append(ids, suite.ID)
+		}
+	}
+	return ids
+}
+
+// tlsPolicy returns the tls policy of the listener
+func (options *Options) tlsPolicy(listener string) TLSPolicy {
+	if policy, ok := options.TLSPolicies[listener]; ok {
+		return policy
+	}
+	return DefaultTLSPolicies[listener]
+}
+
+// listenerTLSConfig returns a copy of the tls configuration applying the
+// policy configured for the listener
+func (options *Options) listenerTLSConfig(listener string, config *tls.Config) *tls.Config {
+	if config == nil {
+		return nil
+	}
+	config = config.Clone()
+	policy := options.tlsPolicy(listener)
+	config.MinVersion = policy.Min
+	config.MaxVersion = policy.Max
+	config.CipherSuites = policy.CipherSuites
+	if policy.Legacy {
+		if config.MinVersion == 0 {
+			config.MinVersion = tls.VersionTLS10
+		}
+		if len(config.CipherSuites) == 0 {
+			config.CipherSuites = legacyCipherSuites()
+		}
+	}
+	if policy.ClientCert {
+		config.ClientAuth = tls.RequestClientCert
+		// https requests carry the certificates of their connection
+		if listener != "http" {
+			config.GetConfigForClient = clientCertificateRecorder(config)
+		}
+	}
+	return config
+}
@@ -0,0 +1,610 @@
+package server
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// tlsClientHello and tlsServerHello are the types of the hello handshake messages
+	tlsClientHello = 0x01
+	tlsServerHello = 0x02
+	// maxHelloSize is the size of the handshake records kept until the hello is complete
+	maxHelloSize = 64 * 1024
+)
+
+// tls extensions read from the hellos
+const (
+	tlsExtServerName          = 0x0000
+	tlsExtSupportedGroups     = 0x000a
+	tlsExtECPointFormats      = 0x000b
+	tlsExtSignatureAlgorithms = 0x000d
+	tlsExtALPN                = 0x0010
+	tlsExtSupportedVersions   = 0x002b
+)
+
+// errShortHello is returned while the hello is not completely received
+var errShortHello = errors.New("short tls hello")
+
+// TLSFingerprint is the fingerprint of the client hello of a tls connection
+// with the parameters negotiated by the server
+type TLSFingerprint struct {
+	// JA3 is the ja3 string of the client hello
+	JA3 string `json:"ja3"`
+	// JA3Hash is the md5 hash of the ja3 string
+	JA3Hash string `json:"ja3-hash"`
+	// JA4 is the ja4 fingerprint of the client hello
+	JA4 string `json:"ja4"`
+	// ServerName is the server name indicated by the client
+	ServerName string `json:"server-name,omitempty"`
+	// ALPN are the application protocols offered by the client
+	ALPN []string `json:"alpn,omitempty"`
+	// Version is the negotiated tls version (eg. TLS 1.3)
+	Version string `json:"version,omitempty"`
+	// CipherSuite is the negotiated cipher suite
+	CipherSuite string `json:"cipher-suite,omitempty"`
+	// ClientCertificate is the certificate presented by the client when requested
+	ClientCertificate *TLSClientCertificate `json:"client-certificate,omitempty"`
+}
+
+// TLSClientCertificate is a certificate presented by a tls client, neither
+// required nor verified
+type TLSClientCertificate struct {
+	// Subject is the distinguished name of the subject
+	Subject string `json:"subject,omitempty"`
+	// Issuer is the distinguished name of the issuer
+	Issuer string `json:"issuer,omitempty"`
+	// SANs are the dns names, email addresses, ip addresses and uris of the certificate
+	SANs []string `json:"sans,omitempty"`
+	// SHA256 is the sha256 fingerprint of the certificate
+	SHA256 string `json:"sha256"`
+	// Chain is the number of certificates presented
+	Chain int `json:"chain"`
+}
+
+// newTLSClientCertificate returns the leaf of the certificates presented by a
+// client, only fingerprinted when it can't be parsed
+func newTLSClientCertificate(rawCerts [][]byte) *TLSClientCertificate {
+	if len(rawCerts) == 0 {
+		return nil
+	}
+	sum := sha256.Sum256(rawCerts[0])
+	certificate := &TLSClientCertificate{SHA256: hex.EncodeToString(sum[:]), Chain: len(rawCerts)}
+	parsed, err := x509.ParseCertificate(rawCerts[0])
+	if err != nil {
+		return certificate
+	}
+	certificate.Subject = parsed.Subject.String()
+	certificate.Issuer = parsed.Issuer.String()
+	certificate.SANs = append(certificate.SANs, parsed.DNSNames...)
+	certificate.SANs = append(certificate.SANs, parsed.EmailAddresses...)
+	for _, ip := range parsed.IPAddresses {
+		certificate.SANs = append(certificate.SANs, ip.String())
+	}
+	for _, uri := range parsed.URIs {
+		certificate.SANs = append(certificate.SANs, uri.String())
+	}
+	return certificate
+}
+
+// clientCertificateRecorder returns a per connection configuration recording
+// the client certificates on the connections fingerprinted
+func clientCertificateRecorder(config *tls.Config) func(*tls.ClientHelloInfo) (*tls.Config, error) {
+	return func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		conn, ok := hello.Conn.(*helloConn)
+		if !ok {
+			return nil, nil
+		}
+		connConfig := config.Clone()
+		connConfig.GetConfigForClient = nil
+		connConfig.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			conn.mu.Lock()
+			conn.certificate = newTLSClientCertificate(rawCerts)
+			conn.mu.Unlock()
+			return nil
+		}
+		return connConfig, nil
+	}
+}
+
+// clientHello are the fields of a client hello making its fingerprints
+type clientHello struct {
+	version             uint16
+	cipherSuites        []uint16
+	extensions          []uint16
+	supportedGroups     []uint16
+	pointFormats        []uint8
+	signatureAlgorithms []uint16
+	supportedVersions   []uint16
+	serverName          string
+	alpn                []string
+}
+
+// isGREASE reports whether a value is a reserved grease value (rfc 8701)
+func isGREASE(value uint16) bool {
+	return value&0x0f0f == 0x0a0a && value>>8 == value&0xff
+}
+
+// readHandshake returns the first handshake message of the tls records, with
+// its type, reassembled from the records it's fragmented into
+func readHandshake(records []byte) (byte, []byte, error) {
+	var message []byte
+	for {
+		if len(message) >= 4 {
+			length := int(message[1])<<16 | int(message[2])<<8 | int(message[3])
+			if len(message) >= 4+length {
+				return message[0], message[4 : 4+length], nil
+			}
+		}
+		if len(records) < 5 {
+			return 0, nil, errShortHello
+		}
+		if records[0] != tlsRecordHandshake || records[1] != 0x03 {
+			return 0, nil, errors.New("not a tls handshake record")
+		}
+		length := int(binary.BigEndian.Uint16(records[3:5]))
+		if len(records) < 5+length {
+			return 0, nil, errShortHello
+		}
+		message = append(message, records[5:5+length]...)
+		records = records[5+length:]
+	}
+}
+
+// helloReader reads the length prefixed fields of a hello
+type helloReader []byte
+
+func (r *helloReader) bytes(n int) ([]byte, bool) {
+	if n < 0 || len(*r) < n {
+		return nil, false
+	}
+	value := (*r)[:n]
+	*r = (*r)[n:]
+	return value, true
+}
+
+func (r *helloReader) uint8() (uint8, bool) {
+	value, ok := r.bytes(1)
+	if !ok {
+		return 0, false
+	}
+	return value[0], true
+}
+
+func (r *helloReader) uint16() (uint16, bool) {
+	value, ok := r.bytes(2)
+	if !ok {
+		return 0, false
+	}
+	return binary.BigEndian.Uint16(value), true
+}
+
+// vector returns a field prefixed with its length of size bytes
+func (r *helloReader) vector(size int) (helloReader, bool) {
+	var length int
+	switch size {
+	case 1:
+		n, ok := r.uint8()
+		if !ok {
+			return nil, false
+		}
+		length = int(n)
+	default:
+		n, ok := r.uint16()
+		if !ok {
+			return nil, false
+		}
+		length = int(n)
+	}
+	value, ok := r.bytes(length)
+	return value, ok
+}
+
+func (r helloReader) uint16s() []uint16 {
+	values := make([]uint16, 0, len(r)/2)
+	for len(r) >= 2 {
+		value, _ := r.uint16()
+		values = append(values, value)
+	}
+	return values
+}
+
+// parseClientHello parses the client hello of the tls records
+func parseClientHello(records []byte) (*clientHello, error) {
+	kind, message, err := readHandshake(records)
+	if err != nil {
+		return nil, err
+	}
+	if kind != tlsClientHello {
+		return nil, errors.New("not a client hello")
+	}
+	r := helloReader(message)
+	hello := &clientHello{}
+	var ok bool
+	if hello.version, ok = r.uint16(); !ok {
+		return nil, errors.New("invalid client hello version")
+	}
+	if _, ok = r.bytes(32); !ok {
+		return nil, errors.New("invalid client hello random")
+	}
+	if _, ok = r.vector(1); !ok {
+		return nil, errors.New("invalid client hello session id")
+	}
+	ciphers, ok := r.vector(2)
+	if !ok {
+		return nil, errors.New("invalid client hello cipher suites")
+	}
+	hello.cipherSuites = ciphers.uint16s()
+	if _, ok = r.vector(1); !ok {
+		return nil, errors.New("invalid client hello compression methods")
+	}
+	// hellos without extensions end after the compression methods
+	extensions, _ := r.vector(2)
+	for len(extensions) > 0 {
+		kind, ok := extensions.uint16()
+		if !ok {
+			return nil, errors.New("invalid client hello extension")
+		}
+		data, ok := extensions.vector(2)
+		if !ok {
+			return nil, errors.New("invalid client hello extension")
+		}
+		hello.extensions = append(hello.extensions, kind)
+		switch kind {
+		case tlsExtServerName:
+			names, _ := data.vector(2)
+			if nameType, ok := names.uint8(); ok && nameType == 0 {
+				name, _ := names.vector(2)
+				hello.serverName = string(name)
+			}
+		case tlsExtSupportedGroups:
+			groups, _ := data.vector(2)
+			hello.supportedGroups = groups.uint16s()
+		case tlsExtECPointFormats:
+			formats, _ := data.vector(1)
+			hello.pointFormats = formats
+		case tlsExtSignatureAlgorithms:
+			algorithms, _ := data.vector(2)
+			hello.signatureAlgorithms = algorithms.uint16s()
+		case tlsExtALPN:
+			protocols, _ := data.vector(2)
+			for len(protocols) > 0 {
+				protocol, ok := protocols.vector(1)
+				if !ok {
+					break
+				}
+				hello.alpn = append(hello.alpn, string(protocol))
+			}
+		case tlsExtSupportedVersions:
+			versions, _ := data.vector(1)
+			hello.supportedVersions = versions.uint16s()
+		}
+	}
+	return hello, nil
+}
+
+// parseServerHello returns the version and cipher suite negotiated in the
+// server hello of the tls records
+func parseServerHello(records []byte) (uint16, uint16, error) {
+	kind, message, err := readHandshake(records)
+	if err != nil {
+		return 0, 0, err
+	}
+	if kind != tlsServerHello {
+		return 0, 0, errors.New("not a server hello")
+	}
+	r := helloReader(message)
+	version, ok := r.uint16()
+	if !ok {
+		return 0, 0, errors.New("invalid server hello version")
+	}
+	if _, ok = r.bytes(32); !ok {
+		return 0, 0, errors.New("invalid server hello random")
+	}
+	if _, ok = r.vector(1); !ok {
+		return 0, 0, errors.New("invalid server hello session id")
+	}
+	cipherSuite, ok := r.uint16()
+	if !ok {
+		return 0, 0, errors.New("invalid server hello cipher suite")
+	}
+	_, _ = r.uint8()
+	extensions, _ := r.vector(2)
+	for len(extensions) > 0 {
+		kind, _ := extensions.uint16()
+		data, ok := extensions.vector(2)
+		if !ok {
+			break
+		}
+		// tls 1.3 is negotiated in the supported versions extension
+		if kind == tlsExtSupportedVersions {
+			if selected, ok := data.uint16(); ok {
+				version = selected
+			}
+		}
+	}
+	return version, cipherSuite, nil
+}
+
+// fingerprint returns the ja3 and ja4 fingerprints of the client hello
+func (h *clientHello) fingerprint() *TLSFingerprint {
+	ciphers := withoutGREASE(h.cipherSuites)
+	extensions := withoutGREASE(h.extensions)
+	groups := withoutGREASE(h.supportedGroups)
+	formats := make([]uint16, len(h.pointFormats))
+	for i, format := range h.pointFormats {
+		formats[i] = uint16(format)
+	}
+	ja3 := strings.Join([]string{
+		strconv.Itoa(int(h.version)),
+		joinUint16(ciphers, "-", "%d"),
+		joinUint16(extensions, "-", "%d"),
+		joinUint16(groups, "-", "%d"),
+		joinUint16(formats, "-", "%d"),
+	}, ",")
+	sum := md5.Sum([]byte(ja3))
+	return &TLSFingerprint{
+		JA3:        ja3,
+		JA3Hash:    hex.EncodeToString(sum[:]),
+		JA4:        h.ja4(ciphers, extensions),
+		ServerName: h.serverName,
+		ALPN:       h.alpn,
+	}
+}
+
+// ja4 returns the ja4 fingerprint (ja4_a_b_c) of the client hello
+func (h *clientHello) ja4(ciphers, extensions []uint16) string {
+	// the highest supported version, the legacy one being 1.2 for tls 1.3 clients
+	version := h.version
+	if supported := withoutGREASE(h.supportedVersions); len(supported) > 0 {
+		version = 0
+		for _, value := range supported {
+			version = max(version, value)
+		}
+	}
+	sni := "i"
+	if h.serverName != "" {
+		sni = "d"
+	}
+	alpn := "00"
+	if len(h.alpn) > 0 && h.alpn[0] != "" {
+		first, last := h.alpn[0][0], h.alpn[0][len(h.alpn[0])-1]
+		if isAlphanumeric(first) && isAlphanumeric(last) {
+			alpn = string([]byte{first, last})
+		} else {
+			encoded := hex.EncodeToString([]byte(h.alpn[0]))
+			alpn = encoded[:1] + encoded[len(encoded)-1:]
+		}
+	}
+	a := fmt.Sprintf("t%s%s%02d%02d%s", ja4Version(version), sni, min(len(ciphers), 99), min(len(extensions), 99), alpn)
+
+	sortedCiphers := append([]uint16(nil), ciphers...)
+	sort.Slice(sortedCiphers, func(i, j int) bool { return sortedCiphers[i] < sortedCiphers[j] })
+	b := ja4Hash(joinUint16(sortedCiphers, ",", "%04x"))
+
+	// the server name and alpn are already part of ja4_a
+	var sortedExtensions []uint16
+	for _, extension := range extensions {
+		if extension != tlsExtServerName && extension != tlsExtALPN {
+			sortedExtensions = append(sortedExtensions, extension)
+		}
+	}
+	sort.Slice(sortedExtensions, func(i, j int) bool { return sortedExtensions[i] < sortedExtensions[j] })
+	c := "000000000000"
+	if len(sortedExtensions) > 0 {
+		value := joinUint16(sortedExtensions, ",", "%04x")
+		if algorithms := withoutGREASE(h.signatureAlgorithms); len(algorithms) > 0 {
+			value += "_" + joinUint16(algorithms, ",", "%04x")
+		}
+		c = ja4Hash(value)
+	}
+	return a + "_" + b + "_" + c
+}
+
+// ja4Version returns the two characters of a tls version in ja4
+func ja4Version(version uint16) string {
+	switch version {
+	case tls.VersionTLS13:
+		return "13"
+	case tls.VersionTLS12:
+		return "12"
+	case tls.VersionTLS11:
+		return "11"
+	case tls.VersionTLS10:
+		return "10"
+	case 0x0300:
+		return "s3"
+	}
+	return "00"
+}
+
+// ja4Hash returns the truncated sha256 of a ja4 part, zeros when empty
+func ja4Hash(value string) string {
+	if value == "" {
+		return "000000000000"
+	}
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+func isAlphanumeric(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z'
+}
+
+func withoutGREASE(values []uint16) []uint16 {
+	filtered := make([]uint16, 0, len(values))
+	for _, value := range values {
+		if !isGREASE(value) {
+			filtered = append(filtered, value)
+		}
+	}
+	return filtered
+}
+
+func joinUint16(values []uint16, separator, format string) string {
+	formatted := make([]string, len(values))
+	for i, value := range values {
+		formatted[i] = fmt.Sprintf(format, value)
+	}
+	return strings.Join(formatted, separator)
+}
+
+// helloConn records the tls hellos exchanged on a connection, from its start
+// or after an upgrade (eg. starttls), and indexes the fingerprint of the
+// client by remote address while the connection is open
+type helloConn struct {
+	net.Conn
+	options *Options
+	key     string
+
+	mu          sync.Mutex
+	client      []byte
+	server      []byte
+	clientDone  bool
+	serverDone  bool
+	fingerprint *TLSFingerprint
+	certificate *TLSClientCertificate
+}
+
+// fingerprintListener returns a listener recording the tls fingerprints of
+// the accepted connections
+func (options *Options) fingerprintListener(listener net.Listener) net.Listener {
+	return &helloListener{Listener: listener, options: options}
+}
+
+// helloListener accepts the connections as hello recording ones
+type helloListener struct {
+	net.Listener
+	options *Options
+}
+
+func (l *helloListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return l.options.fingerprintConn(conn), nil
+}
+
+// fingerprintConn returns the connection recording its tls fingerprint
+func (options *Options) fingerprintConn(conn net.Conn) net.Conn {
+	if _, ok := conn.(*helloConn); ok {
+		return conn
+	}
+	return &helloConn{Conn: conn, options: options, key: conn.RemoteAddr().String()}
+}
+
+// tlsFingerprint returns the tls fingerprint of the open connection from the
+// remote address, nil if none
+func (options *Options) tlsFingerprint(remoteAddr string) *TLSFingerprint {
+	value, ok := options.tlsHellos.Load(remoteAddr)
+	if !ok {
+		return nil
+	}
+	conn := value.(*helloConn)
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	fingerprint := *conn.fingerprint
+	fingerprint.ClientCertificate = conn.certificate
+	return &fingerprint
+}
+
+// requestTLSFingerprint returns the tls fingerprint of the connection of a https request
+func (options *Options) requestTLSFingerprint(r *http.Request) *TLSFingerprint {
+	if r.TLS == nil {
+		return nil
+	}
+	fingerprint := options.tlsFingerprint(r.RemoteAddr)
+	if fingerprint != nil && len(r.TLS.PeerCertificates) > 0 {
+		rawCerts := make([][]byte, len(r.TLS.PeerCertificates))
+		for i, certificate := range r.TLS.PeerCertificates {
+			rawCerts[i] = certificate.Raw
+		}
+		fingerprint.ClientCertificate = newTLSClientCertificate(rawCerts)
+	}
+	return fingerprint
+}
+
+func (c *helloConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.observe(p[:n], true)
+	}
+	return n, err
+}
+
+func (c *helloConn) Write(p []byte) (int, error) {
+	c.observe(p, false)
+	return c.Conn.Write(p)
+}
+
+func (c *helloConn) Close() error {
+	c.options.tlsHellos.CompareAndDelete(c.key, c)
+	return c.Conn.Close()
+}
+
+// observe records the handshake records read from the client or written by
+// the server until their hello is complete
+func (c *helloConn) observe(data []byte, fromClient bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	buffer, done := &c.server, &c.serverDone
+	if fromClient {
+		buffer, done = &c.client, &c.clientDone
+	}
+	// the server hello follows the client one
+	if *done || (!fromClient && c.fingerprint == nil) {
+		return
+	}
+	// plaintext (eg. before starttls) is skipped until a handshake record starts
+	if len(*buffer) == 0 && data[0] != tlsRecordHandshake {
+		return
+	}
+	*buffer = append(*buffer, data...)
+
+	var err error
+	if fromClient {
+		var hello *clientHello
+		if hello, err = parseClientHello(*buffer); err == nil {
+			c.fingerprint = hello.fingerprint()
+			c.options.tlsHellos.Store(c.key, c)
+		}
+	} else {
+		var version, cipherSuite uint16
+		if version, cipherSuite, err = parseServerHello(*buffer); err == nil {
+			c.fingerprint.Version = tls.VersionName(version)
+			c.fingerprint.CipherSuite = tls.CipherSuiteName(cipherSuite)
+		}
+	}
+	if errors.Is(err, errShortHello) && len(*buffer) < maxHelloSize {
+		return
+	}
+	*done = true
+	*buffer = nil
+}
+
+// marker is empty as the fingerprint is only set on the interactions
+func (f *TLSFingerprint) marker() string {
+	return ""
+}
+
+// apply sets the tls fingerprint of the interaction
+func (f *TLSFingerprint) apply(interaction *Interaction) {
+	if f == nil {
+		return
+	}
+	interaction.TLS = f
+}
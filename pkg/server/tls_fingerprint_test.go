@@ -0,0 +1,167 @@
+package server
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// tlsVector returns the data prefixed with its length of size bytes
+func tlsVector(size int, data []byte) []byte {
+	prefix := make([]byte, size)
+	switch size {
+	case 1:
+		prefix[0] = byte(len(data))
+	case 2:
+		binary.BigEndian.PutUint16(prefix, uint16(len(data)))
+	case 3:
+		prefix = []byte{byte(len(data) >> 16), byte(len(data) >> 8), byte(len(data))}
+	}
+	return append(prefix, data...)
+}
+
+func tlsUint16s(values ...uint16) []byte {
+	data := make([]byte, 0, len(values)*2)
+	for _, value := range values {
+		data = binary.BigEndian.AppendUint16(data, value)
+	}
+	return data
+}
+
+func tlsExtension(kind uint16, data []byte) []byte {
+	return append(tlsUint16s(kind), tlsVector(2, data)...)
+}
+
+// testClientHello returns a client hello record with grease values
+func testClientHello() []byte {
+	var extensions []byte
+	extensions = append(extensions, tlsExtension(0x1a1a, nil)...)
+	extensions = append(extensions, tlsExtension(tlsExtServerName, tlsVector(2, append([]byte{0}, tlsVector(2, []byte("a.interact.sh"))...)))...)
+	extensions = append(extensions, tlsExtension(tlsExtSupportedGroups, tlsVector(2, tlsUint16s(0x001d, 0x0017)))...)
+	extensions = append(extensions, tlsExtension(tlsExtECPointFormats, tlsVector(1, []byte{0}))...)
+	extensions = append(extensions, tlsExtension(tlsExtALPN, tlsVector(2, append(tlsVector(1, []byte("h2")), tlsVector(1, []byte("http/1.1"))...)))...)
+	extensions = append(extensions, tlsExtension(tlsExtSignatureAlgorithms, tlsVector(2, tlsUint16s(0x0403, 0x0804)))...)
+	extensions = append(extensions, tlsExtension(tlsExtSupportedVersions, tlsVector(1, tlsUint16s(0x0304, 0x0303)))...)
+
+	body := tlsUint16s(tls.VersionTLS12)
+	body = append(body, make([]byte, 32)...)
+	body = append(body, tlsVector(1, nil)...)
+	body = append(body, tlsVector(2, tlsUint16s(0x0a0a, 0x1301, 0x1302, 0xc02b))...)
+	body = append(body, tlsVector(1, []byte{0})...)
+	body = append(body, tlsVector(2, extensions)...)
+	message := append([]byte{tlsClientHello}, tlsVector(3, body)...)
+	return append([]byte{tlsRecordHandshake, 0x03, 0x01}, tlsVector(2, message)...)
+}
+
+func TestClientHelloFingerprint(t *testing.T) {
+	hello, err := parseClientHello(testClientHello())
+	require.Nil(t, err)
+	fingerprint := hello.fingerprint()
+	require.Equal(t, "771,4865-4866-49195,0-10-11-16-13-43,29-23,0", fingerprint.JA3)
+	require.Equal(t, "021a49ccb388bec134414a2b0af726d0", fingerprint.JA3Hash)
+	require.Equal(t, "t13d0306h2_5559582ccdc4_fb71836bce29", fingerprint.JA4)
+	require.Equal(t, "a.interact.sh", fingerprint.ServerName)
+	require.Equal(t, []string{"h2", "http/1.1"}, fingerprint.ALPN)
+
+	// a hello fragmented into several records is reassembled
+	record := testClientHello()
+	payload := record[5:]
+	fragmented := append([]byte{tlsRecordHandshake, 0x03, 0x01}, tlsVector(2, payload[:10])...)
+	fragmented = append(fragmented, append([]byte{tlsRecordHandshake, 0x03, 0x01}, tlsVector(2, payload[10:])...)...)
+	reassembled, err := parseClientHello(fragmented)
+	require.Nil(t, err)
+	require.Equal(t, hello, reassembled)
+
+	_, err = parseClientHello(record[:len(record)-1])
+	require.ErrorIs(t, err, errShortHello)
+	_, err = parseClientHello([]byte("EHLO interact.sh\r\n"))
+	require.NotNil(t, err)
+}
+
+func TestHelloConn(t *testing.T) {
+	certificate, err := localhostCertificate()
+	require.Nil(t, err)
+
+	options := &Options{}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer listener.Close()
+	tlsListener := tls.NewListener(options.fingerprintListener(listener), &tls.Config{Certificates: []tls.Certificate{certificate}})
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := tlsListener.Accept()
+		if err != nil {
+			close(accepted)
+			return
+		}
+		_ = conn.(*tls.Conn).Handshake()
+		accepted <- conn
+	}()
+
+	conn, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{InsecureSkipVerify: true, ServerName: "a.interact.sh", NextProtos: []string{"h2"}})
+	require.Nil(t, err)
+	defer conn.Close()
+	server := <-accepted
+	require.NotNil(t, server)
+
+	fingerprint := options.tlsFingerprint(conn.LocalAddr().String())
+	require.NotNil(t, fingerprint)
+	require.Equal(t, "a.interact.sh", fingerprint.ServerName)
+	require.True(t, strings.HasPrefix(fingerprint.JA4, "t13d"), fingerprint.JA4)
+	require.Len(t, fingerprint.JA4, 36)
+	require.Len(t, fingerprint.JA3Hash, 32)
+	require.Equal(t, "TLS 1.3", fingerprint.Version)
+	require.NotEmpty(t, fingerprint.CipherSuite)
+
+	// the fingerprint is forgotten with its connection
+	require.Nil(t, server.Close())
+	require.Nil(t, options.tlsFingerprint(conn.LocalAddr().String()))
+}
+
+func TestClientCertificateRecorder(t *testing.T) {
+	certificate, err := localhostCertificate()
+	require.Nil(t, err)
+
+	options := &Options{TLSPolicies: map[string]TLSPolicy{"smtp": {ClientCert: true}}}
+	config := options.listenerTLSConfig("smtp", &tls.Config{Certificates: []tls.Certificate{certificate}})
+	require.Equal(t, tls.RequestClientCert, config.ClientAuth)
+	// https requests carry their certificates
+	require.Nil(t, options.listenerTLSConfig("http", &tls.Config{}).GetConfigForClient)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer listener.Close()
+	tlsListener := tls.NewListener(options.fingerprintListener(listener), config)
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := tlsListener.Accept()
+		if err != nil {
+			close(accepted)
+			return
+		}
+		_ = conn.(*tls.Conn).Handshake()
+		accepted <- conn
+	}()
+
+	conn, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{InsecureSkipVerify: true, Certificates: []tls.Certificate{certificate}})
+	require.Nil(t, err)
+	defer conn.Close()
+	server := <-accepted
+	require.NotNil(t, server)
+	defer server.Close()
+
+	fingerprint := options.tlsFingerprint(conn.LocalAddr().String())
+	require.NotNil(t, fingerprint)
+	require.NotNil(t, fingerprint.ClientCertificate)
+	require.Equal(t, []string{"localhost", "127.0.0.1", "::1"}, fingerprint.ClientCertificate.SANs)
+	require.Equal(t, 1, fingerprint.ClientCertificate.Chain)
+	require.Len(t, fingerprint.ClientCertificate.SHA256, 64)
+
+	require.Nil(t, newTLSClientCertificate(nil))
+	require.Empty(t, newTLSClientCertificate([][]byte{[]byte("invalid")}).Subject)
+}
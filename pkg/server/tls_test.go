@@ -0,0 +1,81 @@
+package server
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTLSPolicies(t *testing.T) {
+	policies, err := ParseTLSPolicies([]string{"ldap=1.1-1.2", "http=1.3"}, []string{"http=TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}, nil, nil)
+	require.Nil(t, err)
+	require.Equal(t, TLSPolicy{Min: tls.VersionTLS11, Max: tls.VersionTLS12}, policies["ldap"])
+	require.Equal(t, TLSPolicy{Min: tls.VersionTLS13, CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}}, policies["http"])
+
+	// insecure cipher suites are only accepted for legacy listeners
+	_, err = ParseTLSPolicies(nil, []string{"smtp=TLS_RSA_WITH_RC4_128_SHA"}, nil, nil)
+	require.NotNil(t, err)
+	policies, err = ParseTLSPolicies(nil, []string{"smtp=TLS_RSA_WITH_RC4_128_SHA"}, []string{"smtp"}, nil)
+	require.Nil(t, err)
+	require.True(t, policies["smtp"].Legacy)
+	require.Equal(t, []uint16{tls.TLS_RSA_WITH_RC4_128_SHA}, policies["smtp"].CipherSuites)
+
+	for _, invalid := range []string{"ftp=1.2", "ldap", "ldap=1.4", "ldap=1.2-1.0", "ldap=ssl3"} {
+		_, err := ParseTLSPolicies([]string{invalid}, nil, nil, nil)
+		require.NotNil(t, err, invalid)
+	}
+	_, err = ParseTLSPolicies(nil, []string{"http=TLS_AES_128_GCM_SHA256"}, nil, nil)
+	require.NotNil(t, err)
+	_, err = ParseTLSPolicies(nil, nil, []string{"dns"}, nil)
+	require.NotNil(t, err)
+
+	policies, err = ParseTLSPolicies(nil, nil, nil, []string{"smtp", "HTTP"})
+	require.Nil(t, err)
+	require.True(t, policies["smtp"].ClientCert)
+	require.True(t, policies["http"].ClientCert)
+	_, err = ParseTLSPolicies(nil, nil, nil, []string{"redis"})
+	require.NotNil(t, err)
+}
+
+func TestListenerTLSConfig(t *testing.T) {
+	policies, err := ParseTLSPolicies(nil, nil, []string{"smtp"}, nil)
+	require.Nil(t, err)
+	options := &Options{TLSPolicies: policies}
+
+	config := options.listenerTLSConfig("smtp", &tls.Config{})
+	require.EqualValues(t, tls.VersionTLS10, config.MinVersion)
+	require.Contains(t, config.CipherSuites, tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA)
+
+	config = options.listenerTLSConfig("http", &tls.Config{})
+	require.EqualValues(t, 0, config.MinVersion)
+	require.Empty(t, config.CipherSuites)
+	// ldap accepts legacy versions by default
+	config = options.listenerTLSConfig("ldap", &tls.Config{})
+	require.EqualValues(t, tls.VersionTLS10, config.MinVersion)
+}
+
+func TestLDAPTLSConfig(t *testing.T) {
+	policies, err := ParseTLSPolicies([]string{"ldap=1.2"}, nil, nil, nil)
+	require.Nil(t, err)
+	ldapServer := &LDAPServer{options: &Options{TLSPolicies: policies}}
+
+	// the localhost certificate is only used without server certificates
+	config, err := ldapServer.getTLSconfig()
+	require.Nil(t, err)
+	require.Len(t, config.Certificates, 1)
+	require.EqualValues(t, tls.VersionTLS12, config.MinVersion)
+
+	cert, err := localhostCertificate()
+	require.Nil(t, err)
+	shared := &tls.Config{Certificates: []tls.Certificate{cert, cert}, NextProtos: []string{"h2", "http/1.1"}}
+	ldapServer.tlsConfig = shared
+	config, err = ldapServer.getTLSconfig()
+	require.Nil(t, err)
+	require.Len(t, config.Certificates, 2)
+	require.Empty(t, config.NextProtos)
+	require.EqualValues(t, tls.VersionTLS12, config.MinVersion)
+	// the shared configuration of the other listeners is left untouched
+	require.Len(t, shared.NextProtos, 2)
+	require.EqualValues(t, 0, shared.MinVersion)
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/gologger"
+	stringsutil "github.com/projectdiscovery/utils/strings"
+)
+
+const (
+	// udpMaxDatagram is the maximum size of a datagram read by the udp catch-all server
+	udpMaxDatagram = 64 * 1024
+	// udpCaptureSize is the number of datagram bytes recorded by the udp catch-all server
+	udpCaptureSize = 1024
+)
+
+// UDPServer is a catch-all udp server receiving the datagrams sent to extra
+// ports (eg. tftp, snmp, ntp), only answering the scanner probes of the
+// deception profile. The datagrams with correlation ids are recorded for them,
+// the others for the server token.
+type UDPServer struct {
+	options *Options
+	conns   []net.PacketConn
+	mu      sync.Mutex
+}
+
+// NewUDPServer returns a new udp catch-all server
+func NewUDPServer(options *Options) (*UDPServer, error) {
+	return &UDPServer{options: options}, nil
+}
+
+// ListenAndServe listens on all the udp ports
+func (h *UDPServer) ListenAndServe(udpAlive chan bool) {
+	for _, port := range h.options.UDPPorts {
+		conn, err := h.options.Listeners.ListenPacket("udp", net.JoinHostPort(h.options.ListenIP, fmt.Sprint(port)))
+		if err != nil {
+			gologger.Error().Msgf("Could not serve udp on port %d: %s\n", port, err)
+			h.Close()
+			udpAlive <- false
+			return
+		}
+		h.mu.Lock()
+		h.conns = append(h.conns, conn)
+		h.mu.Unlock()
+	}
+	udpAlive <- true
+
+	var wg sync.WaitGroup
+	h.mu.Lock()
+	for _, conn := range h.conns {
+		wg.Add(1)
+		go func(conn net.PacketConn) {
+			defer wg.Done()
+			h.serve(conn)
+		}(conn)
+	}
+	h.mu.Unlock()
+	wg.Wait()
+}
+
+func (h *UDPServer) serve(conn net.PacketConn) {
+	var localPort int
+	if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok {
+		localPort = addr.Port
+	}
+	buffer := make([]byte, udpMaxDatagram)
+	for {
+		n, addr, err := conn.ReadFrom(buffer)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				gologger.Error().Msgf("Could not read udp datagram on port %d: %s\n", localPort, err)
+			}
+			return
+		}
+		h.options.Stats.RecordSource("udp", addr.String())
+		probe := matchScannerProbe(buffer[:n], true)
+		if probe != "" {
+			gologger.Debug().Msgf("Scanner probe %s from %s on udp port %d\n", probe, addr, localPort)
+			if response := h.options.DeceptionProfile.response(probe); response != "" {
+				_, _ = conn.WriteTo([]byte(response), addr)
+			}
+		}
+		h.recordInteraction(addr, localPort, buffer[:n], probe)
+	}
+}
+
+// recordInteraction stores the datagram for each correlation id it contains,
+// or for the server token when it contains none
+func (h *UDPServer) recordInteraction(remoteAddr net.Addr, localPort int, datagram []byte, probe string) {
+	host, _, _ := net.SplitHostPort(remoteAddr.String())
+	captured := datagram[:min(len(datagram), udpCaptureSize)]
+	message := fmt.Sprintf("Port=%d\nSize=%d\n\n%s", localPort, len(datagram), hex.Dump(captured))
+	newInteraction := func(uniqueID, fullID string) *Interaction {
+		interaction := &Interaction{
+			Protocol:      "udp",
+			UniqueID:      uniqueID,
+			FullId:        fullID,
+			RawRequest:    message,
+			RawBytes:      captured,
+			RemoteAddress: host,
+			Timestamp:     time.Now(),
+			LocalPort:     localPort,
+			ScannerProbe:  probe,
+		}
+		if len(captured) < len(datagram) {
+			interaction.Truncated = true
+			interaction.BodySize = int64(len(datagram))
+		}
+		h.options.applyNAT64(interaction)
+		return interaction
+	}
+
+	ids := make(map[string]string)
+	for _, chunk := range stringsutil.SplitAny(string(datagram), ".@/\\:;,=()`'\"<>?& \r\n\t\x00") {
+		normalized := NormalizeHost(chunk)
+		for part := range stringsutil.SlideWithLength(normalized, h.options.GetIdLength()) {
+			if _, ok := ids[part]; !ok && h.options.isCorrelationID(part) {
+				ids[part] = normalized
+			}
+		}
+	}
+	if len(ids) == 0 {
+		if h.options.GetToken() == "" {
+			return
+		}
+		h.store(newInteraction("", ""), func(data []byte) error {
+			return h.options.Storage.AddInteractionWithId(h.options.GetToken(), data)
+		})
+		return
+	}
+	for uniqueID, fullID := range ids {
+		correlationID := uniqueID[:h.options.CorrelationIdLength]
+		h.store(newInteraction(uniqueID, fullID), func(data []byte) error {
+			return h.options.Storage.AddInteraction(correlationID, data)
+		})
+	}
+}
+
+func (h *UDPServer) store(interaction *Interaction, add func(data []byte) error) {
+	buffer := &bytes.Buffer{}
+	if err := jsoniter.NewEncoder(buffer).Encode(interaction); err != nil {
+		gologger.Warning().Msgf("Could not encode udp interaction: %s\n", err)
+		return
+	}
+	gologger.Debug().Msgf("%s\n", buffer.String())
+	if err := add(buffer.Bytes()); err != nil {
+		gologger.Warning().Msgf("Could not store udp interaction: %s\n", err)
+	}
+}
+
+// Close stops the udp catch-all server
+func (h *UDPServer) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, conn := range h.conns {
+		_ = conn.Close()
+	}
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/interactsh/pkg/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUDPServer(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0", "token")
+	store := options.Storage
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+	listeners := NewListeners()
+	listeners.addPacketConn(conn)
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+
+	options.ListenIP = "127.0.0.1"
+	options.UDPPorts = []int{port}
+	options.Listeners = listeners
+	options.Token = "token"
+	server, err := NewUDPServer(options)
+	require.Nil(t, err)
+	alive := make(chan bool, 1)
+	go server.ListenAndServe(alive)
+	require.True(t, <-alive)
+	defer server.Close()
+
+	// tftp read request with the correlation id in the file name
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	require.Nil(t, err)
+	defer client.Close()
+	_, err = client.Write([]byte("\x00\x01c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.bin\x00octet\x00"))
+	require.Nil(t, err)
+	var item *storage.CorrelationData
+	for i := 0; i < 50; i++ {
+		if item, err = store.GetCacheItem("c59e3crp82ke7bcnedq0"); err == nil && len(item.Data) > 0 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	require.Len(t, item.Data, 1)
+	interaction := &Interaction{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[0]), interaction))
+	require.Equal(t, "udp", interaction.Protocol)
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy", interaction.UniqueID)
+	require.Equal(t, port, interaction.LocalPort)
+	require.Equal(t, "127.0.0.1", interaction.RemoteAddress)
+	require.Contains(t, interaction.RawRequest, fmt.Sprintf("Port=%d\nSize=46\n\n00000000  00 01 63 35", port))
+	require.Equal(t, []byte("\x00\x01c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.bin\x00octet\x00"), interaction.RawBytes)
+
+	// uncorrelated datagrams are stored for the server token, truncated
+	ntp := make([]byte, 2048)
+	ntp[0] = 0x23
+	server.recordInteraction(&net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 40000}, 123, ntp, "")
+	item, err = store.GetCacheItem("token")
+	require.Nil(t, err)
+	require.Len(t, item.Data, 1)
+	interaction = &Interaction{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[0]), interaction))
+	require.Equal(t, 123, interaction.LocalPort)
+	require.True(t, interaction.Truncated)
+	require.EqualValues(t, 2048, interaction.BodySize)
+	require.Len(t, interaction.RawBytes, udpCaptureSize)
+}
@@ -0,0 +1,205 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UNCChainWindow is the time within which the steps of a chain are linked,
+// windows falling back from smb to webdav within seconds
+var UNCChainWindow = 2 * time.Minute
+
+// uncTrackerSize is the number of lookups and smb attempts kept
+const uncTrackerSize = 4096
+
+// smbClientAddress matches the client address of the smb (impacket) and responder logs
+var smbClientAddress = regexp.MustCompile(`(?:Incoming connection \(|Client\s*:\s*)\[?([0-9a-fA-F:.]+?)\]?[,)\s]`)
+
+// UNCChainStep is a step of a windows unc path resolution
+type UNCChainStep struct {
+	// Protocol is the protocol of the step (dns, smb or webdav)
+	Protocol string `json:"protocol"`
+	// Source is the address the step was made from (the resolver for dns)
+	Source string `json:"source,omitempty"`
+	// Timestamp is the time of the step
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// UNCChain links the dns lookup of a payload, the smb attempt and the webdav
+// fallback of a windows host opening an unc path (\\payload\share)
+type UNCChain struct {
+	// ID identifies the chain, shared by its webdav interactions
+	ID string `json:"id"`
+	// Payload is the full id of the payload looked up
+	Payload string `json:"payload,omitempty"`
+	// Steps are the steps of the chain, in order
+	Steps []UNCChainStep `json:"steps"`
+}
+
+// uncLookup is a dns lookup of a correlation id
+type uncLookup struct {
+	correlationID string
+	fullID        string
+	source        string
+	time          time.Time
+}
+
+// smbAttempt is an smb connection (or a dropped syn to 445) from a source
+type smbAttempt struct {
+	source string
+	time   time.Time
+}
+
+// UNCChainTracker keeps the recent lookups of correlation ids and smb attempts
+// with which the webdav requests of the windows web client are linked
+type UNCChainTracker struct {
+	window   time.Duration
+	mu       sync.Mutex
+	lookups  []uncLookup
+	attempts []smbAttempt
+	chains   map[string]*UNCChain
+}
+
+// NewUNCChainTracker returns a new tracker linking the steps made during the window
+func NewUNCChainTracker(window time.Duration) *UNCChainTracker {
+	return &UNCChainTracker{window: window, chains: make(map[string]*UNCChain)}
+}
+
+// recordLookup adds a lookup of a correlation id, the tracker may be nil
+func (t *UNCChainTracker) recordLookup(correlationID, fullID, source string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	t.prune(now)
+	if len(t.lookups) >= uncTrackerSize {
+		t.lookups = t.lookups[1:]
+	}
+	t.lookups = append(t.lookups, uncLookup{correlationID: correlationID, fullID: fullID, source: source, time: now})
+}
+
+// recordSMB adds an smb attempt of a source, the tracker may be nil
+func (t *UNCChainTracker) recordSMB(source string) {
+	if t == nil || source == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	t.prune(now)
+	// the attempts of a source are retried on several connections
+	if n := len(t.attempts); n > 0 && t.attempts[n-1].source == source && now.Sub(t.attempts[n-1].time) < time.Second {
+		return
+	}
+	if len(t.attempts) >= uncTrackerSize {
+		t.attempts = t.attempts[1:]
+	}
+	t.attempts = append(t.attempts, smbAttempt{source: source, time: now})
+}
+
+// recordSMBLog adds the smb attempts of the client addresses of smb server log lines
+func (t *UNCChainTracker) recordSMBLog(data string) {
+	if t == nil {
+		return
+	}
+	for _, match := range smbClientAddress.FindAllStringSubmatch(data, -1) {
+		t.recordSMB(match[1])
+	}
+}
+
+// link returns the chain of a webdav request of the correlation id from the
+// source, nil if the request isn't one of the windows web client
+func (t *UNCChainTracker) link(r *http.Request, correlationID, source string) *UNCChain {
+	if t == nil || correlationID == "" || !isWebDAVRequest(r) {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	t.prune(now)
+
+	key := correlationID + "|" + source
+	chain, ok := t.chains[key]
+	if !ok {
+		chain = &UNCChain{ID: uncChainID()}
+		for i := len(t.lookups) - 1; i >= 0; i-- {
+			if lookup := t.lookups[i]; lookup.correlationID == correlationID {
+				chain.Payload = lookup.fullID
+				chain.Steps = append(chain.Steps, UNCChainStep{Protocol: "dns", Source: lookup.source, Timestamp: lookup.time})
+				break
+			}
+		}
+		for i := len(t.attempts) - 1; i >= 0; i-- {
+			if attempt := t.attempts[i]; attempt.source == source {
+				chain.Steps = append(chain.Steps, UNCChainStep{Protocol: "smb", Source: source, Timestamp: attempt.time})
+				break
+			}
+		}
+		t.chains[key] = chain
+	}
+	linked := &UNCChain{ID: chain.ID, Payload: chain.Payload}
+	linked.Steps = append(append(linked.Steps, chain.Steps...), UNCChainStep{Protocol: "webdav", Source: source, Timestamp: now})
+	// the following requests of the client are linked to the first one
+	if n := len(chain.Steps); n == 0 || chain.Steps[n-1].Protocol != "webdav" {
+		chain.Steps = append(chain.Steps, linked.Steps[len(linked.Steps)-1])
+	}
+	return linked
+}
+
+func (t *UNCChainTracker) prune(now time.Time) {
+	expired := 0
+	for expired < len(t.lookups) && now.Sub(t.lookups[expired].time) > t.window {
+		expired++
+	}
+	t.lookups = t.lookups[expired:]
+	expired = 0
+	for expired < len(t.attempts) && now.Sub(t.attempts[expired].time) > t.window {
+		expired++
+	}
+	t.attempts = t.attempts[expired:]
+	for key, chain := range t.chains {
+		if last := chain.Steps[len(chain.Steps)-1]; now.Sub(last.Timestamp) > t.window {
+			delete(t.chains, key)
+		}
+	}
+}
+
+// isWebDAVRequest reports whether a request is made by the windows web client
+// (the mini-redirector) or is a webdav propfind
+func isWebDAVRequest(r *http.Request) bool {
+	return r.Method == "PROPFIND" || strings.Contains(r.UserAgent(), "Microsoft-WebDAV-MiniRedir")
+}
+
+func uncChainID() string {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// marker describes the chain in the raw request
+func (c *UNCChain) marker() string {
+	if c == nil {
+		return ""
+	}
+	steps := make([]string, 0, len(c.Steps))
+	for _, step := range c.Steps {
+		steps = append(steps, step.Protocol)
+	}
+	return fmt.Sprintf("\n\nUNC Chain: %s (%s)\n", strings.Join(steps, " -> "), c.ID)
+}
+
+// apply sets the chain of the interaction
+func (c *UNCChain) apply(interaction *Interaction) {
+	if c == nil {
+		return
+	}
+	interaction.UNCChain = c
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUNCChainTracker(t *testing.T) {
+	tracker := NewUNCChainTracker(time.Minute)
+	tracker.recordLookup("c59e3crp82ke7bcnedq0", "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh", "10.0.0.53")
+	tracker.recordSMBLog("[*] Incoming connection (192.0.2.7,49712)\n[*] AUTHENTICATE_MESSAGE (WORKGROUP\\user,DESKTOP)\n")
+
+	// only the webdav requests are linked
+	require.Nil(t, tracker.link(httptest.NewRequest("GET", "/", nil), "c59e3crp82ke7bcnedq0", "192.0.2.7"))
+
+	options := httptest.NewRequest("OPTIONS", "/share", nil)
+	options.Header.Set("User-Agent", "Microsoft-WebDAV-MiniRedir/10.0.19045")
+	chain := tracker.link(options, "c59e3crp82ke7bcnedq0", "192.0.2.7")
+	require.NotNil(t, chain)
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh", chain.Payload)
+	require.Len(t, chain.Steps, 3)
+	require.Equal(t, "dns", chain.Steps[0].Protocol)
+	require.Equal(t, "smb", chain.Steps[1].Protocol)
+	require.Equal(t, "192.0.2.7", chain.Steps[1].Source)
+	require.Equal(t, "webdav", chain.Steps[2].Protocol)
+	require.Equal(t, "\n\nUNC Chain: dns -> smb -> webdav ("+chain.ID+")\n", chain.marker())
+
+	// the following requests of the client share the chain
+	propfind := tracker.link(httptest.NewRequest("PROPFIND", "/share", nil), "c59e3crp82ke7bcnedq0", "192.0.2.7")
+	require.Equal(t, chain.ID, propfind.ID)
+	require.Len(t, propfind.Steps, 4)
+
+	// a client without smb attempt (blocked egress) is linked to the lookup only
+	other := tracker.link(httptest.NewRequest("PROPFIND", "/share", nil), "c59e3crp82ke7bcnedq0", "192.0.2.8")
+	require.NotEqual(t, chain.ID, other.ID)
+	require.Len(t, other.Steps, 2)
+	require.Equal(t, "dns", other.Steps[0].Protocol)
+
+	var empty *UNCChainTracker
+	empty.recordLookup("c59e3crp82ke7bcnedq0", "", "")
+	empty.recordSMBLog("Incoming connection (192.0.2.7,49712)")
+	require.Nil(t, empty.link(options, "c59e3crp82ke7bcnedq0", "192.0.2.7"))
+}
+
+func TestSMBClientAddress(t *testing.T) {
+	for line, address := range map[string]string{
+		"[*] Incoming connection (192.0.2.7,49712)\n":     "192.0.2.7",
+		"[SMB] NTLMv2-SSP Client   : 192.0.2.9\n":         "192.0.2.9",
+		"[SMB] NTLMv2-SSP Client   : ::ffff:192.0.2.10\n": "::ffff:192.0.2.10",
+		"INFO: Incoming connection (2001:db8::1,49712)\n": "2001:db8::1",
+	} {
+		match := smbClientAddress.FindStringSubmatch(line)
+		require.NotNil(t, match, line)
+		require.Equal(t, address, match[1])
+	}
+}
@@ -1,14 +1,40 @@
 package server
 
 import (
+	"sort"
 	"strings"
 
 	"github.com/asaskevich/govalidator"
 	"github.com/rs/xid"
 )
 
+// checksumAlphabet is the zbase32 alphabet the checksum character is taken from,
+// the same as the one of the nonce
+const checksumAlphabet = "ybndrfg8ejkmcpqxot1uwisza345h769"
+
+// CorrelationIDChecksum returns the checksum character of a unique id ending the nonce,
+// computed over the preceding characters
+func CorrelationIDChecksum(id string) byte {
+	var sum int
+	for i, c := range []byte(strings.ToLower(id)) {
+		sum += (i + 1) * int(c)
+	}
+	return checksumAlphabet[sum%len(checksumAlphabet)]
+}
+
+// hasValidChecksum reports whether the unique id ends with the checksum of the preceding characters
+func hasValidChecksum(id string) bool {
+	if id == "" {
+		return false
+	}
+	return strings.ToLower(id)[len(id)-1] == CorrelationIDChecksum(id[:len(id)-1])
+}
+
 func (options *Options) isCorrelationID(s string) bool {
 	if len(s) == options.GetIdLength() && govalidator.IsAlphanumeric(s) {
+		if options.CorrelationIdChecksum && (options.CorrelationIdNonceLength == 0 || !hasValidChecksum(s)) {
+			return false
+		}
 		// xid should be 12
 		if options.CorrelationIdLength != 12 {
 			return true
@@ -18,3 +44,13 @@ func (options *Options) isCorrelationID(s string) bool {
 	}
 	return false
 }
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
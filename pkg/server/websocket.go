@@ -0,0 +1,252 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/gologger"
+	"golang.org/x/net/http/httpguts"
+)
+
+const (
+	// websocketGUID is the key suffix hashed in the handshake accept header
+	websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	// websocketTimeout is the time after which a websocket connection is closed
+	websocketTimeout = 10 * time.Minute
+	// websocketMaxFrame is the number of bytes kept of a frame when the body capture is unlimited
+	websocketMaxFrame = 1024 * 1024
+)
+
+// websocket frame opcodes
+const (
+	websocketContinuation = 0x0
+	websocketText         = 0x1
+	websocketBinary       = 0x2
+	websocketClose        = 0x8
+	websocketPing         = 0x9
+	websocketPong         = 0xa
+)
+
+// isWebSocketRequest reports whether the request is a websocket handshake
+func isWebSocketRequest(r *http.Request) bool {
+	return r.Method == http.MethodGet && r.ProtoMajor == 1 &&
+		strings.EqualFold(r.Header.Get("Upgrade"), "websocket") &&
+		httpguts.HeaderValuesContainsToken(r.Header["Connection"], "upgrade") &&
+		r.Header.Get("Sec-WebSocket-Key") != ""
+}
+
+// websocketAccept returns the accept header value of a handshake key
+func websocketAccept(key string) string {
+	hash := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// websocketConn is a hijacked connection speaking the websocket protocol
+type websocketConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// upgradeWebSocket completes the websocket handshake of the request and
+// returns the connection with the raw handshake response, the connection is
+// nil if it can't be hijacked (eg. http/2)
+func (h *HTTPServer) upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*websocketConn, string) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, ""
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		gologger.Debug().Msgf("Could not hijack websocket connection from %s: %s\n", r.RemoteAddr, err)
+		return nil, ""
+	}
+
+	var response strings.Builder
+	response.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	response.WriteString(fmt.Sprintf("Sec-WebSocket-Accept: %s\r\n", websocketAccept(r.Header.Get("Sec-WebSocket-Key"))))
+	// clients requesting subprotocols may give up without one selected
+	if protocols := r.Header.Get("Sec-WebSocket-Protocol"); protocols != "" {
+		protocol, _, _ := strings.Cut(protocols, ",")
+		response.WriteString(fmt.Sprintf("Sec-WebSocket-Protocol: %s\r\n", strings.TrimSpace(protocol)))
+	}
+	if server := extractServerDomain(h, r); server != "" {
+		response.WriteString(fmt.Sprintf("Server: %s\r\n", server))
+	}
+	response.WriteString("\r\n")
+
+	_ = conn.SetDeadline(time.Now().Add(websocketTimeout))
+	if _, err := conn.Write([]byte(response.String())); err != nil {
+		_ = conn.Close()
+		return nil, ""
+	}
+	source := r.RemoteAddr
+	if originIP := r.Header.Get(h.options.OriginIPHeader); originIP != "" {
+		source = originIP
+	}
+	h.options.Stats.RecordSource("websocket", source)
+	return &websocketConn{conn: conn, reader: rw.Reader}, response.String()
+}
+
+// readFrame reads a frame keeping up to limit bytes of its unmasked payload,
+// the rest of the payload is discarded and only accounted in its size
+func (c *websocketConn) readFrame(limit int) (opcode byte, payload []byte, size int64, err error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(c.reader, header); err != nil {
+		return 0, nil, 0, err
+	}
+	opcode = header[0] & 0x0f
+	masked := header[1]&0x80 != 0
+	size = int64(header[1] & 0x7f)
+	switch size {
+	case 126:
+		extended := make([]byte, 2)
+		if _, err := io.ReadFull(c.reader, extended); err != nil {
+			return 0, nil, 0, err
+		}
+		size = int64(binary.BigEndian.Uint16(extended))
+	case 127:
+		extended := make([]byte, 8)
+		if _, err := io.ReadFull(c.reader, extended); err != nil {
+			return 0, nil, 0, err
+		}
+		size = int64(binary.BigEndian.Uint64(extended) & (1<<63 - 1))
+	}
+	var mask []byte
+	if masked {
+		mask = make([]byte, 4)
+		if _, err := io.ReadFull(c.reader, mask); err != nil {
+			return 0, nil, 0, err
+		}
+	}
+
+	payload = make([]byte, min(size, int64(limit)))
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return 0, nil, 0, err
+	}
+	if _, err := io.CopyN(io.Discard, c.reader, size-int64(len(payload))); err != nil {
+		return 0, nil, 0, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return opcode, payload, size, nil
+}
+
+// writeFrame writes an unmasked frame with a payload of at most 125 bytes
+func (c *websocketConn) writeFrame(opcode byte, payload []byte) error {
+	payload = payload[:min(len(payload), 125)]
+	_, err := c.conn.Write(append([]byte{0x80 | opcode, byte(len(payload))}, payload...))
+	return err
+}
+
+// serveWebSocket captures the data frames of a websocket connection for the
+// correlation ids of its handshake, until the body capture limit is reached
+func (h *HTTPServer) serveWebSocket(ws *websocketConn, host string, ids map[string]string) {
+	defer ws.conn.Close()
+
+	if len(ids) == 0 {
+		_ = ws.writeFrame(websocketClose, []byte{0x03, 0xe8})
+		return
+	}
+	limit := h.options.captureLimit()
+	remaining := limit
+	for frame := 1; ; {
+		frameLimit := websocketMaxFrame
+		if limit > 0 {
+			frameLimit = remaining
+		}
+		opcode, payload, size, err := ws.readFrame(frameLimit)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				gologger.Debug().Msgf("Could not read websocket frame from %s: %s\n", host, err)
+			}
+			return
+		}
+		switch opcode {
+		case websocketPing:
+			_ = ws.writeFrame(websocketPong, payload)
+			continue
+		case websocketPong:
+			continue
+		case websocketClose:
+			_ = ws.writeFrame(websocketClose, payload[:min(len(payload), 2)])
+			return
+		}
+
+		h.recordWebSocketFrame(host, frame, opcode, payload, size, ids)
+		frame++
+		if limit > 0 {
+			remaining -= len(payload)
+			if remaining <= 0 {
+				// 1009 message too big
+				_ = ws.writeFrame(websocketClose, []byte{0x03, 0xf1})
+				return
+			}
+		}
+	}
+}
+
+// recordWebSocketFrame stores a data frame for each correlation id of the connection
+func (h *HTTPServer) recordWebSocketFrame(host string, frame int, opcode byte, payload []byte, size int64, ids map[string]string) {
+	var message strings.Builder
+	message.WriteString(fmt.Sprintf("Frame=%d\nOpcode=%s\nSize=%d\n\n", frame, websocketOpcodeName(opcode), size))
+	if opcode == websocketBinary {
+		message.WriteString(hex.Dump(payload))
+	} else {
+		message.Write(payload)
+	}
+
+	for uniqueID, fullID := range ids {
+		interaction := &Interaction{
+			Protocol:       "websocket",
+			UniqueID:       uniqueID,
+			FullId:         fullID,
+			RawRequest:     message.String(),
+			RemoteAddress:  host,
+			Timestamp:      time.Now(),
+			WebSocketFrame: frame,
+		}
+		if int64(len(payload)) < size {
+			interaction.Truncated = true
+			interaction.BodySize = size
+		}
+		h.options.applyNAT64(interaction)
+		buffer := &bytes.Buffer{}
+		if err := jsoniter.NewEncoder(buffer).Encode(interaction); err != nil {
+			gologger.Warning().Msgf("Could not encode websocket interaction: %s\n", err)
+			continue
+		}
+		gologger.Debug().Msgf("Websocket Interaction: \n%s\n", buffer.String())
+		if err := h.options.Storage.AddInteraction(uniqueID[:h.options.CorrelationIdLength], buffer.Bytes()); err != nil {
+			gologger.Warning().Msgf("Could not store websocket interaction: %s\n", err)
+		}
+	}
+}
+
+// websocketOpcodeName returns the name of a data frame opcode
+func websocketOpcodeName(opcode byte) string {
+	switch opcode {
+	case websocketContinuation:
+		return "continuation"
+	case websocketText:
+		return "text"
+	case websocketBinary:
+		return "binary"
+	default:
+		return fmt.Sprintf("0x%x", opcode)
+	}
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/interactsh/pkg/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebSocketAccept(t *testing.T) {
+	// sample handshake of rfc 6455
+	require.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", websocketAccept("dGhlIHNhbXBsZSBub25jZQ=="))
+}
+
+// writeMaskedFrame writes a client frame masked as required by rfc 6455
+func writeMaskedFrame(conn net.Conn, opcode byte, payload []byte) error {
+	mask := []byte{0x11, 0x22, 0x33, 0x44}
+	frame := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	_, err := conn.Write(frame)
+	return err
+}
+
+func TestWebSocketCapture(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0")
+	store := options.Storage
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	options.BodyCaptureSize = 1
+	server, err := NewHTTPServer(options)
+	require.Nil(t, err)
+	go func() { _ = server.nontlsserver.Serve(listener) }()
+	defer server.nontlsserver.Close()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	require.Nil(t, err)
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	_, err = fmt.Fprint(conn, "GET /ws HTTP/1.1\r\nHost: c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh\r\n"+
+		"Upgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n")
+	require.Nil(t, err)
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, nil)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, response.StatusCode)
+	require.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", response.Header.Get("Sec-WebSocket-Accept"))
+
+	require.Nil(t, writeMaskedFrame(conn, websocketText, []byte("document.cookie=secret")))
+	require.Nil(t, writeMaskedFrame(conn, websocketPing, []byte("ping")))
+	pong := make([]byte, 6)
+	_, err = io.ReadFull(reader, pong)
+	require.Nil(t, err)
+	require.Equal(t, []byte{0x80 | websocketPong, 4, 'p', 'i', 'n', 'g'}, pong)
+	require.Nil(t, writeMaskedFrame(conn, websocketBinary, []byte{0x00, 0xff}))
+	require.Nil(t, writeMaskedFrame(conn, websocketClose, []byte{0x03, 0xe8}))
+	closing := make([]byte, 4)
+	_, err = io.ReadFull(reader, closing)
+	require.Nil(t, err)
+	require.Equal(t, []byte{0x80 | websocketClose, 2, 0x03, 0xe8}, closing)
+
+	var item *storage.CorrelationData
+	for i := 0; i < 50; i++ {
+		if item, err = store.GetCacheItem("c59e3crp82ke7bcnedq0"); err == nil && len(item.Data) == 3 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	require.Nil(t, err)
+	require.Len(t, item.Data, 3)
+
+	handshake := &Interaction{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[0]), handshake))
+	require.Equal(t, "http", handshake.Protocol)
+	require.Contains(t, handshake.RawResponse, "HTTP/1.1 101 Switching Protocols")
+
+	text := &Interaction{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[1]), text))
+	require.Equal(t, "websocket", text.Protocol)
+	require.Equal(t, "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy", text.FullId)
+	require.Equal(t, 1, text.WebSocketFrame)
+	require.Equal(t, "Frame=1\nOpcode=text\nSize=22\n\ndocument.cookie=secret", text.RawRequest)
+
+	binary := &Interaction{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[2]), binary))
+	require.Equal(t, 2, binary.WebSocketFrame)
+	require.Contains(t, binary.RawRequest, "Opcode=binary")
+	require.Contains(t, binary.RawRequest, "00 ff")
+}
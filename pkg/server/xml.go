@@ -0,0 +1,171 @@
+package server
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var (
+	// xmlEntityDeclaration matches the general and parameter entities of a DOCTYPE,
+	// with a SYSTEM or PUBLIC identifier or an internal value
+	xmlEntityDeclaration = regexp.MustCompile(`(?is)<!ENTITY\s+(%\s+)?(\S+)\s+(?:SYSTEM\s+("[^"]*"|'[^']*')|PUBLIC\s+(?:"[^"]*"|'[^']*')\s+("[^"]*"|'[^']*')|("[^"]*"|'[^']*'))`)
+	// xmlExternalDTD matches the external subset of a DOCTYPE
+	xmlExternalDTD = regexp.MustCompile(`(?is)^DOCTYPE\s+[^\s\[]+\s+(?:SYSTEM\s+("[^"]*"|'[^']*')|PUBLIC\s+(?:"[^"]*"|'[^']*')\s+("[^"]*"|'[^']*'))`)
+)
+
+// XMLEntity is an entity declared in the DOCTYPE of an xml request body
+type XMLEntity struct {
+	// Name is the name of the entity
+	Name string `json:"name"`
+	// Parameter reports whether the entity is a parameter entity (%name;)
+	Parameter bool `json:"parameter,omitempty"`
+	// SystemID is the url of an external entity
+	SystemID string `json:"system-id,omitempty"`
+	// Value is the replacement text of an internal entity
+	Value string `json:"value,omitempty"`
+}
+
+// xmlDocument is the DOCTYPE of an xml request body with its entities, the
+// entities are only extracted and never resolved
+type xmlDocument struct {
+	doctype     string
+	externalDTD string
+	entities    []XMLEntity
+}
+
+// isXMLRequest reports whether the request has an xml body
+func isXMLRequest(r *http.Request, body []byte) bool {
+	if strings.Contains(strings.ToLower(r.Header.Get("Content-Type")), "xml") {
+		return true
+	}
+	body = bytes.TrimSpace(body)
+	return bytes.HasPrefix(body, []byte("<?xml")) || bytes.HasPrefix(body, []byte("<!DOCTYPE"))
+}
+
+// parseXMLRequest returns the DOCTYPE of the xml body of the request, nil if
+// the request has no xml body or the body has no DOCTYPE
+func parseXMLRequest(r *http.Request, body []byte) *xmlDocument {
+	if len(body) == 0 || !isXMLRequest(r, body) {
+		return nil
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	// the body is only tokenized up to the root element, whatever its charset
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) { return input, nil }
+	for {
+		token, err := decoder.RawToken()
+		if err != nil {
+			return nil
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			return nil
+		case xml.Directive:
+			if directive := string(token); strings.HasPrefix(strings.ToUpper(directive), "DOCTYPE") {
+				return parseXMLDoctype(directive)
+			}
+		}
+	}
+}
+
+// parseXMLDoctype extracts the external subset and the entities of a DOCTYPE
+func parseXMLDoctype(directive string) *xmlDocument {
+	document := &xmlDocument{doctype: "<!" + directive + ">"}
+	if match := xmlExternalDTD.FindStringSubmatch(directive); match != nil {
+		document.externalDTD = unquoteXML(match[1] + match[2])
+	}
+	for _, match := range xmlEntityDeclaration.FindAllStringSubmatch(directive, -1) {
+		document.entities = append(document.entities, XMLEntity{
+			Name:      match[2],
+			Parameter: match[1] != "",
+			SystemID:  unquoteXML(match[3] + match[4]),
+			Value:     unquoteXML(match[5]),
+		})
+	}
+	return document
+}
+
+// unquoteXML removes the quotes of an xml literal
+func unquoteXML(value string) string {
+	if len(value) < 2 {
+		return value
+	}
+	return value[1 : len(value)-1]
+}
+
+// xxe reports whether the DOCTYPE is a likely xxe attempt, loading an
+// external subset or declaring external or parameter entities
+func (d *xmlDocument) xxe() bool {
+	if d.externalDTD != "" {
+		return true
+	}
+	for _, entity := range d.entities {
+		if entity.SystemID != "" || entity.Parameter {
+			return true
+		}
+	}
+	return false
+}
+
+// marker returns the description of the DOCTYPE appended to the raw request
+func (d *xmlDocument) marker() string {
+	if d == nil {
+		return ""
+	}
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("\n\nXML DOCTYPE: %s\n", d.doctype))
+	if d.externalDTD != "" {
+		builder.WriteString(fmt.Sprintf("External DTD: %s\n", d.externalDTD))
+	}
+	for _, entity := range d.entities {
+		name := entity.Name
+		if entity.Parameter {
+			name = "%" + name
+		}
+		if entity.SystemID != "" {
+			builder.WriteString(fmt.Sprintf("External entity %s: %s\n", name, entity.SystemID))
+		} else {
+			builder.WriteString(fmt.Sprintf("Entity %s: %s\n", name, entity.Value))
+		}
+	}
+	if d.xxe() {
+		builder.WriteString("[likely XXE attempt]\n")
+	}
+	return builder.String()
+}
+
+// apply sets the xml fields of the interaction
+func (d *xmlDocument) apply(interaction *Interaction) {
+	if d == nil {
+		return
+	}
+	interaction.XMLDoctype = d.doctype
+	interaction.XMLEntities = d.entities
+	interaction.XXE = d.xxe()
+}
+
+// writeXMLResponse answers an xml post with the reflection, in a soap envelope
+// of the same version for soap requests
+func writeXMLResponse(w http.ResponseWriter, r *http.Request, body []byte, reflection string) {
+	contentType := r.Header.Get("Content-Type")
+	var envelope string
+	switch {
+	case strings.Contains(contentType, "application/soap+xml"):
+		envelope = "http://www.w3.org/2003/05/soap-envelope"
+	case r.Header.Get("SOAPAction") != "" || bytes.Contains(body, []byte("schemas.xmlsoap.org/soap/envelope")):
+		envelope = "http://schemas.xmlsoap.org/soap/envelope/"
+		contentType = "text/xml; charset=utf-8"
+	default:
+		contentType = "application/xml"
+	}
+	w.Header().Set("Content-Type", contentType)
+	if envelope == "" {
+		fmt.Fprintf(w, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<data>%s</data>", reflection)
+		return
+	}
+	fmt.Fprintf(w, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<soap:Envelope xmlns:soap=\"%s\"><soap:Body><data>%s</data></soap:Body></soap:Envelope>", envelope, reflection)
+}
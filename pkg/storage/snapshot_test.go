@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/xid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageSnapshotRestore(t *testing.T) {
+	db, err := New(&Options{DbPath: t.TempDir(), EvictionTTL: 1 * time.Hour})
+	require.Nil(t, err)
+	defer db.Close()
+
+	_, encoded := newTestRSAKey(t)
+	secret := uuid.New().String()
+	correlationID := xid.New().String()
+	require.Nil(t, db.SetIDPublicKey(correlationID, secret, encoded))
+	require.Nil(t, db.AddInteraction(correlationID, []byte("interaction")))
+	require.Nil(t, db.SetDNSScript(correlationID, secret, "rebind1", []string{"203.0.113.10", "127.0.0.1"}))
+
+	var snapshot bytes.Buffer
+	require.Nil(t, db.Snapshot(&snapshot))
+	require.Nil(t, db.Compact())
+
+	restoredDB, err := New(&Options{DbPath: t.TempDir(), EvictionTTL: 1 * time.Hour})
+	require.Nil(t, err)
+	defer restoredDB.Close()
+
+	restored, err := restoredDB.Restore(&snapshot)
+	require.Nil(t, err)
+	require.Equal(t, 1, restored)
+
+	item, err := restoredDB.GetCacheItem(correlationID)
+	require.Nil(t, err)
+	require.Equal(t, secret, item.SecretKey)
+	answer, ok := item.NextDNSAnswer("rebind1", false)
+	require.True(t, ok)
+	require.Equal(t, "203.0.113.10", answer)
+
+	data, aesKey, err := restoredDB.GetInteractions(correlationID, secret)
+	require.Nil(t, err)
+	require.Len(t, data, 1)
+	require.NotEmpty(t, aesKey)
+
+	// restored sessions are part of the next snapshots
+	snapshot.Reset()
+	require.Nil(t, restoredDB.Snapshot(&snapshot))
+	require.NotEmpty(t, snapshot.Bytes())
+}
@@ -5,11 +5,22 @@ type Storage interface {
 	GetCacheMetrics() (*CacheMetrics, error)
 	SetIDPublicKey(correlationID, secretKey, publicKey string) error
 	SetID(ID string) error
+	ShareID(correlationID, secret, readerSecret, readerPublicKey string) error
+	SetCNAME(correlationID, secret, target string) error
+	SetDNSScript(correlationID, secret, label string, answers []string) error
+	SetHTTPTemplate(correlationID, secret, publicKey string, template *HTTPTemplate, signature []byte) error
+	SetPayloadFile(correlationID, secret, name string, file *PayloadFile) error
+	SetInspectKey(correlationID, secret, key string) error
 	AddInteraction(correlationID string, data []byte) error
 	AddInteractionWithId(id string, data []byte) error
 	GetInteractions(correlationID, secret string) ([]string, string, error)
 	GetInteractionsWithId(id string) ([]string, error)
 	RemoveID(correlationID, secret string) error
 	GetCacheItem(token string) (*CorrelationData, error)
+	Sessions() []*SessionSummary
+	Evict(correlationID string) error
+	Ban(correlationID string) error
+	Unban(correlationID string) error
+	Bans() []string
 	Close() error
 }
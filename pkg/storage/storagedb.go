@@ -3,13 +3,16 @@ package storage
 
 import (
 	"bytes"
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
 	"encoding/base64"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/goburrow/cache"
 	"github.com/google/uuid"
@@ -29,20 +32,28 @@ type StorageDB struct {
 	cache   cache.Cache
 	db      *leveldb.DB
 	dbpath  string
+	// sessions indexes the ids stored in the cache for snapshots and listings
+	sessions sync.Map
+	// evicted are the ids recently removed from the cache without deregistration
+	evicted cache.Cache
+	// banned are the correlation-ids rejected at registration
+	banned sync.Map
+	done   chan struct{}
 }
 
 // New creates a new storage instance for interactsh data.
 func New(options *Options) (*StorageDB, error) {
-	storageDB := &StorageDB{Options: options}
+	storageDB := &StorageDB{
+		Options: options,
+		evicted: cache.New(cache.WithMaximumSize(MaxEvictedIDs), cache.WithExpireAfterWrite(EvictedRetention)),
+	}
 	cacheOptions := []cache.Option{
 		cache.WithMaximumSize(options.MaxSize),
+		cache.WithRemovalListener(storageDB.OnCacheRemovalCallback),
 	}
 	if options.EvictionTTL > 0 {
 		cacheOptions = append(cacheOptions, cache.WithExpireAfterAccess(options.EvictionTTL))
 	}
-	if options.UseDisk() {
-		cacheOptions = append(cacheOptions, cache.WithRemovalListener(storageDB.OnCacheRemovalCallback))
-	}
 	cacheDb := cache.New(cacheOptions...)
 	storageDB.cache = cacheDb
 
@@ -62,13 +73,24 @@ func New(options *Options) (*StorageDB, error) {
 		}
 		storageDB.dbpath = dbpath
 		storageDB.db = levDb
+		storageDB.done = make(chan struct{})
+		go storageDB.maintain()
 	}
 
 	return storageDB, nil
 }
 
+// OnCacheRemovalCallback removes the session index of the ids evicted from
+// the cache, remembering them as evicted, and their records from the disk
 func (s *StorageDB) OnCacheRemovalCallback(key cache.Key, value cache.Value) {
-	if key, ok := value.([]byte); ok {
+	if id, ok := key.(string); ok {
+		// deregistered sessions are removed from the index beforehand
+		if _, indexed := s.sessions.Load(id); indexed {
+			s.evicted.Put(id, struct{}{})
+			s.sessions.Delete(id)
+		}
+	}
+	if key, ok := value.([]byte); ok && s.db != nil {
 		_ = s.db.Delete(key, &opt.WriteOptions{})
 	}
 }
@@ -91,10 +113,15 @@ func (s *StorageDB) GetCacheMetrics() (*CacheMetrics, error) {
 
 // SetIDPublicKey sets the correlation ID and publicKey into the cache for further operations.
 func (s *StorageDB) SetIDPublicKey(correlationID, secretKey, publicKey string) error {
-	// If we already have this correlation ID, return.
-	_, found := s.cache.GetIfPresent(correlationID)
-	if found {
-		return errors.New("correlation-id provided already exists")
+	if s.isBanned(correlationID) {
+		return ErrCorrelationIdBanned
+	}
+	// If we already have this correlation ID, return whether it is the same session.
+	if item, found := s.cache.GetIfPresent(correlationID); found {
+		if value, ok := item.(*CorrelationData); ok && value.registeredBy(secretKey, publicKey) {
+			return ErrCorrelationIdExists
+		}
+		return ErrCorrelationIdConflict
 	}
 	publicKeyData, err := ParseB64RSAPublicKeyFromPEM(publicKey)
 	if err != nil {
@@ -102,23 +129,219 @@ func (s *StorageDB) SetIDPublicKey(correlationID, secretKey, publicKey string) e
 	}
 	aesKey := uuid.New().String()[:32]
 
-	ciphertext, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, publicKeyData, []byte(aesKey), []byte(""))
+	aesKeyEncrypted, err := encryptAESKey(publicKeyData, []byte(aesKey))
 	if err != nil {
-		return errors.New("could not encrypt event data")
+		return err
 	}
 
 	data := &CorrelationData{
 		SecretKey:       secretKey,
 		AESKey:          []byte(aesKey),
-		AESKeyEncrypted: base64.StdEncoding.EncodeToString(ciphertext),
+		AESKeyEncrypted: aesKeyEncrypted,
+		PublicKeyHash:   publicKeyHash(publicKey),
 	}
 	s.cache.Put(correlationID, data)
+	s.index(correlationID)
 	return nil
 }
 
+// ShareID grants read access on a correlation ID to a reader by re-encrypting
+// the session AES key with the reader public key. The reader then polls the
+// correlation ID with its own secret key and receives a private copy of the
+// interactions, so that neither party drains the other's queue.
+func (s *StorageDB) ShareID(correlationID, secret, readerSecret, readerPublicKey string) error {
+	item, ok := s.cache.GetIfPresent(correlationID)
+	if !ok {
+		return ErrCorrelationIdNotFound
+	}
+	value, ok := item.(*CorrelationData)
+	if !ok {
+		return errors.New("invalid correlation-id cache value found")
+	}
+	if !strings.EqualFold(value.SecretKey, secret) {
+		return errors.New("invalid secret key passed for share")
+	}
+	if readerSecret == "" || strings.EqualFold(value.SecretKey, readerSecret) {
+		return errors.New("invalid reader secret key passed for share")
+	}
+	publicKeyData, err := ParseB64RSAPublicKeyFromPEM(readerPublicKey)
+	if err != nil {
+		return errors.Wrap(err, "could not read reader public Key")
+	}
+	aesKeyEncrypted, err := encryptAESKey(publicKeyData, value.AESKey)
+	if err != nil {
+		return err
+	}
+
+	value.Lock()
+	defer value.Unlock()
+	if value.Readers == nil {
+		value.Readers = make(map[string]*SharedReader)
+	}
+	if _, ok := value.Readers[strings.ToLower(readerSecret)]; ok {
+		return errors.New("reader secret key provided already exists")
+	}
+	value.Readers[strings.ToLower(readerSecret)] = &SharedReader{AESKeyEncrypted: aesKeyEncrypted}
+	return nil
+}
+
+// SetCNAME sets the external host the names of the correlation-id resolve to, an empty target removes it
+func (s *StorageDB) SetCNAME(correlationID, secret, target string) error {
+	item, ok := s.cache.GetIfPresent(correlationID)
+	if !ok {
+		return ErrCorrelationIdNotFound
+	}
+	value, ok := item.(*CorrelationData)
+	if !ok {
+		return errors.New("invalid correlation-id cache value found")
+	}
+	if !strings.EqualFold(value.SecretKey, secret) {
+		return errors.New("invalid secret key passed for cname")
+	}
+
+	value.Lock()
+	defer value.Unlock()
+	value.CNAME = target
+	return nil
+}
+
+// SetDNSScript sets the answers of the lookups of a subdomain label of the
+// correlation-id, empty answers removing the script
+func (s *StorageDB) SetDNSScript(correlationID, secret, label string, answers []string) error {
+	item, ok := s.cache.GetIfPresent(correlationID)
+	if !ok {
+		return ErrCorrelationIdNotFound
+	}
+	value, ok := item.(*CorrelationData)
+	if !ok {
+		return errors.New("invalid correlation-id cache value found")
+	}
+	if !strings.EqualFold(value.SecretKey, secret) {
+		return errors.New("invalid secret key passed for dns script")
+	}
+
+	value.Lock()
+	defer value.Unlock()
+	label = strings.ToLower(label)
+	if len(answers) == 0 {
+		delete(value.DNSScripts, label)
+		return nil
+	}
+	if _, ok := value.DNSScripts[label]; !ok && len(value.DNSScripts) >= MaxDNSScripts {
+		return errors.Errorf("at most %d dns scripts can be set", MaxDNSScripts)
+	}
+	if value.DNSScripts == nil {
+		value.DNSScripts = make(map[string]*DNSScript)
+	}
+	value.DNSScripts[label] = &DNSScript{Answers: answers}
+	return nil
+}
+
+// SetHTTPTemplate sets the response served to the http requests of the
+// correlation-id, a nil template removing it. The template must be signed
+// with rsa-pss by the private key of the public key the correlation-id was
+// registered with.
+func (s *StorageDB) SetHTTPTemplate(correlationID, secret, publicKey string, template *HTTPTemplate, signature []byte) error {
+	item, ok := s.cache.GetIfPresent(correlationID)
+	if !ok {
+		return ErrCorrelationIdNotFound
+	}
+	value, ok := item.(*CorrelationData)
+	if !ok {
+		return errors.New("invalid correlation-id cache value found")
+	}
+	if !strings.EqualFold(value.SecretKey, secret) {
+		return errors.New("invalid secret key passed for http template")
+	}
+	if template != nil {
+		if value.PublicKeyHash != "" && value.PublicKeyHash != publicKeyHash(publicKey) {
+			return errors.New("public key doesn't match the registered one")
+		}
+		publicKeyData, err := ParseB64RSAPublicKeyFromPEM(publicKey)
+		if err != nil {
+			return errors.Wrap(err, "could not read public key")
+		}
+		digest := sha256.Sum256(template.SignedData())
+		if err := rsa.VerifyPSS(publicKeyData, crypto.SHA256, digest[:], signature, nil); err != nil {
+			return errors.New("invalid http template signature")
+		}
+	}
+
+	value.Lock()
+	defer value.Unlock()
+	value.HTTPTemplate = template
+	return nil
+}
+
+// SetPayloadFile sets a file hosted for the correlation-id, a nil file
+// removing it. The files are dropped along with the correlation-id.
+func (s *StorageDB) SetPayloadFile(correlationID, secret, name string, file *PayloadFile) error {
+	item, ok := s.cache.GetIfPresent(correlationID)
+	if !ok {
+		return ErrCorrelationIdNotFound
+	}
+	value, ok := item.(*CorrelationData)
+	if !ok {
+		return errors.New("invalid correlation-id cache value found")
+	}
+	if !strings.EqualFold(value.SecretKey, secret) {
+		return errors.New("invalid secret key passed for payload file")
+	}
+
+	value.Lock()
+	defer value.Unlock()
+	if file == nil {
+		delete(value.PayloadFiles, name)
+		return nil
+	}
+	if _, ok := value.PayloadFiles[name]; !ok && len(value.PayloadFiles) >= MaxPayloadFiles {
+		return errors.Errorf("at most %d payload files can be hosted", MaxPayloadFiles)
+	}
+	if value.PayloadFiles == nil {
+		value.PayloadFiles = make(map[string]*PayloadFile)
+	}
+	value.PayloadFiles[name] = file
+	return nil
+}
+
+// SetInspectKey sets the key of the inspect page of the correlation-id, an empty key disables it
+func (s *StorageDB) SetInspectKey(correlationID, secret, key string) error {
+	item, ok := s.cache.GetIfPresent(correlationID)
+	if !ok {
+		return ErrCorrelationIdNotFound
+	}
+	value, ok := item.(*CorrelationData)
+	if !ok {
+		return errors.New("invalid correlation-id cache value found")
+	}
+	if !strings.EqualFold(value.SecretKey, secret) {
+		return errors.New("invalid secret key passed for inspect")
+	}
+
+	value.Lock()
+	defer value.Unlock()
+	value.InspectKey = key
+	return nil
+}
+
+// encryptAESKey encrypts the AES key with the given public key
+func encryptAESKey(publicKey *rsa.PublicKey, aesKey []byte) (string, error) {
+	ciphertext, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, publicKey, aesKey, []byte(""))
+	if err != nil {
+		return "", errors.New("could not encrypt event data")
+	}
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// readerKey returns the disk storage key of a reader queue
+func readerKey(correlationID, readerSecret string) string {
+	return correlationID + ":" + strings.ToLower(readerSecret)
+}
+
 func (s *StorageDB) SetID(ID string) error {
 	data := &CorrelationData{}
 	s.cache.Put(ID, data)
+	s.index(ID)
 	return nil
 }
 
@@ -127,7 +350,7 @@ func (s *StorageDB) SetID(ID string) error {
 func (s *StorageDB) AddInteraction(correlationID string, data []byte) error {
 	item, found := s.cache.GetIfPresent(correlationID)
 	if !found {
-		return ErrCorrelationIdNotFound
+		return s.notFound(correlationID)
 	}
 	value, ok := item.(*CorrelationData)
 	if !ok {
@@ -137,15 +360,23 @@ func (s *StorageDB) AddInteraction(correlationID string, data []byte) error {
 	if s.Options.UseDisk() {
 		ct, err := AESEncrypt(value.AESKey, data)
 		if err != nil {
-			return errors.Wrap(err, "could not encrypt event data")
+			return fmt.Errorf("%w: %s", ErrEncryptionFailed, err)
 		}
 		value.Lock()
 		existingData, _ := s.db.Get([]byte(correlationID), nil)
 		_ = s.db.Put([]byte(correlationID), AppendMany("\n", existingData, []byte(ct)), nil)
+		for readerSecret := range value.Readers {
+			key := []byte(readerKey(correlationID, readerSecret))
+			existingData, _ := s.db.Get(key, nil)
+			_ = s.db.Put(key, AppendMany("\n", existingData, []byte(ct)), nil)
+		}
 		value.Unlock()
 	} else {
 		value.Lock()
 		value.Data = append(value.Data, string(data))
+		for _, reader := range value.Readers {
+			reader.Data = append(reader.Data, string(data))
+		}
 		value.Unlock()
 	}
 
@@ -156,7 +387,7 @@ func (s *StorageDB) AddInteraction(correlationID string, data []byte) error {
 func (s *StorageDB) AddInteractionWithId(id string, data []byte) error {
 	item, ok := s.cache.GetIfPresent(id)
 	if !ok {
-		return ErrCorrelationIdNotFound
+		return s.notFound(id)
 	}
 	value, ok := item.(*CorrelationData)
 	if !ok {
@@ -166,7 +397,7 @@ func (s *StorageDB) AddInteractionWithId(id string, data []byte) error {
 	if s.Options.UseDisk() {
 		ct, err := AESEncrypt(value.AESKey, data)
 		if err != nil {
-			return errors.Wrap(err, "could not encrypt event data")
+			return fmt.Errorf("%w: %s", ErrEncryptionFailed, err)
 		}
 		value.Lock()
 		existingData, _ := s.db.Get([]byte(id), nil)
@@ -181,6 +412,18 @@ func (s *StorageDB) AddInteractionWithId(id string, data []byte) error {
 	return nil
 }
 
+// notFound returns the error of an id missing from the cache, evicted if it
+// was stored and not deregistered since
+func (s *StorageDB) notFound(id string) error {
+	if _, ok := s.sessions.Load(id); ok {
+		return ErrCorrelationIdEvicted
+	}
+	if _, ok := s.evicted.GetIfPresent(id); ok {
+		return ErrCorrelationIdEvicted
+	}
+	return ErrCorrelationIdNotFound
+}
+
 // GetInteractions returns the interactions for a correlationID and removes
 // it from the storage. It also returns AES Encrypted Key for the IDs.
 func (s *StorageDB) GetInteractions(correlationID, secret string) ([]string, string, error) {
@@ -193,7 +436,14 @@ func (s *StorageDB) GetInteractions(correlationID, secret string) ([]string, str
 		return nil, "", errors.New("invalid correlation-id cache value found")
 	}
 	if !strings.EqualFold(value.SecretKey, secret) {
-		return nil, "", errors.New("invalid secret key passed for user")
+		value.Lock()
+		reader, ok := value.Readers[strings.ToLower(secret)]
+		value.Unlock()
+		if !ok {
+			return nil, "", errors.New("invalid secret key passed for user")
+		}
+		data, err := s.getReaderInteractions(value, reader, readerKey(correlationID, secret))
+		return data, reader.AESKeyEncrypted, err
 	}
 	data, err := s.getInteractions(value, correlationID)
 	return data, value.AESKeyEncrypted, err
@@ -223,14 +473,31 @@ func (s *StorageDB) RemoveID(correlationID, secret string) error {
 		return errors.New("invalid correlation-id cache value found")
 	}
 	if !strings.EqualFold(value.SecretKey, secret) {
-		return errors.New("invalid secret key passed for deregister")
+		// a reader deregistering only revokes its own access
+		value.Lock()
+		_, ok := value.Readers[strings.ToLower(secret)]
+		delete(value.Readers, strings.ToLower(secret))
+		value.Unlock()
+		if !ok {
+			return errors.New("invalid secret key passed for deregister")
+		}
+		if s.Options.UseDisk() {
+			return s.db.Delete([]byte(readerKey(correlationID, secret)), nil)
+		}
+		return nil
 	}
 	value.Lock()
 	value.Data = nil
+	readers := value.Readers
+	value.Readers = nil
 	value.Unlock()
+	s.sessions.Delete(correlationID)
 	s.cache.Invalidate(correlationID)
 
 	if s.Options.UseDisk() {
+		for readerSecret := range readers {
+			_ = s.db.Delete([]byte(readerKey(correlationID, readerSecret)), nil)
+		}
 		return s.db.Delete([]byte(correlationID), nil)
 	}
 	return nil
@@ -253,6 +520,20 @@ func (s *StorageDB) getInteractions(correlationData *CorrelationData, id string)
 	correlationData.Lock()
 	defer correlationData.Unlock()
 
+	return s.popInteractions(&correlationData.Data, correlationData.AESKey, id)
+}
+
+// getReaderInteractions returns the interactions queued for a reader and empties its queue
+func (s *StorageDB) getReaderInteractions(correlationData *CorrelationData, reader *SharedReader, id string) ([]string, error) {
+	correlationData.Lock()
+	defer correlationData.Unlock()
+
+	return s.popInteractions(&reader.Data, correlationData.AESKey, id)
+}
+
+// popInteractions returns the encrypted interactions from the in-memory queue or
+// the disk storage key and empties them. The caller must hold the correlation lock.
+func (s *StorageDB) popInteractions(queue *[]string, aesKey []byte, id string) ([]string, error) {
 	switch {
 	case s.Options.UseDisk():
 		data, err := s.db.Get([]byte(id), nil)
@@ -271,14 +552,14 @@ func (s *StorageDB) getInteractions(correlationData *CorrelationData, id string)
 	default:
 		// in memory data
 		var errs []error
-		data := correlationData.Data
-		correlationData.Data = nil
+		data := *queue
+		*queue = nil
 		if len(data) == 0 {
 			return nil, nil
 		}
 
 		for i, dataItem := range data {
-			encryptedDataItem, err := AESEncrypt(correlationData.AESKey, []byte(dataItem))
+			encryptedDataItem, err := AESEncrypt(aesKey, []byte(dataItem))
 			if err != nil {
 				errs = append(errs, errors.Wrap(err, "could not encrypt event data"))
 				data[i] = dataItem
@@ -292,11 +573,15 @@ func (s *StorageDB) getInteractions(correlationData *CorrelationData, id string)
 
 func (s *StorageDB) Close() error {
 	var errdbClosed error
+	if s.done != nil {
+		close(s.done)
+	}
 	if s.db != nil {
 		errdbClosed = s.db.Close()
 	}
 	return multierr.Combine(
 		s.cache.Close(),
+		s.evicted.Close(),
 		errdbClosed,
 		os.RemoveAll(s.dbpath),
 	)
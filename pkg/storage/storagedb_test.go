@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"crypto"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
@@ -9,6 +10,7 @@ import (
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/pem"
+	"errors"
 	"strconv"
 	"testing"
 	"time"
@@ -117,6 +119,158 @@ func TestStorageAddGetInteractions(t *testing.T) {
 	require.Equal(t, dataOriginal, decoded, "could not get correct decrypted interaction")
 }
 
+func TestStorageShareID(t *testing.T) {
+	mem, err := New(&Options{EvictionTTL: 1 * time.Hour})
+	require.Nil(t, err)
+
+	secret := uuid.New().String()
+	readerSecret := uuid.New().String()
+	correlationID := xid.New().String()
+
+	owner, ownerEncoded := newTestRSAKey(t)
+	reader, readerEncoded := newTestRSAKey(t)
+
+	err = mem.SetIDPublicKey(correlationID, secret, ownerEncoded)
+	require.Nil(t, err, "could not set correlation-id and rsa public key in storage")
+
+	err = mem.ShareID(correlationID, uuid.New().String(), readerSecret, readerEncoded)
+	require.NotNil(t, err, "could share with invalid owner secret")
+
+	err = mem.ShareID(correlationID, secret, readerSecret, readerEncoded)
+	require.Nil(t, err, "could not share correlation-id")
+
+	dataOriginal := []byte("hello world, this is unencrypted interaction")
+	err = mem.AddInteraction(correlationID, dataOriginal)
+	require.Nil(t, err, "could not add interaction to storage")
+
+	// both the owner and the reader receive their own copy of the interaction
+	for _, poller := range []struct {
+		secret string
+		key    *rsa.PrivateKey
+	}{{secret, owner}, {readerSecret, reader}} {
+		data, key, err := mem.GetInteractions(correlationID, poller.secret)
+		require.Nil(t, err, "could not get interaction from storage")
+		require.Len(t, data, 1, "could not get interaction")
+		require.Equal(t, dataOriginal, decryptTestInteraction(t, poller.key, key, data[0]), "could not get correct decrypted interaction")
+	}
+
+	err = mem.RemoveID(correlationID, readerSecret)
+	require.Nil(t, err, "could not remove reader")
+	_, _, err = mem.GetInteractions(correlationID, readerSecret)
+	require.NotNil(t, err, "could get interactions with removed reader")
+	_, _, err = mem.GetInteractions(correlationID, secret)
+	require.Nil(t, err, "could not get interactions after reader removal")
+}
+
+func TestStorageSetIDPublicKeyConflict(t *testing.T) {
+	mem, err := New(&Options{EvictionTTL: 1 * time.Hour})
+	require.Nil(t, err)
+
+	secret := uuid.New().String()
+	correlationID := xid.New().String()
+	_, encoded := newTestRSAKey(t)
+	_, otherEncoded := newTestRSAKey(t)
+
+	require.Nil(t, mem.SetIDPublicKey(correlationID, secret, encoded))
+	require.ErrorIs(t, mem.SetIDPublicKey(correlationID, secret, encoded), ErrCorrelationIdExists)
+	require.ErrorIs(t, mem.SetIDPublicKey(correlationID, secret, otherEncoded), ErrCorrelationIdConflict)
+	require.ErrorIs(t, mem.SetIDPublicKey(correlationID, uuid.New().String(), encoded), ErrCorrelationIdConflict)
+}
+
+func TestStorageAddInteractionEvicted(t *testing.T) {
+	mem, err := New(&Options{EvictionTTL: 1 * time.Hour})
+	require.Nil(t, err)
+
+	correlationID := xid.New().String()
+	_, encoded := newTestRSAKey(t)
+	require.Nil(t, mem.SetIDPublicKey(correlationID, uuid.New().String(), encoded))
+	mem.cache.Invalidate(correlationID)
+
+	err = mem.AddInteraction(correlationID, []byte("interaction"))
+	require.ErrorIs(t, err, ErrCorrelationIdEvicted)
+	require.ErrorIs(t, err, ErrCorrelationIdNotFound)
+	// the evicted sessions are no longer indexed
+	for i := 0; i < 500; i++ {
+		if _, indexed := mem.sessions.Load(correlationID); !indexed {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	_, indexed := mem.sessions.Load(correlationID)
+	require.False(t, indexed)
+	require.ErrorIs(t, mem.AddInteraction(correlationID, []byte("interaction")), ErrCorrelationIdEvicted)
+	err = mem.AddInteraction(xid.New().String(), []byte("interaction"))
+	require.ErrorIs(t, err, ErrCorrelationIdNotFound)
+	require.False(t, errors.Is(err, ErrCorrelationIdEvicted))
+}
+
+func TestStorageSessionsBan(t *testing.T) {
+	mem, err := New(&Options{EvictionTTL: 1 * time.Hour})
+	require.Nil(t, err)
+
+	secret := uuid.New().String()
+	correlationID := xid.New().String()
+	_, encoded := newTestRSAKey(t)
+
+	require.Nil(t, mem.SetIDPublicKey(correlationID, secret, encoded))
+	require.Nil(t, mem.AddInteraction(correlationID, []byte("interaction")))
+	sessions := mem.Sessions()
+	require.Len(t, sessions, 1)
+	require.Equal(t, correlationID, sessions[0].CorrelationID)
+	require.Equal(t, 1, sessions[0].Interactions)
+
+	require.Nil(t, mem.Ban(correlationID))
+	require.Empty(t, mem.Sessions())
+	require.Equal(t, []string{correlationID}, mem.Bans())
+	require.ErrorIs(t, mem.SetIDPublicKey(correlationID, secret, encoded), ErrCorrelationIdBanned)
+
+	require.Nil(t, mem.Unban(correlationID))
+	require.NotNil(t, mem.Unban(correlationID))
+	require.Empty(t, mem.Bans())
+
+	otherID := xid.New().String()
+	require.Nil(t, mem.SetIDPublicKey(otherID, secret, encoded))
+	require.Nil(t, mem.Evict(otherID))
+	require.ErrorIs(t, mem.Evict(otherID), ErrCorrelationIdNotFound)
+}
+
+func newTestRSAKey(t *testing.T) (*rsa.PrivateKey, string) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err, "could not generate rsa key")
+
+	pubkeyBytes, err := x509.MarshalPKIXPublicKey(priv.Public())
+	require.Nil(t, err, "could not marshal public key")
+
+	pubkeyPem := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PUBLIC KEY",
+		Bytes: pubkeyBytes,
+	})
+	return priv, base64.StdEncoding.EncodeToString(pubkeyPem)
+}
+
+func decryptTestInteraction(t *testing.T, priv *rsa.PrivateKey, key, data string) []byte {
+	decodedKey, err := base64.StdEncoding.DecodeString(key)
+	require.Nil(t, err, "could not decode key")
+
+	keyPlaintext, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, priv, decodedKey, nil)
+	require.Nil(t, err, "could not decrypt key to plaintext")
+
+	cipherText, err := base64.StdEncoding.DecodeString(data)
+	require.Nil(t, err, "could not decode ciphertext")
+
+	block, err := aes.NewCipher(keyPlaintext)
+	require.Nil(t, err, "could not create aes cipher")
+	require.GreaterOrEqual(t, len(cipherText), aes.BlockSize, "cipher text is less than block size")
+
+	iv := cipherText[:aes.BlockSize]
+	cipherText = cipherText[aes.BlockSize:]
+
+	stream := cipher.NewCFBDecrypter(block, iv)
+	decoded := make([]byte, len(cipherText))
+	stream.XORKeyStream(decoded, cipherText)
+	return decoded
+}
+
 func BenchmarkCacheParallelOther(b *testing.B) {
 	cache := cache.New(cache.WithMaximumSize(DefaultOptions.MaxSize), cache.WithExpireAfterWrite(24*7*time.Hour))
 
@@ -133,3 +287,91 @@ func doStuffWithOtherCache(cache cache.Cache) {
 		_, _ = cache.GetIfPresent(strconv.Itoa(i))
 	}
 }
+
+func TestStorageDNSScript(t *testing.T) {
+	db, err := New(&Options{EvictionTTL: 1 * time.Hour})
+	require.Nil(t, err)
+	defer db.Close()
+
+	_, encoded := newTestRSAKey(t)
+	secret := uuid.New().String()
+	correlationID := xid.New().String()
+	require.Nil(t, db.SetIDPublicKey(correlationID, secret, encoded))
+	require.NotNil(t, db.SetDNSScript(correlationID, "wrong", "rebind1", []string{"127.0.0.1"}))
+	require.Nil(t, db.SetDNSScript(correlationID, secret, "Rebind1", []string{"203.0.113.10", "2001:db8::1", "127.0.0.1"}))
+
+	item, err := db.GetCacheItem(correlationID)
+	require.Nil(t, err)
+	for _, expected := range []string{"203.0.113.10", "127.0.0.1", "127.0.0.1"} {
+		answer, ok := item.NextDNSAnswer("rebind1", false)
+		require.True(t, ok)
+		require.Equal(t, expected, answer)
+	}
+	answer, ok := item.NextDNSAnswer("rebind1", true)
+	require.True(t, ok)
+	require.Equal(t, "2001:db8::1", answer)
+	_, ok = item.NextDNSAnswer("other", false)
+	require.False(t, ok)
+
+	require.Nil(t, db.SetDNSScript(correlationID, secret, "rebind1", nil))
+	_, ok = item.NextDNSAnswer("rebind1", false)
+	require.False(t, ok)
+}
+
+func TestStorageHTTPTemplate(t *testing.T) {
+	db, err := New(&Options{EvictionTTL: 1 * time.Hour})
+	require.Nil(t, err)
+	defer db.Close()
+
+	priv, encoded := newTestRSAKey(t)
+	other, otherEncoded := newTestRSAKey(t)
+	secret := uuid.New().String()
+	correlationID := xid.New().String()
+	require.Nil(t, db.SetIDPublicKey(correlationID, secret, encoded))
+
+	template := &HTTPTemplate{Status: 201, ContentType: "text/html", Headers: map[string]string{"X-B": "2", "X-A": "1"}, Body: "<script>alert(1)</script>"}
+	sign := func(key *rsa.PrivateKey) []byte {
+		digest := sha256.Sum256(template.SignedData())
+		signature, err := rsa.SignPSS(rand.Reader, key, crypto.SHA256, digest[:], nil)
+		require.Nil(t, err)
+		return signature
+	}
+	require.NotNil(t, db.SetHTTPTemplate(correlationID, "wrong", encoded, template, sign(priv)))
+	require.NotNil(t, db.SetHTTPTemplate(correlationID, secret, encoded, template, sign(other)), "a signature of another key should be rejected")
+	require.NotNil(t, db.SetHTTPTemplate(correlationID, secret, otherEncoded, template, sign(other)), "a key other than the registered one should be rejected")
+	require.Nil(t, db.SetHTTPTemplate(correlationID, secret, encoded, template, sign(priv)))
+
+	item, err := db.GetCacheItem(correlationID)
+	require.Nil(t, err)
+	require.Equal(t, template, item.GetHTTPTemplate())
+
+	require.Nil(t, db.SetHTTPTemplate(correlationID, secret, "", nil, nil))
+	require.Nil(t, item.GetHTTPTemplate())
+}
+
+func TestStoragePayloadFiles(t *testing.T) {
+	db, err := New(&Options{EvictionTTL: 1 * time.Hour})
+	require.Nil(t, err)
+	defer db.Close()
+
+	_, encoded := newTestRSAKey(t)
+	secret := uuid.New().String()
+	correlationID := xid.New().String()
+	require.Nil(t, db.SetIDPublicKey(correlationID, secret, encoded))
+	require.NotNil(t, db.SetPayloadFile(correlationID, "wrong", "x.js", &PayloadFile{Data: []byte("alert(1)")}))
+	for i := 0; i < MaxPayloadFiles; i++ {
+		require.Nil(t, db.SetPayloadFile(correlationID, secret, strconv.Itoa(i)+".js", &PayloadFile{Data: []byte("alert(1)")}))
+	}
+	require.NotNil(t, db.SetPayloadFile(correlationID, secret, "x.js", &PayloadFile{Data: []byte("alert(1)")}), "the number of files should be limited")
+	require.Nil(t, db.SetPayloadFile(correlationID, secret, "0.js", &PayloadFile{Data: []byte("alert(2)")}), "a file should be replaceable at the limit")
+
+	item, err := db.GetCacheItem(correlationID)
+	require.Nil(t, err)
+	file, ok := item.GetPayloadFile("0.js")
+	require.True(t, ok)
+	require.Equal(t, "alert(2)", string(file.Data))
+
+	require.Nil(t, db.SetPayloadFile(correlationID, secret, "0.js", nil))
+	_, ok = item.GetPayloadFile("0.js")
+	require.False(t, ok)
+}
@@ -1,8 +1,11 @@
 package storage
 
 import (
+	"strings"
 	"sync"
 	"time"
+
+	jsoniter "github.com/json-iterator/go"
 )
 
 type GetInteractionsFunc func() []string
@@ -27,4 +30,160 @@ type CorrelationData struct {
 	AESKeyEncrypted string `json:"aes-key"`
 	// decrypted AES key for signing
 	AESKey []byte `json:"-"`
+	// Readers are the teammates granted read access keyed by their secret key
+	Readers map[string]*SharedReader `json:"-"`
+	// CNAME is the external host names of the correlation-id resolve to
+	CNAME string `json:"-"`
+	// DNSScripts are the answers of the subdomain labels of the correlation-id, by label
+	DNSScripts map[string]*DNSScript `json:"-"`
+	// HTTPTemplate is the response served to the http requests of the correlation-id, if any
+	HTTPTemplate *HTTPTemplate `json:"-"`
+	// PayloadFiles are the files hosted for the correlation-id, by name
+	PayloadFiles map[string]*PayloadFile `json:"-"`
+	// InspectKey is the key of the inspect page of the correlation-id, empty when disabled
+	InspectKey string `json:"-"`
+	// PublicKeyHash is the sha256 of the public key the correlation-id was registered with
+	PublicKeyHash string `json:"-"`
+}
+
+// registeredBy returns true if the correlation-id belongs to the owner
+// of the secret key and public key, or to one of its readers
+func (c *CorrelationData) registeredBy(secretKey, publicKey string) bool {
+	c.Lock()
+	defer c.Unlock()
+
+	if _, ok := c.Readers[strings.ToLower(secretKey)]; ok {
+		return true
+	}
+	if !strings.EqualFold(c.SecretKey, secretKey) {
+		return false
+	}
+	return c.PublicKeyHash == "" || c.PublicKeyHash == publicKeyHash(publicKey)
+}
+
+// ReadableBy returns true if the secret key is the one of the owner of the
+// correlation-id or of one of its readers
+func (c *CorrelationData) ReadableBy(secretKey string) bool {
+	c.Lock()
+	defer c.Unlock()
+
+	if _, ok := c.Readers[strings.ToLower(secretKey)]; ok {
+		return true
+	}
+	return secretKey != "" && strings.EqualFold(c.SecretKey, secretKey)
+}
+
+// MaxDNSScripts is the number of labels that can be scripted per correlation-id
+var MaxDNSScripts = 64
+
+// DNSScript is the sequence of addresses answered for a subdomain label of a
+// correlation-id, one per lookup, the last one being repeated. The lookups of
+// each address family advance separately.
+type DNSScript struct {
+	Answers []string
+	lookups map[bool]int
+}
+
+// NextDNSAnswer returns the answer of the next lookup of a label for the ipv4
+// or ipv6 family, and whether the label is scripted at all
+func (c *CorrelationData) NextDNSAnswer(label string, ipv6 bool) (string, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	script, ok := c.DNSScripts[label]
+	if !ok {
+		return "", false
+	}
+	var answers []string
+	for _, answer := range script.Answers {
+		if strings.Contains(answer, ":") == ipv6 {
+			answers = append(answers, answer)
+		}
+	}
+	if len(answers) == 0 {
+		return "", true
+	}
+	if script.lookups == nil {
+		script.lookups = make(map[bool]int)
+	}
+	index := script.lookups[ipv6]
+	if index >= len(answers) {
+		index = len(answers) - 1
+	}
+	script.lookups[ipv6]++
+	return answers[index], true
+}
+
+// HTTPTemplate is a response uploaded by the owner of a correlation-id to be
+// served to the http requests of its payloads, eg. for stored-xss or ssrf tests
+type HTTPTemplate struct {
+	// Status is the status code of the response, 200 if zero
+	Status int `yaml:"status,omitempty" json:"status,omitempty"`
+	// ContentType is the value of the Content-Type header, if any
+	ContentType string `yaml:"content-type,omitempty" json:"content-type,omitempty"`
+	// Headers are additional headers sent with the response
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	// Body is the response body
+	Body string `yaml:"body,omitempty" json:"body,omitempty"`
+}
+
+// SignedData returns the canonical encoding of the template signed by the
+// private key of the session owner
+func (t *HTTPTemplate) SignedData() []byte {
+	// the standard library configuration sorts the headers
+	data, _ := jsoniter.ConfigCompatibleWithStandardLibrary.Marshal(t)
+	return data
+}
+
+// GetHTTPTemplate returns the http template of the correlation-id, if any
+func (c *CorrelationData) GetHTTPTemplate() *HTTPTemplate {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.HTTPTemplate
+}
+
+// MaxPayloadFiles is the number of files that can be hosted per correlation-id
+var MaxPayloadFiles = 16
+
+// PayloadFile is a file uploaded by the owner of a correlation-id to be served
+// under /<correlation-id>/<name>, eg. a javascript or dtd loaded by a target
+type PayloadFile struct {
+	// ContentType is the value of the Content-Type header, guessed from the name if empty
+	ContentType string `json:"content-type,omitempty"`
+	// Data is the content of the file
+	Data []byte `json:"data"`
+}
+
+// GetInspectKey returns the key of the inspect page of the correlation-id
+func (c *CorrelationData) GetInspectKey() string {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.InspectKey
+}
+
+// GetPayloadFile returns a file hosted for the correlation-id
+func (c *CorrelationData) GetPayloadFile(name string) (*PayloadFile, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	file, ok := c.PayloadFiles[name]
+	return file, ok
+}
+
+// SharedReader is a teammate granted read access to a correlation-id.
+type SharedReader struct {
+	// Data contains pending interactions for the reader in json format.
+	Data []string
+	// AESKeyEncrypted is the session AES key encrypted with the reader public key.
+	AESKeyEncrypted string
+}
+
+// SessionSummary is the administrative summary of a registered session
+type SessionSummary struct {
+	CorrelationID string `json:"correlation-id"`
+	Interactions  int    `json:"interactions"`
+	Readers       int    `json:"readers"`
+	CNAME         string `json:"cname,omitempty"`
 }
@@ -6,13 +6,21 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/pem"
 	"errors"
 	"io"
 )
 
+// publicKeyHash returns the sha256 of a base64 encoded public key
+func publicKeyHash(publicKey string) string {
+	sum := sha256.Sum256([]byte(publicKey))
+	return hex.EncodeToString(sum[:])
+}
+
 // ParseB64RSAPublicKeyFromPEM parses a base64 encoded rsa pem to a public key structure
 func ParseB64RSAPublicKeyFromPEM(pubPEM string) (*rsa.PublicKey, error) {
 	decoded, err := base64.StdEncoding.DecodeString(pubPEM)